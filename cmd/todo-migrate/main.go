@@ -0,0 +1,113 @@
+// Command todo-migrate applies numbered migrations to the DynamoDB todos tables.
+//
+// Applied migrations are recorded in the todos-v2 table, so running it again only applies new
+// migrations. An interrupted run resumes from the last page it wrote. Use -endpoint to run against
+// DynamoDB Local, for example:
+//
+//	todo-migrate -endpoint http://localhost:8000 -create-table -dry-run
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+)
+
+func main() {
+
+	endpoint := flag.String("endpoint", "", "DynamoDB endpoint, e.g. http://localhost:8000 for DynamoDB Local")
+	region := flag.String("region", "us-west-2", "AWS region")
+	target := flag.Int("target", 0, "apply migrations up to and including this version (0 applies all)")
+	segments := flag.Int("segments", 1, "number of parallel scan segments")
+	dryRun := flag.Bool("dry-run", false, "report changes without writing")
+	pending := flag.Bool("pending", false, "list pending migrations and exit")
+	createTable := flag.Bool("create-table", false, "create the todos table if it does not exist")
+	flag.Parse()
+
+	config := aws.NewConfig().WithRegion(*region).WithMaxRetries(0)
+	if *endpoint != "" {
+		config = config.WithEndpoint(*endpoint)
+	}
+
+	s, err := session.NewSession(config)
+	if err != nil {
+		exit(err)
+	}
+
+	db := awsdynamodb.New(s)
+
+	if *createTable {
+		if err := dynamodb.CreateTable(db); err != nil {
+			exit(err)
+		}
+	}
+
+	m := dynamodb.NewMigrator(db, dynamodb.Migrations)
+	m.Segments = *segments
+	m.DryRun = *dryRun
+
+	if *pending {
+		migrations, err := m.Pending(*target)
+		if err != nil {
+			exit(err)
+		}
+
+		for _, mig := range migrations {
+			fmt.Printf("%04d %s\n", mig.Version, mig.Description)
+		}
+
+		return
+	}
+
+	results, err := m.Run(*target)
+	printResults(results, *dryRun)
+	if err != nil {
+		exit(err)
+	}
+}
+
+// printResults writes a summary of each migration to stdout
+func printResults(results []dynamodb.MigrationResult, dryRun bool) {
+	if len(results) == 0 {
+		fmt.Println("No pending migrations")
+		return
+	}
+
+	if dryRun {
+		fmt.Println("Dry run, nothing was written")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "VERSION\tSCANNED\tCHANGED\tUNCHANGED\tSKIPPED\tDESCRIPTION")
+
+	for _, r := range results {
+		fmt.Fprintf(w, "%04d\t%d\t%d\t%d\t%d\t%s\n",
+			r.Version, r.Scanned, r.Changed, r.Unchanged, r.Skipped, r.Description)
+
+		attrs := []string{}
+		for a := range r.Diff {
+			attrs = append(attrs, a)
+		}
+		sort.Strings(attrs)
+
+		for _, a := range attrs {
+			fmt.Fprintf(w, "\t\t\t\t\t  %s on %d items\n", a, r.Diff[a])
+		}
+	}
+
+	w.Flush()
+}
+
+// exit prints err and exits with a non-zero status
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,286 @@
+// Command todo-server serves the todo API and CalDAV over plain HTTP, for self-hosted deployments and
+// local testing.
+//
+// ToDos are stored in a directory of flat files, or in DynamoDB when -endpoint or -dynamodb is given.
+// CalDAV clients sign in with the -user and -password given, for example:
+//
+//	todo-server -dir ./todos -user me -password secret
+//
+// and then add a CalDAV account for http://localhost:8080/caldav/ to their task app. Changes are pushed to
+// WebSocket clients connected to ws://localhost:8080/ws?list=<list>, and streamed as Server-Sent Events
+// from http://localhost:8080/todos/events?list=<list>.
+//
+// Attachments are kept in a directory given with -blobs, which the server serves presigned URLs for, or in
+// the S3 bucket given with -bucket, which can be on a local S3-compatible store given with -s3-endpoint.
+package main
+
+import (
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/blob"
+	"github.com/benjaminbartels/todo/internal/blob/filesystem"
+	"github.com/benjaminbartels/todo/internal/blob/s3"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/database/attachments"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+	"github.com/benjaminbartels/todo/internal/database/flatfile"
+	"github.com/benjaminbartels/todo/internal/database/notify"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+	"github.com/benjaminbartels/todo/internal/realtime"
+	"github.com/benjaminbartels/todo/internal/server"
+)
+
+func main() {
+
+	addr := flag.String("addr", ":8080", "address to listen on")
+	dir := flag.String("dir", "todos", "directory of ToDo files")
+	yaml := flag.Bool("yaml", false, "write ToDo files as YAML instead of JSON")
+	useDynamoDB := flag.Bool("dynamodb", false, "store ToDos in DynamoDB instead of files")
+	endpoint := flag.String("endpoint", "", "DynamoDB endpoint, e.g. http://localhost:8000 for DynamoDB Local")
+	region := flag.String("region", "us-west-2", "AWS region")
+	user := flag.String("user", "", "user name CalDAV clients sign in with")
+	password := flag.String("password", "", "password CalDAV clients sign in with")
+	blobDir := flag.String("blobs", "", "directory of attachment content")
+	blobURL := flag.String("blob-url", "http://localhost:8080/blobs", "URL the -blobs directory is served at")
+	bucket := flag.String("bucket", "", "S3 bucket of attachment content, instead of a directory")
+	s3Endpoint := flag.String("s3-endpoint", "", "S3 endpoint, e.g. http://localhost:9000 for MinIO")
+	flag.Parse()
+
+	var (
+		repo      database.ToDoRepo
+		todos     database.ToDoRepoProvider
+		feeds     database.FeedTokenRepo
+		webhooks  database.WebhookRepo
+		comments  database.CommentRepo
+		members   database.MemberRepo
+		assigned  database.AssignedToDoFinder
+		entries   database.TimeEntryRepo
+		templates database.TemplateRepo
+	)
+
+	if *useDynamoDB || *endpoint != "" {
+		config := aws.NewConfig().WithRegion(*region).WithMaxRetries(0)
+		if *endpoint != "" {
+			config = config.WithEndpoint(*endpoint)
+		}
+
+		s, err := session.NewSession(config)
+		if err != nil {
+			exit(err)
+		}
+
+		db := awsdynamodb.New(s)
+		r := dynamodb.NewToDoRepo(db)
+
+		repo = r
+		todos = func(listID string) database.ToDoRepo {
+			return r.ForList(listID)
+		}
+		feeds = dynamodb.NewFeedTokenRepo(db)
+		webhooks = dynamodb.NewWebhookRepo(db)
+		comments = dynamodb.NewCommentRepo(db)
+		members = dynamodb.NewMemberRepo(db)
+		assigned = r
+		entries = dynamodb.NewTimeEntryRepo(db)
+		templates = dynamodb.NewTemplateRepo(db)
+	} else {
+		format := flatfile.JSON
+		if *yaml {
+			format = flatfile.YAML
+		}
+
+		r, err := flatfile.NewToDoRepo(*dir, format)
+		if err != nil {
+			exit(err)
+		}
+
+		// A directory holds a single list
+		repo = r
+		todos = func(listID string) database.ToDoRepo {
+			if listID != internal.DefaultListID {
+				return nil
+			}
+			return r
+		}
+	}
+
+	var (
+		store blob.Store
+		blobs http.Handler
+	)
+
+	switch {
+	case *bucket != "":
+		config := aws.NewConfig().WithRegion(*region)
+		if *s3Endpoint != "" {
+			config = config.WithEndpoint(*s3Endpoint).WithS3ForcePathStyle(true)
+		}
+
+		s, err := session.NewSession(config)
+		if err != nil {
+			exit(err)
+		}
+
+		store = s3.NewStore(awss3.New(s), *bucket)
+	case *blobDir != "":
+		// Presigned URLs are only valid until the server restarts
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			exit(err)
+		}
+
+		fs, err := filesystem.NewStore(*blobDir, *blobURL, secret)
+		if err != nil {
+			exit(err)
+		}
+
+		store, blobs = fs, fs
+	}
+
+	// Remove the attachments of the ToDos deleted through every route
+	if store != nil {
+		repo = attachments.NewToDoRepo(repo, store)
+		provider := todos
+		todos = func(listID string) database.ToDoRepo {
+			if r := provider(listID); r != nil {
+				return attachments.NewToDoRepo(r, store)
+			}
+			return nil
+		}
+	}
+
+	// Publish the changes made through every route
+	hub := realtime.NewHub()
+	repo = notify.NewToDoRepo(repo, hub)
+	provider := todos
+	todos = func(listID string) database.ToDoRepo {
+		if r := provider(listID); r != nil {
+			return notify.NewToDoRepo(r, hub)
+		}
+		return nil
+	}
+
+	toDoHandler := handlers.NewToDoHandlerWithMembers(repo, members)
+	calDAVHandler := handlers.NewCalDAVHandler(todos)
+
+	routes := []server.Route{
+		{Resource: "/todos/export", Handler: toDoHandler.Handle},
+		{Resource: "/todos/import", Handler: toDoHandler.Handle},
+		{Resource: "/todos/changes", Handler: toDoHandler.Handle},
+		{Resource: "/todos/sync", Handler: toDoHandler.Handle},
+		{Resource: "/todos/{id}/checklist/{itemId}", Handler: toDoHandler.Handle},
+		{Resource: "/todos/{id}/checklist", Handler: toDoHandler.Handle},
+		{Resource: "/todos/{id}/blockers/{blockerId}", Handler: toDoHandler.Handle},
+		{Resource: "/todos/{id}/blockers", Handler: toDoHandler.Handle},
+		{Resource: "/todos/{id}/graph", Handler: toDoHandler.Handle},
+		{Resource: "/todos/{id}", Handler: toDoHandler.Handle},
+		{Resource: "/todos", Handler: toDoHandler.Handle},
+		{Resource: "/tags/merge", Handler: toDoHandler.Handle},
+		{Resource: "/tags/{tag}", Handler: toDoHandler.Handle},
+		{Resource: "/tags", Handler: toDoHandler.Handle},
+		{Resource: "/.well-known/caldav", Handler: calDAVHandler.Handle},
+		{Resource: "/caldav", Handler: calDAVHandler.Handle},
+		{Resource: "/caldav/{proxy+}", Handler: calDAVHandler.Handle},
+	}
+
+	if store != nil {
+		attachmentHandler := handlers.NewAttachmentHandler(repo, store)
+		routes = append([]server.Route{
+			{Resource: "/todos/{id}/attachments/{attachmentId}", Handler: attachmentHandler.Handle},
+			{Resource: "/todos/{id}/attachments", Handler: attachmentHandler.Handle},
+		}, routes...)
+	}
+
+	if comments != nil {
+		commentHandler := handlers.NewCommentHandler(repo, comments, members)
+		// The user signed in with -user owns the ToDos that were stored before there were lists
+		memberHandler := handlers.NewMemberHandler(members, *user)
+		routes = append([]server.Route{
+			{Resource: "/todos/{id}/comments/{commentId}", Handler: commentHandler.Handle},
+			{Resource: "/todos/{id}/comments", Handler: commentHandler.Handle},
+		}, routes...)
+		assignmentHandler := handlers.NewAssignmentHandler(assigned, members)
+		routes = append(routes,
+			server.Route{Resource: "/lists/{listId}/members/{userId}", Handler: memberHandler.Handle},
+			server.Route{Resource: "/lists/{listId}/members", Handler: memberHandler.Handle},
+			server.Route{Resource: "/me/todos", Handler: assignmentHandler.Handle})
+	}
+
+	if entries != nil {
+		timeHandler := handlers.NewTimeHandler(repo, entries, todos)
+		routes = append([]server.Route{
+			{Resource: "/todos/{id}/timer/start", Handler: timeHandler.Handle},
+			{Resource: "/todos/{id}/timer/stop", Handler: timeHandler.Handle},
+			{Resource: "/todos/{id}/time/{entryId}", Handler: timeHandler.Handle},
+			{Resource: "/todos/{id}/time", Handler: timeHandler.Handle},
+		}, routes...)
+		routes = append(routes,
+			server.Route{Resource: "/me/timer", Handler: timeHandler.Handle},
+			server.Route{Resource: "/reports/time", Handler: timeHandler.Handle})
+	}
+
+	if templates != nil {
+		templateHandler := handlers.NewTemplateHandler(templates, repo)
+		routes = append(routes,
+			server.Route{Resource: "/templates/{id}/instantiate", Handler: templateHandler.Handle},
+			server.Route{Resource: "/templates/{id}", Handler: templateHandler.Handle},
+			server.Route{Resource: "/templates", Handler: templateHandler.Handle})
+	}
+
+	if feeds != nil {
+		feedHandler := handlers.NewFeedHandler(feeds, todos, members)
+		routes = append(routes,
+			server.Route{Resource: "/feeds/{token}", Handler: feedHandler.Handle},
+			server.Route{Resource: "/feeds", Handler: feedHandler.Handle})
+	}
+
+	if webhooks != nil {
+		webhookHandler := handlers.NewWebhookHandler(webhooks, members)
+		routes = append(routes,
+			server.Route{Resource: "/webhooks/{id}/deliveries", Handler: webhookHandler.Handle},
+			server.Route{Resource: "/webhooks/{id}", Handler: webhookHandler.Handle},
+			server.Route{Resource: "/webhooks", Handler: webhookHandler.Handle})
+	}
+
+	var auth server.Authenticator
+	if *user != "" {
+		auth = server.BasicAuth(*user, *password)
+	}
+
+	mux := http.NewServeMux()
+	// Streams carry the ToDos of every list, so they are authenticated like the API
+	mux.Handle("/ws", server.RequireAuth(auth, server.WebSocket(hub)))
+	mux.Handle("/todos/events", server.RequireAuth(auth, server.Events(hub)))
+	if blobs != nil {
+		u, err := url.Parse(*blobURL)
+		if err != nil {
+			exit(err)
+		}
+		mux.Handle(strings.TrimSuffix(u.Path, "/")+"/", blobs)
+	}
+	mux.Handle("/", server.New(routes, auth))
+
+	log.Printf("Listening on %s", *addr)
+
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		exit(err)
+	}
+}
+
+// exit prints err and exits with a non-zero status
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,69 @@
+terraform {
+  source = "github.com/benjaminbartels/terraform-modules.git//dynamodb"
+}
+
+# Must match TableDefinition in internal/database/dynamodb/schema.go. Existing items in the legacy
+# "todos" table, which is kept in ../dynamodb, are copied over with cmd/todo-migrate. The table has its
+# own state, so creating it does not replace the legacy table.
+inputs = {
+  name           = "todos-v2"
+  hash_key       = "listId"
+  range_key      = "id"
+  read_capacity  = 5
+  write_capacity = 5
+  aws_region     = "us-west-2"
+
+  # The stream is consumed by the stream function, which needs the ToDo before and after each change
+  stream_enabled   = true
+  stream_view_type = "NEW_AND_OLD_IMAGES"
+
+  attributes = [
+    { name = "listId", type = "S" },
+    { name = "id", type = "S" },
+    { name = "listStatus", type = "S" },
+    { name = "modTime", type = "S" },
+    { name = "due", type = "S" },
+    { name = "assigneeId", type = "S" },
+    { name = "entryUserId", type = "S" },
+    { name = "start", type = "S" },
+  ]
+
+  global_secondary_indexes = [
+    {
+      name            = "completed-index"
+      hash_key        = "listStatus"
+      range_key       = "modTime"
+      projection_type = "ALL"
+      read_capacity   = 5
+      write_capacity  = 5
+    },
+    {
+      name            = "due-index"
+      hash_key        = "listId"
+      range_key       = "due"
+      projection_type = "ALL"
+      read_capacity   = 5
+      write_capacity  = 5
+    },
+    {
+      name            = "assignee-index"
+      hash_key        = "assigneeId"
+      range_key       = "modTime"
+      projection_type = "ALL"
+      read_capacity   = 5
+      write_capacity  = 5
+    },
+    {
+      name            = "time-index"
+      hash_key        = "entryUserId"
+      range_key       = "start"
+      projection_type = "ALL"
+      read_capacity   = 5
+      write_capacity  = 5
+    },
+  ]
+}
+
+include {
+  path = "${find_in_parent_folders()}"
+}
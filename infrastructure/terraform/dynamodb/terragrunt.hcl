@@ -2,6 +2,11 @@ terraform {
   source = "github.com/benjaminbartels/terraform-modules.git//dynamodb"
 }
 
+# The legacy table, which migration 1 of cmd/todo-migrate copies into todos-v2 in ../dynamodb-v2. Its
+# inputs must not change, as that would replace it and lose the ToDos not yet copied, and it can not be
+# destroyed. It is removed once the migration has run everywhere.
+prevent_destroy = true
+
 inputs = {
   name           = "todos"
   read_capacity  = 5
@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+)
+
+// NormalizeAssignees returns the IDs of the users assigned to a ToDo trimmed, sorted and without duplicates
+// or empty IDs, or nil if there are none
+func NormalizeAssignees(ids []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id != "" && !seen[id] {
+			seen[id] = true
+			normalized = append(normalized, id)
+		}
+	}
+
+	sort.Strings(normalized)
+
+	return normalized
+}
+
+// AssignedTo reports whether the ToDo is assigned to the user
+func (t *ToDo) AssignedTo(userID string) bool {
+	return stringIn(userID, t.AssigneeIDs)
+}
+
+// AddedAssignees returns the IDs in to that are not in from, in the order of to
+func AddedAssignees(from, to []string) []string {
+	var added []string
+	for _, id := range to {
+		if !stringIn(id, from) {
+			added = append(added, id)
+		}
+	}
+	return added
+}
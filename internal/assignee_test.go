@@ -0,0 +1,45 @@
+package internal_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+func TestAssignees(t *testing.T) {
+	t.Run("NormalizeAssignees", testNormalizeAssignees)
+	t.Run("AssignedTo", testAssignedTo)
+	t.Run("AddedAssignees", testAddedAssignees)
+}
+
+func testNormalizeAssignees(t *testing.T) {
+
+	got := internal.NormalizeAssignees([]string{" user-2", "user-1", "", "user-2 "})
+
+	if want := []string{"user-1", "user-2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+
+	if got := internal.NormalizeAssignees([]string{" "}); got != nil {
+		t.Fatalf("Expected nil, got %v", got)
+	}
+}
+
+func testAssignedTo(t *testing.T) {
+
+	todo := internal.ToDo{AssigneeIDs: []string{"user-1", "user-2"}}
+
+	if !todo.AssignedTo("user-2") || todo.AssignedTo("user-3") {
+		t.Fatalf("Unexpected assignment of %v", todo.AssigneeIDs)
+	}
+}
+
+func testAddedAssignees(t *testing.T) {
+
+	got := internal.AddedAssignees([]string{"user-1", "user-2"}, []string{"user-2", "user-3"})
+
+	if want := []string{"user-3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,26 @@
+package internal
+
+import "time"
+
+// Attachment is a file attached to a ToDo. Its content is kept in an object store and uploaded and
+// downloaded by clients directly, see package blob.
+type Attachment struct {
+	ID          string `json:"id" yaml:"id"`
+	Name        string `json:"name" yaml:"name"`
+	Size        int64  `json:"size" yaml:"size"`
+	ContentType string `json:"contentType" yaml:"contentType"`
+	// Checksum is the base64 encoded MD5 digest of the content, as sent in a Content-MD5 header. The object
+	// store rejects uploads that do not match it.
+	Checksum string    `json:"checksum" yaml:"checksum"`
+	Created  time.Time `json:"created" yaml:"created"`
+}
+
+// Attachment returns the index of the attachment with the given ID, or -1 if there is none
+func (t *ToDo) Attachment(id string) int {
+	for i, a := range t.Attachments {
+		if a.ID == id {
+			return i
+		}
+	}
+	return -1
+}
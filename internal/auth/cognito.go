@@ -0,0 +1,208 @@
+// Package auth verifies the tokens Cognito issues to signed in users, for API Gateway routes that can not
+// use a Cognito user pool authorizer, such as the $connect route of a WebSocket API.
+package auth
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, not signed by the user pool, expired or
+// issued to another client
+var ErrInvalidToken = errors.New("invalid token")
+
+const (
+	// keysTimeout is how long the user pool has to return its signing keys
+	keysTimeout = 5 * time.Second
+	// keysRefresh is how often the keys are fetched at most, so tokens signed with unknown keys can not be
+	// used to make the Verifier fetch them for every request
+	keysRefresh = time.Minute
+)
+
+// Verifier verifies the ID and access tokens of a Cognito user pool. It is safe for concurrent use.
+type Verifier struct {
+	issuer   string
+	clientID string
+	keysURL  string
+	client   *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+// NewVerifier returns a Verifier for the tokens the user pool with the given ID, in region, issues to the
+// app client with the given ID
+func NewVerifier(region, poolID, clientID string) *Verifier {
+	issuer := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, poolID)
+	return NewVerifierWithKeys(issuer, clientID, issuer+"/.well-known/jwks.json", &http.Client{Timeout: keysTimeout})
+}
+
+// NewVerifierWithKeys returns a Verifier for the tokens issuer issues to the app client with the given ID,
+// signed with the keys of the JSON Web Key Set at keysURL, which is fetched with client
+func NewVerifierWithKeys(issuer, clientID, keysURL string, client *http.Client) *Verifier {
+	return &Verifier{
+		issuer:   issuer,
+		clientID: clientID,
+		keysURL:  keysURL,
+		client:   client,
+	}
+}
+
+// header is the JOSE header of a token
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// claims are the claims of an ID or access token that are checked. ID tokens name the client in aud and
+// access tokens in client_id.
+type claims struct {
+	Sub      string `json:"sub"`
+	Issuer   string `json:"iss"`
+	Expires  int64  `json:"exp"`
+	TokenUse string `json:"token_use"`
+	Audience string `json:"aud"`
+	ClientID string `json:"client_id"`
+}
+
+// Verify returns the ID of the user a token was issued to, which is its sub claim
+func (v *Verifier) Verify(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidToken
+	}
+
+	var h header
+	if err := decodePart(parts[0], &h); err != nil || h.Alg != "RS256" {
+		return "", ErrInvalidToken
+	}
+
+	key, err := v.key(h.Kid)
+	if err != nil {
+		return "", err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return "", ErrInvalidToken
+	}
+
+	var c claims
+	if err := decodePart(parts[1], &c); err != nil {
+		return "", ErrInvalidToken
+	}
+
+	switch {
+	case c.Issuer != v.issuer, c.Sub == "", time.Now().Unix() >= c.Expires:
+		return "", ErrInvalidToken
+	case c.TokenUse == "id" && c.Audience == v.clientID:
+	case c.TokenUse == "access" && c.ClientID == v.clientID:
+	default:
+		return "", ErrInvalidToken
+	}
+
+	return c.Sub, nil
+}
+
+// key returns the signing key with the given ID. The keys are fetched again when a token is signed with a
+// key that is not known, as the user pool may have rotated its keys.
+func (v *Verifier) key(kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+
+	if v.keys != nil && time.Now().Sub(v.fetched) < keysRefresh {
+		return nil, ErrInvalidToken
+	}
+
+	keys, err := v.fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	v.keys, v.fetched = keys, time.Now()
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	return key, nil
+}
+
+// fetchKeys fetches the RSA keys of the JSON Web Key Set of the user pool, by key ID
+func (v *Verifier) fetchKeys() (map[string]*rsa.PublicKey, error) {
+	resp, err := v.client.Get(v.keysURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get signing keys")
+	}
+	defer resp.Body.Close() // nolint: errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("Could not get signing keys: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, errors.Wrap(err, "Could not decode signing keys")
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) > 4 {
+			continue
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	return keys, nil
+}
+
+// decodePart decodes a base64url encoded JSON part of a token into v
+func decodePart(part string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,197 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal/auth"
+)
+
+const (
+	testIssuer   = "https://cognito-idp.us-west-2.amazonaws.com/us-west-2_test"
+	testClientID = "client-1"
+)
+
+func TestVerifier(t *testing.T) {
+	t.Run("IDToken", testIDToken)
+	t.Run("AccessToken", testAccessToken)
+	t.Run("Invalid", testInvalid)
+	t.Run("KeysCached", testKeysCached)
+}
+
+// keyServer serves the JSON Web Key Set of key with the ID key-1, counting the requests for it
+func keyServer(t *testing.T, key *rsa.PrivateKey, fetches *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(fetches, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{ // nolint: errcheck
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key-1",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+}
+
+// sign returns a token with claims signed by key with the ID kid
+func sign(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	signed := encode(map[string]string{"alg": "RS256", "kid": kid}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// idClaims returns the claims of a valid ID token of user-1
+func idClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":       "user-1",
+		"iss":       testIssuer,
+		"aud":       testClientID,
+		"token_use": "id",
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+// newKey returns a new RSA key
+func newKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func testIDToken(t *testing.T) {
+
+	var fetches int32
+
+	key := newKey(t)
+	keys := keyServer(t, key, &fetches)
+	defer keys.Close()
+
+	v := auth.NewVerifierWithKeys(testIssuer, testClientID, keys.URL, keys.Client())
+
+	sub, err := v.Verify(sign(t, key, "key-1", idClaims()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sub != "user-1" {
+		t.Fatalf("Expected user-1, got %s", sub)
+	}
+}
+
+func testAccessToken(t *testing.T) {
+
+	var fetches int32
+
+	key := newKey(t)
+	keys := keyServer(t, key, &fetches)
+	defer keys.Close()
+
+	claims := idClaims()
+	delete(claims, "aud")
+	claims["token_use"] = "access"
+	claims["client_id"] = testClientID
+
+	sub, err := auth.NewVerifierWithKeys(testIssuer, testClientID, keys.URL, keys.Client()).Verify(sign(t, key, "key-1", claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sub != "user-1" {
+		t.Fatalf("Expected user-1, got %s", sub)
+	}
+}
+
+func testInvalid(t *testing.T) {
+
+	var fetches int32
+
+	key := newKey(t)
+	keys := keyServer(t, key, &fetches)
+	defer keys.Close()
+
+	with := func(name string, value interface{}) map[string]interface{} {
+		c := idClaims()
+		c[name] = value
+		return c
+	}
+
+	valid := sign(t, key, "key-1", idClaims())
+
+	tokens := map[string]string{
+		"malformed":     "not.a-token",
+		"other key":     sign(t, newKey(t), "key-1", idClaims()),
+		"unknown key":   sign(t, key, "key-2", idClaims()),
+		"expired":       sign(t, key, "key-1", with("exp", time.Now().Add(-time.Minute).Unix())),
+		"other issuer":  sign(t, key, "key-1", with("iss", "https://example.com")),
+		"other client":  sign(t, key, "key-1", with("aud", "client-2")),
+		"refresh token": sign(t, key, "key-1", with("token_use", "refresh")),
+		"tampered":      valid[:len(valid)-4] + "AAAA",
+		"unsigned":      valid[:strings.LastIndex(valid, ".")+1],
+		"empty":         "",
+	}
+
+	v := auth.NewVerifierWithKeys(testIssuer, testClientID, keys.URL, keys.Client())
+
+	for name, token := range tokens {
+		if _, err := v.Verify(token); err != auth.ErrInvalidToken {
+			t.Fatalf("Expected %v for %s token, got %v", auth.ErrInvalidToken, name, err)
+		}
+	}
+}
+
+func testKeysCached(t *testing.T) {
+
+	var fetches int32
+
+	key := newKey(t)
+	keys := keyServer(t, key, &fetches)
+	defer keys.Close()
+
+	v := auth.NewVerifierWithKeys(testIssuer, testClientID, keys.URL, keys.Client())
+
+	for i := 0; i < 3; i++ {
+		if _, err := v.Verify(sign(t, key, "key-1", idClaims())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Tokens signed with unknown keys do not fetch the keys again until they are refreshed
+	for i := 0; i < 3; i++ {
+		if _, err := v.Verify(sign(t, key, "key-2", idClaims())); err != auth.ErrInvalidToken {
+			t.Fatalf("Expected %v, got %v", auth.ErrInvalidToken, err)
+		}
+	}
+
+	if fetches != 1 {
+		t.Fatalf("Expected keys to be fetched once, got %d", fetches)
+	}
+}
@@ -0,0 +1,70 @@
+// Package blob defines the object stores that hold the content of attachments. Clients upload and download
+// objects with presigned URLs, so content never passes through the API.
+package blob
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+// Store is an interface for object stores that can presign uploads and downloads
+type Store interface {
+	// PresignPut returns a URL that the content described by upload can be PUT to until it expires. The
+	// request must send the Content-Length, Content-Type and Content-MD5 headers of upload.
+	PresignPut(key string, upload Upload, expires time.Duration) (string, error)
+	// PresignGet returns a URL that downloads an object as a file with the given name until it expires
+	PresignGet(key, filename string, expires time.Duration) (string, error)
+	// Delete removes an object. Removing an object that does not exist is not an error.
+	Delete(key string) error
+	// DeletePrefix removes every object whose key starts with prefix
+	DeletePrefix(prefix string) error
+}
+
+// Upload describes the content that a presigned upload accepts
+type Upload struct {
+	Size        int64
+	ContentType string
+	// Checksum is the base64 encoded MD5 digest of the content
+	Checksum string
+}
+
+// Prefix returns the prefix of the keys of the objects attached to a ToDo. The IDs are escaped, so a
+// prefix never matches the objects of another ToDo.
+func Prefix(listID, todoID string) string {
+	if listID == "" {
+		listID = internal.DefaultListID
+	}
+	return "todos/" + url.PathEscape(listID) + "/" + url.PathEscape(todoID) + "/"
+}
+
+// Key returns the key of the object holding the content of an attachment
+func Key(listID, todoID, attachmentID string) string {
+	return Prefix(listID, todoID) + attachmentID
+}
+
+// ContentDisposition returns the Content-Disposition of a download of a file with the given name. Quotes,
+// backslashes and control characters are dropped from the plain filename parameter, and the name is also
+// given percent encoded for clients that support RFC 6266.
+func ContentDisposition(filename string) string {
+	plain := strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' || r == '\\' || r > 0x7e {
+			return '_'
+		}
+		return r
+	}, filename)
+
+	var encoded strings.Builder
+	for _, b := range []byte(filename) {
+		if b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || strings.IndexByte("!#$&+-.^_`|~", b) >= 0 {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, plain, encoded.String())
+}
@@ -0,0 +1,42 @@
+package blob_test
+
+import (
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal/blob"
+)
+
+func TestBlob(t *testing.T) {
+	t.Run("Prefix", testPrefix)
+	t.Run("ContentDisposition", testContentDisposition)
+}
+
+func testPrefix(t *testing.T) {
+
+	if p := blob.Prefix("", "1"); p != "todos/default/1/" {
+		t.Fatalf("Expected the default list, got %s", p)
+	}
+
+	if k := blob.Key("work", "a/b", "c"); k != "todos/work/a%2Fb/c" {
+		t.Fatalf("Expected IDs to be escaped, got %s", k)
+	}
+}
+
+func testContentDisposition(t *testing.T) {
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"notes.txt", `attachment; filename="notes.txt"; filename*=UTF-8''notes.txt`},
+		{`a "b".txt`, `attachment; filename="a _b_.txt"; filename*=UTF-8''a%20%22b%22.txt`},
+		{"résumé.pdf", `attachment; filename="r_sum_.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`},
+		{"a\r\nb", `attachment; filename="a__b"; filename*=UTF-8''a%0D%0Ab`},
+	}
+
+	for _, test := range tests {
+		if got := blob.ContentDisposition(test.name); got != test.want {
+			t.Errorf("ContentDisposition(%q) = %s, expected %s", test.name, got, test.want)
+		}
+	}
+}
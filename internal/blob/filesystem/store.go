@@ -0,0 +1,249 @@
+// Package filesystem provides a blob.Store that keeps objects in a directory, for self-hosted deployments
+// and local testing. It serves its own presigned URLs, which carry an HMAC signature of the request they
+// allow.
+package filesystem
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal/blob"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidKey is returned when a key can not be used as a path in the directory
+var ErrInvalidKey = errors.New("invalid key")
+
+// Store is a blob.Store that keeps objects in a directory. It is also the http.Handler that serves its
+// presigned URLs, and must be mounted at the path of its base URL.
+type Store struct {
+	dir    string
+	base   *url.URL
+	secret []byte
+	now    func() time.Time
+}
+
+// NewStore returns a Store for the objects in dir, creating it if needed. Presigned URLs start with
+// baseURL and are signed with secret.
+func NewStore(dir, baseURL string, secret []byte) (*Store, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not parse base URL %s", baseURL)
+	}
+
+	if len(secret) == 0 {
+		return nil, errors.New("Secret is required")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "Could not create directory %s", dir)
+	}
+
+	return &Store{
+		dir:    dir,
+		base:   base,
+		secret: secret,
+		now:    time.Now,
+	}, nil
+}
+
+// PresignPut returns a URL that the content described by upload can be PUT to until it expires
+func (s *Store) PresignPut(key string, upload blob.Upload, expires time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(s.now().Add(expires).Unix(), 10))
+	q.Set("signature", s.sign(http.MethodPut, key, q.Get("expires"),
+		strconv.FormatInt(upload.Size, 10), upload.ContentType, upload.Checksum))
+
+	return s.url(key, q), nil
+}
+
+// PresignGet returns a URL that downloads an object as a file with the given name until it expires
+func (s *Store) PresignGet(key, filename string, expires time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("filename", filename)
+	q.Set("expires", strconv.FormatInt(s.now().Add(expires).Unix(), 10))
+	q.Set("signature", s.sign(http.MethodGet, key, q.Get("expires"), filename))
+
+	return s.url(key, q), nil
+}
+
+// Delete removes an object
+func (s *Store) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "Could not delete %s", key)
+	}
+
+	return nil
+}
+
+// DeletePrefix removes every object whose key starts with prefix. The prefix must end with a slash, as
+// objects are grouped in directories.
+func (s *Store) DeletePrefix(prefix string) error {
+	if !strings.HasSuffix(prefix, "/") {
+		return errors.Wrapf(ErrInvalidKey, "Prefix %s must end with a slash", prefix)
+	}
+
+	p, err := s.path(strings.TrimSuffix(prefix, "/"))
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(p); err != nil {
+		return errors.Wrapf(err, "Could not delete objects in %s", prefix)
+	}
+
+	return nil
+}
+
+// ServeHTTP handles the requests made to presigned URLs
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.EscapedPath(), s.base.EscapedPath()+"/")
+
+	p, err := s.path(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	q := r.URL.Query()
+
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil || s.now().Unix() > expires {
+		http.Error(w, "URL has expired", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		sig := s.sign(r.Method, key, q.Get("expires"),
+			strconv.FormatInt(r.ContentLength, 10), r.Header.Get("Content-Type"), r.Header.Get("Content-MD5"))
+		if !hmac.Equal([]byte(sig), []byte(q.Get("signature"))) {
+			http.Error(w, "Signature does not match", http.StatusForbidden)
+			return
+		}
+		s.put(w, r, p)
+	case http.MethodGet, http.MethodHead:
+		filename := q.Get("filename")
+		if !hmac.Equal([]byte(s.sign(http.MethodGet, key, q.Get("expires"), filename)), []byte(q.Get("signature"))) {
+			http.Error(w, "Signature does not match", http.StatusForbidden)
+			return
+		}
+		s.get(w, r, p, filename)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// put writes the request body to the file at p if it matches its Content-MD5
+func (s *Store) put(w http.ResponseWriter, r *http.Request, p string) {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(p), "."+filepath.Base(p)+".tmp")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	defer os.Remove(tmp.Name())
+
+	h := md5.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r.Body, r.ContentLength))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil || n != r.ContentLength {
+		http.Error(w, "Could not read content", http.StatusBadRequest)
+		return
+	}
+
+	if base64.StdEncoding.EncodeToString(h.Sum(nil)) != r.Header.Get("Content-MD5") {
+		http.Error(w, "Content does not match Content-MD5", http.StatusBadRequest)
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// get serves the file at p as a download, so browsers never render it
+func (s *Store) get(w http.ResponseWriter, r *http.Request, p, filename string) {
+	f, err := os.Open(p)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", blob.ContentDisposition(filename))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+
+	http.ServeContent(w, r, "", fi.ModTime(), f)
+}
+
+// url returns the URL of key with the query q
+func (s *Store) url(key string, q url.Values) string {
+	return s.base.String() + "/" + key + "?" + q.Encode()
+}
+
+// sign returns the signature of a request for key with the given method and values
+func (s *Store) sign(method, key string, values ...string) string {
+	m := hmac.New(sha256.New, s.secret)
+	io.WriteString(m, method+"\n"+key)
+	for _, v := range values {
+		io.WriteString(m, "\n"+v)
+	}
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// path returns the path of the file holding the object with key. Keys are made of slash separated
+// segments that may not be empty or start with a dot, so every path is inside the directory.
+func (s *Store) path(key string) (string, error) {
+	segments := strings.Split(key, "/")
+	for _, seg := range segments {
+		if seg == "" || strings.HasPrefix(seg, ".") || strings.ContainsAny(seg, `\`) {
+			return "", errors.Wrapf(ErrInvalidKey, "Invalid key %s", key)
+		}
+	}
+	return filepath.Join(append([]string{s.dir}, segments...)...), nil
+}
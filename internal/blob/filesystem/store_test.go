@@ -0,0 +1,281 @@
+package filesystem_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal/blob"
+	"github.com/benjaminbartels/todo/internal/blob/filesystem"
+	"github.com/pkg/errors"
+)
+
+var content = []byte("Hello, World!")
+
+func TestStore(t *testing.T) {
+	t.Run("PutAndGet", testPutAndGet)
+	t.Run("PutChecksumMismatch", testPutChecksumMismatch)
+	t.Run("PutHeaderMismatch", testPutHeaderMismatch)
+	t.Run("Expired", testExpired)
+	t.Run("TamperedURL", testTamperedURL)
+	t.Run("InvalidKey", testInvalidKey)
+	t.Run("Delete", testDelete)
+	t.Run("DeletePrefix", testDeletePrefix)
+}
+
+// setup returns a Store in a temporary directory that is served by a test server
+func setup(t *testing.T) (*filesystem.Store, string, func()) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+
+	store, err := filesystem.NewStore(dir, srv.URL+"/blobs/", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux.Handle("/blobs/", store)
+
+	return store, dir, func() {
+		srv.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func upload(b []byte) blob.Upload {
+	sum := md5.Sum(b)
+	return blob.Upload{
+		Size:        int64(len(b)),
+		ContentType: "text/plain",
+		Checksum:    base64.StdEncoding.EncodeToString(sum[:]),
+	}
+}
+
+func put(t *testing.T, u string, up blob.Upload, body []byte) *http.Response {
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", up.ContentType)
+	req.Header.Set("Content-MD5", up.Checksum)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	return resp
+}
+
+func testPutAndGet(t *testing.T) {
+
+	store, _, teardown := setup(t)
+	defer teardown()
+
+	key := blob.Key("", "1", "a")
+
+	u, err := store.PresignPut(key, upload(content), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := put(t, u, upload(content), content); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected upload to succeed, got %s", resp.Status)
+	}
+
+	u, err = store.PresignGet(key, "hello.txt", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(b, content) {
+		t.Fatalf("Expected content to be downloaded, got %s %q", resp.Status, b)
+	}
+
+	if d := resp.Header.Get("Content-Disposition"); d != blob.ContentDisposition("hello.txt") {
+		t.Fatalf("Unexpected Content-Disposition %s", d)
+	}
+
+	if resp.Header.Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatal("Expected content sniffing to be disabled")
+	}
+}
+
+func testPutChecksumMismatch(t *testing.T) {
+
+	store, dir, teardown := setup(t)
+	defer teardown()
+
+	key := blob.Key("", "1", "a")
+
+	u, err := store.PresignPut(key, upload(content), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := []byte("Hello, Mars!!")
+
+	if resp := put(t, u, upload(content), other); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d, got %s", http.StatusBadRequest, resp.Status)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "todos", "default", "1", "a")); !os.IsNotExist(err) {
+		t.Fatal("Expected no object to be written")
+	}
+}
+
+func testPutHeaderMismatch(t *testing.T) {
+
+	store, _, teardown := setup(t)
+	defer teardown()
+
+	u, err := store.PresignPut(blob.Key("", "1", "a"), upload(content), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	longer := append(content, '!')
+
+	if resp := put(t, u, upload(longer), longer); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected %d for a different upload, got %s", http.StatusForbidden, resp.Status)
+	}
+}
+
+func testExpired(t *testing.T) {
+
+	store, _, teardown := setup(t)
+	defer teardown()
+
+	u, err := store.PresignGet(blob.Key("", "1", "a"), "hello.txt", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected %d, got %s", http.StatusForbidden, resp.Status)
+	}
+}
+
+func testTamperedURL(t *testing.T) {
+
+	store, _, teardown := setup(t)
+	defer teardown()
+
+	u, err := store.PresignGet(blob.Key("", "1", "a"), "hello.txt", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tampered := range []string{
+		strings.Replace(u, "/1/a", "/2/a", 1),
+		strings.Replace(u, "hello.txt", "hello.html", 1),
+	} {
+		resp, err := http.Get(tampered)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("Expected %d for %s, got %s", http.StatusForbidden, tampered, resp.Status)
+		}
+	}
+}
+
+func testInvalidKey(t *testing.T) {
+
+	store, _, teardown := setup(t)
+	defer teardown()
+
+	for _, key := range []string{"", "todos/../secret", "todos//a", "todos/.hidden", `todos\a`} {
+		if _, err := store.PresignGet(key, "a", time.Minute); errors.Cause(err) != filesystem.ErrInvalidKey {
+			t.Fatalf("Expected %v for %q, got %v", filesystem.ErrInvalidKey, key, err)
+		}
+	}
+}
+
+func testDelete(t *testing.T) {
+
+	store, dir, teardown := setup(t)
+	defer teardown()
+
+	p := filepath.Join(dir, "todos", "default", "1", "a")
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(p, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	key := blob.Key("", "1", "a")
+
+	if err := store.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Fatal("Expected object to be deleted")
+	}
+
+	if err := store.Delete(key); err != nil {
+		t.Fatalf("Expected deleting a missing object to succeed, got %v", err)
+	}
+}
+
+func testDeletePrefix(t *testing.T) {
+
+	store, dir, teardown := setup(t)
+	defer teardown()
+
+	for _, id := range []string{"1", "10"} {
+		p := filepath.Join(dir, "todos", "default", id, "a")
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.DeletePrefix(blob.Prefix("", "1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "todos", "default", "1")); !os.IsNotExist(err) {
+		t.Fatal("Expected objects to be deleted")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "todos", "default", "10", "a")); err != nil {
+		t.Fatalf("Expected the objects of other ToDos to remain, got %v", err)
+	}
+}
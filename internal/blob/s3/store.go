@@ -0,0 +1,123 @@
+// Package s3 provides a blob.Store backed by Amazon S3 or a compatible object store, such as MinIO for
+// local testing.
+package s3
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/benjaminbartels/todo/internal/blob"
+	"github.com/pkg/errors"
+)
+
+// deleteBatchSize is the most objects DeleteObjects removes in one request
+const deleteBatchSize = 1000
+
+// Store is a blob.Store that keeps objects in an S3 bucket
+type Store struct {
+	s3     s3iface.S3API
+	bucket string
+}
+
+// NewStore returns a new Store for the objects in bucket
+func NewStore(api s3iface.S3API, bucket string) *Store {
+	return &Store{
+		s3:     api,
+		bucket: bucket,
+	}
+}
+
+// PresignPut returns a URL that the content described by upload can be PUT to until it expires. S3
+// rejects uploads whose Content-Length, Content-Type or Content-MD5 differ from upload, or whose content
+// does not match the Content-MD5.
+func (s *Store) PresignPut(key string, upload blob.Upload, expires time.Duration) (string, error) {
+	req, _ := s.s3.PutObjectRequest(&awss3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		ContentLength: aws.Int64(upload.Size),
+		ContentType:   aws.String(upload.ContentType),
+		ContentMD5:    aws.String(upload.Checksum),
+	})
+
+	u, err := req.Presign(expires)
+	if err != nil {
+		return "", errors.Wrapf(err, "Could not presign upload of %s", key)
+	}
+
+	return u, nil
+}
+
+// PresignGet returns a URL that downloads an object as a file with the given name until it expires
+func (s *Store) PresignGet(key, filename string, expires time.Duration) (string, error) {
+	req, _ := s.s3.GetObjectRequest(&awss3.GetObjectInput{
+		Bucket:                     aws.String(s.bucket),
+		Key:                        aws.String(key),
+		ResponseContentDisposition: aws.String(blob.ContentDisposition(filename)),
+	})
+
+	u, err := req.Presign(expires)
+	if err != nil {
+		return "", errors.Wrapf(err, "Could not presign download of %s", key)
+	}
+
+	return u, nil
+}
+
+// Delete removes an object
+func (s *Store) Delete(key string) error {
+	_, err := s.s3.DeleteObject(&awss3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not delete %s", key)
+	}
+
+	return nil
+}
+
+// DeletePrefix removes every object whose key starts with prefix
+func (s *Store) DeletePrefix(prefix string) error {
+	input := &awss3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}
+
+	objects := []*awss3.ObjectIdentifier{}
+
+	err := s.s3.ListObjectsV2Pages(input, func(page *awss3.ListObjectsV2Output, last bool) bool {
+		for _, o := range page.Contents {
+			objects = append(objects, &awss3.ObjectIdentifier{Key: o.Key})
+		}
+		return true
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not list objects in %s", prefix)
+	}
+
+	for len(objects) > 0 {
+		n := len(objects)
+		if n > deleteBatchSize {
+			n = deleteBatchSize
+		}
+
+		result, err := s.s3.DeleteObjects(&awss3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &awss3.Delete{Objects: objects[:n], Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return errors.Wrapf(err, "Could not delete objects in %s", prefix)
+		}
+
+		if len(result.Errors) > 0 {
+			e := result.Errors[0]
+			return errors.Errorf("Could not delete %s: %s", aws.StringValue(e.Key), aws.StringValue(e.Message))
+		}
+
+		objects = objects[n:]
+	}
+
+	return nil
+}
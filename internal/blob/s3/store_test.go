@@ -0,0 +1,208 @@
+package s3_test
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/benjaminbartels/todo/internal/blob"
+	"github.com/benjaminbartels/todo/internal/blob/s3"
+	"github.com/pkg/errors"
+)
+
+// ClientMock is used to mock a client that makes calls to S3API
+type ClientMock struct {
+	s3iface.S3API
+	DeleteObjectFn         func(*awss3.DeleteObjectInput) (*awss3.DeleteObjectOutput, error)
+	ListObjectsV2PagesFn   func(*awss3.ListObjectsV2Input, func(*awss3.ListObjectsV2Output, bool) bool) error
+	DeleteObjectsFn        func(*awss3.DeleteObjectsInput) (*awss3.DeleteObjectsOutput, error)
+	DeleteObjectInvoked    bool
+	DeleteObjectsInvoked   bool
+	ListObjectsV2PagesCall int
+}
+
+// DeleteObject removes an object
+func (m *ClientMock) DeleteObject(input *awss3.DeleteObjectInput) (*awss3.DeleteObjectOutput, error) {
+	m.DeleteObjectInvoked = true
+	return m.DeleteObjectFn(input)
+}
+
+// ListObjectsV2Pages calls fn with each page of the objects in a bucket
+func (m *ClientMock) ListObjectsV2Pages(input *awss3.ListObjectsV2Input,
+	fn func(*awss3.ListObjectsV2Output, bool) bool) error {
+	m.ListObjectsV2PagesCall++
+	return m.ListObjectsV2PagesFn(input, fn)
+}
+
+// DeleteObjects removes a batch of objects
+func (m *ClientMock) DeleteObjects(input *awss3.DeleteObjectsInput) (*awss3.DeleteObjectsOutput, error) {
+	m.DeleteObjectsInvoked = true
+	return m.DeleteObjectsFn(input)
+}
+
+func TestStore(t *testing.T) {
+	t.Run("PresignPut", testPresignPut)
+	t.Run("PresignGet", testPresignGet)
+	t.Run("Delete", testDelete)
+	t.Run("DeletePrefix", testDeletePrefix)
+	t.Run("DeletePrefixError", testDeletePrefixError)
+}
+
+// client returns an S3 client for a local S3-compatible store. Presigning does not contact it.
+func client(t *testing.T) *awss3.S3 {
+	s, err := session.NewSession(aws.NewConfig().
+		WithRegion("us-west-2").
+		WithEndpoint("http://localhost:9000").
+		WithS3ForcePathStyle(true).
+		WithCredentials(credentials.NewStaticCredentials("key", "secret", "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return awss3.New(s)
+}
+
+func testPresignPut(t *testing.T) {
+
+	store := s3.NewStore(client(t), "attachments")
+
+	upload := blob.Upload{Size: 5, ContentType: "text/plain", Checksum: "XUFAKrxLKna5cZ2REBfFkg=="}
+
+	raw, err := store.PresignPut("todos/default/1/a", upload, 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Host != "localhost:9000" || u.Path != "/attachments/todos/default/1/a" {
+		t.Fatalf("Unexpected URL %s", raw)
+	}
+
+	q := u.Query()
+	if q.Get("X-Amz-Expires") != "900" || q.Get("X-Amz-Signature") == "" {
+		t.Fatalf("Expected a signature valid for 15 minutes, got %s", raw)
+	}
+
+	signed := q.Get("X-Amz-SignedHeaders")
+	for _, h := range []string{"content-length", "content-md5", "content-type"} {
+		if !strings.Contains(signed, h) {
+			t.Fatalf("Expected %s to be signed, got %s", h, signed)
+		}
+	}
+}
+
+func testPresignGet(t *testing.T) {
+
+	store := s3.NewStore(client(t), "attachments")
+
+	raw, err := store.PresignGet("todos/default/1/a", "notes.txt", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := u.Query().Get("response-content-disposition"); d != blob.ContentDisposition("notes.txt") {
+		t.Fatalf("Unexpected Content-Disposition %s", d)
+	}
+}
+
+func testDelete(t *testing.T) {
+
+	m := &ClientMock{
+		DeleteObjectFn: func(input *awss3.DeleteObjectInput) (*awss3.DeleteObjectOutput, error) {
+			if aws.StringValue(input.Bucket) != "attachments" || aws.StringValue(input.Key) != "k" {
+				return nil, fmt.Errorf("unexpected input %v", input)
+			}
+			return &awss3.DeleteObjectOutput{}, nil
+		},
+	}
+
+	if err := s3.NewStore(m, "attachments").Delete("k"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.DeleteObjectInvoked {
+		t.Fatal("Expected DeleteObject to be invoked")
+	}
+}
+
+func testDeletePrefix(t *testing.T) {
+
+	deleted := []string{}
+	batches := 0
+
+	m := &ClientMock{
+		ListObjectsV2PagesFn: func(input *awss3.ListObjectsV2Input, fn func(*awss3.ListObjectsV2Output, bool) bool) error {
+			if aws.StringValue(input.Prefix) != "todos/default/1/" {
+				return fmt.Errorf("unexpected prefix %s", aws.StringValue(input.Prefix))
+			}
+
+			// Two pages that add up to more than one batch
+			for page := 0; page < 2; page++ {
+				out := &awss3.ListObjectsV2Output{}
+				for i := 0; i < 600; i++ {
+					key := fmt.Sprintf("todos/default/1/%d-%d", page, i)
+					out.Contents = append(out.Contents, &awss3.Object{Key: aws.String(key)})
+				}
+				if !fn(out, page == 1) {
+					break
+				}
+			}
+			return nil
+		},
+		DeleteObjectsFn: func(input *awss3.DeleteObjectsInput) (*awss3.DeleteObjectsOutput, error) {
+			batches++
+			for _, o := range input.Delete.Objects {
+				deleted = append(deleted, aws.StringValue(o.Key))
+			}
+			return &awss3.DeleteObjectsOutput{}, nil
+		},
+	}
+
+	if err := s3.NewStore(m, "attachments").DeletePrefix(blob.Prefix("", "1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deleted) != 1200 || batches != 2 {
+		t.Fatalf("Expected 1200 objects deleted in 2 batches, got %d in %d", len(deleted), batches)
+	}
+}
+
+func testDeletePrefixError(t *testing.T) {
+
+	m := &ClientMock{
+		ListObjectsV2PagesFn: func(input *awss3.ListObjectsV2Input, fn func(*awss3.ListObjectsV2Output, bool) bool) error {
+			fn(&awss3.ListObjectsV2Output{Contents: []*awss3.Object{{Key: aws.String("k")}}}, true)
+			return nil
+		},
+		DeleteObjectsFn: func(input *awss3.DeleteObjectsInput) (*awss3.DeleteObjectsOutput, error) {
+			return &awss3.DeleteObjectsOutput{Errors: []*awss3.Error{{Key: aws.String("k"), Message: aws.String("Access Denied")}}}, nil
+		},
+	}
+
+	if err := s3.NewStore(m, "attachments").DeletePrefix("todos/"); err == nil {
+		t.Fatal("Expected error for objects that could not be deleted")
+	}
+
+	m.ListObjectsV2PagesFn = func(input *awss3.ListObjectsV2Input, fn func(*awss3.ListObjectsV2Output, bool) bool) error {
+		return errors.New("list failed")
+	}
+
+	if err := s3.NewStore(m, "attachments").DeletePrefix("todos/"); err == nil {
+		t.Fatal("Expected error when objects cannot be listed")
+	}
+}
@@ -0,0 +1,198 @@
+// Package caldav encodes and decodes the WebDAV and CalDAV (RFC 4918, RFC 4791, RFC 6578) XML bodies used
+// to sync ToDos with task clients.
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+const (
+	// NamespaceDAV is the WebDAV XML namespace
+	NamespaceDAV = "DAV:"
+	// NamespaceCalDAV is the CalDAV XML namespace
+	NamespaceCalDAV = "urn:ietf:params:xml:ns:caldav"
+	// NamespaceCalendarServer is the namespace of the getctag extension supported by most clients
+	NamespaceCalendarServer = "http://calendarserver.org/ns/"
+	// ContentType is the MIME type of multistatus responses
+	ContentType = "application/xml; charset=utf-8"
+)
+
+// prefixes are the namespace prefixes used when writing XML
+var prefixes = map[string]string{
+	NamespaceDAV:            "d",
+	NamespaceCalDAV:         "c",
+	NamespaceCalendarServer: "cs",
+}
+
+// Well known property and report names
+var (
+	ResourceType                  = xml.Name{Space: NamespaceDAV, Local: "resourcetype"}
+	DisplayName                   = xml.Name{Space: NamespaceDAV, Local: "displayname"}
+	GetETag                       = xml.Name{Space: NamespaceDAV, Local: "getetag"}
+	GetContentType                = xml.Name{Space: NamespaceDAV, Local: "getcontenttype"}
+	CurrentUserPrincipal          = xml.Name{Space: NamespaceDAV, Local: "current-user-principal"}
+	PrincipalURL                  = xml.Name{Space: NamespaceDAV, Local: "principal-URL"}
+	SupportedReportSet            = xml.Name{Space: NamespaceDAV, Local: "supported-report-set"}
+	SyncToken                     = xml.Name{Space: NamespaceDAV, Local: "sync-token"}
+	CalendarHomeSet               = xml.Name{Space: NamespaceCalDAV, Local: "calendar-home-set"}
+	CalendarData                  = xml.Name{Space: NamespaceCalDAV, Local: "calendar-data"}
+	SupportedCalendarComponentSet = xml.Name{Space: NamespaceCalDAV, Local: "supported-calendar-component-set"}
+	GetCTag                       = xml.Name{Space: NamespaceCalendarServer, Local: "getctag"}
+
+	CalendarQuery    = xml.Name{Space: NamespaceCalDAV, Local: "calendar-query"}
+	CalendarMultiget = xml.Name{Space: NamespaceCalDAV, Local: "calendar-multiget"}
+	SyncCollection   = xml.Name{Space: NamespaceDAV, Local: "sync-collection"}
+)
+
+// Property is a WebDAV property. Value is the XML content of the property element.
+type Property struct {
+	Name  xml.Name
+	Value string
+}
+
+// Text returns a property whose value is the escaped text s
+func Text(name xml.Name, s string) Property {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return Property{Name: name, Value: b.String()}
+}
+
+// Href returns a property whose value is an href element
+func Href(name xml.Name, href string) Property {
+	return Property{Name: name, Value: Text(xml.Name{Space: NamespaceDAV, Local: "href"}, href).element()}
+}
+
+// Elements returns a property whose value is a list of empty elements, such as a resourcetype
+func Elements(name xml.Name, children ...xml.Name) Property {
+	var b bytes.Buffer
+	for _, c := range children {
+		b.WriteString(Property{Name: c}.element())
+	}
+	return Property{Name: name, Value: b.String()}
+}
+
+// Raw returns a property whose value is the XML fragment value
+func Raw(name xml.Name, value string) Property {
+	return Property{Name: name, Value: value}
+}
+
+// element returns the property as an XML element
+func (p Property) element() string {
+	open, close := tag(p.Name)
+	if p.Value == "" {
+		return open[:len(open)-1] + "/>"
+	}
+	return open + p.Value + close
+}
+
+// Response is a response element of a multistatus. Responses with a Status, such as members removed
+// since the last sync, have no properties.
+type Response struct {
+	Href     string
+	Status   int
+	Found    []Property
+	NotFound []xml.Name
+}
+
+// Multistatus is the body of a 207 Multi-Status response
+type Multistatus struct {
+	Responses []Response
+	SyncToken string
+}
+
+// Marshal returns the XML encoding of the multistatus
+func (m *Multistatus) Marshal() []byte {
+	var b bytes.Buffer
+
+	writeRoot(&b, "multistatus")
+
+	for _, r := range m.Responses {
+		b.WriteString("<d:response>")
+		b.WriteString(Text(xml.Name{Space: NamespaceDAV, Local: "href"}, r.Href).element())
+
+		if r.Status != 0 {
+			writeStatus(&b, r.Status)
+		}
+
+		if len(r.Found) > 0 {
+			b.WriteString("<d:propstat><d:prop>")
+			for _, p := range r.Found {
+				b.WriteString(p.element())
+			}
+			b.WriteString("</d:prop>")
+			writeStatus(&b, http.StatusOK)
+			b.WriteString("</d:propstat>")
+		}
+
+		if len(r.NotFound) > 0 {
+			b.WriteString("<d:propstat><d:prop>")
+			for _, n := range r.NotFound {
+				b.WriteString(Property{Name: n}.element())
+			}
+			b.WriteString("</d:prop>")
+			writeStatus(&b, http.StatusNotFound)
+			b.WriteString("</d:propstat>")
+		}
+
+		b.WriteString("</d:response>")
+	}
+
+	if m.SyncToken != "" {
+		b.WriteString(Text(SyncToken, m.SyncToken).element())
+	}
+
+	b.WriteString("</d:multistatus>")
+
+	return b.Bytes()
+}
+
+// Error returns the body of an error response for a failed precondition, such as DAV:valid-sync-token
+func Error(precondition xml.Name) []byte {
+	var b bytes.Buffer
+
+	writeRoot(&b, "error")
+	b.WriteString(Property{Name: precondition}.element())
+	b.WriteString("</d:error>")
+
+	return b.Bytes()
+}
+
+// writeRoot writes the XML declaration and the opening tag of a DAV: root element, declaring every
+// known namespace
+func writeRoot(b *bytes.Buffer, local string) {
+	b.WriteString(xml.Header)
+	fmt.Fprintf(b, "<d:%s", local)
+
+	spaces := make([]string, 0, len(prefixes))
+	for ns := range prefixes {
+		spaces = append(spaces, ns)
+	}
+	sort.Strings(spaces)
+
+	for _, ns := range spaces {
+		fmt.Fprintf(b, ` xmlns:%s="%s"`, prefixes[ns], ns)
+	}
+
+	b.WriteString(">")
+}
+
+func writeStatus(b *bytes.Buffer, code int) {
+	fmt.Fprintf(b, "<d:status>HTTP/1.1 %d %s</d:status>", code, http.StatusText(code))
+}
+
+// tag returns the opening and closing tags of an element. Names in namespaces without a known prefix
+// declare the namespace as the default.
+func tag(n xml.Name) (string, string) {
+	if prefix, ok := prefixes[n.Space]; ok {
+		return fmt.Sprintf("<%s:%s>", prefix, n.Local), fmt.Sprintf("</%s:%s>", prefix, n.Local)
+	}
+
+	var ns bytes.Buffer
+	xml.EscapeText(&ns, []byte(n.Space))
+
+	return fmt.Sprintf(`<%s xmlns="%s">`, n.Local, ns.String()), fmt.Sprintf("</%s>", n.Local)
+}
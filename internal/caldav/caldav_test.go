@@ -0,0 +1,90 @@
+package caldav_test
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal/caldav"
+)
+
+func TestMultistatus(t *testing.T) {
+	t.Run("Marshal", testMarshal)
+	t.Run("WellFormed", testWellFormed)
+	t.Run("Error", testError)
+}
+
+func testMarshal(t *testing.T) {
+
+	ms := &caldav.Multistatus{
+		Responses: []caldav.Response{
+			{
+				Href: "/caldav/default/",
+				Found: []caldav.Property{
+					caldav.Elements(caldav.ResourceType,
+						xml.Name{Space: caldav.NamespaceDAV, Local: "collection"},
+						xml.Name{Space: caldav.NamespaceCalDAV, Local: "calendar"}),
+					caldav.Text(caldav.DisplayName, "R&D <infra>"),
+				},
+				NotFound: []xml.Name{{Space: "http://apple.com/ns/ical/", Local: "calendar-color"}},
+			},
+			{Href: "/caldav/default/gone.ics", Status: http.StatusNotFound},
+		},
+		SyncToken: "urn:x-todo:sync:1",
+	}
+
+	expected := xml.Header +
+		`<d:multistatus xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/" xmlns:c="urn:ietf:params:xml:ns:caldav">` +
+		`<d:response><d:href>/caldav/default/</d:href>` +
+		`<d:propstat><d:prop><d:resourcetype><d:collection/><c:calendar/></d:resourcetype>` +
+		`<d:displayname>R&amp;D &lt;infra&gt;</d:displayname></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>` +
+		`<d:propstat><d:prop><calendar-color xmlns="http://apple.com/ns/ical/"/></d:prop>` +
+		`<d:status>HTTP/1.1 404 Not Found</d:status></d:propstat></d:response>` +
+		`<d:response><d:href>/caldav/default/gone.ics</d:href><d:status>HTTP/1.1 404 Not Found</d:status></d:response>` +
+		`<d:sync-token>urn:x-todo:sync:1</d:sync-token></d:multistatus>`
+
+	if got := string(ms.Marshal()); got != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, got)
+	}
+}
+
+func testWellFormed(t *testing.T) {
+
+	ms := &caldav.Multistatus{
+		Responses: []caldav.Response{
+			{
+				Href:  "/caldav/default/1.ics",
+				Found: []caldav.Property{caldav.Text(caldav.CalendarData, "BEGIN:VCALENDAR\r\nSUMMARY:a < b\r\n")},
+			},
+		},
+	}
+
+	var v struct {
+		Responses []struct {
+			Href     string `xml:"DAV: href"`
+			PropStat struct {
+				Prop struct {
+					Data string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+				} `xml:"DAV: prop"`
+			} `xml:"DAV: propstat"`
+		} `xml:"DAV: response"`
+	}
+
+	if err := xml.Unmarshal(ms.Marshal(), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(v.Responses) != 1 || v.Responses[0].PropStat.Prop.Data != "BEGIN:VCALENDAR\r\nSUMMARY:a < b\r\n" {
+		t.Fatalf("Expected calendar data to round trip, got %+v", v)
+	}
+}
+
+func testError(t *testing.T) {
+
+	b := string(caldav.Error(xml.Name{Space: caldav.NamespaceDAV, Local: "valid-sync-token"}))
+
+	if !strings.Contains(b, "<d:error") || !strings.Contains(b, "<d:valid-sync-token/></d:error>") {
+		t.Fatalf("Unexpected error body %s", b)
+	}
+}
@@ -0,0 +1,225 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+// PropFind is the body of a PROPFIND request. A request without a body asks for all properties.
+type PropFind struct {
+	AllProp bool
+	Props   []xml.Name
+}
+
+// Report is the body of a REPORT request
+type Report struct {
+	// Type is the root element, one of CalendarQuery, CalendarMultiget or SyncCollection
+	Type    xml.Name
+	AllProp bool
+	Props   []xml.Name
+	// Filter is the filter of a calendar-query
+	Filter *CompFilter
+	// Hrefs are the resources requested by a calendar-multiget
+	Hrefs []string
+	// SyncToken is the token sent with a sync-collection, which is empty for the initial sync
+	SyncToken string
+}
+
+// CompFilter is a calendar-query comp-filter
+type CompFilter struct {
+	Name         string       `xml:"name,attr"`
+	IsNotDefined *struct{}    `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	CompFilters  []CompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	PropFilters  []PropFilter `xml:"urn:ietf:params:xml:ns:caldav prop-filter"`
+}
+
+// PropFilter is a calendar-query prop-filter
+type PropFilter struct {
+	Name         string     `xml:"name,attr"`
+	IsNotDefined *struct{}  `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	TextMatch    *TextMatch `xml:"urn:ietf:params:xml:ns:caldav text-match"`
+}
+
+// TextMatch is a calendar-query text-match, compared case insensitively
+type TextMatch struct {
+	Value           string `xml:",chardata"`
+	NegateCondition string `xml:"negate-condition,attr"`
+}
+
+// names collects the names of the child elements of a prop element
+type names []xml.Name
+
+func (n *names) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			*n = append(*n, t.Name)
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// body holds the elements of PROPFIND and REPORT bodies
+type body struct {
+	AllProp   *struct{} `xml:"DAV: allprop"`
+	Prop      names     `xml:"DAV: prop"`
+	Filter    *filter   `xml:"urn:ietf:params:xml:ns:caldav filter"`
+	Hrefs     []string  `xml:"DAV: href"`
+	SyncToken string    `xml:"DAV: sync-token"`
+}
+
+type filter struct {
+	CompFilter CompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// ParsePropFind parses the body of a PROPFIND request
+func ParsePropFind(b []byte) (*PropFind, error) {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return &PropFind{AllProp: true}, nil
+	}
+
+	root, v, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if root != (xml.Name{Space: NamespaceDAV, Local: "propfind"}) {
+		return nil, errors.Errorf("unexpected element %s", root.Local)
+	}
+
+	return &PropFind{AllProp: v.AllProp != nil || len(v.Prop) == 0, Props: v.Prop}, nil
+}
+
+// ParseReport parses the body of a REPORT request
+func ParseReport(b []byte) (*Report, error) {
+	root, v, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{
+		Type:      root,
+		AllProp:   v.AllProp != nil,
+		Props:     v.Prop,
+		Hrefs:     v.Hrefs,
+		SyncToken: strings.TrimSpace(v.SyncToken),
+	}
+
+	if v.Filter != nil {
+		r.Filter = &v.Filter.CompFilter
+	}
+
+	return r, nil
+}
+
+// decode decodes a request body, returning the name of its root element
+func decode(b []byte) (xml.Name, *body, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return xml.Name{}, nil, errors.New("empty body")
+		} else if err != nil {
+			return xml.Name{}, nil, errors.Wrap(err, "Could not parse body")
+		}
+
+		if start, ok := tok.(xml.StartElement); ok {
+			v := &body{}
+			if err := d.DecodeElement(v, &start); err != nil {
+				return xml.Name{}, nil, errors.Wrap(err, "Could not parse body")
+			}
+			return start.Name, v, nil
+		}
+	}
+}
+
+// Match reports whether a calendar object containing todo matches the filter, which is expected to be
+// the VCALENDAR comp-filter of a calendar-query. Time ranges are not supported and match every ToDo.
+func (f *CompFilter) Match(todo internal.ToDo) bool {
+	if !strings.EqualFold(f.Name, "VCALENDAR") {
+		return f.IsNotDefined != nil
+	}
+
+	for _, c := range f.CompFilters {
+		if !c.matchToDo(todo) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchToDo matches the filter against the VTODO component of a calendar object
+func (f *CompFilter) matchToDo(todo internal.ToDo) bool {
+	if !strings.EqualFold(f.Name, "VTODO") {
+		return f.IsNotDefined != nil
+	}
+
+	if f.IsNotDefined != nil {
+		return false
+	}
+
+	props := properties(todo)
+
+	for _, p := range f.PropFilters {
+		value, defined := props[strings.ToUpper(p.Name)]
+
+		switch {
+		case p.IsNotDefined != nil:
+			if defined {
+				return false
+			}
+		case !defined:
+			return false
+		case p.TextMatch != nil:
+			matched := strings.Contains(strings.ToLower(value), strings.ToLower(strings.TrimSpace(p.TextMatch.Value)))
+			if matched == (p.TextMatch.NegateCondition == "yes") {
+				return false
+			}
+		}
+	}
+
+	// ToDos have no sub-components, such as VALARM
+	for _, c := range f.CompFilters {
+		if c.IsNotDefined == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// properties returns the VTODO properties of todo that can be filtered on
+func properties(todo internal.ToDo) map[string]string {
+	props := map[string]string{
+		"UID":     todo.ID,
+		"SUMMARY": todo.Title,
+		"STATUS":  "NEEDS-ACTION",
+	}
+
+	if todo.Completed {
+		props["STATUS"] = "COMPLETED"
+		props["COMPLETED"] = todo.ModTime.UTC().Format("20060102T150405Z")
+	}
+
+	if todo.Due != nil {
+		props["DUE"] = todo.Due.UTC().Format("20060102T150405Z")
+	}
+
+	return props
+}
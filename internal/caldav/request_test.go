@@ -0,0 +1,128 @@
+package caldav_test
+
+import (
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/caldav"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("PropFindEmpty", testPropFindEmpty)
+	t.Run("PropFindProps", testPropFindProps)
+	t.Run("PropFindMalformed", testPropFindMalformed)
+	t.Run("Multiget", testParseMultiget)
+	t.Run("SyncCollection", testParseSyncCollection)
+	t.Run("FilterMatch", testFilterMatch)
+}
+
+func testPropFindEmpty(t *testing.T) {
+
+	pf, err := caldav.ParsePropFind(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !pf.AllProp {
+		t.Fatal("Expected empty PROPFIND to ask for all properties")
+	}
+}
+
+func testPropFindProps(t *testing.T) {
+
+	pf, err := caldav.ParsePropFind([]byte(`<?xml version="1.0"?>
+<A:propfind xmlns:A="DAV:" xmlns:B="http://calendarserver.org/ns/">
+  <A:prop><A:getetag/><B:getctag/></A:prop>
+</A:propfind>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pf.AllProp || len(pf.Props) != 2 || pf.Props[0] != caldav.GetETag || pf.Props[1] != caldav.GetCTag {
+		t.Fatalf("Unexpected PropFind %+v", pf)
+	}
+}
+
+func testPropFindMalformed(t *testing.T) {
+
+	for _, body := range []string{`<propfind xmlns="DAV:"><prop>`, `<mkcol xmlns="DAV:"/>`} {
+		if _, err := caldav.ParsePropFind([]byte(body)); err == nil {
+			t.Fatalf("Expected Error for %s", body)
+		}
+	}
+}
+
+func testParseMultiget(t *testing.T) {
+
+	r, err := caldav.ParseReport([]byte(`<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/><C:calendar-data/></D:prop>
+  <D:href>/caldav/default/1.ics</D:href>
+  <D:href>/caldav/default/2.ics</D:href>
+</C:calendar-multiget>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Type != caldav.CalendarMultiget || len(r.Hrefs) != 2 || len(r.Props) != 2 {
+		t.Fatalf("Unexpected Report %+v", r)
+	}
+}
+
+func testParseSyncCollection(t *testing.T) {
+
+	r, err := caldav.ParseReport([]byte(`<sync-collection xmlns="DAV:">
+  <sync-token> urn:x-todo:sync:1-abc </sync-token>
+  <sync-level>1</sync-level>
+  <prop><getetag/></prop>
+</sync-collection>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Type != caldav.SyncCollection || r.SyncToken != "urn:x-todo:sync:1-abc" {
+		t.Fatalf("Unexpected Report %+v", r)
+	}
+}
+
+func testFilterMatch(t *testing.T) {
+
+	open := internal.ToDo{ID: "1", Title: "Rotate certs"}
+	done := internal.ToDo{ID: "2", Title: "Hand off on-call", Completed: true}
+
+	tests := []struct {
+		name   string
+		filter string
+		open   bool
+		done   bool
+	}{
+		{"AllToDos", `<C:comp-filter name="VCALENDAR"><C:comp-filter name="VTODO"/></C:comp-filter>`, true, true},
+		{"Events", `<C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT"/></C:comp-filter>`, false, false},
+		{"NotCompleted", `<C:comp-filter name="VCALENDAR"><C:comp-filter name="VTODO">
+			<C:prop-filter name="COMPLETED"><C:is-not-defined/></C:prop-filter></C:comp-filter></C:comp-filter>`, true, false},
+		{"StatusNegated", `<C:comp-filter name="VCALENDAR"><C:comp-filter name="VTODO">
+			<C:prop-filter name="STATUS"><C:text-match negate-condition="yes">completed</C:text-match></C:prop-filter>
+			</C:comp-filter></C:comp-filter>`, true, false},
+		{"Summary", `<C:comp-filter name="VCALENDAR"><C:comp-filter name="VTODO">
+			<C:prop-filter name="SUMMARY"><C:text-match>CERT</C:text-match></C:prop-filter></C:comp-filter></C:comp-filter>`, true, false},
+		{"Due", `<C:comp-filter name="VCALENDAR"><C:comp-filter name="VTODO">
+			<C:prop-filter name="DUE"/></C:comp-filter></C:comp-filter>`, false, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := caldav.ParseReport([]byte(`<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/></D:prop><C:filter>` + tc.filter + `</C:filter></C:calendar-query>`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := r.Filter.Match(open); got != tc.open {
+				t.Errorf("Expected match %t for open ToDo, got %t", tc.open, got)
+			}
+
+			if got := r.Filter.Match(done); got != tc.done {
+				t.Errorf("Expected match %t for completed ToDo, got %t", tc.done, got)
+			}
+		})
+	}
+}
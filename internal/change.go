@@ -0,0 +1,23 @@
+package internal
+
+// Change types
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// Change is an event that reports a ToDo was created, updated or deleted, for clients that show changes
+// made by others as they happen. ToDo is the ToDo as it was saved, or as it was before it was deleted.
+type Change struct {
+	Type string `json:"type"`
+	ToDo ToDo   `json:"todo"`
+}
+
+// ListID returns the ID of the list of the changed ToDo
+func (c Change) ListID() string {
+	if c.ToDo.ListID == "" {
+		return DefaultListID
+	}
+	return c.ToDo.ListID
+}
@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ChecklistItem is a step of a ToDo that is a multi-step procedure
+type ChecklistItem struct {
+	ID      string `json:"id" yaml:"id"`
+	Title   string `json:"title" yaml:"title"`
+	Checked bool   `json:"checked" yaml:"checked"`
+}
+
+// Progress is how many of the items in a checklist are checked
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// String returns the progress as done/total, e.g. 3/7
+func (p Progress) String() string {
+	return fmt.Sprintf("%d/%d", p.Done, p.Total)
+}
+
+// Progress returns how many of the ToDo's checklist items are checked, or nil if it has no checklist
+func (t *ToDo) Progress() *Progress {
+	if len(t.Checklist) == 0 {
+		return nil
+	}
+
+	p := &Progress{Total: len(t.Checklist)}
+
+	for _, item := range t.Checklist {
+		if item.Checked {
+			p.Done++
+		}
+	}
+
+	return p
+}
+
+// CompleteIfChecked marks the ToDo as completed if it is set to AutoComplete and every item of its
+// checklist is checked. Unchecking an item does not reopen it.
+func (t *ToDo) CompleteIfChecked() {
+	if p := t.Progress(); t.AutoComplete && p != nil && p.Done == p.Total {
+		t.Completed = true
+	}
+}
+
+// ChecklistItem returns the index of the checklist item with the given ID, or -1 if there is none
+func (t *ToDo) ChecklistItem(id string) int {
+	for i, item := range t.Checklist {
+		if item.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// MarshalJSON marshals the ToDo with its Progress, which is derived from the checklist so it is never
+// read back
+func (t ToDo) MarshalJSON() ([]byte, error) {
+	type todo ToDo
+
+	return json.Marshal(struct {
+		todo
+		Progress *Progress `json:"progress,omitempty"`
+	}{todo(t), t.Progress()})
+}
+
+// checklistIDs returns the IDs of the items of a checklist, which are merged as an add-wins set
+func checklistIDs(checklist []ChecklistItem) []string {
+	ids := []string{}
+	for _, item := range checklist {
+		if item.ID != "" {
+			ids = append(ids, item.ID)
+		}
+	}
+	return ids
+}
+
+// keepChecklist replaces the checklist of dst, which is that of the last write, with the items whose IDs
+// are in ids. Items the last write did not have are taken from other and added after the others, in the
+// order other has them. Items without an ID have not been saved yet and are kept.
+func keepChecklist(dst, other *ToDo, ids []string) {
+	keep := make(map[string]bool)
+	for _, id := range ids {
+		keep[id] = true
+	}
+
+	checklist := []ChecklistItem{}
+
+	for _, item := range dst.Checklist {
+		if item.ID == "" || keep[item.ID] {
+			checklist = append(checklist, item)
+			delete(keep, item.ID)
+		}
+	}
+
+	for _, item := range other.Checklist {
+		if keep[item.ID] {
+			checklist = append(checklist, item)
+			delete(keep, item.ID)
+		}
+	}
+
+	if len(checklist) == 0 {
+		checklist = nil
+	}
+
+	dst.Checklist = checklist
+}
+
+// checklistEqual reports whether a and b have the same items in the same order
+func checklistEqual(a, b []ChecklistItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checklistLess orders checklists, so ties between merged writes are broken the same way on every replica
+func checklistLess(a, b []ChecklistItem) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+
+	for i := range a {
+		x, y := a[i], b[i]
+		switch {
+		case x.ID != y.ID:
+			return x.ID < y.ID
+		case x.Title != y.Title:
+			return x.Title < y.Title
+		case x.Checked != y.Checked:
+			return !x.Checked
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,87 @@
+package internal_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+func TestChecklist(t *testing.T) {
+	t.Run("Progress", testProgress)
+	t.Run("CompleteIfChecked", testCompleteIfChecked)
+	t.Run("MarshalProgress", testMarshalProgress)
+}
+
+func testProgress(t *testing.T) {
+
+	todo := internal.ToDo{}
+
+	if todo.Progress() != nil {
+		t.Fatal("Expected no progress without a checklist")
+	}
+
+	todo.Checklist = []internal.ChecklistItem{
+		{ID: "1", Title: "Tag", Checked: true},
+		{ID: "2", Title: "Build", Checked: true},
+		{ID: "3", Title: "Publish"},
+	}
+
+	if p := todo.Progress(); p.String() != "2/3" {
+		t.Fatalf("Expected progress 2/3, got %s", p)
+	}
+}
+
+func testCompleteIfChecked(t *testing.T) {
+
+	todo := internal.ToDo{Checklist: []internal.ChecklistItem{{ID: "1", Title: "Tag", Checked: true}}}
+
+	todo.CompleteIfChecked()
+
+	if todo.Completed {
+		t.Fatal("Expected ToDo not to be completed unless it is set to AutoComplete")
+	}
+
+	todo.AutoComplete = true
+	todo.CompleteIfChecked()
+
+	if !todo.Completed {
+		t.Fatal("Expected ToDo to be completed when every item is checked")
+	}
+
+	todo = internal.ToDo{AutoComplete: true}
+	todo.CompleteIfChecked()
+
+	if todo.Completed {
+		t.Fatal("Expected ToDo without a checklist not to be completed")
+	}
+}
+
+func testMarshalProgress(t *testing.T) {
+
+	todo := internal.ToDo{ID: "1", Title: "Release", Checklist: []internal.ChecklistItem{{ID: "1", Title: "Tag", Checked: true}}}
+
+	b, err := json.Marshal(todo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(b), `"progress":{"done":1,"total":1}`) || !strings.Contains(string(b), `"title":"Release"`) {
+		t.Fatalf("Expected ToDo with progress, got %s", b)
+	}
+
+	var got internal.ToDo
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Title != "Release" || len(got.Checklist) != 1 {
+		t.Fatalf("Unexpected ToDo %+v", got)
+	}
+
+	b, _ = json.Marshal(internal.ToDo{ID: "1"})
+	if strings.Contains(string(b), "progress") {
+		t.Fatalf("Expected no progress without a checklist, got %s", b)
+	}
+}
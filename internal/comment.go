@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Comment is a message about a ToDo. Mentions holds the IDs of the members of the ToDo's list that the
+// body mentions with @handle.
+type Comment struct {
+	ID       string     `json:"id"`
+	ListID   string     `json:"listId"`
+	ToDoID   string     `json:"todoId"`
+	AuthorID string     `json:"authorId"`
+	Body     string     `json:"body"`
+	Mentions []string   `json:"mentions,omitempty"`
+	Created  time.Time  `json:"created"`
+	Edited   *time.Time `json:"edited,omitempty"`
+}
+
+// Mentions returns the handles mentioned in body, normalized and in the order they first appear. A mention
+// is an @ that does not follow a letter or digit, so email addresses are not mentions, followed by a
+// handle. A trailing dot ends the sentence rather than the handle.
+func Mentions(body string) []string {
+	var handles []string
+
+	for i := 0; i < len(body); i++ {
+		if body[i] != '@' {
+			continue
+		}
+
+		if i > 0 {
+			if r, _ := utf8.DecodeLastRuneInString(body[:i]); unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+				continue
+			}
+		}
+
+		end := i + 1
+		for end < len(body) && isHandleByte(body[end]) {
+			end++
+		}
+
+		handle := NormalizeHandle(strings.TrimRight(body[i+1:end], "."))
+		if ValidHandle(handle) && !stringIn(handle, handles) {
+			handles = append(handles, handle)
+		}
+
+		i = end - 1
+	}
+
+	return handles
+}
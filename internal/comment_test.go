@@ -0,0 +1,34 @@
+package internal_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+func TestComment(t *testing.T) {
+	t.Run("Mentions", testMentions)
+}
+
+func testMentions(t *testing.T) {
+
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{"No mentions here", nil},
+		{"@sam can you review?", []string{"sam"}},
+		{"Thanks @Sam.Jones and @alex_b.", []string{"sam.jones", "alex_b"}},
+		{"(@sam) @SAM @sam, @kim-lee", []string{"sam", "kim-lee"}},
+		{"Mail sam@example.com or @ nobody", nil},
+		{"@" + "abcdefghijklmnopqrstuvwxyz0123456789", nil},
+		{"Ünïcode@sam then @ünïcode", nil},
+	}
+
+	for _, test := range tests {
+		if got := internal.Mentions(test.body); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Mentions(%q) = %q, expected %q", test.body, got, test.want)
+		}
+	}
+}
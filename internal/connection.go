@@ -0,0 +1,11 @@
+package internal
+
+import "time"
+
+// Connection is a WebSocket connection of a client that is subscribed to the changes made to a list
+type Connection struct {
+	ID        string    `json:"id"`
+	ListID    string    `json:"listId"`
+	UserID    string    `json:"userId,omitempty"`
+	Connected time.Time `json:"connected"`
+}
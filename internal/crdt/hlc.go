@@ -0,0 +1,137 @@
+// Package crdt provides the conflict-free replicated data types used to merge ToDos edited concurrently by
+// offline clients: hybrid logical clock timestamps, which order last-writer-wins registers, and an
+// add-wins set.
+package crdt
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MaxDrift is how far ahead of the local clock a remote timestamp may be. A client with a clock far in the
+// future would otherwise win every merge until the rest of the world caught up.
+const MaxDrift = 5 * time.Minute
+
+// ErrClockDrift is returned when a remote timestamp is more than MaxDrift ahead of the local clock
+var ErrClockDrift = errors.New("Clock drift exceeds the maximum allowed")
+
+// Timestamp is a hybrid logical clock timestamp. Wall is Unix time in milliseconds, Logical orders events
+// within the same millisecond and Node, the ID of the replica that issued the timestamp, breaks ties so
+// that every replica orders concurrent writes the same way.
+type Timestamp struct {
+	Wall    int64  `json:"wall" yaml:"wall"`
+	Logical uint32 `json:"logical,omitempty" yaml:"logical,omitempty"`
+	Node    string `json:"node,omitempty" yaml:"node,omitempty"`
+}
+
+// FromTime returns the timestamp of a write made at t by an unknown replica, for data written before
+// timestamps were recorded
+func FromTime(t time.Time) Timestamp {
+	if t.IsZero() {
+		return Timestamp{}
+	}
+	return Timestamp{Wall: millis(t)}
+}
+
+// IsZero reports whether ts is the zero Timestamp
+func (ts Timestamp) IsZero() bool {
+	return ts == Timestamp{}
+}
+
+// Compare returns -1 if ts is before other, 1 if it is after and 0 if they are equal
+func (ts Timestamp) Compare(other Timestamp) int {
+	switch {
+	case ts.Wall != other.Wall:
+		return compareInt(ts.Wall, other.Wall)
+	case ts.Logical != other.Logical:
+		return compareInt(int64(ts.Logical), int64(other.Logical))
+	case ts.Node < other.Node:
+		return -1
+	case ts.Node > other.Node:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Before reports whether ts is before other
+func (ts Timestamp) Before(other Timestamp) bool {
+	return ts.Compare(other) < 0
+}
+
+// Clock issues hybrid logical clock timestamps for a replica. It is safe for concurrent use.
+type Clock struct {
+	node string
+	mu   sync.Mutex
+	last Timestamp
+}
+
+// NewClock returns a Clock for the replica with the given ID
+func NewClock(node string) *Clock {
+	return &Clock{node: node}
+}
+
+// Now returns a timestamp after every timestamp the clock has issued or observed
+func (c *Clock) Now() Timestamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pt := millis(time.Now())
+
+	if pt > c.last.Wall {
+		c.last = Timestamp{Wall: pt, Node: c.node}
+	} else {
+		c.last = Timestamp{Wall: c.last.Wall, Logical: c.last.Logical + 1, Node: c.node}
+	}
+
+	return c.last
+}
+
+// Update advances the clock past a timestamp received from another replica, so timestamps issued later
+// are ordered after it
+func (c *Clock) Update(remote Timestamp) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pt := millis(time.Now())
+
+	if remote.Wall-pt > int64(MaxDrift/time.Millisecond) {
+		return errors.Wrapf(ErrClockDrift, "timestamp is %v ahead", time.Duration(remote.Wall-pt)*time.Millisecond)
+	}
+
+	switch {
+	case pt > c.last.Wall && pt > remote.Wall:
+		c.last = Timestamp{Wall: pt, Node: c.node}
+	case c.last.Wall == remote.Wall:
+		logical := c.last.Logical
+		if remote.Logical > logical {
+			logical = remote.Logical
+		}
+		c.last = Timestamp{Wall: c.last.Wall, Logical: logical + 1, Node: c.node}
+	case c.last.Wall > remote.Wall:
+		c.last = Timestamp{Wall: c.last.Wall, Logical: c.last.Logical + 1, Node: c.node}
+	default:
+		c.last = Timestamp{Wall: remote.Wall, Logical: remote.Logical + 1, Node: c.node}
+	}
+
+	return nil
+}
+
+// millis returns t as Unix time in milliseconds
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// compareInt returns -1, 0 or 1 as a is less than, equal to or greater than b
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
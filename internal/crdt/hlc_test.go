@@ -0,0 +1,88 @@
+package crdt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal/crdt"
+	"github.com/pkg/errors"
+)
+
+func TestClock(t *testing.T) {
+	t.Run("NowIsMonotonic", testNowIsMonotonic)
+	t.Run("UpdateFromFuture", testUpdateFromFuture)
+	t.Run("UpdateDrift", testUpdateDrift)
+	t.Run("Compare", testCompare)
+}
+
+func testNowIsMonotonic(t *testing.T) {
+
+	c := crdt.NewClock("a")
+
+	last := c.Now()
+	for i := 0; i < 1000; i++ {
+		ts := c.Now()
+		if !last.Before(ts) {
+			t.Fatalf("Expected %v to be before %v", last, ts)
+		}
+		last = ts
+	}
+
+	if last.Node != "a" {
+		t.Fatalf("Expected node a, got %s", last.Node)
+	}
+}
+
+func testUpdateFromFuture(t *testing.T) {
+
+	c := crdt.NewClock("a")
+
+	remote := crdt.Timestamp{Wall: crdt.FromTime(time.Now().Add(time.Minute)).Wall, Logical: 7, Node: "b"}
+
+	if err := c.Update(remote); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := c.Now()
+	if !remote.Before(ts) {
+		t.Fatalf("Expected %v to be before %v", remote, ts)
+	}
+
+	if ts.Wall != remote.Wall {
+		t.Fatalf("Expected the clock to keep the remote wall time %d, got %d", remote.Wall, ts.Wall)
+	}
+}
+
+func testUpdateDrift(t *testing.T) {
+
+	c := crdt.NewClock("a")
+
+	remote := crdt.FromTime(time.Now().Add(time.Hour))
+
+	if err := c.Update(remote); errors.Cause(err) != crdt.ErrClockDrift {
+		t.Fatalf("Expected %v, got %v", crdt.ErrClockDrift, err)
+	}
+
+	if ts := c.Now(); !ts.Before(remote) {
+		t.Fatalf("Expected the clock not to advance to %v, got %v", remote, ts)
+	}
+}
+
+func testCompare(t *testing.T) {
+
+	tests := []struct {
+		a, b     crdt.Timestamp
+		expected int
+	}{
+		{crdt.Timestamp{Wall: 1}, crdt.Timestamp{Wall: 2}, -1},
+		{crdt.Timestamp{Wall: 2, Logical: 1}, crdt.Timestamp{Wall: 2}, 1},
+		{crdt.Timestamp{Wall: 2, Node: "a"}, crdt.Timestamp{Wall: 2, Node: "b"}, -1},
+		{crdt.Timestamp{Wall: 2, Logical: 3, Node: "a"}, crdt.Timestamp{Wall: 2, Logical: 3, Node: "a"}, 0},
+	}
+
+	for _, test := range tests {
+		if c := test.a.Compare(test.b); c != test.expected {
+			t.Fatalf("Expected %v compared to %v to be %d, got %d", test.a, test.b, test.expected, c)
+		}
+	}
+}
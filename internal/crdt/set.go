@@ -0,0 +1,200 @@
+package crdt
+
+import "sort"
+
+// AddWinsSet is an observed-remove set of strings, for collections such as tags that clients edit while
+// offline. Each add is tagged with a unique timestamp and a remove only removes the adds its replica had
+// observed, so when one replica adds an element that another concurrently removes, the element is kept.
+//
+// Removed elements are kept, so a replica that has not seen a remove can not add them back by merging, until
+// they are pruned. Removed records when each element was last removed, which is when it can be pruned.
+//
+// The zero value is an empty set. Sets are merged by value, so a set must not be shared between ToDos.
+type AddWinsSet struct {
+	Adds    map[string][]Timestamp `json:"adds,omitempty" yaml:"adds,omitempty"`
+	Removes map[string][]Timestamp `json:"removes,omitempty" yaml:"removes,omitempty"`
+	Removed map[string]Timestamp   `json:"removed,omitempty" yaml:"removed,omitempty"`
+}
+
+// Add adds element to the set. ts must be unique, such as a timestamp from Clock.Now.
+func (s *AddWinsSet) Add(element string, ts Timestamp) {
+	if s.Adds == nil {
+		s.Adds = make(map[string][]Timestamp)
+	}
+	s.Adds[element] = union(s.Adds[element], []Timestamp{ts})
+}
+
+// Remove removes element from the set at ts, along with every add of it that has been observed
+func (s *AddWinsSet) Remove(element string, ts Timestamp) {
+	if len(s.Adds[element]) == 0 {
+		return
+	}
+
+	if s.Removes == nil {
+		s.Removes = make(map[string][]Timestamp)
+	}
+	s.Removes[element] = union(s.Removes[element], s.Adds[element])
+
+	if s.Removed == nil {
+		s.Removed = make(map[string]Timestamp)
+	}
+	if s.Removed[element].Before(ts) {
+		s.Removed[element] = ts
+	}
+}
+
+// Contains reports whether element is in the set
+func (s AddWinsSet) Contains(element string) bool {
+	removed := s.Removes[element]
+
+	for _, ts := range s.Adds[element] {
+		if !contains(removed, ts) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Elements returns the elements in the set, sorted
+func (s AddWinsSet) Elements() []string {
+	elements := []string{}
+
+	for e := range s.Adds {
+		if s.Contains(e) {
+			elements = append(elements, e)
+		}
+	}
+
+	sort.Strings(elements)
+
+	return elements
+}
+
+// Merge returns the union of the adds and removes of s and other
+func (s AddWinsSet) Merge(other AddWinsSet) AddWinsSet {
+	return AddWinsSet{
+		Adds:    mergeTags(s.Adds, other.Adds),
+		Removes: mergeTags(s.Removes, other.Removes),
+		Removed: mergeLatest(s.Removed, other.Removed),
+	}
+}
+
+// Prune forgets the removes of elements that were last removed before horizon, and of those removed longest
+// ago beyond the latest max, so a set that is written often does not grow without bound. A replica that
+// still has an add of a pruned element, because it has not merged since the remove, adds it back.
+// Elements removed before removal times were recorded are taken to be removed at their latest add.
+func (s *AddWinsSet) Prune(horizon Timestamp, max int) {
+	removed := make([]string, 0, len(s.Removes))
+	for e := range s.Removes {
+		removed = append(removed, e)
+	}
+
+	sort.Slice(removed, func(i, j int) bool {
+		a, b := s.removedAt(removed[i]), s.removedAt(removed[j])
+		return a.Before(b) || a == b && removed[i] < removed[j]
+	})
+
+	for i, e := range removed {
+		if !s.removedAt(e).Before(horizon) && len(removed)-i <= max {
+			break
+		}
+
+		var adds []Timestamp
+		for _, ts := range s.Adds[e] {
+			if !contains(s.Removes[e], ts) {
+				adds = append(adds, ts)
+			}
+		}
+
+		if len(adds) == 0 {
+			delete(s.Adds, e)
+		} else {
+			s.Adds[e] = adds
+		}
+
+		delete(s.Removes, e)
+		delete(s.Removed, e)
+	}
+
+	if len(s.Removes) == 0 {
+		s.Removes, s.Removed = nil, nil
+	}
+}
+
+// removedAt returns when element was last removed
+func (s AddWinsSet) removedAt(element string) Timestamp {
+	if ts, ok := s.Removed[element]; ok {
+		return ts
+	}
+
+	tags := s.Removes[element]
+	if len(tags) == 0 {
+		return Timestamp{}
+	}
+
+	return tags[len(tags)-1]
+}
+
+// mergeTags returns the union of the timestamps of each element in a and b
+func mergeTags(a, b map[string][]Timestamp) map[string][]Timestamp {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+
+	merged := make(map[string][]Timestamp)
+
+	for e, tags := range a {
+		merged[e] = union(nil, tags)
+	}
+
+	for e, tags := range b {
+		merged[e] = union(merged[e], tags)
+	}
+
+	return merged
+}
+
+// mergeLatest returns the latest timestamp of each element in a or b
+func mergeLatest(a, b map[string]Timestamp) map[string]Timestamp {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]Timestamp)
+
+	for _, m := range []map[string]Timestamp{a, b} {
+		for e, ts := range m {
+			if merged[e].Before(ts) {
+				merged[e] = ts
+			}
+		}
+	}
+
+	return merged
+}
+
+// union returns the timestamps in a or b, sorted and without duplicates
+func union(a, b []Timestamp) []Timestamp {
+	u := append([]Timestamp{}, a...)
+
+	for _, ts := range b {
+		if !contains(u, ts) {
+			u = append(u, ts)
+		}
+	}
+
+	sort.Slice(u, func(i, j int) bool { return u[i].Before(u[j]) })
+
+	return u
+}
+
+// contains reports whether tags contains ts
+func contains(tags []Timestamp, ts Timestamp) bool {
+	for _, t := range tags {
+		if t == ts {
+			return true
+		}
+	}
+	return false
+}
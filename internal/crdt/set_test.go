@@ -0,0 +1,137 @@
+package crdt_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal/crdt"
+)
+
+func TestAddWinsSet(t *testing.T) {
+	t.Run("AddAndRemove", testAddAndRemove)
+	t.Run("ConcurrentAddWins", testConcurrentAddWins)
+	t.Run("MergeIsCommutative", testMergeIsCommutative)
+	t.Run("Prune", testPrune)
+}
+
+func testAddAndRemove(t *testing.T) {
+
+	c := crdt.NewClock("a")
+
+	var s crdt.AddWinsSet
+	s.Add("home", c.Now())
+	s.Add("work", c.Now())
+	s.Remove("home", c.Now())
+	s.Remove("missing", c.Now())
+
+	if e := s.Elements(); !reflect.DeepEqual(e, []string{"work"}) {
+		t.Fatalf("Expected [work], got %v", e)
+	}
+
+	s.Add("home", c.Now())
+
+	if !s.Contains("home") {
+		t.Fatal("Expected home to be added again")
+	}
+}
+
+func testConcurrentAddWins(t *testing.T) {
+
+	a, b := crdt.NewClock("a"), crdt.NewClock("b")
+
+	var base crdt.AddWinsSet
+	base.Add("urgent", a.Now())
+
+	// One replica removes the element while the other adds it again
+	removed := base.Merge(crdt.AddWinsSet{})
+	removed.Remove("urgent", a.Now())
+
+	added := base.Merge(crdt.AddWinsSet{})
+	added.Add("urgent", b.Now())
+
+	if merged := removed.Merge(added); !merged.Contains("urgent") {
+		t.Fatal("Expected the concurrent add to win")
+	}
+
+	// A remove that observed every add removes the element
+	merged := removed.Merge(added)
+	merged.Remove("urgent", a.Now())
+
+	if merged.Merge(added).Contains("urgent") {
+		t.Fatal("Expected the element to be removed")
+	}
+}
+
+func testMergeIsCommutative(t *testing.T) {
+
+	a, b := crdt.NewClock("a"), crdt.NewClock("b")
+
+	var x, y crdt.AddWinsSet
+	x.Add("one", a.Now())
+	x.Add("two", a.Now())
+	x.Remove("two", a.Now())
+	y.Add("two", b.Now())
+	y.Add("three", b.Now())
+
+	xy, yx := x.Merge(y), y.Merge(x)
+
+	if !reflect.DeepEqual(xy, yx) {
+		t.Fatalf("Expected merges to be equal, got %v and %v", xy, yx)
+	}
+
+	if !reflect.DeepEqual(xy.Merge(y), xy) {
+		t.Fatal("Expected merge to be idempotent")
+	}
+
+	if e := xy.Elements(); !reflect.DeepEqual(e, []string{"one", "three", "two"}) {
+		t.Fatalf("Expected [one three two], got %v", e)
+	}
+}
+
+func testPrune(t *testing.T) {
+
+	c := crdt.NewClock("a")
+
+	var s crdt.AddWinsSet
+	s.Add("old", c.Now())
+	s.Remove("old", c.Now())
+	s.Add("kept", c.Now())
+	s.Add("again", c.Now())
+	s.Remove("again", c.Now())
+
+	horizon := c.Now()
+
+	s.Add("again", c.Now())
+	s.Add("recent", c.Now())
+	s.Remove("recent", c.Now())
+
+	s.Prune(horizon, 10)
+
+	if _, ok := s.Adds["old"]; ok {
+		t.Fatal("Expected the element removed before the horizon to be pruned")
+	}
+
+	if len(s.Adds["again"]) != 1 || len(s.Removes["again"]) != 0 || !s.Contains("again") {
+		t.Fatalf("Expected only the removed add of again to be pruned, got %v", s)
+	}
+
+	if _, ok := s.Removes["recent"]; !ok {
+		t.Fatal("Expected the element removed after the horizon to be kept")
+	}
+
+	if e := s.Elements(); !reflect.DeepEqual(e, []string{"again", "kept"}) {
+		t.Fatalf("Expected [again kept], got %v", e)
+	}
+
+	// Only the most recently removed elements are kept beyond max
+	for _, e := range []string{"a", "b", "c"} {
+		s.Add(e, c.Now())
+		s.Remove(e, c.Now())
+	}
+
+	s.Prune(crdt.Timestamp{}, 2)
+
+	if len(s.Removes) != 2 || s.Removes["b"] == nil || s.Removes["c"] == nil {
+		t.Fatalf("Expected the removes of b and c to be kept, got %v", s.Removes)
+	}
+}
@@ -0,0 +1,43 @@
+package attachments_test
+
+import (
+	"time"
+
+	"github.com/benjaminbartels/todo/internal/blob"
+)
+
+// StoreMock is used to mock the store attachments are kept in
+type StoreMock struct {
+	PresignPutFn        func(string, blob.Upload, time.Duration) (string, error)
+	PresignGetFn        func(string, string, time.Duration) (string, error)
+	DeleteFn            func(string) error
+	DeletePrefixFn      func(string) error
+	PresignPutInvoked   bool
+	PresignGetInvoked   bool
+	DeleteInvoked       bool
+	DeletePrefixInvoked bool
+}
+
+// PresignPut returns a URL that content can be uploaded to
+func (m *StoreMock) PresignPut(key string, upload blob.Upload, expires time.Duration) (string, error) {
+	m.PresignPutInvoked = true
+	return m.PresignPutFn(key, upload, expires)
+}
+
+// PresignGet returns a URL that an object can be downloaded from
+func (m *StoreMock) PresignGet(key, filename string, expires time.Duration) (string, error) {
+	m.PresignGetInvoked = true
+	return m.PresignGetFn(key, filename, expires)
+}
+
+// Delete removes an object
+func (m *StoreMock) Delete(key string) error {
+	m.DeleteInvoked = true
+	return m.DeleteFn(key)
+}
+
+// DeletePrefix removes every object whose key starts with prefix
+func (m *StoreMock) DeletePrefix(prefix string) error {
+	m.DeletePrefixInvoked = true
+	return m.DeletePrefixFn(prefix)
+}
@@ -0,0 +1,131 @@
+// Package attachments provides a database.ToDoRepo decorator that deletes the attachments of the ToDos
+// deleted through it from their blob.Store, so every route that deletes ToDos cleans up their objects.
+package attachments
+
+import (
+	"log"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/blob"
+	"github.com/benjaminbartels/todo/internal/database"
+)
+
+// ToDoRepo is a database.ToDoRepo that deletes the attachments of the ToDos deleted through it
+type ToDoRepo struct {
+	repo  database.ToDoRepo
+	store blob.Store
+}
+
+// NewToDoRepo returns a new ToDo repository that deletes the attachments of ToDos deleted from repo
+// from store
+func NewToDoRepo(repo database.ToDoRepo, store blob.Store) *ToDoRepo {
+	return &ToDoRepo{
+		repo:  repo,
+		store: store,
+	}
+}
+
+// Get returns a ToDo by its ID
+func (r *ToDoRepo) Get(id string) (*internal.ToDo, error) {
+	return r.repo.Get(id)
+}
+
+// GetAll returns all ToDos
+func (r *ToDoRepo) GetAll() ([]internal.ToDo, error) {
+	return r.repo.GetAll()
+}
+
+// GetChangedSince returns the ToDos modified, and the tombstones of those deleted, after since
+func (r *ToDoRepo) GetChangedSince(since time.Time) ([]internal.ToDo, []internal.Tombstone, error) {
+	c, ok := r.repo.(database.ToDoChangeRepo)
+	if !ok {
+		return nil, nil, database.ErrNotSupported
+	}
+
+	return c.GetChangedSince(since)
+}
+
+// IsDeleted reports whether the ToDo with the given ID was deleted
+func (r *ToDoRepo) IsDeleted(id string) (bool, error) {
+	d, ok := r.repo.(database.ToDoTombstoneRepo)
+	if !ok {
+		return false, database.ErrNotSupported
+	}
+
+	return d.IsDeleted(id)
+}
+
+// GetByCompleted returns the ToDos that are, or are not, completed
+func (r *ToDoRepo) GetByCompleted(completed bool) ([]internal.ToDo, error) {
+	q, ok := r.repo.(database.ToDoQuerier)
+	if !ok {
+		return nil, database.ErrNotSupported
+	}
+
+	return q.GetByCompleted(completed)
+}
+
+// GetDueBetween returns the ToDos that are due within the given range
+func (r *ToDoRepo) GetDueBetween(from, to time.Time) ([]internal.ToDo, error) {
+	q, ok := r.repo.(database.ToDoQuerier)
+	if !ok {
+		return nil, database.ErrNotSupported
+	}
+
+	return q.GetDueBetween(from, to)
+}
+
+// GetByTags returns the ToDos that have all of the tags, or any of them if all is false
+func (r *ToDoRepo) GetByTags(tags []string, all bool) ([]internal.ToDo, error) {
+	q, ok := r.repo.(database.ToDoTagQuerier)
+	if !ok {
+		return nil, database.ErrNotSupported
+	}
+
+	return q.GetByTags(tags, all)
+}
+
+// Save creates or updates a ToDo
+func (r *ToDoRepo) Save(todo *internal.ToDo) error {
+	return r.repo.Save(todo)
+}
+
+// SaveIfUnmodified updates a ToDo if it was last modified at modTime
+func (r *ToDoRepo) SaveIfUnmodified(todo *internal.ToDo, modTime time.Time) error {
+	c, ok := r.repo.(database.ToDoConditionalRepo)
+	if !ok {
+		return database.ErrNotSupported
+	}
+
+	return c.SaveIfUnmodified(todo, modTime)
+}
+
+// SaveAll creates or updates many ToDos
+func (r *ToDoRepo) SaveAll(todos []*internal.ToDo) error {
+	return database.SaveAll(r.repo, todos)
+}
+
+// Delete permanently removes a ToDo and its attachments. Failing to delete the attachments is logged
+// rather than returned, as the ToDo has already been deleted.
+func (r *ToDoRepo) Delete(id string) error {
+	// The ToDo is read first, as the keys of its attachments depend on its list
+	t, err := r.repo.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if err := r.repo.Delete(id); err != nil {
+		return err
+	}
+
+	// Objects are removed even if the ToDo lists no attachments, in case an upload finished after its
+	// attachment was removed
+	if t != nil {
+		if err := r.store.DeletePrefix(blob.Prefix(t.ListID, t.ID)); err != nil {
+			log.Printf("Could not delete the attachments of ToDo %s: %v", t.ID, err)
+		}
+	}
+
+	return nil
+}
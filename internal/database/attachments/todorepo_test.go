@@ -0,0 +1,133 @@
+package attachments_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/database/attachments"
+	pkgerrors "github.com/pkg/errors"
+)
+
+const testUUID = "a8a43435-20d8-4af2-8f94-f504aff2c6f3"
+
+func TestToDoRepo(t *testing.T) {
+	t.Run("DeleteRemovesAttachments", testDeleteRemovesAttachments)
+	t.Run("DeleteErrorKeepsAttachments", testDeleteErrorKeepsAttachments)
+	t.Run("DeleteNotFound", testDeleteNotFound)
+	t.Run("StoreErrorIgnored", testStoreErrorIgnored)
+	t.Run("GetChangedSinceNotSupported", testGetChangedSinceNotSupported)
+	t.Run("QueriesNotSupported", testQueriesNotSupported)
+}
+
+func newMocks() (*RepoMock, *StoreMock) {
+	repo := &RepoMock{
+		GetFn: func(id string) (*internal.ToDo, error) {
+			return &internal.ToDo{ID: id, ListID: "work", Title: "Some ToDo"}, nil
+		},
+		DeleteFn: func(string) error {
+			return nil
+		},
+	}
+
+	store := &StoreMock{
+		DeletePrefixFn: func(string) error {
+			return nil
+		},
+	}
+
+	return repo, store
+}
+
+func testDeleteRemovesAttachments(t *testing.T) {
+
+	repo, store := newMocks()
+
+	var prefix string
+	store.DeletePrefixFn = func(p string) error {
+		prefix = p
+		return nil
+	}
+
+	if err := attachments.NewToDoRepo(repo, store).Delete(testUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if !repo.DeleteInvoked || prefix != "todos/work/"+testUUID+"/" {
+		t.Fatalf("Expected ToDo and its attachments to be deleted, got prefix %q", prefix)
+	}
+}
+
+func testDeleteErrorKeepsAttachments(t *testing.T) {
+
+	repo, store := newMocks()
+	repo.DeleteFn = func(string) error {
+		return errors.New("DB Error")
+	}
+
+	if err := attachments.NewToDoRepo(repo, store).Delete(testUUID); err == nil {
+		t.Fatal("Expected Error")
+	}
+
+	if store.DeletePrefixInvoked {
+		t.Fatal("Expected attachments to be kept")
+	}
+}
+
+func testDeleteNotFound(t *testing.T) {
+
+	repo, store := newMocks()
+	repo.GetFn = func(string) (*internal.ToDo, error) {
+		return nil, nil
+	}
+
+	if err := attachments.NewToDoRepo(repo, store).Delete(testUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if store.DeletePrefixInvoked {
+		t.Fatal("Expected no attachments to be deleted")
+	}
+}
+
+func testStoreErrorIgnored(t *testing.T) {
+
+	repo, store := newMocks()
+	store.DeletePrefixFn = func(string) error {
+		return errors.New("S3 Error")
+	}
+
+	if err := attachments.NewToDoRepo(repo, store).Delete(testUUID); err != nil {
+		t.Fatalf("Expected the ToDo to be deleted, got %v", err)
+	}
+}
+
+func testGetChangedSinceNotSupported(t *testing.T) {
+
+	repo, store := newMocks()
+
+	_, _, err := attachments.NewToDoRepo(repo, store).GetChangedSince(time.Now())
+	if pkgerrors.Cause(err) != database.ErrNotSupported {
+		t.Fatalf("Expected %v, got %v", database.ErrNotSupported, err)
+	}
+}
+
+func testQueriesNotSupported(t *testing.T) {
+
+	repo, store := newMocks()
+	decorated := attachments.NewToDoRepo(repo, store)
+
+	if _, err := decorated.GetByCompleted(true); pkgerrors.Cause(err) != database.ErrNotSupported {
+		t.Fatalf("Expected %v, got %v", database.ErrNotSupported, err)
+	}
+
+	if _, err := decorated.GetDueBetween(time.Now(), time.Now()); pkgerrors.Cause(err) != database.ErrNotSupported {
+		t.Fatalf("Expected %v, got %v", database.ErrNotSupported, err)
+	}
+
+	if _, err := decorated.GetByTags([]string{"infra"}, true); pkgerrors.Cause(err) != database.ErrNotSupported {
+		t.Fatalf("Expected %v, got %v", database.ErrNotSupported, err)
+	}
+}
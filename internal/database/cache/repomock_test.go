@@ -0,0 +1,85 @@
+package cache_test
+
+import (
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+// RepoMock is used to mock the repository being cached
+type RepoMock struct {
+	GetFn       func(string) (*internal.ToDo, error)
+	GetAllFn    func() ([]internal.ToDo, error)
+	SaveFn      func(todo *internal.ToDo) error
+	DeleteFn    func(string) error
+	GetCalls    int
+	GetAllCalls int
+	SaveCalls   int
+	DeleteCalls int
+}
+
+// Get returns a ToDo by its ID
+func (m *RepoMock) Get(id string) (*internal.ToDo, error) {
+	m.GetCalls++
+	return m.GetFn(id)
+}
+
+// GetAll returns all ToDos
+func (m *RepoMock) GetAll() ([]internal.ToDo, error) {
+	m.GetAllCalls++
+	return m.GetAllFn()
+}
+
+// Save creates or updates a ToDo
+func (m *RepoMock) Save(todo *internal.ToDo) error {
+	m.SaveCalls++
+	return m.SaveFn(todo)
+}
+
+// Delete permanently removes a ToDo
+func (m *RepoMock) Delete(id string) error {
+	m.DeleteCalls++
+	return m.DeleteFn(id)
+}
+
+// ChangeRepoMock is used to mock a repository being cached that tracks changes
+type ChangeRepoMock struct {
+	*RepoMock
+	GetChangedSinceFn    func(time.Time) ([]internal.ToDo, []internal.Tombstone, error)
+	GetChangedSinceCalls int
+}
+
+// GetChangedSince returns the ToDos changed and deleted after since
+func (m *ChangeRepoMock) GetChangedSince(since time.Time) ([]internal.ToDo, []internal.Tombstone, error) {
+	m.GetChangedSinceCalls++
+	return m.GetChangedSinceFn(since)
+}
+
+// QuerierRepoMock is used to mock a repository being cached that can filter ToDos
+type QuerierRepoMock struct {
+	*RepoMock
+	GetByCompletedFn    func(bool) ([]internal.ToDo, error)
+	GetDueBetweenFn     func(time.Time, time.Time) ([]internal.ToDo, error)
+	GetByTagsFn         func([]string, bool) ([]internal.ToDo, error)
+	GetByCompletedCalls int
+	GetDueBetweenCalls  int
+	GetByTagsCalls      int
+}
+
+// GetByCompleted returns the ToDos that are, or are not, completed
+func (m *QuerierRepoMock) GetByCompleted(completed bool) ([]internal.ToDo, error) {
+	m.GetByCompletedCalls++
+	return m.GetByCompletedFn(completed)
+}
+
+// GetDueBetween returns the ToDos that are due within the given range
+func (m *QuerierRepoMock) GetDueBetween(from, to time.Time) ([]internal.ToDo, error) {
+	m.GetDueBetweenCalls++
+	return m.GetDueBetweenFn(from, to)
+}
+
+// GetByTags returns the ToDos that have all of the tags, or any of them if all is false
+func (m *QuerierRepoMock) GetByTags(tags []string, all bool) ([]internal.ToDo, error) {
+	m.GetByTagsCalls++
+	return m.GetByTagsFn(tags, all)
+}
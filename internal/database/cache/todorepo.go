@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+)
+
+// ToDoRepo is a database.ToDoRepo that caches the results of Get and GetAll from the repository of a list
+// and invalidates them when ToDos are saved or deleted. Keys include the list, so repositories of different
+// lists can share a Cache.
+//
+// Each save or delete starts a new generation. Results read in an earlier generation are not cached, so a
+// Get or GetAll that was in flight during a write can not put back what the write replaced.
+type ToDoRepo struct {
+	repo   database.ToDoRepo
+	listID string
+	cache  Cache
+	ttl    time.Duration
+
+	mu         sync.Mutex
+	generation uint64
+}
+
+// NewToDoRepo returns a new ToDo repository that caches results from repo, the repository of the list
+// with the given ID, in cache for ttl
+func NewToDoRepo(repo database.ToDoRepo, listID string, cache Cache, ttl time.Duration) *ToDoRepo {
+	return &ToDoRepo{
+		repo:   repo,
+		listID: listID,
+		cache:  cache,
+		ttl:    ttl,
+	}
+}
+
+// Get returns a ToDo by its ID
+func (r *ToDoRepo) Get(id string) (*internal.ToDo, error) {
+	key := r.toDoKey(id)
+
+	if b, ok := r.cache.Get(key); ok {
+		t := &internal.ToDo{}
+		if err := json.Unmarshal(b, t); err == nil {
+			return t, nil
+		}
+		r.cache.Delete(key)
+	}
+
+	generation := r.current()
+
+	t, err := r.repo.Get(id)
+	if err != nil || t == nil {
+		return t, err
+	}
+
+	r.set(key, t, generation)
+
+	return t, nil
+}
+
+// GetAll returns all ToDos
+func (r *ToDoRepo) GetAll() ([]internal.ToDo, error) {
+	key := r.allKey()
+
+	if b, ok := r.cache.Get(key); ok {
+		t := []internal.ToDo{}
+		if err := json.Unmarshal(b, &t); err == nil {
+			return t, nil
+		}
+		r.cache.Delete(key)
+	}
+
+	generation := r.current()
+
+	t, err := r.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	r.set(key, t, generation)
+
+	return t, nil
+}
+
+// Save creates or updates a ToDo
+func (r *ToDoRepo) Save(todo *internal.ToDo) error {
+	err := r.repo.Save(todo)
+
+	// Invalidate after saving, as the ID may have been assigned by the underlying repository
+	r.invalidate(r.toDoKey(todo.ID), r.allKey())
+
+	return err
+}
+
+// SaveIfUnmodified updates a ToDo if it was last modified at modTime
+func (r *ToDoRepo) SaveIfUnmodified(todo *internal.ToDo, modTime time.Time) error {
+	c, ok := r.repo.(database.ToDoConditionalRepo)
+	if !ok {
+		return database.ErrNotSupported
+	}
+
+	err := c.SaveIfUnmodified(todo, modTime)
+
+	r.invalidate(r.toDoKey(todo.ID), r.allKey())
+
+	return err
+}
+
+// SaveAll creates or updates many ToDos
+func (r *ToDoRepo) SaveAll(todos []*internal.ToDo) error {
+	err := database.SaveAll(r.repo, todos)
+
+	keys := []string{r.allKey()}
+	for _, t := range todos {
+		keys = append(keys, r.toDoKey(t.ID))
+	}
+
+	r.invalidate(keys...)
+
+	return err
+}
+
+// Delete permanently removes a ToDo
+func (r *ToDoRepo) Delete(id string) error {
+	err := r.repo.Delete(id)
+
+	r.invalidate(r.toDoKey(id), r.allKey())
+
+	return err
+}
+
+// GetChangedSince returns the ToDos modified, and the tombstones of those deleted, after since. Changes are
+// never cached, as clients that sync must not miss any.
+func (r *ToDoRepo) GetChangedSince(since time.Time) ([]internal.ToDo, []internal.Tombstone, error) {
+	c, ok := r.repo.(database.ToDoChangeRepo)
+	if !ok {
+		return nil, nil, database.ErrNotSupported
+	}
+
+	return c.GetChangedSince(since)
+}
+
+// IsDeleted reports whether the ToDo with the given ID was deleted. Tombstones are not cached.
+func (r *ToDoRepo) IsDeleted(id string) (bool, error) {
+	d, ok := r.repo.(database.ToDoTombstoneRepo)
+	if !ok {
+		return false, database.ErrNotSupported
+	}
+
+	return d.IsDeleted(id)
+}
+
+// GetByCompleted returns the ToDos that are, or are not, completed. Queries are not cached, as saving
+// a ToDo only invalidates the keys it is cached under.
+func (r *ToDoRepo) GetByCompleted(completed bool) ([]internal.ToDo, error) {
+	q, ok := r.repo.(database.ToDoQuerier)
+	if !ok {
+		return nil, database.ErrNotSupported
+	}
+
+	return q.GetByCompleted(completed)
+}
+
+// GetDueBetween returns the ToDos that are due within the given range. Queries are not cached.
+func (r *ToDoRepo) GetDueBetween(from, to time.Time) ([]internal.ToDo, error) {
+	q, ok := r.repo.(database.ToDoQuerier)
+	if !ok {
+		return nil, database.ErrNotSupported
+	}
+
+	return q.GetDueBetween(from, to)
+}
+
+// GetByTags returns the ToDos that have all of the tags, or any of them if all is false. Queries are not cached.
+func (r *ToDoRepo) GetByTags(tags []string, all bool) ([]internal.ToDo, error) {
+	q, ok := r.repo.(database.ToDoTagQuerier)
+	if !ok {
+		return nil, database.ErrNotSupported
+	}
+
+	return q.GetByTags(tags, all)
+}
+
+// current returns the current generation, which is read before reading from the repository
+func (r *ToDoRepo) current() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.generation
+}
+
+// set stores v, read in the given generation, in the cache unless a write has started a new generation
+// since. Values that can not be encoded are not cached.
+func (r *ToDoRepo) set(key string, v interface{}, generation uint64) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.generation == generation {
+		r.cache.Set(key, b, r.ttl)
+	}
+}
+
+// invalidate starts a new generation and removes keys from the cache
+func (r *ToDoRepo) invalidate(keys ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.generation++
+	r.cache.Delete(keys...)
+}
+
+// allKey returns the cache key used for the result of GetAll
+func (r *ToDoRepo) allKey() string {
+	return "todos:" + r.listID
+}
+
+// toDoKey returns the cache key used for a single ToDo
+func (r *ToDoRepo) toDoKey(id string) string {
+	return "todo:" + r.listID + ":" + id
+}
@@ -0,0 +1,442 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/database/cache"
+	"github.com/pkg/errors"
+)
+
+const testUUID = "a8a43435-20d8-4af2-8f94-f504aff2c6f3"
+
+var savedToDo = internal.ToDo{
+	ID:    testUUID,
+	Title: "Some ToDo",
+}
+
+func TestToDoRepo(t *testing.T) {
+	t.Run("GetToDoCached", testGetToDoCached)
+	t.Run("GetToDoNotFoundNotCached", testGetToDoNotFoundNotCached)
+	t.Run("GetToDoErrorNotCached", testGetToDoErrorNotCached)
+	t.Run("GetAllToDosCached", testGetAllToDosCached)
+	t.Run("GetAllToDosExpired", testGetAllToDosExpired)
+	t.Run("SaveInvalidates", testSaveInvalidates)
+	t.Run("SaveNewInvalidatesAll", testSaveNewInvalidatesAll)
+	t.Run("DeleteInvalidates", testDeleteInvalidates)
+	t.Run("CachedToDoIsCopy", testCachedToDoIsCopy)
+	t.Run("ListsShareCache", testListsShareCache)
+	t.Run("ReadDuringWriteNotCached", testReadDuringWriteNotCached)
+	t.Run("GetChangedSinceNotCached", testGetChangedSinceNotCached)
+	t.Run("GetChangedSinceNotSupported", testGetChangedSinceNotSupported)
+	t.Run("QueriesNotCached", testQueriesNotCached)
+	t.Run("QueriesNotSupported", testQueriesNotSupported)
+}
+
+func TestMemoryCache(t *testing.T) {
+	t.Run("EvictsLeastRecentlyUsed", testEvictsLeastRecentlyUsed)
+	t.Run("ZeroSize", testZeroSize)
+}
+
+func newMock() *RepoMock {
+	return &RepoMock{
+		GetFn: func(string) (*internal.ToDo, error) {
+			t := savedToDo
+			return &t, nil
+		},
+		GetAllFn: func() ([]internal.ToDo, error) {
+			return []internal.ToDo{savedToDo}, nil
+		},
+		SaveFn: func(todo *internal.ToDo) error {
+			if todo.ID == "" {
+				todo.ID = testUUID
+			}
+			return nil
+		},
+		DeleteFn: func(string) error {
+			return nil
+		},
+	}
+}
+
+func testGetToDoCached(t *testing.T) {
+
+	m := newMock()
+	repo := cache.NewToDoRepo(m, internal.DefaultListID, cache.NewMemoryCache(10), time.Minute)
+
+	for i := 0; i < 3; i++ {
+		toDo, err := repo.Get(testUUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if toDo == nil || toDo.ID != testUUID {
+			t.Fatal("Expected ToDo have a value")
+		}
+	}
+
+	if m.GetCalls != 1 {
+		t.Fatalf("Expected Get to be invoked once, got %d", m.GetCalls)
+	}
+}
+
+func testGetToDoNotFoundNotCached(t *testing.T) {
+
+	m := newMock()
+	m.GetFn = func(string) (*internal.ToDo, error) {
+		return nil, nil
+	}
+
+	repo := cache.NewToDoRepo(m, internal.DefaultListID, cache.NewMemoryCache(10), time.Minute)
+
+	for i := 0; i < 2; i++ {
+		toDo, err := repo.Get(testUUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if toDo != nil {
+			t.Fatal("Expected ToDo to be nil")
+		}
+	}
+
+	if m.GetCalls != 2 {
+		t.Fatalf("Expected Get to be invoked twice, got %d", m.GetCalls)
+	}
+}
+
+func testGetToDoErrorNotCached(t *testing.T) {
+
+	m := newMock()
+	m.GetFn = func(string) (*internal.ToDo, error) {
+		return nil, errors.New("DB Error")
+	}
+
+	repo := cache.NewToDoRepo(m, internal.DefaultListID, cache.NewMemoryCache(10), time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if _, err := repo.Get(testUUID); err == nil {
+			t.Fatal("Expected Error")
+		}
+	}
+
+	if m.GetCalls != 2 {
+		t.Fatalf("Expected Get to be invoked twice, got %d", m.GetCalls)
+	}
+}
+
+func testGetAllToDosCached(t *testing.T) {
+
+	m := newMock()
+	repo := cache.NewToDoRepo(m, internal.DefaultListID, cache.NewMemoryCache(10), time.Minute)
+
+	for i := 0; i < 3; i++ {
+		toDos, err := repo.GetAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(toDos) != 1 {
+			t.Fatal("Expected 1 ToDo in result")
+		}
+	}
+
+	if m.GetAllCalls != 1 {
+		t.Fatalf("Expected GetAll to be invoked once, got %d", m.GetAllCalls)
+	}
+}
+
+func testGetAllToDosExpired(t *testing.T) {
+
+	m := newMock()
+	repo := cache.NewToDoRepo(m, internal.DefaultListID, cache.NewMemoryCache(10), time.Millisecond)
+
+	if _, err := repo.GetAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := repo.GetAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.GetAllCalls != 2 {
+		t.Fatalf("Expected GetAll to be invoked twice, got %d", m.GetAllCalls)
+	}
+}
+
+func testSaveInvalidates(t *testing.T) {
+
+	m := newMock()
+	repo := cache.NewToDoRepo(m, internal.DefaultListID, cache.NewMemoryCache(10), time.Minute)
+
+	if _, err := repo.Get(testUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.GetAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	toDo := savedToDo
+	if err := repo.Save(&toDo); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Get(testUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.GetAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.GetCalls != 2 || m.GetAllCalls != 2 {
+		t.Fatalf("Expected Get and GetAll to be invoked twice, got %d and %d", m.GetCalls, m.GetAllCalls)
+	}
+}
+
+func testSaveNewInvalidatesAll(t *testing.T) {
+
+	m := newMock()
+	repo := cache.NewToDoRepo(m, internal.DefaultListID, cache.NewMemoryCache(10), time.Minute)
+
+	if _, err := repo.GetAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Save(&internal.ToDo{Title: "New ToDo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.GetAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.GetAllCalls != 2 {
+		t.Fatalf("Expected GetAll to be invoked twice, got %d", m.GetAllCalls)
+	}
+}
+
+func testDeleteInvalidates(t *testing.T) {
+
+	m := newMock()
+	repo := cache.NewToDoRepo(m, internal.DefaultListID, cache.NewMemoryCache(10), time.Minute)
+
+	if _, err := repo.Get(testUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Delete(testUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Get(testUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.GetCalls != 2 {
+		t.Fatalf("Expected Get to be invoked twice, got %d", m.GetCalls)
+	}
+}
+
+func testCachedToDoIsCopy(t *testing.T) {
+
+	m := newMock()
+	repo := cache.NewToDoRepo(m, internal.DefaultListID, cache.NewMemoryCache(10), time.Minute)
+
+	toDo, err := repo.Get(testUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toDo.Title = "Changed by caller"
+
+	toDo, err = repo.Get(testUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if toDo.Title != savedToDo.Title {
+		t.Fatalf("Expected title '%s', got '%s'", savedToDo.Title, toDo.Title)
+	}
+}
+
+func testListsShareCache(t *testing.T) {
+
+	c := cache.NewMemoryCache(10)
+
+	work, home := newMock(), newMock()
+	home.GetAllFn = func() ([]internal.ToDo, error) {
+		return []internal.ToDo{}, nil
+	}
+
+	if _, err := cache.NewToDoRepo(work, "work", c, time.Minute).GetAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	todos, err := cache.NewToDoRepo(home, "home", c, time.Minute).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(todos) != 0 || home.GetAllCalls != 1 {
+		t.Fatalf("Expected the ToDos of home to be read from its repository, got %v", todos)
+	}
+}
+
+func testReadDuringWriteNotCached(t *testing.T) {
+
+	m := newMock()
+	repo := cache.NewToDoRepo(m, internal.DefaultListID, cache.NewMemoryCache(10), time.Minute)
+
+	// The ToDo is saved after the first Get read it, but before that Get cached it
+	get := m.GetFn
+	m.GetFn = func(id string) (*internal.ToDo, error) {
+		t, err := get(id)
+		if m.GetCalls == 1 {
+			updated := savedToDo
+			if err := repo.Save(&updated); err != nil {
+				return nil, err
+			}
+		}
+		return t, err
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := repo.Get(testUUID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if m.GetCalls != 2 {
+		t.Fatalf("Expected the ToDo read before the save not to be cached, got %d calls to Get", m.GetCalls)
+	}
+}
+
+func testEvictsLeastRecentlyUsed(t *testing.T) {
+
+	c := cache.NewMemoryCache(2)
+
+	c.Set("a", []byte("1"), time.Minute)
+	c.Set("b", []byte("2"), time.Minute)
+
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Expected a to be cached")
+	}
+
+	c.Set("c", []byte("3"), time.Minute)
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("Expected b to be evicted")
+	}
+
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Expected a to be cached")
+	}
+
+	if c.Len() != 2 {
+		t.Fatalf("Expected 2 entries, got %d", c.Len())
+	}
+}
+
+func testZeroSize(t *testing.T) {
+
+	c := cache.NewMemoryCache(0)
+
+	c.Set("a", []byte("1"), time.Minute)
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Expected nothing to be cached")
+	}
+}
+
+func testGetChangedSinceNotCached(t *testing.T) {
+
+	m := &ChangeRepoMock{
+		RepoMock: newMock(),
+		GetChangedSinceFn: func(time.Time) ([]internal.ToDo, []internal.Tombstone, error) {
+			return []internal.ToDo{savedToDo}, []internal.Tombstone{}, nil
+		},
+	}
+	repo := cache.NewToDoRepo(m, internal.DefaultListID, cache.NewMemoryCache(10), time.Minute)
+
+	for i := 0; i < 2; i++ {
+		todos, _, err := repo.GetChangedSince(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(todos) != 1 {
+			t.Fatalf("Expected 1 ToDo, got %d", len(todos))
+		}
+	}
+
+	if m.GetChangedSinceCalls != 2 {
+		t.Fatalf("Expected GetChangedSince to be invoked twice, got %d", m.GetChangedSinceCalls)
+	}
+}
+
+func testGetChangedSinceNotSupported(t *testing.T) {
+
+	repo := cache.NewToDoRepo(newMock(), internal.DefaultListID, cache.NewMemoryCache(10), time.Minute)
+
+	if _, _, err := repo.GetChangedSince(time.Now()); errors.Cause(err) != database.ErrNotSupported {
+		t.Fatalf("Expected %v, got %v", database.ErrNotSupported, err)
+	}
+}
+
+func testQueriesNotCached(t *testing.T) {
+
+	m := &QuerierRepoMock{
+		RepoMock: newMock(),
+		GetByCompletedFn: func(bool) ([]internal.ToDo, error) {
+			return []internal.ToDo{savedToDo}, nil
+		},
+		GetDueBetweenFn: func(time.Time, time.Time) ([]internal.ToDo, error) {
+			return []internal.ToDo{savedToDo}, nil
+		},
+		GetByTagsFn: func([]string, bool) ([]internal.ToDo, error) {
+			return []internal.ToDo{savedToDo}, nil
+		},
+	}
+	repo := cache.NewToDoRepo(m, internal.DefaultListID, cache.NewMemoryCache(10), time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if todos, err := repo.GetByCompleted(false); err != nil || len(todos) != 1 {
+			t.Fatalf("Expected 1 ToDo, got %v, %v", todos, err)
+		}
+
+		if todos, err := repo.GetDueBetween(time.Now(), time.Now().Add(time.Hour)); err != nil || len(todos) != 1 {
+			t.Fatalf("Expected 1 ToDo, got %v, %v", todos, err)
+		}
+
+		if todos, err := repo.GetByTags([]string{"infra"}, true); err != nil || len(todos) != 1 {
+			t.Fatalf("Expected 1 ToDo, got %v, %v", todos, err)
+		}
+	}
+
+	if m.GetByCompletedCalls != 2 || m.GetDueBetweenCalls != 2 || m.GetByTagsCalls != 2 || m.GetAllCalls != 0 {
+		t.Fatalf("Expected every query to reach the repository, got %+v", m)
+	}
+}
+
+func testQueriesNotSupported(t *testing.T) {
+
+	repo := cache.NewToDoRepo(newMock(), internal.DefaultListID, cache.NewMemoryCache(10), time.Minute)
+
+	if _, err := repo.GetByCompleted(true); errors.Cause(err) != database.ErrNotSupported {
+		t.Fatalf("Expected %v, got %v", database.ErrNotSupported, err)
+	}
+
+	if _, err := repo.GetDueBetween(time.Now(), time.Now()); errors.Cause(err) != database.ErrNotSupported {
+		t.Fatalf("Expected %v, got %v", database.ErrNotSupported, err)
+	}
+
+	if _, err := repo.GetByTags([]string{"infra"}, true); errors.Cause(err) != database.ErrNotSupported {
+		t.Fatalf("Expected %v, got %v", database.ErrNotSupported, err)
+	}
+}
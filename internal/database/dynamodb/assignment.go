@@ -0,0 +1,189 @@
+package dynamodb
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+// assignmentsPrefix starts the partition keys of the assignments of the ToDos in a list, which are followed
+// by the list ID. A ToDo has an assignment for each user it is assigned to, as the assignee index can only
+// be keyed by a single value.
+const assignmentsPrefix = reservedPrefix + "assigned#"
+
+// assignmentItem is how the assignment of a ToDo to a user is stored. It is indexed by assignee and the
+// ModTime of the ToDo.
+type assignmentItem struct {
+	ListID     string    `json:"listId"`
+	ID         string    `json:"id"`
+	AssigneeID string    `json:"assigneeId"`
+	ToDoListID string    `json:"todoListId"`
+	ToDoID     string    `json:"todoId"`
+	ModTime    time.Time `json:"modTime"`
+}
+
+// assignmentsListID returns the partition key of the assignments of ToDos in a list
+func assignmentsListID(listID string) string {
+	return assignmentsPrefix + listID
+}
+
+// assignmentID returns the sort key of the assignment of a ToDo to a user
+func assignmentID(todoID, userID string) string {
+	return todoID + "#" + userID
+}
+
+// assigneeIDs returns the users a ToDo item is assigned to
+func assigneeIDs(item map[string]*dynamodb.AttributeValue) []string {
+	if av, ok := item["assigneeIds"]; ok {
+		return aws.StringValueSlice(av.SS)
+	}
+	return nil
+}
+
+// assignmentRequests returns the requests that write the assignments of todo
+func assignmentRequests(todo *internal.ToDo) ([]*dynamodb.WriteRequest, error) {
+	requests := []*dynamodb.WriteRequest{}
+
+	for _, userID := range todo.AssigneeIDs {
+		item, err := dynamodbattribute.MarshalMap(assignmentItem{
+			ListID:     assignmentsListID(todo.ListID),
+			ID:         assignmentID(todo.ID, userID),
+			AssigneeID: userID,
+			ToDoListID: todo.ListID,
+			ToDoID:     todo.ID,
+			ModTime:    todo.ModTime,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		requests = append(requests, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}})
+	}
+
+	return requests, nil
+}
+
+// saveAssignments writes the assignments of todo, and deletes those of the users in previous it is no
+// longer assigned to
+func (r *ToDoRepo) saveAssignments(todo *internal.ToDo, previous []string) error {
+	requests, err := assignmentRequests(todo)
+	if err != nil {
+		return errors.Wrapf(err, "Could not marshal assignments of ToDo %s", todo.ID)
+	}
+
+	for _, userID := range previous {
+		if !todo.AssignedTo(userID) {
+			requests = append(requests, &dynamodb.WriteRequest{
+				DeleteRequest: &dynamodb.DeleteRequest{Key: mapKey(assignmentsListID(todo.ListID), assignmentID(todo.ID, userID))},
+			})
+		}
+	}
+
+	for start := 0; start < len(requests); start += batchWriteSize {
+		end := start + batchWriteSize
+		if end > len(requests) {
+			end = len(requests)
+		}
+
+		if err := batchWrite(r.db, r.retry, todosTableName, requests[start:end]); err != nil {
+			return errors.Wrapf(err, "Could not save assignments of ToDo %s to database", todo.ID)
+		}
+	}
+
+	return nil
+}
+
+// GetAssignedTo returns the ToDos of every list that are assigned to a user, most recently modified first.
+// Assignments left behind by ToDos that were deleted, or reassigned when their previous assignees were not
+// known, are deleted as they are found.
+func (r *ToDoRepo) GetAssignedTo(userID string) ([]internal.ToDo, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(todosTableName),
+		IndexName:              aws.String(assigneeIndexName),
+		KeyConditionExpression: aws.String("assigneeId = :assigneeId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":assigneeId": {S: aws.String(userID)},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	todos := []internal.ToDo{}
+	stale := []assignmentItem{}
+
+	for {
+		var result *dynamodb.QueryOutput
+
+		err := r.retry.do(func() (err error) {
+			result, err = r.db.Query(input)
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not get ToDos assigned to %s from database", userID)
+		}
+
+		page := []assignmentItem{}
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, errors.Wrap(err, "Could not unmarshal assignments")
+		}
+
+		for _, a := range page {
+			todo, err := r.ForList(a.ToDoListID).Get(a.ToDoID)
+			if err != nil {
+				return nil, err
+			}
+
+			if todo == nil || !todo.AssignedTo(userID) {
+				stale = append(stale, a)
+				continue
+			}
+
+			todos = append(todos, *todo)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	for _, a := range stale {
+		if err := r.deleteAssignment(a); err != nil {
+			return nil, err
+		}
+	}
+
+	return todos, nil
+}
+
+// deleteAssignment deletes an assignment unless it was written again since it was read, as the ToDo may
+// have been assigned to the user again
+func (r *ToDoRepo) deleteAssignment(a assignmentItem) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName:           aws.String(todosTableName),
+		Key:                 mapKey(a.ListID, a.ID),
+		ConditionExpression: aws.String("modTime = :modTime"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":modTime": {S: aws.String(a.ModTime.Format(time.RFC3339Nano))},
+		},
+	}
+
+	err := r.retry.do(func() error {
+		_, err := r.db.DeleteItem(input)
+		return err
+	})
+	if aerr, ok := errors.Cause(err).(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Could not delete assignment %s from database", a.ID)
+	}
+
+	return nil
+}
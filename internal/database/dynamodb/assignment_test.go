@@ -0,0 +1,144 @@
+package dynamodb_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+)
+
+func TestAssignments(t *testing.T) {
+	t.Run("SaveAssignments", testSaveAssignments)
+	t.Run("GetAssignedToRemovesStale", testGetAssignedToRemovesStale)
+}
+
+// assignmentTableMock returns a ClientMock that keeps items in memory, returns the item replaced by PutItem
+// and queries the assignee index
+func assignmentTableMock() (*ClientMock, map[string]map[string]*awsdynamodb.AttributeValue) {
+	m, items := connectionTableMock()
+
+	key := func(k map[string]*awsdynamodb.AttributeValue) string {
+		return *k["listId"].S + "/" + *k["id"].S
+	}
+
+	m.PutItemFn = func(input *awsdynamodb.PutItemInput) (*awsdynamodb.PutItemOutput, error) {
+		old := items[key(input.Item)]
+		items[key(input.Item)] = input.Item
+		return &awsdynamodb.PutItemOutput{Attributes: old}, nil
+	}
+
+	m.DeleteItemFn = func(input *awsdynamodb.DeleteItemInput) (*awsdynamodb.DeleteItemOutput, error) {
+		delete(items, key(input.Key))
+		return &awsdynamodb.DeleteItemOutput{}, nil
+	}
+
+	m.BatchWriteItemFn = func(input *awsdynamodb.BatchWriteItemInput) (*awsdynamodb.BatchWriteItemOutput, error) {
+		for _, requests := range input.RequestItems {
+			for _, r := range requests {
+				if r.PutRequest != nil {
+					items[key(r.PutRequest.Item)] = r.PutRequest.Item
+				}
+				if r.DeleteRequest != nil {
+					delete(items, key(r.DeleteRequest.Key))
+				}
+			}
+		}
+		return &awsdynamodb.BatchWriteItemOutput{}, nil
+	}
+
+	query := m.QueryFn
+	m.QueryFn = func(input *awsdynamodb.QueryInput) (*awsdynamodb.QueryOutput, error) {
+		if aws.StringValue(input.IndexName) != "assignee-index" {
+			return query(input)
+		}
+
+		out := &awsdynamodb.QueryOutput{}
+		for _, item := range items {
+			if a, ok := item["assigneeId"]; ok && *a.S == *input.ExpressionAttributeValues[":assigneeId"].S {
+				out.Items = append(out.Items, item)
+			}
+		}
+		return out, nil
+	}
+
+	return m, items
+}
+
+// assignments returns the number of assignment items
+func assignments(items map[string]map[string]*awsdynamodb.AttributeValue) int {
+	n := 0
+	for _, item := range items {
+		if _, ok := item["assigneeId"]; ok {
+			n++
+		}
+	}
+	return n
+}
+
+func testSaveAssignments(t *testing.T) {
+
+	m, items := assignmentTableMock()
+	repo := dynamodb.NewToDoRepo(m).ForList("work")
+
+	todo := &internal.ToDo{ID: "1", Title: "Write report", AssigneeIDs: []string{"user-1", "user-2"}}
+
+	if err := repo.Save(todo); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := assignments(items); n != 2 {
+		t.Fatalf("Expected 2 assignments, got %d", n)
+	}
+
+	todo.AssigneeIDs = []string{"user-2"}
+
+	if err := repo.Save(todo); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := assignments(items); n != 1 {
+		t.Fatalf("Expected the assignment of user-1 to be deleted, got %d assignments", n)
+	}
+
+	got, err := dynamodb.NewToDoRepo(m).GetAssignedTo("user-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 || got[0].ID != "1" || got[0].ListID != "work" {
+		t.Fatalf("Unexpected ToDos %+v", got)
+	}
+
+	if got, _ := dynamodb.NewToDoRepo(m).GetAssignedTo("user-1"); len(got) != 0 {
+		t.Fatalf("Expected no ToDos assigned to user-1, got %+v", got)
+	}
+}
+
+func testGetAssignedToRemovesStale(t *testing.T) {
+
+	m, items := assignmentTableMock()
+	repo := dynamodb.NewToDoRepo(m).ForList("work")
+
+	if err := repo.Save(&internal.ToDo{ID: "1", AssigneeIDs: []string{"user-1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Delete("1"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.GetAssignedTo("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 0 {
+		t.Fatalf("Expected no ToDos, got %+v", got)
+	}
+
+	if n := assignments(items); n != 0 {
+		t.Fatalf("Expected the assignment of the deleted ToDo to be removed, got %d assignments", n)
+	}
+}
@@ -0,0 +1,269 @@
+package dynamodb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+const (
+	// checklistsPrefix starts the partition keys of the parts of checklists that are too large to be kept
+	// in the item of their ToDo, which are followed by the list ID
+	checklistsPrefix = reservedPrefix + "checklist#"
+	// checklistPartBytes is the most checklist data kept in a single item, leaving room below the 400KB
+	// item size limit for the other attributes
+	checklistPartBytes = 256 * 1024
+	// maxItemBytes is the size of a ToDo item above which its checklist is split out of it. It leaves a
+	// margin below the 400KB item size limit, as itemSize only estimates how DynamoDB measures an item.
+	maxItemBytes = 384 * 1024
+	// maxChecklistParts is the most parts a checklist can be split into. The ToDo and its parts are written
+	// in a single transaction, which is limited to 25 items and 4MB.
+	maxChecklistParts = 12
+	// checklistPartsAttribute is the attribute of a ToDo item holding the number of parts its checklist
+	// was split into
+	checklistPartsAttribute = "checklistParts"
+)
+
+// ErrChecklistTooLarge is returned when a checklist is too large to be stored
+var ErrChecklistTooLarge = errors.New("checklist is too large")
+
+// checklistPartItem is how part of a checklist is stored. Parts are numbered from zero.
+type checklistPartItem struct {
+	ListID string                   `json:"listId"`
+	ID     string                   `json:"id"`
+	Items  []internal.ChecklistItem `json:"items"`
+}
+
+// checklistsListID returns the partition key of the parts of the checklists of ToDos in a list
+func checklistsListID(listID string) string {
+	return checklistsPrefix + listID
+}
+
+// checklistPartID returns the sort key of the nth part of the checklist of a ToDo. Parts sort in order.
+func checklistPartID(todoID string, n int) string {
+	return fmt.Sprintf("%s#%03d", todoID, n)
+}
+
+// checklistParts returns the number of parts the checklist of a ToDo item was split into
+func checklistParts(item map[string]*dynamodb.AttributeValue) int {
+	if av, ok := item[checklistPartsAttribute]; ok && av.N != nil {
+		n, _ := strconv.Atoi(*av.N)
+		return n
+	}
+	return 0
+}
+
+// splitChecklist moves the checklist of todo out of its item if the item is too large with it, and returns
+// the items of the parts it was split into. It returns nil if the item, with its notes, clocks, sets and
+// other attributes, is small enough to keep the checklist.
+func splitChecklist(todo *internal.ToDo, item map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, error) {
+	if itemSize(item) <= maxItemBytes {
+		return nil, nil
+	}
+
+	parts := [][]internal.ChecklistItem{}
+	size := 0
+
+	for _, c := range todo.Checklist {
+		b, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(parts) == 0 || size+len(b) > checklistPartBytes {
+			parts = append(parts, []internal.ChecklistItem{})
+			size = 0
+		}
+
+		parts[len(parts)-1] = append(parts[len(parts)-1], c)
+		size += len(b)
+	}
+
+	if len(parts) == 0 {
+		return nil, nil
+	}
+
+	if len(parts) > maxChecklistParts {
+		return nil, ErrChecklistTooLarge
+	}
+
+	items := []map[string]*dynamodb.AttributeValue{}
+
+	for i, p := range parts {
+		part, err := dynamodbattribute.MarshalMap(checklistPartItem{
+			ListID: checklistsListID(todo.ListID),
+			ID:     checklistPartID(todo.ID, i),
+			Items:  p,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, part)
+	}
+
+	delete(item, "checklist")
+	item[checklistPartsAttribute] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(len(parts)))}
+
+	return items, nil
+}
+
+// itemSize estimates the size of an item as DynamoDB measures it: the lengths of its attribute names and
+// the sizes of their values
+func itemSize(item map[string]*dynamodb.AttributeValue) int {
+	size := 0
+	for name, av := range item {
+		size += len(name) + attributeSize(av)
+	}
+	return size
+}
+
+// attributeSize estimates the size of an attribute value. Lists and maps take 3 bytes, and 1 more for each
+// of their elements.
+func attributeSize(av *dynamodb.AttributeValue) int {
+	size := len(aws.StringValue(av.S)) + len(aws.StringValue(av.N)) + len(av.B)
+
+	if av.BOOL != nil || av.NULL != nil {
+		size++
+	}
+
+	for _, s := range av.SS {
+		size += len(aws.StringValue(s))
+	}
+
+	for _, n := range av.NS {
+		size += len(aws.StringValue(n))
+	}
+
+	for _, b := range av.BS {
+		size += len(b)
+	}
+
+	if av.L != nil || av.M != nil {
+		size += 3 + len(av.L) + len(av.M)
+	}
+
+	for _, e := range av.L {
+		size += attributeSize(e)
+	}
+
+	size += itemSize(av.M)
+
+	return size
+}
+
+// checklistPartsQuery returns the input to query the parts of the checklist of a ToDo
+func (r *ToDoRepo) checklistPartsQuery(todoID string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName:              aws.String(todosTableName),
+		KeyConditionExpression: aws.String("listId = :listId AND begins_with(id, :prefix)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":listId": {S: aws.String(checklistsListID(r.listID))},
+			":prefix": {S: aws.String(todoID + "#")},
+		},
+	}
+}
+
+// loadChecklist sets the checklist of a ToDo that was split into parts
+func (r *ToDoRepo) loadChecklist(todo *internal.ToDo, parts int) error {
+	input := r.checklistPartsQuery(todo.ID)
+	checklist := []internal.ChecklistItem{}
+	n := 0
+
+	for {
+		var result *dynamodb.QueryOutput
+
+		err := r.retry.do(func() (err error) {
+			result, err = r.db.Query(input)
+			return err
+		})
+		if err != nil {
+			return errors.Wrapf(err, "Could not get checklist of ToDo %s from database", todo.ID)
+		}
+
+		page := []checklistPartItem{}
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return errors.Wrapf(err, "Could not unmarshal checklist of ToDo %s", todo.ID)
+		}
+
+		// Parts beyond the number the ToDo was saved with are left over from a larger checklist
+		for _, p := range page {
+			if p.ID == checklistPartID(todo.ID, n) && n < parts {
+				checklist = append(checklist, p.Items...)
+				n++
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	if n != parts {
+		return errors.Errorf("Checklist of ToDo %s has %d of %d parts", todo.ID, n, parts)
+	}
+
+	todo.Checklist = checklist
+
+	return nil
+}
+
+// deleteChecklistParts deletes the parts of the checklist of a ToDo from the nth part on
+func (r *ToDoRepo) deleteChecklistParts(todoID string, from int) error {
+	input := r.checklistPartsQuery(todoID)
+	input.ProjectionExpression = aws.String("listId, id")
+
+	requests := []*dynamodb.WriteRequest{}
+
+	for {
+		var result *dynamodb.QueryOutput
+
+		err := r.retry.do(func() (err error) {
+			result, err = r.db.Query(input)
+			return err
+		})
+		if err != nil {
+			return errors.Wrapf(err, "Could not get checklist of ToDo %s from database", todoID)
+		}
+
+		for _, item := range result.Items {
+			id := aws.StringValue(item["id"].S)
+
+			n, err := strconv.Atoi(strings.TrimPrefix(id, todoID+"#"))
+			if err == nil && n >= from {
+				requests = append(requests, &dynamodb.WriteRequest{
+					DeleteRequest: &dynamodb.DeleteRequest{Key: mapKey(checklistsListID(r.listID), id)},
+				})
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	for start := 0; start < len(requests); start += batchWriteSize {
+		end := start + batchWriteSize
+		if end > len(requests) {
+			end = len(requests)
+		}
+
+		if err := batchWrite(r.db, r.retry, todosTableName, requests[start:end]); err != nil {
+			return errors.Wrapf(err, "Could not delete checklist of ToDo %s from database", todoID)
+		}
+	}
+
+	return nil
+}
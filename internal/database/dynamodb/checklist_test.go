@@ -0,0 +1,236 @@
+package dynamodb_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+)
+
+func TestChecklist(t *testing.T) {
+	t.Run("SmallChecklistIsNotSplit", testSmallChecklistIsNotSplit)
+	t.Run("LargeChecklistIsSplit", testLargeChecklistIsSplit)
+	t.Run("ChecklistOfLargeItemIsSplit", testChecklistOfLargeItemIsSplit)
+}
+
+// checklistTableMock returns a ClientMock that keeps items in memory, supporting the requests used to split
+// checklists across items
+func checklistTableMock() (*ClientMock, map[string]map[string]*awsdynamodb.AttributeValue) {
+	m, items := connectionTableMock()
+
+	key := func(k map[string]*awsdynamodb.AttributeValue) string {
+		return *k["listId"].S + "/" + *k["id"].S
+	}
+
+	m.PutItemFn = func(input *awsdynamodb.PutItemInput) (*awsdynamodb.PutItemOutput, error) {
+		old := items[key(input.Item)]
+		items[key(input.Item)] = input.Item
+		return &awsdynamodb.PutItemOutput{Attributes: old}, nil
+	}
+
+	m.BatchWriteItemFn = func(input *awsdynamodb.BatchWriteItemInput) (*awsdynamodb.BatchWriteItemOutput, error) {
+		for _, requests := range input.RequestItems {
+			for _, r := range requests {
+				if r.DeleteRequest != nil {
+					delete(items, key(r.DeleteRequest.Key))
+				}
+				if r.PutRequest != nil {
+					items[key(r.PutRequest.Item)] = r.PutRequest.Item
+				}
+			}
+		}
+		return &awsdynamodb.BatchWriteItemOutput{}, nil
+	}
+
+	query := m.QueryFn
+	m.QueryFn = func(input *awsdynamodb.QueryInput) (*awsdynamodb.QueryOutput, error) {
+		out, err := query(input)
+		if prefix, ok := input.ExpressionAttributeValues[":prefix"]; ok {
+			matched := []map[string]*awsdynamodb.AttributeValue{}
+			for _, item := range out.Items {
+				if strings.HasPrefix(*item["id"].S, *prefix.S) {
+					matched = append(matched, item)
+				}
+			}
+			// Parts are returned in sort key order
+			sort.Slice(matched, func(i, j int) bool { return *matched[i]["id"].S < *matched[j]["id"].S })
+			out.Items = matched
+		}
+		return out, err
+	}
+
+	return m, items
+}
+
+// checklist returns a checklist of n items with titles of size bytes
+func checklist(n, size int) []internal.ChecklistItem {
+	items := []internal.ChecklistItem{}
+	for i := 0; i < n; i++ {
+		items = append(items, internal.ChecklistItem{
+			ID:      fmt.Sprintf("item-%d", i),
+			Title:   strings.Repeat("x", size),
+			Checked: i%2 == 0,
+		})
+	}
+	return items
+}
+
+// countParts returns the number of checklist parts stored
+func countParts(items map[string]map[string]*awsdynamodb.AttributeValue) int {
+	n := 0
+	for k := range items {
+		if strings.HasPrefix(k, "_checklist#") {
+			n++
+		}
+	}
+	return n
+}
+
+func testSmallChecklistIsNotSplit(t *testing.T) {
+
+	m, items := checklistTableMock()
+	repo := dynamodb.NewToDoRepo(m)
+
+	todo := &internal.ToDo{ID: testUUID, Title: "Release", Checklist: checklist(10, 20)}
+
+	if err := repo.Save(todo); err != nil {
+		t.Fatal(err)
+	}
+
+	if countParts(items) != 0 {
+		t.Fatalf("Expected no parts, got %d", countParts(items))
+	}
+
+	got, err := repo.Get(testUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Checklist) != 10 {
+		t.Fatalf("Expected 10 checklist items, got %d", len(got.Checklist))
+	}
+}
+
+func testLargeChecklistIsSplit(t *testing.T) {
+
+	m, items := checklistTableMock()
+	repo := dynamodb.NewToDoRepo(m)
+
+	todo := &internal.ToDo{ID: testUUID, Title: "Release", Checklist: checklist(600, 1000)}
+
+	if err := repo.Save(todo); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := countParts(items)
+	if parts < 2 {
+		t.Fatalf("Expected checklist to be split, got %d parts", parts)
+	}
+
+	item := items["default/"+testUUID]
+	if item["checklist"] != nil || item["checklistParts"] == nil || *item["checklistParts"].N != fmt.Sprint(parts) {
+		t.Fatalf("Expected ToDo item to hold the number of parts instead of the checklist, got %v", item["checklistParts"])
+	}
+
+	for k, v := range items {
+		size := 0
+		for name, av := range v {
+			size += len(name)
+			if av.S != nil {
+				size += len(*av.S)
+			}
+			for _, e := range av.L {
+				for _, f := range e.M {
+					if f.S != nil {
+						size += len(*f.S)
+					}
+				}
+			}
+		}
+		if size > 400*1024 {
+			t.Fatalf("Expected item %s to be smaller than 400KB, got %d bytes", k, size)
+		}
+	}
+
+	got, err := repo.Get(testUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Checklist) != 600 || got.Checklist[599].ID != "item-599" || !got.Checklist[0].Checked {
+		t.Fatalf("Expected the whole checklist in order, got %d items", len(got.Checklist))
+	}
+
+	all, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 1 || len(all[0].Checklist) != 600 {
+		t.Fatal("Expected GetAll to return the whole checklist")
+	}
+
+	// Shrinking the checklist removes the parts
+	got.Checklist = got.Checklist[:5]
+
+	if err := repo.Save(got); err != nil {
+		t.Fatal(err)
+	}
+
+	if countParts(items) != 0 {
+		t.Fatalf("Expected parts to be deleted, got %d", countParts(items))
+	}
+
+	todo.Checklist = checklist(600, 1000)
+
+	if err := repo.Save(todo); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Delete(testUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if countParts(items) != 0 {
+		t.Fatalf("Expected parts to be deleted with the ToDo, got %d", countParts(items))
+	}
+}
+
+func testChecklistOfLargeItemIsSplit(t *testing.T) {
+
+	m, items := checklistTableMock()
+	repo := dynamodb.NewToDoRepo(m)
+
+	// The checklist is smaller than a part, but not small enough to be kept with the notes
+	todo := &internal.ToDo{
+		ID:        testUUID,
+		Title:     "Release",
+		Notes:     strings.Repeat("n", 200*1024),
+		Checklist: checklist(200, 1000),
+	}
+
+	if err := repo.Save(todo); err != nil {
+		t.Fatal(err)
+	}
+
+	if countParts(items) != 1 {
+		t.Fatalf("Expected the checklist to be moved to 1 part, got %d", countParts(items))
+	}
+
+	if items["default/"+testUUID]["checklist"] != nil {
+		t.Fatal("Expected the ToDo item not to hold the checklist")
+	}
+
+	got, err := repo.Get(testUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Checklist) != 200 || len(got.Notes) != 200*1024 {
+		t.Fatalf("Expected the whole ToDo, got %d checklist items", len(got.Checklist))
+	}
+}
@@ -8,14 +8,20 @@ import (
 // ClientMock is used to mock a client that uses makes call to DynamoDBAPI
 type ClientMock struct {
 	dynamodbiface.DynamoDBAPI
-	GetItemFn         func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
-	ScanFn            func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
-	PutItemFn         func(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
-	DeleteItemFn      func(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
-	GetItemInvoked    bool
-	ScanInvoked       bool
-	PutItemInvoked    bool
-	DeleteItemInvoked bool
+	GetItemFn             func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	ScanFn                func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+	QueryFn               func(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	PutItemFn             func(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	DeleteItemFn          func(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+	BatchWriteItemFn      func(*dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+	TransactWriteItemsFn  func(*dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error)
+	GetItemInvoked        bool
+	ScanInvoked           bool
+	QueryInvoked          bool
+	PutItemInvoked        bool
+	DeleteItemInvoked     bool
+	BatchWriteItemInvoked bool
+	TransactWriteInvoked  bool
 }
 
 // GetItem returns a set of attributes for the item with the given primary key
@@ -30,6 +36,12 @@ func (m *ClientMock) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, erro
 	return m.ScanFn(input)
 }
 
+// Query finds items based on primary key values
+func (m *ClientMock) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	m.QueryInvoked = true
+	return m.QueryFn(input)
+}
+
 // PutItem creates a new item, or replaces an old item with a new item
 func (m *ClientMock) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
 	m.PutItemInvoked = true
@@ -40,5 +52,16 @@ func (m *ClientMock) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOut
 func (m *ClientMock) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
 	m.DeleteItemInvoked = true
 	return m.DeleteItemFn(input)
+}
+
+// BatchWriteItem puts or deletes multiple items in one or more tables
+func (m *ClientMock) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	m.BatchWriteItemInvoked = true
+	return m.BatchWriteItemFn(input)
+}
 
+// TransactWriteItems puts, updates or deletes multiple items in a single all-or-nothing operation
+func (m *ClientMock) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	m.TransactWriteInvoked = true
+	return m.TransactWriteItemsFn(input)
 }
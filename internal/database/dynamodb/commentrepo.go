@@ -0,0 +1,200 @@
+package dynamodb
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+// commentsPrefix starts the partition keys of the comments on the ToDos of a list, which are followed by
+// the list ID. Comments are sorted by the ID of their ToDo, so the comments on a ToDo are read with a
+// single query.
+const commentsPrefix = reservedPrefix + "comments#"
+
+// commentItem is how a Comment is stored in the partition of its list
+type commentItem struct {
+	ListID        string     `json:"listId"`
+	ID            string     `json:"id"`
+	CommentID     string     `json:"commentId"`
+	CommentListID string     `json:"commentListId"`
+	ToDoID        string     `json:"todoId"`
+	AuthorID      string     `json:"authorId"`
+	Body          string     `json:"body"`
+	Mentions      []string   `json:"mentions,omitempty"`
+	Created       time.Time  `json:"created"`
+	Edited        *time.Time `json:"edited,omitempty"`
+}
+
+// comment returns the Comment stored in item
+func (item commentItem) comment() internal.Comment {
+	return internal.Comment{
+		ID:       item.CommentID,
+		ListID:   item.CommentListID,
+		ToDoID:   item.ToDoID,
+		AuthorID: item.AuthorID,
+		Body:     item.Body,
+		Mentions: item.Mentions,
+		Created:  item.Created,
+		Edited:   item.Edited,
+	}
+}
+
+// commentKey returns the key of a comment
+func commentKey(listID, todoID, id string) map[string]*dynamodb.AttributeValue {
+	return mapKey(commentsPrefix+listID, todoID+"#"+id)
+}
+
+// CommentRepo represents a DynamoDB repository for managing the comments on ToDos
+type CommentRepo struct {
+	db    dynamodbiface.DynamoDBAPI
+	retry RetryPolicy
+}
+
+// NewCommentRepo returns a new Comment repository using the given DynamoDB client
+func NewCommentRepo(db dynamodbiface.DynamoDBAPI) *CommentRepo {
+	return &CommentRepo{db: db, retry: DefaultRetryPolicy}
+}
+
+// Get returns a comment on a ToDo by its ID
+func (r *CommentRepo) Get(listID, todoID, id string) (*internal.Comment, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(todosTableName),
+		Key:       commentKey(listID, todoID, id),
+	}
+
+	var result *dynamodb.GetItemOutput
+
+	err := r.retry.do(func() (err error) {
+		result, err = r.db.GetItem(input)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not get comment %s from database", id)
+	}
+
+	item := commentItem{}
+
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, errors.Wrapf(err, "Could not unmarshal comment %s", id)
+	}
+
+	if item.CommentID == "" {
+		return nil, nil
+	}
+
+	c := item.comment()
+
+	return &c, nil
+}
+
+// GetByToDo returns the comments on a ToDo, oldest first
+func (r *CommentRepo) GetByToDo(listID, todoID string) ([]internal.Comment, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(todosTableName),
+		KeyConditionExpression: aws.String("listId = :listId AND begins_with(id, :prefix)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":listId": {S: aws.String(commentsPrefix + listID)},
+			":prefix": {S: aws.String(todoID + "#")},
+		},
+	}
+
+	comments := []internal.Comment{}
+
+	for {
+		var result *dynamodb.QueryOutput
+
+		err := r.retry.do(func() (err error) {
+			result, err = r.db.Query(input)
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not get comments on ToDo %s from database", todoID)
+		}
+
+		page := []commentItem{}
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, errors.Wrap(err, "Could not unmarshal comments")
+		}
+
+		for _, item := range page {
+			comments = append(comments, item.comment())
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].Created.Before(comments[j].Created)
+	})
+
+	return comments, nil
+}
+
+// Save creates or updates a comment
+func (r *CommentRepo) Save(comment *internal.Comment) error {
+	if comment.Created.IsZero() {
+		comment.Created = time.Now().UTC()
+	}
+
+	key := commentKey(comment.ListID, comment.ToDoID, comment.ID)
+
+	item, err := dynamodbattribute.MarshalMap(commentItem{
+		ListID:        *key["listId"].S,
+		ID:            *key["id"].S,
+		CommentID:     comment.ID,
+		CommentListID: comment.ListID,
+		ToDoID:        comment.ToDoID,
+		AuthorID:      comment.AuthorID,
+		Body:          comment.Body,
+		Mentions:      comment.Mentions,
+		Created:       comment.Created,
+		Edited:        comment.Edited,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not marshal comment %s", comment.ID)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(todosTableName),
+		Item:      item,
+	}
+
+	err = r.retry.do(func() error {
+		_, err := r.db.PutItem(input)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not save comment %s to database", comment.ID)
+	}
+
+	return nil
+}
+
+// Delete removes a comment. Deleting a comment that does not exist is not an error.
+func (r *CommentRepo) Delete(listID, todoID, id string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(todosTableName),
+		Key:       commentKey(listID, todoID, id),
+	}
+
+	err := r.retry.do(func() error {
+		_, err := r.db.DeleteItem(input)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not delete comment %s from database", id)
+	}
+
+	return nil
+}
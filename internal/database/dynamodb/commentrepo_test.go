@@ -0,0 +1,119 @@
+package dynamodb_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+)
+
+func TestCommentRepo(t *testing.T) {
+	t.Run("SaveGetAndDeleteComment", testSaveGetAndDeleteComment)
+	t.Run("GetCommentsByToDo", testGetCommentsByToDo)
+}
+
+// itemTableMock returns a ClientMock that keeps items in memory, including those written with PutItem and
+// removed with DeleteItem, and that queries by key prefix
+func itemTableMock() *ClientMock {
+	m, items := connectionTableMock()
+
+	m.PutItemFn = func(input *awsdynamodb.PutItemInput) (*awsdynamodb.PutItemOutput, error) {
+		items[*input.Item["listId"].S+"/"+*input.Item["id"].S] = input.Item
+		return &awsdynamodb.PutItemOutput{}, nil
+	}
+
+	m.DeleteItemFn = func(input *awsdynamodb.DeleteItemInput) (*awsdynamodb.DeleteItemOutput, error) {
+		delete(items, *input.Key["listId"].S+"/"+*input.Key["id"].S)
+		return &awsdynamodb.DeleteItemOutput{}, nil
+	}
+
+	m.QueryFn = func(input *awsdynamodb.QueryInput) (*awsdynamodb.QueryOutput, error) {
+		prefix := ""
+		if p, ok := input.ExpressionAttributeValues[":prefix"]; ok {
+			prefix = *p.S
+		}
+
+		out := &awsdynamodb.QueryOutput{}
+		for _, item := range items {
+			if *item["listId"].S == *input.ExpressionAttributeValues[":listId"].S && strings.HasPrefix(*item["id"].S, prefix) {
+				out.Items = append(out.Items, item)
+			}
+		}
+		return out, nil
+	}
+
+	return m
+}
+
+func testSaveGetAndDeleteComment(t *testing.T) {
+
+	repo := dynamodb.NewCommentRepo(itemTableMock())
+
+	c := &internal.Comment{
+		ID:       "c1",
+		ListID:   "work",
+		ToDoID:   "1",
+		AuthorID: "user-1",
+		Body:     "@sam have a look",
+		Mentions: []string{"user-2"},
+	}
+
+	if err := repo.Save(c); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Created.IsZero() {
+		t.Fatal("Expected Comment to have a not zero Created")
+	}
+
+	got, err := repo.Get("work", "1", "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got == nil || got.ListID != "work" || got.ToDoID != "1" || got.Body != c.Body || got.Mentions[0] != "user-2" {
+		t.Fatalf("Unexpected Comment %+v", got)
+	}
+
+	if got, _ := repo.Get("home", "1", "c1"); got != nil {
+		t.Fatalf("Expected no Comment in another list, got %+v", got)
+	}
+
+	if err := repo.Delete("work", "1", "c1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := repo.Get("work", "1", "c1"); got != nil {
+		t.Fatal("Expected Comment to be deleted")
+	}
+}
+
+func testGetCommentsByToDo(t *testing.T) {
+
+	repo := dynamodb.NewCommentRepo(itemTableMock())
+
+	now := time.Now().UTC()
+
+	for _, c := range []*internal.Comment{
+		{ID: "b", ListID: "work", ToDoID: "1", Body: "Second", Created: now},
+		{ID: "a", ListID: "work", ToDoID: "1", Body: "First", Created: now.Add(-time.Minute)},
+		{ID: "c", ListID: "work", ToDoID: "10", Body: "Other ToDo"},
+		{ID: "d", ListID: "home", ToDoID: "1", Body: "Other list"},
+	} {
+		if err := repo.Save(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	comments, err := repo.GetByToDo("work", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(comments) != 2 || comments[0].Body != "First" || comments[1].Body != "Second" {
+		t.Fatalf("Expected the comments on ToDo 1 oldest first, got %+v", comments)
+	}
+}
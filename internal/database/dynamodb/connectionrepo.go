@@ -0,0 +1,186 @@
+package dynamodb
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+const (
+	// connectionsListID is the partition key of the items that map a connection ID to its list, as
+	// $disconnect events only carry the connection ID
+	connectionsListID = reservedPrefix + "connections"
+	// connectionsPrefix starts the partition keys of the connections subscribed to a list, which are
+	// followed by the list ID
+	connectionsPrefix = connectionsListID + "#"
+	// connectionLifetime is the longest API Gateway keeps a WebSocket connection open. Connections whose
+	// $disconnect was missed expire after it.
+	connectionLifetime = 2 * time.Hour
+)
+
+// connectionItem is how a Connection is stored in the partition of its list. Expires is the TTL
+// attribute, in Unix seconds.
+type connectionItem struct {
+	ListID     string    `json:"listId"`
+	ID         string    `json:"id"`
+	ConnListID string    `json:"connListId"`
+	UserID     string    `json:"userId,omitempty"`
+	Connected  time.Time `json:"connected"`
+	Expires    int64     `json:"expires"`
+}
+
+// ConnectionRepo represents a DynamoDB repository for managing the WebSocket connections subscribed to
+// lists
+type ConnectionRepo struct {
+	db    dynamodbiface.DynamoDBAPI
+	retry RetryPolicy
+}
+
+// NewConnectionRepo returns a new Connection repository using the given DynamoDB client
+func NewConnectionRepo(db dynamodbiface.DynamoDBAPI) *ConnectionRepo {
+	return &ConnectionRepo{db: db, retry: DefaultRetryPolicy}
+}
+
+// GetByList returns the connections subscribed to a list
+func (r *ConnectionRepo) GetByList(listID string) ([]internal.Connection, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(todosTableName),
+		KeyConditionExpression: aws.String("listId = :listId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":listId": {S: aws.String(connectionsPrefix + listID)},
+		},
+	}
+
+	connections := []internal.Connection{}
+
+	for {
+		var result *dynamodb.QueryOutput
+
+		err := r.retry.do(func() (err error) {
+			result, err = r.db.Query(input)
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not get connections to list %s from database", listID)
+		}
+
+		page := []connectionItem{}
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, errors.Wrap(err, "Could not unmarshal connections")
+		}
+
+		for _, item := range page {
+			// Expired items are removed by TTL some time after they expire
+			if time.Unix(item.Expires, 0).After(time.Now()) {
+				connections = append(connections, internal.Connection{
+					ID:        item.ID,
+					ListID:    item.ConnListID,
+					UserID:    item.UserID,
+					Connected: item.Connected,
+				})
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return connections, nil
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// Save stores a connection
+func (r *ConnectionRepo) Save(connection *internal.Connection) error {
+	if connection.Connected.IsZero() {
+		connection.Connected = time.Now().UTC()
+	}
+
+	item := connectionItem{
+		ListID:     connectionsPrefix + connection.ListID,
+		ID:         connection.ID,
+		ConnListID: connection.ListID,
+		UserID:     connection.UserID,
+		Connected:  connection.Connected,
+		Expires:    connection.Connected.Add(connectionLifetime).Unix(),
+	}
+
+	subscription, err := dynamodbattribute.MarshalMap(item)
+	if err != nil {
+		return errors.Wrapf(err, "Could not marshal connection %s", connection.ID)
+	}
+
+	item.ListID = connectionsListID
+
+	lookup, err := dynamodbattribute.MarshalMap(item)
+	if err != nil {
+		return errors.Wrapf(err, "Could not marshal connection %s", connection.ID)
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{TableName: aws.String(todosTableName), Item: subscription}},
+			{Put: &dynamodb.Put{TableName: aws.String(todosTableName), Item: lookup}},
+		},
+	}
+
+	err = r.retry.do(func() error {
+		_, err := r.db.TransactWriteItems(input)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not save connection %s to database", connection.ID)
+	}
+
+	return nil
+}
+
+// Delete removes a connection. Deleting a connection that does not exist is not an error.
+func (r *ConnectionRepo) Delete(id string) error {
+	getInput := &dynamodb.GetItemInput{
+		TableName: aws.String(todosTableName),
+		Key:       mapKey(connectionsListID, id),
+	}
+
+	var result *dynamodb.GetItemOutput
+
+	err := r.retry.do(func() (err error) {
+		result, err = r.db.GetItem(getInput)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not get connection %s from database", id)
+	}
+
+	item := connectionItem{}
+
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &item); err != nil {
+		return errors.Wrapf(err, "Could not unmarshal connection %s", id)
+	}
+
+	if item.ID == "" {
+		return nil
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Delete: &dynamodb.Delete{TableName: aws.String(todosTableName), Key: mapKey(connectionsPrefix+item.ConnListID, id)}},
+			{Delete: &dynamodb.Delete{TableName: aws.String(todosTableName), Key: mapKey(connectionsListID, id)}},
+		},
+	}
+
+	err = r.retry.do(func() error {
+		_, err := r.db.TransactWriteItems(input)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not delete connection %s from database", id)
+	}
+
+	return nil
+}
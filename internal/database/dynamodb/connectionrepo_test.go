@@ -0,0 +1,121 @@
+package dynamodb_test
+
+import (
+	"testing"
+
+	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+)
+
+func TestConnectionRepo(t *testing.T) {
+	t.Run("SaveGetAndDeleteConnection", testSaveGetAndDeleteConnection)
+	t.Run("DeleteConnectionNotFound", testDeleteConnectionNotFound)
+}
+
+// connectionTableMock returns a ClientMock that keeps items in memory, keyed by listId and id
+func connectionTableMock() (*ClientMock, map[string]map[string]*awsdynamodb.AttributeValue) {
+	items := make(map[string]map[string]*awsdynamodb.AttributeValue)
+
+	key := func(k map[string]*awsdynamodb.AttributeValue) string {
+		return *k["listId"].S + "/" + *k["id"].S
+	}
+
+	m := &ClientMock{}
+
+	m.TransactWriteItemsFn = func(input *awsdynamodb.TransactWriteItemsInput) (*awsdynamodb.TransactWriteItemsOutput, error) {
+		for _, item := range input.TransactItems {
+			if item.Put != nil {
+				items[key(item.Put.Item)] = item.Put.Item
+			}
+			if item.Delete != nil {
+				delete(items, key(item.Delete.Key))
+			}
+		}
+		return &awsdynamodb.TransactWriteItemsOutput{}, nil
+	}
+
+	m.GetItemFn = func(input *awsdynamodb.GetItemInput) (*awsdynamodb.GetItemOutput, error) {
+		return &awsdynamodb.GetItemOutput{Item: items[key(input.Key)]}, nil
+	}
+
+	m.QueryFn = func(input *awsdynamodb.QueryInput) (*awsdynamodb.QueryOutput, error) {
+		out := &awsdynamodb.QueryOutput{}
+		for _, item := range items {
+			if *item["listId"].S == *input.ExpressionAttributeValues[":listId"].S {
+				out.Items = append(out.Items, item)
+			}
+		}
+		return out, nil
+	}
+
+	return m, items
+}
+
+func testSaveGetAndDeleteConnection(t *testing.T) {
+
+	m, items := connectionTableMock()
+
+	repo := dynamodb.NewConnectionRepo(m)
+
+	for _, c := range []*internal.Connection{
+		{ID: "conn-1", ListID: "work", UserID: "user-1"},
+		{ID: "conn-2", ListID: "work"},
+		{ID: "conn-3", ListID: "home"},
+	} {
+		if err := repo.Save(c); err != nil {
+			t.Fatal(err)
+		}
+
+		if c.Connected.IsZero() {
+			t.Fatal("Expected Connection to have a not zero Connected")
+		}
+	}
+
+	connections, err := repo.GetByList("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(connections) != 2 {
+		t.Fatalf("Expected 2 connections to work, got %d", len(connections))
+	}
+
+	for _, c := range connections {
+		if c.ListID != "work" {
+			t.Fatalf("Expected connection to work, got %+v", c)
+		}
+	}
+
+	if err := repo.Delete("conn-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if connections, err = repo.GetByList("work"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(connections) != 1 || connections[0].ID != "conn-2" {
+		t.Fatalf("Expected only conn-2 to be connected to work, got %+v", connections)
+	}
+
+	// Both the subscription and the lookup by ID are removed
+	if len(items) != 4 {
+		t.Fatalf("Expected 4 items, got %d", len(items))
+	}
+}
+
+func testDeleteConnectionNotFound(t *testing.T) {
+
+	m, _ := connectionTableMock()
+
+	repo := dynamodb.NewConnectionRepo(m)
+
+	if err := repo.Delete("missing"); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.TransactWriteInvoked {
+		t.Fatal("TransactWriteItems should not be invoked")
+	}
+}
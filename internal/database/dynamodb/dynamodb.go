@@ -1,15 +1,61 @@
 package dynamodb
 
 import (
+	"strconv"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
-// mapID return a AttributeValue map with id set
-func mapID(id string) map[string]*dynamodb.AttributeValue {
+// batchWriteSize is the maximum number of items DynamoDB accepts in a single BatchWriteItem request
+const batchWriteSize = 25
+
+// mapKey return a AttributeValue map with the list partition key and id sort key set
+func mapKey(listID, id string) map[string]*dynamodb.AttributeValue {
 	return map[string]*dynamodb.AttributeValue{
+		"listId": {
+			S: aws.String(listID),
+		},
 		"id": {
 			S: aws.String(id),
 		},
 	}
 }
+
+// statusKey returns the partition key of the completed index for ToDos in a list
+func statusKey(listID string, completed bool) string {
+	return listID + "#" + strconv.FormatBool(completed)
+}
+
+// sortableFloor formats t for comparison with stored RFC 3339 times. Stored times omit trailing zeros, so
+// they do not sort correctly within a second, and the result is a whole second before t. Callers filter
+// results on the exact time.
+func sortableFloor(t time.Time) string {
+	return t.UTC().Truncate(time.Second).Add(-time.Second).Format(time.RFC3339)
+}
+
+// batchWrite writes requests to table using policy, resubmitting any that DynamoDB leaves unprocessed
+func batchWrite(db dynamodbiface.DynamoDBAPI, policy RetryPolicy, table string, requests []*dynamodb.WriteRequest) error {
+	pending := map[string][]*dynamodb.WriteRequest{table: requests}
+
+	return policy.do(func() error {
+		for len(pending[table]) > 0 {
+			result, err := db.BatchWriteItem(&dynamodb.BatchWriteItemInput{RequestItems: pending})
+			if err != nil {
+				return err
+			}
+
+			// No progress means the table is throttling writes, so back off before resubmitting
+			if len(result.UnprocessedItems[table]) == len(pending[table]) {
+				return awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "No items were processed", nil)
+			}
+
+			pending = result.UnprocessedItems
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,131 @@
+package dynamodb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+// feedTokensListID is the partition key of feed token items
+const feedTokensListID = reservedPrefix + "feedtokens"
+
+// feedTokenItem is how a FeedToken is stored. Only a hash of the token is kept, so the tokens can not be
+// read back from the table.
+type feedTokenItem struct {
+	ListID     string    `json:"listId"`
+	ID         string    `json:"id"`
+	UserID     string    `json:"userId"`
+	FeedListID string    `json:"feedListId"`
+	Created    time.Time `json:"created"`
+}
+
+// FeedTokenRepo represents a DynamoDB repository for managing calendar feed tokens
+type FeedTokenRepo struct {
+	db    dynamodbiface.DynamoDBAPI
+	retry RetryPolicy
+}
+
+// NewFeedTokenRepo returns a new FeedToken repository using the given DynamoDB client
+func NewFeedTokenRepo(db dynamodbiface.DynamoDBAPI) *FeedTokenRepo {
+	return &FeedTokenRepo{db: db, retry: DefaultRetryPolicy}
+}
+
+// Get returns a FeedToken by its token
+func (r *FeedTokenRepo) Get(token string) (*internal.FeedToken, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(todosTableName),
+		Key:       mapKey(feedTokensListID, hashToken(token)),
+	}
+
+	var result *dynamodb.GetItemOutput
+
+	err := r.retry.do(func() (err error) {
+		result, err = r.db.GetItem(input)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get FeedToken from database")
+	}
+
+	item := &feedTokenItem{}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, item)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not unmarshal FeedToken")
+	}
+
+	if item.ID == "" {
+		return nil, nil
+	}
+
+	return &internal.FeedToken{
+		Token:   token,
+		UserID:  item.UserID,
+		ListID:  item.FeedListID,
+		Created: item.Created,
+	}, nil
+}
+
+// Save creates or updates a FeedToken
+func (r *FeedTokenRepo) Save(token *internal.FeedToken) error {
+	if token.Created.IsZero() {
+		token.Created = time.Now().UTC()
+	}
+
+	item, err := dynamodbattribute.MarshalMap(feedTokenItem{
+		ListID:     feedTokensListID,
+		ID:         hashToken(token.Token),
+		UserID:     token.UserID,
+		FeedListID: token.ListID,
+		Created:    token.Created,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Could not marshal FeedToken")
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(todosTableName),
+		Item:      item,
+	}
+
+	err = r.retry.do(func() error {
+		_, err := r.db.PutItem(input)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "Could not save FeedToken to database")
+	}
+
+	return nil
+}
+
+// Delete permanently removes a FeedToken
+func (r *FeedTokenRepo) Delete(token string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(todosTableName),
+		Key:       mapKey(feedTokensListID, hashToken(token)),
+	}
+
+	err := r.retry.do(func() error {
+		_, err := r.db.DeleteItem(input)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "Could not delete FeedToken from database")
+	}
+
+	return nil
+}
+
+// hashToken returns the hex encoded SHA-256 hash of token
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,78 @@
+package dynamodb_test
+
+import (
+	"testing"
+
+	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+)
+
+func TestFeedTokenRepo(t *testing.T) {
+	t.Run("SaveAndGetFeedToken", testSaveAndGetFeedToken)
+	t.Run("GetFeedTokenNotFound", testGetFeedTokenNotFound)
+}
+
+func testSaveAndGetFeedToken(t *testing.T) {
+
+	var stored map[string]*awsdynamodb.AttributeValue
+
+	m := &ClientMock{}
+
+	m.PutItemFn = func(input *awsdynamodb.PutItemInput) (*awsdynamodb.PutItemOutput, error) {
+		stored = input.Item
+		return &awsdynamodb.PutItemOutput{}, nil
+	}
+
+	m.GetItemFn = func(input *awsdynamodb.GetItemInput) (*awsdynamodb.GetItemOutput, error) {
+		if *input.Key["id"].S != *stored["id"].S {
+			return &awsdynamodb.GetItemOutput{}, nil
+		}
+		return &awsdynamodb.GetItemOutput{Item: stored}, nil
+	}
+
+	repo := dynamodb.NewFeedTokenRepo(m)
+
+	token := &internal.FeedToken{Token: "secret", UserID: "user-1", ListID: "work"}
+
+	if err := repo.Save(token); err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range stored {
+		if v.S != nil && *v.S == "secret" {
+			t.Fatalf("Expected token not to be stored in plain text, found it in %s", k)
+		}
+	}
+
+	if token.Created.IsZero() {
+		t.Fatal("Expected FeedToken to have a not zero Created")
+	}
+
+	got, err := repo.Get("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got == nil || got.UserID != "user-1" || got.ListID != "work" || got.Token != "secret" {
+		t.Fatalf("Unexpected FeedToken %+v", got)
+	}
+}
+
+func testGetFeedTokenNotFound(t *testing.T) {
+
+	m := &ClientMock{}
+
+	m.GetItemFn = func(*awsdynamodb.GetItemInput) (*awsdynamodb.GetItemOutput, error) {
+		return &awsdynamodb.GetItemOutput{}, nil
+	}
+
+	got, err := dynamodb.NewFeedTokenRepo(m).Get("guess")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != nil {
+		t.Fatal("Expected FeedToken to be nil")
+	}
+}
@@ -0,0 +1,145 @@
+package dynamodb
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+// membersPrefix starts the partition keys of the members of a list, which are followed by the list ID.
+// Members are sorted by user ID.
+const membersPrefix = reservedPrefix + "members#"
+
+// memberItem is how a Member is stored in the partition of its list
+type memberItem struct {
+	ListID       string    `json:"listId"`
+	ID           string    `json:"id"`
+	MemberListID string    `json:"memberListId"`
+	Handle       string    `json:"handle"`
+	Added        time.Time `json:"added"`
+}
+
+// member returns the Member stored in item
+func (item memberItem) member() internal.Member {
+	return internal.Member{
+		ListID: item.MemberListID,
+		UserID: item.ID,
+		Handle: item.Handle,
+		Added:  item.Added,
+	}
+}
+
+// MemberRepo represents a DynamoDB repository for managing the members of lists
+type MemberRepo struct {
+	db    dynamodbiface.DynamoDBAPI
+	retry RetryPolicy
+}
+
+// NewMemberRepo returns a new Member repository using the given DynamoDB client
+func NewMemberRepo(db dynamodbiface.DynamoDBAPI) *MemberRepo {
+	return &MemberRepo{db: db, retry: DefaultRetryPolicy}
+}
+
+// GetByList returns the members of a list, ordered by handle
+func (r *MemberRepo) GetByList(listID string) ([]internal.Member, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(todosTableName),
+		KeyConditionExpression: aws.String("listId = :listId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":listId": {S: aws.String(membersPrefix + listID)},
+		},
+	}
+
+	members := []internal.Member{}
+
+	for {
+		var result *dynamodb.QueryOutput
+
+		err := r.retry.do(func() (err error) {
+			result, err = r.db.Query(input)
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not get members of list %s from database", listID)
+		}
+
+		page := []memberItem{}
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, errors.Wrap(err, "Could not unmarshal members")
+		}
+
+		for _, item := range page {
+			members = append(members, item.member())
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].Handle < members[j].Handle
+	})
+
+	return members, nil
+}
+
+// Save adds a member to a list or changes their handle
+func (r *MemberRepo) Save(member *internal.Member) error {
+	if member.Added.IsZero() {
+		member.Added = time.Now().UTC()
+	}
+
+	item, err := dynamodbattribute.MarshalMap(memberItem{
+		ListID:       membersPrefix + member.ListID,
+		ID:           member.UserID,
+		MemberListID: member.ListID,
+		Handle:       member.Handle,
+		Added:        member.Added,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not marshal member %s", member.UserID)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(todosTableName),
+		Item:      item,
+	}
+
+	err = r.retry.do(func() error {
+		_, err := r.db.PutItem(input)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not save member %s of list %s to database", member.UserID, member.ListID)
+	}
+
+	return nil
+}
+
+// Delete removes a member from a list. Removing a user who is not a member is not an error.
+func (r *MemberRepo) Delete(listID, userID string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(todosTableName),
+		Key:       mapKey(membersPrefix+listID, userID),
+	}
+
+	err := r.retry.do(func() error {
+		_, err := r.db.DeleteItem(input)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not delete member %s of list %s from database", userID, listID)
+	}
+
+	return nil
+}
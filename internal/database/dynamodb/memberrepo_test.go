@@ -0,0 +1,52 @@
+package dynamodb_test
+
+import (
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+)
+
+func TestMemberRepo(t *testing.T) {
+	t.Run("SaveGetAndDeleteMembers", testSaveGetAndDeleteMembers)
+}
+
+func testSaveGetAndDeleteMembers(t *testing.T) {
+
+	repo := dynamodb.NewMemberRepo(itemTableMock())
+
+	for _, m := range []*internal.Member{
+		{ListID: "work", UserID: "user-1", Handle: "sam"},
+		{ListID: "work", UserID: "user-2", Handle: "alex"},
+		{ListID: "home", UserID: "user-1", Handle: "sam"},
+	} {
+		if err := repo.Save(m); err != nil {
+			t.Fatal(err)
+		}
+
+		if m.Added.IsZero() {
+			t.Fatal("Expected Member to have a not zero Added")
+		}
+	}
+
+	members, err := repo.GetByList("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(members) != 2 || members[0].Handle != "alex" || members[1].UserID != "user-1" || members[1].ListID != "work" {
+		t.Fatalf("Expected the members of work ordered by handle, got %+v", members)
+	}
+
+	if err := repo.Delete("work", "user-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if members, _ = repo.GetByList("work"); len(members) != 1 || members[0].UserID != "user-2" {
+		t.Fatalf("Expected user-1 to be removed, got %+v", members)
+	}
+
+	if members, _ = repo.GetByList("home"); len(members) != 1 {
+		t.Fatalf("Expected the members of other lists to be kept, got %+v", members)
+	}
+}
@@ -0,0 +1,105 @@
+package dynamodb
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+// Migrations are the migrations applied by cmd/todo-migrate. Versions must never be reused or reordered
+// once released; add new migrations to the end.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Copy ToDos from the legacy todos table into the default list",
+		Source:      legacyTodosTableName,
+		Target:      todosTableName,
+		Transform: func(item Item) (Item, error) {
+			return transformToDo(item, func(t *internal.ToDo) {
+				t.ListID = DefaultListID
+			})
+		},
+	},
+	{
+		Version:     2,
+		Description: "Store modTime and due in UTC so index sort keys order correctly",
+		Source:      todosTableName,
+		Target:      todosTableName,
+		Transform: func(item Item) (Item, error) {
+			return transformToDo(item, func(t *internal.ToDo) {
+				t.ModTime = t.ModTime.UTC()
+			})
+		},
+	},
+	{
+		Version:     3,
+		Description: "Backfill listStatus for the completed index",
+		Source:      todosTableName,
+		Target:      todosTableName,
+		Transform: func(item Item) (Item, error) {
+			return transformToDo(item, func(*internal.ToDo) {})
+		},
+	},
+}
+
+// transformToDo decodes item, applies fn and encodes the result the way ToDoRepo.Save does, so derived
+// attributes are always present. Attributes that are not part of internal.ToDo are preserved.
+func transformToDo(item Item, fn func(*internal.ToDo)) (Item, error) {
+	t := &internal.ToDo{}
+
+	if err := dynamodbattribute.UnmarshalMap(item, t); err != nil {
+		return nil, errors.Wrap(err, "Could not unmarshal ToDo")
+	}
+
+	if t.ID == "" {
+		return nil, nil
+	}
+
+	fn(t)
+
+	migrated, err := marshalToDo(t)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not marshal ToDo %s", t.ID)
+	}
+
+	for k, v := range item {
+		if _, ok := migrated[k]; !ok {
+			migrated[k] = v
+		}
+	}
+
+	// Keep the original encoding of unchanged times, as RFC3339 strings for the same instant can differ
+	for _, k := range []string{"modTime", "due"} {
+		if sameTime(item[k], migrated[k]) {
+			migrated[k] = item[k]
+		}
+	}
+
+	return migrated, nil
+}
+
+// sameTime reports whether a and b hold the same instant in the same time zone
+func sameTime(a, b *dynamodb.AttributeValue) bool {
+	if a == nil || b == nil {
+		return false
+	}
+
+	ta, err := time.Parse(time.RFC3339Nano, aws.StringValue(a.S))
+	if err != nil {
+		return false
+	}
+
+	tb, err := time.Parse(time.RFC3339Nano, aws.StringValue(b.S))
+	if err != nil {
+		return false
+	}
+
+	_, oa := ta.Zone()
+	_, ob := tb.Zone()
+
+	return ta.Equal(tb) && oa == ob
+}
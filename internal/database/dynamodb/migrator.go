@@ -0,0 +1,556 @@
+package dynamodb
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/pkg/errors"
+)
+
+const (
+	// migrationsListID is the partition key of the item recording which migrations have been applied
+	migrationsListID = reservedPrefix + "migrations"
+	// migrationsID is the sort key of the item recording which migrations have been applied
+	migrationsID = "state"
+)
+
+// Item is a raw DynamoDB item
+type Item = map[string]*dynamodb.AttributeValue
+
+// Migration transforms the items of a table. Transform is called for every item in Source and returns
+// the item to write to Target, or nil to leave it alone. Transforms must be idempotent: an item that has
+// already been migrated must be returned unchanged, as migrations are resumed by re-reading the last
+// page that was being processed.
+type Migration struct {
+	Version     int
+	Description string
+	Source      string
+	Target      string
+	Transform   func(Item) (Item, error)
+}
+
+// MigrationResult summarizes the items processed by a Migration
+type MigrationResult struct {
+	Version     int
+	Description string
+	Scanned     int
+	Changed     int
+	Unchanged   int
+	Skipped     int
+	// Diff counts the attributes that were added (+name), changed (~name) or removed (-name)
+	Diff map[string]int
+}
+
+// Migrator applies Migrations in version order, recording applied versions in a metadata item in the
+// ToDos table. Each migration scans its source in Segments parallel segments and checkpoints progress
+// after every page, so an interrupted run resumes where it left off.
+type Migrator struct {
+	db         dynamodbiface.DynamoDBAPI
+	migrations []Migration
+	// Segments is the number of parallel scan segments used for a migration that is not yet in progress
+	Segments int
+	// DryRun reports what would change without writing any items or metadata. The items each migration
+	// would write are kept in memory, so later migrations are previewed against them.
+	DryRun bool
+}
+
+// migrationState is the content of the metadata item
+type migrationState struct {
+	Applied  []int
+	Current  int
+	Segments []segmentState
+}
+
+// segmentState is the progress of a single scan segment of the current migration
+type segmentState struct {
+	Done    bool
+	LastKey Item
+}
+
+// NewMigrator returns a new Migrator for the given migrations
+func NewMigrator(db dynamodbiface.DynamoDBAPI, migrations []Migration) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+		Segments:   1,
+	}
+}
+
+// Pending returns the migrations up to and including version target that have not been applied. A
+// target of 0 includes every migration.
+func (m *Migrator) Pending(target int) ([]Migration, error) {
+	state, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return m.pending(state, target), nil
+}
+
+// Run applies the pending migrations up to and including version target. A target of 0 applies every
+// migration.
+func (m *Migrator) Run(target int) ([]MigrationResult, error) {
+	state, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var staged *dryRunTables
+	if m.DryRun {
+		staged = &dryRunTables{tables: make(map[string]map[string]Item)}
+	}
+
+	results := []MigrationResult{}
+
+	for _, mig := range m.pending(state, target) {
+		if state.Current != mig.Version || m.DryRun {
+			state.Current = mig.Version
+			state.Segments = make([]segmentState, m.segments())
+		}
+
+		result, err := m.run(mig, state, staged)
+		results = append(results, result)
+		if err != nil {
+			return results, errors.Wrapf(err, "Could not apply migration %d", mig.Version)
+		}
+
+		state.Applied = append(state.Applied, mig.Version)
+		state.Current = 0
+		state.Segments = nil
+
+		if err := m.save(state); err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+// pending returns the migrations that have not been applied, in version order
+func (m *Migrator) pending(state *migrationState, target int) []Migration {
+	applied := make(map[int]bool)
+	for _, v := range state.Applied {
+		applied[v] = true
+	}
+
+	p := []Migration{}
+	for _, mig := range m.migrations {
+		if !applied[mig.Version] && (target == 0 || mig.Version <= target) {
+			p = append(p, mig)
+		}
+	}
+
+	sort.Slice(p, func(i, j int) bool { return p[i].Version < p[j].Version })
+
+	return p
+}
+
+// run scans every unfinished segment of mig in parallel. During a dry run the items written by earlier
+// migrations are read from staged instead of the table, and the items mig would write are added to it.
+func (m *Migrator) run(mig Migration, state *migrationState, staged *dryRunTables) (MigrationResult, error) {
+	result := MigrationResult{
+		Version:     mig.Version,
+		Description: mig.Description,
+		Diff:        make(map[string]int),
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+
+	if staged != nil {
+		staged.begin(mig.Source)
+	}
+
+	for i := range state.Segments {
+		if state.Segments[i].Done {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(segment int) {
+			defer wg.Done()
+
+			err := m.runSegment(mig, segment, len(state.Segments), state.Segments[segment].LastKey, staged,
+				func(page MigrationResult, lastKey Item) error {
+					mu.Lock()
+					defer mu.Unlock()
+
+					result.add(page)
+					state.Segments[segment] = segmentState{Done: len(lastKey) == 0, LastKey: lastKey}
+
+					return m.save(state)
+				})
+
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if staged != nil && firstErr == nil {
+		// Items that earlier migrations would have added to the source are not in the table yet
+		page, migrated, err := migrateItems(mig, staged.unread())
+		if err != nil {
+			return result, err
+		}
+
+		result.add(page)
+		staged.put(mig.Target, migrated)
+	}
+
+	return result, firstErr
+}
+
+// runSegment migrates the items in a single scan segment, starting after startKey. checkpoint is called
+// after every page has been written, or staged when staged is not nil.
+func (m *Migrator) runSegment(mig Migration, segment, total int, startKey Item, staged *dryRunTables,
+	checkpoint func(MigrationResult, Item) error) error {
+
+	input := &dynamodb.ScanInput{
+		TableName:         aws.String(mig.Source),
+		Segment:           aws.Int64(int64(segment)),
+		TotalSegments:     aws.Int64(int64(total)),
+		ExclusiveStartKey: startKey,
+		ConsistentRead:    aws.Bool(true),
+	}
+
+	for {
+		var result *dynamodb.ScanOutput
+
+		err := DefaultRetryPolicy.do(func() (err error) {
+			result, err = m.db.Scan(input)
+			return err
+		})
+		if err != nil {
+			return errors.Wrapf(err, "Could not scan %s", mig.Source)
+		}
+
+		items := result.Items
+		if staged != nil {
+			items = staged.read(items)
+		}
+
+		page, migrated, err := migrateItems(mig, items)
+		if err != nil {
+			return err
+		}
+
+		if staged != nil {
+			staged.put(mig.Target, migrated)
+		} else {
+			requests := make([]*dynamodb.WriteRequest, len(migrated))
+			for i, item := range migrated {
+				requests[i] = &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}}
+			}
+
+			for start := 0; start < len(requests); start += batchWriteSize {
+				end := start + batchWriteSize
+				if end > len(requests) {
+					end = len(requests)
+				}
+
+				if err := batchWrite(m.db, DefaultRetryPolicy, mig.Target, requests[start:end]); err != nil {
+					return errors.Wrapf(err, "Could not write to %s", mig.Target)
+				}
+			}
+		}
+
+		if err := checkpoint(page, result.LastEvaluatedKey); err != nil {
+			return err
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return nil
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// migrateItems transforms items with mig, returning the counts for the page and the items to write
+func migrateItems(mig Migration, items []Item) (MigrationResult, []Item, error) {
+	page := MigrationResult{Diff: make(map[string]int)}
+	migrated := []Item{}
+
+	for _, item := range items {
+		page.Scanned++
+
+		if isReserved(item) {
+			page.Skipped++
+			continue
+		}
+
+		to, err := mig.Transform(item)
+		if err != nil {
+			return page, nil, errors.Wrapf(err, "Could not transform item %v", item)
+		}
+
+		if to == nil {
+			page.Skipped++
+			continue
+		}
+
+		diff := diffItems(item, to)
+		if len(diff) == 0 && mig.Source == mig.Target {
+			page.Unchanged++
+			continue
+		}
+
+		page.Changed++
+		for _, d := range diff {
+			page.Diff[d]++
+		}
+
+		migrated = append(migrated, to)
+	}
+
+	return page, migrated, nil
+}
+
+// dryRunTables holds the items a dry run would have written, by table and key. It is safe for concurrent
+// use by the scan segments of a migration.
+type dryRunTables struct {
+	mu     sync.Mutex
+	tables map[string]map[string]Item
+	// pending are the keys of the staged items of the source of the current migration that its scan has
+	// not returned yet
+	pending map[string]bool
+	source  string
+}
+
+// begin starts a migration that reads source
+func (d *dryRunTables) begin(source string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.source = source
+	d.pending = make(map[string]bool)
+
+	for k := range d.tables[source] {
+		d.pending[k] = true
+	}
+}
+
+// read returns items with those that have been staged replaced by their staged version
+func (d *dryRunTables) read(items []Item) []Item {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	read := make([]Item, len(items))
+
+	for i, item := range items {
+		k := stagedKey(item)
+		if d.pending[k] {
+			item = d.tables[d.source][k]
+			delete(d.pending, k)
+		}
+		read[i] = item
+	}
+
+	return read
+}
+
+// unread returns the staged items of the source that the scan did not return, in key order
+func (d *dryRunTables) unread() []Item {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	keys := []string{}
+	for k := range d.pending {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	items := make([]Item, len(keys))
+	for i, k := range keys {
+		items[i] = d.tables[d.source][k]
+	}
+
+	d.pending = nil
+
+	return items
+}
+
+// put stages items as written to table
+func (d *dryRunTables) put(table string, items []Item) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.tables[table] == nil {
+		d.tables[table] = make(map[string]Item)
+	}
+
+	for _, item := range items {
+		d.tables[table][stagedKey(item)] = item
+	}
+}
+
+// stagedKey returns the primary key of item in the legacy and current ToDos tables
+func stagedKey(item Item) string {
+	var listID string
+	if av, ok := item["listId"]; ok {
+		listID = aws.StringValue(av.S)
+	}
+
+	var id string
+	if av, ok := item["id"]; ok {
+		id = aws.StringValue(av.S)
+	}
+
+	return listID + "/" + id
+}
+
+// load reads the metadata item
+func (m *Migrator) load() (*migrationState, error) {
+	var result *dynamodb.GetItemOutput
+
+	err := DefaultRetryPolicy.do(func() (err error) {
+		result, err = m.db.GetItem(&dynamodb.GetItemInput{
+			TableName:      aws.String(todosTableName),
+			Key:            mapKey(migrationsListID, migrationsID),
+			ConsistentRead: aws.Bool(true),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get migration state from database")
+	}
+
+	state := &migrationState{}
+
+	if av, ok := result.Item["applied"]; ok {
+		for _, n := range av.L {
+			v, err := strconv.Atoi(aws.StringValue(n.N))
+			if err != nil {
+				return nil, errors.Wrap(err, "Could not parse applied migrations")
+			}
+			state.Applied = append(state.Applied, v)
+		}
+	}
+
+	if av, ok := result.Item["current"]; ok {
+		v, err := strconv.Atoi(aws.StringValue(av.N))
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not parse current migration")
+		}
+		state.Current = v
+	}
+
+	if av, ok := result.Item["segments"]; ok {
+		for _, s := range av.L {
+			state.Segments = append(state.Segments, segmentState{
+				Done:    aws.BoolValue(s.M["done"].BOOL),
+				LastKey: s.M["lastKey"].M,
+			})
+		}
+	}
+
+	return state, nil
+}
+
+// save writes the metadata item. Nothing is written during a dry run.
+func (m *Migrator) save(state *migrationState) error {
+	if m.DryRun {
+		return nil
+	}
+
+	item := mapKey(migrationsListID, migrationsID)
+
+	applied := []*dynamodb.AttributeValue{}
+	for _, v := range state.Applied {
+		applied = append(applied, &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(v))})
+	}
+	item["applied"] = &dynamodb.AttributeValue{L: applied}
+
+	if state.Current != 0 {
+		segments := []*dynamodb.AttributeValue{}
+		for _, s := range state.Segments {
+			segments = append(segments, &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{
+				"done":    {BOOL: aws.Bool(s.Done)},
+				"lastKey": {M: s.LastKey},
+			}})
+		}
+
+		item["current"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(state.Current))}
+		item["segments"] = &dynamodb.AttributeValue{L: segments}
+	}
+
+	err := DefaultRetryPolicy.do(func() error {
+		_, err := m.db.PutItem(&dynamodb.PutItemInput{
+			TableName: aws.String(todosTableName),
+			Item:      item,
+		})
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "Could not save migration state to database")
+	}
+
+	return nil
+}
+
+// segments returns the number of scan segments to use
+func (m *Migrator) segments() int {
+	if m.Segments < 1 {
+		return 1
+	}
+	return m.Segments
+}
+
+// add accumulates the counts of other into r
+func (r *MigrationResult) add(other MigrationResult) {
+	r.Scanned += other.Scanned
+	r.Changed += other.Changed
+	r.Unchanged += other.Unchanged
+	r.Skipped += other.Skipped
+
+	for k, v := range other.Diff {
+		r.Diff[k] += v
+	}
+}
+
+// isReserved reports whether item is in a reserved partition rather than a list, such as the migration
+// metadata item
+func isReserved(item Item) bool {
+	av, ok := item["listId"]
+	return ok && strings.HasPrefix(aws.StringValue(av.S), reservedPrefix)
+}
+
+// diffItems returns the attributes added (+name), changed (~name) or removed (-name) between from and to
+func diffItems(from, to Item) []string {
+	diff := []string{}
+
+	for k, v := range to {
+		if old, ok := from[k]; !ok {
+			diff = append(diff, "+"+k)
+		} else if !reflect.DeepEqual(old, v) {
+			diff = append(diff, "~"+k)
+		}
+	}
+
+	for k := range from {
+		if _, ok := to[k]; !ok {
+			diff = append(diff, "-"+k)
+		}
+	}
+
+	sort.Strings(diff)
+
+	return diff
+}
@@ -0,0 +1,334 @@
+package dynamodb_test
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+)
+
+// pageSize is the number of items returned by each Scan of a tableMock
+const pageSize = 2
+
+// syncClient serializes calls to a ClientMock so it can be used by parallel scan segments
+type syncClient struct {
+	*ClientMock
+	mu sync.Mutex
+}
+
+// GetItem returns a set of attributes for the item with the given primary key
+func (c *syncClient) GetItem(input *awsdynamodb.GetItemInput) (*awsdynamodb.GetItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ClientMock.GetItem(input)
+}
+
+// Scan returns one or more items and item attributes by accessing every item in a table or a secondary index
+func (c *syncClient) Scan(input *awsdynamodb.ScanInput) (*awsdynamodb.ScanOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ClientMock.Scan(input)
+}
+
+// PutItem creates a new item, or replaces an old item with a new item
+func (c *syncClient) PutItem(input *awsdynamodb.PutItemInput) (*awsdynamodb.PutItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ClientMock.PutItem(input)
+}
+
+// BatchWriteItem puts or deletes multiple items in one or more tables
+func (c *syncClient) BatchWriteItem(input *awsdynamodb.BatchWriteItemInput) (*awsdynamodb.BatchWriteItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ClientMock.BatchWriteItem(input)
+}
+
+// tableMock is an in memory set of tables, keyed by table name and then by "listId/id"
+type tableMock map[string]map[string]dynamodb.Item
+
+func TestMigrator(t *testing.T) {
+	t.Run("RunAll", testMigratorRunAll)
+	t.Run("RunTarget", testMigratorRunTarget)
+	t.Run("DryRun", testMigratorDryRun)
+	t.Run("Resume", testMigratorResume)
+	t.Run("Segments", testMigratorSegments)
+}
+
+func itemKey(item dynamodb.Item) string {
+	k := "/" + aws.StringValue(item["id"].S)
+	if l, ok := item["listId"]; ok {
+		k = aws.StringValue(l.S) + k
+	}
+	return k
+}
+
+func newTableMock(t *testing.T, legacy int) (tableMock, *ClientMock) {
+	tables := tableMock{"todos": {}, "todos-v2": {}}
+
+	for i := 0; i < legacy; i++ {
+		item, err := dynamodbattribute.MarshalMap(internal.ToDo{
+			ID:        fmt.Sprintf("%02d", i),
+			Title:     fmt.Sprintf("Legacy ToDo %d", i),
+			Completed: i%2 == 0,
+			ModTime:   time.Date(2019, 7, 1, 0, 0, 0, 0, time.FixedZone("PDT", -7*60*60)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables["todos"][itemKey(item)] = item
+	}
+
+	m := &ClientMock{}
+
+	m.GetItemFn = func(input *awsdynamodb.GetItemInput) (*awsdynamodb.GetItemOutput, error) {
+		return &awsdynamodb.GetItemOutput{Item: tables[*input.TableName][itemKey(input.Key)]}, nil
+	}
+
+	m.PutItemFn = func(input *awsdynamodb.PutItemInput) (*awsdynamodb.PutItemOutput, error) {
+		tables[*input.TableName][itemKey(input.Item)] = input.Item
+		return &awsdynamodb.PutItemOutput{}, nil
+	}
+
+	m.BatchWriteItemFn = func(input *awsdynamodb.BatchWriteItemInput) (*awsdynamodb.BatchWriteItemOutput, error) {
+		for table, reqs := range input.RequestItems {
+			for _, req := range reqs {
+				tables[table][itemKey(req.PutRequest.Item)] = req.PutRequest.Item
+			}
+		}
+		return &awsdynamodb.BatchWriteItemOutput{}, nil
+	}
+
+	m.ScanFn = func(input *awsdynamodb.ScanInput) (*awsdynamodb.ScanOutput, error) {
+		keys := []string{}
+		for k := range tables[*input.TableName] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		segment := []string{}
+		for i, k := range keys {
+			if int64(i)%aws.Int64Value(input.TotalSegments) == aws.Int64Value(input.Segment) {
+				segment = append(segment, k)
+			}
+		}
+
+		start := 0
+		if input.ExclusiveStartKey != nil {
+			after := itemKey(input.ExclusiveStartKey)
+			for start < len(segment) && segment[start] <= after {
+				start++
+			}
+		}
+
+		out := &awsdynamodb.ScanOutput{}
+		for i := start; i < len(segment) && i < start+pageSize; i++ {
+			out.Items = append(out.Items, tables[*input.TableName][segment[i]])
+		}
+
+		if start+pageSize < len(segment) {
+			last := out.Items[len(out.Items)-1]
+			out.LastEvaluatedKey = dynamodb.Item{"id": last["id"]}
+			if l, ok := last["listId"]; ok {
+				out.LastEvaluatedKey["listId"] = l
+			}
+		}
+
+		return out, nil
+	}
+
+	return tables, m
+}
+
+func countToDos(tables tableMock) int {
+	n := 0
+	for k := range tables["todos-v2"] {
+		if k != "_migrations/state" {
+			n++
+		}
+	}
+	return n
+}
+
+func testMigratorRunAll(t *testing.T) {
+
+	tables, m := newTableMock(t, 5)
+
+	migrator := dynamodb.NewMigrator(m, dynamodb.Migrations)
+
+	results, err := migrator.Run(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != len(dynamodb.Migrations) {
+		t.Fatalf("Expected %d results, got %d", len(dynamodb.Migrations), len(results))
+	}
+
+	if results[0].Changed != 5 {
+		t.Fatalf("Expected 5 ToDos to be copied, got %d", results[0].Changed)
+	}
+
+	if results[1].Changed != 5 || results[1].Diff["~modTime"] != 5 {
+		t.Fatalf("Expected 5 modTimes to be normalized, got %v", results[1])
+	}
+
+	if results[2].Unchanged != 5 || results[2].Skipped != 1 {
+		t.Fatalf("Expected 5 unchanged ToDos and the metadata item to be skipped, got %v", results[2])
+	}
+
+	if countToDos(tables) != 5 {
+		t.Fatalf("Expected 5 ToDos, got %d", countToDos(tables))
+	}
+
+	repo := dynamodb.NewToDoRepo(m)
+
+	toDo, err := repo.Get("00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if toDo == nil || toDo.ListID != dynamodb.DefaultListID {
+		t.Fatalf("Expected ToDo in default list, got %v", toDo)
+	}
+
+	if _, offset := toDo.ModTime.Zone(); offset != 0 {
+		t.Fatalf("Expected ModTime in UTC, got %v", toDo.ModTime)
+	}
+
+	// Running again is a no-op
+	results, err = migrator.Run(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 0 {
+		t.Fatalf("Expected no migrations to run, got %d", len(results))
+	}
+}
+
+func testMigratorRunTarget(t *testing.T) {
+
+	_, m := newTableMock(t, 3)
+
+	migrator := dynamodb.NewMigrator(m, dynamodb.Migrations)
+
+	results, err := migrator.Run(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 migration to run, got %d", len(results))
+	}
+
+	pending, err := migrator.Pending(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pending) != len(dynamodb.Migrations)-1 || pending[0].Version != 2 {
+		t.Fatalf("Expected migrations from version 2 to be pending, got %v", pending)
+	}
+}
+
+func testMigratorDryRun(t *testing.T) {
+
+	tables, m := newTableMock(t, 3)
+
+	migrator := dynamodb.NewMigrator(m, dynamodb.Migrations)
+	migrator.DryRun = true
+
+	results, err := migrator.Run(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results[0].Changed != 3 || results[0].Diff["+listId"] != 3 || results[0].Diff["+listStatus"] != 3 {
+		t.Fatalf("Expected listId and listStatus to be added to 3 ToDos, got %v", results[0])
+	}
+
+	if results[1].Scanned != 3 || results[1].Changed != 3 || results[1].Diff["~modTime"] != 3 {
+		t.Fatalf("Expected modTime to be changed on the 3 ToDos migration 1 would copy, got %v", results[1])
+	}
+
+	if results[2].Scanned != 3 || results[2].Unchanged != 3 {
+		t.Fatalf("Expected the 3 ToDos migration 1 would copy to be unchanged, got %v", results[2])
+	}
+
+	if len(tables["todos-v2"]) != 0 {
+		t.Fatalf("Expected nothing to be written, got %d items", len(tables["todos-v2"]))
+	}
+
+	if m.BatchWriteItemInvoked || m.PutItemInvoked {
+		t.Fatal("Expected no writes")
+	}
+}
+
+func testMigratorResume(t *testing.T) {
+
+	tables, m := newTableMock(t, 7)
+
+	write := m.BatchWriteItemFn
+	writes := 0
+
+	m.BatchWriteItemFn = func(input *awsdynamodb.BatchWriteItemInput) (*awsdynamodb.BatchWriteItemOutput, error) {
+		writes++
+		if writes == 3 {
+			return nil, errors.New("Interrupted")
+		}
+		return write(input)
+	}
+
+	migrator := dynamodb.NewMigrator(m, dynamodb.Migrations)
+
+	if _, err := migrator.Run(0); err == nil {
+		t.Fatal("Expected Error")
+	}
+
+	if countToDos(tables) != 4 {
+		t.Fatalf("Expected 2 pages to be copied before the interruption, got %d ToDos", countToDos(tables))
+	}
+
+	results, err := migrator.Run(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results[0].Version != 1 || results[0].Scanned != 3 {
+		t.Fatalf("Expected migration 1 to resume with the 3 remaining ToDos, got %v", results[0])
+	}
+
+	if countToDos(tables) != 7 {
+		t.Fatalf("Expected 7 ToDos, got %d", countToDos(tables))
+	}
+}
+
+func testMigratorSegments(t *testing.T) {
+
+	tables, m := newTableMock(t, 9)
+
+	migrator := dynamodb.NewMigrator(&syncClient{ClientMock: m}, dynamodb.Migrations)
+	migrator.Segments = 3
+
+	results, err := migrator.Run(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results[0].Scanned != 9 {
+		t.Fatalf("Expected 9 ToDos to be scanned, got %d", results[0].Scanned)
+	}
+
+	if countToDos(tables) != 9 {
+		t.Fatalf("Expected 9 ToDos, got %d", countToDos(tables))
+	}
+}
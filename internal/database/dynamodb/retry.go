@@ -0,0 +1,100 @@
+package dynamodb
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/benjaminbartels/todo/internal/database"
+)
+
+// RetryPolicy controls how requests that fail with a retryable error are retried. The wait between
+// attempts starts at InitialInterval and is multiplied by Multiplier after each attempt, up to
+// MaxInterval. Each wait is randomized by +/- Jitter of its value so that clients retrying at the same
+// time spread out. Retrying stops once MaxElapsedTime would be exceeded.
+type RetryPolicy struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+	Multiplier      float64
+	Jitter          float64
+}
+
+// DefaultRetryPolicy is the RetryPolicy used by NewToDoRepo. MaxElapsedTime is kept well below the
+// API Gateway integration timeout.
+var DefaultRetryPolicy = RetryPolicy{
+	InitialInterval: 50 * time.Millisecond,
+	MaxInterval:     1 * time.Second,
+	MaxElapsedTime:  3 * time.Second,
+	Multiplier:      2,
+	Jitter:          0.5,
+}
+
+// NoRetryPolicy is a RetryPolicy that never retries
+var NoRetryPolicy = RetryPolicy{}
+
+// do calls fn until it succeeds, fails with an error that is not retryable or the policy is exhausted.
+// Throttling and transient errors are returned as a *database.RetryError that wraps the error of the last
+// attempt.
+func (p RetryPolicy) do(fn func() error) error {
+	start := time.Now()
+	interval := p.InitialInterval
+
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		cause := classify(err)
+		if cause == nil {
+			return err
+		}
+
+		wait := p.randomize(interval)
+		if p.MaxElapsedTime <= 0 || time.Since(start)+wait > p.MaxElapsedTime {
+			return &database.RetryError{Kind: cause, Err: err}
+		}
+
+		time.Sleep(wait)
+
+		interval = time.Duration(float64(interval) * p.Multiplier)
+		if interval > p.MaxInterval {
+			interval = p.MaxInterval
+		}
+	}
+}
+
+// randomize returns interval adjusted by a random amount of up to +/- Jitter of its value
+func (p RetryPolicy) randomize(interval time.Duration) time.Duration {
+	delta := p.Jitter * float64(interval)
+	min := float64(interval) - delta
+	return time.Duration(min + rand.Float64()*(2*delta))
+}
+
+// classify returns database.ErrThrottled or database.ErrUnavailable if err can be retried, or nil if
+// it can not
+func classify(err error) error {
+	if request.IsErrorThrottle(err) {
+		return database.ErrThrottled
+	}
+
+	if request.IsErrorRetryable(err) {
+		return database.ErrUnavailable
+	}
+
+	if aerr, ok := err.(awserr.RequestFailure); ok && aerr.StatusCode() >= http.StatusInternalServerError {
+		return database.ErrUnavailable
+	}
+
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case "InternalServerError", "ServiceUnavailable":
+			return database.ErrUnavailable
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package dynamodb
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+const (
+	// todosTableName is the table holding ToDos keyed by list and ID
+	todosTableName = "todos-v2"
+	// legacyTodosTableName is the original table holding ToDos keyed by ID only
+	legacyTodosTableName = "todos"
+	// completedIndexName is the GSI for querying ToDos in a list by completed state, ordered by modTime
+	completedIndexName = "completed-index"
+	// dueIndexName is the GSI for querying ToDos in a list by due date. It is sparse as only ToDos with a
+	// due date are projected.
+	dueIndexName = "due-index"
+	// assigneeIndexName is the GSI for querying the assignments of ToDos to a user across lists, ordered by
+	// modTime. It is sparse as only assignment items have an assignee.
+	assigneeIndexName = "assignee-index"
+	// timeIndexName is the GSI for querying the time entries of a user across lists by when they started.
+	// It is sparse as only time entries have an entry user.
+	timeIndexName = "time-index"
+	// DefaultListID is the list ToDos belong to when none is given
+	DefaultListID = internal.DefaultListID
+	// reservedPrefix starts the partition keys used for items that are not ToDos. List IDs must not start
+	// with it.
+	reservedPrefix = "_"
+	// tombstonesPrefix starts the partition keys of the tombstones of deleted ToDos, which are followed by
+	// the list ID
+	tombstonesPrefix = reservedPrefix + "deleted#"
+)
+
+// tombstoneItem is how the tombstone of a deleted ToDo is stored. Expires is the TTL attribute, in Unix
+// seconds.
+type tombstoneItem struct {
+	ListID  string    `json:"listId"`
+	ID      string    `json:"id"`
+	Deleted time.Time `json:"deleted"`
+	Expires int64     `json:"expires"`
+}
+
+// tombstonesListID returns the partition key of the tombstones of ToDos deleted from a list
+func tombstonesListID(listID string) string {
+	return tombstonesPrefix + listID
+}
+
+// TableDefinition returns the input used to create the ToDos table, for use with DynamoDB Local and
+// infrastructure tooling
+func TableDefinition() *dynamodb.CreateTableInput {
+	throughput := &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(5),
+		WriteCapacityUnits: aws.Int64(5),
+	}
+
+	return &dynamodb.CreateTableInput{
+		TableName: aws.String(todosTableName),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("listId"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			{AttributeName: aws.String("id"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			{AttributeName: aws.String("listStatus"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			{AttributeName: aws.String("modTime"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			{AttributeName: aws.String("due"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			{AttributeName: aws.String("assigneeId"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			{AttributeName: aws.String("entryUserId"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			{AttributeName: aws.String("start"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("listId"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+			{AttributeName: aws.String("id"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+		},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String(completedIndexName),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{AttributeName: aws.String("listStatus"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+					{AttributeName: aws.String("modTime"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+				},
+				Projection:            &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+				ProvisionedThroughput: throughput,
+			},
+			{
+				IndexName: aws.String(dueIndexName),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{AttributeName: aws.String("listId"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+					{AttributeName: aws.String("due"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+				},
+				Projection:            &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+				ProvisionedThroughput: throughput,
+			},
+			{
+				IndexName: aws.String(assigneeIndexName),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{AttributeName: aws.String("assigneeId"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+					{AttributeName: aws.String("modTime"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+				},
+				Projection:            &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+				ProvisionedThroughput: throughput,
+			},
+			{
+				IndexName: aws.String(timeIndexName),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{AttributeName: aws.String("entryUserId"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+					{AttributeName: aws.String("start"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+				},
+				Projection:            &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+				ProvisionedThroughput: throughput,
+			},
+		},
+		ProvisionedThroughput: throughput,
+	}
+}
+
+// CreateTable creates the ToDos table if it does not already exist
+func CreateTable(db dynamodbiface.DynamoDBAPI) error {
+	if _, err := db.CreateTable(TableDefinition()); err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceInUseException {
+			return nil
+		}
+		return errors.Wrapf(err, "Could not create table %s", todosTableName)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package dynamodb
+
+import (
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+// UnmarshalStreamImage returns the ToDo in an image of an item from the stream of the ToDos table, or nil
+// if the image is empty or the item is not a ToDo
+func UnmarshalStreamImage(image map[string]events.DynamoDBAttributeValue) (*internal.ToDo, error) {
+	if len(image) == 0 {
+		return nil, nil
+	}
+
+	item := make(map[string]*dynamodb.AttributeValue, len(image))
+	for k, v := range image {
+		item[k] = attributeValue(v)
+	}
+
+	if listID := item["listId"]; listID == nil || listID.S == nil || strings.HasPrefix(*listID.S, reservedPrefix) {
+		return nil, nil
+	}
+
+	t := &internal.ToDo{}
+
+	if err := dynamodbattribute.UnmarshalMap(item, t); err != nil {
+		return nil, errors.Wrap(err, "Could not unmarshal ToDo from stream image")
+	}
+
+	return t, nil
+}
+
+// UnmarshalStreamComment returns the Comment in an image of an item from the stream of the ToDos table, or
+// nil if the image is empty or the item is not a Comment
+func UnmarshalStreamComment(image map[string]events.DynamoDBAttributeValue) (*internal.Comment, error) {
+	item := make(map[string]*dynamodb.AttributeValue, len(image))
+	for k, v := range image {
+		item[k] = attributeValue(v)
+	}
+
+	if listID := item["listId"]; listID == nil || listID.S == nil || !strings.HasPrefix(*listID.S, commentsPrefix) {
+		return nil, nil
+	}
+
+	c := commentItem{}
+
+	if err := dynamodbattribute.UnmarshalMap(item, &c); err != nil {
+		return nil, errors.Wrap(err, "Could not unmarshal comment from stream image")
+	}
+
+	comment := c.comment()
+
+	return &comment, nil
+}
+
+// attributeValue converts an attribute value from a Lambda stream event to its SDK equivalent
+func attributeValue(v events.DynamoDBAttributeValue) *dynamodb.AttributeValue {
+	av := &dynamodb.AttributeValue{}
+
+	switch v.DataType() {
+	case events.DataTypeBinary:
+		av.SetB(v.Binary())
+	case events.DataTypeBoolean:
+		av.SetBOOL(v.Boolean())
+	case events.DataTypeBinarySet:
+		av.SetBS(v.BinarySet())
+	case events.DataTypeList:
+		l := []*dynamodb.AttributeValue{}
+		for _, e := range v.List() {
+			l = append(l, attributeValue(e))
+		}
+		av.SetL(l)
+	case events.DataTypeMap:
+		m := make(map[string]*dynamodb.AttributeValue)
+		for k, e := range v.Map() {
+			m[k] = attributeValue(e)
+		}
+		av.SetM(m)
+	case events.DataTypeNumber:
+		av.SetN(v.Number())
+	case events.DataTypeNumberSet:
+		av.SetNS(aws.StringSlice(v.NumberSet()))
+	case events.DataTypeNull:
+		av.SetNULL(true)
+	case events.DataTypeString:
+		av.SetS(v.String())
+	case events.DataTypeStringSet:
+		av.SetSS(aws.StringSlice(v.StringSet()))
+	}
+
+	return av
+}
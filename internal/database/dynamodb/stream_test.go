@@ -0,0 +1,103 @@
+package dynamodb_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+)
+
+func TestUnmarshalStreamImage(t *testing.T) {
+	t.Run("ToDo", testUnmarshalStreamImageToDo)
+	t.Run("Empty", testUnmarshalStreamImageEmpty)
+	t.Run("NotToDo", testUnmarshalStreamImageNotToDo)
+	t.Run("Comment", testUnmarshalStreamComment)
+}
+
+func testUnmarshalStreamImageToDo(t *testing.T) {
+
+	image := map[string]events.DynamoDBAttributeValue{
+		"listId":    events.NewStringAttribute("work"),
+		"id":        events.NewStringAttribute("1"),
+		"title":     events.NewStringAttribute("Foo"),
+		"completed": events.NewBooleanAttribute(true),
+		"modTime":   events.NewStringAttribute("2019-07-01T12:00:00Z"),
+	}
+
+	got, err := dynamodb.UnmarshalStreamImage(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got == nil {
+		t.Fatal("Expected ToDo not to be nil")
+	}
+
+	if got.ListID != "work" || got.ID != "1" || got.Title != "Foo" || !got.Completed || got.ModTime.IsZero() {
+		t.Fatalf("Unexpected ToDo %+v", got)
+	}
+}
+
+func testUnmarshalStreamImageEmpty(t *testing.T) {
+
+	got, err := dynamodb.UnmarshalStreamImage(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != nil {
+		t.Fatalf("Expected nil ToDo, got %+v", got)
+	}
+}
+
+func testUnmarshalStreamImageNotToDo(t *testing.T) {
+
+	image := map[string]events.DynamoDBAttributeValue{
+		"listId":  events.NewStringAttribute("_deleted#work"),
+		"id":      events.NewStringAttribute("1"),
+		"expires": events.NewNumberAttribute("1562000000"),
+	}
+
+	got, err := dynamodb.UnmarshalStreamImage(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != nil {
+		t.Fatalf("Expected nil ToDo, got %+v", got)
+	}
+}
+
+func testUnmarshalStreamComment(t *testing.T) {
+
+	image := map[string]events.DynamoDBAttributeValue{
+		"listId":        events.NewStringAttribute("_comments#work"),
+		"id":            events.NewStringAttribute("1#c1"),
+		"commentId":     events.NewStringAttribute("c1"),
+		"commentListId": events.NewStringAttribute("work"),
+		"todoId":        events.NewStringAttribute("1"),
+		"authorId":      events.NewStringAttribute("user-1"),
+		"body":          events.NewStringAttribute("@sam have a look"),
+		"mentions":      events.NewListAttribute([]events.DynamoDBAttributeValue{events.NewStringAttribute("user-2")}),
+		"created":       events.NewStringAttribute("2019-07-01T12:00:00Z"),
+	}
+
+	got, err := dynamodb.UnmarshalStreamComment(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got == nil || got.ID != "c1" || got.ListID != "work" || got.ToDoID != "1" || len(got.Mentions) != 1 {
+		t.Fatalf("Unexpected Comment %+v", got)
+	}
+
+	if todo, err := dynamodb.UnmarshalStreamImage(image); err != nil || todo != nil {
+		t.Fatalf("Expected a comment not to be a ToDo, got %+v", todo)
+	}
+
+	for _, image := range []map[string]events.DynamoDBAttributeValue{nil, {"listId": events.NewStringAttribute("work")}} {
+		if got, err := dynamodb.UnmarshalStreamComment(image); err != nil || got != nil {
+			t.Fatalf("Expected no Comment, got %+v", got)
+		}
+	}
+}
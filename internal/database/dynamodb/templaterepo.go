@@ -0,0 +1,160 @@
+package dynamodb
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+// templatesPrefix starts the partition keys of the templates of a user, which are followed by the user ID
+const templatesPrefix = reservedPrefix + "templates#"
+
+// templateItem is how a Template is stored in the partition of its user
+type templateItem struct {
+	ListID string `json:"listId"`
+	internal.Template
+}
+
+// TemplateRepo represents a DynamoDB repository for managing the templates of users
+type TemplateRepo struct {
+	db    dynamodbiface.DynamoDBAPI
+	retry RetryPolicy
+}
+
+// NewTemplateRepo returns a new Template repository using the given DynamoDB client
+func NewTemplateRepo(db dynamodbiface.DynamoDBAPI) *TemplateRepo {
+	return &TemplateRepo{db: db, retry: DefaultRetryPolicy}
+}
+
+// Get returns a template of a user by its ID
+func (r *TemplateRepo) Get(userID, id string) (*internal.Template, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(todosTableName),
+		Key:       mapKey(templatesPrefix+userID, id),
+	}
+
+	var result *dynamodb.GetItemOutput
+
+	err := r.retry.do(func() (err error) {
+		result, err = r.db.GetItem(input)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not get template %s from database", id)
+	}
+
+	item := templateItem{}
+
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, errors.Wrapf(err, "Could not unmarshal template %s", id)
+	}
+
+	if item.ID == "" {
+		return nil, nil
+	}
+
+	return &item.Template, nil
+}
+
+// GetByUser returns the templates of a user, sorted by name
+func (r *TemplateRepo) GetByUser(userID string) ([]internal.Template, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(todosTableName),
+		KeyConditionExpression: aws.String("listId = :listId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":listId": {S: aws.String(templatesPrefix + userID)},
+		},
+	}
+
+	templates := []internal.Template{}
+
+	for {
+		var result *dynamodb.QueryOutput
+
+		err := r.retry.do(func() (err error) {
+			result, err = r.db.Query(input)
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not get templates of user %s from database", userID)
+		}
+
+		page := []templateItem{}
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, errors.Wrap(err, "Could not unmarshal templates")
+		}
+
+		for _, item := range page {
+			templates = append(templates, item.Template)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	sort.SliceStable(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+
+	return templates, nil
+}
+
+// Save creates or updates a template, setting its ModTime
+func (r *TemplateRepo) Save(template *internal.Template) error {
+	template.ModTime = time.Now().UTC()
+
+	if template.Created.IsZero() {
+		template.Created = template.ModTime
+	}
+
+	item, err := dynamodbattribute.MarshalMap(templateItem{
+		ListID:   templatesPrefix + template.UserID,
+		Template: *template,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not marshal template %s", template.ID)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(todosTableName),
+		Item:      item,
+	}
+
+	err = r.retry.do(func() error {
+		_, err := r.db.PutItem(input)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not save template %s to database", template.ID)
+	}
+
+	return nil
+}
+
+// Delete removes a template of a user. Deleting a template that does not exist is not an error.
+func (r *TemplateRepo) Delete(userID, id string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(todosTableName),
+		Key:       mapKey(templatesPrefix+userID, id),
+	}
+
+	err := r.retry.do(func() error {
+		_, err := r.db.DeleteItem(input)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not delete template %s from database", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package dynamodb_test
+
+import (
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+)
+
+func TestTemplateRepo(t *testing.T) {
+	t.Run("SaveGetAndDeleteTemplate", testSaveGetAndDeleteTemplate)
+	t.Run("GetTemplatesByUser", testGetTemplatesByUser)
+}
+
+func testSaveGetAndDeleteTemplate(t *testing.T) {
+
+	repo := dynamodb.NewTemplateRepo(itemTableMock())
+
+	offset := -2
+
+	tmpl := &internal.Template{
+		ID:     "t1",
+		UserID: "user-1",
+		Name:   "Release",
+		Items: []internal.TemplateItem{
+			{
+				Title:    "Release {{version}}",
+				Tags:     []string{"release"},
+				Subtasks: []internal.TemplateItem{{Title: "Tag {{version}}", DueOffsetDays: &offset}},
+			},
+		},
+	}
+
+	if err := repo.Save(tmpl); err != nil {
+		t.Fatal(err)
+	}
+
+	if tmpl.Created.IsZero() || tmpl.ModTime.IsZero() {
+		t.Fatal("Expected Template to have a not zero Created and ModTime")
+	}
+
+	got, err := repo.Get("user-1", "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got == nil || got.UserID != "user-1" || got.Name != "Release" || len(got.Items) != 1 ||
+		len(got.Items[0].Subtasks) != 1 || *got.Items[0].Subtasks[0].DueOffsetDays != -2 {
+		t.Fatalf("Unexpected Template %+v", got)
+	}
+
+	if other, err := repo.Get("user-2", "t1"); err != nil || other != nil {
+		t.Fatalf("Expected the template of another user not to be found, got %+v, %v", other, err)
+	}
+
+	if err := repo.Delete("user-1", "t1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err = repo.Get("user-1", "t1"); err != nil || got != nil {
+		t.Fatalf("Expected the template to be deleted, got %+v, %v", got, err)
+	}
+}
+
+func testGetTemplatesByUser(t *testing.T) {
+
+	repo := dynamodb.NewTemplateRepo(itemTableMock())
+
+	for _, tmpl := range []internal.Template{
+		{ID: "t1", UserID: "user-1", Name: "Sprint"},
+		{ID: "t2", UserID: "user-2", Name: "Onboarding"},
+		{ID: "t3", UserID: "user-1", Name: "Release"},
+	} {
+		tmpl := tmpl
+		if err := repo.Save(&tmpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	templates, err := repo.GetByUser("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(templates) != 2 || templates[0].ID != "t3" || templates[1].ID != "t1" {
+		t.Fatalf("Expected the templates of user-1 sorted by name, got %+v", templates)
+	}
+}
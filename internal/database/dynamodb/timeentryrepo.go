@@ -0,0 +1,399 @@
+package dynamodb
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/pkg/errors"
+)
+
+const (
+	// timePrefix starts the partition keys of the time entries on the ToDos of a list, which are followed
+	// by the list ID. Like comments, entries are sorted by the ID of their ToDo.
+	timePrefix = reservedPrefix + "time#"
+	// timersPrefix starts the partition keys of running timers, which are followed by the user ID. A user's
+	// running timer is the item with the ID runningTimerID, so a user can only have one.
+	timersPrefix = reservedPrefix + "timer#"
+	// runningTimerID is the ID of the item referring to the running timer of a user
+	runningTimerID = "running"
+)
+
+// timeEntryItem is how a TimeEntry is stored in the partition of its list. EntryUserID and Start are the
+// keys of the time index.
+type timeEntryItem struct {
+	ListID      string     `json:"listId"`
+	ID          string     `json:"id"`
+	EntryID     string     `json:"entryId"`
+	EntryListID string     `json:"entryListId"`
+	ToDoID      string     `json:"todoId"`
+	EntryUserID string     `json:"entryUserId"`
+	Start       time.Time  `json:"start"`
+	End         *time.Time `json:"end,omitempty"`
+	Note        string     `json:"note,omitempty"`
+}
+
+// timeEntry returns the TimeEntry stored in item
+func (item timeEntryItem) timeEntry() internal.TimeEntry {
+	return internal.TimeEntry{
+		ID:     item.EntryID,
+		ListID: item.EntryListID,
+		ToDoID: item.ToDoID,
+		UserID: item.EntryUserID,
+		Start:  item.Start,
+		End:    item.End,
+		Note:   item.Note,
+	}
+}
+
+// timerItem refers to the time entry that is the running timer of a user
+type timerItem struct {
+	ListID      string `json:"listId"`
+	ID          string `json:"id"`
+	EntryID     string `json:"entryId"`
+	EntryListID string `json:"entryListId"`
+	ToDoID      string `json:"todoId"`
+}
+
+// timeEntryKey returns the key of a time entry
+func timeEntryKey(listID, todoID, id string) map[string]*dynamodb.AttributeValue {
+	return mapKey(timePrefix+listID, todoID+"#"+id)
+}
+
+// timerKey returns the key of the running timer of a user
+func timerKey(userID string) map[string]*dynamodb.AttributeValue {
+	return mapKey(timersPrefix+userID, runningTimerID)
+}
+
+// TimeEntryRepo represents a DynamoDB repository for managing the time spent on ToDos
+type TimeEntryRepo struct {
+	db    dynamodbiface.DynamoDBAPI
+	retry RetryPolicy
+}
+
+// NewTimeEntryRepo returns a new TimeEntry repository using the given DynamoDB client
+func NewTimeEntryRepo(db dynamodbiface.DynamoDBAPI) *TimeEntryRepo {
+	return &TimeEntryRepo{db: db, retry: DefaultRetryPolicy}
+}
+
+// Get returns a time entry on a ToDo by its ID
+func (r *TimeEntryRepo) Get(listID, todoID, id string) (*internal.TimeEntry, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(todosTableName),
+		Key:       timeEntryKey(listID, todoID, id),
+	}
+
+	var result *dynamodb.GetItemOutput
+
+	err := r.retry.do(func() (err error) {
+		result, err = r.db.GetItem(input)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not get time entry %s from database", id)
+	}
+
+	item := timeEntryItem{}
+
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, errors.Wrapf(err, "Could not unmarshal time entry %s", id)
+	}
+
+	if item.EntryID == "" {
+		return nil, nil
+	}
+
+	e := item.timeEntry()
+
+	return &e, nil
+}
+
+// GetByToDo returns the time entries on a ToDo, oldest first
+func (r *TimeEntryRepo) GetByToDo(listID, todoID string) ([]internal.TimeEntry, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(todosTableName),
+		KeyConditionExpression: aws.String("listId = :listId AND begins_with(id, :prefix)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":listId": {S: aws.String(timePrefix + listID)},
+			":prefix": {S: aws.String(todoID + "#")},
+		},
+	}
+
+	entries, err := r.query(input)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not get time entries on ToDo %s from database", todoID)
+	}
+
+	return entries, nil
+}
+
+// GetByUser returns the time entries of a user that started from from until to, oldest first
+func (r *TimeEntryRepo) GetByUser(userID string, from, to time.Time) ([]internal.TimeEntry, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(todosTableName),
+		IndexName:              aws.String(timeIndexName),
+		KeyConditionExpression: aws.String("entryUserId = :userId AND #start BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]*string{
+			"#start": aws.String("start"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userId": {S: aws.String(userID)},
+			":from":   {S: aws.String(from.UTC().Format(time.RFC3339Nano))},
+			":to":     {S: aws.String(to.UTC().Format(time.RFC3339Nano))},
+		},
+	}
+
+	entries, err := r.query(input)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not get time entries of user %s from database", userID)
+	}
+
+	// BETWEEN includes to
+	inRange := entries[:0]
+	for _, e := range entries {
+		if e.Start.Before(to) {
+			inRange = append(inRange, e)
+		}
+	}
+
+	return inRange, nil
+}
+
+// query returns every time entry matching input, oldest first
+func (r *TimeEntryRepo) query(input *dynamodb.QueryInput) ([]internal.TimeEntry, error) {
+	entries := []internal.TimeEntry{}
+
+	for {
+		var result *dynamodb.QueryOutput
+
+		err := r.retry.do(func() (err error) {
+			result, err = r.db.Query(input)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		page := []timeEntryItem{}
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, errors.Wrap(err, "Could not unmarshal time entries")
+		}
+
+		for _, item := range page {
+			entries = append(entries, item.timeEntry())
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Start.Before(entries[j].Start)
+	})
+
+	return entries, nil
+}
+
+// GetRunning returns the running timer of a user, or nil if they have none
+func (r *TimeEntryRepo) GetRunning(userID string) (*internal.TimeEntry, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(todosTableName),
+		Key:       timerKey(userID),
+	}
+
+	var result *dynamodb.GetItemOutput
+
+	err := r.retry.do(func() (err error) {
+		result, err = r.db.GetItem(input)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not get running timer of user %s from database", userID)
+	}
+
+	item := timerItem{}
+
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, errors.Wrapf(err, "Could not unmarshal running timer of user %s", userID)
+	}
+
+	if item.EntryID == "" {
+		return nil, nil
+	}
+
+	return r.Get(item.EntryListID, item.ToDoID, item.EntryID)
+}
+
+// Start saves a time entry without an end as the running timer of its user. It returns
+// database.ErrConflict if the user already has a running timer.
+func (r *TimeEntryRepo) Start(entry *internal.TimeEntry) error {
+	item, err := marshalTimeEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	timer, err := dynamodbattribute.MarshalMap(timerItem{
+		ListID:      timersPrefix + entry.UserID,
+		ID:          runningTimerID,
+		EntryID:     entry.ID,
+		EntryListID: entry.ListID,
+		ToDoID:      entry.ToDoID,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not marshal running timer %s", entry.ID)
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{
+				TableName:           aws.String(todosTableName),
+				Item:                timer,
+				ConditionExpression: aws.String("attribute_not_exists(listId)"),
+			}},
+			{Put: &dynamodb.Put{TableName: aws.String(todosTableName), Item: item}},
+		},
+	}
+
+	err = r.retry.do(func() error {
+		_, err := r.db.TransactWriteItems(input)
+		return err
+	})
+	if canceled(err) {
+		return errors.Wrapf(database.ErrConflict, "User %s already has a running timer", entry.UserID)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Could not start timer %s", entry.ID)
+	}
+
+	return nil
+}
+
+// Stop saves the running timer of a user once it has an end, so they can start another. It returns
+// database.ErrConflict if the entry is not their running timer.
+func (r *TimeEntryRepo) Stop(entry *internal.TimeEntry) error {
+	if entry.End == nil {
+		return errors.Errorf("Could not stop timer %s without an end", entry.ID)
+	}
+
+	item, err := marshalTimeEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Delete: &dynamodb.Delete{
+				TableName:           aws.String(todosTableName),
+				Key:                 timerKey(entry.UserID),
+				ConditionExpression: aws.String("entryId = :entryId"),
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":entryId": {S: aws.String(entry.ID)},
+				},
+			}},
+			{Put: &dynamodb.Put{TableName: aws.String(todosTableName), Item: item}},
+		},
+	}
+
+	err = r.retry.do(func() error {
+		_, err := r.db.TransactWriteItems(input)
+		return err
+	})
+	if canceled(err) {
+		return errors.Wrapf(database.ErrConflict, "Time entry %s is not the running timer of user %s", entry.ID, entry.UserID)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Could not stop timer %s", entry.ID)
+	}
+
+	return nil
+}
+
+// Save creates or updates a time entry that is not running
+func (r *TimeEntryRepo) Save(entry *internal.TimeEntry) error {
+	if entry.End == nil {
+		return errors.Errorf("Could not save time entry %s without an end, it must be started", entry.ID)
+	}
+
+	item, err := marshalTimeEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(todosTableName),
+		Item:      item,
+	}
+
+	err = r.retry.do(func() error {
+		_, err := r.db.PutItem(input)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not save time entry %s to database", entry.ID)
+	}
+
+	return nil
+}
+
+// Delete removes a time entry. Deleting a time entry that does not exist is not an error.
+func (r *TimeEntryRepo) Delete(listID, todoID, id string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(todosTableName),
+		Key:       timeEntryKey(listID, todoID, id),
+	}
+
+	err := r.retry.do(func() error {
+		_, err := r.db.DeleteItem(input)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not delete time entry %s from database", id)
+	}
+
+	return nil
+}
+
+// marshalTimeEntry returns the item a time entry is stored as. Times are stored in UTC, so the time index
+// sorts them.
+func marshalTimeEntry(entry *internal.TimeEntry) (map[string]*dynamodb.AttributeValue, error) {
+	entry.Start = entry.Start.UTC()
+	if entry.End != nil {
+		end := entry.End.UTC()
+		entry.End = &end
+	}
+
+	key := timeEntryKey(entry.ListID, entry.ToDoID, entry.ID)
+
+	item, err := dynamodbattribute.MarshalMap(timeEntryItem{
+		ListID:      *key["listId"].S,
+		ID:          *key["id"].S,
+		EntryID:     entry.ID,
+		EntryListID: entry.ListID,
+		ToDoID:      entry.ToDoID,
+		EntryUserID: entry.UserID,
+		Start:       entry.Start,
+		End:         entry.End,
+		Note:        entry.Note,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not marshal time entry %s", entry.ID)
+	}
+
+	return item, nil
+}
+
+// canceled reports whether err is a transaction that was canceled because a condition failed
+func canceled(err error) bool {
+	aerr, ok := errors.Cause(err).(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeTransactionCanceledException
+}
@@ -0,0 +1,233 @@
+package dynamodb_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+	"github.com/pkg/errors"
+)
+
+func TestTimeEntryRepo(t *testing.T) {
+	t.Run("StartAndStopTimer", testStartAndStopTimer)
+	t.Run("StartSecondTimer", testStartSecondTimer)
+	t.Run("StopTimerNotRunning", testStopTimerNotRunning)
+	t.Run("GetTimeEntriesByToDo", testGetTimeEntriesByToDo)
+	t.Run("GetTimeEntriesByUser", testGetTimeEntriesByUser)
+}
+
+// timeTableMock returns a ClientMock that keeps items in memory, applies transactions if their conditions
+// hold and queries the time index
+func timeTableMock() *ClientMock {
+	m, items := connectionTableMock()
+
+	key := func(k map[string]*awsdynamodb.AttributeValue) string {
+		return *k["listId"].S + "/" + *k["id"].S
+	}
+
+	m.PutItemFn = func(input *awsdynamodb.PutItemInput) (*awsdynamodb.PutItemOutput, error) {
+		items[key(input.Item)] = input.Item
+		return &awsdynamodb.PutItemOutput{}, nil
+	}
+
+	m.DeleteItemFn = func(input *awsdynamodb.DeleteItemInput) (*awsdynamodb.DeleteItemOutput, error) {
+		delete(items, key(input.Key))
+		return &awsdynamodb.DeleteItemOutput{}, nil
+	}
+
+	m.TransactWriteItemsFn = func(input *awsdynamodb.TransactWriteItemsInput) (*awsdynamodb.TransactWriteItemsOutput, error) {
+		canceled := awserr.New(awsdynamodb.ErrCodeTransactionCanceledException, "condition failed", nil)
+
+		for _, ti := range input.TransactItems {
+			if ti.Put != nil && ti.Put.ConditionExpression != nil {
+				if _, ok := items[key(ti.Put.Item)]; ok {
+					return nil, canceled
+				}
+			}
+			if ti.Delete != nil && ti.Delete.ConditionExpression != nil {
+				existing, ok := items[key(ti.Delete.Key)]
+				if !ok || *existing["entryId"].S != *ti.Delete.ExpressionAttributeValues[":entryId"].S {
+					return nil, canceled
+				}
+			}
+		}
+
+		for _, ti := range input.TransactItems {
+			if ti.Put != nil {
+				items[key(ti.Put.Item)] = ti.Put.Item
+			}
+			if ti.Delete != nil {
+				delete(items, key(ti.Delete.Key))
+			}
+		}
+
+		return &awsdynamodb.TransactWriteItemsOutput{}, nil
+	}
+
+	m.QueryFn = func(input *awsdynamodb.QueryInput) (*awsdynamodb.QueryOutput, error) {
+		out := &awsdynamodb.QueryOutput{}
+
+		if aws.StringValue(input.IndexName) != "time-index" {
+			for _, i := range items {
+				if *i["listId"].S == *input.ExpressionAttributeValues[":listId"].S &&
+					strings.HasPrefix(*i["id"].S, *input.ExpressionAttributeValues[":prefix"].S) {
+					out.Items = append(out.Items, i)
+				}
+			}
+			return out, nil
+		}
+
+		from := *input.ExpressionAttributeValues[":from"].S
+		to := *input.ExpressionAttributeValues[":to"].S
+
+		for _, i := range items {
+			u, ok := i["entryUserId"]
+			if !ok || *u.S != *input.ExpressionAttributeValues[":userId"].S {
+				continue
+			}
+			if s := *i["start"].S; s >= from && s <= to {
+				out.Items = append(out.Items, i)
+			}
+		}
+		return out, nil
+	}
+
+	return m
+}
+
+var clockIn = time.Date(2019, 7, 1, 9, 0, 0, 0, time.UTC)
+
+func testStartAndStopTimer(t *testing.T) {
+
+	repo := dynamodb.NewTimeEntryRepo(timeTableMock())
+
+	e := &internal.TimeEntry{ID: "e1", ListID: "work", ToDoID: "1", UserID: "user-1", Start: clockIn}
+
+	if err := repo.Start(e); err != nil {
+		t.Fatal(err)
+	}
+
+	running, err := repo.GetRunning("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if running == nil || running.ID != "e1" || running.ToDoID != "1" || !running.Running() {
+		t.Fatalf("Expected e1 to be running, got %+v", running)
+	}
+
+	end := clockIn.Add(time.Hour)
+	running.End = &end
+
+	if err := repo.Stop(running); err != nil {
+		t.Fatal(err)
+	}
+
+	if running, err = repo.GetRunning("user-1"); err != nil || running != nil {
+		t.Fatalf("Expected no running timer, got %+v, %v", running, err)
+	}
+
+	got, err := repo.Get("work", "1", "e1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got == nil || got.End == nil || !got.End.Equal(end) {
+		t.Fatalf("Expected the entry to be stopped, got %+v", got)
+	}
+}
+
+func testStartSecondTimer(t *testing.T) {
+
+	repo := dynamodb.NewTimeEntryRepo(timeTableMock())
+
+	if err := repo.Start(&internal.TimeEntry{ID: "e1", ListID: "work", ToDoID: "1", UserID: "user-1", Start: clockIn}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := repo.Start(&internal.TimeEntry{ID: "e2", ListID: "work", ToDoID: "2", UserID: "user-1", Start: clockIn})
+	if errors.Cause(err) != database.ErrConflict {
+		t.Fatalf("Expected %v, got %v", database.ErrConflict, err)
+	}
+
+	if got, _ := repo.Get("work", "2", "e2"); got != nil {
+		t.Fatal("Expected the second timer not to be saved")
+	}
+
+	if err := repo.Start(&internal.TimeEntry{ID: "e3", ListID: "work", ToDoID: "2", UserID: "user-2", Start: clockIn}); err != nil {
+		t.Fatalf("Expected another user to start a timer, got %v", err)
+	}
+}
+
+func testStopTimerNotRunning(t *testing.T) {
+
+	repo := dynamodb.NewTimeEntryRepo(timeTableMock())
+
+	end := clockIn.Add(time.Hour)
+
+	err := repo.Stop(&internal.TimeEntry{ID: "e1", ListID: "work", ToDoID: "1", UserID: "user-1", Start: clockIn, End: &end})
+	if errors.Cause(err) != database.ErrConflict {
+		t.Fatalf("Expected %v, got %v", database.ErrConflict, err)
+	}
+}
+
+func testGetTimeEntriesByToDo(t *testing.T) {
+
+	repo := dynamodb.NewTimeEntryRepo(timeTableMock())
+
+	for i, id := range []string{"e2", "e1"} {
+		end := clockIn.Add(time.Duration(2-i) * time.Hour)
+		start := end.Add(-time.Hour)
+		e := &internal.TimeEntry{ID: id, ListID: "work", ToDoID: "1", UserID: "user-1", Start: start, End: &end}
+		if err := repo.Save(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	end := clockIn.Add(time.Hour)
+	if err := repo.Save(&internal.TimeEntry{ID: "e3", ListID: "work", ToDoID: "2", UserID: "user-1", Start: clockIn, End: &end}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := repo.GetByToDo("work", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 || entries[0].ID != "e1" || entries[1].ID != "e2" {
+		t.Fatalf("Expected e1 and e2, oldest first, got %+v", entries)
+	}
+
+	if err := repo.Save(&internal.TimeEntry{ID: "e4", ListID: "work", ToDoID: "1", UserID: "user-1", Start: clockIn}); err == nil {
+		t.Fatal("Expected a running entry not to be saved")
+	}
+}
+
+func testGetTimeEntriesByUser(t *testing.T) {
+
+	repo := dynamodb.NewTimeEntryRepo(timeTableMock())
+
+	for i, userID := range []string{"user-1", "user-1", "user-2", "user-1"} {
+		start := clockIn.Add(time.Duration(i) * 24 * time.Hour)
+		end := start.Add(time.Hour)
+		e := &internal.TimeEntry{ID: string(rune('a' + i)), ListID: "work", ToDoID: "1", UserID: userID, Start: start, End: &end}
+		if err := repo.Save(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := repo.GetByUser("user-1", clockIn, clockIn.Add(3*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 || entries[0].ID != "a" || entries[1].ID != "b" {
+		t.Fatalf("Expected the entries of user-1 starting before to, got %+v", entries)
+	}
+}
@@ -1,38 +1,59 @@
 package dynamodb
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
 	"github.com/pkg/errors"
 	uuid "github.com/satori/go.uuid"
 )
 
-const todosTableName = "todos"
-
-// ToDoRepo represents a boltdb repository for managing todos
+// ToDoRepo represents a DynamoDB repository for managing the todos in a single list
 type ToDoRepo struct {
-	db dynamodbiface.DynamoDBAPI
+	db     dynamodbiface.DynamoDBAPI
+	retry  RetryPolicy
+	listID string
 }
 
-// NewToDoRepo returns a new ToDo repository using the given bolt database. It also creates the ToDos
-// bucket if it is not yet created on disk.
+// NewToDoRepo returns a new ToDo repository for the default list using the given DynamoDB client
 func NewToDoRepo(db dynamodbiface.DynamoDBAPI) *ToDoRepo {
-	return &ToDoRepo{db}
+	return NewToDoRepoWithRetryPolicy(db, DefaultRetryPolicy)
+}
+
+// NewToDoRepoWithRetryPolicy returns a new ToDo repository that retries throttled and transient
+// failures according to policy
+func NewToDoRepoWithRetryPolicy(db dynamodbiface.DynamoDBAPI, policy RetryPolicy) *ToDoRepo {
+	return &ToDoRepo{db: db, retry: policy, listID: DefaultListID}
+}
+
+// ForList returns a copy of the repository that manages the ToDos in the given list
+func (r *ToDoRepo) ForList(listID string) *ToDoRepo {
+	c := *r
+	c.listID = listID
+	return &c
 }
 
 // Get returns a ToDo by its ID
 func (r *ToDoRepo) Get(id string) (*internal.ToDo, error) {
 	input := &dynamodb.GetItemInput{
 		TableName: aws.String(todosTableName),
-		Key:       mapID(id),
+		Key:       mapKey(r.listID, id),
 	}
 
-	result, err := r.db.GetItem(input)
+	var result *dynamodb.GetItemOutput
+
+	err := r.retry.do(func() (err error) {
+		result, err = r.db.GetItem(input)
+		return err
+	})
 	if err != nil {
 		return nil, errors.Wrapf(err, "Could not get ToDo %s from database", id)
 	}
@@ -48,69 +69,550 @@ func (r *ToDoRepo) Get(id string) (*internal.ToDo, error) {
 		return nil, nil
 	}
 
+	if parts := checklistParts(result.Item); parts > 0 {
+		if err := r.loadChecklist(t, parts); err != nil {
+			return nil, err
+		}
+	}
+
 	return t, nil
 }
 
-// GetAll returns all ToDos
+// GetAll returns all ToDos in the list
 func (r *ToDoRepo) GetAll() ([]internal.ToDo, error) {
-
-	input := &dynamodb.ScanInput{
-		TableName: aws.String(todosTableName),
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(todosTableName),
+		KeyConditionExpression: aws.String("listId = :listId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":listId": {S: aws.String(r.listID)},
+		},
 	}
 
-	result, err := r.db.Scan(input)
+	t, err := r.query(input)
 	if err != nil {
 		return nil, errors.Wrap(err, "Could not get ToDos from database")
 	}
 
-	t := []internal.ToDo{}
+	return t, nil
+}
 
-	// Unmarshal the Items field in the result value to the Item Go type.
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &t)
+// GetByCompleted returns the ToDos in the list that are, or are not, completed, most recently modified
+// first
+func (r *ToDoRepo) GetByCompleted(completed bool) ([]internal.ToDo, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(todosTableName),
+		IndexName:              aws.String(completedIndexName),
+		KeyConditionExpression: aws.String("listStatus = :listStatus"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":listStatus": {S: aws.String(statusKey(r.listID, completed))},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	t, err := r.query(input)
 	if err != nil {
-		return nil, errors.Wrap(err, "Could not unmarshal ToDos")
+		return nil, errors.Wrap(err, "Could not get ToDos by completed from database")
 	}
 
 	return t, nil
 }
 
+// GetDueBetween returns the ToDos in the list that are due within the given range, soonest first
+func (r *ToDoRepo) GetDueBetween(from, to time.Time) ([]internal.ToDo, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(todosTableName),
+		IndexName:              aws.String(dueIndexName),
+		KeyConditionExpression: aws.String("listId = :listId AND #due BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]*string{
+			"#due": aws.String("due"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":listId": {S: aws.String(r.listID)},
+			":from":   {S: aws.String(from.UTC().Format(time.RFC3339Nano))},
+			":to":     {S: aws.String(to.UTC().Format(time.RFC3339Nano))},
+		},
+	}
+
+	t, err := r.query(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get ToDos by due date from database")
+	}
+
+	return t, nil
+}
+
+// GetByTags returns the ToDos in the list that have all of the tags, or any of them if all is false
+func (r *ToDoRepo) GetByTags(tags []string, all bool) ([]internal.ToDo, error) {
+	if len(tags) == 0 {
+		return r.GetAll()
+	}
+
+	values := map[string]*dynamodb.AttributeValue{
+		":listId": {S: aws.String(r.listID)},
+	}
+
+	conditions := []string{}
+	for i, tag := range tags {
+		name := fmt.Sprintf(":tag%d", i)
+		values[name] = &dynamodb.AttributeValue{S: aws.String(tag)}
+		conditions = append(conditions, fmt.Sprintf("contains(tags, %s)", name))
+	}
+
+	op := " OR "
+	if all {
+		op = " AND "
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(todosTableName),
+		KeyConditionExpression:    aws.String("listId = :listId"),
+		FilterExpression:          aws.String(strings.Join(conditions, op)),
+		ExpressionAttributeValues: values,
+	}
+
+	t, err := r.query(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get ToDos by tag from database")
+	}
+
+	return t, nil
+}
+
+// GetRecurring returns the recurring ToDos of every list
+func (r *ToDoRepo) GetRecurring() ([]internal.ToDo, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(todosTableName),
+		FilterExpression: aws.String("attribute_exists(rrule)"),
+	}
+
+	t := []internal.ToDo{}
+
+	for {
+		var result *dynamodb.ScanOutput
+
+		err := r.retry.do(func() (err error) {
+			result, err = r.db.Scan(input)
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not get recurring ToDos from database")
+		}
+
+		for _, item := range result.Items {
+			if isReserved(item) {
+				continue
+			}
+
+			todo := internal.ToDo{}
+
+			if err := dynamodbattribute.UnmarshalMap(item, &todo); err != nil {
+				return nil, errors.Wrap(err, "Could not unmarshal ToDo")
+			}
+
+			if parts := checklistParts(item); parts > 0 {
+				if err := r.ForList(todo.ListID).loadChecklist(&todo, parts); err != nil {
+					return nil, err
+				}
+			}
+
+			t = append(t, todo)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return t, nil
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
 // Save creates or updates a ToDo
 func (r *ToDoRepo) Save(todo *internal.ToDo) error {
+	return r.save(todo, nil)
+}
+
+// SaveIfUnmodified updates a ToDo if the stored ToDo was last modified at modTime, and returns
+// database.ErrConflict if it was not or has been deleted
+func (r *ToDoRepo) SaveIfUnmodified(todo *internal.ToDo, modTime time.Time) error {
+	return r.save(todo, &modTime)
+}
+
+// save creates or updates a ToDo. If modTime is not nil, the ToDo is only updated if it was last modified
+// at modTime.
+func (r *ToDoRepo) save(todo *internal.ToDo, modTime *time.Time) error {
 
 	if todo.ID == "" {
 		todo.ID = uuid.NewV4().String()
 	}
 
-	todo.ModTime = time.Now()
+	stamp(todo, r.listID)
 
-	t, err := dynamodbattribute.MarshalMap(todo)
+	t, err := marshalToDo(todo)
 	if err != nil {
 		return errors.Wrapf(err, "Could not unmarshal ToDo %s", todo.ID)
 	}
 
+	parts, err := splitChecklist(todo, t)
+	if err != nil {
+		return errors.Wrapf(err, "Could not split checklist of ToDo %s", todo.ID)
+	}
+
+	condition, values := unmodifiedCondition(modTime)
+
+	if len(parts) > 0 {
+		return r.saveSplit(todo, t, parts, condition, values)
+	}
+
 	input := &dynamodb.PutItemInput{
-		TableName: aws.String(todosTableName),
-		Item:      t,
+		TableName:                 aws.String(todosTableName),
+		Item:                      t,
+		ConditionExpression:       condition,
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllOld),
 	}
 
-	if _, err := r.db.PutItem(input); err != nil {
+	var result *dynamodb.PutItemOutput
+
+	err = r.retry.do(func() (err error) {
+		result, err = r.db.PutItem(input)
+		return err
+	})
+	if conditionFailed(err) {
+		return errors.Wrapf(database.ErrConflict, "ToDo %s was modified", todo.ID)
+	}
+	if err != nil {
 		return errors.Wrapf(err, "Could not save ToDo %s to database", todo.ID)
 	}
 
+	// The checklist may have been split before it shrank
+	if checklistParts(result.Attributes) > 0 {
+		if err := r.deleteChecklistParts(todo.ID, 0); err != nil {
+			return err
+		}
+	}
+
+	return r.saveAssignments(todo, assigneeIDs(result.Attributes))
+}
+
+// saveSplit saves a ToDo together with the parts its checklist was split into, then deletes the parts
+// left over from a larger checklist
+func (r *ToDoRepo) saveSplit(todo *internal.ToDo, item map[string]*dynamodb.AttributeValue, parts []map[string]*dynamodb.AttributeValue,
+	condition *string, values map[string]*dynamodb.AttributeValue) error {
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{
+				TableName:                 aws.String(todosTableName),
+				Item:                      item,
+				ConditionExpression:       condition,
+				ExpressionAttributeValues: values,
+			}},
+		},
+	}
+
+	for _, p := range parts {
+		input.TransactItems = append(input.TransactItems, &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{TableName: aws.String(todosTableName), Item: p},
+		})
+	}
+
+	err := r.retry.do(func() error {
+		_, err := r.db.TransactWriteItems(input)
+		return err
+	})
+	if conditionFailed(err) {
+		return errors.Wrapf(database.ErrConflict, "ToDo %s was modified", todo.ID)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Could not save ToDo %s to database", todo.ID)
+	}
+
+	if err := r.deleteChecklistParts(todo.ID, len(parts)); err != nil {
+		return err
+	}
+
+	// A transaction does not return the ToDo it replaced, so the assignments of users it is no longer
+	// assigned to are left to GetAssignedTo
+	return r.saveAssignments(todo, nil)
+}
+
+// SaveAll creates or updates many ToDos using batch writes. Parts left over from a checklist that was split
+// before are not read back, and are deleted with the ToDo. Likewise, the assignments of users the ToDos are
+// no longer assigned to are left to GetAssignedTo.
+func (r *ToDoRepo) SaveAll(todos []*internal.ToDo) error {
+
+	requests := []*dynamodb.WriteRequest{}
+	split := []*internal.ToDo{}
+
+	for _, todo := range todos {
+		if todo.ID == "" {
+			todo.ID = uuid.NewV4().String()
+		}
+
+		stamp(todo, r.listID)
+
+		t, err := marshalToDo(todo)
+		if err != nil {
+			return errors.Wrapf(err, "Could not unmarshal ToDo %s", todo.ID)
+		}
+
+		// ToDos with checklists that must be split are saved with their parts in a transaction, which can not
+		// be part of a batch
+		parts, err := splitChecklist(todo, t)
+		if err != nil {
+			return errors.Wrapf(err, "Could not split checklist of ToDo %s", todo.ID)
+		}
+
+		if len(parts) > 0 {
+			split = append(split, todo)
+			continue
+		}
+
+		assignments, err := assignmentRequests(todo)
+		if err != nil {
+			return errors.Wrapf(err, "Could not marshal assignments of ToDo %s", todo.ID)
+		}
+
+		requests = append(requests, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: t}})
+		requests = append(requests, assignments...)
+	}
+
+	for _, todo := range split {
+		if err := r.Save(todo); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(requests); start += batchWriteSize {
+		end := start + batchWriteSize
+		if end > len(requests) {
+			end = len(requests)
+		}
+
+		if err := batchWrite(r.db, r.retry, todosTableName, requests[start:end]); err != nil {
+			return errors.Wrap(err, "Could not save ToDos to database")
+		}
+	}
+
 	return nil
 }
 
-// Delete permanently removes a ToDo
+// Delete permanently removes a ToDo, leaving a tombstone that expires after database.TombstoneRetention
 func (r *ToDoRepo) Delete(id string) error {
 
-	input := &dynamodb.DeleteItemInput{
-		TableName: aws.String(todosTableName),
-		Key:       mapID(id),
+	now := time.Now().UTC()
+
+	tombstone, err := dynamodbattribute.MarshalMap(tombstoneItem{
+		ListID:  tombstonesListID(r.listID),
+		ID:      id,
+		Deleted: now,
+		Expires: now.Add(database.TombstoneRetention).Unix(),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not marshal tombstone of ToDo %s", id)
+	}
+
+	// Delete the ToDo and write its tombstone together, so clients never miss a delete
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Delete: &dynamodb.Delete{TableName: aws.String(todosTableName), Key: mapKey(r.listID, id)}},
+			{Put: &dynamodb.Put{TableName: aws.String(todosTableName), Item: tombstone}},
+		},
 	}
 
-	if _, err := r.db.DeleteItem(input); err != nil {
+	err = r.retry.do(func() error {
+		_, err := r.db.TransactWriteItems(input)
+		return err
+	})
+	if err != nil {
 		return errors.Wrapf(err, "Could not delete ToDo %s to database", id)
 	}
 
-	return nil
+	return r.deleteChecklistParts(id, 0)
+}
+
+// IsDeleted reports whether the ToDo with the given ID was deleted within database.TombstoneRetention
+func (r *ToDoRepo) IsDeleted(id string) (bool, error) {
+	input := &dynamodb.GetItemInput{
+		TableName:            aws.String(todosTableName),
+		Key:                  mapKey(tombstonesListID(r.listID), id),
+		ProjectionExpression: aws.String("id"),
+	}
+
+	var result *dynamodb.GetItemOutput
+
+	err := r.retry.do(func() (err error) {
+		result, err = r.db.GetItem(input)
+		return err
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "Could not get tombstone of ToDo %s from database", id)
+	}
+
+	return len(result.Item) > 0, nil
+}
+
+// GetChangedSince returns the ToDos in the list modified after since, and the tombstones of those deleted
+// after since
+func (r *ToDoRepo) GetChangedSince(since time.Time) ([]internal.ToDo, []internal.Tombstone, error) {
+
+	changed := []internal.ToDo{}
+
+	// The completed index is sorted by modTime, so both completed states are queried from since
+	for _, completed := range []bool{false, true} {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(todosTableName),
+			IndexName:              aws.String(completedIndexName),
+			KeyConditionExpression: aws.String("listStatus = :listStatus AND modTime > :since"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":listStatus": {S: aws.String(statusKey(r.listID, completed))},
+				":since":      {S: aws.String(sortableFloor(since))},
+			},
+		}
+
+		t, err := r.query(input)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Could not get changed ToDos from database")
+		}
+
+		for _, todo := range t {
+			if todo.ModTime.After(since) {
+				changed = append(changed, todo)
+			}
+		}
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(todosTableName),
+		KeyConditionExpression: aws.String("listId = :listId"),
+		FilterExpression:       aws.String("deleted > :since"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":listId": {S: aws.String(tombstonesListID(r.listID))},
+			":since":  {S: aws.String(sortableFloor(since))},
+		},
+	}
+
+	tombstones := []internal.Tombstone{}
+
+	for {
+		var result *dynamodb.QueryOutput
+
+		err := r.retry.do(func() (err error) {
+			result, err = r.db.Query(input)
+			return err
+		})
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Could not get tombstones from database")
+		}
+
+		page := []tombstoneItem{}
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, nil, errors.Wrap(err, "Could not unmarshal tombstones")
+		}
+
+		for _, item := range page {
+			// Expired items are removed by TTL some time after they expire
+			if item.Deleted.After(since) && time.Unix(item.Expires, 0).After(time.Now()) {
+				tombstones = append(tombstones, internal.Tombstone{ID: item.ID, ListID: r.listID, Deleted: item.Deleted})
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return changed, tombstones, nil
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// query runs input and follows LastEvaluatedKey until every page has been read
+func (r *ToDoRepo) query(input *dynamodb.QueryInput) ([]internal.ToDo, error) {
+	t := []internal.ToDo{}
+
+	for {
+		var result *dynamodb.QueryOutput
+
+		err := r.retry.do(func() (err error) {
+			result, err = r.db.Query(input)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		page := []internal.ToDo{}
+
+		// Unmarshal the Items field in the result value to the Item Go type.
+		err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &page)
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not unmarshal ToDos")
+		}
+
+		for i := range page {
+			if parts := checklistParts(result.Items[i]); parts > 0 {
+				if err := r.loadChecklist(&page[i], parts); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		t = append(t, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return t, nil
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// stamp sets the fields of a ToDo that are managed by the repository before it is saved
+func stamp(todo *internal.ToDo, listID string) {
+	todo.ListID = listID
+	todo.ModTime = time.Now().UTC()
+
+	if todo.Created.IsZero() {
+		todo.Created = todo.ModTime
+	}
+}
+
+// unmodifiedCondition returns the condition expression, and its values, that a ToDo was last modified at
+// modTime, or none if modTime is nil
+func unmodifiedCondition(modTime *time.Time) (*string, map[string]*dynamodb.AttributeValue) {
+	if modTime == nil {
+		return nil, nil
+	}
+
+	return aws.String("modTime = :modTime"), map[string]*dynamodb.AttributeValue{
+		":modTime": {S: aws.String(modTime.UTC().Format(time.RFC3339Nano))},
+	}
+}
+
+// conditionFailed reports whether err is the failure of the condition of a write, or of a transaction
+// because of one
+func conditionFailed(err error) bool {
+	aerr, ok := errors.Cause(err).(awserr.Error)
+	if !ok {
+		return false
+	}
+
+	return aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException ||
+		aerr.Code() == dynamodb.ErrCodeTransactionCanceledException && strings.Contains(aerr.Message(), "ConditionalCheckFailed")
+}
+
+// marshalToDo returns the item stored for todo, including the attributes derived for indexes
+func marshalToDo(todo *internal.ToDo) (map[string]*dynamodb.AttributeValue, error) {
+	if todo.Due != nil {
+		due := todo.Due.UTC()
+		todo.Due = &due
+	}
+
+	item, err := dynamodbattribute.MarshalMap(todo)
+	if err != nil {
+		return nil, err
+	}
+
+	item["listStatus"] = &dynamodb.AttributeValue{S: aws.String(statusKey(todo.ListID, todo.Completed))}
+
+	return item, nil
 }
@@ -5,10 +5,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
 	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+	pkgerrors "github.com/pkg/errors"
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -23,8 +26,30 @@ func TestToDoRepo(t *testing.T) {
 	t.Run("CreateToDo", testCreateToDo)
 	t.Run("CreateToDoError", testCreateToDoError)
 	t.Run("UpdateToDo", testUpdateToDo)
+	t.Run("SaveIfUnmodified", testSaveIfUnmodified)
 	t.Run("DeleteToDo", testDeleteToDo)
 	t.Run("DeleteToDoError", testDeleteToDoError)
+	t.Run("RetryThrottled", testRetryThrottled)
+	t.Run("RetryExhausted", testRetryExhausted)
+	t.Run("RetryServerError", testRetryServerError)
+	t.Run("NoRetryValidationError", testNoRetryValidationError)
+	t.Run("GetAllToDosPaginated", testGetAllToDosPaginated)
+	t.Run("GetToDosByCompleted", testGetToDosByCompleted)
+	t.Run("GetToDosDueBetween", testGetToDosDueBetween)
+	t.Run("GetRecurringToDos", testGetRecurringToDos)
+	t.Run("GetToDosByTags", testGetToDosByTags)
+	t.Run("SaveToDoTags", testSaveToDoTags)
+	t.Run("SaveToDoKeys", testSaveToDoKeys)
+	t.Run("SaveAllToDos", testSaveAllToDos)
+	t.Run("GetChangedSince", testGetChangedSince)
+}
+
+var testRetryPolicy = dynamodb.RetryPolicy{
+	InitialInterval: time.Millisecond,
+	MaxInterval:     2 * time.Millisecond,
+	MaxElapsedTime:  20 * time.Millisecond,
+	Multiplier:      2,
+	Jitter:          0.5,
 }
 
 func testGetToDoFound(t *testing.T) {
@@ -120,7 +145,7 @@ func testGetAllToDos(t *testing.T) {
 
 	m := &ClientMock{}
 
-	m.ScanFn = func(*awsdynamodb.ScanInput) (*awsdynamodb.ScanOutput, error) {
+	m.QueryFn = func(*awsdynamodb.QueryInput) (*awsdynamodb.QueryOutput, error) {
 
 		item1, err := dynamodbattribute.MarshalMap(internal.ToDo{
 			ID:      "99211782-158f-4ccc-99fc-812a583c7e9d",
@@ -151,7 +176,7 @@ func testGetAllToDos(t *testing.T) {
 
 		items := []map[string]*awsdynamodb.AttributeValue{item1, item2, item3}
 
-		out := &awsdynamodb.ScanOutput{
+		out := &awsdynamodb.QueryOutput{
 			Items: items,
 		}
 
@@ -169,8 +194,8 @@ func testGetAllToDos(t *testing.T) {
 		t.Fatal("Expected 3 ToDos in result")
 	}
 
-	if !m.ScanInvoked {
-		t.Fatal("Scan not invoked")
+	if !m.QueryInvoked {
+		t.Fatal("Query not invoked")
 	}
 }
 
@@ -178,7 +203,7 @@ func testGetAllToDosError(t *testing.T) {
 
 	m := &ClientMock{}
 
-	m.ScanFn = func(*awsdynamodb.ScanInput) (*awsdynamodb.ScanOutput, error) {
+	m.QueryFn = func(*awsdynamodb.QueryInput) (*awsdynamodb.QueryOutput, error) {
 		return nil, errors.New("DB Error")
 	}
 
@@ -189,8 +214,8 @@ func testGetAllToDosError(t *testing.T) {
 		t.Fatal("Expected Error")
 	}
 
-	if !m.ScanInvoked {
-		t.Fatal("Scan not invoked")
+	if !m.QueryInvoked {
+		t.Fatal("Query not invoked")
 	}
 
 }
@@ -264,6 +289,28 @@ func testCreateToDoError(t *testing.T) {
 
 }
 
+func testSaveIfUnmodified(t *testing.T) {
+
+	read := time.Date(2019, 7, 1, 9, 0, 0, 123, time.UTC)
+
+	m := &ClientMock{}
+
+	m.PutItemFn = func(input *awsdynamodb.PutItemInput) (*awsdynamodb.PutItemOutput, error) {
+		if *input.ConditionExpression != "modTime = :modTime" ||
+			*input.ExpressionAttributeValues[":modTime"].S != "2019-07-01T09:00:00.000000123Z" {
+			t.Fatalf("Expected the ToDo to be saved if modified at %v, got %v", read, input)
+		}
+		return nil, awserr.New(awsdynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+
+	repo := dynamodb.NewToDoRepo(m)
+
+	err := repo.SaveIfUnmodified(&internal.ToDo{ID: testUUID, Title: "Updated ToDo"}, read)
+	if pkgerrors.Cause(err) != database.ErrConflict {
+		t.Fatalf("Expected %v, got %v", database.ErrConflict, err)
+	}
+}
+
 func testUpdateToDo(t *testing.T) {
 
 	id := uuid.NewV4().String()
@@ -321,24 +368,43 @@ func testDeleteToDo(t *testing.T) {
 
 	m := &ClientMock{}
 
-	m.DeleteItemFn = func(input *awsdynamodb.DeleteItemInput) (*awsdynamodb.DeleteItemOutput, error) {
+	m.TransactWriteItemsFn = func(input *awsdynamodb.TransactWriteItemsInput) (*awsdynamodb.TransactWriteItemsOutput, error) {
 
-		toDo := &internal.ToDo{
-			ID:      testUUID,
-			Title:   "Test ToDo",
-			ModTime: time.Now(),
+		if len(input.TransactItems) != 2 {
+			t.Fatalf("Expected 2 transact items, got %d", len(input.TransactItems))
 		}
 
-		item, err := dynamodbattribute.MarshalMap(toDo)
-		if err != nil {
-			t.Fatal(err)
+		del := input.TransactItems[0].Delete
+		if del == nil || *del.Key["id"].S != testUUID {
+			t.Fatalf("Expected ToDo %s to be deleted, got %v", testUUID, input.TransactItems[0])
 		}
 
-		out := &awsdynamodb.DeleteItemOutput{
-			Attributes: item,
+		put := input.TransactItems[1].Put
+		if put == nil {
+			t.Fatal("Expected a tombstone to be put")
 		}
 
-		return out, nil
+		if listID := *put.Item["listId"].S; listID != "_deleted#default" {
+			t.Fatalf("Expected tombstone in list _deleted#default, got %s", listID)
+		}
+
+		if id := *put.Item["id"].S; id != testUUID {
+			t.Fatalf("Expected tombstone of %s, got %s", testUUID, id)
+		}
+
+		if put.Item["expires"] == nil || put.Item["expires"].N == nil {
+			t.Fatal("Expected tombstone to expire")
+		}
+
+		return &awsdynamodb.TransactWriteItemsOutput{}, nil
+	}
+
+	// The ToDo has no checklist parts to delete
+	m.QueryFn = func(input *awsdynamodb.QueryInput) (*awsdynamodb.QueryOutput, error) {
+		if listID := *input.ExpressionAttributeValues[":listId"].S; listID != "_checklist#default" {
+			t.Fatalf("Expected query of list _checklist#default, got %s", listID)
+		}
+		return &awsdynamodb.QueryOutput{}, nil
 	}
 
 	repo := dynamodb.NewToDoRepo(m)
@@ -348,8 +414,8 @@ func testDeleteToDo(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if !m.DeleteItemInvoked {
-		t.Fatal("DeleteItem not invoked")
+	if !m.TransactWriteInvoked {
+		t.Fatal("TransactWriteItems not invoked")
 	}
 }
 
@@ -357,7 +423,7 @@ func testDeleteToDoError(t *testing.T) {
 
 	m := &ClientMock{}
 
-	m.DeleteItemFn = func(input *awsdynamodb.DeleteItemInput) (*awsdynamodb.DeleteItemOutput, error) {
+	m.TransactWriteItemsFn = func(input *awsdynamodb.TransactWriteItemsInput) (*awsdynamodb.TransactWriteItemsOutput, error) {
 		return nil, errors.New("DB Error")
 	}
 
@@ -368,7 +434,421 @@ func testDeleteToDoError(t *testing.T) {
 		t.Fatal("Expected Error")
 	}
 
-	if !m.DeleteItemInvoked {
-		t.Fatal("DeleteItem not invoked")
+	if !m.TransactWriteInvoked {
+		t.Fatal("TransactWriteItems not invoked")
+	}
+}
+
+func testRetryThrottled(t *testing.T) {
+
+	calls := 0
+
+	m := &ClientMock{}
+
+	m.QueryFn = func(*awsdynamodb.QueryInput) (*awsdynamodb.QueryOutput, error) {
+		calls++
+		if calls < 3 {
+			return nil, awserr.New(awsdynamodb.ErrCodeProvisionedThroughputExceededException, "Throughput exceeded", nil)
+		}
+		return &awsdynamodb.QueryOutput{}, nil
+	}
+
+	repo := dynamodb.NewToDoRepoWithRetryPolicy(m, testRetryPolicy)
+
+	if _, err := repo.GetAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("Expected Query to be invoked 3 times, got %d", calls)
+	}
+}
+
+func testRetryExhausted(t *testing.T) {
+
+	calls := 0
+
+	m := &ClientMock{}
+
+	m.PutItemFn = func(*awsdynamodb.PutItemInput) (*awsdynamodb.PutItemOutput, error) {
+		calls++
+		return nil, awserr.New(awsdynamodb.ErrCodeProvisionedThroughputExceededException, "Throughput exceeded", nil)
+	}
+
+	repo := dynamodb.NewToDoRepoWithRetryPolicy(m, testRetryPolicy)
+
+	err := repo.Save(&internal.ToDo{Title: "New ToDo"})
+	if database.Retryable(err) != database.ErrThrottled {
+		t.Fatalf("Expected %v, got %v", database.ErrThrottled, err)
+	}
+
+	aerr, ok := pkgerrors.Cause(err).(awserr.Error)
+	if !ok || aerr.Code() != awsdynamodb.ErrCodeProvisionedThroughputExceededException {
+		t.Fatalf("Expected the AWS error to be kept, got %v", err)
+	}
+
+	if calls < 2 {
+		t.Fatalf("Expected PutItem to be retried, got %d calls", calls)
+	}
+}
+
+func testRetryServerError(t *testing.T) {
+
+	calls := 0
+
+	m := &ClientMock{}
+
+	m.GetItemFn = func(*awsdynamodb.GetItemInput) (*awsdynamodb.GetItemOutput, error) {
+		calls++
+		return nil, awserr.NewRequestFailure(awserr.New("InternalServerError", "Internal error", nil), 500, "")
+	}
+
+	repo := dynamodb.NewToDoRepoWithRetryPolicy(m, testRetryPolicy)
+
+	_, err := repo.Get(testUUID)
+	if database.Retryable(err) != database.ErrUnavailable {
+		t.Fatalf("Expected %v, got %v", database.ErrUnavailable, err)
+	}
+
+	if calls < 2 {
+		t.Fatalf("Expected GetItem to be retried, got %d calls", calls)
+	}
+}
+
+func testNoRetryValidationError(t *testing.T) {
+
+	calls := 0
+
+	m := &ClientMock{}
+
+	m.TransactWriteItemsFn = func(*awsdynamodb.TransactWriteItemsInput) (*awsdynamodb.TransactWriteItemsOutput, error) {
+		calls++
+		return nil, awserr.NewRequestFailure(awserr.New("ValidationException", "Invalid key", nil), 400, "")
+	}
+
+	repo := dynamodb.NewToDoRepoWithRetryPolicy(m, testRetryPolicy)
+
+	err := repo.Delete(testUUID)
+	if err == nil {
+		t.Fatal("Expected Error")
+	}
+
+	if database.Retryable(err) != nil {
+		t.Fatalf("Expected a non-retryable error, got %v", err)
+	}
+
+	if calls != 1 {
+		t.Fatalf("Expected TransactWriteItems to be invoked once, got %d", calls)
+	}
+}
+
+func testGetAllToDosPaginated(t *testing.T) {
+
+	m := &ClientMock{}
+
+	m.QueryFn = func(input *awsdynamodb.QueryInput) (*awsdynamodb.QueryOutput, error) {
+
+		if *input.ExpressionAttributeValues[":listId"].S != "work" {
+			t.Fatalf("Expected query for list work, got %s", *input.ExpressionAttributeValues[":listId"].S)
+		}
+
+		item, err := dynamodbattribute.MarshalMap(internal.ToDo{
+			ID:      uuid.NewV4().String(),
+			ListID:  "work",
+			Title:   "Test ToDo",
+			ModTime: time.Now(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := &awsdynamodb.QueryOutput{
+			Items: []map[string]*awsdynamodb.AttributeValue{item},
+		}
+
+		if input.ExclusiveStartKey == nil {
+			out.LastEvaluatedKey = item
+		}
+
+		return out, nil
+	}
+
+	repo := dynamodb.NewToDoRepo(m).ForList("work")
+
+	toDos, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(toDos) != 2 {
+		t.Fatalf("Expected 2 ToDos in result, got %d", len(toDos))
+	}
+}
+
+func testGetToDosByCompleted(t *testing.T) {
+
+	m := &ClientMock{}
+
+	m.QueryFn = func(input *awsdynamodb.QueryInput) (*awsdynamodb.QueryOutput, error) {
+
+		if input.IndexName == nil || *input.IndexName != "completed-index" {
+			t.Fatal("Expected query on completed-index")
+		}
+
+		if *input.ExpressionAttributeValues[":listStatus"].S != "default#true" {
+			t.Fatalf("Expected listStatus default#true, got %s", *input.ExpressionAttributeValues[":listStatus"].S)
+		}
+
+		return &awsdynamodb.QueryOutput{}, nil
+	}
+
+	repo := dynamodb.NewToDoRepo(m)
+
+	if _, err := repo.GetByCompleted(true); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.ScanInvoked {
+		t.Fatal("Scan invoked")
+	}
+}
+
+func testGetToDosDueBetween(t *testing.T) {
+
+	from := time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(7 * 24 * time.Hour)
+
+	m := &ClientMock{}
+
+	m.QueryFn = func(input *awsdynamodb.QueryInput) (*awsdynamodb.QueryOutput, error) {
+
+		if input.IndexName == nil || *input.IndexName != "due-index" {
+			t.Fatal("Expected query on due-index")
+		}
+
+		if *input.ExpressionAttributeValues[":from"].S != "2019-07-01T00:00:00Z" {
+			t.Fatalf("Unexpected from %s", *input.ExpressionAttributeValues[":from"].S)
+		}
+
+		return &awsdynamodb.QueryOutput{}, nil
+	}
+
+	repo := dynamodb.NewToDoRepo(m)
+
+	if _, err := repo.GetDueBetween(from, to); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testGetRecurringToDos(t *testing.T) {
+
+	m := &ClientMock{}
+
+	m.ScanFn = func(input *awsdynamodb.ScanInput) (*awsdynamodb.ScanOutput, error) {
+
+		if *input.FilterExpression != "attribute_exists(rrule)" {
+			t.Fatalf("Unexpected filter %s", *input.FilterExpression)
+		}
+
+		todo, _ := dynamodbattribute.MarshalMap(internal.ToDo{ID: testUUID, ListID: "ops", RRule: "FREQ=WEEKLY"})
+		reserved, _ := dynamodbattribute.MarshalMap(internal.ToDo{ID: "1", ListID: "_migrations", RRule: "x"})
+
+		return &awsdynamodb.ScanOutput{Items: []map[string]*awsdynamodb.AttributeValue{todo, reserved}}, nil
+	}
+
+	repo := dynamodb.NewToDoRepo(m)
+
+	todos, err := repo.GetRecurring()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(todos) != 1 || todos[0].ID != testUUID || todos[0].ListID != "ops" {
+		t.Fatalf("Expected the recurring ToDo in list ops, got %v", todos)
+	}
+}
+
+func testGetToDosByTags(t *testing.T) {
+
+	for all, want := range map[bool]string{
+		true:  "contains(tags, :tag0) AND contains(tags, :tag1)",
+		false: "contains(tags, :tag0) OR contains(tags, :tag1)",
+	} {
+		m := &ClientMock{}
+
+		m.QueryFn = func(input *awsdynamodb.QueryInput) (*awsdynamodb.QueryOutput, error) {
+
+			if *input.FilterExpression != want {
+				t.Fatalf("Expected filter %s, got %s", want, *input.FilterExpression)
+			}
+
+			if *input.ExpressionAttributeValues[":tag1"].S != "ops" {
+				t.Fatalf("Unexpected tag %s", *input.ExpressionAttributeValues[":tag1"].S)
+			}
+
+			return &awsdynamodb.QueryOutput{}, nil
+		}
+
+		repo := dynamodb.NewToDoRepo(m)
+
+		if _, err := repo.GetByTags([]string{"infra", "ops"}, all); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testSaveToDoTags(t *testing.T) {
+
+	m := &ClientMock{}
+
+	m.PutItemFn = func(input *awsdynamodb.PutItemInput) (*awsdynamodb.PutItemOutput, error) {
+
+		if tags := input.Item["tags"]; tags == nil || len(tags.SS) != 2 {
+			t.Fatalf("Expected tags to be a string set, got %v", tags)
+		}
+
+		return &awsdynamodb.PutItemOutput{}, nil
+	}
+
+	repo := dynamodb.NewToDoRepo(m)
+
+	if err := repo.Save(&internal.ToDo{ID: testUUID, Title: "Rotate certs", Tags: []string{"infra", "ops"}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testSaveToDoKeys(t *testing.T) {
+
+	m := &ClientMock{}
+
+	m.PutItemFn = func(input *awsdynamodb.PutItemInput) (*awsdynamodb.PutItemOutput, error) {
+
+		if *input.Item["listId"].S != "work" {
+			t.Fatalf("Expected listId work, got %s", *input.Item["listId"].S)
+		}
+
+		if *input.Item["listStatus"].S != "work#false" {
+			t.Fatalf("Expected listStatus work#false, got %s", *input.Item["listStatus"].S)
+		}
+
+		if _, ok := input.Item["due"]; ok {
+			t.Fatal("Expected due to be omitted")
+		}
+
+		return &awsdynamodb.PutItemOutput{}, nil
+	}
+
+	repo := dynamodb.NewToDoRepo(m).ForList("work")
+
+	toDo := &internal.ToDo{Title: "New ToDo"}
+
+	if err := repo.Save(toDo); err != nil {
+		t.Fatal(err)
+	}
+
+	if toDo.ListID != "work" {
+		t.Fatalf("Expected ToDo to have ListID work, got %s", toDo.ListID)
+	}
+}
+
+func testSaveAllToDos(t *testing.T) {
+
+	batches := 0
+	written := 0
+
+	m := &ClientMock{}
+
+	m.BatchWriteItemFn = func(input *awsdynamodb.BatchWriteItemInput) (*awsdynamodb.BatchWriteItemOutput, error) {
+		batches++
+		written += len(input.RequestItems["todos-v2"])
+		return &awsdynamodb.BatchWriteItemOutput{}, nil
+	}
+
+	repo := dynamodb.NewToDoRepo(m)
+
+	toDos := []*internal.ToDo{}
+	for i := 0; i < 30; i++ {
+		toDos = append(toDos, &internal.ToDo{Title: "New ToDo"})
+	}
+
+	if err := repo.SaveAll(toDos); err != nil {
+		t.Fatal(err)
+	}
+
+	if batches != 2 || written != 30 {
+		t.Fatalf("Expected 30 ToDos in 2 batches, got %d in %d", written, batches)
+	}
+
+	for _, toDo := range toDos {
+		if toDo.ID == "" || toDo.ListID != dynamodb.DefaultListID {
+			t.Fatalf("Expected ToDo to have an ID and list, got %v", toDo)
+		}
+	}
+}
+
+func testGetChangedSince(t *testing.T) {
+
+	since := time.Now().Add(-time.Hour)
+
+	m := &ClientMock{}
+
+	m.QueryFn = func(input *awsdynamodb.QueryInput) (*awsdynamodb.QueryOutput, error) {
+
+		if input.IndexName == nil {
+			if listID := *input.ExpressionAttributeValues[":listId"].S; listID != "_deleted#default" {
+				t.Fatalf("Expected tombstones query on _deleted#default, got %s", listID)
+			}
+
+			items := []map[string]*awsdynamodb.AttributeValue{}
+			for _, expires := range []time.Time{time.Now().Add(time.Hour), time.Now().Add(-time.Hour)} {
+				item, err := dynamodbattribute.MarshalMap(map[string]interface{}{
+					"listId":  "_deleted#default",
+					"id":      uuid.NewV4().String(),
+					"deleted": time.Now().Add(-time.Minute),
+					"expires": expires.Unix(),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				items = append(items, item)
+			}
+
+			return &awsdynamodb.QueryOutput{Items: items}, nil
+		}
+
+		if *input.IndexName != "completed-index" {
+			t.Fatalf("Expected query on completed-index, got %s", *input.IndexName)
+		}
+
+		// The query is inclusive of the second before since, so older ToDos are filtered out
+		items := []map[string]*awsdynamodb.AttributeValue{}
+		for _, modTime := range []time.Time{since.Add(time.Minute), since.Add(-time.Millisecond)} {
+			item, err := dynamodbattribute.MarshalMap(&internal.ToDo{ID: uuid.NewV4().String(), ModTime: modTime})
+			if err != nil {
+				t.Fatal(err)
+			}
+			items = append(items, item)
+		}
+
+		return &awsdynamodb.QueryOutput{Items: items}, nil
+	}
+
+	repo := dynamodb.NewToDoRepo(m)
+
+	todos, tombstones, err := repo.GetChangedSince(since)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(todos) != 2 {
+		t.Fatalf("Expected 2 changed ToDos, got %d", len(todos))
+	}
+
+	if len(tombstones) != 1 {
+		t.Fatalf("Expected 1 unexpired tombstone, got %d", len(tombstones))
+	}
+
+	if tombstones[0].ListID != dynamodb.DefaultListID {
+		t.Fatalf("Expected tombstone in list %s, got %s", dynamodb.DefaultListID, tombstones[0].ListID)
 	}
 }
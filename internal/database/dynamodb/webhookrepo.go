@@ -0,0 +1,338 @@
+package dynamodb
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+const (
+	// webhooksListID is the partition key of the items that look up a webhook by its ID
+	webhooksListID = reservedPrefix + "webhooks"
+	// webhooksPrefix starts the partition keys of the webhooks subscribed to a list, which are followed by
+	// the list ID
+	webhooksPrefix = webhooksListID + "#"
+	// deliveriesPrefix starts the partition keys of the deliveries of a webhook, which are followed by the
+	// webhook ID
+	deliveriesPrefix = reservedPrefix + "deliveries#"
+	// deliveryRetention is how long the log of successful deliveries is kept
+	deliveryRetention = 7 * 24 * time.Hour
+	// deadLetterRetention is how long dead-lettered deliveries are kept to be sent again
+	deadLetterRetention = 30 * 24 * time.Hour
+)
+
+// webhookItem is how a Webhook is stored, both in the partition of its list and in the lookup partition
+type webhookItem struct {
+	ListID     string    `json:"listId"`
+	ID         string    `json:"id"`
+	HookListID string    `json:"hookListId"`
+	UserID     string    `json:"userId"`
+	URL        string    `json:"url"`
+	Events     []string  `json:"events,omitempty"`
+	Secret     string    `json:"secret"`
+	Created    time.Time `json:"created"`
+}
+
+// webhook returns the Webhook stored in item
+func (item webhookItem) webhook() internal.Webhook {
+	return internal.Webhook{
+		ID:      item.ID,
+		UserID:  item.UserID,
+		ListID:  item.HookListID,
+		URL:     item.URL,
+		Events:  item.Events,
+		Secret:  item.Secret,
+		Created: item.Created,
+	}
+}
+
+// deliveryItem is how a Delivery is stored in the partition of its webhook. Expires is the TTL attribute,
+// in Unix seconds.
+type deliveryItem struct {
+	ListID string `json:"listId"`
+	internal.Delivery
+	Expires int64 `json:"expires"`
+}
+
+// WebhookRepo represents a DynamoDB repository for managing webhooks and their deliveries
+type WebhookRepo struct {
+	db    dynamodbiface.DynamoDBAPI
+	retry RetryPolicy
+}
+
+// NewWebhookRepo returns a new Webhook repository using the given DynamoDB client
+func NewWebhookRepo(db dynamodbiface.DynamoDBAPI) *WebhookRepo {
+	return &WebhookRepo{db: db, retry: DefaultRetryPolicy}
+}
+
+// Get returns a Webhook by its ID
+func (r *WebhookRepo) Get(id string) (*internal.Webhook, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(todosTableName),
+		Key:       mapKey(webhooksListID, id),
+	}
+
+	var result *dynamodb.GetItemOutput
+
+	err := r.retry.do(func() (err error) {
+		result, err = r.db.GetItem(input)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not get webhook %s from database", id)
+	}
+
+	item := webhookItem{}
+
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, errors.Wrapf(err, "Could not unmarshal webhook %s", id)
+	}
+
+	if item.ID == "" {
+		return nil, nil
+	}
+
+	w := item.webhook()
+
+	return &w, nil
+}
+
+// GetByList returns the webhooks subscribed to a list
+func (r *WebhookRepo) GetByList(listID string) ([]internal.Webhook, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(todosTableName),
+		KeyConditionExpression: aws.String("listId = :listId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":listId": {S: aws.String(webhooksPrefix + listID)},
+		},
+	}
+
+	webhooks := []internal.Webhook{}
+
+	for {
+		var result *dynamodb.QueryOutput
+
+		err := r.retry.do(func() (err error) {
+			result, err = r.db.Query(input)
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not get webhooks of list %s from database", listID)
+		}
+
+		page := []webhookItem{}
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, errors.Wrap(err, "Could not unmarshal webhooks")
+		}
+
+		for _, item := range page {
+			webhooks = append(webhooks, item.webhook())
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return webhooks, nil
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// Save creates or updates a webhook. The list of a webhook can not be changed.
+func (r *WebhookRepo) Save(webhook *internal.Webhook) error {
+	if webhook.Created.IsZero() {
+		webhook.Created = time.Now().UTC()
+	}
+
+	item := webhookItem{
+		ListID:     webhooksPrefix + webhook.ListID,
+		ID:         webhook.ID,
+		HookListID: webhook.ListID,
+		UserID:     webhook.UserID,
+		URL:        webhook.URL,
+		Events:     webhook.Events,
+		Secret:     webhook.Secret,
+		Created:    webhook.Created,
+	}
+
+	subscription, err := dynamodbattribute.MarshalMap(item)
+	if err != nil {
+		return errors.Wrapf(err, "Could not marshal webhook %s", webhook.ID)
+	}
+
+	item.ListID = webhooksListID
+
+	lookup, err := dynamodbattribute.MarshalMap(item)
+	if err != nil {
+		return errors.Wrapf(err, "Could not marshal webhook %s", webhook.ID)
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{TableName: aws.String(todosTableName), Item: subscription}},
+			{Put: &dynamodb.Put{TableName: aws.String(todosTableName), Item: lookup}},
+		},
+	}
+
+	err = r.retry.do(func() error {
+		_, err := r.db.TransactWriteItems(input)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not save webhook %s to database", webhook.ID)
+	}
+
+	return nil
+}
+
+// Delete removes a webhook. Its deliveries expire with their retention. Deleting a webhook that does not
+// exist is not an error.
+func (r *WebhookRepo) Delete(id string) error {
+	w, err := r.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if w == nil {
+		return nil
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Delete: &dynamodb.Delete{TableName: aws.String(todosTableName), Key: mapKey(webhooksPrefix+w.ListID, id)}},
+			{Delete: &dynamodb.Delete{TableName: aws.String(todosTableName), Key: mapKey(webhooksListID, id)}},
+		},
+	}
+
+	err = r.retry.do(func() error {
+		_, err := r.db.TransactWriteItems(input)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not delete webhook %s from database", id)
+	}
+
+	return nil
+}
+
+// GetDelivery returns a delivery of a webhook by its ID
+func (r *WebhookRepo) GetDelivery(webhookID, id string) (*internal.Delivery, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(todosTableName),
+		Key:       mapKey(deliveriesPrefix+webhookID, id),
+	}
+
+	var result *dynamodb.GetItemOutput
+
+	err := r.retry.do(func() (err error) {
+		result, err = r.db.GetItem(input)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not get delivery %s from database", id)
+	}
+
+	item := deliveryItem{}
+
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, errors.Wrapf(err, "Could not unmarshal delivery %s", id)
+	}
+
+	if item.ID == "" {
+		return nil, nil
+	}
+
+	return &item.Delivery, nil
+}
+
+// GetDeliveries returns the deliveries of a webhook, most recent first
+func (r *WebhookRepo) GetDeliveries(webhookID string) ([]internal.Delivery, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(todosTableName),
+		KeyConditionExpression: aws.String("listId = :listId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":listId": {S: aws.String(deliveriesPrefix + webhookID)},
+		},
+	}
+
+	deliveries := []internal.Delivery{}
+
+	for {
+		var result *dynamodb.QueryOutput
+
+		err := r.retry.do(func() (err error) {
+			result, err = r.db.Query(input)
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not get deliveries of webhook %s from database", webhookID)
+		}
+
+		page := []deliveryItem{}
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, errors.Wrap(err, "Could not unmarshal deliveries")
+		}
+
+		for _, item := range page {
+			// Expired items are removed by TTL some time after they expire
+			if time.Unix(item.Expires, 0).After(time.Now()) {
+				deliveries = append(deliveries, item.Delivery)
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].Created.After(deliveries[j].Created)
+	})
+
+	return deliveries, nil
+}
+
+// SaveDelivery stores a delivery. Dead-lettered deliveries are kept for longer than successful ones.
+func (r *WebhookRepo) SaveDelivery(delivery *internal.Delivery) error {
+	if delivery.Created.IsZero() {
+		delivery.Created = time.Now().UTC()
+	}
+
+	retention := deliveryRetention
+	if delivery.Status == internal.DeliveryDeadLettered {
+		retention = deadLetterRetention
+	}
+
+	item, err := dynamodbattribute.MarshalMap(deliveryItem{
+		ListID:   deliveriesPrefix + delivery.WebhookID,
+		Delivery: *delivery,
+		Expires:  delivery.Created.Add(retention).Unix(),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not marshal delivery %s", delivery.ID)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(todosTableName),
+		Item:      item,
+	}
+
+	err = r.retry.do(func() error {
+		_, err := r.db.PutItem(input)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Could not save delivery %s to database", delivery.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package dynamodb_test
+
+import (
+	"testing"
+	"time"
+
+	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+)
+
+func TestWebhookRepo(t *testing.T) {
+	t.Run("SaveGetAndDeleteWebhook", testSaveGetAndDeleteWebhook)
+	t.Run("GetWebhookNotFound", testGetWebhookNotFound)
+	t.Run("SaveAndGetDeliveries", testSaveAndGetDeliveries)
+}
+
+// webhookTableMock returns a ClientMock that keeps items in memory, including those written with PutItem
+func webhookTableMock() *ClientMock {
+	m, items := connectionTableMock()
+
+	m.PutItemFn = func(input *awsdynamodb.PutItemInput) (*awsdynamodb.PutItemOutput, error) {
+		items[*input.Item["listId"].S+"/"+*input.Item["id"].S] = input.Item
+		return &awsdynamodb.PutItemOutput{}, nil
+	}
+
+	return m
+}
+
+func testSaveGetAndDeleteWebhook(t *testing.T) {
+
+	repo := dynamodb.NewWebhookRepo(webhookTableMock())
+
+	w := &internal.Webhook{
+		ID:     "hook-1",
+		UserID: "user-1",
+		ListID: "work",
+		URL:    "https://example.com/hook",
+		Events: []string{"Completed"},
+		Secret: "secret",
+	}
+
+	if err := repo.Save(w); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Created.IsZero() {
+		t.Fatal("Expected Webhook to have a not zero Created")
+	}
+
+	got, err := repo.Get("hook-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got == nil || got.ListID != "work" || got.URL != w.URL || got.Secret != "secret" || !got.Subscribes("Completed") ||
+		got.Subscribes("Created") {
+		t.Fatalf("Unexpected Webhook %+v", got)
+	}
+
+	byList, err := repo.GetByList("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(byList) != 1 || byList[0].ID != "hook-1" {
+		t.Fatalf("Expected webhook hook-1 for list work, got %+v", byList)
+	}
+
+	if err := repo.Delete("hook-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := repo.Get("hook-1"); got != nil {
+		t.Fatal("Expected Webhook to be deleted")
+	}
+
+	if byList, _ := repo.GetByList("work"); len(byList) != 0 {
+		t.Fatalf("Expected no webhooks for list work, got %d", len(byList))
+	}
+}
+
+func testGetWebhookNotFound(t *testing.T) {
+
+	got, err := dynamodb.NewWebhookRepo(webhookTableMock()).Get("missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != nil {
+		t.Fatal("Expected Webhook to be nil")
+	}
+}
+
+func testSaveAndGetDeliveries(t *testing.T) {
+
+	repo := dynamodb.NewWebhookRepo(webhookTableMock())
+
+	now := time.Now().UTC()
+
+	deliveries := []*internal.Delivery{
+		{ID: "e1:Created", WebhookID: "hook-1", Event: "Created", Status: internal.DeliverySucceeded, Attempts: 1,
+			Created: now.Add(-time.Minute)},
+		{ID: "e2:Completed", WebhookID: "hook-1", Event: "Completed", Status: internal.DeliveryDeadLettered,
+			Attempts: 5, StatusCode: 500, Payload: "{}", Created: now},
+		{ID: "e1:Created", WebhookID: "hook-2", Event: "Created", Status: internal.DeliverySucceeded, Attempts: 1},
+	}
+
+	for _, d := range deliveries {
+		if err := repo.SaveDelivery(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := repo.GetDeliveries("hook-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 deliveries, got %d", len(got))
+	}
+
+	if got[0].ID != "e2:Completed" || got[0].StatusCode != 500 || got[0].Payload != "{}" || got[1].ID != "e1:Created" {
+		t.Fatalf("Expected most recent delivery first, got %+v", got)
+	}
+
+	d, err := repo.GetDelivery("hook-2", "e1:Created")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d == nil || d.WebhookID != "hook-2" {
+		t.Fatalf("Unexpected delivery %+v", d)
+	}
+
+	if d, _ := repo.GetDelivery("hook-2", "e2:Completed"); d != nil {
+		t.Fatalf("Expected delivery to be nil, got %+v", d)
+	}
+}
@@ -0,0 +1,56 @@
+package database
+
+import "github.com/pkg/errors"
+
+var (
+	// ErrThrottled is returned when the database rejected a request because its throughput was exceeded
+	ErrThrottled = errors.New("throttled")
+	// ErrUnavailable is returned when the database could not be reached or failed with a transient error
+	ErrUnavailable = errors.New("unavailable")
+	// ErrConflict is returned when a write conflicts with what is stored, such as starting a timer while
+	// another is running
+	ErrConflict = errors.New("conflict")
+	// ErrNotSupported is returned by repository decorators when the repository they wrap does not support
+	// an operation
+	ErrNotSupported = errors.New("not supported")
+)
+
+// RetryError is returned when a request failed with a throttling or transient error and retrying it did
+// not succeed. Kind is ErrThrottled or ErrUnavailable and Err is the error of the last attempt, which
+// errors.Cause returns so the error of the database client can still be inspected.
+type RetryError struct {
+	Kind error
+	Err  error
+}
+
+func (e *RetryError) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Cause returns the error of the last attempt
+func (e *RetryError) Cause() error {
+	return e.Err
+}
+
+// Retryable returns ErrThrottled or ErrUnavailable if err is, or wraps, one of them or a RetryError, or nil
+// if the request that failed with err should not be retried
+func Retryable(err error) error {
+	for err != nil {
+		if e, ok := err.(*RetryError); ok {
+			return e.Kind
+		}
+
+		if err == ErrThrottled || err == ErrUnavailable {
+			return err
+		}
+
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			return nil
+		}
+
+		err = c.Cause()
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package flatfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Format is the encoding used when writing files
+type Format int
+
+const (
+	// JSON writes files as indented JSON with a .json extension
+	JSON Format = iota
+	// YAML writes files as YAML with a .yaml extension
+	YAML
+)
+
+// lockFileName is the name of the file used to coordinate access between processes
+const lockFileName = ".todo.lock"
+
+// tombstonesDirName is the directory holding the tombstones of deleted ToDos. It is hidden so it is not
+// mistaken for a ToDo.
+const tombstonesDirName = ".deleted"
+
+// extensions lists every file extension that is read, regardless of the configured Format
+var extensions = []string{".json", ".yaml", ".yml"}
+
+// ErrInvalidID is returned when an ID can not be used as a file name
+var ErrInvalidID = errors.New("invalid id")
+
+// MalformedFileError is returned when a file on disk can not be decoded, usually because it was edited by hand
+type MalformedFileError struct {
+	Path string
+	Err  error
+}
+
+func (e *MalformedFileError) Error() string {
+	return fmt.Sprintf("malformed file %s: %s", e.Path, e.Err)
+}
+
+// ext returns the file extension written for the Format
+func (f Format) ext() string {
+	if f == YAML {
+		return ".yaml"
+	}
+	return ".json"
+}
+
+// marshal encodes v using the Format
+func (f Format) marshal(v interface{}) ([]byte, error) {
+	if f == YAML {
+		return yaml.Marshal(v)
+	}
+
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+// unmarshal decodes the file at path into v based on its extension
+func unmarshal(path string, v interface{}) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		err = yaml.UnmarshalStrict(b, v)
+	} else {
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		err = d.Decode(v)
+	}
+
+	if err != nil {
+		return &MalformedFileError{Path: path, Err: err}
+	}
+
+	return nil
+}
+
+// writeFile atomically replaces the file at path by writing to a temporary file in the same directory
+// and renaming it into place
+func writeFile(path string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name()) // nolint: errcheck
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close() // nolint: errcheck
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close() // nolint: errcheck
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// validID reports whether id is safe to use as a file name
+func validID(id string) bool {
+	return id != "" && id != "." && id != ".." && !strings.ContainsAny(id, `/\`) && !strings.HasPrefix(id, ".")
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+// +build !windows
+
+package flatfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// lock acquires an advisory lock on the file at path, blocking until it is available. Shared locks
+// may be held by several processes at once while an exclusive lock is held by only one.
+func lock(path string, exclusive bool) (func() error, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close() // nolint: errcheck
+		return nil, err
+	}
+
+	return func() error {
+		defer f.Close() // nolint: errcheck
+		return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	}, nil
+}
@@ -0,0 +1,30 @@
+package flatfile
+
+import (
+	"os"
+	"sync"
+)
+
+// mu stands in for advisory file locks, which are not available on windows. It only protects
+// against concurrent access from within the same process.
+var mu sync.RWMutex
+
+// lock acquires an in-process lock and ensures the lock file at path exists
+func lock(path string, exclusive bool) (func() error, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	if exclusive {
+		mu.Lock()
+		return func() error { mu.Unlock(); return nil }, nil
+	}
+
+	mu.RLock()
+	return func() error { mu.RUnlock(); return nil }, nil
+}
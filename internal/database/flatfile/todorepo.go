@@ -0,0 +1,406 @@
+package flatfile
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// ToDoRepo represents a repository that stores each ToDo as a file in a directory, making it suitable
+// for todo lists that are checked into git
+type ToDoRepo struct {
+	dir    string
+	format Format
+}
+
+// NewToDoRepo returns a new ToDo repository using the given directory. The directory is created if it
+// does not exist. New and updated ToDos are written using format, but files in any supported format are
+// read.
+func NewToDoRepo(dir string, format Format) (*ToDoRepo, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "Could not create directory %s", dir)
+	}
+
+	return &ToDoRepo{dir: dir, format: format}, nil
+}
+
+// Get returns a ToDo by its ID
+func (r *ToDoRepo) Get(id string) (*internal.ToDo, error) {
+	if !validID(id) {
+		return nil, nil
+	}
+
+	unlock, err := r.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock() // nolint: errcheck
+
+	path, err := r.find(id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not get ToDo %s from disk", id)
+	}
+
+	if path == "" {
+		return nil, nil
+	}
+
+	return r.read(path)
+}
+
+// GetAll returns all ToDos. Files that can not be decoded are logged and skipped, so one file broken by
+// hand does not hide every other ToDo; Get returns their MalformedFileError.
+func (r *ToDoRepo) GetAll() ([]internal.ToDo, error) {
+	unlock, err := r.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock() // nolint: errcheck
+
+	files, err := ioutil.ReadDir(r.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get ToDos from disk")
+	}
+
+	t := []internal.ToDo{}
+
+	for _, f := range files {
+		if f.IsDir() || !isToDoFile(f.Name()) {
+			continue
+		}
+
+		todo, err := r.read(filepath.Join(r.dir, f.Name()))
+		if merr, ok := err.(*MalformedFileError); ok {
+			log.Printf("Skipping %v", merr)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		t = append(t, *todo)
+	}
+
+	return t, nil
+}
+
+// GetRecurring returns the recurring ToDos
+func (r *ToDoRepo) GetRecurring() ([]internal.ToDo, error) {
+	todos, err := r.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	t := []internal.ToDo{}
+
+	for _, todo := range todos {
+		if todo.RRule != "" {
+			t = append(t, todo)
+		}
+	}
+
+	return t, nil
+}
+
+// Save creates or updates a ToDo
+func (r *ToDoRepo) Save(todo *internal.ToDo) error {
+	return r.save(todo, nil)
+}
+
+// SaveIfUnmodified updates a ToDo if the stored ToDo was last modified at modTime, and returns
+// database.ErrConflict if it was not or has been deleted
+func (r *ToDoRepo) SaveIfUnmodified(todo *internal.ToDo, modTime time.Time) error {
+	return r.save(todo, &modTime)
+}
+
+// save creates or updates a ToDo. If modTime is not nil, the ToDo is only updated if it was last modified
+// at modTime.
+func (r *ToDoRepo) save(todo *internal.ToDo, modTime *time.Time) error {
+	if todo.ID == "" {
+		todo.ID = uuid.NewV4().String()
+	}
+
+	if !validID(todo.ID) {
+		return errors.Wrapf(ErrInvalidID, "Could not save ToDo %s", todo.ID)
+	}
+
+	unlock, err := r.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock() // nolint: errcheck
+
+	if modTime != nil {
+		if err := r.checkUnmodified(todo.ID, *modTime); err != nil {
+			return err
+		}
+	}
+
+	todo.ModTime = time.Now()
+
+	if todo.Created.IsZero() {
+		todo.Created = todo.ModTime
+	}
+
+	b, err := r.format.marshal(todo)
+	if err != nil {
+		return errors.Wrapf(err, "Could not marshal ToDo %s", todo.ID)
+	}
+
+	path := filepath.Join(r.dir, todo.ID+r.format.ext())
+
+	if err := writeFile(path, b); err != nil {
+		return errors.Wrapf(err, "Could not save ToDo %s to disk", todo.ID)
+	}
+
+	// Remove copies of the ToDo that were written in a different format
+	for _, ext := range extensions {
+		if other := filepath.Join(r.dir, todo.ID+ext); other != path {
+			if err := os.Remove(other); err != nil && !os.IsNotExist(err) {
+				return errors.Wrapf(err, "Could not remove %s", other)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkUnmodified returns database.ErrConflict unless the ToDo with the given ID was last modified at
+// modTime. The directory must be locked.
+func (r *ToDoRepo) checkUnmodified(id string, modTime time.Time) error {
+	path, err := r.find(id)
+	if err != nil {
+		return errors.Wrapf(err, "Could not get ToDo %s from disk", id)
+	}
+
+	if path != "" {
+		stored, err := r.read(path)
+		if err != nil {
+			return err
+		}
+
+		if stored.ModTime.Equal(modTime) {
+			return nil
+		}
+	}
+
+	return errors.Wrapf(database.ErrConflict, "ToDo %s was modified", id)
+}
+
+// Delete permanently removes a ToDo
+func (r *ToDoRepo) Delete(id string) error {
+	if !validID(id) {
+		return nil
+	}
+
+	unlock, err := r.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock() // nolint: errcheck
+
+	path, err := r.find(id)
+	if err != nil {
+		return errors.Wrapf(err, "Could not delete ToDo %s from disk", id)
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	if err := r.bury(id); err != nil {
+		return errors.Wrapf(err, "Could not save tombstone of ToDo %s", id)
+	}
+
+	for _, ext := range extensions {
+		if err := os.Remove(filepath.Join(r.dir, id+ext)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "Could not delete ToDo %s from disk", id)
+		}
+	}
+
+	return nil
+}
+
+// GetChangedSince returns the ToDos modified after since, and the tombstones of those deleted after since
+func (r *ToDoRepo) GetChangedSince(since time.Time) ([]internal.ToDo, []internal.Tombstone, error) {
+	todos, err := r.GetAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changed := []internal.ToDo{}
+	for _, t := range todos {
+		if t.ModTime.After(since) {
+			changed = append(changed, t)
+		}
+	}
+
+	unlock, err := r.lock(false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock() // nolint: errcheck
+
+	all, err := r.tombstones()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Could not get tombstones from disk")
+	}
+
+	tombstones := []internal.Tombstone{}
+	for _, t := range all {
+		if t.Deleted.After(since) {
+			tombstones = append(tombstones, t)
+		}
+	}
+
+	return changed, tombstones, nil
+}
+
+// IsDeleted reports whether the ToDo with the given ID was deleted within database.TombstoneRetention
+func (r *ToDoRepo) IsDeleted(id string) (bool, error) {
+	unlock, err := r.lock(false)
+	if err != nil {
+		return false, err
+	}
+	defer unlock() // nolint: errcheck
+
+	_, err = os.Stat(filepath.Join(r.dir, tombstonesDirName, id+".json"))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "Could not get tombstone of ToDo %s from disk", id)
+	}
+
+	return true, nil
+}
+
+// bury records the tombstone of a deleted ToDo and removes tombstones older than
+// database.TombstoneRetention. The directory must be locked exclusively.
+func (r *ToDoRepo) bury(id string) error {
+	dir := filepath.Join(r.dir, tombstonesDirName)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(internal.Tombstone{ID: id, Deleted: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	if err := writeFile(filepath.Join(dir, id+".json"), b); err != nil {
+		return err
+	}
+
+	all, err := r.tombstones()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range all {
+		if time.Since(t.Deleted) > database.TombstoneRetention {
+			if err := os.Remove(filepath.Join(dir, t.ID+".json")); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// tombstones returns every tombstone on disk
+func (r *ToDoRepo) tombstones() ([]internal.Tombstone, error) {
+	dir := filepath.Join(r.dir, tombstonesDirName)
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	t := []internal.Tombstone{}
+
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+
+		var tombstone internal.Tombstone
+		if err := unmarshal(filepath.Join(dir, f.Name()), &tombstone); err != nil {
+			return nil, err
+		}
+
+		t = append(t, tombstone)
+	}
+
+	return t, nil
+}
+
+// lock acquires the directory lock
+func (r *ToDoRepo) lock(exclusive bool) (func() error, error) {
+	unlock, err := lock(filepath.Join(r.dir, lockFileName), exclusive)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not lock %s", r.dir)
+	}
+
+	return unlock, nil
+}
+
+// find returns the path of the file holding the ToDo with the given id, or an empty string if there is none
+func (r *ToDoRepo) find(id string) (string, error) {
+	for _, ext := range extensions {
+		path := filepath.Join(r.dir, id+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	return "", nil
+}
+
+// read decodes the ToDo in the file at path. The file name is the source of truth for the ID, so a file
+// with a different ID in its body is reported as malformed.
+func (r *ToDoRepo) read(path string) (*internal.ToDo, error) {
+	t := &internal.ToDo{}
+
+	if err := unmarshal(path, t); err != nil {
+		return nil, err
+	}
+
+	id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	if t.ID == "" {
+		t.ID = id
+	} else if t.ID != id {
+		return nil, &MalformedFileError{Path: path, Err: errors.Errorf("id %s does not match file name", t.ID)}
+	}
+
+	return t, nil
+}
+
+// isToDoFile reports whether name looks like a ToDo file
+func isToDoFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+
+	ext := filepath.Ext(name)
+	for _, e := range extensions {
+		if ext == e {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,433 @@
+package flatfile_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/database/flatfile"
+	"github.com/pkg/errors"
+)
+
+const testUUID = "a8a43435-20d8-4af2-8f94-f504aff2c6f3"
+
+func TestToDoRepo(t *testing.T) {
+	t.Run("CreateAndGetJSON", testCreateAndGetJSON)
+	t.Run("CreateAndGetYAML", testCreateAndGetYAML)
+	t.Run("GetToDoNotFound", testGetToDoNotFound)
+	t.Run("GetAllToDos", testGetAllToDos)
+	t.Run("UpdateToDoChangesFormat", testUpdateToDoChangesFormat)
+	t.Run("DeleteToDo", testDeleteToDo)
+	t.Run("InvalidID", testInvalidID)
+	t.Run("HandEditedFile", testHandEditedFile)
+	t.Run("MalformedFile", testMalformedFile)
+	t.Run("MismatchedID", testMismatchedID)
+	t.Run("ConcurrentSaves", testConcurrentSaves)
+	t.Run("GetChangedSince", testGetChangedSince)
+	t.Run("SaveIfUnmodified", testSaveIfUnmodified)
+}
+
+func newRepo(t *testing.T, format flatfile.Format) (*flatfile.ToDoRepo, string) {
+	dir, err := ioutil.TempDir("", "todos")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := flatfile.NewToDoRepo(dir, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return repo, dir
+}
+
+func testCreateAndGetJSON(t *testing.T) {
+
+	repo, dir := newRepo(t, flatfile.JSON)
+	defer os.RemoveAll(dir)
+
+	newToDo := &internal.ToDo{Title: "New ToDo"}
+
+	if err := repo.Save(newToDo); err != nil {
+		t.Fatal(err)
+	}
+
+	if newToDo.ID == "" {
+		t.Fatal("Expected ToDo to have an ID")
+	}
+
+	if newToDo.ModTime.IsZero() {
+		t.Fatal("Expected ToDo to have a not zero ModTime")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, newToDo.ID+".json")); err != nil {
+		t.Fatal(err)
+	}
+
+	toDo, err := repo.Get(newToDo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if toDo == nil || toDo.Title != newToDo.Title {
+		t.Fatalf("Expected ToDo with title '%s'", newToDo.Title)
+	}
+}
+
+func testCreateAndGetYAML(t *testing.T) {
+
+	repo, dir := newRepo(t, flatfile.YAML)
+	defer os.RemoveAll(dir)
+
+	newToDo := &internal.ToDo{Title: "New ToDo", Completed: true}
+
+	if err := repo.Save(newToDo); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, newToDo.ID+".yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(b), "title: New ToDo") {
+		t.Fatalf("Expected YAML file, got '%s'", b)
+	}
+
+	toDo, err := repo.Get(newToDo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if toDo == nil || !toDo.Completed {
+		t.Fatal("Expected completed ToDo")
+	}
+}
+
+func testGetToDoNotFound(t *testing.T) {
+
+	repo, dir := newRepo(t, flatfile.JSON)
+	defer os.RemoveAll(dir)
+
+	toDo, err := repo.Get(testUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if toDo != nil {
+		t.Fatal("Expected ToDo to be nil")
+	}
+}
+
+func testGetAllToDos(t *testing.T) {
+
+	repo, dir := newRepo(t, flatfile.JSON)
+	defer os.RemoveAll(dir)
+
+	for _, title := range []string{"Test ToDo 1", "Test ToDo 2", "Test ToDo 3"} {
+		if err := repo.Save(&internal.ToDo{Title: title}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Files that are not ToDos are ignored
+	if err := ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("# Checklist"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	toDos, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(toDos) != 3 {
+		t.Fatalf("Expected 3 ToDos in result, got %d", len(toDos))
+	}
+}
+
+func testUpdateToDoChangesFormat(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "todos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, testUUID+".yml"), []byte("title: Old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := flatfile.NewToDoRepo(dir, flatfile.JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Save(&internal.ToDo{ID: testUUID, Title: "Updated ToDo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, testUUID+".yml")); !os.IsNotExist(err) {
+		t.Fatal("Expected old YAML file to be removed")
+	}
+
+	toDos, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(toDos) != 1 || toDos[0].Title != "Updated ToDo" {
+		t.Fatalf("Expected 1 updated ToDo, got %v", toDos)
+	}
+}
+
+func testDeleteToDo(t *testing.T) {
+
+	repo, dir := newRepo(t, flatfile.JSON)
+	defer os.RemoveAll(dir)
+
+	toDo := &internal.ToDo{Title: "Test ToDo"}
+
+	if err := repo.Save(toDo); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Delete(toDo.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.Get(toDo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != nil {
+		t.Fatal("Expected ToDo to be deleted")
+	}
+
+	if deleted, err := repo.IsDeleted(toDo.ID); err != nil || !deleted {
+		t.Fatalf("Expected a tombstone of the ToDo, got %v, %v", deleted, err)
+	}
+
+	if deleted, err := repo.IsDeleted("other"); err != nil || deleted {
+		t.Fatalf("Expected no tombstone of another ToDo, got %v, %v", deleted, err)
+	}
+}
+
+func testInvalidID(t *testing.T) {
+
+	repo, dir := newRepo(t, flatfile.JSON)
+	defer os.RemoveAll(dir)
+
+	err := repo.Save(&internal.ToDo{ID: "../escape", Title: "Test ToDo"})
+	if errors.Cause(err) != flatfile.ErrInvalidID {
+		t.Fatalf("Expected %v, got %v", flatfile.ErrInvalidID, err)
+	}
+
+	toDo, err := repo.Get("../escape")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if toDo != nil {
+		t.Fatal("Expected ToDo to be nil")
+	}
+}
+
+func testHandEditedFile(t *testing.T) {
+
+	repo, dir := newRepo(t, flatfile.JSON)
+	defer os.RemoveAll(dir)
+
+	body := "# added by hand\ntitle: Hand written\ncompleted: true\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "release.yaml"), []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	toDo, err := repo.Get("release")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if toDo == nil || toDo.ID != "release" || !toDo.Completed {
+		t.Fatalf("Expected completed ToDo with ID from file name, got %v", toDo)
+	}
+}
+
+func testMalformedFile(t *testing.T) {
+
+	repo, dir := newRepo(t, flatfile.JSON)
+	defer os.RemoveAll(dir)
+
+	if err := repo.Save(&internal.ToDo{Title: "Good ToDo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, testUUID+".json")
+	if err := ioutil.WriteFile(path, []byte(`{"title": "Broken",`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The malformed file is skipped
+	all, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 1 || all[0].Title != "Good ToDo" {
+		t.Fatalf("Expected only the good ToDo, got %v", all)
+	}
+
+	_, err = repo.Get(testUUID)
+
+	merr, ok := err.(*flatfile.MalformedFileError)
+	if !ok {
+		t.Fatalf("Expected MalformedFileError, got %v", err)
+	}
+
+	if merr.Path != path {
+		t.Fatalf("Expected error for %s, got %s", path, merr.Path)
+	}
+}
+
+func testMismatchedID(t *testing.T) {
+
+	repo, dir := newRepo(t, flatfile.JSON)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, testUUID+".json")
+	if err := ioutil.WriteFile(path, []byte(`{"id": "other", "title": "Copied"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Get(testUUID); err == nil {
+		t.Fatal("Expected Error")
+	}
+}
+
+func testConcurrentSaves(t *testing.T) {
+
+	repo, dir := newRepo(t, flatfile.JSON)
+	defer os.RemoveAll(dir)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := repo.Save(&internal.ToDo{ID: testUUID, Title: "Concurrent ToDo"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	toDos, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(toDos) != 1 {
+		t.Fatalf("Expected 1 ToDo in result, got %d", len(toDos))
+	}
+}
+
+func testGetChangedSince(t *testing.T) {
+
+	repo, dir := newRepo(t, flatfile.JSON)
+	defer os.RemoveAll(dir)
+
+	unchanged := &internal.ToDo{Title: "Unchanged ToDo"}
+	deleted := &internal.ToDo{Title: "Deleted ToDo"}
+
+	for _, toDo := range []*internal.ToDo{unchanged, deleted} {
+		if err := repo.Save(toDo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	since := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	changed := &internal.ToDo{Title: "Changed ToDo"}
+
+	if err := repo.Save(changed); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Delete(deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	todos, tombstones, err := repo.GetChangedSince(since)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(todos) != 1 || todos[0].ID != changed.ID {
+		t.Fatalf("Expected only %s to have changed, got %v", changed.ID, todos)
+	}
+
+	if todos[0].Created.IsZero() {
+		t.Fatal("Expected ToDo to have a Created time")
+	}
+
+	if len(tombstones) != 1 || tombstones[0].ID != deleted.ID {
+		t.Fatalf("Expected a tombstone of %s, got %v", deleted.ID, tombstones)
+	}
+
+	// The tombstones directory is not a ToDo
+	all, err := repo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 2 {
+		t.Fatalf("Expected 2 ToDos, got %d", len(all))
+	}
+}
+
+func testSaveIfUnmodified(t *testing.T) {
+
+	repo, dir := newRepo(t, flatfile.JSON)
+	defer os.RemoveAll(dir)
+
+	if err := repo.Save(&internal.ToDo{ID: testUUID, Title: "Draft"}); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := repo.Get(testUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := *read
+	first.Title = "First"
+
+	if err := repo.SaveIfUnmodified(&first, read.ModTime); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ToDo was modified since it was read
+	second := *read
+	second.Title = "Second"
+
+	if err := repo.SaveIfUnmodified(&second, read.ModTime); errors.Cause(err) != database.ErrConflict {
+		t.Fatalf("Expected %v, got %v", database.ErrConflict, err)
+	}
+
+	if err := repo.Delete(testUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.SaveIfUnmodified(&first, first.ModTime); errors.Cause(err) != database.ErrConflict {
+		t.Fatalf("Expected %v for a deleted ToDo, got %v", database.ErrConflict, err)
+	}
+}
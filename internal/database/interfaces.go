@@ -1,6 +1,8 @@
 package database
 
 import (
+	"time"
+
 	"github.com/benjaminbartels/todo/internal"
 )
 
@@ -11,3 +13,138 @@ type ToDoRepo interface {
 	Save(todo *internal.ToDo) error
 	Delete(id string) error
 }
+
+// ToDoQuerier is an interface for repositories that can filter ToDos without reading all of them.
+// Decorators implement it, returning ErrNotSupported if the repository they wrap does not.
+type ToDoQuerier interface {
+	GetByCompleted(completed bool) ([]internal.ToDo, error)
+	GetDueBetween(from, to time.Time) ([]internal.ToDo, error)
+}
+
+// ToDoTagQuerier is an interface for repositories that can filter ToDos by tag without returning all of them.
+// Decorators implement it, returning ErrNotSupported if the repository they wrap does not.
+type ToDoTagQuerier interface {
+	GetByTags(tags []string, all bool) ([]internal.ToDo, error)
+}
+
+// TombstoneRetention is how long repositories keep the tombstones of deleted ToDos. Clients that have not
+// synced for longer must fetch every ToDo again.
+const TombstoneRetention = internal.TombstoneRetention
+
+// ToDoChangeRepo is an interface for repositories that keep tombstones of deleted ToDos, so clients can
+// fetch what changed since they last synced
+type ToDoChangeRepo interface {
+	GetChangedSince(since time.Time) ([]internal.ToDo, []internal.Tombstone, error)
+}
+
+// ToDoTombstoneRepo is an interface for repositories that can tell whether a ToDo was deleted, for as long as
+// they keep its tombstone. Decorators implement it, returning ErrNotSupported if the repository they wrap
+// does not.
+type ToDoTombstoneRepo interface {
+	IsDeleted(id string) (bool, error)
+}
+
+// ToDoConditionalRepo is an interface for repositories that can update a ToDo only if it was not modified
+// since it was read. SaveIfUnmodified returns ErrConflict if the stored ToDo was last modified at another
+// time than modTime, or was deleted. Decorators implement it, returning ErrNotSupported if the repository
+// they wrap does not.
+type ToDoConditionalRepo interface {
+	SaveIfUnmodified(todo *internal.ToDo, modTime time.Time) error
+}
+
+// ToDoBatchRepo is an interface for repositories that can save many ToDos in a single operation
+type ToDoBatchRepo interface {
+	SaveAll(todos []*internal.ToDo) error
+}
+
+// SaveAll saves todos using the repository's batch operation if it has one, or one at a time if it
+// does not
+func SaveAll(repo ToDoRepo, todos []*internal.ToDo) error {
+	if b, ok := repo.(ToDoBatchRepo); ok {
+		return b.SaveAll(todos)
+	}
+
+	for _, t := range todos {
+		if err := repo.Save(t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecurringToDoFinder is an interface for repositories that can find the recurring ToDos of every list, so
+// their upcoming occurrences can be created ahead of time
+type RecurringToDoFinder interface {
+	GetRecurring() ([]internal.ToDo, error)
+}
+
+// AssignedToDoFinder is an interface for repositories that can find the ToDos of every list that are
+// assigned to a user without reading all of them
+type AssignedToDoFinder interface {
+	GetAssignedTo(userID string) ([]internal.ToDo, error)
+}
+
+// ToDoRepoProvider returns the ToDoRepo for a list, or nil if the list can not be stored
+type ToDoRepoProvider func(listID string) ToDoRepo
+
+// FeedTokenRepo is an interface for storing calendar feed tokens
+type FeedTokenRepo interface {
+	Get(token string) (*internal.FeedToken, error)
+	Save(token *internal.FeedToken) error
+	Delete(token string) error
+}
+
+// ConnectionRepo is an interface for storing the WebSocket connections subscribed to lists
+type ConnectionRepo interface {
+	GetByList(listID string) ([]internal.Connection, error)
+	Save(connection *internal.Connection) error
+	Delete(id string) error
+}
+
+// WebhookRepo is an interface for storing webhooks and the log of their deliveries
+type WebhookRepo interface {
+	Get(id string) (*internal.Webhook, error)
+	GetByList(listID string) ([]internal.Webhook, error)
+	Save(webhook *internal.Webhook) error
+	Delete(id string) error
+	GetDelivery(webhookID, id string) (*internal.Delivery, error)
+	GetDeliveries(webhookID string) ([]internal.Delivery, error)
+	SaveDelivery(delivery *internal.Delivery) error
+}
+
+// CommentRepo is an interface for storing the comments on ToDos
+type CommentRepo interface {
+	Get(listID, todoID, id string) (*internal.Comment, error)
+	GetByToDo(listID, todoID string) ([]internal.Comment, error)
+	Save(comment *internal.Comment) error
+	Delete(listID, todoID, id string) error
+}
+
+// MemberRepo is an interface for storing the members of lists
+type MemberRepo interface {
+	GetByList(listID string) ([]internal.Member, error)
+	Save(member *internal.Member) error
+	Delete(listID, userID string) error
+}
+
+// TimeEntryRepo is an interface for storing the time users spend on ToDos. Start returns ErrConflict if the
+// user already has a running timer, and Stop saves the running timer of a user once it has an end.
+type TimeEntryRepo interface {
+	Get(listID, todoID, id string) (*internal.TimeEntry, error)
+	GetByToDo(listID, todoID string) ([]internal.TimeEntry, error)
+	GetByUser(userID string, from, to time.Time) ([]internal.TimeEntry, error)
+	GetRunning(userID string) (*internal.TimeEntry, error)
+	Start(entry *internal.TimeEntry) error
+	Stop(entry *internal.TimeEntry) error
+	Save(entry *internal.TimeEntry) error
+	Delete(listID, todoID, id string) error
+}
+
+// TemplateRepo is an interface for storing the templates of users
+type TemplateRepo interface {
+	Get(userID, id string) (*internal.Template, error)
+	GetByUser(userID string) ([]internal.Template, error)
+	Save(template *internal.Template) error
+	Delete(userID, id string) error
+}
@@ -0,0 +1,175 @@
+// Package notify provides a database.ToDoRepo decorator that publishes a Change for every ToDo saved or
+// deleted, so every backend emits change events without the handlers knowing about them.
+package notify
+
+import (
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+)
+
+// Publisher is sent the changes made through a ToDoRepo. Changes are published after they are saved and
+// a Publisher can not fail the write, so it handles its own errors.
+type Publisher interface {
+	Publish(change internal.Change)
+}
+
+// PublisherFunc is a function that is a Publisher
+type PublisherFunc func(change internal.Change)
+
+// Publish calls f(change)
+func (f PublisherFunc) Publish(change internal.Change) {
+	f(change)
+}
+
+// ToDoRepo is a database.ToDoRepo that publishes the changes made through it
+type ToDoRepo struct {
+	repo      database.ToDoRepo
+	publisher Publisher
+}
+
+// NewToDoRepo returns a new ToDo repository that publishes the changes made to repo
+func NewToDoRepo(repo database.ToDoRepo, publisher Publisher) *ToDoRepo {
+	return &ToDoRepo{
+		repo:      repo,
+		publisher: publisher,
+	}
+}
+
+// Get returns a ToDo by its ID
+func (r *ToDoRepo) Get(id string) (*internal.ToDo, error) {
+	return r.repo.Get(id)
+}
+
+// GetAll returns all ToDos
+func (r *ToDoRepo) GetAll() ([]internal.ToDo, error) {
+	return r.repo.GetAll()
+}
+
+// GetChangedSince returns the ToDos modified, and the tombstones of those deleted, after since
+func (r *ToDoRepo) GetChangedSince(since time.Time) ([]internal.ToDo, []internal.Tombstone, error) {
+	c, ok := r.repo.(database.ToDoChangeRepo)
+	if !ok {
+		return nil, nil, database.ErrNotSupported
+	}
+
+	return c.GetChangedSince(since)
+}
+
+// IsDeleted reports whether the ToDo with the given ID was deleted
+func (r *ToDoRepo) IsDeleted(id string) (bool, error) {
+	d, ok := r.repo.(database.ToDoTombstoneRepo)
+	if !ok {
+		return false, database.ErrNotSupported
+	}
+
+	return d.IsDeleted(id)
+}
+
+// GetByCompleted returns the ToDos that are, or are not, completed
+func (r *ToDoRepo) GetByCompleted(completed bool) ([]internal.ToDo, error) {
+	q, ok := r.repo.(database.ToDoQuerier)
+	if !ok {
+		return nil, database.ErrNotSupported
+	}
+
+	return q.GetByCompleted(completed)
+}
+
+// GetDueBetween returns the ToDos that are due within the given range
+func (r *ToDoRepo) GetDueBetween(from, to time.Time) ([]internal.ToDo, error) {
+	q, ok := r.repo.(database.ToDoQuerier)
+	if !ok {
+		return nil, database.ErrNotSupported
+	}
+
+	return q.GetDueBetween(from, to)
+}
+
+// GetByTags returns the ToDos that have all of the tags, or any of them if all is false
+func (r *ToDoRepo) GetByTags(tags []string, all bool) ([]internal.ToDo, error) {
+	q, ok := r.repo.(database.ToDoTagQuerier)
+	if !ok {
+		return nil, database.ErrNotSupported
+	}
+
+	return q.GetByTags(tags, all)
+}
+
+// Save creates or updates a ToDo
+func (r *ToDoRepo) Save(todo *internal.ToDo) error {
+	// Repositories set Created when a ToDo is first saved
+	created := todo.Created.IsZero()
+
+	if err := r.repo.Save(todo); err != nil {
+		return err
+	}
+
+	r.publish(todo, created)
+
+	return nil
+}
+
+// SaveIfUnmodified updates a ToDo if it was last modified at modTime
+func (r *ToDoRepo) SaveIfUnmodified(todo *internal.ToDo, modTime time.Time) error {
+	c, ok := r.repo.(database.ToDoConditionalRepo)
+	if !ok {
+		return database.ErrNotSupported
+	}
+
+	if err := c.SaveIfUnmodified(todo, modTime); err != nil {
+		return err
+	}
+
+	r.publish(todo, false)
+
+	return nil
+}
+
+// SaveAll creates or updates many ToDos
+func (r *ToDoRepo) SaveAll(todos []*internal.ToDo) error {
+	created := make([]bool, len(todos))
+	for i, t := range todos {
+		created[i] = t.Created.IsZero()
+	}
+
+	if err := database.SaveAll(r.repo, todos); err != nil {
+		return err
+	}
+
+	for i, t := range todos {
+		r.publish(t, created[i])
+	}
+
+	return nil
+}
+
+// Delete permanently removes a ToDo
+func (r *ToDoRepo) Delete(id string) error {
+	// The ToDo is read first, so subscribers know which list it was deleted from
+	t, err := r.repo.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if err := r.repo.Delete(id); err != nil {
+		return err
+	}
+
+	if t != nil {
+		r.publisher.Publish(internal.Change{Type: internal.ChangeDeleted, ToDo: *t})
+	}
+
+	return nil
+}
+
+// publish publishes the change made by saving todo
+func (r *ToDoRepo) publish(todo *internal.ToDo, created bool) {
+	c := internal.Change{Type: internal.ChangeUpdated, ToDo: *todo}
+	if created {
+		c.Type = internal.ChangeCreated
+	}
+
+	r.publisher.Publish(c)
+}
@@ -0,0 +1,200 @@
+package notify_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/database/notify"
+	pkgerrors "github.com/pkg/errors"
+)
+
+const testUUID = "a8a43435-20d8-4af2-8f94-f504aff2c6f3"
+
+func TestToDoRepo(t *testing.T) {
+	t.Run("SavePublishesCreated", testSavePublishesCreated)
+	t.Run("SavePublishesUpdated", testSavePublishesUpdated)
+	t.Run("SaveErrorNotPublished", testSaveErrorNotPublished)
+	t.Run("DeletePublishesDeleted", testDeletePublishesDeleted)
+	t.Run("DeleteNotFoundNotPublished", testDeleteNotFoundNotPublished)
+	t.Run("SaveAllPublishesEach", testSaveAllPublishesEach)
+	t.Run("GetChangedSinceNotSupported", testGetChangedSinceNotSupported)
+	t.Run("QueriesNotSupported", testQueriesNotSupported)
+}
+
+func newMock() *RepoMock {
+	return &RepoMock{
+		GetFn: func(id string) (*internal.ToDo, error) {
+			return &internal.ToDo{ID: id, ListID: "work", Title: "Some ToDo"}, nil
+		},
+		SaveFn: func(todo *internal.ToDo) error {
+			if todo.ID == "" {
+				todo.ID = testUUID
+			}
+			todo.ModTime = time.Now()
+			if todo.Created.IsZero() {
+				todo.Created = todo.ModTime
+			}
+			return nil
+		},
+		DeleteFn: func(string) error {
+			return nil
+		},
+	}
+}
+
+// recorder returns a Publisher that records the changes published to it
+func recorder() (notify.Publisher, *[]internal.Change) {
+	changes := &[]internal.Change{}
+	return notify.PublisherFunc(func(c internal.Change) {
+		*changes = append(*changes, c)
+	}), changes
+}
+
+func testSavePublishesCreated(t *testing.T) {
+
+	p, changes := recorder()
+	repo := notify.NewToDoRepo(newMock(), p)
+
+	if err := repo.Save(&internal.ToDo{Title: "New ToDo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*changes) != 1 {
+		t.Fatalf("Expected 1 change, got %d", len(*changes))
+	}
+
+	c := (*changes)[0]
+	if c.Type != internal.ChangeCreated || c.ToDo.ID != testUUID {
+		t.Fatalf("Expected ToDo %s to be created, got %+v", testUUID, c)
+	}
+
+	if c.ListID() != internal.DefaultListID {
+		t.Fatalf("Expected change to the default list, got %s", c.ListID())
+	}
+}
+
+func testSavePublishesUpdated(t *testing.T) {
+
+	p, changes := recorder()
+	repo := notify.NewToDoRepo(newMock(), p)
+
+	todo := &internal.ToDo{ID: testUUID, Title: "Renamed", Created: time.Now().Add(-time.Hour)}
+
+	if err := repo.Save(todo); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*changes) != 1 || (*changes)[0].Type != internal.ChangeUpdated {
+		t.Fatalf("Expected an updated change, got %+v", *changes)
+	}
+}
+
+func testSaveErrorNotPublished(t *testing.T) {
+
+	m := newMock()
+	m.SaveFn = func(*internal.ToDo) error {
+		return errors.New("DB Error")
+	}
+
+	p, changes := recorder()
+	repo := notify.NewToDoRepo(m, p)
+
+	if err := repo.Save(&internal.ToDo{Title: "New ToDo"}); err == nil {
+		t.Fatal("Expected Error")
+	}
+
+	if len(*changes) != 0 {
+		t.Fatalf("Expected no changes, got %+v", *changes)
+	}
+}
+
+func testDeletePublishesDeleted(t *testing.T) {
+
+	p, changes := recorder()
+	repo := notify.NewToDoRepo(newMock(), p)
+
+	if err := repo.Delete(testUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*changes) != 1 {
+		t.Fatalf("Expected 1 change, got %d", len(*changes))
+	}
+
+	c := (*changes)[0]
+	if c.Type != internal.ChangeDeleted || c.ToDo.Title != "Some ToDo" || c.ListID() != "work" {
+		t.Fatalf("Expected the deleted ToDo to be published, got %+v", c)
+	}
+}
+
+func testDeleteNotFoundNotPublished(t *testing.T) {
+
+	m := newMock()
+	m.GetFn = func(string) (*internal.ToDo, error) {
+		return nil, nil
+	}
+
+	p, changes := recorder()
+	repo := notify.NewToDoRepo(m, p)
+
+	if err := repo.Delete(testUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*changes) != 0 {
+		t.Fatalf("Expected no changes, got %+v", *changes)
+	}
+}
+
+func testSaveAllPublishesEach(t *testing.T) {
+
+	p, changes := recorder()
+	repo := notify.NewToDoRepo(newMock(), p)
+
+	todos := []*internal.ToDo{{Title: "One"}, {Title: "Two"}}
+
+	if err := repo.SaveAll(todos); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d", len(*changes))
+	}
+
+	for _, c := range *changes {
+		if c.Type != internal.ChangeCreated {
+			t.Fatalf("Expected created changes, got %+v", c)
+		}
+	}
+}
+
+func testGetChangedSinceNotSupported(t *testing.T) {
+
+	p, _ := recorder()
+	repo := notify.NewToDoRepo(newMock(), p)
+
+	if _, _, err := repo.GetChangedSince(time.Now()); pkgerrors.Cause(err) != database.ErrNotSupported {
+		t.Fatalf("Expected %v, got %v", database.ErrNotSupported, err)
+	}
+}
+
+func testQueriesNotSupported(t *testing.T) {
+
+	p, _ := recorder()
+	repo := notify.NewToDoRepo(newMock(), p)
+
+	if _, err := repo.GetByCompleted(true); pkgerrors.Cause(err) != database.ErrNotSupported {
+		t.Fatalf("Expected %v, got %v", database.ErrNotSupported, err)
+	}
+
+	if _, err := repo.GetDueBetween(time.Now(), time.Now()); pkgerrors.Cause(err) != database.ErrNotSupported {
+		t.Fatalf("Expected %v, got %v", database.ErrNotSupported, err)
+	}
+
+	if _, err := repo.GetByTags([]string{"infra"}, true); pkgerrors.Cause(err) != database.ErrNotSupported {
+		t.Fatalf("Expected %v, got %v", database.ErrNotSupported, err)
+	}
+}
@@ -0,0 +1,94 @@
+package internal
+
+// DependencyGraph is the graph of the ToDos in a list that block each other. An edge from a ToDo to one of
+// its BlockedBy points upstream; the reverse edge points downstream.
+type DependencyGraph struct {
+	blockedBy map[string][]string
+	blocks    map[string][]string
+}
+
+// NewDependencyGraph returns the graph of todos. Blockers that are not in todos, such as those that were
+// deleted, are left out.
+func NewDependencyGraph(todos []ToDo) *DependencyGraph {
+	g := &DependencyGraph{
+		blockedBy: make(map[string][]string),
+		blocks:    make(map[string][]string),
+	}
+
+	ids := make(map[string]bool, len(todos))
+	for _, t := range todos {
+		ids[t.ID] = true
+	}
+
+	for _, t := range todos {
+		for _, blocker := range t.BlockedBy {
+			if ids[blocker] {
+				g.blockedBy[t.ID] = append(g.blockedBy[t.ID], blocker)
+				g.blocks[blocker] = append(g.blocks[blocker], t.ID)
+			}
+		}
+	}
+
+	return g
+}
+
+// Upstream returns the IDs of the ToDos that block id, directly or through other ToDos, nearest first
+func (g *DependencyGraph) Upstream(id string) []string {
+	return closure(g.blockedBy, id)
+}
+
+// Downstream returns the IDs of the ToDos that id blocks, directly or through other ToDos, nearest first
+func (g *DependencyGraph) Downstream(id string) []string {
+	return closure(g.blocks, id)
+}
+
+// Path returns the IDs of the ToDos from, through the ToDos that block it, to to, or nil if to does not
+// block from. Adding to as a blocker of from creates a cycle if from is on a path from to.
+func (g *DependencyGraph) Path(from, to string) []string {
+	previous := map[string]string{from: ""}
+	queue := []string{from}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		if id == to {
+			path := []string{}
+			for ; id != ""; id = previous[id] {
+				path = append([]string{id}, path...)
+			}
+			return path
+		}
+
+		for _, next := range g.blockedBy[id] {
+			if _, ok := previous[next]; !ok {
+				previous[next] = id
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return nil
+}
+
+// closure returns the IDs reachable from id following edges, breadth first and without id itself
+func closure(edges map[string][]string, id string) []string {
+	reached := []string{}
+	seen := map[string]bool{id: true}
+	queue := []string{id}
+
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+
+		for _, e := range edges[next] {
+			if !seen[e] {
+				seen[e] = true
+				reached = append(reached, e)
+				queue = append(queue, e)
+			}
+		}
+	}
+
+	return reached
+}
@@ -0,0 +1,62 @@
+package internal_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+// 1 is blocked by 2 and 3, which are both blocked by 4. 5 is blocked by 1 and by 6, which was deleted.
+var dependencies = []internal.ToDo{
+	{ID: "1", BlockedBy: []string{"2", "3"}},
+	{ID: "2", BlockedBy: []string{"4"}},
+	{ID: "3", BlockedBy: []string{"4"}},
+	{ID: "4"},
+	{ID: "5", BlockedBy: []string{"1", "6"}},
+}
+
+func TestDependencyGraph(t *testing.T) {
+	t.Run("Upstream", testUpstream)
+	t.Run("Downstream", testDownstream)
+	t.Run("Path", testPath)
+}
+
+func testUpstream(t *testing.T) {
+
+	g := internal.NewDependencyGraph(dependencies)
+
+	if got, want := g.Upstream("1"), []string{"2", "3", "4"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+
+	if got := g.Upstream("4"); len(got) != 0 {
+		t.Fatalf("Expected nothing upstream of 4, got %v", got)
+	}
+}
+
+func testDownstream(t *testing.T) {
+
+	g := internal.NewDependencyGraph(dependencies)
+
+	if got, want := g.Downstream("4"), []string{"2", "3", "1", "5"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+}
+
+func testPath(t *testing.T) {
+
+	g := internal.NewDependencyGraph(dependencies)
+
+	if got, want := g.Path("5", "4"), []string{"5", "1", "2", "4"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+
+	if got := g.Path("4", "5"); got != nil {
+		t.Fatalf("Expected no path, got %v", got)
+	}
+
+	if got, want := g.Path("1", "1"), []string{"1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,200 @@
+// Package domain defines the events raised when ToDos change, and the sinks that handle them outside the
+// request path, such as the audit trail.
+package domain
+
+import (
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+// Event names
+const (
+	CreatedEvent    = "Created"
+	CompletedEvent  = "Completed"
+	ReopenedEvent   = "Reopened"
+	RenamedEvent    = "Renamed"
+	DeletedEvent    = "Deleted"
+	MentionedEvent  = "Mentioned"
+	ReassignedEvent = "Reassigned"
+)
+
+// Event is a domain event raised when a ToDo changes
+type Event interface {
+	// Name returns the name of the event, such as Created
+	Name() string
+	// Metadata returns what identifies the event and the ToDo it is for
+	Metadata() Meta
+}
+
+// Meta identifies an event and the ToDo it is for. Events are delivered at least once, so sinks use ID to
+// ignore duplicates.
+type Meta struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	ListID string    `json:"listId"`
+	ToDoID string    `json:"todoId"`
+}
+
+// Metadata returns m
+func (m Meta) Metadata() Meta {
+	return m
+}
+
+// Created is raised when a ToDo is created
+type Created struct {
+	Meta
+	ToDo internal.ToDo `json:"todo"`
+}
+
+// Name returns CreatedEvent
+func (Created) Name() string { return CreatedEvent }
+
+// Completed is raised when a ToDo is marked as completed
+type Completed struct {
+	Meta
+	ToDo internal.ToDo `json:"todo"`
+}
+
+// Name returns CompletedEvent
+func (Completed) Name() string { return CompletedEvent }
+
+// Reopened is raised when a completed ToDo is marked as not completed
+type Reopened struct {
+	Meta
+	ToDo internal.ToDo `json:"todo"`
+}
+
+// Name returns ReopenedEvent
+func (Reopened) Name() string { return ReopenedEvent }
+
+// Renamed is raised when the title of a ToDo changes
+type Renamed struct {
+	Meta
+	ToDo     internal.ToDo `json:"todo"`
+	OldTitle string        `json:"oldTitle"`
+}
+
+// Name returns RenamedEvent
+func (Renamed) Name() string { return RenamedEvent }
+
+// Reassigned is raised when the users a ToDo is assigned to change. The audit trail keeps these events as
+// the history of its assignment.
+type Reassigned struct {
+	Meta
+	ToDo           internal.ToDo `json:"todo"`
+	OldAssigneeIDs []string      `json:"oldAssigneeIds"`
+}
+
+// Name returns ReassignedEvent
+func (Reassigned) Name() string { return ReassignedEvent }
+
+// Deleted is raised when a ToDo is deleted. ToDo is the ToDo as it was before it was deleted.
+type Deleted struct {
+	Meta
+	ToDo internal.ToDo `json:"todo"`
+}
+
+// Name returns DeletedEvent
+func (Deleted) Name() string { return DeletedEvent }
+
+// Mentioned is raised when a comment mentions a member of the ToDo's list, either when it is written or
+// when an edit adds the mention. UserID is the member mentioned.
+type Mentioned struct {
+	Meta
+	UserID  string           `json:"userId"`
+	Comment internal.Comment `json:"comment"`
+}
+
+// Name returns MentionedEvent
+func (Mentioned) Name() string { return MentionedEvent }
+
+// Events returns the events raised by a change from old to new, either of which is nil when the ToDo was
+// created or deleted. id identifies the change, and each event's ID is derived from it. A change to fields
+// that no event is raised for, such as the due date, raises none.
+func Events(id string, at time.Time, old, new *internal.ToDo) []Event {
+	events := []Event{}
+
+	meta := func(name string, t *internal.ToDo) Meta {
+		listID := t.ListID
+		if listID == "" {
+			listID = internal.DefaultListID
+		}
+		return Meta{ID: id + ":" + name, Time: at, ListID: listID, ToDoID: t.ID}
+	}
+
+	switch {
+	case old == nil && new != nil:
+		events = append(events, Created{Meta: meta(CreatedEvent, new), ToDo: *new})
+	case old != nil && new == nil:
+		events = append(events, Deleted{Meta: meta(DeletedEvent, old), ToDo: *old})
+	case old != nil && new != nil:
+		if new.Title != old.Title {
+			events = append(events, Renamed{Meta: meta(RenamedEvent, new), ToDo: *new, OldTitle: old.Title})
+		}
+
+		if !sameStrings(new.AssigneeIDs, old.AssigneeIDs) {
+			events = append(events, Reassigned{Meta: meta(ReassignedEvent, new), ToDo: *new, OldAssigneeIDs: old.AssigneeIDs})
+		}
+
+		if new.Completed && !old.Completed {
+			events = append(events, Completed{Meta: meta(CompletedEvent, new), ToDo: *new})
+		} else if !new.Completed && old.Completed {
+			events = append(events, Reopened{Meta: meta(ReopenedEvent, new), ToDo: *new})
+		}
+	}
+
+	return events
+}
+
+// CommentEvents returns the events raised by a change to a comment from old to new, either of which is nil
+// when the comment was written or deleted. Each member newly mentioned by new is sent a Mentioned event.
+func CommentEvents(id string, at time.Time, old, new *internal.Comment) []Event {
+	events := []Event{}
+
+	if new == nil {
+		return events
+	}
+
+	listID := new.ListID
+	if listID == "" {
+		listID = internal.DefaultListID
+	}
+
+	for _, userID := range new.Mentions {
+		if old != nil && stringIn(userID, old.Mentions) {
+			continue
+		}
+
+		events = append(events, Mentioned{
+			Meta:    Meta{ID: id + ":" + MentionedEvent + ":" + userID, Time: at, ListID: listID, ToDoID: new.ToDoID},
+			UserID:  userID,
+			Comment: *new,
+		})
+	}
+
+	return events
+}
+
+// sameStrings reports whether a and b hold the same strings, in any order
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, s := range a {
+		if !stringIn(s, b) {
+			return false
+		}
+	}
+	return true
+}
+
+// stringIn reports whether values contains s
+func stringIn(s string, values []string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
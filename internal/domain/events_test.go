@@ -0,0 +1,175 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/domain"
+)
+
+func TestEvents(t *testing.T) {
+	t.Run("Created", testEventsCreated)
+	t.Run("Deleted", testEventsDeleted)
+	t.Run("CompletedAndRenamed", testEventsCompletedAndRenamed)
+	t.Run("Reopened", testEventsReopened)
+	t.Run("Reassigned", testEventsReassigned)
+	t.Run("NoEvents", testEventsNoEvents)
+	t.Run("Mentioned", testCommentEventsMentioned)
+	t.Run("MentionAddedByEdit", testCommentEventsMentionAddedByEdit)
+}
+
+var at = time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+
+func testEventsCreated(t *testing.T) {
+
+	got := domain.Events("e1", at, nil, &internal.ToDo{ID: "1", Title: "Foo"})
+
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(got))
+	}
+
+	c, ok := got[0].(domain.Created)
+	if !ok {
+		t.Fatalf("Expected Created event, got %T", got[0])
+	}
+
+	want := domain.Meta{ID: "e1:Created", Time: at, ListID: internal.DefaultListID, ToDoID: "1"}
+	if c.Metadata() != want {
+		t.Fatalf("Expected %+v, got %+v", want, c.Metadata())
+	}
+}
+
+func testEventsDeleted(t *testing.T) {
+
+	got := domain.Events("e1", at, &internal.ToDo{ListID: "work", ID: "1", Title: "Foo"}, nil)
+
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(got))
+	}
+
+	d, ok := got[0].(domain.Deleted)
+	if !ok {
+		t.Fatalf("Expected Deleted event, got %T", got[0])
+	}
+
+	if d.ListID != "work" || d.ToDo.Title != "Foo" {
+		t.Fatalf("Unexpected event %+v", d)
+	}
+}
+
+func testEventsCompletedAndRenamed(t *testing.T) {
+
+	old := &internal.ToDo{ID: "1", Title: "Foo"}
+	new := &internal.ToDo{ID: "1", Title: "Bar", Completed: true}
+
+	got := domain.Events("e1", at, old, new)
+
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(got))
+	}
+
+	r, ok := got[0].(domain.Renamed)
+	if !ok {
+		t.Fatalf("Expected Renamed event, got %T", got[0])
+	}
+
+	if r.OldTitle != "Foo" || r.ToDo.Title != "Bar" {
+		t.Fatalf("Unexpected event %+v", r)
+	}
+
+	if _, ok := got[1].(domain.Completed); !ok {
+		t.Fatalf("Expected Completed event, got %T", got[1])
+	}
+
+	if got[0].Metadata().ID == got[1].Metadata().ID {
+		t.Fatal("Expected events to have different IDs")
+	}
+}
+
+func testEventsReopened(t *testing.T) {
+
+	got := domain.Events("e1", at, &internal.ToDo{ID: "1", Completed: true}, &internal.ToDo{ID: "1"})
+
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(got))
+	}
+
+	if got[0].Name() != domain.ReopenedEvent {
+		t.Fatalf("Expected %s event, got %s", domain.ReopenedEvent, got[0].Name())
+	}
+}
+
+func testEventsReassigned(t *testing.T) {
+
+	old := &internal.ToDo{ID: "1", AssigneeIDs: []string{"user-1"}}
+	new := &internal.ToDo{ID: "1", AssigneeIDs: []string{"user-1", "user-2"}}
+
+	got := domain.Events("e1", at, old, new)
+
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(got))
+	}
+
+	r, ok := got[0].(domain.Reassigned)
+	if !ok {
+		t.Fatalf("Expected Reassigned event, got %T", got[0])
+	}
+
+	if len(r.OldAssigneeIDs) != 1 || r.OldAssigneeIDs[0] != "user-1" || len(r.ToDo.AssigneeIDs) != 2 {
+		t.Fatalf("Unexpected Reassigned event %+v", r)
+	}
+}
+
+func testEventsNoEvents(t *testing.T) {
+
+	due := at.Add(24 * time.Hour)
+
+	got := domain.Events("e1", at, &internal.ToDo{ID: "1", Title: "Foo", AssigneeIDs: []string{"user-1", "user-2"}},
+		&internal.ToDo{ID: "1", Title: "Foo", Due: &due, AssigneeIDs: []string{"user-2", "user-1"}})
+
+	if len(got) != 0 {
+		t.Fatalf("Expected no events, got %d", len(got))
+	}
+}
+
+func testCommentEventsMentioned(t *testing.T) {
+
+	c := &internal.Comment{ID: "c1", ListID: "work", ToDoID: "1", Mentions: []string{"user-1", "user-2"}}
+
+	got := domain.CommentEvents("e1", at, nil, c)
+
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(got))
+	}
+
+	m, ok := got[1].(domain.Mentioned)
+	if !ok {
+		t.Fatalf("Expected Mentioned event, got %T", got[1])
+	}
+
+	want := domain.Meta{ID: "e1:Mentioned:user-2", Time: at, ListID: "work", ToDoID: "1"}
+	if m.Metadata() != want || m.UserID != "user-2" || m.Comment.ID != "c1" {
+		t.Fatalf("Unexpected event %+v", m)
+	}
+
+	if got := domain.CommentEvents("e2", at, c, nil); len(got) != 0 {
+		t.Fatalf("Expected no events for a deleted comment, got %d", len(got))
+	}
+}
+
+func testCommentEventsMentionAddedByEdit(t *testing.T) {
+
+	old := &internal.Comment{ID: "c1", ToDoID: "1", Mentions: []string{"user-1"}}
+	edited := &internal.Comment{ID: "c1", ToDoID: "1", Mentions: []string{"user-1", "user-2"}}
+
+	got := domain.CommentEvents("e1", at, old, edited)
+
+	if len(got) != 1 || got[0].(domain.Mentioned).UserID != "user-2" {
+		t.Fatalf("Expected only the new mention, got %+v", got)
+	}
+
+	if got[0].Metadata().ListID != internal.DefaultListID {
+		t.Fatalf("Expected the default list, got %s", got[0].Metadata().ListID)
+	}
+}
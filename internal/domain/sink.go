@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Sink handles domain events. An error causes the events to be delivered again, so sinks must be
+// idempotent.
+type Sink interface {
+	Handle(e Event) error
+}
+
+// ContextSink is a Sink that stops handling an event when a context is done, such as one that sends events
+// over the network and must return before the Lambda function it runs in times out
+type ContextSink interface {
+	Sink
+	HandleContext(ctx context.Context, e Event) error
+}
+
+// Handle passes e to s, with ctx if s is a ContextSink
+func Handle(ctx context.Context, s Sink, e Event) error {
+	if c, ok := s.(ContextSink); ok {
+		return c.HandleContext(ctx, e)
+	}
+	return s.Handle(e)
+}
+
+// SinkFunc is a function that is a Sink
+type SinkFunc func(e Event) error
+
+// Handle calls f(e)
+func (f SinkFunc) Handle(e Event) error {
+	return f(e)
+}
+
+// LogSink writes events to an io.Writer as JSON Lines, as an audit trail. In Lambda, writing to standard
+// output sends them to CloudWatch Logs.
+type LogSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewLogSink returns a LogSink that writes to w
+func NewLogSink(w io.Writer) *LogSink {
+	return &LogSink{w: w}
+}
+
+// logEntry is a line written by a LogSink
+type logEntry struct {
+	Event string `json:"event"`
+	Data  Event  `json:"data"`
+}
+
+// Handle writes e as a single line
+func (s *LogSink) Handle(e Event) error {
+	b, err := json.Marshal(logEntry{Event: e.Name(), Data: e})
+	if err != nil {
+		return errors.Wrapf(err, "Could not marshal %s event", e.Name())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.w.Write(append(b, '\n')); err != nil {
+		return errors.Wrapf(err, "Could not write %s event", e.Name())
+	}
+
+	return nil
+}
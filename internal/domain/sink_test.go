@@ -0,0 +1,39 @@
+package domain_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/domain"
+)
+
+func TestLogSink(t *testing.T) {
+
+	var buf bytes.Buffer
+
+	s := domain.NewLogSink(&buf)
+
+	for _, e := range domain.Events("e1", at, &internal.ToDo{ID: "1", Title: "Foo"}, &internal.ToDo{ID: "1", Title: "Bar"}) {
+		if err := s.Handle(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var entry struct {
+		Event string `json:"event"`
+		Data  struct {
+			ID       string `json:"id"`
+			OldTitle string `json:"oldTitle"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.Event != domain.RenamedEvent || entry.Data.ID != "e1:Renamed" || entry.Data.OldTitle != "Foo" {
+		t.Fatalf("Unexpected entry %s", buf.String())
+	}
+}
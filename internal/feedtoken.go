@@ -0,0 +1,12 @@
+package internal
+
+import "time"
+
+// FeedToken is a secret that grants read-only access to the calendar feed of a list. Calendar clients
+// can not send credentials, so the token is part of the feed URL.
+type FeedToken struct {
+	Token   string    `json:"token"`
+	UserID  string    `json:"userId"`
+	ListID  string    `json:"listId"`
+	Created time.Time `json:"created"`
+}
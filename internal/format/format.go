@@ -0,0 +1,194 @@
+package format
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+// Format is a file format ToDos can be written in
+type Format string
+
+const (
+	// CSV is comma separated values with a header row
+	CSV Format = "csv"
+	// Markdown is a GitHub task list
+	Markdown Format = "md"
+	// JSONLines is one JSON encoded ToDo per line
+	JSONLines Format = "jsonl"
+	// ToDoTxt is the todo.txt format described at https://github.com/todotxt/todo.txt
+	ToDoTxt Format = "todotxt"
+)
+
+// dateLayout is the date format used by todo.txt and for due dates in Markdown
+const dateLayout = "2006-01-02"
+
+// csvHeader is the header row written to CSV files
+var csvHeader = []string{"id", "listId", "title", "completed", "due", "modTime", "notes"}
+
+// ErrUnknownFormat is returned when a format name is not recognized
+var ErrUnknownFormat = errors.New("unknown format")
+
+// Parse returns the Format with the given name
+func Parse(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case CSV, Markdown, JSONLines, ToDoTxt:
+		return f, nil
+	default:
+		return "", errors.Wrapf(ErrUnknownFormat, "%s is not one of csv, md, jsonl or todotxt", name)
+	}
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case Markdown:
+		return "text/markdown; charset=utf-8"
+	case JSONLines:
+		return "application/x-ndjson"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// Filename returns a file name with the extension for the format
+func (f Format) Filename(name string) string {
+	switch f {
+	case ToDoTxt:
+		return name + ".txt"
+	default:
+		return name + "." + string(f)
+	}
+}
+
+// Write writes todos to w in the given format
+func Write(w io.Writer, f Format, todos []internal.ToDo) error {
+	switch f {
+	case CSV:
+		return writeCSV(w, todos)
+	case Markdown:
+		return writeMarkdown(w, todos)
+	case JSONLines:
+		return writeJSONLines(w, todos)
+	case ToDoTxt:
+		return writeToDoTxt(w, todos)
+	default:
+		return errors.Wrap(ErrUnknownFormat, string(f))
+	}
+}
+
+func writeCSV(w io.Writer, todos []internal.ToDo) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, t := range todos {
+		due := ""
+		if t.Due != nil {
+			due = t.Due.Format(time.RFC3339)
+		}
+
+		record := []string{
+			t.ID,
+			t.ListID,
+			t.Title,
+			strconv.FormatBool(t.Completed),
+			due,
+			t.ModTime.Format(time.RFC3339),
+			t.Notes,
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func writeMarkdown(w io.Writer, todos []internal.ToDo) error {
+	bw := bufio.NewWriter(w)
+
+	for _, t := range todos {
+		check := " "
+		if t.Completed {
+			check = "x"
+		}
+
+		line := fmt.Sprintf("- [%s] %s", check, singleLine(t.Title))
+		if t.Due != nil {
+			line += fmt.Sprintf(" (due %s)", t.Due.Format(dateLayout))
+		}
+
+		if _, err := fmt.Fprintln(bw, line); err != nil {
+			return err
+		}
+
+		// Notes are quoted under their item, so they are not read back as items
+		if t.Notes != "" {
+			for _, l := range strings.Split(strings.TrimRight(t.Notes, "\n"), "\n") {
+				if _, err := fmt.Fprintln(bw, strings.TrimRight("  > "+strings.TrimRight(l, "\r"), " ")); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeJSONLines(w io.Writer, todos []internal.ToDo) error {
+	enc := json.NewEncoder(w)
+
+	for _, t := range todos {
+		if err := enc.Encode(t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeToDoTxt(w io.Writer, todos []internal.ToDo) error {
+	bw := bufio.NewWriter(w)
+
+	for _, t := range todos {
+		parts := []string{}
+
+		// The completion date is not recorded, so the last modification is the best approximation
+		if t.Completed {
+			parts = append(parts, "x", t.ModTime.Format(dateLayout))
+		}
+
+		parts = append(parts, singleLine(t.Title))
+
+		if t.Due != nil {
+			parts = append(parts, "due:"+t.Due.Format(dateLayout))
+		}
+
+		if _, err := fmt.Fprintln(bw, strings.Join(parts, " ")); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// singleLine replaces line breaks in s with spaces, for formats with one ToDo per line
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,103 @@
+package format_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/format"
+	"github.com/pkg/errors"
+)
+
+var due = time.Date(2019, 7, 15, 0, 0, 0, 0, time.UTC)
+
+var todos = []internal.ToDo{
+	{
+		ID:      "1",
+		ListID:  "default",
+		Title:   "Write release notes",
+		Created: time.Date(2019, 6, 30, 9, 0, 0, 0, time.UTC),
+		ModTime: time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC),
+		Due:     &due,
+		Notes:   "Use the [template](https://wiki.example.com/release).\n\nAsk #docs to review.",
+	},
+	{
+		ID:        "2",
+		ListID:    "default",
+		Title:     "Tag, \"v1.0\"",
+		Completed: true,
+		Created:   time.Date(2019, 6, 30, 9, 0, 0, 0, time.UTC),
+		ModTime:   time.Date(2019, 7, 2, 12, 0, 0, 0, time.UTC),
+	},
+}
+
+func TestFormat(t *testing.T) {
+	t.Run("Parse", testParse)
+	t.Run("WriteCSV", testWriteCSV)
+	t.Run("WriteMarkdown", testWriteMarkdown)
+	t.Run("WriteJSONLines", testWriteJSONLines)
+	t.Run("WriteToDoTxt", testWriteToDoTxt)
+}
+
+func testParse(t *testing.T) {
+
+	f, err := format.Parse("CSV")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f != format.CSV {
+		t.Fatalf("Expected %s, got %s", format.CSV, f)
+	}
+
+	if _, err := format.Parse("pdf"); errors.Cause(err) != format.ErrUnknownFormat {
+		t.Fatalf("Expected %v, got %v", format.ErrUnknownFormat, err)
+	}
+
+	if name := format.ToDoTxt.Filename("todos"); name != "todos.txt" {
+		t.Fatalf("Expected todos.txt, got %s", name)
+	}
+}
+
+func testWrite(t *testing.T, f format.Format, expected string) {
+	var b bytes.Buffer
+
+	if err := format.Write(&b, f, todos); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.String() != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, b.String())
+	}
+}
+
+func testWriteCSV(t *testing.T) {
+	testWrite(t, format.CSV, `id,listId,title,completed,due,modTime,notes
+1,default,Write release notes,false,2019-07-15T00:00:00Z,2019-07-01T12:00:00Z,"Use the [template](https://wiki.example.com/release).
+
+Ask #docs to review."
+2,default,"Tag, ""v1.0""",true,,2019-07-02T12:00:00Z,
+`)
+}
+
+func testWriteMarkdown(t *testing.T) {
+	testWrite(t, format.Markdown, `- [ ] Write release notes (due 2019-07-15)
+  > Use the [template](https://wiki.example.com/release).
+  >
+  > Ask #docs to review.
+- [x] Tag, "v1.0"
+`)
+}
+
+func testWriteJSONLines(t *testing.T) {
+	testWrite(t, format.JSONLines, `{"id":"1","listId":"default","title":"Write release notes","completed":false,"due":"2019-07-15T00:00:00Z","created":"2019-06-30T09:00:00Z","modTime":"2019-07-01T12:00:00Z","notes":"Use the [template](https://wiki.example.com/release).\n\nAsk #docs to review."}
+{"id":"2","listId":"default","title":"Tag, \"v1.0\"","completed":true,"created":"2019-06-30T09:00:00Z","modTime":"2019-07-02T12:00:00Z"}
+`)
+}
+
+func testWriteToDoTxt(t *testing.T) {
+	testWrite(t, format.ToDoTxt, `Write release notes due:2019-07-15
+x 2019-07-02 Tag, "v1.0"
+`)
+}
@@ -0,0 +1,313 @@
+package format
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+// Row is a ToDo read from a file, or the reason it could not be read
+type Row struct {
+	Line int
+	ToDo internal.ToDo
+	Err  error
+}
+
+// csvAliases are the header names recognized for each field when no mapping is given
+var csvAliases = map[string][]string{
+	"title":     {"title", "name", "task", "summary", "description"},
+	"notes":     {"notes", "note", "details", "body"},
+	"completed": {"completed", "done", "status", "complete"},
+	"due":       {"due", "due date", "duedate", "deadline"},
+}
+
+var (
+	// markdownTask matches a GitHub task list item
+	markdownTask = regexp.MustCompile(`^\s*[-*+] \[([ xX])\]\s+(.*)$`)
+	// markdownDue matches the due date suffix written by Markdown export
+	markdownDue = regexp.MustCompile(`\s*\(due (\d{4}-\d{2}-\d{2})\)$`)
+	// todoTxtPriority matches a todo.txt priority
+	todoTxtPriority = regexp.MustCompile(`^\([A-Z]\)$`)
+	// todoTxtDate matches a todo.txt date
+	todoTxtDate = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// ErrMissingColumn is returned when a CSV file has no column for a required field
+var ErrMissingColumn = errors.New("missing column")
+
+// Read reads the ToDos in r. Rows that can not be parsed are returned with Err set rather than failing
+// the whole read. columns maps field names (title, notes, completed, due) to CSV header names, overriding the
+// headers that are recognized by default; it is ignored for other formats.
+func Read(r io.Reader, f Format, columns map[string]string) ([]Row, error) {
+	switch f {
+	case CSV:
+		return readCSV(r, columns)
+	case Markdown:
+		return readLines(r, parseMarkdown)
+	case JSONLines:
+		return readLines(r, parseJSONLine)
+	case ToDoTxt:
+		return readLines(r, parseToDoTxt)
+	default:
+		return nil, errors.Wrap(ErrUnknownFormat, string(f))
+	}
+}
+
+// readLines calls parse for every non blank line in r. parse returns false for lines that do not hold
+// a ToDo.
+func readLines(r io.Reader, parse func(string, *Row) bool) ([]Row, error) {
+	rows := []Row{}
+
+	s := bufio.NewScanner(r)
+	line := 0
+
+	for s.Scan() {
+		line++
+
+		text := strings.TrimSpace(s.Text())
+		if text == "" {
+			continue
+		}
+
+		row := Row{Line: line}
+		if parse(text, &row) {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows, s.Err()
+}
+
+func parseMarkdown(text string, row *Row) bool {
+	m := markdownTask.FindStringSubmatch(text)
+	if m == nil {
+		return false
+	}
+
+	row.ToDo.Completed = m[1] != " "
+	row.ToDo.Title = m[2]
+
+	if d := markdownDue.FindStringSubmatch(row.ToDo.Title); d != nil {
+		due, err := time.Parse(dateLayout, d[1])
+		if err != nil {
+			row.Err = errors.Wrap(err, "invalid due date")
+			return true
+		}
+		row.ToDo.Due = &due
+		row.ToDo.Title = strings.TrimSuffix(row.ToDo.Title, d[0])
+	}
+
+	return true
+}
+
+func parseJSONLine(text string, row *Row) bool {
+	if err := json.Unmarshal([]byte(text), &row.ToDo); err != nil {
+		row.Err = errors.Wrap(err, "invalid JSON")
+	}
+
+	row.ToDo.ID = ""
+	row.ToDo.ListID = ""
+
+	return true
+}
+
+// parseToDoTxt parses a line as described at https://github.com/todotxt/todo.txt. Projects and contexts
+// are kept in the title, as is conventional, and become the tags of the ToDo. ToDos have no priority, so
+// it is kept at the start of the title, where todo.txt export writes it back. Completion and creation
+// dates are skipped, as ToDos do not record them.
+func parseToDoTxt(text string, row *Row) bool {
+	fields := strings.Fields(text)
+
+	if len(fields) > 0 && fields[0] == "x" {
+		row.ToDo.Completed = true
+		fields = fields[1:]
+
+		// Completion date, which must be followed by a creation date if one is given
+		if len(fields) > 0 && todoTxtDate.MatchString(fields[0]) {
+			fields = fields[1:]
+		}
+	}
+
+	words := []string{}
+
+	if len(fields) > 0 && todoTxtPriority.MatchString(fields[0]) {
+		words = append(words, fields[0])
+		fields = fields[1:]
+	}
+
+	// Creation date
+	if len(fields) > 0 && todoTxtDate.MatchString(fields[0]) {
+		fields = fields[1:]
+	}
+
+	description := false
+
+	for _, f := range fields {
+		switch {
+		case strings.HasPrefix(f, "+") && len(f) > 1, strings.HasPrefix(f, "@") && len(f) > 1:
+			row.ToDo.Tags = append(row.ToDo.Tags, f[1:])
+		case strings.HasPrefix(f, "due:"):
+			due, err := time.Parse(dateLayout, strings.TrimPrefix(f, "due:"))
+			if err != nil {
+				row.Err = errors.Wrap(err, "invalid due date")
+				return true
+			}
+			row.ToDo.Due = &due
+			continue
+		}
+
+		words, description = append(words, f), true
+	}
+
+	row.ToDo.Title = strings.Join(words, " ")
+
+	if !description {
+		row.Err = errors.New("missing description")
+	}
+
+	return true
+}
+
+func readCSV(r io.Reader, columns map[string]string) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return []Row{}, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "Could not read CSV header")
+	}
+
+	index, err := csvColumns(header, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []Row{}
+
+	// Line numbers assume that quoted values do not span lines
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+
+		row := Row{Line: line}
+
+		if err != nil {
+			if perr, ok := err.(*csv.ParseError); ok {
+				row.Line = perr.Line
+			}
+			row.Err = err
+			rows = append(rows, row)
+			continue
+		}
+
+		get := func(field string) string {
+			if i, ok := index[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row.ToDo.Title = get("title")
+		if row.ToDo.Title == "" {
+			row.Err = errors.New("missing title")
+		}
+
+		// Notes keep their line breaks and indentation
+		if i, ok := index["notes"]; ok && i < len(record) {
+			row.ToDo.Notes = strings.TrimRight(record[i], " \t\r\n")
+		}
+
+		if v := get("completed"); v != "" {
+			row.ToDo.Completed, err = parseCompleted(v)
+			if err != nil {
+				row.Err = err
+			}
+		}
+
+		if v := get("due"); v != "" {
+			due, err := parseDate(v)
+			if err != nil {
+				row.Err = err
+			}
+			row.ToDo.Due = due
+		}
+
+		rows = append(rows, row)
+	}
+}
+
+// csvColumns returns the index of the column for each field, using columns to map fields to header
+// names before falling back to the recognized aliases
+func csvColumns(header []string, columns map[string]string) (map[string]int, error) {
+	positions := make(map[string]int)
+	for i, h := range header {
+		positions[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	index := make(map[string]int)
+
+	for field, aliases := range csvAliases {
+		if name, ok := columns[field]; ok {
+			i, ok := positions[strings.ToLower(name)]
+			if !ok {
+				return nil, errors.Wrapf(ErrMissingColumn, "no column named %s for %s", name, field)
+			}
+			index[field] = i
+			continue
+		}
+
+		for _, a := range aliases {
+			if i, ok := positions[a]; ok {
+				index[field] = i
+				break
+			}
+		}
+	}
+
+	if _, ok := index["title"]; !ok {
+		return nil, errors.Wrap(ErrMissingColumn, "no title column")
+	}
+
+	return index, nil
+}
+
+// parseCompleted parses the completed column, accepting the values commonly used by spreadsheets
+func parseCompleted(v string) (bool, error) {
+	switch strings.ToLower(v) {
+	case "x", "y", "yes", "done", "completed", "complete":
+		return true, nil
+	case "n", "no", "open", "todo", "pending":
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, errors.Errorf("invalid completed value %s", v)
+	}
+
+	return b, nil
+}
+
+// parseDate parses an RFC 3339 timestamp or a plain date
+func parseDate(v string) (*time.Time, error) {
+	for _, layout := range []string{time.RFC3339, dateLayout} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return &t, nil
+		}
+	}
+
+	return nil, errors.Errorf("invalid due date %s", v)
+}
@@ -0,0 +1,192 @@
+package format_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal/format"
+	"github.com/pkg/errors"
+)
+
+func TestRead(t *testing.T) {
+	t.Run("ReadToDoTxt", testReadToDoTxt)
+	t.Run("ReadMarkdown", testReadMarkdown)
+	t.Run("ReadCSV", testReadCSV)
+	t.Run("ReadCSVMapping", testReadCSVMapping)
+	t.Run("ReadCSVNotes", testReadCSVNotes)
+	t.Run("ReadCSVMissingTitle", testReadCSVMissingTitle)
+	t.Run("ReadJSONLines", testReadJSONLines)
+}
+
+func testReadToDoTxt(t *testing.T) {
+
+	in := `(A) 2019-06-01 Call the bank @phone +finances due:2019-07-15
+x 2019-07-02 2019-06-20 Renew certs +infra
+
+x
+not a date due:someday
+`
+
+	rows, err := format.Read(strings.NewReader(in), format.ToDoTxt, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 4 {
+		t.Fatalf("Expected 4 rows, got %d", len(rows))
+	}
+
+	r := rows[0]
+	if r.ToDo.Title != "(A) Call the bank @phone +finances" || r.ToDo.Completed {
+		t.Fatalf("Unexpected first row %+v", r)
+	}
+
+	if len(r.ToDo.Tags) != 2 || r.ToDo.Tags[0] != "phone" || r.ToDo.Tags[1] != "finances" {
+		t.Fatalf("Expected contexts and projects to become tags, got %v", r.ToDo.Tags)
+	}
+
+	if r.ToDo.Due == nil || r.ToDo.Due.Format("2006-01-02") != "2019-07-15" {
+		t.Fatalf("Unexpected due date %v", r.ToDo.Due)
+	}
+
+	if !rows[1].ToDo.Completed || rows[1].ToDo.Title != "Renew certs +infra" || rows[1].Line != 2 {
+		t.Fatalf("Unexpected second row %+v", rows[1])
+	}
+
+	if rows[2].Err == nil || rows[2].Line != 4 {
+		t.Fatalf("Expected error for empty description on line 4, got %+v", rows[2])
+	}
+
+	if rows[3].Err == nil || rows[3].Line != 5 {
+		t.Fatalf("Expected error for invalid due date on line 5, got %+v", rows[3])
+	}
+}
+
+func testReadMarkdown(t *testing.T) {
+
+	in := `# Release checklist
+
+- [ ] Write release notes (due 2019-07-15)
+- [x] Tag v1.0
+  * [X] Nested item
+Some prose that is not a task
+`
+
+	rows, err := format.Read(strings.NewReader(in), format.Markdown, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(rows))
+	}
+
+	if rows[0].ToDo.Title != "Write release notes" || rows[0].ToDo.Due == nil || rows[0].Line != 3 {
+		t.Fatalf("Unexpected first row %+v", rows[0])
+	}
+
+	if !rows[1].ToDo.Completed || !rows[2].ToDo.Completed || rows[2].ToDo.Title != "Nested item" {
+		t.Fatalf("Unexpected rows %+v", rows[1:])
+	}
+}
+
+func testReadCSV(t *testing.T) {
+
+	in := `Task,Done,Due Date,Owner
+Write release notes,no,2019-07-15,sam
+Tag v1.0,yes,,sam
+,yes,,sam
+Deploy,maybe,,sam
+`
+
+	rows, err := format.Read(strings.NewReader(in), format.CSV, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 4 {
+		t.Fatalf("Expected 4 rows, got %d", len(rows))
+	}
+
+	if rows[0].ToDo.Title != "Write release notes" || rows[0].ToDo.Completed || rows[0].ToDo.Due == nil {
+		t.Fatalf("Unexpected first row %+v", rows[0])
+	}
+
+	if !rows[1].ToDo.Completed || rows[1].Line != 3 {
+		t.Fatalf("Unexpected second row %+v", rows[1])
+	}
+
+	if rows[2].Err == nil || rows[3].Err == nil {
+		t.Fatal("Expected errors for missing title and invalid completed value")
+	}
+}
+
+func testReadCSVNotes(t *testing.T) {
+
+	var b bytes.Buffer
+
+	if err := format.Write(&b, format.CSV, todos); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := format.Read(&b, format.CSV, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 2 || rows[0].ToDo.Notes != todos[0].Notes || rows[1].ToDo.Notes != "" {
+		t.Fatalf("Expected notes to be read back, got %+v", rows)
+	}
+}
+
+func testReadCSVMapping(t *testing.T) {
+
+	in := "Item,Finished\nWrite release notes,true\n"
+
+	rows, err := format.Read(strings.NewReader(in), format.CSV, map[string]string{"title": "Item", "completed": "finished"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 || rows[0].ToDo.Title != "Write release notes" || !rows[0].ToDo.Completed {
+		t.Fatalf("Unexpected rows %+v", rows)
+	}
+}
+
+func testReadCSVMissingTitle(t *testing.T) {
+
+	_, err := format.Read(strings.NewReader("Owner,Done\nsam,true\n"), format.CSV, nil)
+	if errors.Cause(err) != format.ErrMissingColumn {
+		t.Fatalf("Expected %v, got %v", format.ErrMissingColumn, err)
+	}
+
+	_, err = format.Read(strings.NewReader("Task\nDeploy\n"), format.CSV, map[string]string{"title": "Item"})
+	if errors.Cause(err) != format.ErrMissingColumn {
+		t.Fatalf("Expected %v, got %v", format.ErrMissingColumn, err)
+	}
+}
+
+func testReadJSONLines(t *testing.T) {
+
+	in := `{"id":"1","title":"Write release notes","completed":true}
+{"title":
+`
+
+	rows, err := format.Read(strings.NewReader(in), format.JSONLines, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(rows))
+	}
+
+	if rows[0].ToDo.ID != "" || !rows[0].ToDo.Completed {
+		t.Fatalf("Expected completed ToDo without an ID, got %+v", rows[0].ToDo)
+	}
+
+	if rows[1].Err == nil {
+		t.Fatal("Expected error for invalid JSON")
+	}
+}
@@ -0,0 +1,143 @@
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+const (
+	// ContentType is the MIME type of iCalendar data
+	ContentType = "text/calendar; charset=utf-8"
+	// prodID identifies the product that created the calendar
+	prodID = "-//benjaminbartels//todo//EN"
+	// dateTimeLayout is the RFC 5545 DATE-TIME format in UTC
+	dateTimeLayout = "20060102T150405Z"
+	// dateLayout is the RFC 5545 DATE format
+	dateLayout = "20060102"
+	// maxLineLength is the number of octets after which content lines are folded
+	maxLineLength = 75
+)
+
+// textEscaper escapes TEXT property values
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// WriteCalendar writes todos to w as an RFC 5545 VCALENDAR of VTODO components. stamp is the time the
+// calendar was generated.
+func WriteCalendar(w io.Writer, name string, todos []internal.ToDo, stamp time.Time) error {
+	bw := bufio.NewWriter(w)
+	cw := &contentWriter{w: bw}
+
+	cw.line("BEGIN", "VCALENDAR")
+	cw.line("VERSION", "2.0")
+	cw.line("PRODID", prodID)
+	cw.line("CALSCALE", "GREGORIAN")
+
+	if name != "" {
+		cw.line("X-WR-CALNAME", escape(name))
+	}
+
+	for _, t := range todos {
+		writeVTodo(cw, t, stamp)
+	}
+
+	cw.line("END", "VCALENDAR")
+
+	if cw.err != nil {
+		return cw.err
+	}
+
+	return bw.Flush()
+}
+
+// UID returns the globally unique identifier used for a ToDo. CalDAV clients choose the UID of the
+// ToDos they create and expect it back unchanged, so it is the ID itself.
+func UID(todo internal.ToDo) string {
+	return todo.ID
+}
+
+func writeVTodo(cw *contentWriter, t internal.ToDo, stamp time.Time) {
+	cw.line("BEGIN", "VTODO")
+	cw.line("UID", UID(t))
+	cw.line("DTSTAMP", formatDateTime(stamp))
+	cw.line("SUMMARY", escape(t.Title))
+
+	if t.Notes != "" {
+		cw.line("DESCRIPTION", escape(t.Notes))
+	}
+
+	if t.Completed {
+		cw.line("STATUS", "COMPLETED")
+		cw.line("PERCENT-COMPLETE", "100")
+		cw.line("COMPLETED", formatDateTime(t.ModTime))
+	} else {
+		cw.line("STATUS", "NEEDS-ACTION")
+	}
+
+	if !t.ModTime.IsZero() {
+		cw.line("LAST-MODIFIED", formatDateTime(t.ModTime))
+	}
+
+	if t.Due != nil {
+		if isDate(*t.Due) {
+			cw.line("DUE;VALUE=DATE", t.Due.UTC().Format(dateLayout))
+		} else {
+			cw.line("DUE", formatDateTime(*t.Due))
+		}
+	}
+
+	cw.line("END", "VTODO")
+}
+
+// contentWriter writes folded content lines, remembering the first error
+type contentWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes a content line, folding it so no line is longer than maxLineLength octets
+func (cw *contentWriter) line(name, value string) {
+	if cw.err != nil {
+		return
+	}
+
+	s := name + ":" + value
+	limit := maxLineLength
+
+	for len(s) > limit {
+		// Do not split a multi-byte UTF-8 sequence
+		i := limit
+		for i > 0 && !utf8.RuneStart(s[i]) {
+			i--
+		}
+
+		if _, cw.err = cw.w.WriteString(s[:i] + "\r\n "); cw.err != nil {
+			return
+		}
+
+		s = s[i:]
+		limit = maxLineLength - 1
+	}
+
+	_, cw.err = cw.w.WriteString(s + "\r\n")
+}
+
+// escape escapes a TEXT value
+func escape(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// formatDateTime formats t as a UTC DATE-TIME
+func formatDateTime(t time.Time) string {
+	return t.UTC().Format(dateTimeLayout)
+}
+
+// isDate reports whether t has no time of day, in which case it is written as a DATE
+func isDate(t time.Time) bool {
+	t = t.UTC()
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+}
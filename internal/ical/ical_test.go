@@ -0,0 +1,114 @@
+package ical_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/ical"
+)
+
+var stamp = time.Date(2019, 7, 20, 8, 30, 0, 0, time.UTC)
+
+func TestWriteCalendar(t *testing.T) {
+	t.Run("VTodo", testVTodo)
+	t.Run("Escaping", testEscaping)
+	t.Run("Folding", testFolding)
+}
+
+func write(t *testing.T, todos ...internal.ToDo) string {
+	var b bytes.Buffer
+
+	if err := ical.WriteCalendar(&b, "default", todos, stamp); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.String()
+}
+
+func testVTodo(t *testing.T) {
+
+	due := time.Date(2019, 7, 31, 0, 0, 0, 0, time.UTC)
+	dueTime := time.Date(2019, 8, 1, 17, 30, 0, 0, time.FixedZone("PDT", -7*60*60))
+
+	out := write(t,
+		internal.ToDo{
+			ID:      "1",
+			Title:   "Rotate certs",
+			Due:     &due,
+			ModTime: time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC),
+		},
+		internal.ToDo{
+			ID:        "2",
+			Title:     "Hand off on-call",
+			Completed: true,
+			Due:       &dueTime,
+			ModTime:   time.Date(2019, 7, 2, 12, 0, 0, 0, time.UTC),
+		},
+	)
+
+	expected := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//benjaminbartels//todo//EN",
+		"CALSCALE:GREGORIAN",
+		"X-WR-CALNAME:default",
+		"BEGIN:VTODO",
+		"UID:1",
+		"DTSTAMP:20190720T083000Z",
+		"SUMMARY:Rotate certs",
+		"STATUS:NEEDS-ACTION",
+		"LAST-MODIFIED:20190701T120000Z",
+		"DUE;VALUE=DATE:20190731",
+		"END:VTODO",
+		"BEGIN:VTODO",
+		"UID:2",
+		"DTSTAMP:20190720T083000Z",
+		"SUMMARY:Hand off on-call",
+		"STATUS:COMPLETED",
+		"PERCENT-COMPLETE:100",
+		"COMPLETED:20190702T120000Z",
+		"LAST-MODIFIED:20190702T120000Z",
+		"DUE:20190802T003000Z",
+		"END:VTODO",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	if out != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, out)
+	}
+}
+
+func testEscaping(t *testing.T) {
+
+	out := write(t, internal.ToDo{ID: "1", Title: "Call Sam; then Alex, maybe\nC:\\temp"})
+
+	if !strings.Contains(out, `SUMMARY:Call Sam\; then Alex\, maybe\nC:\\temp`+"\r\n") {
+		t.Fatalf("Expected escaped SUMMARY, got:\n%s", out)
+	}
+
+	out = write(t, internal.ToDo{ID: "1", Title: "Rotate certs", Notes: "See the runbook;\n- drain, then patch"})
+
+	if !strings.Contains(out, `DESCRIPTION:See the runbook\;\n- drain\, then patch`+"\r\n") {
+		t.Fatalf("Expected escaped DESCRIPTION, got:\n%s", out)
+	}
+}
+
+func testFolding(t *testing.T) {
+
+	out := write(t, internal.ToDo{ID: "1", Title: strings.Repeat("ü", 100)})
+
+	for _, line := range strings.Split(out, "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("Expected lines of at most 75 octets, got %d", len(line))
+		}
+	}
+
+	unfolded := strings.Replace(out, "\r\n ", "", -1)
+	if !strings.Contains(unfolded, "SUMMARY:"+strings.Repeat("ü", 100)+"\r\n") {
+		t.Fatal("Expected folded SUMMARY to unfold to the original value")
+	}
+}
@@ -0,0 +1,234 @@
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+// localDateTimeLayout is the RFC 5545 DATE-TIME format for floating and TZID qualified times
+const localDateTimeLayout = "20060102T150405"
+
+// ErrNoToDo is returned when a calendar does not contain a VTODO component
+var ErrNoToDo = errors.New("calendar has no VTODO")
+
+// property is a content line of an iCalendar object
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// ReadToDo reads a calendar object containing a VTODO, as sent by CalDAV clients, and returns the ToDo it
+// describes. The ID of the ToDo is the UID of the VTODO. Properties that ToDos have no field for are
+// ignored, as are recurrence overrides after the first VTODO.
+func ReadToDo(r io.Reader) (*internal.ToDo, error) {
+	props, err := readProperties(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		todo      *internal.ToDo
+		depth     int
+		status    string
+		completed bool
+	)
+
+	for _, p := range props {
+		switch {
+		case p.name == "BEGIN":
+			if todo == nil && depth == 0 && strings.EqualFold(p.value, "VTODO") {
+				todo = &internal.ToDo{}
+				depth = 1
+			} else if depth > 0 {
+				depth++
+			}
+			continue
+		case p.name == "END":
+			if depth > 0 {
+				depth--
+			}
+			continue
+		case depth != 1:
+			// Outside the VTODO, or in one of its sub-components such as VALARM
+			continue
+		}
+
+		switch p.name {
+		case "UID":
+			todo.ID = p.value
+		case "SUMMARY":
+			todo.Title = unescape(p.value)
+		case "DESCRIPTION":
+			todo.Notes = unescape(p.value)
+		case "STATUS":
+			status = strings.ToUpper(p.value)
+		case "COMPLETED":
+			completed = true
+		case "DUE":
+			due, err := parseTime(p)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Could not parse DUE %s", p.value)
+			}
+			todo.Due = &due
+		}
+	}
+
+	if todo == nil {
+		return nil, ErrNoToDo
+	}
+
+	// Some clients only set COMPLETED when a task is checked off
+	todo.Completed = status == "COMPLETED" || (status == "" && completed)
+
+	return todo, nil
+}
+
+// readProperties unfolds and parses the content lines read from r
+func readProperties(r io.Reader) ([]property, error) {
+	var (
+		props []property
+		lines []string
+	)
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "Could not read calendar")
+	}
+
+	for i, line := range lines {
+		p, err := parseProperty(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not parse content line %d", i+1)
+		}
+		props = append(props, p)
+	}
+
+	return props, nil
+}
+
+// parseProperty parses an unfolded content line of the form name *(";" param) ":" value
+func parseProperty(line string) (property, error) {
+	p := property{params: make(map[string]string)}
+
+	// Find the end of the name and parameters, which is the first colon that is not quoted
+	end, quoted := -1, false
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ':' && !quoted {
+			end = i
+			break
+		}
+	}
+
+	if end < 0 {
+		return p, errors.New("missing ':'")
+	}
+
+	p.value = line[end+1:]
+
+	parts := splitParams(line[:end])
+	p.name = strings.ToUpper(parts[0])
+
+	if p.name == "" {
+		return p, errors.New("missing property name")
+	}
+
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			return p, errors.Errorf("invalid parameter %s", param)
+		}
+		p.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+
+	return p, nil
+}
+
+// splitParams splits s at semicolons that are not quoted
+func splitParams(s string) []string {
+	var (
+		parts  []string
+		start  int
+		quoted bool
+	)
+
+	for i, c := range s {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ';' && !quoted {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// parseTime parses a DATE or DATE-TIME value. Times with a TZID are resolved using the IANA time zone
+// database, and floating times and unknown time zones are treated as UTC.
+func parseTime(p property) (time.Time, error) {
+	if strings.EqualFold(p.params["VALUE"], "DATE") || len(p.value) == len(dateLayout) {
+		return time.Parse(dateLayout, p.value)
+	}
+
+	if strings.HasSuffix(p.value, "Z") {
+		return time.Parse(dateTimeLayout, p.value)
+	}
+
+	loc := time.UTC
+	if tzid, ok := p.params["TZID"]; ok {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+
+	return time.ParseInLocation(localDateTimeLayout, p.value, loc)
+}
+
+// unescape reverses the escaping of a TEXT value
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String()
+}
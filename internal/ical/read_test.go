@@ -0,0 +1,149 @@
+package ical_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/ical"
+)
+
+var todoWithLongTitle = internal.ToDo{
+	ID:        "a8a43435-20d8-4af2-8f94-f504aff2c6f3",
+	Title:     "Rotate the wildcard certificate; update the load balancers, CDN and the status page before Friday",
+	Notes:     "Follow https://wiki.example.com/runbooks/tls,\nthen *announce* it; in #ops.",
+	Completed: true,
+}
+
+func TestReadToDo(t *testing.T) {
+	t.Run("Thunderbird", testReadThunderbird)
+	t.Run("CompletedWithoutStatus", testReadCompletedWithoutStatus)
+	t.Run("DueDate", testReadDueDate)
+	t.Run("NoToDo", testReadNoToDo)
+	t.Run("Malformed", testReadMalformed)
+	t.Run("RoundTrip", testReadRoundTrip)
+}
+
+func testReadThunderbird(t *testing.T) {
+
+	body := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"PRODID:-//Mozilla.org/NONSGML Mozilla Calendar V1.1//EN",
+		"VERSION:2.0",
+		"BEGIN:VTIMEZONE",
+		"TZID:America/Los_Angeles",
+		"BEGIN:DAYLIGHT",
+		"TZOFFSETFROM:-0800",
+		"TZOFFSETTO:-0700",
+		"DTSTART:19700308T020000",
+		"END:DAYLIGHT",
+		"END:VTIMEZONE",
+		"BEGIN:VTODO",
+		"CREATED:20190719T190802Z",
+		"LAST-MODIFIED:20190719T190822Z",
+		"DTSTAMP:20190719T190822Z",
+		"UID:b9ad0f4c-2a6b-4e5f-a0f2-0c9c5fbd6a43",
+		"SUMMARY:Review release notes\\, then tag v1.2\\; notify #releases about t",
+		" he rollout",
+		"STATUS:NEEDS-ACTION",
+		"DUE;TZID=America/Los_Angeles:20190726T170000",
+		"X-MOZ-GENERATION:2",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:Default Mozilla Description",
+		"STATUS:COMPLETED",
+		"TRIGGER;VALUE=DURATION:-PT15M",
+		"END:VALARM",
+		"END:VTODO",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	todo, err := ical.ReadToDo(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if todo.ID != "b9ad0f4c-2a6b-4e5f-a0f2-0c9c5fbd6a43" {
+		t.Fatalf("Expected ID from UID, got '%s'", todo.ID)
+	}
+
+	if todo.Title != "Review release notes, then tag v1.2; notify #releases about the rollout" {
+		t.Fatalf("Unexpected Title '%s'", todo.Title)
+	}
+
+	if todo.Completed || todo.Notes != "" {
+		t.Fatal("Expected ToDo not to be changed by the STATUS and DESCRIPTION of its VALARM")
+	}
+
+	expected := time.Date(2019, 7, 27, 0, 0, 0, 0, time.UTC)
+	if todo.Due == nil || !todo.Due.Equal(expected) {
+		t.Fatalf("Expected Due %s, got %v", expected, todo.Due)
+	}
+}
+
+func testReadCompletedWithoutStatus(t *testing.T) {
+
+	body := "BEGIN:VCALENDAR\nBEGIN:VTODO\nUID:1\nSUMMARY:Done\nCOMPLETED:20190720T100000Z\nEND:VTODO\nEND:VCALENDAR\n"
+
+	todo, err := ical.ReadToDo(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !todo.Completed {
+		t.Fatal("Expected ToDo to be completed")
+	}
+}
+
+func testReadDueDate(t *testing.T) {
+
+	body := "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:1\r\nDUE;VALUE=DATE:20190731\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+
+	todo, err := ical.ReadToDo(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := time.Date(2019, 7, 31, 0, 0, 0, 0, time.UTC)
+	if todo.Due == nil || !todo.Due.Equal(expected) {
+		t.Fatalf("Expected Due %s, got %v", expected, todo.Due)
+	}
+}
+
+func testReadNoToDo(t *testing.T) {
+
+	body := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:1\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+	if _, err := ical.ReadToDo(strings.NewReader(body)); err != ical.ErrNoToDo {
+		t.Fatalf("Expected %v, got %v", ical.ErrNoToDo, err)
+	}
+}
+
+func testReadMalformed(t *testing.T) {
+
+	for _, body := range []string{
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nSUMMARY no colon\r\nEND:VTODO\r\nEND:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nDUE:tomorrow\r\nEND:VTODO\r\nEND:VCALENDAR\r\n",
+	} {
+		if _, err := ical.ReadToDo(strings.NewReader(body)); err == nil {
+			t.Fatalf("Expected Error for %q", body)
+		}
+	}
+}
+
+func testReadRoundTrip(t *testing.T) {
+
+	out := write(t, todoWithLongTitle)
+
+	todo, err := ical.ReadToDo(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if todo.ID != todoWithLongTitle.ID || todo.Title != todoWithLongTitle.Title || !todo.Completed ||
+		todo.Notes != todoWithLongTitle.Notes {
+		t.Fatalf("Expected %+v, got %+v", todoWithLongTitle, todo)
+	}
+}
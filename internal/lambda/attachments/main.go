@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+
+	awslambda "github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/benjaminbartels/todo/internal/blob/s3"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+// bucketEnv names the environment variable holding the bucket attachments are kept in
+const bucketEnv = "ATTACHMENTS_BUCKET"
+
+func main() {
+
+	// Retries are handled by the repository's RetryPolicy rather than the SDK
+	s, err := session.NewSession(aws.NewConfig().WithRegion("us-west-2").WithMaxRetries(0))
+	if err != nil {
+		panic(err)
+	}
+
+	bucket := os.Getenv(bucketEnv)
+	if bucket == "" {
+		panic(bucketEnv + " is not set")
+	}
+
+	// S3 has no RetryPolicy of its own, so the SDK retries its requests
+	store := s3.NewStore(awss3.New(s, aws.NewConfig().WithMaxRetries(3)), bucket)
+
+	h := handlers.NewAttachmentHandler(dynamodb.NewToDoRepo(awsdynamodb.New(s)), store)
+
+	awslambda.Start(h.Handle)
+}
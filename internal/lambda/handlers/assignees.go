@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+)
+
+// maxAssignees is the most users a ToDo can be assigned to
+const maxAssignees = 20
+
+// prepareAssignees normalizes the assignees of todo and checks that the users assigned since previous,
+// which is nil when todo is created, are members of its list. Users who have left the list stay assigned
+// until they are removed, so edits to other fields are not rejected.
+func (h *ToDoHandler) prepareAssignees(previous, todo *internal.ToDo) error {
+
+	todo.AssigneeIDs = internal.NormalizeAssignees(todo.AssigneeIDs)
+
+	if len(todo.AssigneeIDs) > maxAssignees {
+		return errors.Wrapf(ErrBadRequest, "a ToDo can be assigned to at most %d users", maxAssignees)
+	}
+
+	listID := todoListID(todo)
+	var before []string
+
+	if previous != nil {
+		listID, before = todoListID(previous), previous.AssigneeIDs
+	}
+
+	added := internal.AddedAssignees(before, todo.AssigneeIDs)
+	if len(added) == 0 {
+		return nil
+	}
+
+	if h.members == nil {
+		return errors.Wrap(ErrBadRequest, "ToDos can only be assigned to the members of shared lists")
+	}
+
+	members, err := h.members.GetByList(listID)
+	if err != nil {
+		return repoError(err)
+	}
+
+	for _, userID := range added {
+		if member(members, userID) < 0 {
+			return errors.Wrapf(ErrBadRequest, "%s is not a member of list %s", userID, listID)
+		}
+	}
+
+	return nil
+}
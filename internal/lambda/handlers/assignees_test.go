@@ -0,0 +1,137 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+func TestAssignees(t *testing.T) {
+	t.Run("CreateToDoWithAssignees", testCreateToDoWithAssignees)
+	t.Run("CreateToDoWithNonMember", testCreateToDoWithNonMember)
+	t.Run("CreateToDoWithoutMembers", testCreateToDoWithoutMembers)
+	t.Run("UpdateKeepsFormerMember", testUpdateKeepsFormerMember)
+	t.Run("SyncWithNonMember", testSyncWithNonMember)
+}
+
+func testCreateToDoWithAssignees(t *testing.T) {
+
+	m, todos := memoryRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:   "/todos",
+		HTTPMethod: http.MethodPost,
+		Body:       `{"title":"Write report","assigneeIds":["user-2"," user-1","user-2"]}`,
+	}
+
+	resp, err := handlers.NewToDoHandlerWithMembers(m, staticMembers()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	if got := todos[""].AssigneeIDs; !reflect.DeepEqual(got, []string{"user-1", "user-2"}) {
+		t.Fatalf("Expected assignees to be normalized, got %v", got)
+	}
+}
+
+func testCreateToDoWithNonMember(t *testing.T) {
+
+	m, _ := memoryRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:   "/todos",
+		HTTPMethod: http.MethodPost,
+		Body:       `{"title":"Write report","assigneeIds":["user-3"]}`,
+	}
+
+	resp, err := handlers.NewToDoHandlerWithMembers(m, staticMembers()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest || m.SaveInvoked {
+		t.Fatalf("Expected status 400, got %d", resp.StatusCode)
+	}
+}
+
+func testCreateToDoWithoutMembers(t *testing.T) {
+
+	m, _ := memoryRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:   "/todos",
+		HTTPMethod: http.MethodPost,
+		Body:       `{"title":"Write report","assigneeIds":["user-1"]}`,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest || m.SaveInvoked {
+		t.Fatalf("Expected status 400, got %d", resp.StatusCode)
+	}
+}
+
+func testUpdateKeepsFormerMember(t *testing.T) {
+
+	// user-3 was assigned before they left the list
+	m, todos := memoryRepo(internal.ToDo{ID: "1", Title: "Write report", AssigneeIDs: []string{"user-3"}})
+
+	b, _ := json.Marshal(internal.ToDo{ID: "1", Title: "Write the report", AssigneeIDs: []string{"user-3", "user-1"}})
+
+	req := events.APIGatewayProxyRequest{
+		Resource:       "/todos/{id}",
+		HTTPMethod:     http.MethodPut,
+		PathParameters: map[string]string{"id": "1"},
+		Body:           string(b),
+	}
+
+	resp, err := handlers.NewToDoHandlerWithMembers(m, staticMembers()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	if got := todos["1"].AssigneeIDs; !reflect.DeepEqual(got, []string{"user-1", "user-3"}) {
+		t.Fatalf("Unexpected assignees %v", got)
+	}
+}
+
+func testSyncWithNonMember(t *testing.T) {
+
+	m, _ := memoryRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:   "/todos/sync",
+		HTTPMethod: http.MethodPost,
+		Body:       `{"mutations":[{"op":"create","todo":{"id":"1","title":"Write report","assigneeIds":["user-3"]}}]}`,
+	}
+
+	resp, err := handlers.NewToDoHandlerWithMembers(m, staticMembers()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result handlers.SyncResult
+	if err := json.Unmarshal([]byte(resp.Body), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Results) != 1 || result.Results[0].Status != handlers.MutationRejected {
+		t.Fatalf("Expected the mutation to be rejected, got %+v", result.Results)
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+)
+
+// AssignmentHandler provides a handle method to handle incoming AWS API Gateway requests for the ToDos
+// assigned to the caller, across every list they are a member of
+type AssignmentHandler struct {
+	finder  database.AssignedToDoFinder
+	members database.MemberRepo
+}
+
+// NewAssignmentHandler creates a new Assignment handler
+func NewAssignmentHandler(finder database.AssignedToDoFinder, members database.MemberRepo) *AssignmentHandler {
+	return &AssignmentHandler{
+		finder:  finder,
+		members: members,
+	}
+}
+
+// Handle handles a request from AWS API Gateway and returns a response
+func (h *AssignmentHandler) Handle(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	userID := callerID(req)
+	if userID == "" {
+		return CreateErrorResponse(ErrUnauthorized)
+	}
+
+	if req.HTTPMethod != "GET" {
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+
+	completed, err := parseCompleted(req)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	assigned, err := h.finder.GetAssignedTo(userID)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	// ToDos stay assigned to users who have left their list, who can no longer see them
+	visible := make(map[string]bool)
+	todos := []internal.ToDo{}
+
+	for _, t := range assigned {
+		if completed != nil && t.Completed != *completed {
+			continue
+		}
+
+		listID := todoListID(&t)
+
+		if _, ok := visible[listID]; !ok {
+			members, err := h.members.GetByList(listID)
+			if err != nil {
+				return CreateErrorResponse(repoError(err))
+			}
+			visible[listID] = member(members, userID) >= 0
+		}
+
+		if visible[listID] {
+			todos = append(todos, t)
+		}
+	}
+
+	return respondToDos(req, todos)
+}
@@ -0,0 +1,120 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+func TestAssignmentHandler(t *testing.T) {
+	t.Run("GetAssignedOK", testGetAssignedOK)
+	t.Run("GetAssignedCompleted", testGetAssignedCompleted)
+	t.Run("GetAssignedUnauthorized", testGetAssignedUnauthorized)
+}
+
+// AssignedFinderMock is used to mock a repository that finds assigned ToDos
+type AssignedFinderMock struct {
+	GetAssignedToFn      func(string) ([]internal.ToDo, error)
+	GetAssignedToInvoked bool
+}
+
+// GetAssignedTo returns the ToDos of every list assigned to a user
+func (m *AssignedFinderMock) GetAssignedTo(userID string) ([]internal.ToDo, error) {
+	m.GetAssignedToInvoked = true
+	return m.GetAssignedToFn(userID)
+}
+
+var assignedToDos = []internal.ToDo{
+	{ID: "1", ListID: "work", Title: "Write report", AssigneeIDs: []string{"user-1"}},
+	{ID: "2", ListID: "work", Title: "Send report", Completed: true, AssigneeIDs: []string{"user-1"}},
+	{ID: "3", ListID: "home", Title: "Fix sink", AssigneeIDs: []string{"user-1"}},
+}
+
+// assignedFinder returns an AssignedFinderMock that finds assignedToDos
+func assignedFinder(t *testing.T) *AssignedFinderMock {
+	return &AssignedFinderMock{
+		GetAssignedToFn: func(userID string) ([]internal.ToDo, error) {
+			if userID != "user-1" {
+				t.Fatalf("Expected user-1, got %s", userID)
+			}
+			return assignedToDos, nil
+		},
+	}
+}
+
+// workMembers returns a MemberRepoMock in which user-1 is only a member of the work list
+func workMembers() *MemberRepoMock {
+	return &MemberRepoMock{
+		GetByListFn: func(listID string) ([]internal.Member, error) {
+			if listID != "work" {
+				return []internal.Member{{ListID: listID, UserID: "user-2", Handle: "bob"}}, nil
+			}
+			return listMembers, nil
+		},
+	}
+}
+
+func getAssigned(t *testing.T, req events.APIGatewayProxyRequest) []internal.ToDo {
+	resp, err := handlers.NewAssignmentHandler(assignedFinder(t), workMembers()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	var got []internal.ToDo
+	if err := json.Unmarshal([]byte(resp.Body), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	return got
+}
+
+func testGetAssignedOK(t *testing.T) {
+
+	got := getAssigned(t, authorized(events.APIGatewayProxyRequest{
+		Resource:   "/me/todos",
+		HTTPMethod: http.MethodGet,
+	}, "user-1"))
+
+	// user-1 has left the home list, so can no longer see its ToDos
+	if len(got) != 2 || got[0].ID != "1" || got[1].ID != "2" {
+		t.Fatalf("Unexpected ToDos %+v", got)
+	}
+}
+
+func testGetAssignedCompleted(t *testing.T) {
+
+	got := getAssigned(t, authorized(events.APIGatewayProxyRequest{
+		Resource:              "/me/todos",
+		HTTPMethod:            http.MethodGet,
+		QueryStringParameters: map[string]string{"completed": "false"},
+	}, "user-1"))
+
+	if len(got) != 1 || got[0].ID != "1" {
+		t.Fatalf("Unexpected ToDos %+v", got)
+	}
+}
+
+func testGetAssignedUnauthorized(t *testing.T) {
+
+	m := &AssignedFinderMock{}
+
+	resp, err := handlers.NewAssignmentHandler(m, workMembers()).Handle(events.APIGatewayProxyRequest{
+		Resource:   "/me/todos",
+		HTTPMethod: http.MethodGet,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized || m.GetAssignedToInvoked {
+		t.Fatalf("Expected %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
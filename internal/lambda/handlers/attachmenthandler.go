@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"mime"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/blob"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// attachmentsResource is the API Gateway resource for listing and adding the attachments of a ToDo
+	attachmentsResource = "/todos/{id}/attachments"
+	// attachmentResource is the API Gateway resource for downloading and removing an attachment
+	attachmentResource = "/todos/{id}/attachments/{attachmentId}"
+	// maxAttachments is the most attachments a ToDo can have
+	maxAttachments = 20
+	// maxAttachmentSize is the largest attachment that can be uploaded, in bytes
+	maxAttachmentSize = 100 << 20
+	// maxAttachmentNameLength is the longest name of an attachment, in bytes
+	maxAttachmentNameLength = 255
+	// presignExpiry is how long presigned upload and download URLs can be used for
+	presignExpiry = 15 * time.Minute
+)
+
+// AttachmentRequest is the body of a request to add an attachment. Checksum is the base64 encoded MD5
+// digest of the content.
+type AttachmentRequest struct {
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"contentType"`
+	Checksum    string `json:"checksum"`
+}
+
+// AttachmentUpload is the response to adding an attachment. The content must be PUT to URL with Headers
+// before it expires.
+type AttachmentUpload struct {
+	Attachment internal.Attachment `json:"attachment"`
+	URL        string              `json:"uploadUrl"`
+	Headers    map[string]string   `json:"headers"`
+	Expires    time.Time           `json:"expires"`
+}
+
+// AttachmentDownload is the response to getting an attachment. Its content can be downloaded from URL
+// until it expires.
+type AttachmentDownload struct {
+	Attachment internal.Attachment `json:"attachment"`
+	URL        string              `json:"downloadUrl"`
+	Expires    time.Time           `json:"expires"`
+}
+
+// AttachmentHandler provides a handle method to handle incoming AWS API Gateway requests for the
+// attachments of ToDos. Content never passes through it; clients are given presigned URLs of the store.
+type AttachmentHandler struct {
+	repo  database.ToDoRepo
+	store blob.Store
+}
+
+// NewAttachmentHandler creates a new Attachment handler
+func NewAttachmentHandler(repo database.ToDoRepo, store blob.Store) *AttachmentHandler {
+	return &AttachmentHandler{
+		repo:  repo,
+		store: store,
+	}
+}
+
+// Handle handles a request from AWS API Gateway and returns a response
+func (h *AttachmentHandler) Handle(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	id := req.PathParameters["id"]
+	if id == "" {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "ID is required"))
+	}
+
+	todo, err := h.repo.Get(id)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	} else if todo == nil {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	switch {
+	case req.Resource == attachmentsResource && req.HTTPMethod == "GET":
+		return CreateOKResponse(attachments(todo))
+	case req.Resource == attachmentsResource && req.HTTPMethod == "POST":
+		return h.post(req, todo)
+	case req.Resource == attachmentResource && req.HTTPMethod == "GET":
+		return h.get(req, todo)
+	case req.Resource == attachmentResource && req.HTTPMethod == "DELETE":
+		return h.delete(req, todo)
+	default:
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+}
+
+// post adds an attachment to a ToDo and returns the URL its content is uploaded to
+func (h *AttachmentHandler) post(req events.APIGatewayProxyRequest, todo *internal.ToDo) (events.APIGatewayProxyResponse, error) {
+
+	var r AttachmentRequest
+
+	if err := json.Unmarshal([]byte(req.Body), &r); err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "body is not valid JSON"))
+	}
+
+	a, err := newAttachment(r)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	if len(todo.Attachments) >= maxAttachments {
+		return CreateErrorResponse(errors.Wrapf(ErrBadRequest, "a ToDo can have at most %d attachments", maxAttachments))
+	}
+
+	upload := blob.Upload{Size: a.Size, ContentType: a.ContentType, Checksum: a.Checksum}
+	expires := time.Now().Add(presignExpiry)
+
+	u, err := h.store.PresignPut(blob.Key(todo.ListID, todo.ID, a.ID), upload, presignExpiry)
+	if err != nil {
+		return CreateErrorResponse(ErrInternal)
+	}
+
+	todo.Attachments = append(todo.Attachments, a)
+
+	if err := h.repo.Save(todo); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse(AttachmentUpload{
+		Attachment: a,
+		URL:        u,
+		Headers:    map[string]string{"Content-Type": a.ContentType, "Content-MD5": a.Checksum},
+		Expires:    expires,
+	})
+}
+
+// get returns an attachment and the URL its content is downloaded from
+func (h *AttachmentHandler) get(req events.APIGatewayProxyRequest, todo *internal.ToDo) (events.APIGatewayProxyResponse, error) {
+
+	i := todo.Attachment(req.PathParameters["attachmentId"])
+	if i < 0 {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	a := todo.Attachments[i]
+	expires := time.Now().Add(presignExpiry)
+
+	u, err := h.store.PresignGet(blob.Key(todo.ListID, todo.ID, a.ID), a.Name, presignExpiry)
+	if err != nil {
+		return CreateErrorResponse(ErrInternal)
+	}
+
+	return CreateOKResponse(AttachmentDownload{Attachment: a, URL: u, Expires: expires})
+}
+
+// delete removes an attachment and its content
+func (h *AttachmentHandler) delete(req events.APIGatewayProxyRequest, todo *internal.ToDo) (events.APIGatewayProxyResponse, error) {
+
+	i := todo.Attachment(req.PathParameters["attachmentId"])
+	if i < 0 {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	// The content is removed first, so an attachment is never left without being listed
+	if err := h.store.Delete(blob.Key(todo.ListID, todo.ID, todo.Attachments[i].ID)); err != nil {
+		return CreateErrorResponse(ErrInternal)
+	}
+
+	todo.Attachments = append(todo.Attachments[:i:i], todo.Attachments[i+1:]...)
+
+	if err := h.repo.Save(todo); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse("")
+}
+
+// newAttachment returns the attachment described by r if it is valid
+func newAttachment(r AttachmentRequest) (internal.Attachment, error) {
+
+	if r.Name == "" || len(r.Name) > maxAttachmentNameLength || !utf8.ValidString(r.Name) ||
+		strings.IndexFunc(r.Name, unicode.IsControl) >= 0 || strings.ContainsAny(r.Name, `/\`) {
+		return internal.Attachment{}, errors.Wrapf(ErrBadRequest,
+			"name must be 1 to %d bytes without slashes or control characters", maxAttachmentNameLength)
+	}
+
+	if r.Size <= 0 || r.Size > maxAttachmentSize {
+		return internal.Attachment{}, errors.Wrapf(ErrBadRequest, "size must be 1 to %d bytes", maxAttachmentSize)
+	}
+
+	contentType := "application/octet-stream"
+	if r.ContentType != "" {
+		t, params, err := mime.ParseMediaType(r.ContentType)
+		if err != nil {
+			return internal.Attachment{}, errors.Wrap(ErrBadRequest, "contentType is not a valid media type")
+		}
+		contentType = mime.FormatMediaType(t, params)
+	}
+
+	if sum, err := base64.StdEncoding.DecodeString(r.Checksum); err != nil || len(sum) != 16 {
+		return internal.Attachment{}, errors.Wrap(ErrBadRequest, "checksum must be the base64 encoded MD5 digest of the content")
+	}
+
+	return internal.Attachment{
+		ID:          uuid.NewV4().String(),
+		Name:        r.Name,
+		Size:        r.Size,
+		ContentType: contentType,
+		Checksum:    r.Checksum,
+		Created:     time.Now().UTC(),
+	}, nil
+}
+
+// attachments returns the attachments of a ToDo, or an empty list if it has none
+func attachments(todo *internal.ToDo) []internal.Attachment {
+	if todo.Attachments == nil {
+		return []internal.Attachment{}
+	}
+	return todo.Attachments
+}
@@ -0,0 +1,367 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/blob"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+	"github.com/pkg/errors"
+)
+
+// testChecksum is the base64 encoded MD5 digest of "Hello, World!"
+const testChecksum = "ZajifYh5KDgxtmS9i38K1A=="
+
+func TestAttachmentHandler(t *testing.T) {
+	t.Run("AddAttachment", testAddAttachment)
+	t.Run("AddAttachmentBadRequest", testAddAttachmentBadRequest)
+	t.Run("AddAttachmentLimit", testAddAttachmentLimit)
+	t.Run("ListAttachments", testListAttachments)
+	t.Run("GetAttachment", testGetAttachment)
+	t.Run("DeleteAttachment", testDeleteAttachment)
+	t.Run("DeleteAttachmentStoreError", testDeleteAttachmentStoreError)
+	t.Run("AttachmentNotFound", testAttachmentNotFound)
+	t.Run("AttachmentToDoNotFound", testAttachmentToDoNotFound)
+	t.Run("PutKeepsAttachments", testPutKeepsAttachments)
+	t.Run("PostIgnoresAttachments", testPostIgnoresAttachments)
+}
+
+// attachedToDo returns a ToDo in the work list with one attachment
+func attachedToDo() internal.ToDo {
+	return internal.ToDo{
+		ID:     testUUID,
+		ListID: "work",
+		Title:  "Expenses",
+		Attachments: []internal.Attachment{
+			{ID: "receipt", Name: "receipt.pdf", Size: 13, ContentType: "application/pdf", Checksum: testChecksum},
+		},
+	}
+}
+
+// storeMock returns a StoreMock whose URLs are made of the key, and that records the keys deleted
+func storeMock(deleted *[]string) *StoreMock {
+	return &StoreMock{
+		PresignPutFn: func(key string, upload blob.Upload, expires time.Duration) (string, error) {
+			return "https://blobs.test/" + key + "?put", nil
+		},
+		PresignGetFn: func(key, filename string, expires time.Duration) (string, error) {
+			return "https://blobs.test/" + key + "?filename=" + filename, nil
+		},
+		DeleteFn: func(key string) error {
+			*deleted = append(*deleted, key)
+			return nil
+		},
+	}
+}
+
+// attachmentRequest returns a request to the attachments of the test ToDo, or to one of them if
+// attachmentID is not empty
+func attachmentRequest(method, attachmentID, body string) events.APIGatewayProxyRequest {
+	req := events.APIGatewayProxyRequest{
+		Resource:       "/todos/{id}/attachments",
+		HTTPMethod:     method,
+		PathParameters: map[string]string{"id": testUUID},
+		Body:           body,
+	}
+
+	if attachmentID != "" {
+		req.Resource = "/todos/{id}/attachments/{attachmentId}"
+		req.PathParameters["attachmentId"] = attachmentID
+	}
+
+	return req
+}
+
+func testAddAttachment(t *testing.T) {
+
+	repo, todos := memoryRepo(attachedToDo())
+	store := storeMock(nil)
+	h := handlers.NewAttachmentHandler(repo, store)
+
+	var upload blob.Upload
+	store.PresignPutFn = func(key string, u blob.Upload, expires time.Duration) (string, error) {
+		upload = u
+		return "https://blobs.test/" + key, nil
+	}
+
+	body := `{"name":"notes.txt","size":13,"contentType":"Text/Plain; charset=UTF-8","checksum":"` + testChecksum + `"}`
+
+	resp, err := h.Handle(attachmentRequest("POST", "", body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	var out handlers.AttachmentUpload
+	if err := json.Unmarshal([]byte(resp.Body), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	a := out.Attachment
+	if a.ID == "" || a.Name != "notes.txt" || a.ContentType != "text/plain; charset=UTF-8" || a.Checksum != testChecksum {
+		t.Fatalf("Unexpected attachment %+v", a)
+	}
+
+	if out.URL != "https://blobs.test/todos/work/"+testUUID+"/"+a.ID {
+		t.Fatalf("Unexpected upload URL %s", out.URL)
+	}
+
+	if upload.Size != 13 || upload.ContentType != a.ContentType || upload.Checksum != testChecksum {
+		t.Fatalf("Unexpected upload %+v", upload)
+	}
+
+	if out.Headers["Content-MD5"] != testChecksum || out.Headers["Content-Type"] != a.ContentType {
+		t.Fatalf("Unexpected upload headers %v", out.Headers)
+	}
+
+	if saved := todos[testUUID]; len(saved.Attachments) != 2 || saved.Attachments[1].ID != a.ID {
+		t.Fatalf("Expected attachment to be saved, got %+v", saved.Attachments)
+	}
+}
+
+func testAddAttachmentBadRequest(t *testing.T) {
+
+	repo, todos := memoryRepo(attachedToDo())
+	h := handlers.NewAttachmentHandler(repo, storeMock(nil))
+
+	bodies := []string{
+		`{"size":13,"checksum":"` + testChecksum + `"}`,
+		`{"name":"a/b.txt","size":13,"checksum":"` + testChecksum + `"}`,
+		`{"name":"a\nb.txt","size":13,"checksum":"` + testChecksum + `"}`,
+		`{"name":"` + strings.Repeat("a", 256) + `","size":13,"checksum":"` + testChecksum + `"}`,
+		`{"name":"a.txt","size":0,"checksum":"` + testChecksum + `"}`,
+		`{"name":"a.txt","size":104857601,"checksum":"` + testChecksum + `"}`,
+		`{"name":"a.txt","size":13,"contentType":"text/","checksum":"` + testChecksum + `"}`,
+		`{"name":"a.txt","size":13,"checksum":"not base64"}`,
+		`{"name":"a.txt","size":13,"checksum":"YWJj"}`,
+		`not json`,
+	}
+
+	for _, body := range bodies {
+		resp, err := h.Handle(attachmentRequest("POST", "", body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expected %d for %s, got %d", http.StatusBadRequest, body, resp.StatusCode)
+		}
+	}
+
+	if len(todos[testUUID].Attachments) != 1 {
+		t.Fatal("Expected no attachments to be added")
+	}
+}
+
+func testAddAttachmentLimit(t *testing.T) {
+
+	todo := attachedToDo()
+	for len(todo.Attachments) < 20 {
+		todo.Attachments = append(todo.Attachments, todo.Attachments[0])
+	}
+
+	repo, _ := memoryRepo(todo)
+	store := storeMock(nil)
+	h := handlers.NewAttachmentHandler(repo, store)
+
+	body := `{"name":"notes.txt","size":13,"checksum":"` + testChecksum + `"}`
+
+	resp, err := h.Handle(attachmentRequest("POST", "", body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest || store.PresignPutInvoked {
+		t.Fatalf("Expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func testListAttachments(t *testing.T) {
+
+	todo := attachedToDo()
+	todo.ID = "empty"
+	todo.Attachments = nil
+
+	repo, _ := memoryRepo(attachedToDo(), todo)
+	h := handlers.NewAttachmentHandler(repo, storeMock(nil))
+
+	for id, want := range map[string]int{testUUID: 1, "empty": 0} {
+		req := attachmentRequest("GET", "", "")
+		req.PathParameters["id"] = id
+
+		resp, err := h.Handle(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out []internal.Attachment
+		if err := json.Unmarshal([]byte(resp.Body), &out); err != nil {
+			t.Fatal(err)
+		}
+
+		if out == nil || len(out) != want {
+			t.Fatalf("Expected %d attachments for %s, got %s", want, id, resp.Body)
+		}
+	}
+}
+
+func testGetAttachment(t *testing.T) {
+
+	repo, _ := memoryRepo(attachedToDo())
+	h := handlers.NewAttachmentHandler(repo, storeMock(nil))
+
+	resp, err := h.Handle(attachmentRequest("GET", "receipt", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out handlers.AttachmentDownload
+	if err := json.Unmarshal([]byte(resp.Body), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Attachment.ID != "receipt" || out.URL != "https://blobs.test/todos/work/"+testUUID+"/receipt?filename=receipt.pdf" {
+		t.Fatalf("Unexpected download %+v", out)
+	}
+
+	if out.Expires.Before(time.Now()) {
+		t.Fatalf("Expected download URL to expire in the future, got %v", out.Expires)
+	}
+}
+
+func testDeleteAttachment(t *testing.T) {
+
+	repo, todos := memoryRepo(attachedToDo())
+	deleted := []string{}
+	h := handlers.NewAttachmentHandler(repo, storeMock(&deleted))
+
+	resp, err := h.Handle(attachmentRequest("DELETE", "receipt", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if len(deleted) != 1 || deleted[0] != "todos/work/"+testUUID+"/receipt" {
+		t.Fatalf("Expected content to be deleted, got %v", deleted)
+	}
+
+	if len(todos[testUUID].Attachments) != 0 {
+		t.Fatalf("Expected attachment to be removed, got %+v", todos[testUUID].Attachments)
+	}
+}
+
+func testDeleteAttachmentStoreError(t *testing.T) {
+
+	repo, todos := memoryRepo(attachedToDo())
+	store := storeMock(nil)
+	store.DeleteFn = func(string) error {
+		return errors.New("S3 Error")
+	}
+
+	h := handlers.NewAttachmentHandler(repo, store)
+
+	resp, err := h.Handle(attachmentRequest("DELETE", "receipt", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusInternalServerError || repo.SaveInvoked {
+		t.Fatalf("Expected %d without saving, got %d", http.StatusInternalServerError, resp.StatusCode)
+	}
+
+	if len(todos[testUUID].Attachments) != 1 {
+		t.Fatal("Expected attachment to be kept")
+	}
+}
+
+func testAttachmentNotFound(t *testing.T) {
+
+	repo, _ := memoryRepo(attachedToDo())
+	h := handlers.NewAttachmentHandler(repo, storeMock(nil))
+
+	for _, method := range []string{"GET", "DELETE"} {
+		resp, err := h.Handle(attachmentRequest(method, "missing", ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("Expected %d for %s, got %d", http.StatusNotFound, method, resp.StatusCode)
+		}
+	}
+}
+
+func testAttachmentToDoNotFound(t *testing.T) {
+
+	repo, _ := memoryRepo()
+	h := handlers.NewAttachmentHandler(repo, storeMock(nil))
+
+	resp, err := h.Handle(attachmentRequest("GET", "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func testPutKeepsAttachments(t *testing.T) {
+
+	repo, todos := memoryRepo(attachedToDo())
+	h := handlers.NewToDoHandler(repo)
+
+	req := events.APIGatewayProxyRequest{
+		HTTPMethod:     "PUT",
+		PathParameters: map[string]string{"id": testUUID},
+		Body:           `{"id":"` + testUUID + `","listId":"work","title":"Expenses","attachments":[]}`,
+	}
+
+	resp, err := h.Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	if len(todos[testUUID].Attachments) != 1 {
+		t.Fatalf("Expected attachments to be kept, got %+v", todos[testUUID].Attachments)
+	}
+}
+
+func testPostIgnoresAttachments(t *testing.T) {
+
+	repo, todos := memoryRepo()
+	h := handlers.NewToDoHandler(repo)
+
+	req := events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"title":"Expenses","attachments":[{"id":"forged","name":"a.txt"}]}`,
+	}
+
+	resp, err := h.Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	if len(todos[""].Attachments) != 0 {
+		t.Fatalf("Expected attachments to be ignored, got %+v", todos[""].Attachments)
+	}
+}
@@ -0,0 +1,611 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/caldav"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/ical"
+	"github.com/pkg/errors"
+)
+
+const (
+	// caldavPrefix is the path of the CalDAV principal and calendar home. Each list is a calendar
+	// collection below it, and each ToDo a <id>.ics resource in its list.
+	caldavPrefix = "/caldav/"
+	// wellKnownCalDAVResource is the resource clients use to discover the CalDAV server (RFC 6764)
+	wellKnownCalDAVResource = "/.well-known/caldav"
+	// caldavAuthenticate is sent with 401 responses so clients prompt for credentials
+	caldavAuthenticate = `Basic realm="todo"`
+	// syncTokenPrefix makes sync tokens URIs, as required by RFC 6578
+	syncTokenPrefix = "urn:x-todo:sync:"
+	// vtodoContentType is the content type of ToDo resources
+	vtodoContentType = "text/calendar; charset=utf-8; component=vtodo"
+)
+
+// CalDAVHandler provides a handle method to handle CalDAV requests from task clients. API Gateway does not
+// pass WebDAV methods such as PROPFIND to Lambda, so it is served by the standalone server.
+type CalDAVHandler struct {
+	todos database.ToDoRepoProvider
+}
+
+// NewCalDAVHandler creates a new CalDAV handler
+func NewCalDAVHandler(todos database.ToDoRepoProvider) *CalDAVHandler {
+	return &CalDAVHandler{
+		todos: todos,
+	}
+}
+
+// caldavTarget is the resource a CalDAV request is for. The home has no list, and a calendar collection
+// has no ID.
+type caldavTarget struct {
+	listID string
+	id     string
+	repo   database.ToDoRepo
+}
+
+// Handle handles a CalDAV request and returns a response
+func (h *CalDAVHandler) Handle(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	if req.Resource == wellKnownCalDAVResource {
+		r, err := CreateResponse(RawBody{}, http.StatusMovedPermanently)
+		r.Headers["Location"] = caldavPrefix
+		return r, err
+	}
+
+	if req.HTTPMethod == "OPTIONS" {
+		r, err := CreateOKResponse(RawBody{})
+		r.Headers["DAV"] = "1, 3, calendar-access"
+		r.Headers["Allow"] = "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT"
+		return r, err
+	}
+
+	if callerID(req) == "" {
+		r, err := CreateErrorResponse(ErrUnauthorized)
+		r.Headers["WWW-Authenticate"] = caldavAuthenticate
+		return r, err
+	}
+
+	target, ok := h.target(req.PathParameters["proxy"])
+	if !ok {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	switch req.HTTPMethod {
+	case "PROPFIND":
+		return h.propfind(req, target)
+	case "REPORT":
+		return h.report(req, target)
+	case "GET", "HEAD":
+		return h.get(req, target)
+	case "PUT":
+		return h.put(req, target)
+	case "DELETE":
+		return h.delete(req, target)
+	default:
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+}
+
+// target resolves the path below caldavPrefix
+func (h *CalDAVHandler) target(path string) (caldavTarget, bool) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return caldavTarget{}, true
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) > 2 || !validListID(parts[0]) {
+		return caldavTarget{}, false
+	}
+
+	t := caldavTarget{listID: parts[0], repo: h.todos(parts[0])}
+	if t.repo == nil {
+		return caldavTarget{}, false
+	}
+
+	if len(parts) == 2 {
+		if !strings.HasSuffix(parts[1], ".ics") || parts[1] == ".ics" {
+			return caldavTarget{}, false
+		}
+		t.id = strings.TrimSuffix(parts[1], ".ics")
+	}
+
+	return t, true
+}
+
+func (h *CalDAVHandler) propfind(req events.APIGatewayProxyRequest, target caldavTarget) (events.APIGatewayProxyResponse, error) {
+
+	body, err := requestBody(req)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	pf, err := caldav.ParsePropFind(body)
+	if err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, err.Error()))
+	}
+
+	depth := header(req, "Depth") != "0"
+	ms := &caldav.Multistatus{}
+
+	switch {
+	case target.listID == "":
+		ms.Responses = append(ms.Responses, propResponse(caldavPrefix, homeProperties(), pf.AllProp, pf.Props))
+
+		if depth {
+			// Lists can not be enumerated, so only the default list is discovered
+			if repo := h.todos(internal.DefaultListID); repo != nil {
+				todos, err := repo.GetAll()
+				if err != nil {
+					return CreateErrorResponse(repoError(err))
+				}
+				ms.Responses = append(ms.Responses, propResponse(collectionHref(internal.DefaultListID),
+					collectionProperties(internal.DefaultListID, todos), pf.AllProp, pf.Props))
+			}
+		}
+	case target.id == "":
+		todos, err := target.repo.GetAll()
+		if err != nil {
+			return CreateErrorResponse(repoError(err))
+		}
+
+		ms.Responses = append(ms.Responses, propResponse(collectionHref(target.listID),
+			collectionProperties(target.listID, todos), pf.AllProp, pf.Props))
+
+		if depth {
+			for _, t := range todos {
+				ms.Responses = append(ms.Responses, toDoResponse(target.listID, t, pf.AllProp, pf.Props))
+			}
+		}
+	default:
+		t, err := target.repo.Get(target.id)
+		if err != nil {
+			return CreateErrorResponse(repoError(err))
+		}
+
+		if t == nil {
+			return CreateErrorResponse(ErrNotFound)
+		}
+
+		ms.Responses = append(ms.Responses, toDoResponse(target.listID, *t, pf.AllProp, pf.Props))
+	}
+
+	return multistatusResponse(ms)
+}
+
+func (h *CalDAVHandler) report(req events.APIGatewayProxyRequest, target caldavTarget) (events.APIGatewayProxyResponse, error) {
+
+	if target.listID == "" || target.id != "" {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "reports are only supported on calendar collections"))
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	r, err := caldav.ParseReport(body)
+	if err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, err.Error()))
+	}
+
+	switch r.Type {
+	case caldav.CalendarQuery:
+		return h.calendarQuery(target, r)
+	case caldav.CalendarMultiget:
+		return h.calendarMultiget(target, r)
+	case caldav.SyncCollection:
+		return h.syncCollection(target, r)
+	default:
+		return CreateErrorResponse(errors.Wrapf(ErrBadRequest, "unsupported report %s", r.Type.Local))
+	}
+}
+
+func (h *CalDAVHandler) calendarQuery(target caldavTarget, r *caldav.Report) (events.APIGatewayProxyResponse, error) {
+
+	todos, err := target.repo.GetAll()
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	ms := &caldav.Multistatus{}
+
+	for _, t := range todos {
+		if r.Filter == nil || r.Filter.Match(t) {
+			ms.Responses = append(ms.Responses, toDoResponse(target.listID, t, r.AllProp, r.Props))
+		}
+	}
+
+	return multistatusResponse(ms)
+}
+
+func (h *CalDAVHandler) calendarMultiget(target caldavTarget, r *caldav.Report) (events.APIGatewayProxyResponse, error) {
+
+	ms := &caldav.Multistatus{}
+
+	for _, href := range r.Hrefs {
+		id, ok := hrefToDoID(target.listID, href)
+		if !ok {
+			ms.Responses = append(ms.Responses, caldav.Response{Href: href, Status: http.StatusNotFound})
+			continue
+		}
+
+		t, err := target.repo.Get(id)
+		if err != nil {
+			return CreateErrorResponse(repoError(err))
+		}
+
+		if t == nil {
+			ms.Responses = append(ms.Responses, caldav.Response{Href: href, Status: http.StatusNotFound})
+			continue
+		}
+
+		ms.Responses = append(ms.Responses, toDoResponse(target.listID, *t, r.AllProp, r.Props))
+	}
+
+	return multistatusResponse(ms)
+}
+
+// syncCollection returns the ToDos modified since the sync token. Deleted ToDos are not recorded, so if
+// the set of ToDos has changed the token is rejected and the client falls back to a full sync.
+func (h *CalDAVHandler) syncCollection(target caldavTarget, r *caldav.Report) (events.APIGatewayProxyResponse, error) {
+
+	todos, err := target.repo.GetAll()
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	since := time.Time{}
+
+	if r.SyncToken != "" {
+		var members string
+		var ok bool
+
+		since, members, ok = parseSyncToken(r.SyncToken)
+		if !ok || members != membersHash(todos) {
+			return CreateResponse(RawBody{
+				ContentType: caldav.ContentType,
+				Body:        caldav.Error(xml.Name{Space: caldav.NamespaceDAV, Local: "valid-sync-token"}),
+			}, http.StatusForbidden)
+		}
+	}
+
+	ms := &caldav.Multistatus{SyncToken: syncToken(todos)}
+
+	for _, t := range todos {
+		if r.SyncToken == "" || t.ModTime.After(since) {
+			ms.Responses = append(ms.Responses, toDoResponse(target.listID, t, r.AllProp, r.Props))
+		}
+	}
+
+	return multistatusResponse(ms)
+}
+
+func (h *CalDAVHandler) get(req events.APIGatewayProxyRequest, target caldavTarget) (events.APIGatewayProxyResponse, error) {
+
+	if target.id == "" {
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+
+	t, err := target.repo.Get(target.id)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	if t == nil {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	var b bytes.Buffer
+
+	if req.HTTPMethod != "HEAD" {
+		if err := ical.WriteCalendar(&b, "", []internal.ToDo{*t}, time.Now()); err != nil {
+			return CreateErrorResponse(ErrInternal)
+		}
+	}
+
+	r, err := CreateOKResponse(RawBody{ContentType: vtodoContentType, Body: b.Bytes()})
+	r.Headers["ETag"] = etag(*t)
+
+	return r, err
+}
+
+// put creates or updates a ToDo from a VTODO. The client chooses the resource name of new ToDos, which
+// becomes their ID. Completing a recurring ToDo creates its next occurrence, as the API does.
+func (h *CalDAVHandler) put(req events.APIGatewayProxyRequest, target caldavTarget) (events.APIGatewayProxyResponse, error) {
+
+	if target.id == "" {
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	in, err := ical.ReadToDo(bytes.NewReader(body))
+	if err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, err.Error()))
+	}
+
+	if in.ID != "" && in.ID != target.id {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "UID does not match resource name"))
+	}
+
+	existing, err := target.repo.Get(target.id)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	if err := checkPreconditions(req, existing); err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	// Keep fields that VTODOs do not carry
+	todo := internal.ToDo{ID: target.id}
+	if existing != nil {
+		todo = *existing
+	}
+
+	todo.Title = in.Title
+	todo.Notes = in.Notes
+	todo.Completed = in.Completed
+	todo.Due = in.Due
+
+	if err := prepareToDo(target.repo, existing, &todo, false); err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	todo.Stamp(existing, writeTime(existing))
+
+	if err := target.repo.Save(&todo); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	recur(target.repo, existing, &todo)
+
+	code := http.StatusNoContent
+	if existing == nil {
+		code = http.StatusCreated
+	}
+
+	r, err := CreateResponse(RawBody{}, code)
+	r.Headers["ETag"] = etag(todo)
+
+	return r, err
+}
+
+func (h *CalDAVHandler) delete(req events.APIGatewayProxyRequest, target caldavTarget) (events.APIGatewayProxyResponse, error) {
+
+	if target.id == "" {
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+
+	t, err := target.repo.Get(target.id)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	if t == nil {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	if err := checkPreconditions(req, t); err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	if err := target.repo.Delete(target.id); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateResponse(RawBody{}, http.StatusNoContent)
+}
+
+// checkPreconditions evaluates the If-Match and If-None-Match headers against the current ToDo, which is
+// nil if it does not exist
+func checkPreconditions(req events.APIGatewayProxyRequest, current *internal.ToDo) error {
+	if m := header(req, "If-Match"); m != "" {
+		if current == nil || (m != "*" && !matchETag(m, etag(*current))) {
+			return ErrPreconditionFailed
+		}
+	}
+
+	if m := header(req, "If-None-Match"); m != "" && current != nil {
+		if m == "*" || matchETag(m, etag(*current)) {
+			return ErrPreconditionFailed
+		}
+	}
+
+	return nil
+}
+
+// matchETag reports whether the comma separated list of ETags in header contains tag
+func matchETag(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(t), "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// etag returns the ETag of a ToDo, which changes every time it is saved
+func etag(t internal.ToDo) string {
+	return `"` + strconv.FormatInt(t.ModTime.UnixNano(), 36) + `"`
+}
+
+// syncToken returns a token identifying the current state of a list. It records the latest ModTime, to
+// find ToDos modified since, and a hash of the IDs, to detect ToDos that were deleted.
+func syncToken(todos []internal.ToDo) string {
+	var latest time.Time
+	for _, t := range todos {
+		if t.ModTime.After(latest) {
+			latest = t.ModTime
+		}
+	}
+
+	return fmt.Sprintf("%s%d-%s", syncTokenPrefix, latest.UnixNano(), membersHash(todos))
+}
+
+// parseSyncToken returns the latest ModTime and the hash of IDs recorded in a sync token
+func parseSyncToken(token string) (time.Time, string, bool) {
+	if !strings.HasPrefix(token, syncTokenPrefix) {
+		return time.Time{}, "", false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(token, syncTokenPrefix), "-", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", false
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+
+	return time.Unix(0, nanos), parts[1], true
+}
+
+// membersHash returns a short hash of the IDs of todos
+func membersHash(todos []internal.ToDo) string {
+	ids := make([]string, len(todos))
+	for i, t := range todos {
+		ids[i] = t.ID
+	}
+	sort.Strings(ids)
+
+	h := sha256.Sum256([]byte(strings.Join(ids, "\n")))
+	return hex.EncodeToString(h[:8])
+}
+
+// collectionHref returns the href of the calendar collection of a list
+func collectionHref(listID string) string {
+	return caldavPrefix + url.PathEscape(listID) + "/"
+}
+
+// toDoHref returns the href of a ToDo resource
+func toDoHref(listID, id string) string {
+	return collectionHref(listID) + url.PathEscape(id) + ".ics"
+}
+
+// hrefToDoID returns the ID of the ToDo an href in the list refers to. Clients send either paths or
+// absolute URLs.
+func hrefToDoID(listID, href string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+
+	i := strings.Index(u.Path, collectionHref(listID))
+	if i < 0 {
+		return "", false
+	}
+
+	name := u.Path[i+len(collectionHref(listID)):]
+	if !strings.HasSuffix(name, ".ics") || strings.Contains(name, "/") {
+		return "", false
+	}
+
+	return strings.TrimSuffix(name, ".ics"), true
+}
+
+// homeProperties returns the properties of the principal, which is also the calendar home
+func homeProperties() []caldav.Property {
+	return []caldav.Property{
+		caldav.Elements(caldav.ResourceType, xml.Name{Space: caldav.NamespaceDAV, Local: "collection"}),
+		caldav.Text(caldav.DisplayName, "ToDo"),
+		caldav.Href(caldav.CurrentUserPrincipal, caldavPrefix),
+		caldav.Href(caldav.PrincipalURL, caldavPrefix),
+		caldav.Href(caldav.CalendarHomeSet, caldavPrefix),
+	}
+}
+
+// collectionProperties returns the properties of the calendar collection of a list
+func collectionProperties(listID string, todos []internal.ToDo) []caldav.Property {
+	token := syncToken(todos)
+
+	return []caldav.Property{
+		caldav.Elements(caldav.ResourceType,
+			xml.Name{Space: caldav.NamespaceDAV, Local: "collection"},
+			xml.Name{Space: caldav.NamespaceCalDAV, Local: "calendar"}),
+		caldav.Text(caldav.DisplayName, listID),
+		caldav.Href(caldav.CurrentUserPrincipal, caldavPrefix),
+		caldav.Raw(caldav.SupportedCalendarComponentSet, `<c:comp name="VTODO"/>`),
+		caldav.Raw(caldav.SupportedReportSet,
+			`<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>`+
+				`<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>`+
+				`<d:supported-report><d:report><d:sync-collection/></d:report></d:supported-report>`),
+		caldav.Text(caldav.GetCTag, token),
+		caldav.Text(caldav.SyncToken, token),
+	}
+}
+
+// toDoResponse returns the response element for a ToDo resource. Its calendar data is only included
+// when requested by name.
+func toDoResponse(listID string, t internal.ToDo, allProp bool, requested []xml.Name) caldav.Response {
+	props := []caldav.Property{
+		caldav.Elements(caldav.ResourceType),
+		caldav.Text(caldav.GetETag, etag(t)),
+		caldav.Text(caldav.GetContentType, vtodoContentType),
+	}
+
+	for _, n := range requested {
+		if n == caldav.CalendarData {
+			var b bytes.Buffer
+			if err := ical.WriteCalendar(&b, "", []internal.ToDo{t}, time.Now()); err == nil {
+				props = append(props, caldav.Text(caldav.CalendarData, b.String()))
+			}
+		}
+	}
+
+	return propResponse(toDoHref(listID, t.ID), props, allProp, requested)
+}
+
+// propResponse returns the response element for a resource, with the requested properties it has and
+// the ones it does not
+func propResponse(href string, props []caldav.Property, allProp bool, requested []xml.Name) caldav.Response {
+	r := caldav.Response{Href: href}
+
+	if allProp {
+		for _, p := range props {
+			if p.Name != caldav.CalendarData {
+				r.Found = append(r.Found, p)
+			}
+		}
+		requested = nil
+	}
+
+	for _, n := range requested {
+		found := false
+		for _, p := range props {
+			if p.Name == n {
+				r.Found = append(r.Found, p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			r.NotFound = append(r.NotFound, n)
+		}
+	}
+
+	return r
+}
+
+// multistatusResponse generates a 207 Multi-Status response
+func multistatusResponse(ms *caldav.Multistatus) (events.APIGatewayProxyResponse, error) {
+	return CreateResponse(RawBody{ContentType: caldav.ContentType, Body: ms.Marshal()}, http.StatusMultiStatus)
+}
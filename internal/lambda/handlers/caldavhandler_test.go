@@ -0,0 +1,414 @@
+package handlers_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+const putUUID = "4d0a3c5e-9f5b-4c1e-8d2c-6f1b9f3c2a77"
+
+func TestCalDAVHandler(t *testing.T) {
+	t.Run("Unauthorized", testCalDAVUnauthorized)
+	t.Run("WellKnown", testCalDAVWellKnown)
+	t.Run("PropfindHome", testCalDAVPropfindHome)
+	t.Run("PropfindCollection", testCalDAVPropfindCollection)
+	t.Run("PropfindETags", testCalDAVPropfindETags)
+	t.Run("UnknownList", testCalDAVUnknownList)
+	t.Run("Multiget", testCalDAVMultiget)
+	t.Run("CalendarQuery", testCalDAVCalendarQuery)
+	t.Run("SyncCollection", testCalDAVSyncCollection)
+	t.Run("SyncCollectionAfterDelete", testCalDAVSyncCollectionAfterDelete)
+	t.Run("GetToDo", testCalDAVGetToDo)
+	t.Run("PutNewToDo", testCalDAVPutNewToDo)
+	t.Run("PutUIDMismatch", testCalDAVPutUIDMismatch)
+	t.Run("PutPreconditionFailed", testCalDAVPutPreconditionFailed)
+	t.Run("PutInvalid", testCalDAVPutInvalid)
+	t.Run("PutCompletesRecurring", testCalDAVPutCompletesRecurring)
+	t.Run("DeleteToDo", testCalDAVDeleteToDo)
+}
+
+// memoryRepo returns a RepoMock that stores ToDos in a map
+func memoryRepo(todos ...internal.ToDo) (*RepoMock, map[string]internal.ToDo) {
+	m := make(map[string]internal.ToDo)
+	for _, t := range todos {
+		m[t.ID] = t
+	}
+
+	return &RepoMock{
+		GetFn: func(id string) (*internal.ToDo, error) {
+			if t, ok := m[id]; ok {
+				return &t, nil
+			}
+			return nil, nil
+		},
+		GetAllFn: func() ([]internal.ToDo, error) {
+			all := []internal.ToDo{}
+			for _, t := range m {
+				all = append(all, t)
+			}
+			return all, nil
+		},
+		SaveFn: func(t *internal.ToDo) error {
+			t.ModTime = time.Now()
+			m[t.ID] = *t
+			return nil
+		},
+		DeleteFn: func(id string) error {
+			delete(m, id)
+			return nil
+		},
+	}, m
+}
+
+func newCalDAVHandler(repo database.ToDoRepo) *handlers.CalDAVHandler {
+	return handlers.NewCalDAVHandler(func(listID string) database.ToDoRepo {
+		if listID != internal.DefaultListID {
+			return nil
+		}
+		return repo
+	})
+}
+
+func fixture(t *testing.T, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "caldav", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func calDAVRequest(method, path, body string, headers map[string]string) events.APIGatewayProxyRequest {
+	req := events.APIGatewayProxyRequest{
+		Resource:       "/caldav/{proxy+}",
+		Path:           "/caldav/" + path,
+		HTTPMethod:     method,
+		Headers:        headers,
+		PathParameters: map[string]string{"proxy": path},
+		Body:           body,
+	}
+	return authorized(req, "user-1")
+}
+
+func serve(t *testing.T, h *handlers.CalDAVHandler, req events.APIGatewayProxyRequest, code int) events.APIGatewayProxyResponse {
+	resp, err := h.Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != code {
+		t.Fatalf("Expected %d http response code, got %d: %s", code, resp.StatusCode, resp.Body)
+	}
+
+	return resp
+}
+
+func assertContains(t *testing.T, body string, parts ...string) {
+	for _, p := range parts {
+		if !strings.Contains(body, p) {
+			t.Fatalf("Expected body to contain %s, got:\n%s", p, body)
+		}
+	}
+}
+
+func testCalDAVUnauthorized(t *testing.T) {
+
+	repo, _ := memoryRepo()
+
+	req := calDAVRequest("PROPFIND", "", "", nil)
+	req.RequestContext.Authorizer = nil
+
+	resp := serve(t, newCalDAVHandler(repo), req, http.StatusUnauthorized)
+
+	if resp.Headers["WWW-Authenticate"] == "" {
+		t.Fatal("Expected WWW-Authenticate header")
+	}
+}
+
+func testCalDAVWellKnown(t *testing.T) {
+
+	repo, _ := memoryRepo()
+
+	req := events.APIGatewayProxyRequest{Resource: "/.well-known/caldav", HTTPMethod: "PROPFIND"}
+
+	resp := serve(t, newCalDAVHandler(repo), req, http.StatusMovedPermanently)
+
+	if resp.Headers["Location"] != "/caldav/" {
+		t.Fatalf("Unexpected Location '%s'", resp.Headers["Location"])
+	}
+}
+
+func testCalDAVPropfindHome(t *testing.T) {
+
+	repo, _ := memoryRepo(savedToDo)
+
+	req := calDAVRequest("PROPFIND", "", fixture(t, "davx5-propfind-home.xml"), map[string]string{"depth": "0"})
+
+	resp := serve(t, newCalDAVHandler(repo), req, http.StatusMultiStatus)
+
+	assertContains(t, resp.Body,
+		"<d:current-user-principal><d:href>/caldav/</d:href></d:current-user-principal>",
+		"<c:calendar-home-set><d:href>/caldav/</d:href></c:calendar-home-set>",
+		`<addressbook-home-set xmlns="urn:ietf:params:xml:ns:carddav"/>`,
+		"HTTP/1.1 404 Not Found")
+
+	if repo.GetAllInvoked {
+		t.Fatal("GetAll invoked for Depth 0")
+	}
+}
+
+func testCalDAVPropfindCollection(t *testing.T) {
+
+	repo, _ := memoryRepo(savedToDo)
+
+	req := calDAVRequest("PROPFIND", "default/", fixture(t, "thunderbird-propfind-collection.xml"), map[string]string{"Depth": "0"})
+
+	resp := serve(t, newCalDAVHandler(repo), req, http.StatusMultiStatus)
+
+	assertContains(t, resp.Body,
+		"<d:href>/caldav/default/</d:href>",
+		"<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>",
+		`<c:supported-calendar-component-set><c:comp name="VTODO"/></c:supported-calendar-component-set>`,
+		"<cs:getctag>urn:x-todo:sync:",
+		"<d:owner/>")
+}
+
+func testCalDAVPropfindETags(t *testing.T) {
+
+	todo := savedToDo
+	todo.ModTime = time.Date(2019, 7, 20, 0, 0, 0, 0, time.UTC)
+	repo, _ := memoryRepo(todo)
+
+	req := calDAVRequest("PROPFIND", "default/", fixture(t, "thunderbird-propfind-etags.xml"), map[string]string{"Depth": "1"})
+
+	resp := serve(t, newCalDAVHandler(repo), req, http.StatusMultiStatus)
+
+	assertContains(t, resp.Body,
+		"<d:href>/caldav/default/"+testUUID+".ics</d:href>",
+		"<d:getetag>&#34;",
+		"<d:getcontenttype>text/calendar; charset=utf-8; component=vtodo</d:getcontenttype>")
+}
+
+func testCalDAVUnknownList(t *testing.T) {
+
+	repo, _ := memoryRepo()
+
+	req := calDAVRequest("PROPFIND", "work/", "", map[string]string{"Depth": "1"})
+
+	serve(t, newCalDAVHandler(repo), req, http.StatusNotFound)
+}
+
+func testCalDAVMultiget(t *testing.T) {
+
+	repo, _ := memoryRepo(savedToDo)
+
+	req := calDAVRequest("REPORT", "default/", fixture(t, "thunderbird-multiget.xml"), map[string]string{"Depth": "1"})
+
+	resp := serve(t, newCalDAVHandler(repo), req, http.StatusMultiStatus)
+
+	assertContains(t, resp.Body,
+		"<c:calendar-data>BEGIN:VCALENDAR&#xD;&#xA;",
+		"SUMMARY:Some ToDo&#xD;&#xA;",
+		"<d:response><d:href>/caldav/default/missing.ics</d:href><d:status>HTTP/1.1 404 Not Found</d:status></d:response>")
+}
+
+func testCalDAVCalendarQuery(t *testing.T) {
+
+	completed := internal.ToDo{ID: "done", Title: "Done", Completed: true}
+	repo, _ := memoryRepo(savedToDo, completed)
+
+	req := calDAVRequest("REPORT", "default/", fixture(t, "tasks-calendar-query.xml"), map[string]string{"Depth": "1"})
+
+	resp := serve(t, newCalDAVHandler(repo), req, http.StatusMultiStatus)
+
+	assertContains(t, resp.Body, "/caldav/default/"+testUUID+".ics")
+
+	if strings.Contains(resp.Body, "/caldav/default/done.ics") {
+		t.Fatal("Expected completed ToDo to be filtered out")
+	}
+}
+
+// syncTokenPattern extracts the sync token from a multistatus
+var syncTokenPattern = regexp.MustCompile(`<d:sync-token>([^<]+)</d:sync-token>`)
+
+func testCalDAVSyncCollection(t *testing.T) {
+
+	old := internal.ToDo{ID: "old", Title: "Old", ModTime: time.Now().Add(-time.Hour)}
+	repo, _ := memoryRepo(old, savedToDo)
+	h := newCalDAVHandler(repo)
+
+	req := calDAVRequest("REPORT", "default/", fixture(t, "davx5-sync-collection.xml"), nil)
+
+	resp := serve(t, h, req, http.StatusMultiStatus)
+
+	assertContains(t, resp.Body, "/caldav/default/old.ics", "/caldav/default/"+testUUID+".ics")
+
+	token := syncTokenPattern.FindStringSubmatch(resp.Body)
+	if token == nil {
+		t.Fatalf("Expected sync-token in body:\n%s", resp.Body)
+	}
+
+	// Update one ToDo, only it is returned by the next sync
+	serve(t, h, calDAVRequest("PUT", "default/"+testUUID+".ics", strings.Replace(
+		fixture(t, "thunderbird-put.ics"), putUUID, testUUID, 1), nil), http.StatusNoContent)
+
+	body := strings.Replace(fixture(t, "davx5-sync-collection.xml"), "<sync-token />", "<sync-token>"+token[1]+"</sync-token>", 1)
+
+	resp = serve(t, h, calDAVRequest("REPORT", "default/", body, nil), http.StatusMultiStatus)
+
+	assertContains(t, resp.Body, "/caldav/default/"+testUUID+".ics")
+
+	if strings.Contains(resp.Body, "/caldav/default/old.ics") {
+		t.Fatal("Expected unchanged ToDo not to be returned")
+	}
+}
+
+func testCalDAVSyncCollectionAfterDelete(t *testing.T) {
+
+	repo, _ := memoryRepo(savedToDo)
+	h := newCalDAVHandler(repo)
+
+	resp := serve(t, h, calDAVRequest("REPORT", "default/", fixture(t, "davx5-sync-collection.xml"), nil), http.StatusMultiStatus)
+
+	token := syncTokenPattern.FindStringSubmatch(resp.Body)
+	if token == nil {
+		t.Fatalf("Expected sync-token in body:\n%s", resp.Body)
+	}
+
+	serve(t, h, calDAVRequest("DELETE", "default/"+testUUID+".ics", "", nil), http.StatusNoContent)
+
+	body := strings.Replace(fixture(t, "davx5-sync-collection.xml"), "<sync-token />", "<sync-token>"+token[1]+"</sync-token>", 1)
+
+	resp = serve(t, h, calDAVRequest("REPORT", "default/", body, nil), http.StatusForbidden)
+
+	assertContains(t, resp.Body, "<d:valid-sync-token/>")
+}
+
+func testCalDAVGetToDo(t *testing.T) {
+
+	repo, _ := memoryRepo(savedToDo)
+
+	resp := serve(t, newCalDAVHandler(repo), calDAVRequest("GET", "default/"+testUUID+".ics", "", nil), http.StatusOK)
+
+	assertContains(t, resp.Body, "UID:"+testUUID+"\r\n", "SUMMARY:Some ToDo\r\n")
+
+	if resp.Headers["ETag"] == "" {
+		t.Fatal("Expected ETag header")
+	}
+}
+
+func testCalDAVPutNewToDo(t *testing.T) {
+
+	repo, todos := memoryRepo()
+
+	req := calDAVRequest("PUT", "default/"+putUUID+".ics", fixture(t, "thunderbird-put.ics"), map[string]string{"If-None-Match": "*"})
+
+	resp := serve(t, newCalDAVHandler(repo), req, http.StatusCreated)
+
+	todo, ok := todos[putUUID]
+	if !ok {
+		t.Fatal("Expected ToDo to be saved")
+	}
+
+	if todo.Title != "Hand off on-call" || todo.Due == nil || todo.Completed {
+		t.Fatalf("Unexpected ToDo %+v", todo)
+	}
+
+	if resp.Headers["ETag"] == "" {
+		t.Fatal("Expected ETag header")
+	}
+}
+
+func testCalDAVPutUIDMismatch(t *testing.T) {
+
+	repo, _ := memoryRepo()
+
+	req := calDAVRequest("PUT", "default/other.ics", fixture(t, "thunderbird-put.ics"), nil)
+
+	serve(t, newCalDAVHandler(repo), req, http.StatusBadRequest)
+
+	if repo.SaveInvoked {
+		t.Fatal("Save invoked")
+	}
+}
+
+func testCalDAVPutPreconditionFailed(t *testing.T) {
+
+	repo, _ := memoryRepo(internal.ToDo{ID: putUUID, Title: "Hand off on-call", ModTime: time.Now()})
+
+	for _, headers := range []map[string]string{
+		{"If-None-Match": "*"},
+		{"If-Match": `"stale"`},
+	} {
+		req := calDAVRequest("PUT", "default/"+putUUID+".ics", fixture(t, "thunderbird-put.ics"), headers)
+		serve(t, newCalDAVHandler(repo), req, http.StatusPreconditionFailed)
+	}
+
+	if repo.SaveInvoked {
+		t.Fatal("Save invoked")
+	}
+}
+
+func testCalDAVPutInvalid(t *testing.T) {
+
+	repo, _ := memoryRepo()
+
+	// Lines of the description are folded to 75 octets, as iCalendar requires
+	notes := "DESCRIPTION:" + strings.Repeat(strings.Repeat("a", 74)+"\r\n ", 1000) + "a\r\n"
+	body := strings.Replace(fixture(t, "thunderbird-put.ics"), "STATUS:", notes+"STATUS:", 1)
+
+	req := calDAVRequest("PUT", "default/"+putUUID+".ics", body, nil)
+
+	resp := serve(t, newCalDAVHandler(repo), req, http.StatusBadRequest)
+	assertContains(t, resp.Body, "notes must be at most")
+
+	if repo.SaveInvoked {
+		t.Fatal("Save invoked")
+	}
+}
+
+func testCalDAVPutCompletesRecurring(t *testing.T) {
+
+	due := time.Date(2019, 7, 22, 0, 0, 0, 0, time.UTC)
+	repo, todos := memoryRepo(internal.ToDo{ID: putUUID, Title: "Hand off on-call", Due: &due, RRule: "FREQ=WEEKLY"})
+
+	body := strings.Replace(fixture(t, "thunderbird-put.ics"), "STATUS:NEEDS-ACTION", "STATUS:COMPLETED", 1)
+	req := calDAVRequest("PUT", "default/"+putUUID+".ics", body, nil)
+
+	serve(t, newCalDAVHandler(repo), req, http.StatusNoContent)
+
+	if !todos[putUUID].Completed {
+		t.Fatal("Expected the ToDo to be completed")
+	}
+
+	for id, todo := range todos {
+		if id != putUUID && todo.SeriesID == putUUID && !todo.Completed {
+			return
+		}
+	}
+
+	t.Fatalf("Expected the next occurrence to be created, got %v", todos)
+}
+
+func testCalDAVDeleteToDo(t *testing.T) {
+
+	repo, todos := memoryRepo(savedToDo)
+	h := newCalDAVHandler(repo)
+
+	resp := serve(t, h, calDAVRequest("GET", "default/"+testUUID+".ics", "", nil), http.StatusOK)
+
+	serve(t, h, calDAVRequest("DELETE", "default/"+testUUID+".ics", "", map[string]string{"If-Match": resp.Headers["ETag"]}), http.StatusNoContent)
+
+	if _, ok := todos[testUUID]; ok {
+		t.Fatal("Expected ToDo to be deleted")
+	}
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// checklistResource is the API Gateway resource for adding items to and reordering the checklist of a
+	// ToDo
+	checklistResource = "/todos/{id}/checklist"
+	// checklistItemResource is the API Gateway resource for updating and removing a checklist item
+	checklistItemResource = "/todos/{id}/checklist/{itemId}"
+	// maxChecklistItems is the most items a checklist can have
+	maxChecklistItems = 500
+	// maxChecklistTitleLength is the longest title of a checklist item, in bytes
+	maxChecklistTitleLength = 1000
+)
+
+// ChecklistItemRequest is the body of a request to add or update a checklist item. Fields that are not
+// set are left unchanged by updates.
+type ChecklistItemRequest struct {
+	Title   *string `json:"title"`
+	Checked *bool   `json:"checked"`
+}
+
+// ChecklistOrderRequest is the body of a request to reorder a checklist. Order holds the ID of every item,
+// in their new order.
+type ChecklistOrderRequest struct {
+	Order []string `json:"order"`
+}
+
+// checklist handles requests to change the checklist of a ToDo, responding with the ToDo
+func (h *ToDoHandler) checklist(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	id := req.PathParameters["id"]
+	if id == "" {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "ID is required"))
+	}
+
+	var change func(todo *internal.ToDo) error
+
+	switch {
+	case req.Resource == checklistResource && req.HTTPMethod == "POST":
+		change = func(todo *internal.ToDo) error { return addChecklistItem(todo, req.Body) }
+	case req.Resource == checklistResource && req.HTTPMethod == "PUT":
+		change = func(todo *internal.ToDo) error { return reorderChecklist(todo, req.Body) }
+	case req.Resource == checklistItemResource && req.HTTPMethod == "PUT":
+		change = func(todo *internal.ToDo) error {
+			return updateChecklistItem(todo, req.PathParameters["itemId"], req.Body)
+		}
+	case req.Resource == checklistItemResource && req.HTTPMethod == "DELETE":
+		change = func(todo *internal.ToDo) error { return removeChecklistItem(todo, req.PathParameters["itemId"]) }
+	default:
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+
+	t, err := h.repo.Get(id)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	} else if t == nil {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	todo := *t
+	todo.Checklist = append([]internal.ChecklistItem{}, t.Checklist...)
+
+	if err := change(&todo); err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	force, err := parseForce(req)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	if err := h.prepare(t, &todo, force); err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	todo.Stamp(t, writeTime(t))
+
+	if err := h.repo.Save(&todo); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	// Checking the last item may have completed it
+	recur(h.repo, t, &todo)
+
+	return CreateOKResponse(todo)
+}
+
+// addChecklistItem adds the item in body to the end of the checklist
+func addChecklistItem(todo *internal.ToDo, body string) error {
+	var r ChecklistItemRequest
+
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		return errors.Wrap(ErrBadRequest, "body is not valid JSON")
+	}
+
+	if r.Title == nil {
+		return errors.Wrap(ErrBadRequest, "title is required")
+	}
+
+	item := internal.ChecklistItem{ID: uuid.NewV4().String(), Title: *r.Title}
+	if r.Checked != nil {
+		item.Checked = *r.Checked
+	}
+
+	todo.Checklist = append(todo.Checklist, item)
+
+	return nil
+}
+
+// updateChecklistItem sets the fields of a checklist item that are in body
+func updateChecklistItem(todo *internal.ToDo, itemID, body string) error {
+	i := todo.ChecklistItem(itemID)
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	var r ChecklistItemRequest
+
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		return errors.Wrap(ErrBadRequest, "body is not valid JSON")
+	}
+
+	if r.Title != nil {
+		todo.Checklist[i].Title = *r.Title
+	}
+
+	if r.Checked != nil {
+		todo.Checklist[i].Checked = *r.Checked
+	}
+
+	return nil
+}
+
+// removeChecklistItem removes an item from the checklist
+func removeChecklistItem(todo *internal.ToDo, itemID string) error {
+	i := todo.ChecklistItem(itemID)
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	todo.Checklist = append(todo.Checklist[:i], todo.Checklist[i+1:]...)
+
+	return nil
+}
+
+// reorderChecklist puts the items of the checklist in the order given in body
+func reorderChecklist(todo *internal.ToDo, body string) error {
+	var r ChecklistOrderRequest
+
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		return errors.Wrap(ErrBadRequest, "body is not valid JSON")
+	}
+
+	if len(r.Order) != len(todo.Checklist) {
+		return errors.Wrap(ErrBadRequest, "order must contain every item once")
+	}
+
+	ordered := make([]internal.ChecklistItem, 0, len(r.Order))
+	seen := make(map[string]bool)
+
+	for _, id := range r.Order {
+		i := todo.ChecklistItem(id)
+		if i < 0 || seen[id] {
+			return errors.Wrap(ErrBadRequest, "order must contain every item once")
+		}
+
+		seen[id] = true
+		ordered = append(ordered, todo.Checklist[i])
+	}
+
+	todo.Checklist = ordered
+
+	return nil
+}
+
+// prepareChecklist checks the checklist of a ToDo that is about to be saved, gives items without an ID
+// one, and completes the ToDo if it is set to AutoComplete and every item is checked
+func prepareChecklist(todo *internal.ToDo) error {
+	if len(todo.Checklist) > maxChecklistItems {
+		return errors.Wrapf(ErrBadRequest, "checklist can not have more than %d items", maxChecklistItems)
+	}
+
+	ids := make(map[string]bool)
+
+	for i := range todo.Checklist {
+		item := &todo.Checklist[i]
+
+		if item.Title == "" || len(item.Title) > maxChecklistTitleLength {
+			return errors.Wrapf(ErrBadRequest, "checklist item titles must be 1 to %d bytes", maxChecklistTitleLength)
+		}
+
+		if item.ID == "" {
+			item.ID = uuid.NewV4().String()
+		}
+
+		if ids[item.ID] {
+			return errors.Wrap(ErrBadRequest, "checklist item IDs must be unique")
+		}
+
+		ids[item.ID] = true
+	}
+
+	todo.CompleteIfChecked()
+
+	return nil
+}
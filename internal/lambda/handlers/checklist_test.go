@@ -0,0 +1,288 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+func TestChecklist(t *testing.T) {
+	t.Run("AddChecklistItem", testAddChecklistItem)
+	t.Run("CheckChecklistItem", testCheckChecklistItem)
+	t.Run("AutoComplete", testAutoComplete)
+	t.Run("RemoveChecklistItem", testRemoveChecklistItem)
+	t.Run("ReorderChecklist", testReorderChecklist)
+	t.Run("ReorderChecklistBadRequest", testReorderChecklistBadRequest)
+	t.Run("ChecklistItemNotFound", testChecklistItemNotFound)
+	t.Run("ChecklistToDoNotFound", testChecklistToDoNotFound)
+	t.Run("CreateToDoWithChecklist", testCreateToDoWithChecklist)
+}
+
+// releaseToDo returns a ToDo with a checklist of three items, the first of which is checked
+func releaseToDo() *internal.ToDo {
+	return &internal.ToDo{
+		ID:    testUUID,
+		Title: "Release",
+		Checklist: []internal.ChecklistItem{
+			{ID: "tag", Title: "Tag", Checked: true},
+			{ID: "build", Title: "Build"},
+			{ID: "publish", Title: "Publish"},
+		},
+	}
+}
+
+// checklistRepo returns a RepoMock holding todo that records the ToDo saved
+func checklistRepo(todo *internal.ToDo, saved **internal.ToDo) *RepoMock {
+	return &RepoMock{
+		GetFn: func(id string) (*internal.ToDo, error) {
+			if id != todo.ID {
+				return nil, nil
+			}
+			return todo, nil
+		},
+		SaveFn: func(t *internal.ToDo) error {
+			*saved = t
+			return nil
+		},
+	}
+}
+
+// checklistRequest returns a request to the checklist of the test ToDo, or to one of its items if itemID is
+// not empty
+func checklistRequest(method, itemID, body string) events.APIGatewayProxyRequest {
+	req := events.APIGatewayProxyRequest{
+		Resource:       "/todos/{id}/checklist",
+		HTTPMethod:     method,
+		PathParameters: map[string]string{"id": testUUID},
+		Body:           body,
+	}
+
+	if itemID != "" {
+		req.Resource = "/todos/{id}/checklist/{itemId}"
+		req.PathParameters["itemId"] = itemID
+	}
+
+	return req
+}
+
+// titles returns the titles of the items of a checklist
+func titles(checklist []internal.ChecklistItem) string {
+	s := []string{}
+	for _, item := range checklist {
+		s = append(s, item.Title)
+	}
+	return strings.Join(s, ",")
+}
+
+func testAddChecklistItem(t *testing.T) {
+
+	var saved *internal.ToDo
+
+	todo := releaseToDo()
+	m := checklistRepo(todo, &saved)
+
+	resp, err := handlers.NewToDoHandler(m).Handle(checklistRequest(http.MethodPost, "", `{"title":"Announce"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	if saved == nil || titles(saved.Checklist) != "Tag,Build,Publish,Announce" || saved.Checklist[3].ID == "" {
+		t.Fatalf("Expected item to be added to the end, got %+v", saved)
+	}
+
+	if len(todo.Checklist) != 3 {
+		t.Fatal("Expected the stored ToDo not to be modified")
+	}
+
+	var got struct {
+		Progress internal.Progress `json:"progress"`
+	}
+
+	if err := json.Unmarshal([]byte(resp.Body), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Progress.String() != "1/4" {
+		t.Fatalf("Expected progress 1/4, got %s", got.Progress)
+	}
+
+	if _, ok := saved.Clocks["checklist"]; !ok {
+		t.Fatal("Expected checklist to be stamped")
+	}
+}
+
+func testCheckChecklistItem(t *testing.T) {
+
+	var saved *internal.ToDo
+
+	m := checklistRepo(releaseToDo(), &saved)
+
+	resp, err := handlers.NewToDoHandler(m).Handle(checklistRequest(http.MethodPut, "build", `{"checked":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	if !saved.Checklist[1].Checked || saved.Checklist[1].Title != "Build" || saved.Completed {
+		t.Fatalf("Expected Build to be checked, got %+v", saved.Checklist[1])
+	}
+}
+
+func testAutoComplete(t *testing.T) {
+
+	var saved *internal.ToDo
+
+	todo := releaseToDo()
+	todo.AutoComplete = true
+	todo.Checklist[1].Checked = true
+
+	m := checklistRepo(todo, &saved)
+
+	if _, err := handlers.NewToDoHandler(m).Handle(checklistRequest(http.MethodPut, "publish", `{"checked":true}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !saved.Completed {
+		t.Fatal("Expected ToDo to be completed when its last item is checked")
+	}
+}
+
+func testRemoveChecklistItem(t *testing.T) {
+
+	var saved *internal.ToDo
+
+	m := checklistRepo(releaseToDo(), &saved)
+
+	if _, err := handlers.NewToDoHandler(m).Handle(checklistRequest(http.MethodDelete, "build", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if titles(saved.Checklist) != "Tag,Publish" {
+		t.Fatalf("Expected Build to be removed, got %s", titles(saved.Checklist))
+	}
+}
+
+func testReorderChecklist(t *testing.T) {
+
+	var saved *internal.ToDo
+
+	m := checklistRepo(releaseToDo(), &saved)
+
+	req := checklistRequest(http.MethodPut, "", `{"order":["build","publish","tag"]}`)
+
+	if _, err := handlers.NewToDoHandler(m).Handle(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if titles(saved.Checklist) != "Build,Publish,Tag" || !saved.Checklist[2].Checked {
+		t.Fatalf("Expected checklist to be reordered, got %+v", saved.Checklist)
+	}
+}
+
+func testReorderChecklistBadRequest(t *testing.T) {
+
+	for _, body := range []string{`{"order":["build","publish"]}`, `{"order":["build","build","tag"]}`, `{"order":["x","build","tag"]}`} {
+		var saved *internal.ToDo
+
+		m := checklistRepo(releaseToDo(), &saved)
+
+		resp, err := handlers.NewToDoHandler(m).Handle(checklistRequest(http.MethodPut, "", body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expected %d for %s, got %d", http.StatusBadRequest, body, resp.StatusCode)
+		}
+
+		if m.SaveInvoked {
+			t.Fatalf("Expected Save not to be invoked for %s", body)
+		}
+	}
+}
+
+func testChecklistItemNotFound(t *testing.T) {
+
+	var saved *internal.ToDo
+
+	m := checklistRepo(releaseToDo(), &saved)
+
+	resp, err := handlers.NewToDoHandler(m).Handle(checklistRequest(http.MethodDelete, "missing", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func testChecklistToDoNotFound(t *testing.T) {
+
+	var saved *internal.ToDo
+
+	todo := releaseToDo()
+	todo.ID = "other"
+
+	resp, err := handlers.NewToDoHandler(checklistRepo(todo, &saved)).Handle(checklistRequest(http.MethodPost, "", `{"title":"Tag"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func testCreateToDoWithChecklist(t *testing.T) {
+
+	var saved *internal.ToDo
+
+	m := &RepoMock{
+		SaveFn: func(t *internal.ToDo) error {
+			saved = t
+			return nil
+		},
+	}
+
+	req := events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodPost,
+		Body:       `{"title":"Release","autoComplete":true,"checklist":[{"title":"Tag","checked":true}]}`,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	if saved.Checklist[0].ID == "" || !saved.Completed {
+		t.Fatalf("Expected item to be given an ID and the ToDo to be completed, got %+v", saved)
+	}
+
+	req.Body = `{"title":"Release","checklist":[{"title":""}]}`
+
+	resp, err = handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d for an item without a title, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
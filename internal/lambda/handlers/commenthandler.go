@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// commentsResource is the API Gateway resource for listing and adding the comments on a ToDo
+	commentsResource = "/todos/{id}/comments"
+	// commentResource is the API Gateway resource for a comment on a ToDo
+	commentResource = "/todos/{id}/comments/{commentId}"
+	// maxCommentLength is the longest body of a comment, in bytes
+	maxCommentLength = 10000
+)
+
+// CommentRequest is the body of a request to add or edit a comment
+type CommentRequest struct {
+	Body string `json:"body"`
+}
+
+// CommentHandler provides a handle method to handle incoming AWS API Gateway requests for the comments on
+// ToDos. Comments can be read by anyone and only edited and removed by their author.
+type CommentHandler struct {
+	todos    database.ToDoRepo
+	comments database.CommentRepo
+	members  database.MemberRepo
+}
+
+// NewCommentHandler creates a new Comment handler. Mentions are resolved to the members in members.
+func NewCommentHandler(todos database.ToDoRepo, comments database.CommentRepo, members database.MemberRepo) *CommentHandler {
+	return &CommentHandler{
+		todos:    todos,
+		comments: comments,
+		members:  members,
+	}
+}
+
+// Handle handles a request from AWS API Gateway and returns a response
+func (h *CommentHandler) Handle(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	userID := callerID(req)
+	if userID == "" {
+		return CreateErrorResponse(ErrUnauthorized)
+	}
+
+	id := req.PathParameters["id"]
+	if id == "" {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "ID is required"))
+	}
+
+	todo, err := h.todos.Get(id)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	} else if todo == nil {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	switch {
+	case req.Resource == commentsResource && req.HTTPMethod == "GET":
+		return h.getAll(todo)
+	case req.Resource == commentsResource && req.HTTPMethod == "POST":
+		return h.post(req, todo, userID)
+	case req.Resource == commentResource && req.HTTPMethod == "GET":
+		return h.get(req, todo)
+	case req.Resource == commentResource && req.HTTPMethod == "PUT":
+		return h.put(req, todo, userID)
+	case req.Resource == commentResource && req.HTTPMethod == "DELETE":
+		return h.delete(req, todo, userID)
+	default:
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+}
+
+// getAll returns the comments on a ToDo, oldest first
+func (h *CommentHandler) getAll(todo *internal.ToDo) (events.APIGatewayProxyResponse, error) {
+
+	comments, err := h.comments.GetByToDo(todoListID(todo), todo.ID)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse(comments)
+}
+
+// get returns a comment
+func (h *CommentHandler) get(req events.APIGatewayProxyRequest, todo *internal.ToDo) (events.APIGatewayProxyResponse, error) {
+
+	c, err := h.comment(req, todo)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	return CreateOKResponse(c)
+}
+
+// post adds a comment by userID to a ToDo
+func (h *CommentHandler) post(req events.APIGatewayProxyRequest, todo *internal.ToDo, userID string) (events.APIGatewayProxyResponse, error) {
+
+	body, err := parseCommentBody(req.Body)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	mentions, err := h.mentions(todoListID(todo), body, userID)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	c := &internal.Comment{
+		ID:       uuid.NewV4().String(),
+		ListID:   todoListID(todo),
+		ToDoID:   todo.ID,
+		AuthorID: userID,
+		Body:     body,
+		Mentions: mentions,
+	}
+
+	if err := h.comments.Save(c); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	h.count(todo)
+
+	return CreateOKResponse(c)
+}
+
+// put edits a comment. Only its author can edit it.
+func (h *CommentHandler) put(req events.APIGatewayProxyRequest, todo *internal.ToDo, userID string) (events.APIGatewayProxyResponse, error) {
+
+	c, err := h.comment(req, todo)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	if c.AuthorID != userID {
+		return CreateErrorResponse(errors.Wrap(ErrForbidden, "only the author can edit a comment"))
+	}
+
+	body, err := parseCommentBody(req.Body)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	if c.Mentions, err = h.mentions(todoListID(todo), body, userID); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	edited := time.Now().UTC()
+	c.Body, c.Edited = body, &edited
+
+	if err := h.comments.Save(c); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse(c)
+}
+
+// delete removes a comment. Only its author can remove it.
+func (h *CommentHandler) delete(req events.APIGatewayProxyRequest, todo *internal.ToDo, userID string) (events.APIGatewayProxyResponse, error) {
+
+	c, err := h.comment(req, todo)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	if c.AuthorID != userID {
+		return CreateErrorResponse(errors.Wrap(ErrForbidden, "only the author can delete a comment"))
+	}
+
+	if err := h.comments.Delete(todoListID(todo), todo.ID, c.ID); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	h.count(todo)
+
+	return CreateOKResponse("")
+}
+
+// todoListID returns the list of a ToDo, which is the default list if it has none
+func todoListID(todo *internal.ToDo) string {
+	if todo.ListID == "" {
+		return internal.DefaultListID
+	}
+	return todo.ListID
+}
+
+// comment returns the comment in the path
+func (h *CommentHandler) comment(req events.APIGatewayProxyRequest, todo *internal.ToDo) (*internal.Comment, error) {
+
+	c, err := h.comments.Get(todoListID(todo), todo.ID, req.PathParameters["commentId"])
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	if c == nil {
+		return nil, ErrNotFound
+	}
+
+	return c, nil
+}
+
+// mentions returns the IDs of the members of a list mentioned in body, other than its author. Handles that
+// are not members are left as text.
+func (h *CommentHandler) mentions(listID, body, authorID string) ([]string, error) {
+
+	handles := internal.Mentions(body)
+	if len(handles) == 0 {
+		return nil, nil
+	}
+
+	members, err := h.members.GetByList(listID)
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []string
+
+	for _, handle := range handles {
+		for _, m := range members {
+			if m.Handle == handle && m.UserID != authorID {
+				userIDs = append(userIDs, m.UserID)
+			}
+		}
+	}
+
+	return userIDs, nil
+}
+
+// count updates the comment count of a ToDo. Failures are logged, as the comment has already been saved,
+// and the count is corrected when the next comment is added or removed.
+func (h *CommentHandler) count(todo *internal.ToDo) {
+
+	comments, err := h.comments.GetByToDo(todoListID(todo), todo.ID)
+	if err != nil {
+		log.Printf("Could not count the comments on ToDo %s: %v", todo.ID, err)
+		return
+	}
+
+	if len(comments) == todo.CommentCount {
+		return
+	}
+
+	todo.CommentCount = len(comments)
+
+	if err := h.todos.Save(todo); err != nil {
+		log.Printf("Could not save the comment count of ToDo %s: %v", todo.ID, err)
+	}
+}
+
+// parseCommentBody returns the trimmed body of a comment request if it is valid
+func parseCommentBody(body string) (string, error) {
+
+	var r CommentRequest
+
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		return "", errors.Wrap(ErrBadRequest, "body is not valid JSON")
+	}
+
+	r.Body = strings.TrimSpace(r.Body)
+
+	if r.Body == "" || len(r.Body) > maxCommentLength {
+		return "", errors.Wrapf(ErrBadRequest, "body must be 1 to %d bytes", maxCommentLength)
+	}
+
+	return r.Body, nil
+}
@@ -0,0 +1,304 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+var listMembers = []internal.Member{
+	{ListID: internal.DefaultListID, UserID: "user-1", Handle: "ann"},
+	{ListID: internal.DefaultListID, UserID: "user-2", Handle: "bob"},
+}
+
+func TestCommentHandler(t *testing.T) {
+	t.Run("PostCommentOK", testPostCommentOK)
+	t.Run("PostCommentInvalid", testPostCommentInvalid)
+	t.Run("PostCommentUnauthorized", testPostCommentUnauthorized)
+	t.Run("PostCommentToDoNotFound", testPostCommentToDoNotFound)
+	t.Run("EditCommentOK", testEditCommentOK)
+	t.Run("EditCommentNotAuthor", testEditCommentNotAuthor)
+	t.Run("DeleteCommentOK", testDeleteCommentOK)
+	t.Run("DeleteCommentNotAuthor", testDeleteCommentNotAuthor)
+	t.Run("GetCommentsOK", testGetCommentsOK)
+}
+
+// memoryComments returns a CommentRepoMock that keeps comments in a map
+func memoryComments(comments ...internal.Comment) (*CommentRepoMock, map[string]internal.Comment) {
+	m := make(map[string]internal.Comment)
+	for _, c := range comments {
+		m[c.ID] = c
+	}
+
+	return &CommentRepoMock{
+		GetFn: func(listID, todoID, id string) (*internal.Comment, error) {
+			if c, ok := m[id]; ok && c.ListID == listID && c.ToDoID == todoID {
+				return &c, nil
+			}
+			return nil, nil
+		},
+		GetByToDoFn: func(listID, todoID string) ([]internal.Comment, error) {
+			var all []internal.Comment
+			for _, c := range m {
+				if c.ListID == listID && c.ToDoID == todoID {
+					all = append(all, c)
+				}
+			}
+			return all, nil
+		},
+		SaveFn: func(c *internal.Comment) error {
+			m[c.ID] = *c
+			return nil
+		},
+		DeleteFn: func(listID, todoID, id string) error {
+			delete(m, id)
+			return nil
+		},
+	}, m
+}
+
+// staticMembers returns a MemberRepoMock with listMembers in every list
+func staticMembers() *MemberRepoMock {
+	return &MemberRepoMock{
+		GetByListFn: func(string) ([]internal.Member, error) {
+			return listMembers, nil
+		},
+	}
+}
+
+func commentRequest(method, commentID, body, userID string) events.APIGatewayProxyRequest {
+	req := events.APIGatewayProxyRequest{
+		Resource:       "/todos/{id}/comments",
+		HTTPMethod:     method,
+		PathParameters: map[string]string{"id": "1"},
+		Body:           body,
+	}
+
+	if commentID != "" {
+		req.Resource = "/todos/{id}/comments/{commentId}"
+		req.PathParameters["commentId"] = commentID
+	}
+
+	return authorized(req, userID)
+}
+
+var savedComment = internal.Comment{
+	ID:       "c-1",
+	ListID:   internal.DefaultListID,
+	ToDoID:   "1",
+	AuthorID: "user-1",
+	Body:     "First",
+}
+
+func testPostCommentOK(t *testing.T) {
+
+	repo, todos := memoryRepo(internal.ToDo{ID: "1", Title: "Write report"})
+	comments, saved := memoryComments()
+
+	req := commentRequest(http.MethodPost, "", `{"body":"  @Bob @ann @carol take a look  "}`, "user-1")
+
+	resp, err := handlers.NewCommentHandler(repo, comments, staticMembers()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	var got internal.Comment
+	if err := json.Unmarshal([]byte(resp.Body), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.ID == "" || got.AuthorID != "user-1" || got.ListID != internal.DefaultListID || got.ToDoID != "1" ||
+		got.Body != "@Bob @ann @carol take a look" {
+		t.Fatalf("Unexpected Comment %+v", got)
+	}
+
+	// The author and handles that are not members are not mentioned
+	if !reflect.DeepEqual(got.Mentions, []string{"user-2"}) {
+		t.Fatalf("Expected mentions [user-2], got %v", got.Mentions)
+	}
+
+	if _, ok := saved[got.ID]; !ok {
+		t.Fatal("Expected Comment to be saved")
+	}
+
+	if todos["1"].CommentCount != 1 {
+		t.Fatalf("Expected comment count 1, got %d", todos["1"].CommentCount)
+	}
+}
+
+func testPostCommentInvalid(t *testing.T) {
+
+	repo, _ := memoryRepo(internal.ToDo{ID: "1"})
+	comments, _ := memoryComments()
+
+	for _, body := range []string{`{"body":"   "}`, `not json`} {
+		req := commentRequest(http.MethodPost, "", body, "user-1")
+
+		resp, err := handlers.NewCommentHandler(repo, comments, staticMembers()).Handle(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expected %d for %s, got %d", http.StatusBadRequest, body, resp.StatusCode)
+		}
+	}
+
+	if comments.SaveInvoked {
+		t.Fatal("Expected no Comment to be saved")
+	}
+}
+
+func testPostCommentUnauthorized(t *testing.T) {
+
+	repo, _ := memoryRepo(internal.ToDo{ID: "1"})
+	comments, _ := memoryComments()
+
+	req := commentRequest(http.MethodPost, "", `{"body":"Hi"}`, "")
+	req.RequestContext.Authorizer = nil
+
+	resp, err := handlers.NewCommentHandler(repo, comments, staticMembers()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
+
+func testPostCommentToDoNotFound(t *testing.T) {
+
+	repo, _ := memoryRepo()
+	comments, _ := memoryComments()
+
+	resp, err := handlers.NewCommentHandler(repo, comments, staticMembers()).
+		Handle(commentRequest(http.MethodPost, "", `{"body":"Hi"}`, "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func testEditCommentOK(t *testing.T) {
+
+	repo, _ := memoryRepo(internal.ToDo{ID: "1", CommentCount: 1})
+	comments, saved := memoryComments(savedComment)
+
+	req := commentRequest(http.MethodPut, "c-1", `{"body":"Edited for @bob"}`, "user-1")
+
+	resp, err := handlers.NewCommentHandler(repo, comments, staticMembers()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	c := saved["c-1"]
+	if c.Body != "Edited for @bob" || c.Edited == nil || !reflect.DeepEqual(c.Mentions, []string{"user-2"}) {
+		t.Fatalf("Unexpected Comment saved %+v", c)
+	}
+
+	if repo.SaveInvoked {
+		t.Fatal("Expected ToDo not to be saved when its comment count is unchanged")
+	}
+}
+
+func testEditCommentNotAuthor(t *testing.T) {
+
+	repo, _ := memoryRepo(internal.ToDo{ID: "1", CommentCount: 1})
+	comments, _ := memoryComments(savedComment)
+
+	resp, err := handlers.NewCommentHandler(repo, comments, staticMembers()).
+		Handle(commentRequest(http.MethodPut, "c-1", `{"body":"Mine now"}`, "user-2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	if comments.SaveInvoked {
+		t.Fatal("Expected Comment not to be saved")
+	}
+}
+
+func testDeleteCommentOK(t *testing.T) {
+
+	repo, todos := memoryRepo(internal.ToDo{ID: "1", CommentCount: 1})
+	comments, saved := memoryComments(savedComment)
+
+	resp, err := handlers.NewCommentHandler(repo, comments, staticMembers()).
+		Handle(commentRequest(http.MethodDelete, "c-1", "", "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	if _, ok := saved["c-1"]; ok {
+		t.Fatal("Expected Comment to be deleted")
+	}
+
+	if todos["1"].CommentCount != 0 {
+		t.Fatalf("Expected comment count 0, got %d", todos["1"].CommentCount)
+	}
+}
+
+func testDeleteCommentNotAuthor(t *testing.T) {
+
+	repo, _ := memoryRepo(internal.ToDo{ID: "1", CommentCount: 1})
+	comments, _ := memoryComments(savedComment)
+
+	resp, err := handlers.NewCommentHandler(repo, comments, staticMembers()).
+		Handle(commentRequest(http.MethodDelete, "c-1", "", "user-2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	if comments.DeleteInvoked {
+		t.Fatal("Expected Comment not to be deleted")
+	}
+}
+
+func testGetCommentsOK(t *testing.T) {
+
+	repo, _ := memoryRepo(internal.ToDo{ID: "1", CommentCount: 1})
+	comments, _ := memoryComments(savedComment)
+
+	resp, err := handlers.NewCommentHandler(repo, comments, staticMembers()).
+		Handle(commentRequest(http.MethodGet, "", "", "user-2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []internal.Comment
+	if err := json.Unmarshal([]byte(resp.Body), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 || got[0].ID != "c-1" {
+		t.Fatalf("Unexpected comments %+v", got)
+	}
+}
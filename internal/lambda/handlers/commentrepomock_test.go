@@ -0,0 +1,41 @@
+package handlers_test
+
+import (
+	"github.com/benjaminbartels/todo/internal"
+)
+
+// CommentRepoMock is used to mock a CommentRepo
+type CommentRepoMock struct {
+	GetFn            func(string, string, string) (*internal.Comment, error)
+	GetByToDoFn      func(string, string) ([]internal.Comment, error)
+	SaveFn           func(*internal.Comment) error
+	DeleteFn         func(string, string, string) error
+	GetInvoked       bool
+	GetByToDoInvoked bool
+	SaveInvoked      bool
+	DeleteInvoked    bool
+}
+
+// Get returns a Comment by its ID
+func (m *CommentRepoMock) Get(listID, todoID, id string) (*internal.Comment, error) {
+	m.GetInvoked = true
+	return m.GetFn(listID, todoID, id)
+}
+
+// GetByToDo returns the comments on a ToDo
+func (m *CommentRepoMock) GetByToDo(listID, todoID string) ([]internal.Comment, error) {
+	m.GetByToDoInvoked = true
+	return m.GetByToDoFn(listID, todoID)
+}
+
+// Save creates or updates a Comment
+func (m *CommentRepoMock) Save(comment *internal.Comment) error {
+	m.SaveInvoked = true
+	return m.SaveFn(comment)
+}
+
+// Delete removes a Comment
+func (m *CommentRepoMock) Delete(listID, todoID, id string) error {
+	m.DeleteInvoked = true
+	return m.DeleteFn(listID, todoID, id)
+}
@@ -0,0 +1,33 @@
+package handlers_test
+
+import (
+	"github.com/benjaminbartels/todo/internal"
+)
+
+// ConnectionRepoMock is used to mock a ConnectionRepo
+type ConnectionRepoMock struct {
+	GetByListFn      func(string) ([]internal.Connection, error)
+	SaveFn           func(*internal.Connection) error
+	DeleteFn         func(string) error
+	GetByListInvoked bool
+	SaveInvoked      bool
+	DeleteInvoked    bool
+}
+
+// GetByList returns the connections subscribed to a list
+func (m *ConnectionRepoMock) GetByList(listID string) ([]internal.Connection, error) {
+	m.GetByListInvoked = true
+	return m.GetByListFn(listID)
+}
+
+// Save stores a connection
+func (m *ConnectionRepoMock) Save(connection *internal.Connection) error {
+	m.SaveInvoked = true
+	return m.SaveFn(connection)
+}
+
+// Delete removes a connection
+func (m *ConnectionRepoMock) Delete(id string) error {
+	m.DeleteInvoked = true
+	return m.DeleteFn(id)
+}
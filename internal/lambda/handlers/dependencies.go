@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/pkg/errors"
+)
+
+const (
+	// blockersResource is the API Gateway resource for the ToDos that block a ToDo
+	blockersResource = "/todos/{id}/blockers"
+	// blockerResource is the API Gateway resource for adding and removing a ToDo that blocks a ToDo
+	blockerResource = "/todos/{id}/blockers/{blockerId}"
+	// graphResource is the API Gateway resource for the ToDos that block, or are blocked by, a ToDo
+	graphResource = "/todos/{id}/graph"
+	// maxBlockers is the most ToDos that can block a ToDo
+	maxBlockers = 50
+)
+
+// Graph is a ToDo with the ToDos that block it, directly or through other ToDos, and those it blocks, each
+// nearest first
+type Graph struct {
+	ToDo       internal.ToDo   `json:"todo"`
+	Upstream   []internal.ToDo `json:"upstream"`
+	Downstream []internal.ToDo `json:"downstream"`
+}
+
+// dependencies handles requests for the ToDos that block a ToDo
+func (h *ToDoHandler) dependencies(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	id := req.PathParameters["id"]
+	if id == "" {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "ID is required"))
+	}
+
+	switch {
+	case req.Resource == blockersResource && req.HTTPMethod == "GET":
+		return h.graph(id, false)
+	case req.Resource == blockerResource && req.HTTPMethod == "PUT":
+		return h.addBlocker(id, req.PathParameters["blockerId"])
+	case req.Resource == blockerResource && req.HTTPMethod == "DELETE":
+		return h.removeBlocker(id, req.PathParameters["blockerId"])
+	case req.Resource == graphResource && req.HTTPMethod == "GET":
+		return h.graph(id, true)
+	default:
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+}
+
+// graph responds with the ToDos that block a ToDo directly, or with its Graph if closure is set
+func (h *ToDoHandler) graph(id string, closure bool) (events.APIGatewayProxyResponse, error) {
+
+	todos, err := h.repo.GetAll()
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	byID := make(map[string]internal.ToDo, len(todos))
+	for _, t := range todos {
+		byID[t.ID] = t
+	}
+
+	t, ok := byID[id]
+	if !ok {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	lookup := func(ids []string) []internal.ToDo {
+		found := []internal.ToDo{}
+		for _, id := range ids {
+			if t, ok := byID[id]; ok {
+				found = append(found, t)
+			}
+		}
+		return found
+	}
+
+	if !closure {
+		return CreateOKResponse(lookup(t.BlockedBy))
+	}
+
+	g := internal.NewDependencyGraph(todos)
+
+	return CreateOKResponse(Graph{
+		ToDo:       t,
+		Upstream:   lookup(g.Upstream(id)),
+		Downstream: lookup(g.Downstream(id)),
+	})
+}
+
+// addBlocker makes blockerID block a ToDo, unless blockerID is already blocked by it. The ToDo is only saved
+// if it was not modified since the ToDos were read, as the check for a cycle is made against them.
+func (h *ToDoHandler) addBlocker(id, blockerID string) (events.APIGatewayProxyResponse, error) {
+
+	todos, err := h.repo.GetAll()
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	var t, blocker *internal.ToDo
+
+	for i := range todos {
+		switch todos[i].ID {
+		case id:
+			t = &todos[i]
+		case blockerID:
+			blocker = &todos[i]
+		}
+	}
+
+	if t == nil {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	if blocker == nil && blockerID != id {
+		return CreateErrorResponse(errors.Wrapf(ErrBadRequest, "blocker %s does not exist", blockerID))
+	}
+
+	for _, b := range t.BlockedBy {
+		if b == blockerID {
+			return CreateOKResponse(t)
+		}
+	}
+
+	if len(t.BlockedBy) >= maxBlockers {
+		return CreateErrorResponse(errors.Wrapf(ErrBadRequest, "a ToDo can be blocked by at most %d ToDos", maxBlockers))
+	}
+
+	if path := internal.NewDependencyGraph(todos).Path(blockerID, id); path != nil {
+		return CreateErrorResponse(errors.Wrapf(ErrBadRequest, "%s can not be blocked by %s, as that would create the cycle %s",
+			id, blockerID, strings.Join(append([]string{id}, path...), " -> ")))
+	}
+
+	todo := *t
+	todo.BlockedBy = append(append([]string{}, t.BlockedBy...), blockerID)
+	todo.Stamp(t, writeTime(t))
+
+	err = saveIfUnmodified(h.repo, &todo, t.ModTime)
+	if errors.Cause(err) == database.ErrConflict {
+		return CreateErrorResponse(errors.Wrap(ErrConflict, "ToDo was modified while adding the blocker; try again"))
+	} else if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse(todo)
+}
+
+// saveIfUnmodified saves todo if it was last modified at modTime, when the repository can check that
+func saveIfUnmodified(repo database.ToDoRepo, todo *internal.ToDo, modTime time.Time) error {
+	if c, ok := repo.(database.ToDoConditionalRepo); ok {
+		err := c.SaveIfUnmodified(todo, modTime)
+		if errors.Cause(err) != database.ErrNotSupported {
+			return err
+		}
+	}
+
+	return repo.Save(todo)
+}
+
+// removeBlocker stops blockerID from blocking a ToDo. Blockers that were deleted can be removed.
+func (h *ToDoHandler) removeBlocker(id, blockerID string) (events.APIGatewayProxyResponse, error) {
+
+	t, err := h.repo.Get(id)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	} else if t == nil {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	todo := *t
+	todo.BlockedBy = nil
+
+	for _, b := range t.BlockedBy {
+		if b != blockerID {
+			todo.BlockedBy = append(todo.BlockedBy, b)
+		}
+	}
+
+	if len(todo.BlockedBy) == len(t.BlockedBy) {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	todo.Stamp(t, writeTime(t))
+
+	if err := h.repo.Save(&todo); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse(todo)
+}
+
+// parseForce returns whether a request sets force, to complete a ToDo while ToDos that block it are open
+func parseForce(req events.APIGatewayProxyRequest) (bool, error) {
+	v, ok := req.QueryStringParameters["force"]
+	if !ok {
+		return false, nil
+	}
+
+	force, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, errors.Wrap(ErrBadRequest, "force must be true or false")
+	}
+
+	return force, nil
+}
+
+// checkBlockers returns an error if todo is being completed while a ToDo that blocks it is open, unless
+// force is set. previous is nil when todo is created. Blockers that were deleted do not block.
+func checkBlockers(repo database.ToDoRepo, previous, todo *internal.ToDo, force bool) error {
+
+	if force || previous == nil || !todo.Completed || previous.Completed || len(previous.BlockedBy) == 0 {
+		return nil
+	}
+
+	open := []string{}
+
+	for _, id := range previous.BlockedBy {
+		b, err := repo.Get(id)
+		if err != nil {
+			return repoError(err)
+		}
+
+		if b != nil && !b.Completed {
+			open = append(open, id)
+		}
+	}
+
+	if len(open) > 0 {
+		return errors.Wrapf(ErrConflict, "ToDo is blocked by open ToDos %s; set force=true to complete it anyway",
+			strings.Join(open, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,469 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/crdt"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+	"github.com/pkg/errors"
+)
+
+func TestDependencies(t *testing.T) {
+	t.Run("AddBlocker", testAddBlocker)
+	t.Run("AddBlockerCycle", testAddBlockerCycle)
+	t.Run("AddBlockerNotFound", testAddBlockerNotFound)
+	t.Run("AddBlockerModified", testAddBlockerModified)
+	t.Run("RemoveBlocker", testRemoveBlocker)
+	t.Run("GetBlockers", testGetBlockers)
+	t.Run("GetGraph", testGetGraph)
+	t.Run("CompleteBlocked", testCompleteBlocked)
+	t.Run("CompleteBlockedForced", testCompleteBlockedForced)
+	t.Run("PatchBlocked", testPatchBlocked)
+	t.Run("ChecklistCompletesBlocked", testChecklistCompletesBlocked)
+	t.Run("SyncCompletesBlocked", testSyncCompletesBlocked)
+	t.Run("CalDAVCompletesBlocked", testCalDAVCompletesBlocked)
+	t.Run("PutKeepsBlockers", testPutKeepsBlockers)
+}
+
+// blockedRepo returns a RepoMock holding the chain 3 blocked by 2 blocked by 1, and 4 blocked by 3
+func blockedRepo() (*RepoMock, map[string]internal.ToDo) {
+	return memoryRepo(
+		internal.ToDo{ID: "1", Title: "Order parts"},
+		internal.ToDo{ID: "2", Title: "Assemble", BlockedBy: []string{"1"}},
+		internal.ToDo{ID: "3", Title: "Test", BlockedBy: []string{"2"}},
+		internal.ToDo{ID: "4", Title: "Ship", BlockedBy: []string{"3"}},
+		internal.ToDo{ID: "5", Title: "Unrelated"},
+	)
+}
+
+// blockerRequest returns a request to add or remove blockerID from the blockers of id
+func blockerRequest(method, id, blockerID string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		Resource:       "/todos/{id}/blockers/{blockerId}",
+		HTTPMethod:     method,
+		PathParameters: map[string]string{"id": id, "blockerId": blockerID},
+	}
+}
+
+func testAddBlocker(t *testing.T) {
+
+	m, todos := blockedRepo()
+
+	resp, err := handlers.NewToDoHandler(m).Handle(blockerRequest(http.MethodPut, "2", "5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	if got := todos["2"].BlockedBy; len(got) != 2 || got[0] != "1" || got[1] != "5" {
+		t.Fatalf("Expected blockers [1 5], got %v", got)
+	}
+}
+
+func testAddBlockerCycle(t *testing.T) {
+
+	m, todos := blockedRepo()
+
+	resp, err := handlers.NewToDoHandler(m).Handle(blockerRequest(http.MethodPut, "1", "4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	var e struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(resp.Body), &e); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(e.Error, "1 -> 4 -> 3 -> 2 -> 1") {
+		t.Fatalf("Expected the cycle in the error, got %s", e.Error)
+	}
+
+	if m.SaveInvoked || len(todos["1"].BlockedBy) != 0 {
+		t.Fatal("Expected the ToDo not to be saved")
+	}
+
+	resp, err = handlers.NewToDoHandler(m).Handle(blockerRequest(http.MethodPut, "1", "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d for a ToDo blocking itself, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func testAddBlockerNotFound(t *testing.T) {
+
+	m, _ := blockedRepo()
+
+	resp, err := handlers.NewToDoHandler(m).Handle(blockerRequest(http.MethodPut, "9", "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+	resp, err = handlers.NewToDoHandler(m).Handle(blockerRequest(http.MethodPut, "1", "9"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d for a missing blocker, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func testAddBlockerModified(t *testing.T) {
+
+	m, todos := blockedRepo()
+	read := todos["2"].ModTime
+
+	// Another request changed the ToDo after it was read
+	c := &ConditionalRepoMock{
+		RepoMock: m,
+		SaveIfUnmodifiedFn: func(todo *internal.ToDo, modTime time.Time) error {
+			if todo.ID != "2" || !modTime.Equal(read) {
+				t.Fatalf("Expected ToDo 2 to be saved if unmodified since %v, got %s and %v", read, todo.ID, modTime)
+			}
+			return errors.Wrap(database.ErrConflict, "ToDo 2 was modified")
+		},
+	}
+
+	resp, err := handlers.NewToDoHandler(c).Handle(blockerRequest(http.MethodPut, "2", "5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusConflict, resp.StatusCode, resp.Body)
+	}
+
+	if m.SaveInvoked || len(todos["2"].BlockedBy) != 1 {
+		t.Fatal("Expected the ToDo not to be saved")
+	}
+}
+
+func testRemoveBlocker(t *testing.T) {
+
+	m, todos := blockedRepo()
+
+	resp, err := handlers.NewToDoHandler(m).Handle(blockerRequest(http.MethodDelete, "3", "2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if len(todos["3"].BlockedBy) != 0 {
+		t.Fatalf("Expected no blockers, got %v", todos["3"].BlockedBy)
+	}
+
+	resp, err = handlers.NewToDoHandler(m).Handle(blockerRequest(http.MethodDelete, "3", "2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func testGetBlockers(t *testing.T) {
+
+	m, _ := blockedRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:       "/todos/{id}/blockers",
+		HTTPMethod:     http.MethodGet,
+		PathParameters: map[string]string{"id": "3"},
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(t, resp.Body); got != "2" {
+		t.Fatalf("Expected blocker 2, got %s", got)
+	}
+}
+
+func testGetGraph(t *testing.T) {
+
+	m, _ := blockedRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:       "/todos/{id}/graph",
+		HTTPMethod:     http.MethodGet,
+		PathParameters: map[string]string{"id": "2"},
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var g handlers.Graph
+	if err := json.Unmarshal([]byte(resp.Body), &g); err != nil {
+		t.Fatal(err)
+	}
+
+	if g.ToDo.ID != "2" || len(g.Upstream) != 1 || g.Upstream[0].ID != "1" {
+		t.Fatalf("Unexpected upstream %+v", g)
+	}
+
+	if len(g.Downstream) != 2 || g.Downstream[0].ID != "3" || g.Downstream[1].ID != "4" {
+		t.Fatalf("Unexpected downstream %+v", g.Downstream)
+	}
+}
+
+func testCompleteBlocked(t *testing.T) {
+
+	m, todos := blockedRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:       "/todos/{id}",
+		HTTPMethod:     http.MethodPut,
+		PathParameters: map[string]string{"id": "2"},
+		Body:           `{"id":"2","title":"Assemble","completed":true}`,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Expected %d, got %d", http.StatusConflict, resp.StatusCode)
+	}
+
+	if todos["2"].Completed {
+		t.Fatal("Expected the ToDo not to be completed")
+	}
+
+	todos["1"] = internal.ToDo{ID: "1", Title: "Order parts", Completed: true}
+
+	resp, err = handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || !todos["2"].Completed {
+		t.Fatalf("Expected the ToDo to be completed once its blocker is, got %d", resp.StatusCode)
+	}
+}
+
+func testCompleteBlockedForced(t *testing.T) {
+
+	m, todos := blockedRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:              "/todos/{id}",
+		HTTPMethod:            http.MethodPut,
+		PathParameters:        map[string]string{"id": "2"},
+		QueryStringParameters: map[string]string{"force": "maybe"},
+		Body:                  `{"id":"2","title":"Assemble","completed":true}`,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	req.QueryStringParameters["force"] = "true"
+
+	resp, err = handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || !todos["2"].Completed {
+		t.Fatalf("Expected the ToDo to be completed, got %d", resp.StatusCode)
+	}
+}
+
+func testPatchBlocked(t *testing.T) {
+
+	m, todos := blockedRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:       "/todos/{id}",
+		HTTPMethod:     http.MethodPatch,
+		PathParameters: map[string]string{"id": "3"},
+		Body:           `{"completed":true}`,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Expected %d, got %d", http.StatusConflict, resp.StatusCode)
+	}
+
+	req.Body = `{"title":"Test everything","blockedBy":null}`
+
+	resp, err = handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	if got := todos["3"]; got.Title != "Test everything" || len(got.BlockedBy) != 1 {
+		t.Fatalf("Expected the title to change and the blockers to be kept, got %+v", got)
+	}
+
+	req.Body = `{"id":"6"}`
+
+	resp, err = handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d when changing the ID, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func testPutKeepsBlockers(t *testing.T) {
+
+	m, todos := blockedRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:       "/todos/{id}",
+		HTTPMethod:     http.MethodPut,
+		PathParameters: map[string]string{"id": "4"},
+		Body:           `{"id":"4","title":"Ship it","blockedBy":["5"]}`,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if got := todos["4"].BlockedBy; len(got) != 1 || got[0] != "3" {
+		t.Fatalf("Expected blockers to only change through their API, got %v", got)
+	}
+}
+
+func testChecklistCompletesBlocked(t *testing.T) {
+
+	m, todos := blockedRepo()
+	todos["2"] = internal.ToDo{
+		ID:           "2",
+		Title:        "Assemble",
+		BlockedBy:    []string{"1"},
+		AutoComplete: true,
+		Checklist:    []internal.ChecklistItem{{ID: "frame", Title: "Frame"}},
+	}
+
+	req := events.APIGatewayProxyRequest{
+		Resource:       "/todos/{id}/checklist/{itemId}",
+		HTTPMethod:     http.MethodPut,
+		PathParameters: map[string]string{"id": "2", "itemId": "frame"},
+		Body:           `{"checked":true}`,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusConflict || todos["2"].Completed {
+		t.Fatalf("Expected %d and the ToDo not to be completed, got %d", http.StatusConflict, resp.StatusCode)
+	}
+
+	req.QueryStringParameters = map[string]string{"force": "true"}
+
+	resp, err = handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || !todos["2"].Completed {
+		t.Fatalf("Expected the ToDo to be completed, got %d", resp.StatusCode)
+	}
+}
+
+func testSyncCompletesBlocked(t *testing.T) {
+
+	m, todos := blockedRepo()
+
+	base := todos["2"].ModTime
+	completed := todos["2"]
+	completed.Completed = true
+
+	merged := todos["3"]
+	merged.Completed = true
+	merged.Stamp(nil, crdt.FromTime(time.Now()))
+
+	result := postSync(t, handlers.NewToDoHandler(m),
+		handlers.Mutation{Op: handlers.MutationUpdate, ToDo: completed, BaseModTime: &base},
+		handlers.Mutation{Op: handlers.MutationMerge, ToDo: merged},
+	)
+
+	for i, r := range result.Results {
+		if r.Status != handlers.MutationConflict || r.ToDo == nil {
+			t.Fatalf("Expected mutation %d to conflict, got %v", i, r)
+		}
+	}
+
+	if todos["2"].Completed || todos["3"].Completed {
+		t.Fatal("Expected the ToDos not to be completed")
+	}
+
+	result = postSync(t, handlers.NewToDoHandler(m),
+		handlers.Mutation{Op: handlers.MutationMerge, ToDo: merged, Force: true},
+	)
+
+	if r := result.Results[0]; r.Status != handlers.MutationApplied || !todos["3"].Completed {
+		t.Fatalf("Expected forced merge to complete the ToDo, got %v", r)
+	}
+}
+
+func testCalDAVCompletesBlocked(t *testing.T) {
+
+	m, todos := blockedRepo()
+	todos[putUUID] = internal.ToDo{ID: putUUID, Title: "Hand off on-call", BlockedBy: []string{"1"}}
+
+	body := strings.Replace(fixture(t, "thunderbird-put.ics"), "STATUS:NEEDS-ACTION", "STATUS:COMPLETED", 1)
+	req := calDAVRequest("PUT", "default/"+putUUID+".ics", body, nil)
+
+	serve(t, newCalDAVHandler(m), req, http.StatusConflict)
+
+	if todos[putUUID].Completed {
+		t.Fatal("Expected the ToDo not to be completed")
+	}
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/ical"
+	"github.com/pkg/errors"
+)
+
+// feedTokenBytes is the number of random bytes in a feed token
+const feedTokenBytes = 32
+
+// FeedHandler provides a handle method to handle incoming AWS API Gateway requests for calendar feeds.
+// Tokens can only be created by the members of a list, and stop working when their user is no longer one.
+type FeedHandler struct {
+	tokens  database.FeedTokenRepo
+	todos   database.ToDoRepoProvider
+	members database.MemberRepo
+}
+
+// FeedTokenResponse is the response to creating a feed token
+type FeedTokenResponse struct {
+	internal.FeedToken
+	Path string `json:"path"`
+}
+
+// NewFeedHandler creates a new Feed handler. The members of lists are read from members.
+func NewFeedHandler(tokens database.FeedTokenRepo, todos database.ToDoRepoProvider, members database.MemberRepo) *FeedHandler {
+	return &FeedHandler{
+		tokens:  tokens,
+		todos:   todos,
+		members: members,
+	}
+}
+
+// Handle handles a request from AWS API Gateway and returns a response
+func (h *FeedHandler) Handle(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	switch req.HTTPMethod {
+	case "GET":
+		return h.get(req)
+	case "POST":
+		return h.post(req)
+	case "DELETE":
+		return h.delete(req)
+	default:
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+}
+
+// get returns the feed for the token in the path. The token is the only credential, so unknown tokens,
+// and those of users who are no longer members of the list, are reported as not found.
+func (h *FeedHandler) get(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	token := strings.TrimSuffix(req.PathParameters["token"], ".ics")
+	if token == "" {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	t, err := h.tokens.Get(token)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	if t == nil {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	ok, err := isMember(h.members, t.ListID, t.UserID)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	if !ok {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	repo := h.todos(t.ListID)
+	if repo == nil {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	todos, err := repo.GetAll()
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	var b bytes.Buffer
+
+	if err := ical.WriteCalendar(&b, t.ListID, todos, time.Now()); err != nil {
+		return CreateErrorResponse(ErrInternal)
+	}
+
+	r, err := CreateOKResponse(RawBody{ContentType: ical.ContentType, Body: b.Bytes()})
+	r.Headers["Content-Disposition"] = `inline; filename="todos.ics"`
+	r.Headers["Cache-Control"] = "private, max-age=300"
+
+	return r, err
+}
+
+// post creates a feed token for a list the caller is a member of
+func (h *FeedHandler) post(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	userID := callerID(req)
+	if userID == "" {
+		return CreateErrorResponse(ErrUnauthorized)
+	}
+
+	var body struct {
+		ListID string `json:"listId"`
+	}
+
+	if req.Body != "" {
+		if err := json.Unmarshal([]byte(req.Body), &body); err != nil {
+			return CreateErrorResponse(errors.Wrap(ErrBadRequest, "body is not valid JSON"))
+		}
+	}
+
+	if body.ListID == "" {
+		body.ListID = internal.DefaultListID
+	}
+
+	if !validListID(body.ListID) {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "invalid listId"))
+	}
+
+	ok, err := isMember(h.members, body.ListID, userID)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	if !ok {
+		return CreateErrorResponse(errors.Wrap(ErrForbidden, "only members of a list can create feeds of it"))
+	}
+
+	b := make([]byte, feedTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return CreateErrorResponse(ErrInternal)
+	}
+
+	t := &internal.FeedToken{
+		Token:  base64.RawURLEncoding.EncodeToString(b),
+		UserID: userID,
+		ListID: body.ListID,
+	}
+
+	if err := h.tokens.Save(t); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse(FeedTokenResponse{
+		FeedToken: *t,
+		Path:      fmt.Sprintf("/feeds/%s.ics", t.Token),
+	})
+}
+
+// delete revokes a token. Only the user that created it can revoke it.
+func (h *FeedHandler) delete(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	userID := callerID(req)
+	if userID == "" {
+		return CreateErrorResponse(ErrUnauthorized)
+	}
+
+	token := strings.TrimSuffix(req.PathParameters["token"], ".ics")
+	if token == "" {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "token is required"))
+	}
+
+	t, err := h.tokens.Get(token)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	if t == nil || t.UserID != userID {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	if err := h.tokens.Delete(token); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse("")
+}
@@ -0,0 +1,300 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+const testToken = "c2VjcmV0LWZlZWQtdG9rZW4"
+
+var savedFeedToken = internal.FeedToken{
+	Token:  testToken,
+	UserID: "user-1",
+	ListID: "work",
+}
+
+func TestFeedHandler(t *testing.T) {
+	t.Run("GetFeedOK", testGetFeedOK)
+	t.Run("GetFeedNotFound", testGetFeedNotFound)
+	t.Run("GetFeedNoLongerMember", testGetFeedNoLongerMember)
+	t.Run("CreateFeedTokenOK", testCreateFeedTokenOK)
+	t.Run("CreateFeedTokenUnauthorized", testCreateFeedTokenUnauthorized)
+	t.Run("CreateFeedTokenNotMember", testCreateFeedTokenNotMember)
+	t.Run("DeleteFeedTokenOK", testDeleteFeedTokenOK)
+	t.Run("DeleteFeedTokenOtherUser", testDeleteFeedTokenOtherUser)
+}
+
+func providerFor(t *testing.T, listID string, m *RepoMock) database.ToDoRepoProvider {
+	return func(id string) database.ToDoRepo {
+		if id != listID {
+			t.Fatalf("Expected repository for list %s, got %s", listID, id)
+		}
+		return m
+	}
+}
+
+func authorized(req events.APIGatewayProxyRequest, userID string) events.APIGatewayProxyRequest {
+	req.RequestContext.Authorizer = map[string]interface{}{"principalId": userID}
+	return req
+}
+
+func testGetFeedOK(t *testing.T) {
+
+	tokens := &FeedTokenRepoMock{
+		GetFn: func(token string) (*internal.FeedToken, error) {
+			if token != testToken {
+				t.Fatalf("Expected token %s, got %s", testToken, token)
+			}
+			return &savedFeedToken, nil
+		},
+	}
+
+	todos := &RepoMock{
+		GetAllFn: func() ([]internal.ToDo, error) {
+			return []internal.ToDo{savedToDo}, nil
+		},
+	}
+
+	req := events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"token": testToken + ".ics"},
+		HTTPMethod:     http.MethodGet,
+	}
+
+	resp, err := handlers.NewFeedHandler(tokens, providerFor(t, "work", todos), soleMember()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(resp.Body, "BEGIN:VTODO") || !strings.Contains(resp.Body, "UID:"+testUUID) {
+		t.Fatalf("Expected body to contain VTODO for '%s'", testUUID)
+	}
+
+	if resp.Headers["Content-Type"] != "text/calendar; charset=utf-8" {
+		t.Fatalf("Unexpected Content-Type '%s'", resp.Headers["Content-Type"])
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+}
+
+func testGetFeedNotFound(t *testing.T) {
+
+	tokens := &FeedTokenRepoMock{
+		GetFn: func(string) (*internal.FeedToken, error) {
+			return nil, nil
+		},
+	}
+
+	todos := &RepoMock{}
+
+	req := events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"token": "guess.ics"},
+		HTTPMethod:     http.MethodGet,
+	}
+
+	resp, err := handlers.NewFeedHandler(tokens, providerFor(t, "work", todos), soleMember()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if todos.GetAllInvoked {
+		t.Fatal("GetAll invoked")
+	}
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+}
+
+func testGetFeedNoLongerMember(t *testing.T) {
+
+	tokens := &FeedTokenRepoMock{
+		GetFn: func(string) (*internal.FeedToken, error) {
+			return &internal.FeedToken{Token: testToken, UserID: "user-2", ListID: "work"}, nil
+		},
+	}
+
+	todos := &RepoMock{}
+
+	req := events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"token": testToken + ".ics"},
+		HTTPMethod:     http.MethodGet,
+	}
+
+	// user-2 created the token, but has since been removed from the list
+	resp, err := handlers.NewFeedHandler(tokens, providerFor(t, "work", todos), soleMember()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if todos.GetAllInvoked {
+		t.Fatal("GetAll invoked")
+	}
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+}
+
+func testCreateFeedTokenOK(t *testing.T) {
+
+	var saved *internal.FeedToken
+
+	tokens := &FeedTokenRepoMock{
+		SaveFn: func(token *internal.FeedToken) error {
+			saved = token
+			return nil
+		},
+	}
+
+	req := authorized(events.APIGatewayProxyRequest{
+		Body:       `{"listId": "work"}`,
+		HTTPMethod: http.MethodPost,
+	}, "user-1")
+
+	resp, err := handlers.NewFeedHandler(tokens, providerFor(t, "work", &RepoMock{}), soleMember()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var result handlers.FeedTokenResponse
+	if err := json.Unmarshal([]byte(resp.Body), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Token) < 40 || saved == nil || saved.Token != result.Token {
+		t.Fatalf("Expected a long random token to be saved, got '%s'", result.Token)
+	}
+
+	if saved.UserID != "user-1" || saved.ListID != "work" {
+		t.Fatalf("Unexpected saved token %+v", saved)
+	}
+
+	if result.Path != "/feeds/"+result.Token+".ics" {
+		t.Fatalf("Unexpected path '%s'", result.Path)
+	}
+
+}
+
+func testCreateFeedTokenUnauthorized(t *testing.T) {
+
+	tokens := &FeedTokenRepoMock{}
+
+	req := events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodPost,
+	}
+
+	resp, err := handlers.NewFeedHandler(tokens, nil, soleMember()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tokens.SaveInvoked {
+		t.Fatal("Save invoked")
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+
+}
+
+func testCreateFeedTokenNotMember(t *testing.T) {
+
+	tokens := &FeedTokenRepoMock{}
+
+	for _, listID := range []string{"work", internal.DefaultListID} {
+		req := authorized(events.APIGatewayProxyRequest{
+			Body:       `{"listId": "` + listID + `"}`,
+			HTTPMethod: http.MethodPost,
+		}, "user-2")
+
+		resp, err := handlers.NewFeedHandler(tokens, nil, soleMember()).Handle(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("Expected %d http response code for %s, got %d", http.StatusForbidden, listID, resp.StatusCode)
+		}
+	}
+
+	if tokens.SaveInvoked {
+		t.Fatal("Save invoked")
+	}
+
+}
+
+func testDeleteFeedTokenOK(t *testing.T) {
+
+	tokens := &FeedTokenRepoMock{
+		GetFn: func(string) (*internal.FeedToken, error) {
+			return &savedFeedToken, nil
+		},
+		DeleteFn: func(string) error {
+			return nil
+		},
+	}
+
+	req := authorized(events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"token": testToken},
+		HTTPMethod:     http.MethodDelete,
+	}, "user-1")
+
+	resp, err := handlers.NewFeedHandler(tokens, nil, soleMember()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !tokens.DeleteInvoked {
+		t.Fatal("Delete not invoked")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+}
+
+func testDeleteFeedTokenOtherUser(t *testing.T) {
+
+	tokens := &FeedTokenRepoMock{
+		GetFn: func(string) (*internal.FeedToken, error) {
+			return &savedFeedToken, nil
+		},
+	}
+
+	req := authorized(events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"token": testToken},
+		HTTPMethod:     http.MethodDelete,
+	}, "user-2")
+
+	resp, err := handlers.NewFeedHandler(tokens, nil, soleMember()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tokens.DeleteInvoked {
+		t.Fatal("Delete invoked")
+	}
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+}
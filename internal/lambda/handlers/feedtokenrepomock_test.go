@@ -0,0 +1,33 @@
+package handlers_test
+
+import (
+	"github.com/benjaminbartels/todo/internal"
+)
+
+// FeedTokenRepoMock is used to mock a FeedTokenRepo
+type FeedTokenRepoMock struct {
+	GetFn         func(string) (*internal.FeedToken, error)
+	SaveFn        func(*internal.FeedToken) error
+	DeleteFn      func(string) error
+	GetInvoked    bool
+	SaveInvoked   bool
+	DeleteInvoked bool
+}
+
+// Get returns a FeedToken by its token
+func (m *FeedTokenRepoMock) Get(token string) (*internal.FeedToken, error) {
+	m.GetInvoked = true
+	return m.GetFn(token)
+}
+
+// Save creates or updates a FeedToken
+func (m *FeedTokenRepoMock) Save(token *internal.FeedToken) error {
+	m.SaveInvoked = true
+	return m.SaveFn(token)
+}
+
+// Delete permanently removes a FeedToken
+func (m *FeedTokenRepoMock) Delete(token string) error {
+	m.DeleteInvoked = true
+	return m.DeleteFn(token)
+}
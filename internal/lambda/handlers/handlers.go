@@ -1,20 +1,63 @@
 package handlers
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/crdt"
+	"github.com/benjaminbartels/todo/internal/database"
 	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
 )
 
-// CreateResponse generates an APIGatewayProxyResponse using the provided data and http code
+// retryAfterSeconds is sent in the Retry-After header when the database is throttled or unavailable
+const retryAfterSeconds = 1
+
+// clock timestamps the fields written by requests. Each Lambda container is a separate replica.
+var clock = crdt.NewClock(uuid.NewV4().String())
+
+// writeTime returns a timestamp after every write to the fields of previous, which may be nil, so a write
+// stamped with it wins over them
+func writeTime(previous *internal.ToDo) crdt.Timestamp {
+	if previous != nil {
+		for _, ts := range previous.Clocks {
+			// Stored timestamps were checked for drift when they were written
+			clock.Update(ts)
+		}
+	}
+
+	return clock.Now()
+}
+
+// RawBody is response data that is sent as is, rather than being marshaled to JSON
+type RawBody struct {
+	ContentType string
+	Body        []byte
+}
+
+// CreateResponse generates an APIGatewayProxyResponse using the provided data and http code. Data is
+// marshaled to JSON unless it is a RawBody.
 func CreateResponse(data interface{}, code int) (events.APIGatewayProxyResponse, error) {
 
 	r := events.APIGatewayProxyResponse{
 		StatusCode: code,
 	}
 
+	r.Headers = make(map[string]string)
+	r.Headers["Access-Control-Allow-Origin"] = "*"
+	r.Headers["Access-Control-Allow-Credentials"] = "true"
+
+	if raw, ok := data.(RawBody); ok {
+		r.Headers["Content-Type"] = raw.ContentType
+		r.Body = string(raw.Body)
+		return r, nil
+	}
+
 	// Try to marashal data, if it fail return errorResponse
 	js, err := json.Marshal(data)
 	if err != nil {
@@ -25,9 +68,7 @@ func CreateResponse(data interface{}, code int) (events.APIGatewayProxyResponse,
 		}
 	}
 
-	r.Headers = make(map[string]string)
-	r.Headers["Access-Control-Allow-Origin"] = "*"
-	r.Headers["Access-Control-Allow-Credentials"] = "true"
+	r.Headers["Content-Type"] = "application/json"
 
 	r.Body = string(js)
 
@@ -53,6 +94,20 @@ func CreateErrorResponse(err error) (events.APIGatewayProxyResponse, error) {
 		code = http.StatusMethodNotAllowed
 	case ErrUnauthorized:
 		code = http.StatusUnauthorized
+	case ErrForbidden:
+		code = http.StatusForbidden
+	case ErrConflict:
+		code = http.StatusConflict
+	case ErrPreconditionFailed:
+		code = http.StatusPreconditionFailed
+	case ErrGone:
+		code = http.StatusGone
+	case ErrNotImplemented:
+		code = http.StatusNotImplemented
+	case ErrThrottled:
+		code = http.StatusTooManyRequests
+	case ErrUnavailable:
+		code = http.StatusServiceUnavailable
 	default:
 		code = http.StatusInternalServerError
 	}
@@ -61,7 +116,13 @@ func CreateErrorResponse(err error) (events.APIGatewayProxyResponse, error) {
 		Err: err.Error(),
 	}
 
-	return CreateResponse(e, code)
+	r, rerr := CreateResponse(e, code)
+
+	if code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable {
+		r.Headers["Retry-After"] = strconv.Itoa(retryAfterSeconds)
+	}
+
+	return r, rerr
 
 }
 
@@ -76,8 +137,89 @@ var (
 	ErrMethodNotAllowed = errors.New("method not allowed")
 	// ErrUnauthorized is returned when the request is not authorized
 	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned when the caller is not allowed to make the request, such as editing another
+	// user's comment
+	ErrForbidden = errors.New("forbidden")
+	// ErrConflict is returned when the request conflicts with the state of a resource, such as completing a
+	// ToDo that is blocked
+	ErrConflict = errors.New("conflict")
+	// ErrPreconditionFailed is returned when a conditional request does not match the current version
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrGone is returned when a resource, such as an expired sync token, is no longer available
+	ErrGone = errors.New("gone")
+	// ErrNotImplemented is returned when the repository does not support the request
+	ErrNotImplemented = errors.New("not implemented")
+	// ErrThrottled is returned when too many requests are being made and the client should retry later
+	ErrThrottled = errors.New("too many requests")
+	// ErrUnavailable is returned when a dependency is temporarily unavailable and the client should retry later
+	ErrUnavailable = errors.New("service unavailable")
 )
 
+// callerID returns the ID of the authenticated user making the request, as set by the API Gateway
+// authorizer, or an empty string if the request is not authenticated
+func callerID(req events.APIGatewayProxyRequest) string {
+	return authorizerID(req.RequestContext.Authorizer)
+}
+
+// authorizerID returns the ID of the user in the context set by an API Gateway authorizer
+func authorizerID(authorizer map[string]interface{}) string {
+	if id, ok := authorizer["principalId"].(string); ok && id != "" {
+		return id
+	}
+
+	if claims, ok := authorizer["claims"].(map[string]interface{}); ok {
+		if sub, ok := claims["sub"].(string); ok {
+			return sub
+		}
+	}
+
+	return ""
+}
+
+// header returns the value of a request header. API Gateway passes headers with the case the client sent,
+// so the name is matched case insensitively.
+func header(req events.APIGatewayProxyRequest, name string) string {
+	for k, v := range req.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// requestBody returns the body of a request, decoding it if API Gateway base64 encoded it
+func requestBody(req events.APIGatewayProxyRequest) ([]byte, error) {
+	if !req.IsBase64Encoded {
+		return []byte(req.Body), nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(req.Body)
+	if err != nil {
+		return nil, errors.Wrap(ErrBadRequest, "body is not valid base64")
+	}
+
+	return b, nil
+}
+
+// validListID reports whether id can be used as a list ID. IDs starting with an underscore are reserved
+// for items that are not ToDos.
+func validListID(id string) bool {
+	return id != "" && !strings.HasPrefix(id, "_")
+}
+
+// repoError returns the error to respond with when a repository call fails. Throttling and transient
+// database errors are passed through so the client knows to retry, anything else is an internal error.
+func repoError(err error) error {
+	switch database.Retryable(err) {
+	case database.ErrThrottled:
+		return ErrThrottled
+	case database.ErrUnavailable:
+		return ErrUnavailable
+	default:
+		return ErrInternal
+	}
+}
+
 // errorResponse is the response sent to the client in the event of a error
 type errorResponse struct {
 	Err string `json:"error,omitempty"`
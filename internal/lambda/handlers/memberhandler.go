@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/pkg/errors"
+)
+
+// MemberHandler provides a handle method to handle incoming AWS API Gateway requests for the members of
+// lists. Anyone can claim a list that has no members by adding themselves as its first member; after that
+// only its members can change it. The default list holds the ToDos of every user from before there were
+// lists, so only its owner can claim it.
+type MemberHandler struct {
+	members      database.MemberRepo
+	defaultOwner string
+}
+
+// NewMemberHandler creates a new Member handler. defaultOwner is the ID of the user who can claim the
+// default list, which no one can if it is empty.
+func NewMemberHandler(members database.MemberRepo, defaultOwner string) *MemberHandler {
+	return &MemberHandler{
+		members:      members,
+		defaultOwner: defaultOwner,
+	}
+}
+
+// Handle handles a request from AWS API Gateway and returns a response
+func (h *MemberHandler) Handle(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	userID := callerID(req)
+	if userID == "" {
+		return CreateErrorResponse(ErrUnauthorized)
+	}
+
+	listID := req.PathParameters["listId"]
+	if !validListID(listID) {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "invalid listId"))
+	}
+
+	members, err := h.members.GetByList(listID)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	if len(members) > 0 && member(members, userID) < 0 {
+		return CreateErrorResponse(errors.Wrap(ErrForbidden, "only members can see and change the members of a list"))
+	}
+
+	switch req.HTTPMethod {
+	case "GET":
+		if members == nil {
+			members = []internal.Member{}
+		}
+		return CreateOKResponse(members)
+	case "PUT":
+		if len(members) == 0 {
+			if err := h.claim(req, listID, userID); err != nil {
+				return CreateErrorResponse(err)
+			}
+		}
+		return h.put(req, listID, members)
+	case "DELETE":
+		return h.delete(req, listID, members)
+	default:
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+}
+
+// claim checks that the caller, userID, can become the first member of a list
+func (h *MemberHandler) claim(req events.APIGatewayProxyRequest, listID, userID string) error {
+	if req.PathParameters["userId"] != userID {
+		return errors.Wrap(ErrForbidden, "the first member of a list must be the caller")
+	}
+
+	if listID == internal.DefaultListID && userID != h.defaultOwner {
+		return errors.Wrap(ErrForbidden, "only the owner of the default list can claim it")
+	}
+
+	return nil
+}
+
+// put adds a member to a list or changes their handle. Handles are unique within a list.
+func (h *MemberHandler) put(req events.APIGatewayProxyRequest, listID string, members []internal.Member) (events.APIGatewayProxyResponse, error) {
+
+	userID := req.PathParameters["userId"]
+	if userID == "" {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "userId is required"))
+	}
+
+	var body struct {
+		Handle string `json:"handle"`
+	}
+
+	if err := json.Unmarshal([]byte(req.Body), &body); err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "body is not valid JSON"))
+	}
+
+	handle := internal.NormalizeHandle(body.Handle)
+	if !internal.ValidHandle(handle) {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest,
+			"handle must be 1 to 32 letters, digits, dots, dashes and underscores and not end with a dot"))
+	}
+
+	m := internal.Member{ListID: listID, UserID: userID, Handle: handle, Added: time.Now().UTC()}
+
+	for _, other := range members {
+		if other.UserID == userID {
+			m.Added = other.Added
+		} else if other.Handle == handle {
+			return CreateErrorResponse(errors.Wrapf(ErrBadRequest, "handle %s is already taken", handle))
+		}
+	}
+
+	if err := h.members.Save(&m); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse(m)
+}
+
+// delete removes a member from a list
+func (h *MemberHandler) delete(req events.APIGatewayProxyRequest, listID string, members []internal.Member) (events.APIGatewayProxyResponse, error) {
+
+	userID := req.PathParameters["userId"]
+	if member(members, userID) < 0 {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	if err := h.members.Delete(listID, userID); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse("")
+}
+
+// member returns the index of the member with userID, or -1 if there is none
+func member(members []internal.Member, userID string) int {
+	for i, m := range members {
+		if m.UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+// isMember reports whether userID is a member of a list
+func isMember(members database.MemberRepo, listID, userID string) (bool, error) {
+	m, err := members.GetByList(listID)
+	if err != nil {
+		return false, err
+	}
+	return member(m, userID) >= 0, nil
+}
@@ -0,0 +1,216 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+func TestMemberHandler(t *testing.T) {
+	t.Run("PutFirstMemberOK", testPutFirstMemberOK)
+	t.Run("PutFirstMemberNotCaller", testPutFirstMemberNotCaller)
+	t.Run("PutFirstMemberNotDefaultOwner", testPutFirstMemberNotDefaultOwner)
+	t.Run("PutMemberNotMember", testPutMemberNotMember)
+	t.Run("PutMemberHandleTaken", testPutMemberHandleTaken)
+	t.Run("PutMemberInvalidHandle", testPutMemberInvalidHandle)
+	t.Run("DeleteMemberOK", testDeleteMemberOK)
+	t.Run("DeleteMemberNotFound", testDeleteMemberNotFound)
+}
+
+// memoryMembers returns a MemberRepoMock that keeps the members of one list in a slice
+func memoryMembers(members ...internal.Member) (*MemberRepoMock, *[]internal.Member) {
+	all := append([]internal.Member(nil), members...)
+
+	return &MemberRepoMock{
+		GetByListFn: func(string) ([]internal.Member, error) {
+			return append([]internal.Member(nil), all...), nil
+		},
+		SaveFn: func(m *internal.Member) error {
+			for i := range all {
+				if all[i].UserID == m.UserID {
+					all[i] = *m
+					return nil
+				}
+			}
+			all = append(all, *m)
+			return nil
+		},
+		DeleteFn: func(listID, userID string) error {
+			for i := range all {
+				if all[i].UserID == userID {
+					all = append(all[:i], all[i+1:]...)
+					break
+				}
+			}
+			return nil
+		},
+	}, &all
+}
+
+func memberRequest(method, userID, body, callerID string) events.APIGatewayProxyRequest {
+	return authorized(events.APIGatewayProxyRequest{
+		Resource:       "/lists/{listId}/members/{userId}",
+		HTTPMethod:     method,
+		PathParameters: map[string]string{"listId": internal.DefaultListID, "userId": userID},
+		Body:           body,
+	}, callerID)
+}
+
+func testPutFirstMemberOK(t *testing.T) {
+
+	repo, all := memoryMembers()
+
+	resp, err := handlers.NewMemberHandler(repo, "user-1").
+		Handle(memberRequest(http.MethodPut, "user-1", `{"handle":"Ann"}`, "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	if len(*all) != 1 || (*all)[0].Handle != "ann" || (*all)[0].ListID != internal.DefaultListID {
+		t.Fatalf("Unexpected members %+v", *all)
+	}
+}
+
+func testPutFirstMemberNotCaller(t *testing.T) {
+
+	repo, _ := memoryMembers()
+
+	// The owner of the default list can only claim it for themselves
+	resp, err := handlers.NewMemberHandler(repo, "user-1").
+		Handle(memberRequest(http.MethodPut, "user-2", `{"handle":"bob"}`, "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	if repo.SaveInvoked {
+		t.Fatal("Expected Member not to be saved")
+	}
+}
+
+func testPutFirstMemberNotDefaultOwner(t *testing.T) {
+
+	for _, owner := range []string{"user-1", ""} {
+		repo, _ := memoryMembers()
+
+		resp, err := handlers.NewMemberHandler(repo, owner).
+			Handle(memberRequest(http.MethodPut, "user-2", `{"handle":"bob"}`, "user-2"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("Expected %d with owner %q, got %d", http.StatusForbidden, owner, resp.StatusCode)
+		}
+	}
+
+	// Other lists are claimed by whoever adds themselves first
+	repo, all := memoryMembers()
+
+	req := memberRequest(http.MethodPut, "user-2", `{"handle":"bob"}`, "user-2")
+	req.PathParameters["listId"] = "home"
+
+	resp, err := handlers.NewMemberHandler(repo, "user-1").Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || len(*all) != 1 {
+		t.Fatalf("Expected user-2 to claim the list, got %d: %s", resp.StatusCode, resp.Body)
+	}
+}
+
+func testPutMemberNotMember(t *testing.T) {
+
+	repo, _ := memoryMembers(listMembers...)
+
+	resp, err := handlers.NewMemberHandler(repo, "user-1").
+		Handle(memberRequest(http.MethodPut, "user-3", `{"handle":"carol"}`, "user-3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+}
+
+func testPutMemberHandleTaken(t *testing.T) {
+
+	repo, _ := memoryMembers(listMembers...)
+
+	resp, err := handlers.NewMemberHandler(repo, "user-1").
+		Handle(memberRequest(http.MethodPut, "user-3", `{"handle":"BOB"}`, "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	if repo.SaveInvoked {
+		t.Fatal("Expected Member not to be saved")
+	}
+}
+
+func testPutMemberInvalidHandle(t *testing.T) {
+
+	repo, _ := memoryMembers(listMembers...)
+
+	for _, body := range []string{`{"handle":""}`, `{"handle":"a b"}`, `{"handle":"ann."}`} {
+		resp, err := handlers.NewMemberHandler(repo, "user-1").
+			Handle(memberRequest(http.MethodPut, "user-3", body, "user-1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expected %d for %s, got %d", http.StatusBadRequest, body, resp.StatusCode)
+		}
+	}
+}
+
+func testDeleteMemberOK(t *testing.T) {
+
+	repo, all := memoryMembers(listMembers...)
+
+	resp, err := handlers.NewMemberHandler(repo, "user-1").
+		Handle(memberRequest(http.MethodDelete, "user-2", "", "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	if len(*all) != 1 || (*all)[0].UserID != "user-1" {
+		t.Fatalf("Unexpected members %+v", *all)
+	}
+}
+
+func testDeleteMemberNotFound(t *testing.T) {
+
+	repo, _ := memoryMembers(listMembers...)
+
+	resp, err := handlers.NewMemberHandler(repo, "user-1").
+		Handle(memberRequest(http.MethodDelete, "user-3", "", "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
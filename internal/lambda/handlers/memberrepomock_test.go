@@ -0,0 +1,33 @@
+package handlers_test
+
+import (
+	"github.com/benjaminbartels/todo/internal"
+)
+
+// MemberRepoMock is used to mock a MemberRepo
+type MemberRepoMock struct {
+	GetByListFn      func(string) ([]internal.Member, error)
+	SaveFn           func(*internal.Member) error
+	DeleteFn         func(string, string) error
+	GetByListInvoked bool
+	SaveInvoked      bool
+	DeleteInvoked    bool
+}
+
+// GetByList returns the members of a list
+func (m *MemberRepoMock) GetByList(listID string) ([]internal.Member, error) {
+	m.GetByListInvoked = true
+	return m.GetByListFn(listID)
+}
+
+// Save creates or updates a Member
+func (m *MemberRepoMock) Save(member *internal.Member) error {
+	m.SaveInvoked = true
+	return m.SaveFn(member)
+}
+
+// Delete removes a Member
+func (m *MemberRepoMock) Delete(listID, userID string) error {
+	m.DeleteInvoked = true
+	return m.DeleteFn(listID, userID)
+}
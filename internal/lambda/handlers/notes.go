@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/markdown"
+	"github.com/pkg/errors"
+)
+
+// maxNotesLength is the longest notes of a ToDo, in bytes
+const maxNotesLength = 64 * 1024
+
+// renderedToDo is a ToDo with its notes rendered as HTML, in the notesHtml field
+type renderedToDo struct {
+	todo internal.ToDo
+}
+
+// MarshalJSON marshals the ToDo with notesHtml added after its other fields
+func (t renderedToDo) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(t.todo)
+	if err != nil || t.todo.Notes == "" {
+		return b, err
+	}
+
+	h, err := json.Marshal(markdown.HTML(t.todo.Notes))
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b[:len(b)-1], `,"notesHtml":`...)
+	b = append(b, h...)
+
+	return append(b, '}'), nil
+}
+
+// prepareNotes checks the notes of a ToDo that is about to be saved
+func prepareNotes(todo *internal.ToDo) error {
+	if len(todo.Notes) > maxNotesLength {
+		return errors.Wrapf(ErrBadRequest, "notes must be at most %d bytes", maxNotesLength)
+	}
+	return nil
+}
+
+// respondToDo responds with todo, with its notes rendered as HTML if the request has render=html
+func respondToDo(req events.APIGatewayProxyRequest, todo *internal.ToDo) (events.APIGatewayProxyResponse, error) {
+
+	html, err := renderHTML(req)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	if html {
+		return CreateOKResponse(renderedToDo{*todo})
+	}
+
+	return CreateOKResponse(todo)
+}
+
+// respondToDos responds with the todos that match the words in the q query string parameter, if it is
+// given, with their notes rendered as HTML if the request has render=html
+func respondToDos(req events.APIGatewayProxyRequest, todos []internal.ToDo) (events.APIGatewayProxyResponse, error) {
+
+	html, err := renderHTML(req)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	if q, ok := req.QueryStringParameters["q"]; ok {
+		matched := []internal.ToDo{}
+		for i := range todos {
+			if todos[i].Matches(q) {
+				matched = append(matched, todos[i])
+			}
+		}
+		todos = matched
+	}
+
+	if html {
+		rendered := make([]renderedToDo, len(todos))
+		for i := range todos {
+			rendered[i] = renderedToDo{todos[i]}
+		}
+		return CreateOKResponse(rendered)
+	}
+
+	return CreateOKResponse(todos)
+}
+
+// renderHTML reports whether the request asks for notes to be rendered as HTML
+func renderHTML(req events.APIGatewayProxyRequest) (bool, error) {
+	switch req.QueryStringParameters["render"] {
+	case "":
+		return false, nil
+	case "html":
+		return true, nil
+	default:
+		return false, errors.Wrap(ErrBadRequest, "render must be html")
+	}
+}
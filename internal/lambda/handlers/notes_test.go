@@ -0,0 +1,182 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+func TestNotes(t *testing.T) {
+	t.Run("RenderNotes", testRenderNotes)
+	t.Run("RenderNotesList", testRenderNotesList)
+	t.Run("RenderNotesBadRequest", testRenderNotesBadRequest)
+	t.Run("SearchNotes", testSearchNotes)
+	t.Run("CreateToDoWithLargeNotes", testCreateToDoWithLargeNotes)
+	t.Run("SyncToDoWithLargeNotes", testSyncToDoWithLargeNotes)
+}
+
+// notesRepo returns a RepoMock holding ToDos with notes
+func notesRepo() (*RepoMock, map[string]internal.ToDo) {
+	return memoryRepo(
+		internal.ToDo{ID: testUUID, Title: "Rotate certs",
+			Notes: "Follow the [runbook](https://wiki.example.com/tls).\n\n<script>alert(1)</script>"},
+		internal.ToDo{ID: "2", Title: "Patch hosts", Notes: "Drain each node first"},
+		internal.ToDo{ID: "3", Title: "Buy milk"},
+	)
+}
+
+func testRenderNotes(t *testing.T) {
+
+	m, _ := notesRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:              "/todos/{id}",
+		HTTPMethod:            http.MethodGet,
+		PathParameters:        map[string]string{"id": testUUID},
+		QueryStringParameters: map[string]string{"render": "html"},
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body struct {
+		internal.ToDo
+		NotesHTML string `json:"notesHtml"`
+	}
+
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<p>Follow the <a href="https://wiki.example.com/tls" rel="nofollow noopener noreferrer">runbook</a>.</p>
+<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>
+`
+
+	if body.NotesHTML != want {
+		t.Fatalf("Expected notes rendered as:\n%s\ngot:\n%s", want, body.NotesHTML)
+	}
+
+	if !strings.HasPrefix(body.Notes, "Follow the [runbook]") || body.ID != testUUID {
+		t.Fatalf("Expected the ToDo with its Markdown notes, got %+v", body.ToDo)
+	}
+}
+
+func testRenderNotesList(t *testing.T) {
+
+	m, _ := notesRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:              "/todos",
+		HTTPMethod:            http.MethodGet,
+		QueryStringParameters: map[string]string{"render": "html"},
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var todos []map[string]interface{}
+	if err := json.Unmarshal([]byte(resp.Body), &todos); err != nil {
+		t.Fatal(err)
+	}
+
+	rendered := 0
+	for _, todo := range todos {
+		if _, ok := todo["notesHtml"]; ok {
+			rendered++
+		}
+	}
+
+	if len(todos) != 3 || rendered != 2 {
+		t.Fatalf("Expected the notes of 2 of 3 ToDos to be rendered, got %s", resp.Body)
+	}
+}
+
+func testRenderNotesBadRequest(t *testing.T) {
+
+	m, _ := notesRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:              "/todos",
+		HTTPMethod:            http.MethodGet,
+		QueryStringParameters: map[string]string{"render": "pdf"},
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", resp.StatusCode)
+	}
+}
+
+func testSearchNotes(t *testing.T) {
+
+	m, _ := notesRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:              "/todos",
+		HTTPMethod:            http.MethodGet,
+		QueryStringParameters: map[string]string{"q": "drain NODE"},
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(t, resp.Body); got != "2" {
+		t.Fatalf("Expected ToDo 2, got %s", got)
+	}
+}
+
+func testCreateToDoWithLargeNotes(t *testing.T) {
+
+	m, _ := memoryRepo()
+
+	b, _ := json.Marshal(internal.ToDo{Title: "Large", Notes: strings.Repeat("a", 64*1024+1)})
+
+	req := events.APIGatewayProxyRequest{Resource: "/todos", HTTPMethod: http.MethodPost, Body: string(b)}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest || m.SaveInvoked {
+		t.Fatalf("Expected status 400, got %d", resp.StatusCode)
+	}
+}
+
+func testSyncToDoWithLargeNotes(t *testing.T) {
+
+	m, _ := memoryRepo()
+
+	b, _ := json.Marshal(handlers.SyncRequest{
+		Mutations: []handlers.Mutation{{
+			Op:   handlers.MutationCreate,
+			ToDo: internal.ToDo{ID: testUUID, Title: "Large", Notes: strings.Repeat("a", 64*1024+1)},
+		}},
+	})
+
+	req := events.APIGatewayProxyRequest{Resource: "/todos/sync", HTTPMethod: http.MethodPost, Body: string(b)}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(resp.Body, `"status":"rejected"`) || m.SaveInvoked {
+		t.Fatalf("Expected the mutation to be rejected, got %s", resp.Body)
+	}
+}
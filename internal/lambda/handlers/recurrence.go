@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/rrule"
+	"github.com/pkg/errors"
+)
+
+// prepareRecurrence checks the recurrence rule of a ToDo that is about to be saved, and keeps the series
+// it is part of unless its rule changed. previous is nil when the ToDo is being created. A ToDo whose rule
+// changed starts a new series from its due date.
+func prepareRecurrence(previous, todo *internal.ToDo) error {
+	if todo.RRule == "" {
+		return nil
+	}
+
+	if _, err := rrule.Parse(todo.RRule); err != nil {
+		return errors.Wrapf(ErrBadRequest, "invalid rrule: %v", err)
+	}
+
+	if _, err := todo.Location(); err != nil {
+		return errors.Wrapf(ErrBadRequest, "invalid timeZone %s", todo.TimeZone)
+	}
+
+	if todo.Due == nil {
+		return errors.Wrap(ErrBadRequest, "due is required for recurring ToDos")
+	}
+
+	if previous != nil && previous.RRule == todo.RRule && previous.TimeZone == todo.TimeZone {
+		todo.SeriesID, todo.SeriesStart = previous.SeriesID, previous.SeriesStart
+	} else {
+		todo.SeriesID, todo.SeriesStart = "", nil
+	}
+
+	if todo.SeriesStart == nil {
+		start := *todo.Due
+		todo.SeriesStart = &start
+	}
+
+	return nil
+}
+
+// recur creates the next occurrence of a recurring ToDo that was just completed. previous is the ToDo
+// before it was saved, or nil if it was created. Failures are only logged, as the occurrence is also
+// created by the RecurrenceHandler.
+func recur(repo database.ToDoRepo, previous, todo *internal.ToDo) {
+	if !todo.Completed || previous != nil && previous.Completed {
+		return
+	}
+
+	next, err := todo.NextOccurrence()
+	if err == nil && next != nil {
+		_, err = saveOccurrence(repo, next)
+	}
+
+	if err != nil {
+		log.Printf("Could not create next occurrence of ToDo %s: %v", todo.ID, err)
+	}
+}
+
+// saveOccurrence saves an occurrence of a recurring ToDo if it does not already exist, and returns the
+// occurrence that is stored. An occurrence that was deleted is not created again, as its ID is the same;
+// it is returned unsaved so the occurrences that follow it are still created.
+func saveOccurrence(repo database.ToDoRepo, occurrence *internal.ToDo) (*internal.ToDo, error) {
+	existing, err := repo.Get(occurrence.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		return existing, nil
+	}
+
+	if d, ok := repo.(database.ToDoTombstoneRepo); ok {
+		deleted, err := d.IsDeleted(occurrence.ID)
+		if err != nil && errors.Cause(err) != database.ErrNotSupported {
+			return nil, err
+		}
+
+		if deleted {
+			return occurrence, nil
+		}
+	}
+
+	occurrence.Stamp(nil, writeTime(nil))
+
+	if err := repo.Save(occurrence); err != nil {
+		return nil, err
+	}
+
+	return occurrence, nil
+}
@@ -0,0 +1,181 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+func TestRecurrence(t *testing.T) {
+	t.Run("CreateRecurringToDo", testCreateRecurringToDo)
+	t.Run("CreateRecurringToDoBadRequest", testCreateRecurringToDoBadRequest)
+	t.Run("CompleteCreatesNextOccurrence", testCompleteCreatesNextOccurrence)
+	t.Run("CompleteTwiceCreatesOneOccurrence", testCompleteTwiceCreatesOneOccurrence)
+	t.Run("ChangeRuleStartsNewSeries", testChangeRuleStartsNewSeries)
+}
+
+// handoffToDo returns a ToDo due every Monday at 9am in New York, stamped as if it had been saved
+func handoffToDo() *internal.ToDo {
+	due := time.Date(2019, 3, 4, 14, 0, 0, 0, time.UTC)
+
+	todo := &internal.ToDo{
+		ID:          testUUID,
+		Title:       "On-call handoff",
+		Due:         &due,
+		RRule:       "FREQ=WEEKLY;BYDAY=MO",
+		TimeZone:    "America/New_York",
+		SeriesStart: &due,
+	}
+	todo.Stamp(nil, todo.Clocks["title"])
+
+	return todo
+}
+
+// putToDo returns a request to update todo
+func putToDo(todo *internal.ToDo) events.APIGatewayProxyRequest {
+	b, _ := json.Marshal(todo)
+
+	return events.APIGatewayProxyRequest{
+		Resource:       "/todos/{id}",
+		HTTPMethod:     http.MethodPut,
+		PathParameters: map[string]string{"id": todo.ID},
+		Body:           string(b),
+	}
+}
+
+func testCreateRecurringToDo(t *testing.T) {
+
+	m, todos := memoryRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:   "/todos",
+		HTTPMethod: http.MethodPost,
+		Body:       `{"title":"Rotate certs","due":"2019-07-01T09:00:00Z","rrule":"FREQ=MONTHLY;BYMONTHDAY=1"}`,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	todo, ok := todos[""]
+	if !ok || todo.SeriesStart == nil || !todo.SeriesStart.Equal(*todo.Due) {
+		t.Fatalf("Expected the series to start at the due date, got %v", todo)
+	}
+
+	if len(todos) != 1 {
+		t.Fatalf("Expected no occurrence to be created, got %d ToDos", len(todos))
+	}
+}
+
+func testCreateRecurringToDoBadRequest(t *testing.T) {
+
+	bodies := []string{
+		`{"title":"No due date","rrule":"FREQ=DAILY"}`,
+		`{"title":"Bad rule","due":"2019-07-01T09:00:00Z","rrule":"FREQ=SOMETIMES"}`,
+		`{"title":"Bad time zone","due":"2019-07-01T09:00:00Z","rrule":"FREQ=DAILY","timeZone":"Mars/Olympus"}`,
+	}
+
+	for _, body := range bodies {
+		m, _ := memoryRepo()
+
+		req := events.APIGatewayProxyRequest{Resource: "/todos", HTTPMethod: http.MethodPost, Body: body}
+
+		resp, err := handlers.NewToDoHandler(m).Handle(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusBadRequest || m.SaveInvoked {
+			t.Fatalf("Expected status 400 for %s, got %d", body, resp.StatusCode)
+		}
+	}
+}
+
+func testCompleteCreatesNextOccurrence(t *testing.T) {
+
+	todo := handoffToDo()
+	m, todos := memoryRepo(*todo)
+
+	completed := *todo
+	completed.Completed = true
+
+	resp, err := handlers.NewToDoHandler(m).Handle(putToDo(&completed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	due := time.Date(2019, 3, 11, 13, 0, 0, 0, time.UTC)
+
+	next, ok := todos[internal.OccurrenceID(testUUID, due)]
+	if !ok {
+		t.Fatalf("Expected an occurrence due %v, got %v", due, todos)
+	}
+
+	if next.Completed || next.SeriesID != testUUID || next.Title != todo.Title || len(next.Clocks) == 0 {
+		t.Fatalf("Expected an open occurrence of the series, got %+v", next)
+	}
+}
+
+func testCompleteTwiceCreatesOneOccurrence(t *testing.T) {
+
+	todo := handoffToDo()
+	m, todos := memoryRepo(*todo)
+	h := handlers.NewToDoHandler(m)
+
+	completed := *todo
+	completed.Completed = true
+
+	if _, err := h.Handle(putToDo(&completed)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen and complete again
+	reopened := todos[testUUID]
+	reopened.Completed = false
+
+	if _, err := h.Handle(putToDo(&reopened)); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened.Completed = true
+
+	if _, err := h.Handle(putToDo(&reopened)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(todos) != 2 {
+		t.Fatalf("Expected a single occurrence to be created, got %d ToDos", len(todos))
+	}
+}
+
+func testChangeRuleStartsNewSeries(t *testing.T) {
+
+	todo := handoffToDo()
+	todo.SeriesID = "series"
+	m, todos := memoryRepo(*todo)
+
+	changed := *todo
+	changed.RRule = "FREQ=WEEKLY;BYDAY=TU"
+
+	if _, err := handlers.NewToDoHandler(m).Handle(putToDo(&changed)); err != nil {
+		t.Fatal(err)
+	}
+
+	if saved := todos[testUUID]; saved.SeriesID != "" || !saved.SeriesStart.Equal(*saved.Due) {
+		t.Fatalf("Expected a new series, got %+v", saved)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/pkg/errors"
+)
+
+// DefaultHorizon is how far ahead the RecurrenceHandler creates occurrences of recurring ToDos
+const DefaultHorizon = 7 * 24 * time.Hour
+
+// RecurrenceHandler runs on a schedule and creates the occurrences of recurring ToDos that are due within
+// its horizon, so they show up before the previous occurrence is completed
+type RecurrenceHandler struct {
+	finder  database.RecurringToDoFinder
+	todos   database.ToDoRepoProvider
+	horizon time.Duration
+	now     func() time.Time
+}
+
+// NewRecurrenceHandler creates a new recurrence handler that finds recurring ToDos with finder and saves
+// their occurrences to the repository of their list. Occurrences due up to horizon from now are created.
+func NewRecurrenceHandler(finder database.RecurringToDoFinder, todos database.ToDoRepoProvider,
+	horizon time.Duration) *RecurrenceHandler {
+	return &RecurrenceHandler{
+		finder:  finder,
+		todos:   todos,
+		horizon: horizon,
+		now:     time.Now,
+	}
+}
+
+// Handle handles a scheduled event. Occurrences that already exist are skipped, so it is safe to run
+// again after a failure, and so are those that were deleted.
+func (h *RecurrenceHandler) Handle(e events.CloudWatchEvent) error {
+
+	recurring, err := h.finder.GetRecurring()
+	if err != nil {
+		return errors.Wrap(err, "Could not get recurring ToDos")
+	}
+
+	until := h.now().Add(h.horizon)
+
+	for _, t := range latestOccurrences(recurring) {
+		repo := h.todos(t.ListID)
+		if repo == nil {
+			continue
+		}
+
+		for {
+			next, err := t.NextOccurrence()
+			if err != nil {
+				return err
+			}
+
+			if next == nil || next.Due.After(until) {
+				break
+			}
+
+			if t, err = saveOccurrence(repo, next); err != nil {
+				return errors.Wrapf(err, "Could not create occurrence %s", next.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// latestOccurrences returns the occurrence of each series that is due last, as the next occurrence of a
+// series follows it
+func latestOccurrences(todos []internal.ToDo) []*internal.ToDo {
+	type series struct{ listID, id string }
+
+	latest := make(map[series]*internal.ToDo)
+	order := []series{}
+
+	for i := range todos {
+		t := &todos[i]
+		if t.Due == nil {
+			continue
+		}
+
+		if t.ListID == "" {
+			t.ListID = internal.DefaultListID
+		}
+
+		s := series{listID: t.ListID, id: t.SeriesID}
+		if s.id == "" {
+			s.id = t.ID
+		}
+
+		l, ok := latest[s]
+		if !ok {
+			order = append(order, s)
+		}
+
+		if !ok || t.Due.After(*l.Due) {
+			latest[s] = t
+		}
+	}
+
+	result := make([]*internal.ToDo, 0, len(order))
+	for _, s := range order {
+		result = append(result, latest[s])
+	}
+
+	return result
+}
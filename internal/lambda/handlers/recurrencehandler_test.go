@@ -0,0 +1,165 @@
+package handlers_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+func TestRecurrenceHandler(t *testing.T) {
+	t.Run("MaterializeOccurrences", testMaterializeOccurrences)
+	t.Run("MaterializeFromLatestOccurrence", testMaterializeFromLatestOccurrence)
+	t.Run("MaterializeSkipsDeleted", testMaterializeSkipsDeleted)
+	t.Run("MaterializeError", testMaterializeError)
+}
+
+// FinderMock is used to mock a repository that finds recurring ToDos
+type FinderMock struct {
+	GetRecurringFn      func() ([]internal.ToDo, error)
+	GetRecurringInvoked bool
+}
+
+// GetRecurring returns the recurring ToDos of every list
+func (m *FinderMock) GetRecurring() ([]internal.ToDo, error) {
+	m.GetRecurringInvoked = true
+	return m.GetRecurringFn()
+}
+
+// recurring returns a FinderMock that finds the recurring ToDos in todos
+func recurring(todos map[string]internal.ToDo) *FinderMock {
+	return &FinderMock{
+		GetRecurringFn: func() ([]internal.ToDo, error) {
+			t := []internal.ToDo{}
+			for _, todo := range todos {
+				if todo.RRule != "" {
+					t = append(t, todo)
+				}
+			}
+			return t, nil
+		},
+	}
+}
+
+func testMaterializeOccurrences(t *testing.T) {
+
+	due := time.Now().UTC().Truncate(time.Hour)
+	m, todos := memoryRepo(internal.ToDo{ID: testUUID, ListID: "ops", Title: "Backup", Due: &due, RRule: "FREQ=DAILY"})
+
+	var listID string
+	provider := func(l string) database.ToDoRepo {
+		listID = l
+		return m
+	}
+
+	h := handlers.NewRecurrenceHandler(recurring(todos), provider, 3*24*time.Hour)
+
+	if err := h.Handle(events.CloudWatchEvent{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if listID != "ops" {
+		t.Fatalf("Expected occurrences to be saved to list ops, got %s", listID)
+	}
+
+	if len(todos) != 4 {
+		t.Fatalf("Expected 3 occurrences to be created, got %d ToDos", len(todos)-1)
+	}
+
+	// Running again creates nothing new
+	if err := h.Handle(events.CloudWatchEvent{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(todos) != 4 {
+		t.Fatalf("Expected no more occurrences to be created, got %d ToDos", len(todos)-1)
+	}
+}
+
+func testMaterializeFromLatestOccurrence(t *testing.T) {
+
+	first := time.Now().UTC().Truncate(time.Hour).Add(-14 * 24 * time.Hour)
+	second := first.Add(7 * 24 * time.Hour)
+
+	m, todos := memoryRepo(
+		internal.ToDo{ID: "first", Title: "Handoff", Due: &first, RRule: "FREQ=WEEKLY", Completed: true},
+		internal.ToDo{ID: "second", Title: "Handoff", Due: &second, RRule: "FREQ=WEEKLY", SeriesID: "first",
+			SeriesStart: &first})
+
+	provider := func(string) database.ToDoRepo {
+		return m
+	}
+
+	h := handlers.NewRecurrenceHandler(recurring(todos), provider, handlers.DefaultHorizon)
+
+	if err := h.Handle(events.CloudWatchEvent{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The occurrences due now and in a week follow the second
+	for _, due := range []time.Time{second.Add(7 * 24 * time.Hour), second.Add(14 * 24 * time.Hour)} {
+		if _, ok := todos[internal.OccurrenceID("first", due)]; !ok {
+			t.Fatalf("Expected an occurrence due %v, got %v", due, todos)
+		}
+	}
+
+	if len(todos) != 4 {
+		t.Fatalf("Expected 2 occurrences to be created, got %d ToDos", len(todos)-2)
+	}
+}
+
+func testMaterializeSkipsDeleted(t *testing.T) {
+
+	due := time.Now().UTC().Truncate(time.Hour)
+	m, todos := memoryRepo(internal.ToDo{ID: testUUID, Title: "Standup", Due: &due, RRule: "FREQ=DAILY"})
+
+	// The user deleted tomorrow's occurrence
+	deleted := internal.OccurrenceID(testUUID, due.Add(24*time.Hour))
+
+	repo := &TombstoneRepoMock{
+		RepoMock: m,
+		IsDeletedFn: func(id string) (bool, error) {
+			return id == deleted, nil
+		},
+	}
+
+	provider := func(string) database.ToDoRepo {
+		return repo
+	}
+
+	h := handlers.NewRecurrenceHandler(recurring(todos), provider, 3*24*time.Hour)
+
+	if err := h.Handle(events.CloudWatchEvent{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := todos[deleted]; ok {
+		t.Fatal("Expected the deleted occurrence not to be created again")
+	}
+
+	// The occurrences that follow it are still created
+	for _, d := range []time.Duration{2, 3} {
+		if _, ok := todos[internal.OccurrenceID(testUUID, due.Add(d*24*time.Hour))]; !ok {
+			t.Fatalf("Expected an occurrence due in %d days, got %v", d, todos)
+		}
+	}
+}
+
+func testMaterializeError(t *testing.T) {
+
+	m := &FinderMock{
+		GetRecurringFn: func() ([]internal.ToDo, error) {
+			return nil, errors.New("boom")
+		},
+	}
+
+	h := handlers.NewRecurrenceHandler(m, nil, handlers.DefaultHorizon)
+
+	if err := h.Handle(events.CloudWatchEvent{}); err == nil {
+		t.Fatal("Expected an error")
+	}
+}
@@ -1,6 +1,8 @@
 package handlers_test
 
 import (
+	"time"
+
 	"github.com/benjaminbartels/todo/internal"
 )
 
@@ -39,3 +41,71 @@ func (m *RepoMock) Delete(id string) error {
 	m.DeleteInvoked = true
 	return m.DeleteFn(id)
 }
+
+// ChangeRepoMock is used to mock a repository that tracks changes
+type ChangeRepoMock struct {
+	*RepoMock
+	GetChangedSinceFn      func(time.Time) ([]internal.ToDo, []internal.Tombstone, error)
+	GetChangedSinceInvoked bool
+}
+
+// GetChangedSince returns the ToDos changed and deleted after since
+func (m *ChangeRepoMock) GetChangedSince(since time.Time) ([]internal.ToDo, []internal.Tombstone, error) {
+	m.GetChangedSinceInvoked = true
+	return m.GetChangedSinceFn(since)
+}
+
+// TombstoneRepoMock is used to mock a repository that keeps tombstones of deleted ToDos
+type TombstoneRepoMock struct {
+	*RepoMock
+	IsDeletedFn      func(string) (bool, error)
+	IsDeletedInvoked bool
+}
+
+// IsDeleted reports whether a ToDo was deleted
+func (m *TombstoneRepoMock) IsDeleted(id string) (bool, error) {
+	m.IsDeletedInvoked = true
+	return m.IsDeletedFn(id)
+}
+
+// ConditionalRepoMock is used to mock a repository that can save a ToDo only if it was not modified
+type ConditionalRepoMock struct {
+	*RepoMock
+	SaveIfUnmodifiedFn      func(*internal.ToDo, time.Time) error
+	SaveIfUnmodifiedInvoked bool
+}
+
+// SaveIfUnmodified updates a ToDo if it was last modified at modTime
+func (m *ConditionalRepoMock) SaveIfUnmodified(todo *internal.ToDo, modTime time.Time) error {
+	m.SaveIfUnmodifiedInvoked = true
+	return m.SaveIfUnmodifiedFn(todo, modTime)
+}
+
+// QuerierRepoMock is used to mock a repository that can filter ToDos without reading all of them
+type QuerierRepoMock struct {
+	*RepoMock
+	GetByCompletedFn      func(bool) ([]internal.ToDo, error)
+	GetDueBetweenFn       func(time.Time, time.Time) ([]internal.ToDo, error)
+	GetByTagsFn           func([]string, bool) ([]internal.ToDo, error)
+	GetByCompletedInvoked bool
+	GetDueBetweenInvoked  bool
+	GetByTagsInvoked      bool
+}
+
+// GetByCompleted returns the ToDos that are, or are not, completed
+func (m *QuerierRepoMock) GetByCompleted(completed bool) ([]internal.ToDo, error) {
+	m.GetByCompletedInvoked = true
+	return m.GetByCompletedFn(completed)
+}
+
+// GetDueBetween returns the ToDos that are due within the given range
+func (m *QuerierRepoMock) GetDueBetween(from, to time.Time) ([]internal.ToDo, error) {
+	m.GetDueBetweenInvoked = true
+	return m.GetDueBetweenFn(from, to)
+}
+
+// GetByTags returns the ToDos that have all of the tags, or any of them if all is false
+func (m *QuerierRepoMock) GetByTags(tags []string, all bool) ([]internal.ToDo, error) {
+	m.GetByTagsInvoked = true
+	return m.GetByTagsFn(tags, all)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+	"github.com/benjaminbartels/todo/internal/domain"
+	"github.com/pkg/errors"
+)
+
+// StreamHandler consumes the stream of the ToDos table, turning each change to a ToDo or comment into
+// domain events and passing them to sinks. The stream must include new and old images.
+type StreamHandler struct {
+	sinks []domain.Sink
+}
+
+// NewStreamHandler creates a new stream handler that passes events to sinks
+func NewStreamHandler(sinks ...domain.Sink) *StreamHandler {
+	return &StreamHandler{
+		sinks: sinks,
+	}
+}
+
+// Handle handles a batch of records from the stream. Records for items that are not ToDos or comments are
+// skipped. If an error is returned Lambda retries the whole batch, so records that were handled are handled
+// again. Sinks that are ContextSinks are passed ctx, which Lambda cancels when the function times out.
+func (h *StreamHandler) Handle(ctx context.Context, e events.DynamoDBEvent) error {
+
+	for _, r := range e.Records {
+		old, err := dynamodb.UnmarshalStreamImage(r.Change.OldImage)
+		if err != nil {
+			return errors.Wrapf(err, "Could not read old image of record %s", r.EventID)
+		}
+
+		new, err := dynamodb.UnmarshalStreamImage(r.Change.NewImage)
+		if err != nil {
+			return errors.Wrapf(err, "Could not read new image of record %s", r.EventID)
+		}
+
+		oldComment, err := dynamodb.UnmarshalStreamComment(r.Change.OldImage)
+		if err != nil {
+			return errors.Wrapf(err, "Could not read old image of record %s", r.EventID)
+		}
+
+		newComment, err := dynamodb.UnmarshalStreamComment(r.Change.NewImage)
+		if err != nil {
+			return errors.Wrapf(err, "Could not read new image of record %s", r.EventID)
+		}
+
+		at := r.Change.ApproximateCreationDateTime.Time
+		if at.IsZero() {
+			at = time.Now()
+		}
+
+		evs := append(domain.Events(r.EventID, at.UTC(), old, new),
+			domain.CommentEvents(r.EventID, at.UTC(), oldComment, newComment)...)
+
+		for _, ev := range evs {
+			for _, s := range h.sinks {
+				if err := domain.Handle(ctx, s, ev); err != nil {
+					return errors.Wrapf(err, "Could not handle %s event %s", ev.Name(), ev.Metadata().ID)
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package handlers_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal/domain"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+	"github.com/pkg/errors"
+)
+
+func TestStreamHandler(t *testing.T) {
+	t.Run("Events", testStreamEvents)
+	t.Run("SkipsItemsThatAreNotToDos", testStreamSkipsItemsThatAreNotToDos)
+	t.Run("SinkError", testStreamSinkError)
+	t.Run("Mentions", testStreamMentions)
+}
+
+// streamRecord returns a stream record for a change to a ToDo titled old to one titled new, either of
+// which is empty if the ToDo did not exist
+func streamRecord(eventID, listID, old, new string) events.DynamoDBEventRecord {
+	image := func(title string) map[string]events.DynamoDBAttributeValue {
+		if title == "" {
+			return nil
+		}
+		return map[string]events.DynamoDBAttributeValue{
+			"listId": events.NewStringAttribute(listID),
+			"id":     events.NewStringAttribute("1"),
+			"title":  events.NewStringAttribute(title),
+		}
+	}
+
+	return events.DynamoDBEventRecord{
+		EventID: eventID,
+		Change: events.DynamoDBStreamRecord{
+			OldImage: image(old),
+			NewImage: image(new),
+		},
+	}
+}
+
+func testStreamEvents(t *testing.T) {
+
+	var got []domain.Event
+
+	h := handlers.NewStreamHandler(domain.SinkFunc(func(e domain.Event) error {
+		got = append(got, e)
+		return nil
+	}))
+
+	err := h.Handle(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		streamRecord("e1", "work", "", "Foo"),
+		streamRecord("e2", "work", "Foo", "Bar"),
+		streamRecord("e3", "work", "Bar", ""),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{domain.CreatedEvent, domain.RenamedEvent, domain.DeletedEvent}
+
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d events, got %d", len(want), len(got))
+	}
+
+	for i, e := range got {
+		if e.Name() != want[i] {
+			t.Fatalf("Expected event %d to be %s, got %s", i, want[i], e.Name())
+		}
+
+		if e.Metadata().ListID != "work" || e.Metadata().ToDoID != "1" {
+			t.Fatalf("Unexpected metadata %+v", e.Metadata())
+		}
+	}
+}
+
+func testStreamSkipsItemsThatAreNotToDos(t *testing.T) {
+
+	invoked := false
+
+	h := handlers.NewStreamHandler(domain.SinkFunc(func(e domain.Event) error {
+		invoked = true
+		return nil
+	}))
+
+	if err := h.Handle(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		streamRecord("e1", "_connections#work", "", "abc"),
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if invoked {
+		t.Fatal("Expected sink not to be invoked")
+	}
+}
+
+func testStreamSinkError(t *testing.T) {
+
+	h := handlers.NewStreamHandler(domain.SinkFunc(func(e domain.Event) error {
+		return errors.New("sink error")
+	}))
+
+	err := h.Handle(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		streamRecord("e1", "work", "", "Foo"),
+	}})
+	if err == nil {
+		t.Fatal("Expected error so the batch is retried")
+	}
+}
+
+func testStreamMentions(t *testing.T) {
+
+	var got []domain.Event
+
+	h := handlers.NewStreamHandler(domain.SinkFunc(func(e domain.Event) error {
+		got = append(got, e)
+		return nil
+	}))
+
+	comment := map[string]events.DynamoDBAttributeValue{
+		"listId":        events.NewStringAttribute("_comments#work"),
+		"id":            events.NewStringAttribute("1#c1"),
+		"commentId":     events.NewStringAttribute("c1"),
+		"commentListId": events.NewStringAttribute("work"),
+		"todoId":        events.NewStringAttribute("1"),
+		"body":          events.NewStringAttribute("@sam"),
+		"mentions":      events.NewListAttribute([]events.DynamoDBAttributeValue{events.NewStringAttribute("user-2")}),
+	}
+
+	err := h.Handle(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		{EventID: "e1", Change: events.DynamoDBStreamRecord{NewImage: comment}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 || got[0].Name() != domain.MentionedEvent || got[0].Metadata().ListID != "work" {
+		t.Fatalf("Expected a Mentioned event, got %+v", got)
+	}
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/pkg/errors"
+)
+
+const (
+	// changesResource is the API Gateway resource for fetching changes since a sync token
+	changesResource = "/todos/changes"
+	// syncResource is the API Gateway resource for applying changes made by offline clients
+	syncResource = "/todos/sync"
+	// syncTokenOverlap is subtracted from the time a sync token is issued at, so ToDos saved by requests
+	// that were in flight, or by servers with a skewed clock, are not missed. Clients may receive the same
+	// change twice.
+	syncTokenOverlap = 5 * time.Second
+	// maxMutations is the largest number of mutations accepted in a single sync
+	maxMutations = 100
+)
+
+// Mutation operations
+const (
+	MutationCreate = "create"
+	MutationUpdate = "update"
+	MutationDelete = "delete"
+	// MutationMerge merges the client's copy of a ToDo, with the clocks of its fields, into the server's,
+	// keeping the latest write of each field. Merges never conflict.
+	MutationMerge = "merge"
+)
+
+// Mutation results
+const (
+	// MutationApplied means the mutation was saved
+	MutationApplied = "applied"
+	// MutationConflict means the ToDo was changed on the server since the client last saw it. The result
+	// contains the server's copy, if it still exists.
+	MutationConflict = "conflict"
+	// MutationRejected means the mutation is invalid and will never be applied
+	MutationRejected = "rejected"
+	// MutationFailed means the mutation could not be saved and can be retried
+	MutationFailed = "failed"
+)
+
+// Changes are the ToDos created, updated and deleted since a sync token
+type Changes struct {
+	Created []internal.ToDo      `json:"created"`
+	Updated []internal.ToDo      `json:"updated"`
+	Deleted []internal.Tombstone `json:"deleted"`
+	Token   string               `json:"token"`
+}
+
+// SyncRequest is a batch of mutations made by a client while it was offline, in the order they were made
+type SyncRequest struct {
+	Mutations []Mutation `json:"mutations"`
+}
+
+// Mutation is a change made by a client. BaseModTime is the ModTime of the ToDo when the client last
+// fetched it, and is required to update or delete a ToDo unless Force is set. Force also completes a ToDo
+// while ToDos that block it are open.
+type Mutation struct {
+	Op          string        `json:"op"`
+	ToDo        internal.ToDo `json:"todo"`
+	BaseModTime *time.Time    `json:"baseModTime,omitempty"`
+	Force       bool          `json:"force,omitempty"`
+}
+
+// SyncResult reports the result of each mutation of a SyncRequest
+type SyncResult struct {
+	Results []MutationResult `json:"results"`
+}
+
+// MutationResult is the result of a Mutation. ToDo is the server's copy after the mutation was applied, or
+// when it conflicted.
+type MutationResult struct {
+	Index  int            `json:"index"`
+	ID     string         `json:"id,omitempty"`
+	Status string         `json:"status"`
+	Reason string         `json:"reason,omitempty"`
+	ToDo   *internal.ToDo `json:"todo,omitempty"`
+}
+
+// changes returns the ToDos changed since the sync token in the since parameter, or every ToDo if there
+// is none
+func (h *ToDoHandler) changes(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	c, ok := h.repo.(database.ToDoChangeRepo)
+	if !ok {
+		return CreateErrorResponse(ErrNotImplemented)
+	}
+
+	issued := time.Now()
+
+	var since time.Time
+
+	if token := req.QueryStringParameters["since"]; token != "" {
+		var err error
+		if since, err = parseChangesToken(token); err != nil {
+			return CreateErrorResponse(errors.Wrap(ErrBadRequest, "invalid sync token"))
+		}
+
+		if issued.Sub(since) > database.TombstoneRetention {
+			return CreateErrorResponse(errors.Wrap(ErrGone, "sync token has expired"))
+		}
+	}
+
+	todos, tombstones, err := c.GetChangedSince(since)
+	if errors.Cause(err) == database.ErrNotSupported {
+		return CreateErrorResponse(ErrNotImplemented)
+	} else if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	result := Changes{
+		Created: []internal.ToDo{},
+		Updated: []internal.ToDo{},
+		Deleted: []internal.Tombstone{},
+		Token:   changesToken(issued.Add(-syncTokenOverlap)),
+	}
+
+	ids := make(map[string]bool)
+
+	for _, t := range todos {
+		ids[t.ID] = true
+
+		if since.IsZero() || t.Created.After(since) {
+			result.Created = append(result.Created, t)
+		} else {
+			result.Updated = append(result.Updated, t)
+		}
+	}
+
+	// A ToDo that was deleted and then created again with the same ID exists
+	for _, t := range tombstones {
+		if !ids[t.ID] {
+			result.Deleted = append(result.Deleted, t)
+		}
+	}
+
+	return CreateOKResponse(result)
+}
+
+// sync applies the mutations made by an offline client, reporting the result of each one
+func (h *ToDoHandler) sync(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	var s SyncRequest
+	if err := json.Unmarshal([]byte(req.Body), &s); err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "body is not valid JSON"))
+	}
+
+	if len(s.Mutations) > maxMutations {
+		return CreateErrorResponse(errors.Wrapf(ErrBadRequest, "at most %d mutations can be synced at once", maxMutations))
+	}
+
+	result := SyncResult{Results: []MutationResult{}}
+
+	for i, m := range s.Mutations {
+		r := h.apply(m)
+		r.Index = i
+		result.Results = append(result.Results, r)
+	}
+
+	return CreateOKResponse(result)
+}
+
+// apply applies a single mutation
+func (h *ToDoHandler) apply(m Mutation) MutationResult {
+
+	todo := m.ToDo
+	r := MutationResult{ID: todo.ID}
+
+	if todo.ID == "" && m.Op != MutationCreate {
+		r.Status, r.Reason = MutationRejected, "ID is required"
+		return r
+	}
+
+	if m.Op == MutationMerge {
+		for _, ts := range todo.Clocks {
+			if err := clock.Update(ts); err != nil {
+				r.Status, r.Reason = MutationRejected, err.Error()
+				return r
+			}
+		}
+	}
+
+	var existing *internal.ToDo
+
+	if todo.ID != "" {
+		var err error
+		if existing, err = h.repo.Get(todo.ID); err != nil {
+			r.Status, r.Reason = MutationFailed, repoError(err).Error()
+			return r
+		}
+	}
+
+	// conflict reports whether the ToDo was changed since the client fetched it
+	conflict := func() bool {
+		return !m.Force && (m.BaseModTime == nil || !existing.ModTime.Equal(*m.BaseModTime))
+	}
+
+	switch m.Op {
+	case MutationCreate:
+		if existing != nil {
+			r.Status, r.Reason, r.ToDo = MutationConflict, "ToDo already exists", existing
+			return r
+		}
+
+		if err := h.prepare(nil, &todo, m.Force); err != nil {
+			return unprepared(r, existing, err)
+		}
+
+		keepManaged(nil, &todo)
+		todo.Stamp(nil, writeTime(nil))
+	case MutationUpdate:
+		if existing == nil {
+			r.Status, r.Reason = MutationConflict, "ToDo was deleted"
+			return r
+		}
+
+		if conflict() {
+			r.Status, r.Reason, r.ToDo = MutationConflict, "ToDo was modified", existing
+			return r
+		}
+
+		if err := h.prepare(existing, &todo, m.Force); err != nil {
+			return unprepared(r, existing, err)
+		}
+
+		keepManaged(existing, &todo)
+		todo.Created = existing.Created
+		todo.Stamp(existing, writeTime(existing))
+	case MutationMerge:
+		// Managed fields are not merged, so those of the existing ToDo are kept
+		if existing != nil {
+			todo = existing.Merge(todo)
+		} else {
+			keepManaged(nil, &todo)
+		}
+
+		if err := h.prepare(existing, &todo, m.Force); err != nil {
+			return unprepared(r, existing, err)
+		}
+	case MutationDelete:
+		if existing == nil {
+			// Already deleted, possibly by an earlier attempt to sync
+			r.Status = MutationApplied
+			return r
+		}
+
+		if conflict() {
+			r.Status, r.Reason, r.ToDo = MutationConflict, "ToDo was modified", existing
+			return r
+		}
+
+		if err := h.repo.Delete(todo.ID); err != nil {
+			r.Status, r.Reason = MutationFailed, repoError(err).Error()
+			return r
+		}
+
+		r.Status = MutationApplied
+		return r
+	default:
+		r.Status, r.Reason = MutationRejected, "op must be create, update, merge or delete"
+		return r
+	}
+
+	if err := h.repo.Save(&todo); err != nil {
+		r.Status, r.Reason = MutationFailed, repoError(err).Error()
+		return r
+	}
+
+	recur(h.repo, existing, &todo)
+
+	r.ID, r.Status, r.ToDo = todo.ID, MutationApplied, &todo
+
+	return r
+}
+
+// unprepared returns the result of a mutation of existing that could not be prepared. The mutation
+// conflicts if it completes a ToDo while ToDos that block it are open, and failed, and can be retried, if
+// the members of the list could not be read to check its assignees.
+func unprepared(r MutationResult, existing *internal.ToDo, err error) MutationResult {
+	switch errors.Cause(err) {
+	case ErrBadRequest:
+		r.Status = MutationRejected
+	case ErrConflict:
+		r.Status, r.ToDo = MutationConflict, existing
+	default:
+		r.Status = MutationFailed
+	}
+
+	r.Reason = err.Error()
+
+	return r
+}
+
+// changesToken returns an opaque sync token for changes after t
+func changesToken(t time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(t.UnixNano(), 10)))
+}
+
+// parseChangesToken returns the time a sync token was issued for
+func parseChangesToken(token string) (time.Time, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	nanos, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(0, nanos), nil
+}
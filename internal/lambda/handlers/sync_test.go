@@ -0,0 +1,345 @@
+package handlers_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/crdt"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+func TestSync(t *testing.T) {
+	t.Run("ChangesOK", testChangesOK)
+	t.Run("ChangesBadToken", testChangesBadToken)
+	t.Run("ChangesExpiredToken", testChangesExpiredToken)
+	t.Run("ChangesNotImplemented", testChangesNotImplemented)
+	t.Run("SyncOK", testSyncOK)
+	t.Run("SyncTooManyMutations", testSyncTooManyMutations)
+	t.Run("SyncMerge", testSyncMerge)
+	t.Run("SyncMergeInvalid", testSyncMergeInvalid)
+}
+
+func postSync(t *testing.T, h *handlers.ToDoHandler, mutations ...handlers.Mutation) handlers.SyncResult {
+	body, err := json.Marshal(handlers.SyncRequest{Mutations: mutations})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := events.APIGatewayProxyRequest{
+		Resource:   "/todos/sync",
+		HTTPMethod: http.MethodPost,
+		Body:       string(body),
+	}
+
+	resp, err := h.Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var result handlers.SyncResult
+	if err := json.Unmarshal([]byte(resp.Body), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func getChanges(t *testing.T, h *handlers.ToDoHandler, token string) (events.APIGatewayProxyResponse, handlers.Changes) {
+	req := events.APIGatewayProxyRequest{
+		Resource:              "/todos/changes",
+		HTTPMethod:            http.MethodGet,
+		QueryStringParameters: map[string]string{},
+	}
+
+	if token != "" {
+		req.QueryStringParameters["since"] = token
+	}
+
+	resp, err := h.Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var changes handlers.Changes
+	if resp.StatusCode == http.StatusOK {
+		if err := json.Unmarshal([]byte(resp.Body), &changes); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return resp, changes
+}
+
+func testChangesOK(t *testing.T) {
+
+	created := internal.ToDo{ID: "created", Created: time.Now(), ModTime: time.Now()}
+	updated := internal.ToDo{ID: "updated", Created: time.Now().Add(-time.Hour), ModTime: time.Now()}
+	recreated := internal.ToDo{ID: "recreated", Created: time.Now(), ModTime: time.Now()}
+
+	var since time.Time
+
+	m := &ChangeRepoMock{
+		RepoMock: &RepoMock{},
+		GetChangedSinceFn: func(s time.Time) ([]internal.ToDo, []internal.Tombstone, error) {
+			since = s
+			return []internal.ToDo{created, updated, recreated}, []internal.Tombstone{
+				{ID: "deleted", Deleted: time.Now()},
+				{ID: "recreated", Deleted: time.Now()},
+			}, nil
+		},
+	}
+
+	h := handlers.NewToDoHandler(m)
+
+	// Without a token every ToDo is created
+	resp, changes := getChanges(t, h, "")
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if !since.IsZero() {
+		t.Fatalf("Expected changes since the beginning, got %v", since)
+	}
+
+	if len(changes.Created) != 3 || len(changes.Updated) != 0 {
+		t.Fatalf("Expected 3 created ToDos, got %v", changes)
+	}
+
+	resp, changes = getChanges(t, h, changes.Token)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if time.Since(since) > time.Minute {
+		t.Fatalf("Expected changes since the previous token, got %v", since)
+	}
+
+	if len(changes.Created) != 2 || changes.Created[0].ID != "created" {
+		t.Fatalf("Expected created ToDos, got %v", changes.Created)
+	}
+
+	if len(changes.Updated) != 1 || changes.Updated[0].ID != "updated" {
+		t.Fatalf("Expected updated ToDo, got %v", changes.Updated)
+	}
+
+	if len(changes.Deleted) != 1 || changes.Deleted[0].ID != "deleted" {
+		t.Fatalf("Expected only deleted ToDo to be deleted, got %v", changes.Deleted)
+	}
+}
+
+func testChangesBadToken(t *testing.T) {
+
+	m := &ChangeRepoMock{RepoMock: &RepoMock{}}
+
+	resp, _ := getChanges(t, handlers.NewToDoHandler(m), "not a token")
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	if m.GetChangedSinceInvoked {
+		t.Fatal("GetChangedSince should not be invoked")
+	}
+}
+
+func testChangesExpiredToken(t *testing.T) {
+
+	m := &ChangeRepoMock{RepoMock: &RepoMock{}}
+
+	issued := time.Now().Add(-60 * 24 * time.Hour).UnixNano()
+	token := base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(issued, 10)))
+
+	resp, _ := getChanges(t, handlers.NewToDoHandler(m), token)
+
+	if resp.StatusCode != http.StatusGone {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusGone, resp.StatusCode)
+	}
+}
+
+func testChangesNotImplemented(t *testing.T) {
+
+	resp, _ := getChanges(t, handlers.NewToDoHandler(&RepoMock{}), "")
+
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusNotImplemented, resp.StatusCode)
+	}
+}
+
+func testSyncOK(t *testing.T) {
+
+	base := time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC)
+	created := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	m, todos := memoryRepo(
+		internal.ToDo{ID: "unchanged", Title: "Unchanged", Created: created, ModTime: base},
+		internal.ToDo{ID: "modified", Title: "Modified", ModTime: base.Add(time.Minute)},
+		internal.ToDo{ID: "remove", Title: "Remove", ModTime: base},
+	)
+
+	result := postSync(t, handlers.NewToDoHandler(m),
+		handlers.Mutation{Op: handlers.MutationCreate, ToDo: internal.ToDo{ID: "new", Title: "New"}},
+		handlers.Mutation{Op: handlers.MutationUpdate, ToDo: internal.ToDo{ID: "unchanged", Title: "Renamed"}, BaseModTime: &base},
+		handlers.Mutation{Op: handlers.MutationUpdate, ToDo: internal.ToDo{ID: "modified", Title: "Mine"}, BaseModTime: &base},
+		handlers.Mutation{Op: handlers.MutationUpdate, ToDo: internal.ToDo{ID: "gone", Title: "Gone"}, BaseModTime: &base},
+		handlers.Mutation{Op: handlers.MutationDelete, ToDo: internal.ToDo{ID: "remove"}, BaseModTime: &base},
+		handlers.Mutation{Op: handlers.MutationDelete, ToDo: internal.ToDo{ID: "remove"}, BaseModTime: &base},
+		handlers.Mutation{Op: handlers.MutationUpdate, ToDo: internal.ToDo{ID: "modified", Title: "Forced"}, Force: true},
+		handlers.Mutation{Op: "rename", ToDo: internal.ToDo{ID: "unchanged"}},
+	)
+
+	expected := []string{
+		handlers.MutationApplied,
+		handlers.MutationApplied,
+		handlers.MutationConflict,
+		handlers.MutationConflict,
+		handlers.MutationApplied,
+		handlers.MutationApplied,
+		handlers.MutationApplied,
+		handlers.MutationRejected,
+	}
+
+	if len(result.Results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(result.Results))
+	}
+
+	for i, r := range result.Results {
+		if r.Index != i || r.Status != expected[i] {
+			t.Fatalf("Expected mutation %d to be %s, got %v", i, expected[i], r)
+		}
+	}
+
+	if r := result.Results[2]; r.ToDo == nil || r.ToDo.Title != "Modified" {
+		t.Fatalf("Expected conflict to return the server's ToDo, got %v", r.ToDo)
+	}
+
+	if todos["unchanged"].Title != "Renamed" || !todos["unchanged"].Created.Equal(created) {
+		t.Fatalf("Expected ToDo to be renamed and keep its Created time, got %v", todos["unchanged"])
+	}
+
+	if todos["modified"].Title != "Forced" {
+		t.Fatalf("Expected forced update to be applied, got %v", todos["modified"])
+	}
+
+	if _, ok := todos["remove"]; ok {
+		t.Fatal("Expected ToDo to be deleted")
+	}
+
+	if _, ok := todos["new"]; !ok {
+		t.Fatal("Expected ToDo to be created")
+	}
+}
+
+func testSyncTooManyMutations(t *testing.T) {
+
+	m := &RepoMock{}
+
+	body, err := json.Marshal(handlers.SyncRequest{Mutations: make([]handlers.Mutation, 101)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := events.APIGatewayProxyRequest{
+		Resource:   "/todos/sync",
+		HTTPMethod: http.MethodPost,
+		Body:       string(body),
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	if m.GetInvoked {
+		t.Fatal("Get should not be invoked")
+	}
+}
+
+func testSyncMerge(t *testing.T) {
+
+	base := internal.ToDo{ID: testUUID, Title: "Buy milk"}
+	base.Stamp(nil, crdt.FromTime(time.Now().Add(-time.Hour)))
+
+	m, todos := memoryRepo(base)
+	h := handlers.NewToDoHandler(m)
+
+	// Two clients edit different fields while offline, then sync in turn
+	renamed := base
+	renamed.Title = "Buy oat milk"
+	renamed.Stamp(&base, crdt.Timestamp{Wall: crdt.FromTime(time.Now()).Wall, Node: "phone"})
+
+	completed := base
+	completed.Completed = true
+	completed.Stamp(&base, crdt.Timestamp{Wall: crdt.FromTime(time.Now()).Wall, Node: "laptop"})
+
+	far := base
+	far.Title = "From the future"
+	far.Stamp(&base, crdt.FromTime(time.Now().Add(time.Hour)))
+
+	result := postSync(t, h,
+		handlers.Mutation{Op: handlers.MutationMerge, ToDo: renamed},
+		handlers.Mutation{Op: handlers.MutationMerge, ToDo: completed},
+		handlers.Mutation{Op: handlers.MutationMerge, ToDo: far},
+	)
+
+	for i, status := range []string{handlers.MutationApplied, handlers.MutationApplied, handlers.MutationRejected} {
+		if result.Results[i].Status != status {
+			t.Fatalf("Expected mutation %d to be %s, got %v", i, status, result.Results[i])
+		}
+	}
+
+	merged := todos[testUUID]
+	if merged.Title != "Buy oat milk" || !merged.Completed {
+		t.Fatalf("Expected both edits to survive, got %v", merged)
+	}
+
+	if merged.Clocks["title"].Node != "phone" || merged.Clocks["completed"].Node != "laptop" {
+		t.Fatalf("Expected the clocks of both edits to be kept, got %v", merged.Clocks)
+	}
+}
+
+func testSyncMergeInvalid(t *testing.T) {
+
+	base := internal.ToDo{ID: testUUID, Title: "Buy milk"}
+	base.Stamp(nil, crdt.FromTime(time.Now().Add(-time.Hour)))
+
+	m, todos := memoryRepo(base)
+
+	long := base
+	long.Notes = strings.Repeat("a", 64*1024+1)
+	long.Stamp(&base, crdt.FromTime(time.Now()))
+
+	created := internal.ToDo{ID: "new", Title: "New", Notes: long.Notes}
+	created.Stamp(nil, crdt.FromTime(time.Now()))
+
+	result := postSync(t, handlers.NewToDoHandler(m),
+		handlers.Mutation{Op: handlers.MutationMerge, ToDo: long},
+		handlers.Mutation{Op: handlers.MutationMerge, ToDo: created},
+	)
+
+	for i, r := range result.Results {
+		if r.Status != handlers.MutationRejected {
+			t.Fatalf("Expected mutation %d to be rejected, got %v", i, r)
+		}
+	}
+
+	if todos[testUUID].Notes != "" || m.SaveInvoked {
+		t.Fatalf("Expected no ToDo to be saved, got %v", todos)
+	}
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/pkg/errors"
+)
+
+const (
+	// tagsResource is the API Gateway resource for the tags in use and their counts
+	tagsResource = "/tags"
+	// tagResource is the API Gateway resource for renaming a tag
+	tagResource = "/tags/{tag}"
+	// tagsMergeResource is the API Gateway resource for merging tags
+	tagsMergeResource = "/tags/merge"
+	// maxTags is the most tags a ToDo can have
+	maxTags = 50
+	// maxTagLength is the longest tag, in bytes
+	maxTagLength = 64
+)
+
+// TagRenameRequest is the body of a request to rename a tag. Renaming a tag to one that is in use merges
+// them.
+type TagRenameRequest struct {
+	Name string `json:"name"`
+}
+
+// TagMergeRequest is the body of a request to replace every tag in Tags with Into
+type TagMergeRequest struct {
+	Tags []string `json:"tags"`
+	Into string   `json:"into"`
+}
+
+// tags handles requests to list, rename and merge the tags of every ToDo
+func (h *ToDoHandler) tags(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	switch {
+	case req.Resource == tagsResource && req.HTTPMethod == "GET":
+		todos, err := h.repo.GetAll()
+		if err != nil {
+			return CreateErrorResponse(repoError(err))
+		}
+		return CreateOKResponse(internal.CountTags(todos))
+	case req.Resource == tagResource && req.HTTPMethod == "PUT":
+		var r TagRenameRequest
+		if err := json.Unmarshal([]byte(req.Body), &r); err != nil {
+			return CreateErrorResponse(errors.Wrap(ErrBadRequest, "body is not valid JSON"))
+		}
+		return h.retag([]string{req.PathParameters["tag"]}, r.Name)
+	case req.Resource == tagsMergeResource && req.HTTPMethod == "POST":
+		var r TagMergeRequest
+		if err := json.Unmarshal([]byte(req.Body), &r); err != nil {
+			return CreateErrorResponse(errors.Wrap(ErrBadRequest, "body is not valid JSON"))
+		}
+		return h.retag(r.Tags, r.Into)
+	default:
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+}
+
+// retag replaces the tags in from with to on every ToDo, responding with to and the number of ToDos that
+// have it
+func (h *ToDoHandler) retag(from []string, to string) (events.APIGatewayProxyResponse, error) {
+
+	from = internal.NormalizeTags(from)
+	if len(from) == 0 {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "tags to replace are required"))
+	}
+
+	to = internal.NormalizeTag(to)
+	if err := validTag(to); err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	todos, err := h.repo.GetAll()
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	changed := []*internal.ToDo{}
+	count := internal.TagCount{Name: to}
+
+	for i := range todos {
+		previous := todos[i]
+		todo := &todos[i]
+
+		if todo.Retag(from, to) {
+			todo.Stamp(&previous, writeTime(&previous))
+			changed = append(changed, todo)
+		}
+
+		if todo.HasTag(to) {
+			count.Count++
+		}
+	}
+
+	if len(changed) > 0 {
+		if err := database.SaveAll(h.repo, changed); err != nil {
+			return CreateErrorResponse(repoError(err))
+		}
+	}
+
+	return CreateOKResponse(count)
+}
+
+// getByTags responds with the ToDos that have all of the tags, or any of them if mode is any. If completed
+// is given only the ToDos that are, or are not, completed are included.
+func (h *ToDoHandler) getByTags(req events.APIGatewayProxyRequest, tags []string, mode string,
+	completed *bool) (events.APIGatewayProxyResponse, error) {
+
+	tags = internal.NormalizeTags(tags)
+	if len(tags) == 0 {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "tag must not be empty"))
+	}
+
+	var all bool
+	switch mode {
+	case "", "all":
+		all = true
+	case "any":
+		all = false
+	default:
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "tagMode must be all or any"))
+	}
+
+	var todos []internal.ToDo
+	err := database.ErrNotSupported
+
+	// Use the repository's filter when it has one, otherwise filter all ToDos
+	if q, ok := h.repo.(database.ToDoTagQuerier); ok {
+		todos, err = q.GetByTags(tags, all)
+	}
+	if errors.Cause(err) == database.ErrNotSupported {
+		todos, err = h.repo.GetAll()
+	}
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	filtered := []internal.ToDo{}
+	for _, t := range todos {
+		if t.HasTags(tags, all) && (completed == nil || t.Completed == *completed) {
+			filtered = append(filtered, t)
+		}
+	}
+
+	return respondToDos(req, filtered)
+}
+
+// prepareTags normalizes the tags of a ToDo that is about to be saved and checks they are valid
+func prepareTags(todo *internal.ToDo) error {
+	todo.Tags = internal.NormalizeTags(todo.Tags)
+
+	if len(todo.Tags) > maxTags {
+		return errors.Wrapf(ErrBadRequest, "a ToDo can have at most %d tags", maxTags)
+	}
+
+	for _, tag := range todo.Tags {
+		if err := validTag(tag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validTag checks that a normalized tag is not empty and not too long
+func validTag(tag string) error {
+	if tag == "" || len(tag) > maxTagLength {
+		return errors.Wrapf(ErrBadRequest, "tags must be 1 to %d bytes", maxTagLength)
+	}
+	return nil
+}
+
+// parseCompleted parses the completed query string parameter, returning nil if it is not given
+func parseCompleted(req events.APIGatewayProxyRequest) (*bool, error) {
+	value, ok := req.QueryStringParameters["completed"]
+	if !ok {
+		return nil, nil
+	}
+
+	completed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, errors.Wrap(ErrBadRequest, "completed must be true or false")
+	}
+
+	return &completed, nil
+}
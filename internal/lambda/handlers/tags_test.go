@@ -0,0 +1,336 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+func TestTags(t *testing.T) {
+	t.Run("GetTags", testGetTags)
+	t.Run("RenameTag", testRenameTag)
+	t.Run("RenameTagMerges", testRenameTagMerges)
+	t.Run("MergeTags", testMergeTags)
+	t.Run("RenameTagBadRequest", testRenameTagBadRequest)
+	t.Run("FilterByAllTags", testFilterByAllTags)
+	t.Run("FilterByAnyTag", testFilterByAnyTag)
+	t.Run("FilterByTagsAndCompleted", testFilterByTagsAndCompleted)
+	t.Run("FilterByTagsBadRequest", testFilterByTagsBadRequest)
+	t.Run("FilterByTagsDecorated", testFilterByTagsDecorated)
+	t.Run("FilterByTagsNotSupported", testFilterByTagsNotSupported)
+	t.Run("CreateToDoWithTags", testCreateToDoWithTags)
+	t.Run("CreateToDoWithTooManyTags", testCreateToDoWithTooManyTags)
+}
+
+// taggedRepo returns a RepoMock holding ToDos with tags
+func taggedRepo() (*RepoMock, map[string]internal.ToDo) {
+	return memoryRepo(
+		internal.ToDo{ID: "1", Title: "Rotate certs", Tags: []string{"infra", "security"}},
+		internal.ToDo{ID: "2", Title: "Patch hosts", Tags: []string{"infra", "ops"}, Completed: true},
+		internal.ToDo{ID: "3", Title: "Write runbook", Tags: []string{"docs", "ops"}},
+		internal.ToDo{ID: "4", Title: "Buy milk"},
+	)
+}
+
+// filterRequest returns a request for the ToDos with tags
+func filterRequest(query map[string]string, tags ...string) events.APIGatewayProxyRequest {
+	if query == nil {
+		query = make(map[string]string)
+	}
+
+	return events.APIGatewayProxyRequest{
+		Resource:                        "/todos",
+		HTTPMethod:                      http.MethodGet,
+		QueryStringParameters:           query,
+		MultiValueQueryStringParameters: map[string][]string{"tag": tags},
+	}
+}
+
+// ids returns the sorted IDs of the ToDos in a response body
+func ids(t *testing.T, body string) string {
+	var todos []internal.ToDo
+	if err := json.Unmarshal([]byte(body), &todos); err != nil {
+		t.Fatal(err)
+	}
+
+	s := []string{}
+	for _, todo := range todos {
+		s = append(s, todo.ID)
+	}
+	sort.Strings(s)
+
+	return strings.Join(s, ",")
+}
+
+func testGetTags(t *testing.T) {
+
+	m, _ := taggedRepo()
+
+	req := events.APIGatewayProxyRequest{Resource: "/tags", HTTPMethod: http.MethodGet}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var counts []internal.TagCount
+	if err := json.Unmarshal([]byte(resp.Body), &counts); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []internal.TagCount{{Name: "docs", Count: 1}, {Name: "infra", Count: 2}, {Name: "ops", Count: 2},
+		{Name: "security", Count: 1}}
+
+	if len(counts) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, counts)
+	}
+
+	for i := range want {
+		if counts[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, counts)
+		}
+	}
+}
+
+func testRenameTag(t *testing.T) {
+
+	m, todos := taggedRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:       "/tags/{tag}",
+		HTTPMethod:     http.MethodPut,
+		PathParameters: map[string]string{"tag": "docs"},
+		Body:           `{"name":"Documentation"}`,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Body, `"name":"documentation","count":1`) {
+		t.Fatalf("Expected the renamed tag, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	if tags := strings.Join(todos["3"].Tags, ","); tags != "documentation,ops" {
+		t.Fatalf("Expected docs to be renamed, got %s", tags)
+	}
+
+	if _, ok := todos["3"].Clocks["tags"]; !ok {
+		t.Fatal("Expected tags to be stamped")
+	}
+}
+
+func testRenameTagMerges(t *testing.T) {
+
+	m, todos := taggedRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:       "/tags/{tag}",
+		HTTPMethod:     http.MethodPut,
+		PathParameters: map[string]string{"tag": "ops"},
+		Body:           `{"name":"infra"}`,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(resp.Body, `"count":3`) {
+		t.Fatalf("Expected 3 ToDos tagged infra, got %s", resp.Body)
+	}
+
+	if tags := strings.Join(todos["2"].Tags, ","); tags != "infra" {
+		t.Fatalf("Expected a single infra tag, got %s", tags)
+	}
+}
+
+func testMergeTags(t *testing.T) {
+
+	m, todos := taggedRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:   "/tags/merge",
+		HTTPMethod: http.MethodPost,
+		Body:       `{"tags":["security","ops"],"into":"platform"}`,
+	}
+
+	if _, err := handlers.NewToDoHandler(m).Handle(req); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[string]string{"1": "infra,platform", "2": "infra,platform", "3": "docs,platform", "4": ""} {
+		if tags := strings.Join(todos[id].Tags, ","); tags != want {
+			t.Fatalf("Expected ToDo %s to be tagged %s, got %s", id, want, tags)
+		}
+	}
+}
+
+func testRenameTagBadRequest(t *testing.T) {
+
+	m, _ := taggedRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:       "/tags/{tag}",
+		HTTPMethod:     http.MethodPut,
+		PathParameters: map[string]string{"tag": "ops"},
+		Body:           `{"name":" "}`,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest || m.SaveInvoked {
+		t.Fatalf("Expected status 400, got %d", resp.StatusCode)
+	}
+}
+
+func testFilterByAllTags(t *testing.T) {
+
+	m, _ := taggedRepo()
+
+	resp, err := handlers.NewToDoHandler(m).Handle(filterRequest(nil, "Infra", "ops"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(t, resp.Body); got != "2" {
+		t.Fatalf("Expected ToDo 2, got %s", got)
+	}
+}
+
+func testFilterByAnyTag(t *testing.T) {
+
+	m, _ := taggedRepo()
+
+	resp, err := handlers.NewToDoHandler(m).Handle(filterRequest(map[string]string{"tagMode": "any"}, "security", "docs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(t, resp.Body); got != "1,3" {
+		t.Fatalf("Expected ToDos 1 and 3, got %s", got)
+	}
+}
+
+func testFilterByTagsAndCompleted(t *testing.T) {
+
+	m, _ := taggedRepo()
+
+	resp, err := handlers.NewToDoHandler(m).Handle(filterRequest(map[string]string{"completed": "false"}, "ops"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(t, resp.Body); got != "3" {
+		t.Fatalf("Expected ToDo 3, got %s", got)
+	}
+}
+
+func testFilterByTagsDecorated(t *testing.T) {
+
+	m := &QuerierRepoMock{
+		RepoMock: &RepoMock{},
+		GetByTagsFn: func(tags []string, all bool) ([]internal.ToDo, error) {
+			return []internal.ToDo{{ID: "1", Title: "Rotate certs", Tags: []string{"infra", "security"}}}, nil
+		},
+	}
+
+	resp, err := handlers.NewToDoHandler(decorate(m)).Handle(filterRequest(nil, "infra"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(t, resp.Body); got != "1" {
+		t.Fatalf("Expected ToDo 1, got %s", got)
+	}
+
+	if !m.GetByTagsInvoked || m.GetAllInvoked {
+		t.Fatal("Expected the repository's filter to be used through its decorators")
+	}
+}
+
+func testFilterByTagsNotSupported(t *testing.T) {
+
+	m, _ := taggedRepo()
+
+	resp, err := handlers.NewToDoHandler(decorate(m)).Handle(filterRequest(nil, "ops"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(t, resp.Body); got != "2,3" || !m.GetAllInvoked {
+		t.Fatalf("Expected all ToDos to be filtered, got %s", got)
+	}
+}
+
+func testFilterByTagsBadRequest(t *testing.T) {
+
+	m, _ := taggedRepo()
+
+	for _, req := range []events.APIGatewayProxyRequest{
+		filterRequest(map[string]string{"tagMode": "some"}, "ops"),
+		filterRequest(nil, ""),
+		filterRequest(map[string]string{"completed": "maybe"}, "ops"),
+	} {
+		resp, err := handlers.NewToDoHandler(m).Handle(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expected status 400 for %v, got %d", req.QueryStringParameters, resp.StatusCode)
+		}
+	}
+}
+
+func testCreateToDoWithTags(t *testing.T) {
+
+	m, todos := memoryRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:   "/todos",
+		HTTPMethod: http.MethodPost,
+		Body:       `{"title":"Rotate certs","tags":["Infra","security","infra"]}`,
+	}
+
+	if _, err := handlers.NewToDoHandler(m).Handle(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if tags := strings.Join(todos[""].Tags, ","); tags != "infra,security" {
+		t.Fatalf("Expected tags to be normalized, got %s", tags)
+	}
+}
+
+func testCreateToDoWithTooManyTags(t *testing.T) {
+
+	m, _ := memoryRepo()
+
+	tags := []string{}
+	for i := 0; i < 51; i++ {
+		tags = append(tags, strings.Repeat("t", i+1))
+	}
+
+	b, _ := json.Marshal(internal.ToDo{Title: "Tagged", Tags: tags})
+
+	req := events.APIGatewayProxyRequest{Resource: "/todos", HTTPMethod: http.MethodPost, Body: string(b)}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest || m.SaveInvoked {
+		t.Fatalf("Expected status 400, got %d", resp.StatusCode)
+	}
+}
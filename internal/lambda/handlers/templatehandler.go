@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// templatesResource is the API Gateway resource for listing and adding the templates of the caller
+	templatesResource = "/templates"
+	// templateResource is the API Gateway resource for a template of the caller
+	templateResource = "/templates/{id}"
+	// templateInstantiateResource is the API Gateway resource for creating the ToDos of a template
+	templateInstantiateResource = "/templates/{id}/instantiate"
+	// maxTemplateSize is the largest template, in bytes of JSON, which leaves room for it to be stored as a
+	// single item
+	maxTemplateSize = 350 * 1024
+	// maxTemplateItems is the most items a template can have, counting subtasks
+	maxTemplateItems = 200
+	// maxTemplateDepth is the most levels of subtasks a template can have, counting its items
+	maxTemplateDepth = 5
+	// maxDueOffsetDays is the most days a template item can be due before or after the anchor date
+	maxDueOffsetDays = 3650
+)
+
+// InstantiateRequest is the body of a request to create the ToDos of a template. Anchor is the date, or
+// RFC 3339 time, that the due dates of the items are relative to, and is required if any item has one.
+// Variables has a value for each variable used by the template.
+type InstantiateRequest struct {
+	Anchor    string            `json:"anchor"`
+	TimeZone  string            `json:"timeZone"`
+	Variables map[string]string `json:"variables"`
+}
+
+// TemplateHandler provides a handle method to handle incoming AWS API Gateway requests for the templates
+// of the caller, and for creating their ToDos
+type TemplateHandler struct {
+	templates database.TemplateRepo
+	todos     database.ToDoRepo
+}
+
+// NewTemplateHandler creates a new Template handler. The ToDos of templates are created in todos.
+func NewTemplateHandler(templates database.TemplateRepo, todos database.ToDoRepo) *TemplateHandler {
+	return &TemplateHandler{
+		templates: templates,
+		todos:     todos,
+	}
+}
+
+// Handle handles a request from AWS API Gateway and returns a response
+func (h *TemplateHandler) Handle(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	userID := callerID(req)
+	if userID == "" {
+		return CreateErrorResponse(ErrUnauthorized)
+	}
+
+	switch {
+	case req.Resource == templatesResource && req.HTTPMethod == "GET":
+		return h.getAll(userID)
+	case req.Resource == templatesResource && req.HTTPMethod == "POST":
+		return h.post(req, userID)
+	case req.Resource == templatesResource:
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+
+	id := req.PathParameters["id"]
+	if id == "" {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "ID is required"))
+	}
+
+	t, err := h.templates.Get(userID, id)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	} else if t == nil {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	switch {
+	case req.Resource == templateResource && req.HTTPMethod == "GET":
+		return CreateOKResponse(t)
+	case req.Resource == templateResource && req.HTTPMethod == "PUT":
+		return h.put(req, t)
+	case req.Resource == templateResource && req.HTTPMethod == "DELETE":
+		return h.delete(t)
+	case req.Resource == templateInstantiateResource && req.HTTPMethod == "POST":
+		return h.instantiate(req, t)
+	default:
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+}
+
+// getAll returns the templates of a user, sorted by name
+func (h *TemplateHandler) getAll(userID string) (events.APIGatewayProxyResponse, error) {
+
+	templates, err := h.templates.GetByUser(userID)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse(templates)
+}
+
+// post adds a template of a user
+func (h *TemplateHandler) post(req events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
+
+	t, err := parseTemplate(req.Body)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	if t.ID != "" {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "ID must be empty"))
+	}
+
+	t.ID, t.UserID = uuid.NewV4().String(), userID
+
+	if err := h.templates.Save(&t); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse(t)
+}
+
+// put replaces the name and items of a template
+func (h *TemplateHandler) put(req events.APIGatewayProxyRequest, previous *internal.Template) (events.APIGatewayProxyResponse, error) {
+
+	t, err := parseTemplate(req.Body)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	if t.ID != "" && t.ID != previous.ID {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "ID can not be changed"))
+	}
+
+	t.ID, t.UserID, t.Created = previous.ID, previous.UserID, previous.Created
+
+	if err := h.templates.Save(&t); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse(t)
+}
+
+// delete removes a template. The ToDos created from it are kept.
+func (h *TemplateHandler) delete(t *internal.Template) (events.APIGatewayProxyResponse, error) {
+
+	if err := h.templates.Delete(t.UserID, t.ID); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse("")
+}
+
+// instantiate creates the ToDos of a template in a single batch, responding with them, parents first
+func (h *TemplateHandler) instantiate(req events.APIGatewayProxyRequest, t *internal.Template) (events.APIGatewayProxyResponse, error) {
+
+	var r InstantiateRequest
+
+	if req.Body != "" {
+		if err := json.Unmarshal([]byte(req.Body), &r); err != nil {
+			return CreateErrorResponse(errors.Wrap(ErrBadRequest, "body is not valid JSON"))
+		}
+	}
+
+	missing := []string{}
+	for _, name := range t.Variables() {
+		if _, ok := r.Variables[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return CreateErrorResponse(errors.Wrapf(ErrBadRequest, "variables %s are required", strings.Join(missing, ", ")))
+	}
+
+	loc, err := time.LoadLocation(r.TimeZone)
+	if err != nil {
+		return CreateErrorResponse(errors.Wrapf(ErrBadRequest, "time zone %s is not valid", r.TimeZone))
+	}
+
+	var anchor time.Time
+
+	if r.Anchor != "" {
+		if anchor, err = parseDateTime(r.Anchor, loc, false); err != nil {
+			return CreateErrorResponse(errors.Wrap(ErrBadRequest, "anchor must be a date or an RFC 3339 time"))
+		}
+	} else if t.HasDueOffsets() {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "anchor is required, as items are due relative to it"))
+	}
+
+	todos := t.Instantiate(r.Variables, anchor, func() string { return uuid.NewV4().String() })
+	batch := make([]*internal.ToDo, len(todos))
+
+	for i := range todos {
+		todo := &todos[i]
+
+		if strings.TrimSpace(todo.Title) == "" {
+			return CreateErrorResponse(errors.Wrap(ErrBadRequest, "titles must not be empty once variables are replaced"))
+		}
+
+		if err := prepareNotes(todo); err != nil {
+			return CreateErrorResponse(err)
+		}
+
+		todo.Stamp(nil, writeTime(nil))
+		batch[i] = todo
+	}
+
+	if err := database.SaveAll(h.todos, batch); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse(todos)
+}
+
+// parseTemplate parses and validates a template, normalizing the tags of its items
+func parseTemplate(body string) (internal.Template, error) {
+
+	var t internal.Template
+
+	if len(body) > maxTemplateSize {
+		return t, errors.Wrapf(ErrBadRequest, "templates must be at most %d bytes", maxTemplateSize)
+	}
+
+	if err := json.Unmarshal([]byte(body), &t); err != nil {
+		return t, errors.Wrap(ErrBadRequest, "body is not valid JSON")
+	}
+
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return t, errors.Wrap(ErrBadRequest, "name is required")
+	}
+
+	if len(t.Items) == 0 {
+		return t, errors.Wrap(ErrBadRequest, "a template must have at least one item")
+	}
+
+	count := 0
+	var err error
+
+	t.Walk(func(item *internal.TemplateItem, depth int) {
+		count++
+
+		switch {
+		case err != nil:
+		case count > maxTemplateItems:
+			err = errors.Wrapf(ErrBadRequest, "a template can have at most %d items", maxTemplateItems)
+		case depth > maxTemplateDepth:
+			err = errors.Wrapf(ErrBadRequest, "a template can have at most %d levels of subtasks", maxTemplateDepth-1)
+		case strings.TrimSpace(item.Title) == "":
+			err = errors.Wrap(ErrBadRequest, "every item must have a title")
+		case len(item.Subtasks) > maxBlockers:
+			err = errors.Wrapf(ErrBadRequest, "an item can have at most %d subtasks", maxBlockers)
+		case item.DueOffsetDays != nil && (*item.DueOffsetDays > maxDueOffsetDays || *item.DueOffsetDays < -maxDueOffsetDays):
+			err = errors.Wrapf(ErrBadRequest, "items must be due within %d days of the anchor", maxDueOffsetDays)
+		default:
+			// Items are checked as the ToDos they become, except for variables that may lengthen their notes
+			todo := internal.ToDo{Notes: item.Notes, Tags: item.Tags}
+			if err = prepareNotes(&todo); err == nil {
+				err = prepareTags(&todo)
+			}
+			item.Tags = todo.Tags
+		}
+	})
+
+	return t, err
+}
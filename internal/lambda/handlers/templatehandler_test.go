@@ -0,0 +1,336 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+func TestTemplateHandler(t *testing.T) {
+	t.Run("PostTemplateOK", testPostTemplateOK)
+	t.Run("PostTemplateInvalid", testPostTemplateInvalid)
+	t.Run("GetTemplateOtherUser", testGetTemplateOtherUser)
+	t.Run("PutTemplateOK", testPutTemplateOK)
+	t.Run("DeleteTemplateOK", testDeleteTemplateOK)
+	t.Run("InstantiateOK", testInstantiateOK)
+	t.Run("InstantiateMissingVariables", testInstantiateMissingVariables)
+	t.Run("InstantiateMissingAnchor", testInstantiateMissingAnchor)
+}
+
+// memoryTemplates returns a TemplateRepoMock that keeps templates in a map
+func memoryTemplates(templates ...internal.Template) (*TemplateRepoMock, map[string]internal.Template) {
+	m := make(map[string]internal.Template)
+	for _, t := range templates {
+		m[t.ID] = t
+	}
+
+	return &TemplateRepoMock{
+		GetFn: func(userID, id string) (*internal.Template, error) {
+			if t, ok := m[id]; ok && t.UserID == userID {
+				return &t, nil
+			}
+			return nil, nil
+		},
+		GetByUserFn: func(userID string) ([]internal.Template, error) {
+			all := []internal.Template{}
+			for _, t := range m {
+				if t.UserID == userID {
+					all = append(all, t)
+				}
+			}
+			return all, nil
+		},
+		SaveFn: func(t *internal.Template) error {
+			t.ModTime = time.Now()
+			m[t.ID] = *t
+			return nil
+		},
+		DeleteFn: func(userID, id string) error {
+			delete(m, id)
+			return nil
+		},
+	}, m
+}
+
+// releaseChecklist returns a Template of user-1 with a release, which is blocked by tagging it, and an
+// announcement
+func releaseChecklist() internal.Template {
+	zero, before, after := 0, -1, 2
+
+	return internal.Template{
+		ID:     "t1",
+		UserID: "user-1",
+		Name:   "Release",
+		Items: []internal.TemplateItem{
+			{
+				Title:         "Release {{version}}",
+				Tags:          []string{"release"},
+				DueOffsetDays: &zero,
+				Subtasks:      []internal.TemplateItem{{Title: "Tag {{version}}", DueOffsetDays: &before}},
+			},
+			{Title: "Announce {{version}}", Notes: "Thank {{contributors}}", DueOffsetDays: &after},
+		},
+	}
+}
+
+func templateRequest(method, resource, id, body, userID string) events.APIGatewayProxyRequest {
+	return authorized(events.APIGatewayProxyRequest{
+		Resource:       resource,
+		HTTPMethod:     method,
+		PathParameters: map[string]string{"id": id},
+		Body:           body,
+	}, userID)
+}
+
+func testPostTemplateOK(t *testing.T) {
+
+	templates, m := memoryTemplates()
+	repo, _ := memoryRepo()
+
+	body := `{"name":" Release ","items":[{"title":"Release {{version}}","tags":[" Release ","release"],
+		"subtasks":[{"title":"Tag {{version}}","dueOffsetDays":-1}]}]}`
+
+	resp, err := handlers.NewTemplateHandler(templates, repo).Handle(templateRequest("POST", "/templates", "", body, "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	var got internal.Template
+	if err := json.Unmarshal([]byte(resp.Body), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.ID == "" || got.UserID != "user-1" || got.Name != "Release" {
+		t.Fatalf("Unexpected Template %+v", got)
+	}
+
+	if len(got.Items[0].Tags) != 1 || got.Items[0].Tags[0] != "release" {
+		t.Fatalf("Expected the tags to be normalized, got %v", got.Items[0].Tags)
+	}
+
+	if _, ok := m[got.ID]; !ok {
+		t.Fatal("Expected the template to be saved")
+	}
+}
+
+func testPostTemplateInvalid(t *testing.T) {
+
+	deep := `{"title":"5"}`
+	for i := 4; i > 0; i-- {
+		deep = `{"title":"` + string(rune('0'+i)) + `","subtasks":[` + deep + `]}`
+	}
+	deep = `{"title":"0","subtasks":[` + deep + `]}`
+
+	bodies := []string{
+		`not json`,
+		`{"items":[{"title":"Release"}]}`,
+		`{"name":"Release","items":[]}`,
+		`{"name":"Release","items":[{"title":" "}]}`,
+		`{"name":"Release","items":[{"title":"Release","subtasks":[{"title":""}]}]}`,
+		`{"name":"Release","items":[{"title":"Release","dueOffsetDays":4000}]}`,
+		`{"name":"Release","items":[{"title":"Release","tags":["` + strings.Repeat("a", 65) + `"]}]}`,
+		`{"name":"Release","items":[{"title":"Release","notes":"` + strings.Repeat("a", 64*1024+1) + `"}]}`,
+		`{"name":"Release","items":[` + deep + `]}`,
+		`{"id":"t1","name":"Release","items":[{"title":"Release"}]}`,
+	}
+
+	for _, body := range bodies {
+		templates, _ := memoryTemplates()
+		repo, _ := memoryRepo()
+
+		resp, err := handlers.NewTemplateHandler(templates, repo).Handle(templateRequest("POST", "/templates", "", body, "user-1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expected %d for %.80s, got %d", http.StatusBadRequest, body, resp.StatusCode)
+		}
+
+		if templates.SaveInvoked {
+			t.Fatalf("Expected %.80s not to be saved", body)
+		}
+	}
+}
+
+func testGetTemplateOtherUser(t *testing.T) {
+
+	templates, _ := memoryTemplates(releaseChecklist())
+	repo, _ := memoryRepo()
+
+	resp, err := handlers.NewTemplateHandler(templates, repo).Handle(templateRequest("GET", "/templates/{id}", "t1", "", "user-2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func testPutTemplateOK(t *testing.T) {
+
+	tmpl := releaseChecklist()
+	tmpl.Created = time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	templates, m := memoryTemplates(tmpl)
+	repo, _ := memoryRepo()
+
+	body := `{"name":"Hotfix","items":[{"title":"Patch {{version}}"}]}`
+
+	resp, err := handlers.NewTemplateHandler(templates, repo).Handle(templateRequest("PUT", "/templates/{id}", "t1", body, "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	got := m["t1"]
+	if got.Name != "Hotfix" || len(got.Items) != 1 || got.UserID != "user-1" || !got.Created.Equal(tmpl.Created) {
+		t.Fatalf("Unexpected Template %+v", got)
+	}
+
+	body = `{"id":"t2","name":"Hotfix","items":[{"title":"Patch"}]}`
+
+	resp, err = handlers.NewTemplateHandler(templates, repo).Handle(templateRequest("PUT", "/templates/{id}", "t1", body, "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func testDeleteTemplateOK(t *testing.T) {
+
+	templates, m := memoryTemplates(releaseChecklist())
+	repo, _ := memoryRepo()
+
+	resp, err := handlers.NewTemplateHandler(templates, repo).Handle(templateRequest("DELETE", "/templates/{id}", "t1", "", "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if _, ok := m["t1"]; ok {
+		t.Fatal("Expected the template to be deleted")
+	}
+}
+
+func testInstantiateOK(t *testing.T) {
+
+	templates, _ := memoryTemplates(releaseChecklist())
+	repo, saved := memoryRepo()
+
+	body := `{"anchor":"2019-07-10","timeZone":"Europe/Berlin","variables":{"version":"1.4","contributors":"everyone"}}`
+
+	resp, err := handlers.NewTemplateHandler(templates, repo).Handle(
+		templateRequest("POST", "/templates/{id}/instantiate", "t1", body, "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	var todos []internal.ToDo
+	if err := json.Unmarshal([]byte(resp.Body), &todos); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(todos) != 3 || len(saved) != 3 {
+		t.Fatalf("Expected 3 ToDos to be created, got %d and %d saved", len(todos), len(saved))
+	}
+
+	release, tag, announce := saved[todos[0].ID], saved[todos[1].ID], saved[todos[2].ID]
+
+	if release.Title != "Release 1.4" || tag.Title != "Tag 1.4" || announce.Title != "Announce 1.4" ||
+		announce.Notes != "Thank everyone" {
+		t.Fatalf("Expected the variables to be replaced, got %q, %q, %q", release.Title, tag.Title, announce.Title)
+	}
+
+	if len(release.BlockedBy) != 1 || release.BlockedBy[0] != tag.ID {
+		t.Fatalf("Expected the release to be blocked by tagging it, got %v", release.BlockedBy)
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := time.Date(2019, 7, 9, 0, 0, 0, 0, berlin); tag.Due == nil || !tag.Due.Equal(want) {
+		t.Fatalf("Expected tagging to be due %s, got %v", want, tag.Due)
+	}
+
+	if want := time.Date(2019, 7, 12, 0, 0, 0, 0, berlin); announce.Due == nil || !announce.Due.Equal(want) {
+		t.Fatalf("Expected the announcement to be due %s, got %v", want, announce.Due)
+	}
+
+	if release.Tags[0] != "release" || release.ModTime.IsZero() {
+		t.Fatalf("Unexpected ToDo %+v", release)
+	}
+}
+
+func testInstantiateMissingVariables(t *testing.T) {
+
+	templates, _ := memoryTemplates(releaseChecklist())
+	repo, saved := memoryRepo()
+
+	body := `{"anchor":"2019-07-10","variables":{"version":"1.4"}}`
+
+	resp, err := handlers.NewTemplateHandler(templates, repo).Handle(
+		templateRequest("POST", "/templates/{id}/instantiate", "t1", body, "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(resp.Body, "contributors") {
+		t.Fatalf("Expected %d naming the missing variable, got %d: %s", http.StatusBadRequest, resp.StatusCode, resp.Body)
+	}
+
+	if len(saved) != 0 {
+		t.Fatal("Expected no ToDos to be created")
+	}
+}
+
+func testInstantiateMissingAnchor(t *testing.T) {
+
+	templates, _ := memoryTemplates(releaseChecklist())
+	repo, saved := memoryRepo()
+
+	for _, body := range []string{
+		`{"variables":{"version":"1.4","contributors":"everyone"}}`,
+		`{"anchor":"next week","variables":{"version":"1.4","contributors":"everyone"}}`,
+	} {
+		resp, err := handlers.NewTemplateHandler(templates, repo).Handle(
+			templateRequest("POST", "/templates/{id}/instantiate", "t1", body, "user-1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expected %d for %s, got %d", http.StatusBadRequest, body, resp.StatusCode)
+		}
+	}
+
+	if len(saved) != 0 {
+		t.Fatal("Expected no ToDos to be created")
+	}
+}
@@ -0,0 +1,41 @@
+package handlers_test
+
+import (
+	"github.com/benjaminbartels/todo/internal"
+)
+
+// TemplateRepoMock is used to mock a TemplateRepo
+type TemplateRepoMock struct {
+	GetFn            func(string, string) (*internal.Template, error)
+	GetByUserFn      func(string) ([]internal.Template, error)
+	SaveFn           func(*internal.Template) error
+	DeleteFn         func(string, string) error
+	GetInvoked       bool
+	GetByUserInvoked bool
+	SaveInvoked      bool
+	DeleteInvoked    bool
+}
+
+// Get returns a Template of a user by its ID
+func (m *TemplateRepoMock) Get(userID, id string) (*internal.Template, error) {
+	m.GetInvoked = true
+	return m.GetFn(userID, id)
+}
+
+// GetByUser returns the templates of a user
+func (m *TemplateRepoMock) GetByUser(userID string) ([]internal.Template, error) {
+	m.GetByUserInvoked = true
+	return m.GetByUserFn(userID)
+}
+
+// Save creates or updates a Template
+func (m *TemplateRepoMock) Save(template *internal.Template) error {
+	m.SaveInvoked = true
+	return m.SaveFn(template)
+}
+
+// Delete removes a Template
+func (m *TemplateRepoMock) Delete(userID, id string) error {
+	m.DeleteInvoked = true
+	return m.DeleteFn(userID, id)
+}
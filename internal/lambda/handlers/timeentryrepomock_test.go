@@ -0,0 +1,75 @@
+package handlers_test
+
+import (
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+// TimeEntryRepoMock is used to mock a TimeEntryRepo
+type TimeEntryRepoMock struct {
+	GetFn             func(string, string, string) (*internal.TimeEntry, error)
+	GetByToDoFn       func(string, string) ([]internal.TimeEntry, error)
+	GetByUserFn       func(string, time.Time, time.Time) ([]internal.TimeEntry, error)
+	GetRunningFn      func(string) (*internal.TimeEntry, error)
+	StartFn           func(*internal.TimeEntry) error
+	StopFn            func(*internal.TimeEntry) error
+	SaveFn            func(*internal.TimeEntry) error
+	DeleteFn          func(string, string, string) error
+	GetInvoked        bool
+	GetByToDoInvoked  bool
+	GetByUserInvoked  bool
+	GetRunningInvoked bool
+	StartInvoked      bool
+	StopInvoked       bool
+	SaveInvoked       bool
+	DeleteInvoked     bool
+}
+
+// Get returns a TimeEntry by its ID
+func (m *TimeEntryRepoMock) Get(listID, todoID, id string) (*internal.TimeEntry, error) {
+	m.GetInvoked = true
+	return m.GetFn(listID, todoID, id)
+}
+
+// GetByToDo returns the time entries on a ToDo
+func (m *TimeEntryRepoMock) GetByToDo(listID, todoID string) ([]internal.TimeEntry, error) {
+	m.GetByToDoInvoked = true
+	return m.GetByToDoFn(listID, todoID)
+}
+
+// GetByUser returns the time entries of a user that started in a period
+func (m *TimeEntryRepoMock) GetByUser(userID string, from, to time.Time) ([]internal.TimeEntry, error) {
+	m.GetByUserInvoked = true
+	return m.GetByUserFn(userID, from, to)
+}
+
+// GetRunning returns the running timer of a user
+func (m *TimeEntryRepoMock) GetRunning(userID string) (*internal.TimeEntry, error) {
+	m.GetRunningInvoked = true
+	return m.GetRunningFn(userID)
+}
+
+// Start saves a running timer
+func (m *TimeEntryRepoMock) Start(entry *internal.TimeEntry) error {
+	m.StartInvoked = true
+	return m.StartFn(entry)
+}
+
+// Stop saves a running timer once it has an end
+func (m *TimeEntryRepoMock) Stop(entry *internal.TimeEntry) error {
+	m.StopInvoked = true
+	return m.StopFn(entry)
+}
+
+// Save creates or updates a TimeEntry
+func (m *TimeEntryRepoMock) Save(entry *internal.TimeEntry) error {
+	m.SaveInvoked = true
+	return m.SaveFn(entry)
+}
+
+// Delete removes a TimeEntry
+func (m *TimeEntryRepoMock) Delete(listID, todoID, id string) error {
+	m.DeleteInvoked = true
+	return m.DeleteFn(listID, todoID, id)
+}
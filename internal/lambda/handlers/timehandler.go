@@ -0,0 +1,564 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/format"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// timerStartResource is the API Gateway resource for starting a timer on a ToDo
+	timerStartResource = "/todos/{id}/timer/start"
+	// timerStopResource is the API Gateway resource for stopping the timer running on a ToDo
+	timerStopResource = "/todos/{id}/timer/stop"
+	// timeEntriesResource is the API Gateway resource for listing and adding the time spent on a ToDo
+	timeEntriesResource = "/todos/{id}/time"
+	// timeEntryResource is the API Gateway resource for a time entry on a ToDo
+	timeEntryResource = "/todos/{id}/time/{entryId}"
+	// runningTimerResource is the API Gateway resource for the running timer of the caller
+	runningTimerResource = "/me/timer"
+	// timeReportResource is the API Gateway resource for reporting the time spent by the caller
+	timeReportResource = "/reports/time"
+	// maxTimeNoteLength is the longest note of a time entry, in bytes
+	maxTimeNoteLength = 1000
+	// maxTimeEntry is the longest time entry that can be added, rather than tracked with a timer
+	maxTimeEntry = 24 * time.Hour
+	// maxEstimateMinutes is the longest estimate of a ToDo, a year
+	maxEstimateMinutes = 365 * 24 * 60
+	// maxReportDays is the longest period a time report can cover
+	maxReportDays = 366
+	// dateLayout is the layout of the days in time reports, and of the dates accepted for from, to and
+	// the anchor of a template instance
+	dateLayout = "2006-01-02"
+)
+
+// Time report groupings
+const (
+	GroupByList = "list"
+	GroupByTag  = "tag"
+	GroupByDay  = "day"
+)
+
+// TimeEntryRequest is the body of a request to add or edit a time entry. Only Note is used to start a
+// timer.
+type TimeEntryRequest struct {
+	Start *time.Time `json:"start"`
+	End   *time.Time `json:"end"`
+	Note  string     `json:"note"`
+}
+
+// TimeReport is the time the caller spent in the time entries that started from From until To, grouped
+// by list, tag or day
+type TimeReport struct {
+	From         time.Time            `json:"from"`
+	To           time.Time            `json:"to"`
+	GroupBy      string               `json:"groupBy"`
+	Groups       []internal.TimeGroup `json:"groups"`
+	TotalSeconds int64                `json:"totalSeconds"`
+}
+
+// TimeHandler provides a handle method to handle incoming AWS API Gateway requests for the time spent on
+// ToDos. Each user can have one running timer, and time entries can only be edited and removed by their
+// user.
+type TimeHandler struct {
+	todos   database.ToDoRepo
+	entries database.TimeEntryRepo
+	lists   database.ToDoRepoProvider
+}
+
+// NewTimeHandler creates a new Time handler. The ToDos of entries in other lists are read from lists, to
+// group reports by tag.
+func NewTimeHandler(todos database.ToDoRepo, entries database.TimeEntryRepo, lists database.ToDoRepoProvider) *TimeHandler {
+	return &TimeHandler{
+		todos:   todos,
+		entries: entries,
+		lists:   lists,
+	}
+}
+
+// Handle handles a request from AWS API Gateway and returns a response
+func (h *TimeHandler) Handle(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	userID := callerID(req)
+	if userID == "" {
+		return CreateErrorResponse(ErrUnauthorized)
+	}
+
+	switch {
+	case req.Resource == timeReportResource && req.HTTPMethod == "GET":
+		return h.report(req, userID)
+	case req.Resource == runningTimerResource && req.HTTPMethod == "GET":
+		return h.running(userID)
+	case req.Resource == timeReportResource || req.Resource == runningTimerResource:
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+
+	id := req.PathParameters["id"]
+	if id == "" {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "ID is required"))
+	}
+
+	todo, err := h.todos.Get(id)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	} else if todo == nil {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	switch {
+	case req.Resource == timerStartResource && req.HTTPMethod == "POST":
+		return h.start(req, todo, userID)
+	case req.Resource == timerStopResource && req.HTTPMethod == "POST":
+		return h.stop(todo, userID)
+	case req.Resource == timeEntriesResource && req.HTTPMethod == "GET":
+		return h.getAll(todo)
+	case req.Resource == timeEntriesResource && req.HTTPMethod == "POST":
+		return h.post(req, todo, userID)
+	case req.Resource == timeEntryResource && req.HTTPMethod == "GET":
+		return h.get(req, todo)
+	case req.Resource == timeEntryResource && req.HTTPMethod == "PUT":
+		return h.put(req, todo, userID)
+	case req.Resource == timeEntryResource && req.HTTPMethod == "DELETE":
+		return h.delete(req, todo, userID)
+	default:
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+}
+
+// running returns the running timer of a user
+func (h *TimeHandler) running(userID string) (events.APIGatewayProxyResponse, error) {
+
+	e, err := h.entries.GetRunning(userID)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	} else if e == nil {
+		return CreateErrorResponse(errors.Wrap(ErrNotFound, "no timer is running"))
+	}
+
+	return CreateOKResponse(e)
+}
+
+// start starts a timer on a ToDo for userID, unless they already have a running timer
+func (h *TimeHandler) start(req events.APIGatewayProxyRequest, todo *internal.ToDo, userID string) (events.APIGatewayProxyResponse, error) {
+
+	var r TimeEntryRequest
+
+	if req.Body != "" {
+		if err := json.Unmarshal([]byte(req.Body), &r); err != nil {
+			return CreateErrorResponse(errors.Wrap(ErrBadRequest, "body is not valid JSON"))
+		}
+	}
+
+	note, err := parseTimeNote(r.Note)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	e := &internal.TimeEntry{
+		ID:     uuid.NewV4().String(),
+		ListID: todoListID(todo),
+		ToDoID: todo.ID,
+		UserID: userID,
+		Start:  time.Now().UTC().Truncate(time.Second),
+		Note:   note,
+	}
+
+	err = h.entries.Start(e)
+	if errors.Cause(err) == database.ErrConflict {
+		msg := "a timer is already running, stop it before starting another"
+		if running, err := h.entries.GetRunning(userID); err == nil && running != nil {
+			msg = fmt.Sprintf("a timer is already running on ToDo %s, stop it before starting another", running.ToDoID)
+		}
+		return CreateErrorResponse(errors.Wrap(ErrConflict, msg))
+	} else if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse(e)
+}
+
+// stop stops the timer userID has running on a ToDo
+func (h *TimeHandler) stop(todo *internal.ToDo, userID string) (events.APIGatewayProxyResponse, error) {
+
+	e, err := h.entries.GetRunning(userID)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	if e == nil || e.ListID != todoListID(todo) || e.ToDoID != todo.ID {
+		return CreateErrorResponse(errors.Wrap(ErrNotFound, "no timer is running on this ToDo"))
+	}
+
+	end := time.Now().UTC().Truncate(time.Second)
+	if end.Before(e.Start) {
+		end = e.Start
+	}
+	e.End = &end
+
+	err = h.entries.Stop(e)
+	if errors.Cause(err) == database.ErrConflict {
+		return CreateErrorResponse(errors.Wrap(ErrNotFound, "no timer is running on this ToDo"))
+	} else if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	h.track(todo)
+
+	return CreateOKResponse(e)
+}
+
+// getAll returns the time entries on a ToDo, oldest first
+func (h *TimeHandler) getAll(todo *internal.ToDo) (events.APIGatewayProxyResponse, error) {
+
+	entries, err := h.entries.GetByToDo(todoListID(todo), todo.ID)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse(entries)
+}
+
+// get returns a time entry
+func (h *TimeHandler) get(req events.APIGatewayProxyRequest, todo *internal.ToDo) (events.APIGatewayProxyResponse, error) {
+
+	e, err := h.entry(req, todo)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	return CreateOKResponse(e)
+}
+
+// post adds time userID spent on a ToDo without a timer
+func (h *TimeHandler) post(req events.APIGatewayProxyRequest, todo *internal.ToDo, userID string) (events.APIGatewayProxyResponse, error) {
+
+	r, err := parseTimeEntry(req.Body)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	e := &internal.TimeEntry{
+		ID:     uuid.NewV4().String(),
+		ListID: todoListID(todo),
+		ToDoID: todo.ID,
+		UserID: userID,
+		Start:  *r.Start,
+		End:    r.End,
+		Note:   r.Note,
+	}
+
+	if err := h.entries.Save(e); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	h.track(todo)
+
+	return CreateOKResponse(e)
+}
+
+// put edits a time entry. Only its user can edit it, once it is not running.
+func (h *TimeHandler) put(req events.APIGatewayProxyRequest, todo *internal.ToDo, userID string) (events.APIGatewayProxyResponse, error) {
+
+	e, err := h.entry(req, todo)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	if e.UserID != userID {
+		return CreateErrorResponse(errors.Wrap(ErrForbidden, "only its user can edit a time entry"))
+	}
+
+	if e.Running() {
+		return CreateErrorResponse(errors.Wrap(ErrConflict, "stop the timer before editing it"))
+	}
+
+	r, err := parseTimeEntry(req.Body)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	e.Start, e.End, e.Note = *r.Start, r.End, r.Note
+
+	if err := h.entries.Save(e); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	h.track(todo)
+
+	return CreateOKResponse(e)
+}
+
+// delete removes a time entry. Only its user can remove it, once it is not running.
+func (h *TimeHandler) delete(req events.APIGatewayProxyRequest, todo *internal.ToDo, userID string) (events.APIGatewayProxyResponse, error) {
+
+	e, err := h.entry(req, todo)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	if e.UserID != userID {
+		return CreateErrorResponse(errors.Wrap(ErrForbidden, "only its user can delete a time entry"))
+	}
+
+	if e.Running() {
+		return CreateErrorResponse(errors.Wrap(ErrConflict, "stop the timer before deleting it"))
+	}
+
+	if err := h.entries.Delete(todoListID(todo), todo.ID, e.ID); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	h.track(todo)
+
+	return CreateOKResponse("")
+}
+
+// entry returns the time entry in the path
+func (h *TimeHandler) entry(req events.APIGatewayProxyRequest, todo *internal.ToDo) (*internal.TimeEntry, error) {
+
+	e, err := h.entries.Get(todoListID(todo), todo.ID, req.PathParameters["entryId"])
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	if e == nil {
+		return nil, ErrNotFound
+	}
+
+	return e, nil
+}
+
+// track updates the time tracked on a ToDo. Failures are logged, as the entry has already been saved, and
+// the time is corrected when the next entry is changed.
+func (h *TimeHandler) track(todo *internal.ToDo) {
+
+	entries, err := h.entries.GetByToDo(todoListID(todo), todo.ID)
+	if err != nil {
+		log.Printf("Could not total the time tracked on ToDo %s: %v", todo.ID, err)
+		return
+	}
+
+	seconds := internal.TrackedSeconds(entries)
+	if seconds == todo.TrackedSeconds {
+		return
+	}
+
+	todo.TrackedSeconds = seconds
+
+	if err := h.todos.Save(todo); err != nil {
+		log.Printf("Could not save the time tracked on ToDo %s: %v", todo.ID, err)
+	}
+}
+
+// report responds with the time userID spent in the period given by the from and to parameters, grouped
+// by the groupBy parameter, as JSON or, if the format parameter is csv, CSV. Days are in the IANA time
+// zone in the timeZone parameter, UTC if it is not set. Entries on ToDos without tags, or that were deleted,
+// are grouped under an empty tag.
+func (h *TimeHandler) report(req events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
+
+	q := req.QueryStringParameters
+
+	groupBy := q["groupBy"]
+	if groupBy == "" {
+		groupBy = GroupByList
+	}
+
+	if groupBy != GroupByList && groupBy != GroupByTag && groupBy != GroupByDay {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "groupBy must be list, tag or day"))
+	}
+
+	csvFormat := false
+	switch q["format"] {
+	case "", "json":
+	case string(format.CSV):
+		csvFormat = true
+	default:
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "format must be json or csv"))
+	}
+
+	loc, err := time.LoadLocation(q["timeZone"])
+	if err != nil {
+		return CreateErrorResponse(errors.Wrapf(ErrBadRequest, "time zone %s is not valid", q["timeZone"]))
+	}
+
+	from, err := parseDateTime(q["from"], loc, false)
+	if err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "from must be a date or an RFC 3339 time"))
+	}
+
+	to, err := parseDateTime(q["to"], loc, true)
+	if err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "to must be a date or an RFC 3339 time"))
+	}
+
+	if !to.After(from) || to.Sub(from) > maxReportDays*24*time.Hour {
+		return CreateErrorResponse(errors.Wrapf(ErrBadRequest, "to must be after from, by at most %d days", maxReportDays))
+	}
+
+	entries, err := h.entries.GetByUser(userID, from, to)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	var keys func(internal.TimeEntry) []string
+
+	switch groupBy {
+	case GroupByList:
+		keys = func(e internal.TimeEntry) []string { return []string{e.ListID} }
+	case GroupByDay:
+		keys = func(e internal.TimeEntry) []string { return []string{e.Start.In(loc).Format(dateLayout)} }
+	case GroupByTag:
+		tags, err := h.tags(entries)
+		if err != nil {
+			return CreateErrorResponse(repoError(err))
+		}
+		keys = func(e internal.TimeEntry) []string { return tags[e.ListID+"/"+e.ToDoID] }
+	}
+
+	now := time.Now()
+
+	r := TimeReport{
+		From:    from,
+		To:      to,
+		GroupBy: groupBy,
+		Groups:  internal.GroupTime(entries, now, keys),
+	}
+
+	for _, e := range entries {
+		r.TotalSeconds += int64(e.Duration(now) / time.Second)
+	}
+
+	if !csvFormat {
+		return CreateOKResponse(r)
+	}
+
+	var b bytes.Buffer
+
+	w := csv.NewWriter(&b)
+	w.Write([]string{groupBy, "entries", "seconds", "hours"})
+	for _, g := range r.Groups {
+		w.Write([]string{g.Key, strconv.Itoa(g.Entries), strconv.FormatInt(g.Seconds, 10),
+			strconv.FormatFloat(float64(g.Seconds)/3600, 'f', 2, 64)})
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return CreateErrorResponse(ErrInternal)
+	}
+
+	resp, err := CreateOKResponse(RawBody{ContentType: format.CSV.ContentType(), Body: b.Bytes()})
+	resp.Headers["Content-Disposition"] = fmt.Sprintf(`attachment; filename="%s"`, format.CSV.Filename("time"))
+
+	return resp, err
+}
+
+// tags returns the tags of the ToDos of entries, keyed by list and ToDo ID. ToDos without tags, or that
+// were deleted, have an empty tag.
+func (h *TimeHandler) tags(entries []internal.TimeEntry) (map[string][]string, error) {
+
+	tags := make(map[string][]string)
+
+	for _, e := range entries {
+		k := e.ListID + "/" + e.ToDoID
+		if _, ok := tags[k]; ok {
+			continue
+		}
+
+		tags[k] = []string{""}
+
+		repo := h.lists(e.ListID)
+		if repo == nil {
+			continue
+		}
+
+		todo, err := repo.Get(e.ToDoID)
+		if err != nil {
+			return nil, err
+		}
+
+		if todo != nil && len(todo.Tags) > 0 {
+			tags[k] = todo.Tags
+		}
+	}
+
+	return tags, nil
+}
+
+// parseDateTime parses a date in loc, which starts at midnight, or an RFC 3339 time. A date that ends a
+// period, such as the to of a report, includes the whole day.
+func parseDateTime(v string, loc *time.Location, to bool) (time.Time, error) {
+
+	if t, err := time.ParseInLocation(dateLayout, v, loc); err == nil {
+		if to {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, v)
+}
+
+// parseTimeEntry returns the body of a request to add or edit a time entry if it is valid
+func parseTimeEntry(body string) (TimeEntryRequest, error) {
+
+	var r TimeEntryRequest
+
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		return r, errors.Wrap(ErrBadRequest, "body is not valid JSON")
+	}
+
+	if r.Start == nil || r.End == nil {
+		return r, errors.Wrap(ErrBadRequest, "start and end are required, use a timer to track time as it is spent")
+	}
+
+	start, end := r.Start.UTC().Truncate(time.Second), r.End.UTC().Truncate(time.Second)
+
+	if !end.After(start) || end.Sub(start) > maxTimeEntry {
+		return r, errors.Wrapf(ErrBadRequest, "end must be after start, by at most %s", maxTimeEntry)
+	}
+
+	if end.After(time.Now()) {
+		return r, errors.Wrap(ErrBadRequest, "end can not be in the future")
+	}
+
+	note, err := parseTimeNote(r.Note)
+	if err != nil {
+		return r, err
+	}
+
+	r.Start, r.End, r.Note = &start, &end, note
+
+	return r, nil
+}
+
+// parseTimeNote returns the trimmed note of a time entry if it is valid
+func parseTimeNote(note string) (string, error) {
+
+	note = strings.TrimSpace(note)
+
+	if len(note) > maxTimeNoteLength {
+		return "", errors.Wrapf(ErrBadRequest, "note must be at most %d bytes", maxTimeNoteLength)
+	}
+
+	return note, nil
+}
+
+// prepareEstimate checks the estimate of a ToDo that is about to be saved
+func prepareEstimate(todo *internal.ToDo) error {
+	if todo.EstimateMinutes < 0 || todo.EstimateMinutes > maxEstimateMinutes {
+		return errors.Wrapf(ErrBadRequest, "estimateMinutes must be 0 to %d", maxEstimateMinutes)
+	}
+	return nil
+}
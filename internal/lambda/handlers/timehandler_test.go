@@ -0,0 +1,541 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+func TestTimeHandler(t *testing.T) {
+	t.Run("StartAndStopTimer", testStartAndStopTimer)
+	t.Run("StartTimerUnauthorized", testStartTimerUnauthorized)
+	t.Run("PostTimeEntryOK", testPostTimeEntryOK)
+	t.Run("PostTimeEntryInvalid", testPostTimeEntryInvalid)
+	t.Run("EditTimeEntryNotUser", testEditTimeEntryNotUser)
+	t.Run("EditRunningTimer", testEditRunningTimer)
+	t.Run("DeleteTimeEntryOK", testDeleteTimeEntryOK)
+	t.Run("TimeReportByList", testTimeReportByList)
+	t.Run("TimeReportByTag", testTimeReportByTag)
+	t.Run("TimeReportByDayCSV", testTimeReportByDayCSV)
+	t.Run("TimeReportBadRequest", testTimeReportBadRequest)
+	t.Run("EstimateInvalid", testEstimateInvalid)
+	t.Run("PutKeepsTrackedTime", testPutKeepsTrackedTime)
+}
+
+// memoryTime returns a TimeEntryRepoMock that keeps time entries in a map, and the running timer of each
+// user in another
+func memoryTime(entries ...internal.TimeEntry) (*TimeEntryRepoMock, map[string]internal.TimeEntry) {
+	m := make(map[string]internal.TimeEntry)
+	running := make(map[string]string)
+
+	for _, e := range entries {
+		m[e.ID] = e
+		if e.Running() {
+			running[e.UserID] = e.ID
+		}
+	}
+
+	return &TimeEntryRepoMock{
+		GetFn: func(listID, todoID, id string) (*internal.TimeEntry, error) {
+			if e, ok := m[id]; ok && e.ListID == listID && e.ToDoID == todoID {
+				return &e, nil
+			}
+			return nil, nil
+		},
+		GetByToDoFn: func(listID, todoID string) ([]internal.TimeEntry, error) {
+			var all []internal.TimeEntry
+			for _, e := range m {
+				if e.ListID == listID && e.ToDoID == todoID {
+					all = append(all, e)
+				}
+			}
+			return all, nil
+		},
+		GetByUserFn: func(userID string, from, to time.Time) ([]internal.TimeEntry, error) {
+			var all []internal.TimeEntry
+			for _, e := range m {
+				if e.UserID == userID && !e.Start.Before(from) && e.Start.Before(to) {
+					all = append(all, e)
+				}
+			}
+			return all, nil
+		},
+		GetRunningFn: func(userID string) (*internal.TimeEntry, error) {
+			if id, ok := running[userID]; ok {
+				e := m[id]
+				return &e, nil
+			}
+			return nil, nil
+		},
+		StartFn: func(e *internal.TimeEntry) error {
+			if _, ok := running[e.UserID]; ok {
+				return database.ErrConflict
+			}
+			running[e.UserID] = e.ID
+			m[e.ID] = *e
+			return nil
+		},
+		StopFn: func(e *internal.TimeEntry) error {
+			if running[e.UserID] != e.ID {
+				return database.ErrConflict
+			}
+			delete(running, e.UserID)
+			m[e.ID] = *e
+			return nil
+		},
+		SaveFn: func(e *internal.TimeEntry) error {
+			m[e.ID] = *e
+			return nil
+		},
+		DeleteFn: func(listID, todoID, id string) error {
+			delete(m, id)
+			return nil
+		},
+	}, m
+}
+
+// todoLists returns a ToDoRepoProvider with repo as every list
+func todoLists(repo database.ToDoRepo) database.ToDoRepoProvider {
+	return func(string) database.ToDoRepo {
+		return repo
+	}
+}
+
+func timeRequest(method, resource, todoID, body, userID string) events.APIGatewayProxyRequest {
+	return authorized(events.APIGatewayProxyRequest{
+		Resource:       resource,
+		HTTPMethod:     method,
+		PathParameters: map[string]string{"id": todoID},
+		Body:           body,
+	}, userID)
+}
+
+// timeEntry returns a finished TimeEntry of user-1 on a ToDo in the default list
+func timeEntry(id, todoID string, start time.Time, d time.Duration) internal.TimeEntry {
+	end := start.Add(d)
+	return internal.TimeEntry{ID: id, ListID: internal.DefaultListID, ToDoID: todoID, UserID: "user-1", Start: start, End: &end}
+}
+
+var billable = time.Date(2019, 7, 1, 9, 0, 0, 0, time.UTC)
+
+func testStartAndStopTimer(t *testing.T) {
+
+	repo, _ := memoryRepo(internal.ToDo{ID: "1", Title: "Write report"}, internal.ToDo{ID: "2", Title: "Review"})
+	entries, saved := memoryTime()
+	h := handlers.NewTimeHandler(repo, entries, todoLists(repo))
+
+	resp, err := h.Handle(timeRequest(http.MethodPost, "/todos/{id}/timer/start", "1", `{"note":" drafting "}`, "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var started internal.TimeEntry
+	if err := json.Unmarshal([]byte(resp.Body), &started); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || !started.Running() || started.Note != "drafting" || started.ToDoID != "1" {
+		t.Fatalf("Expected a running timer, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	resp, err = h.Handle(timeRequest(http.MethodPost, "/todos/{id}/timer/start", "2", "", "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusConflict || !strings.Contains(resp.Body, "ToDo 1") {
+		t.Fatalf("Expected %d naming the running ToDo, got %d: %s", http.StatusConflict, resp.StatusCode, resp.Body)
+	}
+
+	resp, err = h.Handle(timeRequest(http.MethodGet, "/me/timer", "", "", "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Body, started.ID) {
+		t.Fatalf("Expected the running timer, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	resp, err = h.Handle(timeRequest(http.MethodPost, "/todos/{id}/timer/stop", "2", "", "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d stopping a ToDo without a timer, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+	resp, err = h.Handle(timeRequest(http.MethodPost, "/todos/{id}/timer/stop", "1", "", "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || saved[started.ID].Running() {
+		t.Fatalf("Expected the timer to be stopped, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	resp, err = h.Handle(timeRequest(http.MethodGet, "/me/timer", "", "", "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func testStartTimerUnauthorized(t *testing.T) {
+
+	repo, _ := memoryRepo(internal.ToDo{ID: "1"})
+	entries, _ := memoryTime()
+
+	req := timeRequest(http.MethodPost, "/todos/{id}/timer/start", "1", "", "")
+	req.RequestContext.Authorizer = nil
+
+	resp, err := handlers.NewTimeHandler(repo, entries, todoLists(repo)).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized || entries.StartInvoked {
+		t.Fatalf("Expected %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
+
+func testPostTimeEntryOK(t *testing.T) {
+
+	repo, todos := memoryRepo(internal.ToDo{ID: "1", Title: "Write report", EstimateMinutes: 120})
+	entries, saved := memoryTime(timeEntry("e1", "1", billable, 30*time.Minute))
+
+	body := `{"start":"2019-07-02T09:00:00Z","end":"2019-07-02T10:30:00Z","note":"review"}`
+
+	resp, err := handlers.NewTimeHandler(repo, entries, todoLists(repo)).Handle(
+		timeRequest(http.MethodPost, "/todos/{id}/time", "1", body, "user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || len(saved) != 2 {
+		t.Fatalf("Expected the entry to be saved, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	if got := todos["1"].TrackedSeconds; got != 2*60*60 {
+		t.Fatalf("Expected 7200 tracked seconds, got %d", got)
+	}
+}
+
+func testPostTimeEntryInvalid(t *testing.T) {
+
+	repo, _ := memoryRepo(internal.ToDo{ID: "1"})
+	entries, _ := memoryTime()
+	h := handlers.NewTimeHandler(repo, entries, todoLists(repo))
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	bodies := []string{
+		`{"start":"2019-07-02T09:00:00Z"}`,
+		`{"start":"2019-07-02T09:00:00Z","end":"2019-07-02T08:00:00Z"}`,
+		`{"start":"2019-07-02T09:00:00Z","end":"2019-07-04T09:00:00Z"}`,
+		`{"start":"` + time.Now().UTC().Format(time.RFC3339) + `","end":"` + future + `"}`,
+		`{"start":"2019-07-02T09:00:00Z","end":"2019-07-02T10:00:00Z","note":"` + strings.Repeat("a", 1001) + `"}`,
+		`not json`,
+	}
+
+	for _, body := range bodies {
+		resp, err := h.Handle(timeRequest(http.MethodPost, "/todos/{id}/time", "1", body, "user-1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expected %d for %s, got %d", http.StatusBadRequest, body, resp.StatusCode)
+		}
+	}
+
+	if entries.SaveInvoked {
+		t.Fatal("Expected no entry to be saved")
+	}
+}
+
+func testEditTimeEntryNotUser(t *testing.T) {
+
+	repo, _ := memoryRepo(internal.ToDo{ID: "1"})
+	entries, _ := memoryTime(timeEntry("e1", "1", billable, time.Hour))
+
+	req := timeRequest(http.MethodPut, "/todos/{id}/time/{entryId}", "1",
+		`{"start":"2019-07-01T09:00:00Z","end":"2019-07-01T11:00:00Z"}`, "user-2")
+	req.PathParameters["entryId"] = "e1"
+
+	resp, err := handlers.NewTimeHandler(repo, entries, todoLists(repo)).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusForbidden || entries.SaveInvoked {
+		t.Fatalf("Expected %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+}
+
+func testEditRunningTimer(t *testing.T) {
+
+	repo, _ := memoryRepo(internal.ToDo{ID: "1"})
+	entries, _ := memoryTime(internal.TimeEntry{ID: "e1", ListID: internal.DefaultListID, ToDoID: "1", UserID: "user-1", Start: billable})
+
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		req := timeRequest(method, "/todos/{id}/time/{entryId}", "1",
+			`{"start":"2019-07-01T09:00:00Z","end":"2019-07-01T11:00:00Z"}`, "user-1")
+		req.PathParameters["entryId"] = "e1"
+
+		resp, err := handlers.NewTimeHandler(repo, entries, todoLists(repo)).Handle(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("Expected %d for %s, got %d", http.StatusConflict, method, resp.StatusCode)
+		}
+	}
+}
+
+func testDeleteTimeEntryOK(t *testing.T) {
+
+	todo := internal.ToDo{ID: "1", TrackedSeconds: 5400}
+	repo, todos := memoryRepo(todo)
+	entries, saved := memoryTime(timeEntry("e1", "1", billable, time.Hour), timeEntry("e2", "1", billable.Add(2*time.Hour), 30*time.Minute))
+
+	req := timeRequest(http.MethodDelete, "/todos/{id}/time/{entryId}", "1", "", "user-1")
+	req.PathParameters["entryId"] = "e1"
+
+	resp, err := handlers.NewTimeHandler(repo, entries, todoLists(repo)).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if _, ok := saved["e1"]; ok {
+		t.Fatal("Expected the entry to be deleted")
+	}
+
+	if got := todos["1"].TrackedSeconds; got != 1800 {
+		t.Fatalf("Expected 1800 tracked seconds, got %d", got)
+	}
+}
+
+// reportEntries returns a ToDoRepo and TimeEntryRepo holding time spent on tagged ToDos over two days
+func reportEntries() (*RepoMock, *TimeEntryRepoMock) {
+	repo, _ := memoryRepo(
+		internal.ToDo{ID: "1", Tags: []string{"infra", "ops"}},
+		internal.ToDo{ID: "2", Tags: []string{"docs"}},
+		internal.ToDo{ID: "3"},
+	)
+
+	work := timeEntry("e3", "3", billable.Add(26*time.Hour), 15*time.Minute)
+	work.ListID = "work"
+
+	entries, _ := memoryTime(
+		timeEntry("e1", "1", billable, time.Hour),
+		timeEntry("e2", "2", billable.Add(2*time.Hour), 30*time.Minute),
+		work,
+		timeEntry("e4", "1", billable.Add(24*time.Hour), time.Hour),
+	)
+
+	return repo, entries
+}
+
+// timeReport returns the report in the body of a response
+func timeReport(t *testing.T, resp events.APIGatewayProxyResponse) handlers.TimeReport {
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	var r handlers.TimeReport
+	if err := json.Unmarshal([]byte(resp.Body), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func reportRequest(query map[string]string) events.APIGatewayProxyRequest {
+	req := timeRequest(http.MethodGet, "/reports/time", "", "", "user-1")
+	req.QueryStringParameters = query
+	return req
+}
+
+func testTimeReportByList(t *testing.T) {
+
+	repo, entries := reportEntries()
+
+	resp, err := handlers.NewTimeHandler(repo, entries, todoLists(repo)).Handle(
+		reportRequest(map[string]string{"from": "2019-07-01", "to": "2019-07-01"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := timeReport(t, resp)
+
+	if r.GroupBy != handlers.GroupByList || r.TotalSeconds != 5400 || len(r.Groups) != 1 ||
+		r.Groups[0] != (internal.TimeGroup{Key: internal.DefaultListID, Seconds: 5400, Entries: 2}) {
+		t.Fatalf("Expected the first day only, got %+v", r)
+	}
+
+	resp, err = handlers.NewTimeHandler(repo, entries, todoLists(repo)).Handle(
+		reportRequest(map[string]string{"from": "2019-07-01", "to": "2019-07-02", "groupBy": "list"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r = timeReport(t, resp)
+
+	if r.TotalSeconds != 9000+900 || len(r.Groups) != 2 || r.Groups[1].Key != "work" || r.Groups[1].Seconds != 900 {
+		t.Fatalf("Unexpected report %+v", r)
+	}
+}
+
+func testTimeReportByTag(t *testing.T) {
+
+	repo, entries := reportEntries()
+
+	lists := func(listID string) database.ToDoRepo {
+		if listID == "work" {
+			return nil
+		}
+		return repo
+	}
+
+	resp, err := handlers.NewTimeHandler(repo, entries, lists).Handle(
+		reportRequest(map[string]string{"from": "2019-07-01T00:00:00Z", "to": "2019-07-03T00:00:00Z", "groupBy": "tag"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := timeReport(t, resp)
+
+	want := []internal.TimeGroup{
+		{Key: "", Seconds: 900, Entries: 1},
+		{Key: "docs", Seconds: 1800, Entries: 1},
+		{Key: "infra", Seconds: 7200, Entries: 2},
+		{Key: "ops", Seconds: 7200, Entries: 2},
+	}
+
+	if len(r.Groups) != len(want) {
+		t.Fatalf("Expected %+v, got %+v", want, r.Groups)
+	}
+
+	for i := range want {
+		if r.Groups[i] != want[i] {
+			t.Fatalf("Expected %+v, got %+v", want, r.Groups)
+		}
+	}
+
+	// Entries with several tags are only counted once in the total
+	if r.TotalSeconds != 9000+900 {
+		t.Fatalf("Expected total 9900, got %d", r.TotalSeconds)
+	}
+}
+
+func testTimeReportByDayCSV(t *testing.T) {
+
+	repo, entries := reportEntries()
+
+	resp, err := handlers.NewTimeHandler(repo, entries, todoLists(repo)).Handle(reportRequest(map[string]string{
+		"from": "2019-07-01", "to": "2019-07-02", "groupBy": "day", "format": "csv", "timeZone": "America/Los_Angeles",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Headers["Content-Type"], "text/csv") {
+		t.Fatalf("Expected CSV, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	// Days start at 07:00 UTC in Los Angeles, so from and to cover the entries started at 09:00 UTC
+	want := "day,entries,seconds,hours\n2019-07-01,2,5400,1.50\n2019-07-02,2,4500,1.25\n"
+	if resp.Body != want {
+		t.Fatalf("Expected %q, got %q", want, resp.Body)
+	}
+}
+
+func testTimeReportBadRequest(t *testing.T) {
+
+	repo, entries := reportEntries()
+
+	queries := []map[string]string{
+		{"to": "2019-07-02"},
+		{"from": "2019-07-02", "to": "2019-07-01T00:00:00Z"},
+		{"from": "2019-07-01", "to": "2020-07-02"},
+		{"from": "2019-07-01", "to": "2019-07-02", "groupBy": "week"},
+		{"from": "2019-07-01", "to": "2019-07-02", "format": "xml"},
+		{"from": "2019-07-01", "to": "2019-07-02", "timeZone": "Mars/Olympus"},
+	}
+
+	for _, q := range queries {
+		resp, err := handlers.NewTimeHandler(repo, entries, todoLists(repo)).Handle(reportRequest(q))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expected %d for %v, got %d", http.StatusBadRequest, q, resp.StatusCode)
+		}
+	}
+
+	if entries.GetByUserInvoked {
+		t.Fatal("Expected no entries to be read")
+	}
+}
+
+func testEstimateInvalid(t *testing.T) {
+
+	repo, _ := memoryRepo()
+
+	req := events.APIGatewayProxyRequest{
+		Resource:   "/todos",
+		HTTPMethod: http.MethodPost,
+		Body:       `{"title":"Migrate","estimateMinutes":-30}`,
+	}
+
+	resp, err := handlers.NewToDoHandler(repo).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest || repo.SaveInvoked {
+		t.Fatalf("Expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func testPutKeepsTrackedTime(t *testing.T) {
+
+	repo, todos := memoryRepo(internal.ToDo{ID: "1", Title: "Migrate", TrackedSeconds: 3600})
+
+	req := events.APIGatewayProxyRequest{
+		Resource:       "/todos/{id}",
+		HTTPMethod:     http.MethodPut,
+		PathParameters: map[string]string{"id": "1"},
+		Body:           `{"id":"1","title":"Migrate","estimateMinutes":90,"trackedSeconds":60}`,
+	}
+
+	resp, err := handlers.NewToDoHandler(repo).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if got := todos["1"]; got.EstimateMinutes != 90 || got.TrackedSeconds != 3600 {
+		t.Fatalf("Expected the estimate to change and the tracked time to be kept, got %+v", got)
+	}
+}
@@ -1,29 +1,76 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/benjaminbartels/todo/internal"
 	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/format"
 	"github.com/pkg/errors"
 )
 
+const (
+	// exportResource is the API Gateway resource for exporting ToDos
+	exportResource = "/todos/export"
+	// importResource is the API Gateway resource for importing ToDos
+	importResource = "/todos/import"
+)
+
+// ImportResult reports the outcome of importing each row of a file
+type ImportResult struct {
+	DryRun  bool        `json:"dryRun"`
+	Created []ImportRow `json:"created"`
+	Skipped []ImportRow `json:"skipped"`
+	Errors  []ImportRow `json:"errors"`
+}
+
+// ImportRow is a row of an imported file
+type ImportRow struct {
+	Line   int            `json:"line"`
+	ToDo   *internal.ToDo `json:"todo,omitempty"`
+	Reason string         `json:"reason,omitempty"`
+}
+
 // ToDoHandler provides a handle method to handle incoming AWS API Gateway request
 type ToDoHandler struct {
-	repo database.ToDoRepo
+	repo    database.ToDoRepo
+	members database.MemberRepo
 }
 
-// NewToDoHandler creates a new ToDo handler
+// NewToDoHandler creates a new ToDo handler. ToDos can not be assigned, as lists have no members.
 func NewToDoHandler(repo database.ToDoRepo) *ToDoHandler {
+	return NewToDoHandlerWithMembers(repo, nil)
+}
+
+// NewToDoHandlerWithMembers creates a new ToDo handler that assigns ToDos to the members of their list
+func NewToDoHandlerWithMembers(repo database.ToDoRepo, members database.MemberRepo) *ToDoHandler {
 	return &ToDoHandler{
-		repo: repo,
+		repo:    repo,
+		members: members,
 	}
 }
 
 // Handle handles a request from AWS API Gateway and returns a response
 func (h *ToDoHandler) Handle(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 
+	if req.Resource == checklistResource || req.Resource == checklistItemResource {
+		return h.checklist(req)
+	}
+
+	if req.Resource == tagsResource || req.Resource == tagResource || req.Resource == tagsMergeResource {
+		return h.tags(req)
+	}
+
+	if req.Resource == blockersResource || req.Resource == blockerResource || req.Resource == graphResource {
+		return h.dependencies(req)
+	}
+
 	switch req.HTTPMethod {
 	case "GET":
 		return h.get(req)
@@ -31,6 +78,8 @@ func (h *ToDoHandler) Handle(req events.APIGatewayProxyRequest) (events.APIGatew
 		return h.post(req)
 	case "PUT":
 		return h.put(req)
+	case "PATCH":
+		return h.patch(req)
 	case "DELETE":
 		return h.delete(req)
 	default:
@@ -40,41 +89,124 @@ func (h *ToDoHandler) Handle(req events.APIGatewayProxyRequest) (events.APIGatew
 
 func (h *ToDoHandler) get(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 
+	if req.Resource == exportResource {
+		return h.export(req)
+	}
+
+	if req.Resource == changesResource {
+		return h.changes(req)
+	}
+
 	if id, ok := req.PathParameters["id"]; ok {
-		return h.getOne(id)
+		return h.getOne(req, id)
+	}
+
+	if tags, ok := req.MultiValueQueryStringParameters["tag"]; ok {
+		completed, err := parseCompleted(req)
+		if err != nil {
+			return CreateErrorResponse(err)
+		}
+		return h.getByTags(req, tags, req.QueryStringParameters["tagMode"], completed)
+	}
+
+	if completed, ok := req.QueryStringParameters["completed"]; ok {
+		return h.getByCompleted(req, completed)
 	}
 
-	return h.getAll()
+	return h.getAll(req)
 }
 
-func (h *ToDoHandler) getOne(id string) (events.APIGatewayProxyResponse, error) {
+func (h *ToDoHandler) getOne(req events.APIGatewayProxyRequest, id string) (events.APIGatewayProxyResponse, error) {
 
 	todo, err := h.repo.Get(id)
 	if err != nil {
-		return CreateErrorResponse(ErrInternal)
+		return CreateErrorResponse(repoError(err))
 	}
 
 	if todo == nil {
 		return CreateErrorResponse(ErrNotFound)
 	}
 
-	return CreateOKResponse(todo)
+	return respondToDo(req, todo)
 
 }
 
-func (h *ToDoHandler) getAll() (events.APIGatewayProxyResponse, error) {
+func (h *ToDoHandler) getAll(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 
 	todos, err := h.repo.GetAll()
 	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return respondToDos(req, todos)
+
+}
+
+func (h *ToDoHandler) getByCompleted(req events.APIGatewayProxyRequest, value string) (events.APIGatewayProxyResponse, error) {
+
+	completed, err := strconv.ParseBool(value)
+	if err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "completed must be true or false"))
+	}
+
+	var todos []internal.ToDo
+	err = database.ErrNotSupported
+
+	// Use the repository's index when it has one, otherwise filter all ToDos
+	if q, ok := h.repo.(database.ToDoQuerier); ok {
+		todos, err = q.GetByCompleted(completed)
+	}
+	if errors.Cause(err) == database.ErrNotSupported {
+		todos, err = h.repo.GetAll()
+	}
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	filtered := []internal.ToDo{}
+	for _, t := range todos {
+		if t.Completed == completed {
+			filtered = append(filtered, t)
+		}
+	}
+
+	return respondToDos(req, filtered)
+}
+
+func (h *ToDoHandler) export(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	f, err := format.Parse(req.QueryStringParameters["format"])
+	if err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, err.Error()))
+	}
+
+	todos, err := h.repo.GetAll()
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	var b bytes.Buffer
+
+	if err := format.Write(&b, f, todos); err != nil {
 		return CreateErrorResponse(ErrInternal)
 	}
 
-	return CreateOKResponse(todos)
+	r, err := CreateOKResponse(RawBody{ContentType: f.ContentType(), Body: b.Bytes()})
+	r.Headers["Content-Disposition"] = fmt.Sprintf(`attachment; filename="%s"`, f.Filename("todos"))
 
+	return r, err
 }
 
 func (h *ToDoHandler) post(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 
+	if req.Resource == importResource {
+		return h.importToDos(req)
+	}
+
+	if req.Resource == syncResource {
+		return h.sync(req)
+	}
+
 	todo, err := parseToDo(req.Body)
 	if err != nil {
 		return CreateErrorResponse(ErrInternal)
@@ -84,13 +216,105 @@ func (h *ToDoHandler) post(req events.APIGatewayProxyRequest) (events.APIGateway
 		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "ID must be empty"))
 	}
 
+	if err := h.prepare(nil, &todo, false); err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	keepManaged(nil, &todo)
+	todo.Stamp(nil, writeTime(nil))
+
 	err = h.repo.Save(&todo)
 	if err != nil {
-		return CreateErrorResponse(ErrInternal)
+		return CreateErrorResponse(repoError(err))
 	}
+
+	recur(h.repo, nil, &todo)
+
 	return CreateOKResponse(todo)
 }
 
+func (h *ToDoHandler) importToDos(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	f, err := format.Parse(req.QueryStringParameters["format"])
+	if err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, err.Error()))
+	}
+
+	dryRun := false
+	if v, ok := req.QueryStringParameters["dryRun"]; ok {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			return CreateErrorResponse(errors.Wrap(ErrBadRequest, "dryRun must be true or false"))
+		}
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	columns := make(map[string]string)
+	for _, field := range []string{"title", "notes", "completed", "due"} {
+		if name, ok := req.QueryStringParameters[field+"Column"]; ok {
+			columns[field] = name
+		}
+	}
+
+	rows, err := format.Read(bytes.NewReader(body), f, columns)
+	if err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, err.Error()))
+	}
+
+	existing, err := h.repo.GetAll()
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	titles := make(map[string]bool)
+	for _, t := range existing {
+		titles[normalizeTitle(t.Title)] = true
+	}
+
+	result := ImportResult{
+		DryRun:  dryRun,
+		Created: []ImportRow{},
+		Skipped: []ImportRow{},
+		Errors:  []ImportRow{},
+	}
+
+	todos := []*internal.ToDo{}
+
+	for i := range rows {
+		row := rows[i]
+		todo := &row.ToDo
+
+		switch {
+		case row.Err != nil:
+			result.Errors = append(result.Errors, ImportRow{Line: row.Line, Reason: row.Err.Error()})
+		case titles[normalizeTitle(todo.Title)]:
+			result.Skipped = append(result.Skipped, ImportRow{Line: row.Line, ToDo: todo, Reason: "duplicate title"})
+		default:
+			if err := h.prepare(nil, todo, false); err != nil {
+				result.Errors = append(result.Errors, ImportRow{Line: row.Line, Reason: err.Error()})
+				continue
+			}
+
+			keepManaged(nil, todo)
+			todo.Stamp(nil, writeTime(nil))
+			titles[normalizeTitle(todo.Title)] = true
+			todos = append(todos, todo)
+			result.Created = append(result.Created, ImportRow{Line: row.Line, ToDo: todo})
+		}
+	}
+
+	if !dryRun && len(todos) > 0 {
+		if err := database.SaveAll(h.repo, todos); err != nil {
+			return CreateErrorResponse(repoError(err))
+		}
+	}
+
+	return CreateOKResponse(result)
+}
+
 func (h *ToDoHandler) put(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 
 	id, ok := req.PathParameters["id"]
@@ -107,16 +331,77 @@ func (h *ToDoHandler) put(req events.APIGatewayProxyRequest) (events.APIGatewayP
 		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "ID in body does not match ID in path"))
 	}
 
-	if t, err := h.repo.Get(id); err != nil {
-		return CreateErrorResponse(ErrInternal)
+	t, err := h.repo.Get(id)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
 	} else if t == nil {
 		return CreateErrorResponse(ErrNotFound)
 	}
 
-	err = h.repo.Save(&todo)
+	return h.update(req, t, todo)
+}
+
+// patch applies the JSON merge patch (RFC 7386) in the body to a ToDo, then saves it as put does
+func (h *ToDoHandler) patch(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	id, ok := req.PathParameters["id"]
+	if !ok {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "ID is required"))
+	}
+
+	t, err := h.repo.Get(id)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	} else if t == nil {
+		return CreateErrorResponse(ErrNotFound)
+	}
+
+	current, err := json.Marshal(t)
 	if err != nil {
 		return CreateErrorResponse(ErrInternal)
 	}
+
+	patched, err := mergePatch(current, []byte(req.Body))
+	if err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "body is not a valid JSON merge patch"))
+	}
+
+	todo, err := parseToDo(string(patched))
+	if err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "patch does not result in a valid ToDo"))
+	}
+
+	if todo.ID != id {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "ID can not be changed"))
+	}
+
+	return h.update(req, t, todo)
+}
+
+// update replaces the ToDo t with todo. Completing a ToDo while ToDos that block it are open is refused
+// unless the request is forced.
+func (h *ToDoHandler) update(req events.APIGatewayProxyRequest, t *internal.ToDo, todo internal.ToDo) (events.APIGatewayProxyResponse, error) {
+
+	force, err := parseForce(req)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	if err := h.prepare(t, &todo, force); err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	keepManaged(t, &todo)
+
+	todo.Created = t.Created
+	todo.Stamp(t, writeTime(t))
+
+	if err := h.repo.Save(&todo); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	recur(h.repo, t, &todo)
+
 	return CreateOKResponse(todo)
 }
 
@@ -130,7 +415,7 @@ func (h *ToDoHandler) delete(req events.APIGatewayProxyRequest) (events.APIGatew
 
 	t, err := h.repo.Get(id)
 	if err != nil {
-		return CreateErrorResponse(ErrInternal)
+		return CreateErrorResponse(repoError(err))
 	}
 
 	if t == nil {
@@ -138,13 +423,107 @@ func (h *ToDoHandler) delete(req events.APIGatewayProxyRequest) (events.APIGatew
 	}
 
 	if err := h.repo.Delete(id); err != nil {
-		return CreateErrorResponse(ErrInternal)
+		return CreateErrorResponse(repoError(err))
 	}
 
 	return CreateOKResponse("")
 
 }
 
+// normalizeTitle returns the form of title used to detect duplicates
+func normalizeTitle(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
+
+// prepare checks and normalizes a ToDo that is about to be saved, by any request that writes it. previous
+// is nil when todo is created. Completing a ToDo while ToDos that block it are open is refused unless
+// force is set.
+func (h *ToDoHandler) prepare(previous, todo *internal.ToDo, force bool) error {
+	if err := prepareToDo(h.repo, previous, todo, force); err != nil {
+		return err
+	}
+
+	return h.prepareAssignees(previous, todo)
+}
+
+// prepareToDo checks and normalizes the fields of a ToDo that is about to be saved in repo, other than its
+// assignees, which can only be checked against the members of its list
+func prepareToDo(repo database.ToDoRepo, previous, todo *internal.ToDo, force bool) error {
+	if err := prepareChecklist(todo); err != nil {
+		return err
+	}
+
+	if err := prepareNotes(todo); err != nil {
+		return err
+	}
+
+	if err := prepareTags(todo); err != nil {
+		return err
+	}
+
+	if err := prepareEstimate(todo); err != nil {
+		return err
+	}
+
+	if err := prepareRecurrence(previous, todo); err != nil {
+		return err
+	}
+
+	// Checked last, as the checklist may complete the ToDo
+	return checkBlockers(repo, previous, todo, force)
+}
+
+// keepManaged replaces the fields of todo that are only changed through their own APIs, such as its
+// attachments, with those of previous, which is nil when todo is created. A new ToDo has no Created time
+// until it is saved, as repositories and the notifications of changes tell new ToDos apart by it.
+func keepManaged(previous, todo *internal.ToDo) {
+	if previous == nil {
+		todo.Attachments, todo.CommentCount, todo.BlockedBy, todo.TrackedSeconds = nil, 0, nil, 0
+		todo.Created = time.Time{}
+		return
+	}
+	todo.Attachments, todo.CommentCount, todo.BlockedBy = previous.Attachments, previous.CommentCount, previous.BlockedBy
+	todo.TrackedSeconds = previous.TrackedSeconds
+}
+
+// mergePatch returns doc with the JSON merge patch (RFC 7386) applied
+func mergePatch(doc, patch []byte) ([]byte, error) {
+	var d, p interface{}
+
+	if err := json.Unmarshal(doc, &d); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(applyMergePatch(d, p))
+}
+
+// applyMergePatch applies patch to target. Members of patch that are null remove those of target.
+func applyMergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = applyMergePatch(t[k], v)
+		}
+	}
+
+	return t
+}
+
 func parseToDo(body string) (internal.ToDo, error) {
 	var t internal.ToDo
 	err := json.Unmarshal([]byte(body), &t)
@@ -6,9 +6,14 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/database/attachments"
+	"github.com/benjaminbartels/todo/internal/database/cache"
+	"github.com/benjaminbartels/todo/internal/database/notify"
 	"github.com/benjaminbartels/todo/internal/lambda/handlers"
 	"github.com/pkg/errors"
 )
@@ -32,6 +37,7 @@ func TestToDoRepo(t *testing.T) {
 	t.Run("GetAllToDoInternalError", testGetAllToDoInternalError)
 	t.Run("CreateToDoOK", testCreateToDoOK)
 	t.Run("CreateToDoBadRequest", testCreateToDoBadRequest)
+	t.Run("CreateToDoIgnoresCreated", testCreateToDoIgnoresCreated)
 	t.Run("CreateToDoInternalErrorOnParse", testCreateToDoInternalErrorOnParse)
 	t.Run("CreateToDoInternalErrorOnSave", testCreateToDoInternalErrorOnSave)
 	t.Run("UpdateToDoOK", testUpdateToDoOK)
@@ -47,6 +53,18 @@ func TestToDoRepo(t *testing.T) {
 	t.Run("DeleteToDoInternalErrorOnGet", testDeleteToDoInternalErrorOnGet)
 	t.Run("DeleteToDoInternalErrorOnDelete", testDeleteToDoInternalErrorOnDelete)
 	t.Run("MethodNotAllowed", testMethodNotAllowed)
+	t.Run("GetAllToDoThrottled", testGetAllToDoThrottled)
+	t.Run("UpdateToDoUnavailable", testUpdateToDoUnavailable)
+	t.Run("GetCompletedToDoOK", testGetCompletedToDoOK)
+	t.Run("GetCompletedToDoBadRequest", testGetCompletedToDoBadRequest)
+	t.Run("GetCompletedToDoDecorated", testGetCompletedToDoDecorated)
+	t.Run("GetCompletedToDoNotSupported", testGetCompletedToDoNotSupported)
+	t.Run("ExportToDoOK", testExportToDoOK)
+	t.Run("ExportToDoBadRequest", testExportToDoBadRequest)
+	t.Run("ImportToDoOK", testImportToDoOK)
+	t.Run("ImportToDoDryRun", testImportToDoDryRun)
+	t.Run("ImportToDoBadRequest", testImportToDoBadRequest)
+	t.Run("ImportToDoInvalid", testImportToDoInvalid)
 }
 
 func testGetToDoOK(t *testing.T) {
@@ -240,6 +258,31 @@ func testCreateToDoOK(t *testing.T) {
 
 }
 
+func testCreateToDoIgnoresCreated(t *testing.T) {
+
+	var created time.Time
+
+	m := &RepoMock{
+		SaveFn: func(todo *internal.ToDo) error {
+			created = todo.Created
+			return nil
+		},
+	}
+
+	req := events.APIGatewayProxyRequest{
+		Body:       `{"title":"Backdated","created":"2019-01-01T00:00:00Z"}`,
+		HTTPMethod: http.MethodPost,
+	}
+
+	if _, err := handlers.NewToDoHandler(m).Handle(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if !created.IsZero() {
+		t.Fatalf("Expected Created to be left for the repo to set, got %v", created)
+	}
+}
+
 func testCreateToDoBadRequest(t *testing.T) {
 
 	m := &RepoMock{
@@ -821,7 +864,7 @@ func testMethodNotAllowed(t *testing.T) {
 
 	req := events.APIGatewayProxyRequest{
 		PathParameters: map[string]string{"id": testUUID},
-		HTTPMethod:     http.MethodPatch,
+		HTTPMethod:     http.MethodOptions,
 	}
 
 	resp, err := handlers.NewToDoHandler(m).Handle(req)
@@ -840,7 +883,394 @@ func testMethodNotAllowed(t *testing.T) {
 
 }
 
+func testGetAllToDoThrottled(t *testing.T) {
+
+	m := &RepoMock{
+		GetAllFn: func() ([]internal.ToDo, error) {
+			return nil, errors.Wrap(database.ErrThrottled, "Could not get ToDos from database")
+		},
+	}
+
+	req := events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodGet,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(resp.Body, handlers.ErrThrottled.Error()) {
+		t.Fatalf("Expected body to contain '%s'", handlers.ErrThrottled.Error())
+	}
+
+	if resp.Headers["Retry-After"] == "" {
+		t.Fatal("Expected Retry-After header")
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusTooManyRequests, resp.StatusCode)
+	}
+
+}
+
+func testUpdateToDoUnavailable(t *testing.T) {
+
+	m := &RepoMock{
+		GetFn: func(string) (*internal.ToDo, error) {
+			return &savedToDo, nil
+		},
+		SaveFn: func(*internal.ToDo) error {
+			return errors.Wrap(database.ErrUnavailable, "Could not save ToDo")
+		},
+	}
+
+	req := events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"id": testUUID},
+		Body:           toDoToString(&savedToDo),
+		HTTPMethod:     http.MethodPut,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Headers["Retry-After"] == "" {
+		t.Fatal("Expected Retry-After header")
+	}
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+}
+
+func testGetCompletedToDoOK(t *testing.T) {
+
+	m := &RepoMock{
+		GetAllFn: func() ([]internal.ToDo, error) {
+			return []internal.ToDo{savedToDo, {ID: "completed", Title: "Done", Completed: true}}, nil
+		},
+	}
+
+	req := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"completed": "true"},
+		HTTPMethod:            http.MethodGet,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(resp.Body, testUUID) || !strings.Contains(resp.Body, "completed") {
+		t.Fatalf("Expected body to contain only completed ToDos, got %s", resp.Body)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+}
+
+// decorate wraps repo in the decorators the todos function uses
+func decorate(repo database.ToDoRepo) database.ToDoRepo {
+	repo = cache.NewToDoRepo(repo, internal.DefaultListID, cache.NewMemoryCache(10), time.Minute)
+	repo = notify.NewToDoRepo(repo, notify.PublisherFunc(func(internal.Change) {}))
+	return attachments.NewToDoRepo(repo, &StoreMock{})
+}
+
+func testGetCompletedToDoDecorated(t *testing.T) {
+
+	m := &QuerierRepoMock{
+		RepoMock: &RepoMock{},
+		GetByCompletedFn: func(completed bool) ([]internal.ToDo, error) {
+			return []internal.ToDo{{ID: "completed", Title: "Done", Completed: completed}}, nil
+		},
+	}
+
+	req := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"completed": "true"},
+		HTTPMethod:            http.MethodGet,
+	}
+
+	resp, err := handlers.NewToDoHandler(decorate(m)).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Body, "completed") {
+		t.Fatalf("Expected the completed ToDo, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	if !m.GetByCompletedInvoked || m.GetAllInvoked {
+		t.Fatal("Expected the repository's index to be used through its decorators")
+	}
+}
+
+func testGetCompletedToDoNotSupported(t *testing.T) {
+
+	m := &RepoMock{
+		GetAllFn: func() ([]internal.ToDo, error) {
+			return []internal.ToDo{savedToDo, {ID: "completed", Title: "Done", Completed: true}}, nil
+		},
+	}
+
+	req := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"completed": "true"},
+		HTTPMethod:            http.MethodGet,
+	}
+
+	resp, err := handlers.NewToDoHandler(decorate(m)).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || strings.Contains(resp.Body, testUUID) || !m.GetAllInvoked {
+		t.Fatalf("Expected all ToDos to be filtered, got %d: %s", resp.StatusCode, resp.Body)
+	}
+}
+
+func testGetCompletedToDoBadRequest(t *testing.T) {
+
+	m := &RepoMock{}
+
+	req := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"completed": "maybe"},
+		HTTPMethod:            http.MethodGet,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.GetAllInvoked {
+		t.Fatal("GetAll invoked")
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+}
+
+func testExportToDoOK(t *testing.T) {
+
+	m := &RepoMock{
+		GetAllFn: func() ([]internal.ToDo, error) {
+			return []internal.ToDo{savedToDo, {ID: "completed", Title: "Done", Completed: true}}, nil
+		},
+	}
+
+	req := events.APIGatewayProxyRequest{
+		Resource:              "/todos/export",
+		QueryStringParameters: map[string]string{"format": "md"},
+		HTTPMethod:            http.MethodGet,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Body != "- [ ] Some ToDo\n- [x] Done\n" {
+		t.Fatalf("Unexpected body '%s'", resp.Body)
+	}
+
+	if resp.Headers["Content-Type"] != "text/markdown; charset=utf-8" {
+		t.Fatalf("Unexpected Content-Type '%s'", resp.Headers["Content-Type"])
+	}
+
+	if resp.Headers["Content-Disposition"] != `attachment; filename="todos.md"` {
+		t.Fatalf("Unexpected Content-Disposition '%s'", resp.Headers["Content-Disposition"])
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+}
+
+func testExportToDoBadRequest(t *testing.T) {
+
+	m := &RepoMock{}
+
+	req := events.APIGatewayProxyRequest{
+		Resource:              "/todos/export",
+		QueryStringParameters: map[string]string{"format": "pdf"},
+		HTTPMethod:            http.MethodGet,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.GetAllInvoked {
+		t.Fatal("GetAll invoked")
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+}
+
+func testImportToDoOK(t *testing.T) {
+
+	var saved []*internal.ToDo
+
+	m := &RepoMock{
+		GetAllFn: func() ([]internal.ToDo, error) {
+			return []internal.ToDo{savedToDo}, nil
+		},
+		SaveFn: func(todo *internal.ToDo) error {
+			todo.ID = fmt.Sprintf("id-%d", len(saved))
+			saved = append(saved, todo)
+			return nil
+		},
+	}
+
+	req := events.APIGatewayProxyRequest{
+		Resource:              "/todos/import",
+		QueryStringParameters: map[string]string{"format": "md"},
+		Body:                  "- [ ] some todo\n- [x] New ToDo\n- [ ] new todo\n",
+		HTTPMethod:            http.MethodPost,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var result handlers.ImportResult
+	if err := json.Unmarshal([]byte(resp.Body), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Created) != 1 || result.Created[0].Line != 2 || result.Created[0].ToDo.ID != "id-0" {
+		t.Fatalf("Expected line 2 to be created, got %+v", result.Created)
+	}
+
+	if len(result.Skipped) != 2 || result.Skipped[0].Line != 1 || result.Skipped[1].Line != 3 {
+		t.Fatalf("Expected lines 1 and 3 to be skipped as duplicates, got %+v", result.Skipped)
+	}
+
+	if len(saved) != 1 || !saved[0].Completed {
+		t.Fatalf("Expected 1 completed ToDo to be saved, got %v", saved)
+	}
+
+	if len(saved[0].Clocks) == 0 {
+		t.Fatal("Expected the fields of the imported ToDo to be stamped")
+	}
+
+}
+
+func testImportToDoDryRun(t *testing.T) {
+
+	m := &RepoMock{
+		GetAllFn: func() ([]internal.ToDo, error) {
+			return []internal.ToDo{}, nil
+		},
+	}
+
+	req := events.APIGatewayProxyRequest{
+		Resource:              "/todos/import",
+		QueryStringParameters: map[string]string{"format": "todotxt", "dryRun": "true"},
+		Body:                  "(A) Call the bank @Phone +finances\nx\n",
+		HTTPMethod:            http.MethodPost,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result handlers.ImportResult
+	if err := json.Unmarshal([]byte(resp.Body), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.DryRun || len(result.Created) != 1 || len(result.Errors) != 1 || result.Errors[0].Line != 2 {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+
+	if tags := result.Created[0].ToDo.Tags; len(tags) != 2 || tags[0] != "finances" || tags[1] != "phone" {
+		t.Fatalf("Expected the project and context to become tags, got %v", tags)
+	}
+
+	if m.SaveInvoked {
+		t.Fatal("Save invoked")
+	}
+
+}
+
+func testImportToDoBadRequest(t *testing.T) {
+
+	m := &RepoMock{}
+
+	req := events.APIGatewayProxyRequest{
+		Resource:              "/todos/import",
+		QueryStringParameters: map[string]string{"format": "csv"},
+		Body:                  "Owner,Done\nsam,true\n",
+		HTTPMethod:            http.MethodPost,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(resp.Body, "no title column") {
+		t.Fatalf("Expected body to contain '%s'", "no title column")
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+}
+
 func toDoToString(todo *internal.ToDo) string {
 	b, _ := json.Marshal(todo)
 	return string(b)
 }
+
+func testImportToDoInvalid(t *testing.T) {
+
+	m := &RepoMock{
+		GetAllFn: func() ([]internal.ToDo, error) {
+			return []internal.ToDo{}, nil
+		},
+		SaveFn: func(todo *internal.ToDo) error {
+			return nil
+		},
+	}
+
+	req := events.APIGatewayProxyRequest{
+		Resource:              "/todos/import",
+		QueryStringParameters: map[string]string{"format": "csv"},
+		Body:                  "Title,Notes\nShort,ok\nLong," + strings.Repeat("a", 64*1024+1) + "\n",
+		HTTPMethod:            http.MethodPost,
+	}
+
+	resp, err := handlers.NewToDoHandler(m).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result handlers.ImportResult
+	if err := json.Unmarshal([]byte(resp.Body), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Created) != 1 || len(result.Errors) != 1 || result.Errors[0].Line != 3 {
+		t.Fatalf("Expected line 3 to be rejected for its notes, got %+v", result)
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/domain"
+	"github.com/benjaminbartels/todo/internal/webhook"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// deliveriesResource is the API Gateway resource for the delivery log of a webhook
+	deliveriesResource = "/webhooks/{id}/deliveries"
+	// webhookSecretBytes is the number of random bytes in a generated webhook secret
+	webhookSecretBytes = 32
+)
+
+// eventNames are the events webhooks can subscribe to
+var eventNames = map[string]bool{
+	domain.CreatedEvent:    true,
+	domain.CompletedEvent:  true,
+	domain.ReopenedEvent:   true,
+	domain.RenamedEvent:    true,
+	domain.DeletedEvent:    true,
+	domain.MentionedEvent:  true,
+	domain.ReassignedEvent: true,
+}
+
+// WebhookHandler provides a handle method to handle incoming AWS API Gateway requests for managing
+// webhooks. Webhooks can only be added to a list by its members, and can only be seen and removed by the
+// user that created them.
+type WebhookHandler struct {
+	webhooks database.WebhookRepo
+	members  database.MemberRepo
+}
+
+// NewWebhookHandler creates a new Webhook handler. The members of lists are read from members.
+func NewWebhookHandler(webhooks database.WebhookRepo, members database.MemberRepo) *WebhookHandler {
+	return &WebhookHandler{
+		webhooks: webhooks,
+		members:  members,
+	}
+}
+
+// Handle handles a request from AWS API Gateway and returns a response
+func (h *WebhookHandler) Handle(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	userID := callerID(req)
+	if userID == "" {
+		return CreateErrorResponse(ErrUnauthorized)
+	}
+
+	switch req.HTTPMethod {
+	case "GET":
+		if req.Resource == deliveriesResource {
+			return h.deliveries(req, userID)
+		}
+		return h.get(req, userID)
+	case "POST":
+		return h.post(req, userID)
+	case "DELETE":
+		return h.delete(req, userID)
+	default:
+		return CreateErrorResponse(ErrMethodNotAllowed)
+	}
+}
+
+// owned returns the webhook in the path if it belongs to userID. Webhooks of other users are reported as
+// not found.
+func (h *WebhookHandler) owned(req events.APIGatewayProxyRequest, userID string) (*internal.Webhook, error) {
+
+	id := req.PathParameters["id"]
+	if id == "" {
+		return nil, errors.Wrap(ErrBadRequest, "id is required")
+	}
+
+	w, err := h.webhooks.Get(id)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	if w == nil || w.UserID != userID {
+		return nil, ErrNotFound
+	}
+
+	return w, nil
+}
+
+// get returns a webhook. Its secret is only sent when the webhook is created.
+func (h *WebhookHandler) get(req events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
+
+	w, err := h.owned(req, userID)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	w.Secret = ""
+
+	return CreateOKResponse(w)
+}
+
+// deliveries returns the delivery log of a webhook, most recent first
+func (h *WebhookHandler) deliveries(req events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
+
+	w, err := h.owned(req, userID)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	deliveries, err := h.webhooks.GetDeliveries(w.ID)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse(deliveries)
+}
+
+// post creates a webhook. A secret is generated if none is given. Its URL must not be to a host that is
+// not public, such as a loopback, private or link-local address.
+func (h *WebhookHandler) post(req events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
+
+	var body struct {
+		ListID string   `json:"listId"`
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+
+	if err := json.Unmarshal([]byte(req.Body), &body); err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "body is not valid JSON"))
+	}
+
+	if body.ListID == "" {
+		body.ListID = internal.DefaultListID
+	}
+
+	if !validListID(body.ListID) {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "invalid listId"))
+	}
+
+	if err := webhook.CheckURL(body.URL); err != nil {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, err.Error()))
+	}
+
+	for _, e := range body.Events {
+		if !eventNames[e] {
+			return CreateErrorResponse(errors.Wrapf(ErrBadRequest, "unknown event %s", e))
+		}
+	}
+
+	ok, err := isMember(h.members, body.ListID, userID)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	if !ok {
+		return CreateErrorResponse(errors.Wrap(ErrForbidden, "only members of a list can add webhooks to it"))
+	}
+
+	if body.Secret == "" {
+		b := make([]byte, webhookSecretBytes)
+		if _, err := rand.Read(b); err != nil {
+			return CreateErrorResponse(ErrInternal)
+		}
+		body.Secret = base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	w := &internal.Webhook{
+		ID:     uuid.NewV4().String(),
+		UserID: userID,
+		ListID: body.ListID,
+		URL:    body.URL,
+		Events: body.Events,
+		Secret: body.Secret,
+	}
+
+	if err := h.webhooks.Save(w); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse(w)
+}
+
+// delete removes a webhook
+func (h *WebhookHandler) delete(req events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
+
+	w, err := h.owned(req, userID)
+	if err != nil {
+		return CreateErrorResponse(err)
+	}
+
+	if err := h.webhooks.Delete(w.ID); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateOKResponse("")
+}
@@ -0,0 +1,331 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+var savedWebhook = internal.Webhook{
+	ID:     "hook-1",
+	UserID: "user-1",
+	ListID: "work",
+	URL:    "https://example.com/hook",
+	Secret: "secret",
+}
+
+func TestWebhookHandler(t *testing.T) {
+	t.Run("CreateWebhookOK", testCreateWebhookOK)
+	t.Run("CreateWebhookInvalid", testCreateWebhookInvalid)
+	t.Run("CreateWebhookUnauthorized", testCreateWebhookUnauthorized)
+	t.Run("CreateWebhookPrivateHost", testCreateWebhookPrivateHost)
+	t.Run("CreateWebhookNotMember", testCreateWebhookNotMember)
+	t.Run("GetWebhookOK", testGetWebhookOK)
+	t.Run("GetWebhookOtherUser", testGetWebhookOtherUser)
+	t.Run("GetDeliveriesOK", testGetDeliveriesOK)
+	t.Run("DeleteWebhookOK", testDeleteWebhookOK)
+}
+
+// webhookGetter returns a function that returns savedWebhook by its ID
+func webhookGetter(t *testing.T) func(string) (*internal.Webhook, error) {
+	return func(id string) (*internal.Webhook, error) {
+		if id != savedWebhook.ID {
+			t.Fatalf("Expected webhook %s, got %s", savedWebhook.ID, id)
+		}
+		w := savedWebhook
+		return &w, nil
+	}
+}
+
+// soleMember returns a MemberRepoMock in which user-1 is the only member of the work list, and other lists
+// have no members
+func soleMember() *MemberRepoMock {
+	return &MemberRepoMock{
+		GetByListFn: func(listID string) ([]internal.Member, error) {
+			if listID != "work" {
+				return nil, nil
+			}
+			return []internal.Member{{ListID: "work", UserID: "user-1", Handle: "ann"}}, nil
+		},
+	}
+}
+
+func testCreateWebhookOK(t *testing.T) {
+
+	var saved *internal.Webhook
+
+	m := &WebhookRepoMock{
+		SaveFn: func(w *internal.Webhook) error {
+			saved = w
+			return nil
+		},
+	}
+
+	req := authorized(events.APIGatewayProxyRequest{
+		Resource:   "/webhooks",
+		HTTPMethod: http.MethodPost,
+		Body:       `{"listId":"work","url":"https://example.com/hook","events":["Completed"]}`,
+	}, "user-1")
+
+	resp, err := handlers.NewWebhookHandler(m, soleMember()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, resp.StatusCode, resp.Body)
+	}
+
+	if saved == nil || saved.ID == "" || saved.UserID != "user-1" || saved.ListID != "work" || saved.Secret == "" {
+		t.Fatalf("Unexpected Webhook saved %+v", saved)
+	}
+
+	var got internal.Webhook
+	if err := json.Unmarshal([]byte(resp.Body), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Secret != saved.Secret {
+		t.Fatal("Expected generated secret to be returned when the webhook is created")
+	}
+}
+
+func testCreateWebhookInvalid(t *testing.T) {
+
+	bodies := []string{
+		`{"url":"ftp://example.com/hook"}`,
+		`{"url":"/hook"}`,
+		`{"url":"https://example.com/hook","events":["Exploded"]}`,
+		`{"listId":"_webhooks","url":"https://example.com/hook"}`,
+		`not json`,
+	}
+
+	for _, body := range bodies {
+		m := &WebhookRepoMock{}
+
+		req := authorized(events.APIGatewayProxyRequest{
+			Resource:   "/webhooks",
+			HTTPMethod: http.MethodPost,
+			Body:       body,
+		}, "user-1")
+
+		resp, err := handlers.NewWebhookHandler(m, soleMember()).Handle(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expected %d for %s, got %d", http.StatusBadRequest, body, resp.StatusCode)
+		}
+
+		if m.SaveInvoked {
+			t.Fatalf("Expected Save not to be invoked for %s", body)
+		}
+	}
+}
+
+func testCreateWebhookUnauthorized(t *testing.T) {
+
+	req := events.APIGatewayProxyRequest{
+		Resource:   "/webhooks",
+		HTTPMethod: http.MethodPost,
+		Body:       `{"url":"https://example.com/hook"}`,
+	}
+
+	resp, err := handlers.NewWebhookHandler(&WebhookRepoMock{}, &MemberRepoMock{}).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
+
+func testGetWebhookOK(t *testing.T) {
+
+	m := &WebhookRepoMock{GetFn: webhookGetter(t)}
+
+	req := authorized(events.APIGatewayProxyRequest{
+		Resource:       "/webhooks/{id}",
+		HTTPMethod:     http.MethodGet,
+		PathParameters: map[string]string{"id": savedWebhook.ID},
+	}, "user-1")
+
+	resp, err := handlers.NewWebhookHandler(m, soleMember()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got internal.Webhook
+	if err := json.Unmarshal([]byte(resp.Body), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.ID != savedWebhook.ID || got.URL != savedWebhook.URL {
+		t.Fatalf("Unexpected Webhook %+v", got)
+	}
+
+	if got.Secret != "" {
+		t.Fatal("Expected secret not to be returned")
+	}
+}
+
+func testGetWebhookOtherUser(t *testing.T) {
+
+	m := &WebhookRepoMock{GetFn: webhookGetter(t)}
+
+	for _, resource := range []string{"/webhooks/{id}", "/webhooks/{id}/deliveries"} {
+		req := authorized(events.APIGatewayProxyRequest{
+			Resource:       resource,
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"id": savedWebhook.ID},
+		}, "user-2")
+
+		resp, err := handlers.NewWebhookHandler(m, soleMember()).Handle(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("Expected %d for %s, got %d", http.StatusNotFound, resource, resp.StatusCode)
+		}
+	}
+
+	if m.GetDeliveriesInvoked {
+		t.Fatal("Expected GetDeliveries not to be invoked")
+	}
+}
+
+func testGetDeliveriesOK(t *testing.T) {
+
+	m := &WebhookRepoMock{
+		GetFn: webhookGetter(t),
+		GetDeliveriesFn: func(webhookID string) ([]internal.Delivery, error) {
+			return []internal.Delivery{
+				{ID: "e2:Completed", WebhookID: webhookID, Status: internal.DeliveryDeadLettered, Attempts: 5},
+				{ID: "e1:Created", WebhookID: webhookID, Status: internal.DeliverySucceeded, Attempts: 1},
+			}, nil
+		},
+	}
+
+	req := authorized(events.APIGatewayProxyRequest{
+		Resource:       "/webhooks/{id}/deliveries",
+		HTTPMethod:     http.MethodGet,
+		PathParameters: map[string]string{"id": savedWebhook.ID},
+	}, "user-1")
+
+	resp, err := handlers.NewWebhookHandler(m, soleMember()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []internal.Delivery
+	if err := json.Unmarshal([]byte(resp.Body), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 || got[0].Status != internal.DeliveryDeadLettered {
+		t.Fatalf("Unexpected deliveries %+v", got)
+	}
+}
+
+func testDeleteWebhookOK(t *testing.T) {
+
+	m := &WebhookRepoMock{
+		GetFn: webhookGetter(t),
+		DeleteFn: func(id string) error {
+			return nil
+		},
+	}
+
+	req := authorized(events.APIGatewayProxyRequest{
+		Resource:       "/webhooks/{id}",
+		HTTPMethod:     http.MethodDelete,
+		PathParameters: map[string]string{"id": savedWebhook.ID},
+	}, "user-1")
+
+	resp, err := handlers.NewWebhookHandler(m, soleMember()).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || !m.DeleteInvoked {
+		t.Fatalf("Expected webhook to be deleted, got %d", resp.StatusCode)
+	}
+}
+
+func testCreateWebhookPrivateHost(t *testing.T) {
+
+	urls := []string{
+		"http://localhost:8080/hook",
+		"http://127.0.0.1/hook",
+		"http://[::1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.1/hook",
+		"https://192.168.1.1/hook",
+		"http://0.0.0.0/hook",
+	}
+
+	for _, u := range urls {
+		m := &WebhookRepoMock{}
+
+		req := authorized(events.APIGatewayProxyRequest{
+			Resource:   "/webhooks",
+			HTTPMethod: http.MethodPost,
+			Body:       `{"listId":"work","url":"` + u + `"}`,
+		}, "user-1")
+
+		resp, err := handlers.NewWebhookHandler(m, soleMember()).Handle(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expected %d for %s, got %d", http.StatusBadRequest, u, resp.StatusCode)
+		}
+
+		if m.SaveInvoked {
+			t.Fatalf("Expected Save not to be invoked for %s", u)
+		}
+	}
+}
+
+func testCreateWebhookNotMember(t *testing.T) {
+
+	// user-1 is only a member of the work list
+	callers := []struct {
+		userID string
+		listID string
+	}{
+		{"user-2", "work"},
+		{"user-1", "home"},
+	}
+
+	for _, c := range callers {
+		m := &WebhookRepoMock{}
+
+		req := authorized(events.APIGatewayProxyRequest{
+			Resource:   "/webhooks",
+			HTTPMethod: http.MethodPost,
+			Body:       `{"listId":"` + c.listID + `","url":"https://example.com/hook"}`,
+		}, c.userID)
+
+		resp, err := handlers.NewWebhookHandler(m, soleMember()).Handle(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("Expected %d for %s in %s, got %d", http.StatusForbidden, c.userID, c.listID, resp.StatusCode)
+		}
+
+		if m.SaveInvoked {
+			t.Fatal("Expected Save not to be invoked")
+		}
+	}
+}
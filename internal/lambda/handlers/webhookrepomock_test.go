@@ -0,0 +1,65 @@
+package handlers_test
+
+import (
+	"github.com/benjaminbartels/todo/internal"
+)
+
+// WebhookRepoMock is used to mock a WebhookRepo
+type WebhookRepoMock struct {
+	GetFn                func(string) (*internal.Webhook, error)
+	GetByListFn          func(string) ([]internal.Webhook, error)
+	SaveFn               func(*internal.Webhook) error
+	DeleteFn             func(string) error
+	GetDeliveryFn        func(string, string) (*internal.Delivery, error)
+	GetDeliveriesFn      func(string) ([]internal.Delivery, error)
+	SaveDeliveryFn       func(*internal.Delivery) error
+	GetInvoked           bool
+	GetByListInvoked     bool
+	SaveInvoked          bool
+	DeleteInvoked        bool
+	GetDeliveryInvoked   bool
+	GetDeliveriesInvoked bool
+	SaveDeliveryInvoked  bool
+}
+
+// Get returns a Webhook by its ID
+func (m *WebhookRepoMock) Get(id string) (*internal.Webhook, error) {
+	m.GetInvoked = true
+	return m.GetFn(id)
+}
+
+// GetByList returns the webhooks subscribed to a list
+func (m *WebhookRepoMock) GetByList(listID string) ([]internal.Webhook, error) {
+	m.GetByListInvoked = true
+	return m.GetByListFn(listID)
+}
+
+// Save creates or updates a Webhook
+func (m *WebhookRepoMock) Save(webhook *internal.Webhook) error {
+	m.SaveInvoked = true
+	return m.SaveFn(webhook)
+}
+
+// Delete removes a Webhook
+func (m *WebhookRepoMock) Delete(id string) error {
+	m.DeleteInvoked = true
+	return m.DeleteFn(id)
+}
+
+// GetDelivery returns a delivery of a webhook by its ID
+func (m *WebhookRepoMock) GetDelivery(webhookID, id string) (*internal.Delivery, error) {
+	m.GetDeliveryInvoked = true
+	return m.GetDeliveryFn(webhookID, id)
+}
+
+// GetDeliveries returns the deliveries of a webhook
+func (m *WebhookRepoMock) GetDeliveries(webhookID string) ([]internal.Delivery, error) {
+	m.GetDeliveriesInvoked = true
+	return m.GetDeliveriesFn(webhookID)
+}
+
+// SaveDelivery stores a delivery
+func (m *WebhookRepoMock) SaveDelivery(delivery *internal.Delivery) error {
+	m.SaveDeliveryInvoked = true
+	return m.SaveDeliveryFn(delivery)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal/auth"
+	"github.com/pkg/errors"
+)
+
+// TokenVerifier returns the ID of the user a token was issued to, or auth.ErrInvalidToken
+type TokenVerifier interface {
+	Verify(token string) (string, error)
+}
+
+// errUnauthorized is the error an API Gateway authorizer returns to have the request rejected with 401
+var errUnauthorized = errors.New("Unauthorized")
+
+// WebSocketAuthorizer is the API Gateway authorizer of the $connect route of the WebSocket API. Browsers
+// can not send headers when they open a WebSocket, so clients send the token of their user in the token
+// query parameter.
+type WebSocketAuthorizer struct {
+	verifier TokenVerifier
+}
+
+// NewWebSocketAuthorizer creates a new WebSocket authorizer that verifies tokens with verifier
+func NewWebSocketAuthorizer(verifier TokenVerifier) *WebSocketAuthorizer {
+	return &WebSocketAuthorizer{
+		verifier: verifier,
+	}
+}
+
+// Handle allows a connection for the user its token was issued to, who is passed to the WebSocketHandler
+// as the principal
+func (a *WebSocketAuthorizer) Handle(req events.APIGatewayCustomAuthorizerRequestTypeRequest) (events.APIGatewayCustomAuthorizerResponse, error) {
+
+	userID, err := a.verifier.Verify(req.QueryStringParameters["token"])
+	if errors.Cause(err) == auth.ErrInvalidToken {
+		return events.APIGatewayCustomAuthorizerResponse{}, errUnauthorized
+	} else if err != nil {
+		return events.APIGatewayCustomAuthorizerResponse{}, err
+	}
+
+	return events.APIGatewayCustomAuthorizerResponse{
+		PrincipalID: userID,
+		PolicyDocument: events.APIGatewayCustomAuthorizerPolicy{
+			Version: "2012-10-17",
+			Statement: []events.IAMPolicyStatement{{
+				Action:   []string{"execute-api:Invoke"},
+				Effect:   "Allow",
+				Resource: []string{req.MethodArn},
+			}},
+		},
+	}, nil
+}
@@ -0,0 +1,81 @@
+package handlers_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal/auth"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+// VerifierFunc is a function that is a TokenVerifier
+type VerifierFunc func(string) (string, error)
+
+// Verify calls f(token)
+func (f VerifierFunc) Verify(token string) (string, error) {
+	return f(token)
+}
+
+func TestWebSocketAuthorizer(t *testing.T) {
+	t.Run("AuthorizeOK", testAuthorizeOK)
+	t.Run("AuthorizeInvalidToken", testAuthorizeInvalidToken)
+	t.Run("AuthorizeKeysUnavailable", testAuthorizeKeysUnavailable)
+}
+
+// authorizerRequest returns a request to authorize a connection with token
+func authorizerRequest(token string) events.APIGatewayCustomAuthorizerRequestTypeRequest {
+	return events.APIGatewayCustomAuthorizerRequestTypeRequest{
+		MethodArn:             "arn:aws:execute-api:us-west-2:123456789012:api/stage/$connect",
+		QueryStringParameters: map[string]string{"token": token},
+	}
+}
+
+func testAuthorizeOK(t *testing.T) {
+
+	verifier := VerifierFunc(func(token string) (string, error) {
+		if token != "good" {
+			t.Fatalf("Expected token good, got %s", token)
+		}
+		return "user-1", nil
+	})
+
+	resp, err := handlers.NewWebSocketAuthorizer(verifier).Handle(authorizerRequest("good"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.PrincipalID != "user-1" {
+		t.Fatalf("Expected principal user-1, got %s", resp.PrincipalID)
+	}
+
+	s := resp.PolicyDocument.Statement
+	if len(s) != 1 || s[0].Effect != "Allow" || s[0].Resource[0] != authorizerRequest("").MethodArn {
+		t.Fatalf("Unexpected policy %+v", resp.PolicyDocument)
+	}
+}
+
+func testAuthorizeInvalidToken(t *testing.T) {
+
+	verifier := VerifierFunc(func(string) (string, error) {
+		return "", auth.ErrInvalidToken
+	})
+
+	// API Gateway responds with 401 to this error
+	_, err := handlers.NewWebSocketAuthorizer(verifier).Handle(authorizerRequest("bad"))
+	if err == nil || err.Error() != "Unauthorized" {
+		t.Fatalf("Expected Unauthorized, got %v", err)
+	}
+}
+
+func testAuthorizeKeysUnavailable(t *testing.T) {
+
+	verifier := VerifierFunc(func(string) (string, error) {
+		return "", errors.New("Could not get signing keys")
+	})
+
+	_, err := handlers.NewWebSocketAuthorizer(verifier).Handle(authorizerRequest("good"))
+	if err == nil || err.Error() == "Unauthorized" {
+		t.Fatalf("Expected the error to be returned, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/pkg/errors"
+)
+
+// WebSocket API route keys
+const (
+	connectRoute    = "$connect"
+	disconnectRoute = "$disconnect"
+)
+
+// WebSocketHandler handles the connections of an API Gateway WebSocket API. Clients connect with the list
+// they want the changes of in the list query parameter, and are sent each change as a JSON message. Only
+// members of a list can connect to it, once the WebSocketAuthorizer has authenticated them.
+type WebSocketHandler struct {
+	connections database.ConnectionRepo
+	members     database.MemberRepo
+}
+
+// NewWebSocketHandler creates a new WebSocket handler. The members of lists are read from members.
+func NewWebSocketHandler(connections database.ConnectionRepo, members database.MemberRepo) *WebSocketHandler {
+	return &WebSocketHandler{
+		connections: connections,
+		members:     members,
+	}
+}
+
+// Handle handles a WebSocket event from AWS API Gateway and returns a response
+func (h *WebSocketHandler) Handle(req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	switch req.RequestContext.RouteKey {
+	case connectRoute:
+		return h.connect(req)
+	case disconnectRoute:
+		return h.disconnect(req)
+	default:
+		// Clients only receive, so messages they send are ignored
+		return CreateResponse(RawBody{}, http.StatusOK)
+	}
+}
+
+// connect subscribes a new connection to the changes of a list. An error response rejects the connection.
+func (h *WebSocketHandler) connect(req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	listID := req.QueryStringParameters["list"]
+	if listID == "" {
+		listID = internal.DefaultListID
+	}
+
+	if !validListID(listID) {
+		return CreateErrorResponse(errors.Wrap(ErrBadRequest, "invalid list ID"))
+	}
+
+	authorizer, _ := req.RequestContext.Authorizer.(map[string]interface{})
+
+	userID := authorizerID(authorizer)
+	if userID == "" {
+		return CreateErrorResponse(ErrUnauthorized)
+	}
+
+	ok, err := isMember(h.members, listID, userID)
+	if err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	if !ok {
+		return CreateErrorResponse(errors.Wrap(ErrForbidden, "only members of a list can subscribe to it"))
+	}
+
+	c := &internal.Connection{
+		ID:     req.RequestContext.ConnectionID,
+		ListID: listID,
+		UserID: userID,
+	}
+
+	if err := h.connections.Save(c); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateResponse(RawBody{}, http.StatusOK)
+}
+
+// disconnect removes a closed connection
+func (h *WebSocketHandler) disconnect(req events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	if err := h.connections.Delete(req.RequestContext.ConnectionID); err != nil {
+		return CreateErrorResponse(repoError(err))
+	}
+
+	return CreateResponse(RawBody{}, http.StatusOK)
+}
@@ -0,0 +1,211 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+func TestWebSocketHandler(t *testing.T) {
+	t.Run("ConnectOK", testConnectOK)
+	t.Run("ConnectDefaultList", testConnectDefaultList)
+	t.Run("ConnectBadRequest", testConnectBadRequest)
+	t.Run("ConnectUnauthorized", testConnectUnauthorized)
+	t.Run("ConnectNotMember", testConnectNotMember)
+	t.Run("ConnectThrottled", testConnectThrottled)
+	t.Run("DisconnectOK", testDisconnectOK)
+	t.Run("MessageIgnored", testMessageIgnored)
+}
+
+// webSocketRequest returns a request of user-1, as authorized by the WebSocketAuthorizer
+func webSocketRequest(routeKey string, query map[string]string) events.APIGatewayWebsocketProxyRequest {
+	req := events.APIGatewayWebsocketProxyRequest{QueryStringParameters: query}
+	req.RequestContext.RouteKey = routeKey
+	req.RequestContext.ConnectionID = "conn-1"
+	req.RequestContext.Authorizer = map[string]interface{}{"principalId": "user-1"}
+	return req
+}
+
+// memberOfEvery returns a MemberRepoMock in which userID is a member of every list
+func memberOfEvery(userID string) *MemberRepoMock {
+	return &MemberRepoMock{
+		GetByListFn: func(listID string) ([]internal.Member, error) {
+			return []internal.Member{{ListID: listID, UserID: userID, Handle: "ann"}}, nil
+		},
+	}
+}
+
+func testConnectOK(t *testing.T) {
+
+	var saved *internal.Connection
+
+	m := &ConnectionRepoMock{
+		SaveFn: func(c *internal.Connection) error {
+			saved = c
+			return nil
+		},
+	}
+
+	req := webSocketRequest("$connect", map[string]string{"list": "work"})
+
+	resp, err := handlers.NewWebSocketHandler(m, memberOfEvery("user-1")).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if saved == nil || saved.ID != "conn-1" || saved.ListID != "work" || saved.UserID != "user-1" {
+		t.Fatalf("Unexpected connection %+v", saved)
+	}
+}
+
+func testConnectDefaultList(t *testing.T) {
+
+	var saved *internal.Connection
+
+	m := &ConnectionRepoMock{
+		SaveFn: func(c *internal.Connection) error {
+			saved = c
+			return nil
+		},
+	}
+
+	resp, err := handlers.NewWebSocketHandler(m, memberOfEvery("user-1")).Handle(webSocketRequest("$connect", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if saved == nil || saved.ListID != internal.DefaultListID {
+		t.Fatalf("Expected connection to the default list, got %+v", saved)
+	}
+}
+
+func testConnectBadRequest(t *testing.T) {
+
+	m := &ConnectionRepoMock{}
+
+	resp, err := handlers.NewWebSocketHandler(m, memberOfEvery("user-1")).Handle(webSocketRequest("$connect", map[string]string{"list": "_connections"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	if m.SaveInvoked {
+		t.Fatal("Save should not be invoked")
+	}
+}
+
+func testConnectUnauthorized(t *testing.T) {
+
+	m := &ConnectionRepoMock{}
+
+	req := webSocketRequest("$connect", map[string]string{"list": "work"})
+	req.RequestContext.Authorizer = nil
+
+	resp, err := handlers.NewWebSocketHandler(m, memberOfEvery("user-1")).Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	if m.SaveInvoked {
+		t.Fatal("Save should not be invoked")
+	}
+}
+
+func testConnectNotMember(t *testing.T) {
+
+	m := &ConnectionRepoMock{}
+
+	// user-1 is only a member of the work list
+	resp, err := handlers.NewWebSocketHandler(m, soleMember()).Handle(webSocketRequest("$connect", map[string]string{"list": "home"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	if m.SaveInvoked {
+		t.Fatal("Save should not be invoked")
+	}
+}
+
+func testConnectThrottled(t *testing.T) {
+
+	m := &ConnectionRepoMock{
+		SaveFn: func(*internal.Connection) error {
+			return database.ErrThrottled
+		},
+	}
+
+	resp, err := handlers.NewWebSocketHandler(m, memberOfEvery("user-1")).Handle(webSocketRequest("$connect", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusTooManyRequests, resp.StatusCode)
+	}
+}
+
+func testDisconnectOK(t *testing.T) {
+
+	var deleted string
+
+	m := &ConnectionRepoMock{
+		DeleteFn: func(id string) error {
+			deleted = id
+			return nil
+		},
+	}
+
+	resp, err := handlers.NewWebSocketHandler(m, memberOfEvery("user-1")).Handle(webSocketRequest("$disconnect", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if deleted != "conn-1" {
+		t.Fatalf("Expected conn-1 to be deleted, got %s", deleted)
+	}
+}
+
+func testMessageIgnored(t *testing.T) {
+
+	m := &ConnectionRepoMock{}
+
+	resp, err := handlers.NewWebSocketHandler(m, memberOfEvery("user-1")).Handle(webSocketRequest("$default", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if m.SaveInvoked || m.DeleteInvoked {
+		t.Fatal("Repository should not be invoked")
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	awslambda "github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+func main() {
+
+	// Retries are handled by the repository's RetryPolicy rather than the SDK
+	s, err := session.NewSession(aws.NewConfig().WithRegion("us-west-2").WithMaxRetries(0))
+	if err != nil {
+		panic(err)
+	}
+
+	r := dynamodb.NewToDoRepo(awsdynamodb.New(s))
+
+	todos := func(listID string) database.ToDoRepo {
+		return r.ForList(listID)
+	}
+
+	h := handlers.NewRecurrenceHandler(r, todos, handlers.DefaultHorizon)
+
+	awslambda.Start(h.Handle)
+}
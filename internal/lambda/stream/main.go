@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+
+	awslambda "github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+	"github.com/benjaminbartels/todo/internal/domain"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+	"github.com/benjaminbartels/todo/internal/webhook"
+)
+
+func main() {
+
+	// Retries are handled by the repository's RetryPolicy rather than the SDK
+	s, err := session.NewSession(aws.NewConfig().WithRegion("us-west-2").WithMaxRetries(0))
+	if err != nil {
+		panic(err)
+	}
+
+	webhooks := dynamodb.NewWebhookRepo(awsdynamodb.New(s))
+
+	// Events written to standard output are kept in CloudWatch Logs as the audit trail
+	h := handlers.NewStreamHandler(domain.NewLogSink(os.Stdout), webhook.NewDispatcher(webhooks))
+
+	awslambda.Start(h.Handle)
+}
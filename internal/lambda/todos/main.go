@@ -1,25 +1,60 @@
 package main
 
 import (
+	"os"
+	"time"
+
 	awslambda "github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/apigatewaymanagementapi"
 	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/benjaminbartels/todo/internal/blob/s3"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/database/attachments"
+	"github.com/benjaminbartels/todo/internal/database/cache"
 	"github.com/benjaminbartels/todo/internal/database/dynamodb"
+	"github.com/benjaminbartels/todo/internal/database/notify"
 	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+	"github.com/benjaminbartels/todo/internal/realtime"
+)
+
+const (
+	// cacheSize is the maximum number of results kept by a warm container
+	cacheSize = 1000
+	// cacheTTL bounds how stale a result can be, as writes handled by other containers do not invalidate it
+	cacheTTL = 10 * time.Second
+	// websocketEndpointEnv names the environment variable holding the endpoint of the WebSocket API's
+	// stage. Changes are only pushed to clients when it is set.
+	websocketEndpointEnv = "WEBSOCKET_ENDPOINT"
+	// attachmentsBucketEnv names the environment variable holding the bucket attachments are kept in. The
+	// attachments of deleted ToDos are only removed when it is set.
+	attachmentsBucketEnv = "ATTACHMENTS_BUCKET"
 )
 
 func main() {
 
-	s, err := session.NewSession(aws.NewConfig().WithRegion("us-west-2"))
+	// Retries are handled by the repository's RetryPolicy rather than the SDK
+	s, err := session.NewSession(aws.NewConfig().WithRegion("us-west-2").WithMaxRetries(0))
 	if err != nil {
 		panic(err)
 	}
 
 	db := awsdynamodb.New(s)
-	repo := dynamodb.NewToDoRepo(db)
+	var repo database.ToDoRepo = cache.NewToDoRepo(dynamodb.NewToDoRepo(db), dynamodb.DefaultListID,
+		cache.NewMemoryCache(cacheSize), cacheTTL)
+
+	if endpoint := os.Getenv(websocketEndpointEnv); endpoint != "" {
+		api := apigatewaymanagementapi.New(s, aws.NewConfig().WithEndpoint(endpoint))
+		repo = notify.NewToDoRepo(repo, realtime.NewConnectionPublisher(dynamodb.NewConnectionRepo(db), api))
+	}
+
+	if bucket := os.Getenv(attachmentsBucketEnv); bucket != "" {
+		repo = attachments.NewToDoRepo(repo, s3.NewStore(awss3.New(s, aws.NewConfig().WithMaxRetries(3)), bucket))
+	}
 
-	h := handlers.NewToDoHandler(repo)
+	h := handlers.NewToDoHandlerWithMembers(repo, dynamodb.NewMemberRepo(db))
 
 	awslambda.Start(h.Handle)
 }
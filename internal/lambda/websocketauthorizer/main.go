@@ -0,0 +1,18 @@
+package main
+
+import (
+	"os"
+
+	awslambda "github.com/aws/aws-lambda-go/lambda"
+	"github.com/benjaminbartels/todo/internal/auth"
+	"github.com/benjaminbartels/todo/internal/lambda/handlers"
+)
+
+func main() {
+
+	verifier := auth.NewVerifier("us-west-2", os.Getenv("USER_POOL_ID"), os.Getenv("USER_POOL_CLIENT_ID"))
+
+	h := handlers.NewWebSocketAuthorizer(verifier)
+
+	awslambda.Start(h.Handle)
+}
@@ -0,0 +1,505 @@
+// Package markdown renders the Markdown of ToDo notes as HTML that is safe to insert into a page.
+//
+// A practical subset of CommonMark and GitHub Flavored Markdown is supported: paragraphs, ATX headings,
+// thematic breaks, block quotes, fenced code, ordered, unordered and task lists, emphasis, strikethrough,
+// code spans, links and autolinks. Raw HTML is never passed through, it is escaped, and links are only
+// made for http, https and mailto URLs, so the output can not run script.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// headingLine matches an ATX heading, capturing its level and text
+	headingLine = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	// fenceLine matches the opening of fenced code, capturing the fence and the language
+	fenceLine = regexp.MustCompile("^ {0,3}(```+|~~~+)[ \t]*([A-Za-z0-9_+.#-]*)")
+	// listItemLine matches a list item, capturing its indent, marker and text
+	listItemLine = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])(?:[ \t]+(.*))?$`)
+	// taskMarker matches the checkbox at the start of a task list item
+	taskMarker = regexp.MustCompile(`^\[([ xX])\](?:[ \t]+|$)`)
+)
+
+// HTML returns src rendered as HTML
+func HTML(src string) string {
+	src = strings.Replace(src, "\r\n", "\n", -1)
+	src = strings.Replace(src, "\t", "    ", -1)
+
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"))
+
+	return b.String()
+}
+
+// renderBlocks renders lines as a sequence of blocks
+func renderBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case fenceLine.MatchString(line):
+			i = renderCode(b, lines, i)
+		case headingLine.MatchString(line):
+			m := headingLine.FindStringSubmatch(line)
+			tag := "h" + strconv.Itoa(len(m[1]))
+			b.WriteString("<" + tag + ">" + inline(m[2]) + "</" + tag + ">\n")
+			i++
+		case isRule(line):
+			b.WriteString("<hr>\n")
+			i++
+		case isQuote(line):
+			i = renderQuote(b, lines, i)
+		case listItemLine.MatchString(line):
+			i = renderList(b, lines, i)
+		default:
+			i = renderParagraph(b, lines, i)
+		}
+	}
+}
+
+// startsBlock reports whether line starts a block other than a paragraph, ending the paragraph before it
+func startsBlock(line string) bool {
+	return fenceLine.MatchString(line) || headingLine.MatchString(line) || isRule(line) || isQuote(line) ||
+		listItemLine.MatchString(line)
+}
+
+// isRule reports whether line is a thematic break, three or more -, * or _ characters
+func isRule(line string) bool {
+	s := strings.Replace(strings.TrimSpace(line), " ", "", -1)
+	if len(s) < 3 || indent(line) > 3 {
+		return false
+	}
+
+	return strings.Count(s, s[:1]) == len(s) && strings.ContainsAny(s[:1], "-*_")
+}
+
+// isQuote reports whether line is part of a block quote
+func isQuote(line string) bool {
+	return indent(line) <= 3 && strings.HasPrefix(strings.TrimSpace(line), ">")
+}
+
+// indent returns the number of spaces line starts with
+func indent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// renderParagraph renders the paragraph that starts at lines[i] and returns the index of the line after it
+func renderParagraph(b *strings.Builder, lines []string, i int) int {
+	text := []string{strings.TrimSpace(lines[i])}
+
+	for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "" && !startsBlock(lines[i]); i++ {
+		text = append(text, strings.TrimSpace(lines[i]))
+	}
+
+	b.WriteString("<p>" + inline(strings.Join(text, "\n")) + "</p>\n")
+
+	return i
+}
+
+// renderCode renders the fenced code that starts at lines[i] and returns the index of the line after it.
+// Code that is not closed runs to the end of the notes.
+func renderCode(b *strings.Builder, lines []string, i int) int {
+	m := fenceLine.FindStringSubmatch(lines[i])
+	fence := m[1]
+
+	if m[2] != "" {
+		b.WriteString(`<pre><code class="language-` + html.EscapeString(m[2]) + `">`)
+	} else {
+		b.WriteString("<pre><code>")
+	}
+
+	for i++; i < len(lines); i++ {
+		if t := strings.TrimSpace(lines[i]); strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+			i++
+			break
+		}
+		b.WriteString(html.EscapeString(lines[i]) + "\n")
+	}
+
+	b.WriteString("</code></pre>\n")
+
+	return i
+}
+
+// renderQuote renders the block quote that starts at lines[i] and returns the index of the line after it
+func renderQuote(b *strings.Builder, lines []string, i int) int {
+	quoted := []string{}
+
+	for ; i < len(lines) && isQuote(lines[i]); i++ {
+		s := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+		quoted = append(quoted, strings.TrimPrefix(s, " "))
+	}
+
+	b.WriteString("<blockquote>\n")
+	renderBlocks(b, quoted)
+	b.WriteString("</blockquote>\n")
+
+	return i
+}
+
+// renderList renders the list that starts at lines[i] and returns the index of the line after it. Lines
+// indented to the text of an item belong to it, so lists can be nested.
+func renderList(b *strings.Builder, lines []string, i int) int {
+	m := listItemLine.FindStringSubmatch(lines[i])
+	ordered := !strings.ContainsAny(m[2], "-*+")
+	marker := m[2][len(m[2])-1:]
+
+	if ordered {
+		if n, _ := strconv.Atoi(m[2][:len(m[2])-1]); n != 1 {
+			b.WriteString(`<ol start="` + strconv.Itoa(n) + `">` + "\n")
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+
+	for i < len(lines) {
+		m := listItemLine.FindStringSubmatch(lines[i])
+		if m == nil || isRule(lines[i]) || m[2][len(m[2])-1:] != marker {
+			break
+		}
+
+		// The text of the item starts after the marker and the space following it
+		width := len(m[1]) + len(m[2]) + 1
+		item := []string{m[3]}
+
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+
+			if strings.TrimSpace(line) == "" {
+				// A blank line only continues the item if the next line is indented to its text
+				next := i + 1
+				for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+					next++
+				}
+				if next == len(lines) || indent(lines[next]) < width {
+					i = next
+					break
+				}
+				item = append(item, "")
+				continue
+			}
+
+			if indent(line) >= width {
+				item = append(item, line[width:])
+				continue
+			}
+
+			// Lines that do not start a block continue the paragraph the item starts with
+			if startsBlock(line) || len(item) > 1 && item[len(item)-1] == "" {
+				break
+			}
+
+			item = append(item, strings.TrimSpace(line))
+		}
+
+		renderItem(b, item)
+	}
+
+	if ordered {
+		b.WriteString("</ol>\n")
+	} else {
+		b.WriteString("</ul>\n")
+	}
+
+	return i
+}
+
+// renderItem renders the lines of a list item. The paragraph it starts with is rendered without <p>, as
+// lists in notes are almost always tight.
+func renderItem(b *strings.Builder, lines []string) {
+	b.WriteString("<li>")
+
+	first := lines[0]
+	if m := taskMarker.FindStringSubmatch(first); m != nil {
+		if m[1] == " " {
+			b.WriteString(`<input type="checkbox" disabled> `)
+		} else {
+			b.WriteString(`<input type="checkbox" checked disabled> `)
+		}
+		first = first[len(m[0]):]
+	}
+
+	text := []string{strings.TrimSpace(first)}
+
+	i := 1
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != "" && !startsBlock(lines[i]); i++ {
+		text = append(text, strings.TrimSpace(lines[i]))
+	}
+
+	b.WriteString(inline(strings.Join(text, "\n")))
+
+	if i < len(lines) {
+		b.WriteString("\n")
+		renderBlocks(b, lines[i:])
+	}
+
+	b.WriteString("</li>\n")
+}
+
+// inline renders the inline content of a block
+func inline(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(punctuation, s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '`':
+			if code, end, ok := codeSpan(s, i); ok {
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i = end
+				continue
+			}
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			// Images are rendered as links, so notes can not load content when they are viewed
+			if text, dest, end, ok := link(s, i+1); ok {
+				if u, ok := safeURL(dest); ok {
+					b.WriteString(anchor(u, html.EscapeString(text)))
+					i = end
+					continue
+				}
+			}
+		case c == '[':
+			if text, dest, end, ok := link(s, i); ok {
+				if u, ok := safeURL(dest); ok {
+					b.WriteString(anchor(u, inline(text)))
+				} else {
+					b.WriteString(inline(text))
+				}
+				i = end
+				continue
+			}
+		case c == '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 0 {
+				if u, ok := safeURL(s[i+1 : i+end]); ok && !strings.ContainsAny(u, " \n") {
+					b.WriteString(anchor(u, html.EscapeString(u)))
+					i += end + 1
+					continue
+				}
+			}
+		case c == 'h' && (i == 0 || !isWordByte(s[i-1])) &&
+			(strings.HasPrefix(s[i:], "http://") || strings.HasPrefix(s[i:], "https://")):
+			u := bareURL(s[i:])
+			if safe, ok := safeURL(u); ok {
+				b.WriteString(anchor(safe, html.EscapeString(u)))
+				i += len(u)
+				continue
+			}
+		case c == '*' || c == '_' || c == '~':
+			if tag, inner, end, ok := emphasis(s, i); ok {
+				b.WriteString("<" + tag + ">" + inline(inner) + "</" + tag + ">")
+				i = end
+				continue
+			}
+		}
+
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+
+	return b.String()
+}
+
+// punctuation are the characters that can be escaped with a backslash
+const punctuation = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// codeSpan returns the code of the code span starting at s[i] and the index after it
+func codeSpan(s string, i int) (string, int, bool) {
+	n := 0
+	for i+n < len(s) && s[i+n] == '`' {
+		n++
+	}
+
+	fence := s[i : i+n]
+
+	for j := i + n; j < len(s); {
+		k := strings.Index(s[j:], fence)
+		if k < 0 {
+			return "", 0, false
+		}
+
+		k += j
+		end := k + n
+
+		// The closing run must be exactly as long as the opening one
+		if end < len(s) && s[end] == '`' {
+			for end < len(s) && s[end] == '`' {
+				end++
+			}
+			j = end
+			continue
+		}
+
+		code := strings.Replace(s[i+n:k], "\n", " ", -1)
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+			code = code[1 : len(code)-1]
+		}
+
+		return code, end, true
+	}
+
+	return "", 0, false
+}
+
+// link parses the link [text](dest) starting at s[i], returning its text, its destination and the index
+// after it. A title following the destination is ignored.
+func link(s string, i int) (string, string, int, bool) {
+	depth := 0
+	end := -1
+
+	for j := i; j < len(s) && end < 0; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				end = j
+			}
+		}
+	}
+
+	if end < 0 || end+1 >= len(s) || s[end+1] != '(' {
+		return "", "", 0, false
+	}
+
+	depth = 0
+	for j := end + 2; j < len(s); j++ {
+		switch s[j] {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+				continue
+			}
+
+			dest := strings.TrimSpace(s[end+2 : j])
+			if k := strings.IndexAny(dest, " \n"); k >= 0 {
+				dest = dest[:k]
+			}
+			dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+
+			return s[i+1 : end], dest, j + 1, true
+		}
+	}
+
+	return "", "", 0, false
+}
+
+// emphasis parses the emphasis, strong emphasis or strikethrough starting at s[i], returning its tag, its
+// content and the index after it
+func emphasis(s string, i int) (string, string, int, bool) {
+	c := s[i]
+
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+
+	// Underscores inside words, as in snake_case, are not emphasis
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return "", "", 0, false
+	}
+
+	var tag string
+	switch {
+	case c == '~' && n == 2:
+		tag = "del"
+	case c != '~' && n == 2:
+		tag = "strong"
+	case c != '~' && n == 1:
+		tag = "em"
+	default:
+		return "", "", 0, false
+	}
+
+	delim := s[i : i+n]
+	start := i + n
+
+	if start >= len(s) || s[start] == ' ' || s[start] == '\n' {
+		return "", "", 0, false
+	}
+
+	for j := start; j < len(s); {
+		k := strings.Index(s[j:], delim)
+		if k < 0 {
+			return "", "", 0, false
+		}
+
+		k += j
+		end := k + n
+
+		// The closing delimiter must not follow a space or run into more of the same character
+		if k == start || s[k-1] == ' ' || end < len(s) && s[end] == c ||
+			c == '_' && end < len(s) && isWordByte(s[end]) {
+			j = k + 1
+			continue
+		}
+
+		return tag, s[start:k], end, true
+	}
+
+	return "", "", 0, false
+}
+
+// bareURL returns the URL at the start of s, without trailing punctuation that ends the sentence around
+// it
+func bareURL(s string) string {
+	end := strings.IndexAny(s, " \n<")
+	if end < 0 {
+		end = len(s)
+	}
+
+	u := s[:end]
+
+	for len(u) > 0 && strings.IndexByte(".,:;!?'\"*_~", u[len(u)-1]) >= 0 ||
+		strings.HasSuffix(u, ")") && strings.Count(u, "(") < strings.Count(u, ")") {
+		u = u[:len(u)-1]
+	}
+
+	return u
+}
+
+// safeURL returns u if it is an absolute http, https or mailto URL
+func safeURL(u string) (string, bool) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", false
+	}
+
+	switch strings.ToLower(parsed.Scheme) {
+	case "http", "https":
+		return u, parsed.Host != ""
+	case "mailto":
+		return u, parsed.Opaque != ""
+	default:
+		return "", false
+	}
+}
+
+// anchor returns a link to u with the given HTML content. Links are not followed by search engines and do
+// not give the linked page access to the page they are on.
+func anchor(u, content string) string {
+	return `<a href="` + html.EscapeString(u) + `" rel="nofollow noopener noreferrer">` + content + "</a>"
+}
+
+// isWordByte reports whether c is an ASCII letter or digit
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
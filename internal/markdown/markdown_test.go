@@ -0,0 +1,65 @@
+package markdown_test
+
+import (
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal/markdown"
+)
+
+func TestHTML(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		markdown string
+		html     string
+	}{
+		{"Paragraphs", "First line\nsecond line\n\nNext", "<p>First line\nsecond line</p>\n<p>Next</p>\n"},
+		{"Headings", "# Runbook\n### Steps ###", "<h1>Runbook</h1>\n<h3>Steps</h3>\n"},
+		{"NotAHeading", "#hashtag", "<p>#hashtag</p>\n"},
+		{"Emphasis", "*em* **strong** _em_ __strong__ ~~gone~~", "<p><em>em</em> <strong>strong</strong> <em>em</em> <strong>strong</strong> <del>gone</del></p>\n"},
+		{"SnakeCase", "set max_open_files and *", "<p>set max_open_files and *</p>\n"},
+		{"CodeSpan", "run `kubectl get <pods>` now", "<p>run <code>kubectl get &lt;pods&gt;</code> now</p>\n"},
+		{"Escapes", `\*not em\* and \<b>`, "<p>*not em* and &lt;b&gt;</p>\n"},
+		{"FencedCode", "```sh\necho '<hi>'\n```\nafter", "<pre><code class=\"language-sh\">echo &#39;&lt;hi&gt;&#39;\n</code></pre>\n<p>after</p>\n"},
+		{"Rule", "above\n\n---\nbelow", "<p>above</p>\n<hr>\n<p>below</p>\n"},
+		{"Quote", "> quoted\n> **text**", "<blockquote>\n<p>quoted\n<strong>text</strong></p>\n</blockquote>\n"},
+		{"UnorderedList", "- one\n- two\n  continued", "<ul>\n<li>one</li>\n<li>two\ncontinued</li>\n</ul>\n"},
+		{"OrderedList", "3. three\n4. four", "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>\n"},
+		{"NestedList", "- parent\n  - child\n- sibling", "<ul>\n<li>parent\n<ul>\n<li>child</li>\n</ul>\n</li>\n<li>sibling</li>\n</ul>\n"},
+		{"TaskList", "- [x] drain\n- [ ] patch", "<ul>\n<li><input type=\"checkbox\" checked disabled> drain</li>\n<li><input type=\"checkbox\" disabled> patch</li>\n</ul>\n"},
+		{"Link", "see [the runbook](https://wiki.example.com/runbooks/certs)", "<p>see <a href=\"https://wiki.example.com/runbooks/certs\" rel=\"nofollow noopener noreferrer\">the runbook</a></p>\n"},
+		{"Autolink", "<https://example.com/a?b=c&d=e>", "<p><a href=\"https://example.com/a?b=c&amp;d=e\" rel=\"nofollow noopener noreferrer\">https://example.com/a?b=c&amp;d=e</a></p>\n"},
+		{"BareURL", "Dashboard: https://grafana.example.com/d/abc.", "<p>Dashboard: <a href=\"https://grafana.example.com/d/abc\" rel=\"nofollow noopener noreferrer\">https://grafana.example.com/d/abc</a>.</p>\n"},
+		{"Mailto", "[mail](mailto:oncall@example.com)", "<p><a href=\"mailto:oncall@example.com\" rel=\"nofollow noopener noreferrer\">mail</a></p>\n"},
+		{"Image", "![graph](https://example.com/graph.png)", "<p><a href=\"https://example.com/graph.png\" rel=\"nofollow noopener noreferrer\">graph</a></p>\n"},
+	}
+
+	for _, test := range tests {
+		if got := markdown.HTML(test.markdown); got != test.html {
+			t.Fatalf("%s: Expected:\n%q\nGot:\n%q", test.name, test.html, got)
+		}
+	}
+}
+
+func TestHTMLSanitized(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		markdown string
+		html     string
+	}{
+		{"Script", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"EventHandler", `<img src=x onerror="alert(1)">`, "<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>\n"},
+		{"JavaScriptLink", "[click](javascript:alert(1))", "<p>click</p>\n"},
+		{"DataLink", "[click](data:text/html;base64,PHNjcmlwdD4=)", "<p>click</p>\n"},
+		{"JavaScriptAutolink", "<javascript:alert(1)>", "<p>&lt;javascript:alert(1)&gt;</p>\n"},
+		{"QuoteInURL", `[x](https://example.com/"onmouseover="alert(1))`, "<p><a href=\"https://example.com/&#34;onmouseover=&#34;alert(1)\" rel=\"nofollow noopener noreferrer\">x</a></p>\n"},
+		{"CodeLanguage", "```\"><script>\nx\n```", "<pre><code>x\n</code></pre>\n"},
+	}
+
+	for _, test := range tests {
+		if got := markdown.HTML(test.markdown); got != test.html {
+			t.Fatalf("%s: Expected:\n%q\nGot:\n%q", test.name, test.html, got)
+		}
+	}
+}
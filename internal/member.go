@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"strings"
+	"time"
+)
+
+// maxHandleLength is the longest handle, in bytes
+const maxHandleLength = 32
+
+// Member is a user who belongs to a list. Handle is the name others mention them by in comments.
+type Member struct {
+	ListID string    `json:"listId"`
+	UserID string    `json:"userId"`
+	Handle string    `json:"handle"`
+	Added  time.Time `json:"added"`
+}
+
+// NormalizeHandle returns the form of a handle that is stored and compared, which is lower case
+func NormalizeHandle(handle string) string {
+	return strings.ToLower(handle)
+}
+
+// ValidHandle reports whether handle is a normalized handle of 1 to 32 letters, digits, dots, dashes and
+// underscores that does not end with a dot
+func ValidHandle(handle string) bool {
+	if handle == "" || len(handle) > maxHandleLength || strings.HasSuffix(handle, ".") {
+		return false
+	}
+
+	for i := 0; i < len(handle); i++ {
+		if !isHandleByte(handle[i]) || handle[i] >= 'A' && handle[i] <= 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isHandleByte reports whether b can be part of a handle
+func isHandleByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '.' || b == '-' || b == '_'
+}
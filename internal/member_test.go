@@ -0,0 +1,38 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+func TestMember(t *testing.T) {
+	t.Run("ValidHandle", testValidHandle)
+}
+
+func testValidHandle(t *testing.T) {
+
+	tests := []struct {
+		handle string
+		want   bool
+	}{
+		{"sam", true},
+		{"sam.jones-2_b", true},
+		{"", false},
+		{"Sam", false},
+		{"sam.", false},
+		{"sam jones", false},
+		{"abcdefghijklmnopqrstuvwxyz012345", true},
+		{"abcdefghijklmnopqrstuvwxyz0123456", false},
+	}
+
+	for _, test := range tests {
+		if got := internal.ValidHandle(test.handle); got != test.want {
+			t.Errorf("ValidHandle(%q) = %v, expected %v", test.handle, got, test.want)
+		}
+	}
+
+	if h := internal.NormalizeHandle("Sam.Jones"); h != "sam.jones" {
+		t.Fatalf("Expected handle to be lower case, got %s", h)
+	}
+}
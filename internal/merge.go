@@ -0,0 +1,303 @@
+package internal
+
+import (
+	"time"
+
+	"github.com/benjaminbartels/todo/internal/crdt"
+)
+
+// TombstoneRetention is how long deleted ToDos, and the elements removed from their sets, are remembered.
+// Clients that have not synced for longer must fetch every ToDo again rather than merge their edits.
+const TombstoneRetention = 30 * 24 * time.Hour
+
+// maxRemoved is how many of the elements removed from a set within TombstoneRetention are remembered, so
+// a collection that is replaced often stays far below the size limit of a DynamoDB item
+const maxRemoved = 100
+
+// Clocks are the timestamps of the last write to each field of a ToDo, keyed by the field's JSON name.
+// Together with its fields they make a ToDo a set of last-writer-wins registers, so edits made to
+// different fields by clients that were offline at the same time are all kept.
+type Clocks map[string]crdt.Timestamp
+
+// Sets are the add-wins sets of the elements of fields that are collections, such as tags, assignees and
+// the IDs of checklist items, keyed by the field's JSON name. An element added by one client is kept when
+// another concurrently removes or replaces others, rather than the last write to the field replacing the
+// whole collection.
+type Sets map[string]crdt.AddWinsSet
+
+// field is a ToDo field that is merged as a last-writer-wins register. less orders its values, so ties
+// between writes with the same timestamp are broken the same way on every replica.
+//
+// Fields that are collections also have elements, and are merged as an add-wins set of them as well.
+// keep replaces the field of dst, which holds the value of the last write, with the merged elements,
+// taking those the last write did not have from other.
+type field struct {
+	name     string
+	equal    func(a, b *ToDo) bool
+	less     func(a, b *ToDo) bool
+	copy     func(dst, src *ToDo)
+	elements func(t *ToDo) []string
+	keep     func(dst, other *ToDo, elements []string)
+}
+
+// fields are the fields of a ToDo that clients can edit
+var fields = []field{
+	{
+		name:  "title",
+		equal: func(a, b *ToDo) bool { return a.Title == b.Title },
+		less:  func(a, b *ToDo) bool { return a.Title < b.Title },
+		copy:  func(dst, src *ToDo) { dst.Title = src.Title },
+	},
+	{
+		name:  "notes",
+		equal: func(a, b *ToDo) bool { return a.Notes == b.Notes },
+		less:  func(a, b *ToDo) bool { return a.Notes < b.Notes },
+		copy:  func(dst, src *ToDo) { dst.Notes = src.Notes },
+	},
+	{
+		name:  "completed",
+		equal: func(a, b *ToDo) bool { return a.Completed == b.Completed },
+		less:  func(a, b *ToDo) bool { return !a.Completed && b.Completed },
+		copy:  func(dst, src *ToDo) { dst.Completed = src.Completed },
+	},
+	{
+		name: "due",
+		equal: func(a, b *ToDo) bool {
+			return a.Due == nil && b.Due == nil || a.Due != nil && b.Due != nil && a.Due.Equal(*b.Due)
+		},
+		less: func(a, b *ToDo) bool {
+			return a.Due == nil && b.Due != nil || a.Due != nil && b.Due != nil && a.Due.Before(*b.Due)
+		},
+		copy: func(dst, src *ToDo) { dst.Due = src.Due },
+	},
+	{
+		// The order of the checklist and the titles and states of its items are a single register, so
+		// reordering and checking items are kept together. Items are added and removed as a set of their IDs.
+		name:     "checklist",
+		equal:    func(a, b *ToDo) bool { return checklistEqual(a.Checklist, b.Checklist) },
+		less:     func(a, b *ToDo) bool { return checklistLess(a.Checklist, b.Checklist) },
+		copy:     func(dst, src *ToDo) { dst.Checklist = src.Checklist },
+		elements: func(t *ToDo) []string { return checklistIDs(t.Checklist) },
+		keep:     keepChecklist,
+	},
+	{
+		name:     "tags",
+		equal:    func(a, b *ToDo) bool { return tagsEqual(a.Tags, b.Tags) },
+		less:     func(a, b *ToDo) bool { return tagsLess(a.Tags, b.Tags) },
+		copy:     func(dst, src *ToDo) { dst.Tags = src.Tags },
+		elements: func(t *ToDo) []string { return t.Tags },
+		keep:     func(dst, _ *ToDo, elements []string) { dst.Tags = elements },
+	},
+	{
+		name:     "assigneeIds",
+		equal:    func(a, b *ToDo) bool { return tagsEqual(a.AssigneeIDs, b.AssigneeIDs) },
+		less:     func(a, b *ToDo) bool { return tagsLess(a.AssigneeIDs, b.AssigneeIDs) },
+		copy:     func(dst, src *ToDo) { dst.AssigneeIDs = src.AssigneeIDs },
+		elements: func(t *ToDo) []string { return t.AssigneeIDs },
+		keep:     func(dst, _ *ToDo, elements []string) { dst.AssigneeIDs = elements },
+	},
+	{
+		name:  "estimateMinutes",
+		equal: func(a, b *ToDo) bool { return a.EstimateMinutes == b.EstimateMinutes },
+		less:  func(a, b *ToDo) bool { return a.EstimateMinutes < b.EstimateMinutes },
+		copy:  func(dst, src *ToDo) { dst.EstimateMinutes = src.EstimateMinutes },
+	},
+	{
+		// The rule, its time zone and the start of its series are a single register, as a rule is expanded
+		// from the start of its series in its time zone
+		name:  "rrule",
+		equal: func(a, b *ToDo) bool { return a.RRule == b.RRule && a.TimeZone == b.TimeZone },
+		less: func(a, b *ToDo) bool {
+			return a.RRule < b.RRule || a.RRule == b.RRule && a.TimeZone < b.TimeZone
+		},
+		copy: func(dst, src *ToDo) {
+			dst.RRule, dst.TimeZone, dst.SeriesID, dst.SeriesStart = src.RRule, src.TimeZone, src.SeriesID, src.SeriesStart
+		},
+	},
+	{
+		name:  "autoComplete",
+		equal: func(a, b *ToDo) bool { return a.AutoComplete == b.AutoComplete },
+		less:  func(a, b *ToDo) bool { return !a.AutoComplete && b.AutoComplete },
+		copy:  func(dst, src *ToDo) { dst.AutoComplete = src.AutoComplete },
+	},
+}
+
+// Clock returns the timestamp of the last write to the named field. Fields written before clocks were
+// recorded, or by a client that does not record them, were last written at the ToDo's ModTime.
+func (t *ToDo) Clock(name string) crdt.Timestamp {
+	if ts, ok := t.Clocks[name]; ok {
+		return ts
+	}
+	return crdt.FromTime(t.ModTime)
+}
+
+// Merge returns the result of merging other into t, taking each field from the ToDo that wrote it last.
+// Merging is commutative, associative and idempotent, so replicas that merge the same writes in any
+// order agree. Fields that are not edited by clients, such as ID and Created, are taken from t.
+func (t ToDo) Merge(other ToDo) ToDo {
+	merged := t
+	merged.Clocks = make(Clocks)
+	merged.Sets = nil
+
+	for _, f := range fields {
+		ts, otherTS := t.Clock(f.name), other.Clock(f.name)
+		loser := &other
+
+		// Ties between equal timestamps with different values, which only happen when Clocks are missing,
+		// are broken by value so the result does not depend on the order of the merge
+		if ts.Before(otherTS) || ts == otherTS && f.less(&t, &other) {
+			f.copy(&merged, &other)
+			ts, loser = otherTS, &t
+		}
+
+		if !ts.IsZero() {
+			merged.Clocks[f.name] = ts
+		}
+
+		if f.elements == nil {
+			continue
+		}
+
+		if set, ok := mergeSets(f, &t, &other); ok {
+			prune(&set, ts)
+			f.keep(&merged, loser, set.Elements())
+			merged.setSet(f.name, set)
+		}
+	}
+
+	if other.ModTime.After(merged.ModTime) {
+		merged.ModTime = other.ModTime
+	}
+
+	return merged
+}
+
+// Stamp records ts as the time of the write of each field that differs from previous, keeping the clocks
+// of the fields that are unchanged. Every field is stamped when previous is nil. Writes by clients that
+// do not send clocks are stamped, so clients that merge see them as the latest writes. The sets of
+// collections are rebuilt from those of previous, so the sets sent by clients are ignored.
+func (t *ToDo) Stamp(previous *ToDo, ts crdt.Timestamp) {
+	clocks := make(Clocks)
+	t.Sets = nil
+
+	for _, f := range fields {
+		changed := previous == nil || !f.equal(t, previous)
+
+		if !changed {
+			if c := previous.Clock(f.name); !c.IsZero() {
+				clocks[f.name] = c
+			}
+		} else {
+			clocks[f.name] = ts
+		}
+
+		if f.elements == nil {
+			continue
+		}
+
+		var set crdt.AddWinsSet
+		var ok bool
+
+		if previous != nil {
+			set, ok = previous.set(f)
+		}
+
+		if changed {
+			set, ok = write(set, f.elements(t), ts), true
+		}
+
+		if ok {
+			t.setSet(f.name, set)
+		}
+	}
+
+	t.Clocks = clocks
+}
+
+// set returns a copy of the set of the elements of field f, and whether t has one. ToDos saved before sets
+// were recorded have one with each element added at the time the field was last written.
+func (t *ToDo) set(f field) (crdt.AddWinsSet, bool) {
+	if set, ok := t.Sets[f.name]; ok {
+		return crdt.AddWinsSet{}.Merge(set), true
+	}
+
+	elements := f.elements(t)
+	if len(elements) == 0 {
+		return crdt.AddWinsSet{}, false
+	}
+
+	return write(crdt.AddWinsSet{}, elements, t.Clock(f.name)), true
+}
+
+// setSet stores the set of the named field, leaving out sets that were never added to
+func (t *ToDo) setSet(name string, set crdt.AddWinsSet) {
+	if len(set.Adds) == 0 {
+		return
+	}
+
+	if t.Sets == nil {
+		t.Sets = make(Sets)
+	}
+
+	t.Sets[name] = set
+}
+
+// mergeSets merges the sets of the elements of field f. A ToDo without a set, such as one merged by a client
+// that does not record them, is treated as a write of all of its elements at the time of its field, which
+// is only applied if it is later than the last write to the other's set. ok is false if neither has a set,
+// leaving the field to be merged as a register.
+func mergeSets(f field, t, other *ToDo) (merged crdt.AddWinsSet, ok bool) {
+	a, aok := t.Sets[f.name]
+	b, bok := other.Sets[f.name]
+
+	switch {
+	case aok && bok:
+		return a.Merge(b), true
+	case aok:
+		return overwrite(a, t.Clock(f.name), f.elements(other), other.Clock(f.name)), true
+	case bok:
+		return overwrite(b, other.Clock(f.name), f.elements(t), t.Clock(f.name)), true
+	default:
+		return crdt.AddWinsSet{}, false
+	}
+}
+
+// overwrite returns a copy of set, last written at last, with a write of elements at ts applied if it is
+// later
+func overwrite(set crdt.AddWinsSet, last crdt.Timestamp, elements []string, ts crdt.Timestamp) crdt.AddWinsSet {
+	set = crdt.AddWinsSet{}.Merge(set)
+
+	if !last.Before(ts) {
+		return set
+	}
+
+	return write(set, elements, ts)
+}
+
+// write makes set contain exactly elements, adding those it does not contain at ts and removing the others
+func write(set crdt.AddWinsSet, elements []string, ts crdt.Timestamp) crdt.AddWinsSet {
+	keep := make(map[string]bool)
+
+	for _, e := range elements {
+		keep[e] = true
+		if !set.Contains(e) {
+			set.Add(e, ts)
+		}
+	}
+
+	for _, e := range set.Elements() {
+		if !keep[e] {
+			set.Remove(e, ts)
+		}
+	}
+
+	prune(&set, ts)
+
+	return set
+}
+
+// prune forgets the elements removed from set more than TombstoneRetention before ts, the time of the last
+// write to it, and all but the maxRemoved removed most recently
+func prune(set *crdt.AddWinsSet, ts crdt.Timestamp) {
+	set.Prune(crdt.Timestamp{Wall: ts.Wall - TombstoneRetention.Nanoseconds()/int64(time.Millisecond)}, maxRemoved)
+}
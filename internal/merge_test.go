@@ -0,0 +1,331 @@
+package internal_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/crdt"
+)
+
+func TestMerge(t *testing.T) {
+	t.Run("ConcurrentFieldEdits", testConcurrentFieldEdits)
+	t.Run("SameFieldLastWriterWins", testSameFieldLastWriterWins)
+	t.Run("MissingClocksUseModTime", testMissingClocksUseModTime)
+	t.Run("Stamp", testStamp)
+	t.Run("ConcurrentTagEdits", testConcurrentTagEdits)
+	t.Run("TagsFromClientWithoutSets", testTagsFromClientWithoutSets)
+	t.Run("ConcurrentChecklistEdits", testConcurrentChecklistEdits)
+	t.Run("ConcurrentAssigneeEdits", testConcurrentAssigneeEdits)
+	t.Run("RemovedElementsArePruned", testRemovedElementsArePruned)
+}
+
+func testConcurrentFieldEdits(t *testing.T) {
+
+	base := internal.ToDo{ID: "1", Title: "Buy milk"}
+	base.Stamp(nil, crdt.NewClock("server").Now())
+
+	// Both clients fetched the ToDo before editing it
+	a, b := crdt.NewClock("a"), crdt.NewClock("b")
+	for _, c := range []*crdt.Clock{a, b} {
+		if err := c.Update(base.Clocks["title"]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	renamed := base
+	renamed.Title = "Buy oat milk"
+	renamed.Stamp(&base, a.Now())
+
+	completed := base
+	completed.Completed = true
+	completed.Stamp(&base, b.Now())
+
+	ab := renamed.Merge(completed)
+	ba := completed.Merge(renamed)
+
+	for _, merged := range []internal.ToDo{ab, ba} {
+		if merged.Title != "Buy oat milk" || !merged.Completed {
+			t.Fatalf("Expected both edits to survive, got %v", merged)
+		}
+	}
+
+	if !reflect.DeepEqual(ab.Clocks, ba.Clocks) {
+		t.Fatalf("Expected merges to agree, got %v and %v", ab.Clocks, ba.Clocks)
+	}
+
+	if again := ab.Merge(completed); !reflect.DeepEqual(again, ab) {
+		t.Fatal("Expected merge to be idempotent")
+	}
+}
+
+func testSameFieldLastWriterWins(t *testing.T) {
+
+	a, b := crdt.NewClock("a"), crdt.NewClock("b")
+
+	base := internal.ToDo{ID: "1", Title: "Draft"}
+
+	first := base
+	first.Title = "First"
+	first.Stamp(&base, a.Now())
+
+	if err := b.Update(first.Clocks["title"]); err != nil {
+		t.Fatal(err)
+	}
+
+	second := base
+	second.Title = "Second"
+	second.Stamp(&base, b.Now())
+
+	if merged := second.Merge(first); merged.Title != "Second" {
+		t.Fatalf("Expected the later write to win, got %s", merged.Title)
+	}
+
+	if merged := first.Merge(second); merged.Title != "Second" {
+		t.Fatalf("Expected the later write to win, got %s", merged.Title)
+	}
+}
+
+func testMissingClocksUseModTime(t *testing.T) {
+
+	now := time.Now()
+	due := now.Add(24 * time.Hour)
+
+	old := internal.ToDo{ID: "1", Title: "Old", ModTime: now.Add(-time.Hour)}
+	edited := internal.ToDo{ID: "1", Title: "Old", Due: &due, Clocks: internal.Clocks{"due": crdt.FromTime(now)}}
+
+	merged := old.Merge(edited)
+
+	if merged.Due == nil || !merged.Due.Equal(due) {
+		t.Fatalf("Expected due to be set, got %v", merged.Due)
+	}
+
+	if merged.Clocks["title"] != crdt.FromTime(old.ModTime) {
+		t.Fatalf("Expected title clock to be the ModTime, got %v", merged.Clocks["title"])
+	}
+}
+
+func testStamp(t *testing.T) {
+
+	c := crdt.NewClock("a")
+	first, second := c.Now(), c.Now()
+
+	previous := internal.ToDo{ID: "1", Title: "Title"}
+	previous.Stamp(nil, first)
+
+	if len(previous.Clocks) != 10 {
+		t.Fatalf("Expected every field to be stamped, got %v", previous.Clocks)
+	}
+
+	todo := previous
+	todo.Completed = true
+	todo.Stamp(&previous, second)
+
+	if todo.Clocks["completed"] != second || todo.Clocks["title"] != first {
+		t.Fatalf("Expected only completed to be stamped, got %v", todo.Clocks)
+	}
+}
+
+func testConcurrentTagEdits(t *testing.T) {
+
+	base := internal.ToDo{ID: "1", Title: "Rotate keys", Tags: []string{"infra", "security"}}
+	base.Stamp(nil, crdt.NewClock("server").Now())
+
+	a, b := crdt.NewClock("a"), crdt.NewClock("b")
+	for _, c := range []*crdt.Clock{a, b} {
+		if err := c.Update(base.Clocks["tags"]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// One client adds a tag while the other replaces one, removing infra
+	added := base
+	added.Tags = []string{"infra", "on-call", "security"}
+	added.Stamp(&base, a.Now())
+
+	replaced := base
+	replaced.Tags = []string{"q3", "security"}
+	replaced.Stamp(&base, b.Now())
+
+	ab := added.Merge(replaced)
+	ba := replaced.Merge(added)
+
+	expected := []string{"on-call", "q3", "security"}
+
+	for _, merged := range []internal.ToDo{ab, ba} {
+		if !reflect.DeepEqual(merged.Tags, expected) {
+			t.Fatalf("Expected tags %v, got %v", expected, merged.Tags)
+		}
+	}
+
+	if !reflect.DeepEqual(ab.Sets, ba.Sets) {
+		t.Fatalf("Expected merges to agree, got %v and %v", ab.Sets, ba.Sets)
+	}
+
+	if again := ab.Merge(replaced); !reflect.DeepEqual(again, ab) {
+		t.Fatal("Expected merge to be idempotent")
+	}
+
+	// A tag removed on one replica and added again on another is kept
+	removed := ab
+	removed.Tags = []string{"q3", "security"}
+	removed.Stamp(&ab, a.Now())
+
+	readded := ab
+	readded.Tags = []string{"on-call", "q3", "security"}
+	readded.Sets = nil
+	readded.Stamp(&base, b.Now())
+
+	if merged := removed.Merge(readded); !reflect.DeepEqual(merged.Tags, expected) {
+		t.Fatalf("Expected the concurrent add to win, got %v", merged.Tags)
+	}
+}
+
+func testTagsFromClientWithoutSets(t *testing.T) {
+
+	server := crdt.NewClock("server")
+
+	todo := internal.ToDo{ID: "1", Title: "Rotate keys", Tags: []string{"infra"}}
+	todo.Stamp(nil, server.Now())
+
+	earlier := todo.Clocks["tags"]
+
+	later := todo
+	later.Tags = []string{"infra", "security"}
+	later.Stamp(&todo, server.Now())
+
+	// A client that does not record sets sends a write to tags that is older than the server's
+	stale := internal.ToDo{ID: "1", Title: "Rotate keys", Tags: []string{"old"}, Clocks: internal.Clocks{"tags": earlier}}
+
+	if merged := later.Merge(stale); !reflect.DeepEqual(merged.Tags, later.Tags) {
+		t.Fatalf("Expected the older write to be ignored, got %v", merged.Tags)
+	}
+
+	// and one that is newer, which replaces the tags
+	fresh := stale
+	fresh.Clocks = internal.Clocks{"tags": server.Now()}
+
+	if merged := later.Merge(fresh); !reflect.DeepEqual(merged.Tags, []string{"old"}) {
+		t.Fatalf("Expected the newer write to replace the tags, got %v", merged.Tags)
+	}
+}
+
+func testConcurrentChecklistEdits(t *testing.T) {
+
+	base := internal.ToDo{ID: "1", Title: "Release", Checklist: []internal.ChecklistItem{
+		{ID: "tag", Title: "Tag"},
+		{ID: "build", Title: "Build"},
+	}}
+	base.Stamp(nil, crdt.NewClock("server").Now())
+
+	a, b := crdt.NewClock("a"), crdt.NewClock("b")
+	for _, c := range []*crdt.Clock{a, b} {
+		if err := c.Update(base.Clocks["checklist"]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// One client checks an item and adds another while the other removes one and adds its own
+	checked := base
+	checked.Checklist = []internal.ChecklistItem{
+		{ID: "tag", Title: "Tag", Checked: true},
+		{ID: "build", Title: "Build"},
+		{ID: "publish", Title: "Publish"},
+	}
+	checked.Stamp(&base, a.Now())
+
+	edited := base
+	edited.Checklist = []internal.ChecklistItem{
+		{ID: "tag", Title: "Tag"},
+		{ID: "announce", Title: "Announce"},
+	}
+	edited.Stamp(&base, b.Now())
+
+	ab := checked.Merge(edited)
+	ba := edited.Merge(checked)
+
+	if !reflect.DeepEqual(ab, ba) {
+		t.Fatalf("Expected merges to agree, got %v and %v", ab.Checklist, ba.Checklist)
+	}
+
+	// The later write orders the items and decides whether they are checked
+	expected := []internal.ChecklistItem{
+		{ID: "tag", Title: "Tag"},
+		{ID: "announce", Title: "Announce"},
+		{ID: "publish", Title: "Publish"},
+	}
+
+	if !reflect.DeepEqual(ab.Checklist, expected) {
+		t.Fatalf("Expected checklist %v, got %v", expected, ab.Checklist)
+	}
+}
+
+func testConcurrentAssigneeEdits(t *testing.T) {
+
+	base := internal.ToDo{ID: "1", Title: "Rotate keys", AssigneeIDs: []string{"sam"}}
+	base.Stamp(nil, crdt.NewClock("server").Now())
+
+	a, b := crdt.NewClock("a"), crdt.NewClock("b")
+	for _, c := range []*crdt.Clock{a, b} {
+		if err := c.Update(base.Clocks["assigneeIds"]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	added := base
+	added.AssigneeIDs = []string{"alex", "sam"}
+	added.Stamp(&base, a.Now())
+
+	reassigned := base
+	reassigned.AssigneeIDs = []string{"kim"}
+	reassigned.Stamp(&base, b.Now())
+
+	expected := []string{"alex", "kim"}
+
+	for _, merged := range []internal.ToDo{added.Merge(reassigned), reassigned.Merge(added)} {
+		if !reflect.DeepEqual(merged.AssigneeIDs, expected) {
+			t.Fatalf("Expected assignees %v, got %v", expected, merged.AssigneeIDs)
+		}
+	}
+}
+
+func testRemovedElementsArePruned(t *testing.T) {
+
+	c := crdt.NewClock("server")
+
+	todo := internal.ToDo{ID: "1", Title: "Water the plants"}
+	todo.Stamp(nil, c.Now())
+
+	// Replacing the only item of a checklist removes an element from its set every time
+	for i := 0; i < 2000; i++ {
+		previous := todo
+		todo.Checklist = []internal.ChecklistItem{{ID: uuid(i), Title: "Water the plants"}}
+		todo.Stamp(&previous, c.Now())
+	}
+
+	b, err := json.Marshal(todo.Sets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b) > 64*1024 {
+		t.Fatalf("Expected the sets to be pruned, got %d bytes", len(b))
+	}
+
+	// Elements removed longer ago than the retention are forgotten on the next write
+	previous := todo
+	todo.Checklist = nil
+	todo.Stamp(&previous, crdt.FromTime(time.Now().Add(internal.TombstoneRetention+time.Hour)))
+
+	if set := todo.Sets["checklist"]; len(set.Adds) != 1 || len(set.Removes) != 1 {
+		t.Fatalf("Expected only the last removed item, got %v", set)
+	}
+}
+
+// uuid returns a unique ID of the same length as a UUID
+func uuid(i int) string {
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", i)
+}
@@ -0,0 +1,147 @@
+// Package realtime pushes ToDo changes to the clients subscribed to a list: in process for the standalone
+// server, and through API Gateway WebSocket connections in Lambda.
+package realtime
+
+import (
+	"sync"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+const (
+	// subscriberBuffer is the number of events queued for a subscriber before it is considered too slow
+	subscriberBuffer = 64
+	// historySize is the number of recent events kept, so subscribers that reconnect can resume
+	historySize = 1024
+)
+
+// Event is a change published by a Hub. IDs increase with every event, and start from the time the Hub
+// was created, so IDs from an earlier process are not mistaken for recent ones.
+type Event struct {
+	ID uint64 `json:"id"`
+	internal.Change
+}
+
+// Hub fans changes out to subscribers in the same process and keeps the most recent in a ring buffer. It
+// is a notify.Publisher and is safe for concurrent use.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]bool
+	history     []Event
+	head        int
+	next        uint64
+}
+
+// Subscription receives the changes made to a list on C. C is closed when the subscription is closed, or
+// when the subscriber falls more than subscriberBuffer events behind, after which it must resume from the
+// last event it received.
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	listID string
+	hub    *Hub
+}
+
+// NewHub returns a Hub without subscribers
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[*Subscription]bool),
+		history:     make([]Event, 0, historySize),
+		next:        uint64(time.Now().UnixNano()),
+	}
+}
+
+// Subscribe returns a subscription to the changes made to a list
+func (h *Hub) Subscribe(listID string) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.subscribe(listID)
+}
+
+// Resume returns a subscription to the changes made to a list, along with the events after lastID that
+// were missed. It reports false if events after lastID are no longer kept, in which case the subscriber
+// must fetch the list again.
+func (h *Hub) Resume(listID string, lastID uint64) (*Subscription, []Event, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	oldest := h.next - uint64(len(h.history))
+	if lastID+1 < oldest || lastID >= h.next {
+		return h.subscribe(listID), nil, false
+	}
+
+	missed := []Event{}
+	for i := range h.history {
+		e := h.history[(h.head+i)%historySize]
+		if e.ID > lastID && e.ListID() == listID {
+			missed = append(missed, e)
+		}
+	}
+
+	return h.subscribe(listID), missed, true
+}
+
+// LastID returns the ID of the last event published, which subscribers that have seen every event
+// resume from
+func (h *Hub) LastID() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.next - 1
+}
+
+// Publish sends change to the subscribers of its list without blocking
+func (h *Hub) Publish(change internal.Change) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e := Event{ID: h.next, Change: change}
+	h.next++
+
+	if len(h.history) < historySize {
+		h.history = append(h.history, e)
+	} else {
+		h.history[h.head] = e
+		h.head = (h.head + 1) % historySize
+	}
+
+	for s := range h.subscribers {
+		if s.listID != change.ListID() {
+			continue
+		}
+
+		select {
+		case s.c <- e:
+		default:
+			h.remove(s)
+		}
+	}
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.remove(s)
+}
+
+// subscribe adds a subscription to a list. The hub must be locked.
+func (h *Hub) subscribe(listID string) *Subscription {
+	c := make(chan Event, subscriberBuffer)
+	s := &Subscription{C: c, c: c, listID: listID, hub: h}
+
+	h.subscribers[s] = true
+
+	return s
+}
+
+// remove closes s if it is subscribed. The hub must be locked.
+func (h *Hub) remove(s *Subscription) {
+	if h.subscribers[s] {
+		delete(h.subscribers, s)
+		close(s.c)
+	}
+}
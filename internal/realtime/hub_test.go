@@ -0,0 +1,145 @@
+package realtime_test
+
+import (
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/realtime"
+)
+
+func TestHub(t *testing.T) {
+	t.Run("PublishToList", testPublishToList)
+	t.Run("SlowSubscriberDropped", testSlowSubscriberDropped)
+	t.Run("CloseTwice", testCloseTwice)
+	t.Run("Resume", testResume)
+	t.Run("ResumeGap", testResumeGap)
+}
+
+func testPublishToList(t *testing.T) {
+
+	hub := realtime.NewHub()
+
+	work := hub.Subscribe("work")
+	defer work.Close()
+
+	home := hub.Subscribe(internal.DefaultListID)
+	defer home.Close()
+
+	hub.Publish(internal.Change{Type: internal.ChangeCreated, ToDo: internal.ToDo{ID: "1", ListID: "work"}})
+	hub.Publish(internal.Change{Type: internal.ChangeDeleted, ToDo: internal.ToDo{ID: "2"}})
+
+	if c := <-work.C; c.ToDo.ID != "1" {
+		t.Fatalf("Expected change to ToDo 1, got %+v", c)
+	}
+
+	if c := <-home.C; c.ToDo.ID != "2" || c.Type != internal.ChangeDeleted {
+		t.Fatalf("Expected ToDo 2 to be deleted, got %+v", c)
+	}
+
+	select {
+	case c := <-work.C:
+		t.Fatalf("Expected no more changes to work, got %+v", c)
+	default:
+	}
+}
+
+func testSlowSubscriberDropped(t *testing.T) {
+
+	hub := realtime.NewHub()
+
+	sub := hub.Subscribe(internal.DefaultListID)
+
+	for i := 0; i < 1000; i++ {
+		hub.Publish(internal.Change{Type: internal.ChangeUpdated})
+	}
+
+	n := 0
+	for range sub.C {
+		n++
+	}
+
+	if n == 0 || n == 1000 {
+		t.Fatalf("Expected the subscriber to be dropped once its buffer filled, got %d changes", n)
+	}
+
+	sub.Close()
+}
+
+func testCloseTwice(t *testing.T) {
+
+	hub := realtime.NewHub()
+
+	sub := hub.Subscribe(internal.DefaultListID)
+	sub.Close()
+	sub.Close()
+
+	if _, ok := <-sub.C; ok {
+		t.Fatal("Expected the subscription to be closed")
+	}
+
+	// Publishing to a hub without subscribers does nothing
+	hub.Publish(internal.Change{Type: internal.ChangeUpdated})
+}
+
+func testResume(t *testing.T) {
+
+	hub := realtime.NewHub()
+
+	last := hub.LastID()
+
+	hub.Publish(internal.Change{Type: internal.ChangeCreated, ToDo: internal.ToDo{ID: "1"}})
+	hub.Publish(internal.Change{Type: internal.ChangeCreated, ToDo: internal.ToDo{ID: "other", ListID: "work"}})
+	hub.Publish(internal.Change{Type: internal.ChangeUpdated, ToDo: internal.ToDo{ID: "1"}})
+
+	sub, missed, ok := hub.Resume(internal.DefaultListID, last)
+	defer sub.Close()
+
+	if !ok {
+		t.Fatal("Expected to resume")
+	}
+
+	if len(missed) != 2 || missed[0].ID != last+1 || missed[1].ID != last+3 {
+		t.Fatalf("Expected the 2 changes to the default list, got %+v", missed)
+	}
+
+	// Resuming from the last event misses nothing
+	sub2, missed, ok := hub.Resume(internal.DefaultListID, hub.LastID())
+	defer sub2.Close()
+
+	if !ok || len(missed) != 0 {
+		t.Fatalf("Expected to resume without missed events, got %v and %+v", ok, missed)
+	}
+
+	hub.Publish(internal.Change{Type: internal.ChangeDeleted, ToDo: internal.ToDo{ID: "1"}})
+
+	if e := <-sub.C; e.ID != last+4 || e.Type != internal.ChangeDeleted {
+		t.Fatalf("Expected ToDo 1 to be deleted, got %+v", e)
+	}
+}
+
+func testResumeGap(t *testing.T) {
+
+	hub := realtime.NewHub()
+
+	first := hub.LastID() + 1
+
+	for i := 0; i < 2000; i++ {
+		hub.Publish(internal.Change{Type: internal.ChangeUpdated})
+	}
+
+	for _, last := range []uint64{first, 0, hub.LastID() + 1} {
+		sub, missed, ok := hub.Resume(internal.DefaultListID, last)
+		sub.Close()
+
+		if ok || len(missed) != 0 {
+			t.Fatalf("Expected not to resume from %d, got %v and %d events", last, ok, len(missed))
+		}
+	}
+
+	sub, missed, ok := hub.Resume(internal.DefaultListID, hub.LastID()-10)
+	sub.Close()
+
+	if !ok || len(missed) != 10 {
+		t.Fatalf("Expected to resume with 10 missed events, got %v and %d", ok, len(missed))
+	}
+}
@@ -0,0 +1,76 @@
+package realtime
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/apigatewaymanagementapi"
+	"github.com/aws/aws-sdk-go/service/apigatewaymanagementapi/apigatewaymanagementapiiface"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/pkg/errors"
+)
+
+// ConnectionPublisher sends changes to the API Gateway WebSocket connections subscribed to their list. It
+// is a notify.Publisher.
+type ConnectionPublisher struct {
+	connections database.ConnectionRepo
+	api         apigatewaymanagementapiiface.ApiGatewayManagementApiAPI
+}
+
+// NewConnectionPublisher returns a ConnectionPublisher that finds subscribers in connections and posts to
+// them through api, which must use the endpoint of the WebSocket API's stage
+func NewConnectionPublisher(connections database.ConnectionRepo,
+	api apigatewaymanagementapiiface.ApiGatewayManagementApiAPI) *ConnectionPublisher {
+	return &ConnectionPublisher{
+		connections: connections,
+		api:         api,
+	}
+}
+
+// Publish sends change to every connection subscribed to its list. Failures are logged, as the change
+// has already been saved.
+func (p *ConnectionPublisher) Publish(change internal.Change) {
+	if err := p.publish(change); err != nil {
+		log.Printf("Could not publish change to ToDo %s: %v", change.ToDo.ID, err)
+	}
+}
+
+// publish sends change to every connection subscribed to its list and removes the connections that have
+// gone away. It returns the last error, after trying every connection.
+func (p *ConnectionPublisher) publish(change internal.Change) error {
+	connections, err := p.connections.GetByList(change.ListID())
+	if err != nil {
+		return err
+	}
+
+	if len(connections) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(change)
+	if err != nil {
+		return errors.Wrap(err, "Could not marshal change")
+	}
+
+	var last error
+
+	for _, c := range connections {
+		_, err := p.api.PostToConnection(&apigatewaymanagementapi.PostToConnectionInput{
+			ConnectionId: aws.String(c.ID),
+			Data:         b,
+		})
+
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == apigatewaymanagementapi.ErrCodeGoneException {
+			err = p.connections.Delete(c.ID)
+		}
+
+		if err != nil {
+			last = errors.Wrapf(err, "Could not post to connection %s", c.ID)
+		}
+	}
+
+	return last
+}
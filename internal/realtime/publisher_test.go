@@ -0,0 +1,128 @@
+package realtime_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/apigatewaymanagementapi"
+	"github.com/aws/aws-sdk-go/service/apigatewaymanagementapi/apigatewaymanagementapiiface"
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/realtime"
+)
+
+// APIMock is used to mock the API Gateway management API
+type APIMock struct {
+	apigatewaymanagementapiiface.ApiGatewayManagementApiAPI
+	PostToConnectionFn func(*apigatewaymanagementapi.PostToConnectionInput) (*apigatewaymanagementapi.PostToConnectionOutput, error)
+}
+
+// PostToConnection sends data to a connection
+func (m *APIMock) PostToConnection(input *apigatewaymanagementapi.PostToConnectionInput) (*apigatewaymanagementapi.PostToConnectionOutput, error) {
+	return m.PostToConnectionFn(input)
+}
+
+// ConnectionRepoMock is used to mock the repository of connections
+type ConnectionRepoMock struct {
+	GetByListFn func(string) ([]internal.Connection, error)
+	SaveFn      func(*internal.Connection) error
+	DeleteFn    func(string) error
+}
+
+// GetByList returns the connections subscribed to a list
+func (m *ConnectionRepoMock) GetByList(listID string) ([]internal.Connection, error) {
+	return m.GetByListFn(listID)
+}
+
+// Save stores a connection
+func (m *ConnectionRepoMock) Save(connection *internal.Connection) error {
+	return m.SaveFn(connection)
+}
+
+// Delete removes a connection
+func (m *ConnectionRepoMock) Delete(id string) error {
+	return m.DeleteFn(id)
+}
+
+func TestConnectionPublisher(t *testing.T) {
+	t.Run("PublishToConnections", testPublishToConnections)
+	t.Run("GoneConnectionDeleted", testGoneConnectionDeleted)
+}
+
+func testPublishToConnections(t *testing.T) {
+
+	connections := &ConnectionRepoMock{
+		GetByListFn: func(listID string) ([]internal.Connection, error) {
+			if listID != "work" {
+				t.Fatalf("Expected connections to work, got %s", listID)
+			}
+			return []internal.Connection{{ID: "a"}, {ID: "b"}}, nil
+		},
+	}
+
+	posted := make(map[string]internal.Change)
+
+	api := &APIMock{
+		PostToConnectionFn: func(input *apigatewaymanagementapi.PostToConnectionInput) (*apigatewaymanagementapi.PostToConnectionOutput, error) {
+			var c internal.Change
+			if err := json.Unmarshal(input.Data, &c); err != nil {
+				t.Fatal(err)
+			}
+			posted[*input.ConnectionId] = c
+			return &apigatewaymanagementapi.PostToConnectionOutput{}, nil
+		},
+	}
+
+	p := realtime.NewConnectionPublisher(connections, api)
+	p.Publish(internal.Change{Type: internal.ChangeCreated, ToDo: internal.ToDo{ID: "1", ListID: "work"}})
+
+	if len(posted) != 2 {
+		t.Fatalf("Expected the change to be posted to 2 connections, got %d", len(posted))
+	}
+
+	if c := posted["a"]; c.Type != internal.ChangeCreated || c.ToDo.ID != "1" {
+		t.Fatalf("Unexpected change %+v", c)
+	}
+}
+
+func testGoneConnectionDeleted(t *testing.T) {
+
+	var deleted []string
+
+	connections := &ConnectionRepoMock{
+		GetByListFn: func(string) ([]internal.Connection, error) {
+			return []internal.Connection{{ID: "gone"}, {ID: "broken"}, {ID: "ok"}}, nil
+		},
+		DeleteFn: func(id string) error {
+			deleted = append(deleted, id)
+			return nil
+		},
+	}
+
+	var posted []string
+
+	api := &APIMock{
+		PostToConnectionFn: func(input *apigatewaymanagementapi.PostToConnectionInput) (*apigatewaymanagementapi.PostToConnectionOutput, error) {
+			posted = append(posted, *input.ConnectionId)
+			switch *input.ConnectionId {
+			case "gone":
+				return nil, awserr.New(apigatewaymanagementapi.ErrCodeGoneException, "Gone", nil)
+			case "broken":
+				return nil, errors.New("Network Error")
+			}
+			return &apigatewaymanagementapi.PostToConnectionOutput{}, nil
+		},
+	}
+
+	p := realtime.NewConnectionPublisher(connections, api)
+	p.Publish(internal.Change{Type: internal.ChangeDeleted, ToDo: internal.ToDo{ID: "1"}})
+
+	if len(posted) != 3 {
+		t.Fatalf("Expected every connection to be tried, got %v", posted)
+	}
+
+	if len(deleted) != 1 || deleted[0] != "gone" {
+		t.Fatalf("Expected only the gone connection to be deleted, got %v", deleted)
+	}
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"time"
+
+	"github.com/benjaminbartels/todo/internal/rrule"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Location returns the time zone the ToDo's recurrence rule is expanded in
+func (t *ToDo) Location() (*time.Location, error) {
+	if t.TimeZone == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(t.TimeZone)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not load time zone %s", t.TimeZone)
+	}
+
+	return loc, nil
+}
+
+// NextOccurrence returns the occurrence of a recurring ToDo that follows it, due at the next time its rule
+// occurs after its due date, or nil if it is not recurring or its rule has ended. The ID of an occurrence
+// is derived from its series and due date, so the same occurrence is never created twice.
+func (t *ToDo) NextOccurrence() (*ToDo, error) {
+	if t.RRule == "" || t.Due == nil {
+		return nil, nil
+	}
+
+	rule, err := rrule.Parse(t.RRule)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not parse rule of ToDo %s", t.ID)
+	}
+
+	loc, err := t.Location()
+	if err != nil {
+		return nil, err
+	}
+
+	start := *t.Due
+	if t.SeriesStart != nil {
+		start = *t.SeriesStart
+	}
+
+	next, ok := rule.After(start.In(loc), *t.Due)
+	if !ok {
+		return nil, nil
+	}
+
+	seriesID := t.SeriesID
+	if seriesID == "" {
+		seriesID = t.ID
+	}
+
+	due, seriesStart := next.UTC(), start.UTC()
+
+	// Every item of the checklist has to be done again
+	var checklist []ChecklistItem
+	for _, item := range t.Checklist {
+		item.Checked = false
+		checklist = append(checklist, item)
+	}
+
+	return &ToDo{
+		ID:              OccurrenceID(seriesID, due),
+		ListID:          t.ListID,
+		Title:           t.Title,
+		Notes:           t.Notes,
+		Due:             &due,
+		Checklist:       checklist,
+		Tags:            append([]string(nil), t.Tags...),
+		AssigneeIDs:     append([]string(nil), t.AssigneeIDs...),
+		EstimateMinutes: t.EstimateMinutes,
+		AutoComplete:    t.AutoComplete,
+		RRule:           t.RRule,
+		TimeZone:        t.TimeZone,
+		SeriesID:        seriesID,
+		SeriesStart:     &seriesStart,
+	}, nil
+}
+
+// OccurrenceID returns the ID of the occurrence of a series that is due at due
+func OccurrenceID(seriesID string, due time.Time) string {
+	return uuid.NewV5(uuid.NamespaceURL, seriesID+"@"+due.UTC().Format(time.RFC3339)).String()
+}
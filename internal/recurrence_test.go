@@ -0,0 +1,167 @@
+package internal_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+func TestNextOccurrence(t *testing.T) {
+	t.Run("Occurrences", testOccurrences)
+	t.Run("OccurrenceKeepsSeries", testOccurrenceKeepsSeries)
+	t.Run("OccurrenceResetsChecklist", testOccurrenceResetsChecklist)
+	t.Run("OccurrenceKeepsDetails", testOccurrenceKeepsDetails)
+	t.Run("NotRecurring", testNotRecurring)
+}
+
+func testOccurrences(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		rrule    string
+		timeZone string
+		start    string
+		due      string
+		next     string
+	}{
+		{"Daily", "FREQ=DAILY", "", "", "2019-03-01T09:00:00Z", "2019-03-02T09:00:00Z"},
+		{"WeeklyOnMonday", "FREQ=WEEKLY;BYDAY=MO", "", "", "2019-07-01T09:00:00Z", "2019-07-08T09:00:00Z"},
+		{"LastDayOfMonth", "FREQ=MONTHLY;BYMONTHDAY=-1", "", "", "2019-01-31T09:00:00Z", "2019-02-28T09:00:00Z"},
+		{"FromSeriesStart", "FREQ=DAILY;INTERVAL=3", "", "2019-03-01T09:00:00Z", "2019-03-02T12:00:00Z",
+			"2019-03-04T09:00:00Z"},
+		{"Ended", "FREQ=DAILY;COUNT=2", "", "2019-03-01T09:00:00Z", "2019-03-02T09:00:00Z", ""},
+		{"AcrossDST", "FREQ=WEEKLY;BYDAY=FR", "America/New_York", "", "2019-03-08T14:00:00Z",
+			"2019-03-15T13:00:00Z"},
+	}
+
+	for _, test := range tests {
+		due, _ := time.Parse(time.RFC3339, test.due)
+		start := due
+		if test.start != "" {
+			start, _ = time.Parse(time.RFC3339, test.start)
+		}
+
+		todo := internal.ToDo{ID: "1", Title: "On-call handoff", Due: &due, SeriesStart: &start,
+			RRule: test.rrule, TimeZone: test.timeZone}
+
+		next, err := todo.NextOccurrence()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if test.next == "" {
+			if next != nil {
+				t.Fatalf("%s: Expected no occurrence, got %v", test.name, next.Due)
+			}
+			continue
+		}
+
+		if next == nil || next.Due.Format(time.RFC3339) != test.next {
+			t.Fatalf("%s: Expected next occurrence due %s, got %v", test.name, test.next, next)
+		}
+	}
+}
+
+func testOccurrenceKeepsSeries(t *testing.T) {
+
+	due := time.Date(2019, 7, 1, 9, 0, 0, 0, time.UTC)
+	todo := internal.ToDo{ID: "1", Title: "Rotate certs", Due: &due, RRule: "FREQ=MONTHLY", ListID: "ops"}
+
+	next, err := todo.NextOccurrence()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next.SeriesID != "1" || !next.SeriesStart.Equal(due) || next.ListID != "ops" || next.Completed {
+		t.Fatalf("Expected an open occurrence of series 1, got %v", next)
+	}
+
+	again, err := todo.NextOccurrence()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again.ID != next.ID || next.ID == todo.ID {
+		t.Fatalf("Expected the same new ID for the occurrence, got %s and %s", next.ID, again.ID)
+	}
+
+	after, err := next.NextOccurrence()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if after.SeriesID != "1" || after.Due.Month() != time.September {
+		t.Fatalf("Expected the occurrence after to be due in September, got %v", after)
+	}
+}
+
+func testOccurrenceResetsChecklist(t *testing.T) {
+
+	due := time.Date(2019, 7, 1, 9, 0, 0, 0, time.UTC)
+	todo := internal.ToDo{ID: "1", Title: "Release", Due: &due, RRule: "FREQ=WEEKLY", Completed: true,
+		Checklist: []internal.ChecklistItem{{ID: "tag", Title: "Tag", Checked: true}}}
+
+	next, err := todo.NextOccurrence()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(next.Checklist) != 1 || next.Checklist[0].Checked || !todo.Checklist[0].Checked {
+		t.Fatalf("Expected an unchecked copy of the checklist, got %v", next.Checklist)
+	}
+}
+
+func testOccurrenceKeepsDetails(t *testing.T) {
+
+	due := time.Date(2019, 7, 1, 9, 0, 0, 0, time.UTC)
+	todo := internal.ToDo{ID: "1", Title: "Pay rent", Notes: "Transfer to **account 2**", Due: &due,
+		RRule: "FREQ=MONTHLY", Tags: []string{"bills", "home"}, AssigneeIDs: []string{"user-1"},
+		EstimateMinutes: 15}
+
+	next, err := todo.NextOccurrence()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next.Notes != todo.Notes {
+		t.Fatalf("Expected notes %q, got %q", todo.Notes, next.Notes)
+	}
+
+	if !reflect.DeepEqual(next.Tags, todo.Tags) {
+		t.Fatalf("Expected tags %v, got %v", todo.Tags, next.Tags)
+	}
+
+	if !reflect.DeepEqual(next.AssigneeIDs, todo.AssigneeIDs) {
+		t.Fatalf("Expected assignees %v, got %v", todo.AssigneeIDs, next.AssigneeIDs)
+	}
+
+	if next.EstimateMinutes != 15 {
+		t.Fatalf("Expected an estimate of 15 minutes, got %d", next.EstimateMinutes)
+	}
+
+	// The occurrence has its own copy, so editing it does not change the ToDo it follows
+	next.Tags[0] = "paid"
+
+	if todo.Tags[0] != "bills" {
+		t.Fatal("Expected the tags of the ToDo to be unchanged")
+	}
+}
+
+func testNotRecurring(t *testing.T) {
+
+	due := time.Now()
+
+	for _, todo := range []internal.ToDo{{ID: "1", Due: &due}, {ID: "2", RRule: "FREQ=DAILY"}} {
+		next, err := todo.NextOccurrence()
+		if err != nil || next != nil {
+			t.Fatalf("Expected no occurrence of %s, got %v, %v", todo.ID, next, err)
+		}
+	}
+
+	todo := internal.ToDo{ID: "3", Due: &due, RRule: "FREQ=HOURLY"}
+	if _, err := todo.NextOccurrence(); err == nil {
+		t.Fatal("Expected an error for an unsupported rule")
+	}
+}
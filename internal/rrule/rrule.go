@@ -0,0 +1,493 @@
+// Package rrule parses and expands RFC 5545 recurrence rules, such as FREQ=WEEKLY;BYDAY=MO.
+//
+// Rules with a frequency of DAILY, WEEKLY, MONTHLY or YEARLY are supported, with the INTERVAL, COUNT, UNTIL,
+// BYDAY, BYMONTHDAY, BYMONTH and WKST parts. Occurrences are expanded in the time zone of the start of the
+// series, so they keep their local time of day when daylight saving time starts or ends.
+package rrule
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Frequency is how often a rule repeats
+type Frequency int
+
+// Frequencies
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+// WeekdayNum is a BYDAY value. For monthly and yearly rules N selects which occurrence of the weekday in
+// the month or year it is, counting from the end when negative, or every occurrence when zero.
+type WeekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+// Rule is a recurrence rule
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	// Count is the number of occurrences, or zero if there is no limit
+	Count int
+	// Until is the last time an occurrence can be at, or zero if there is none. UntilLocal is set when it
+	// was given without a time zone, so it is in the time zone of the start of the series.
+	Until      time.Time
+	UntilLocal bool
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+const (
+	// maxGap and minEmptyPeriods bound the runs of periods without any days that match a rule. Expansion
+	// stops after a run that is longer than both, so rules that can never occur, such as the 30th of
+	// February, end. Periods that have days never end a rule, however long its interval is.
+	maxGap          = 10 * 366 * 24 * time.Hour
+	minEmptyPeriods = 12
+)
+
+var (
+	frequencies = map[string]Frequency{"DAILY": Daily, "WEEKLY": Weekly, "MONTHLY": Monthly, "YEARLY": Yearly}
+	weekdays    = map[string]time.Weekday{
+		"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+		"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+	}
+)
+
+// Parse parses a rule, with or without the RRULE: prefix
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 6 && strings.EqualFold(s[:6], "RRULE:") {
+		s = s[6:]
+	}
+
+	r := &Rule{Interval: 1, WeekStart: time.Monday}
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, errors.Errorf("invalid rule part %q", part)
+		}
+
+		name, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		if seen[name] {
+			return nil, errors.Errorf("%s is given more than once", name)
+		}
+		seen[name] = true
+
+		var err error
+
+		switch name {
+		case "FREQ":
+			f, ok := frequencies[value]
+			if !ok {
+				return nil, errors.Errorf("unsupported FREQ %s", value)
+			}
+			r.Freq = f
+		case "INTERVAL":
+			r.Interval, err = parseInt(value, 1, 1<<16)
+		case "COUNT":
+			r.Count, err = parseInt(value, 1, 1<<16)
+		case "UNTIL":
+			r.Until, r.UntilLocal, err = parseUntil(value)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseList(value, -31, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseList(value, 1, 12)
+			for _, m := range months {
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+			}
+		case "WKST":
+			d, ok := weekdays[value]
+			if !ok {
+				return nil, errors.Errorf("invalid WKST %s", value)
+			}
+			r.WeekStart = d
+		default:
+			return nil, errors.Errorf("unsupported rule part %s", name)
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", name)
+		}
+	}
+
+	if !seen["FREQ"] {
+		return nil, errors.New("FREQ is required")
+	}
+
+	if seen["COUNT"] && seen["UNTIL"] {
+		return nil, errors.New("COUNT and UNTIL can not both be given")
+	}
+
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return nil, errors.New("BYMONTHDAY can not be used with FREQ=WEEKLY")
+	}
+
+	for _, d := range r.ByDay {
+		if d.N != 0 && r.Freq != Monthly && r.Freq != Yearly {
+			return nil, errors.New("BYDAY can only be numbered with FREQ=MONTHLY or FREQ=YEARLY")
+		}
+	}
+
+	return r, nil
+}
+
+// parseInt parses an integer between min and max
+func parseInt(s string, min, max int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return 0, errors.Errorf("%s is not a number from %d to %d", s, min, max)
+	}
+	return n, nil
+}
+
+// parseList parses a comma separated list of non-zero integers between min and max
+func parseList(s string, min, max int) ([]int, error) {
+	list := []int{}
+
+	for _, v := range strings.Split(s, ",") {
+		n, err := parseInt(v, min, max)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, errors.New("0 is not allowed")
+		}
+		list = append(list, n)
+	}
+
+	return list, nil
+}
+
+// parseUntil parses an UNTIL value, which is a UTC date-time, a local date-time or a date. A date is the
+// last day there can be an occurrence on.
+func parseUntil(s string) (time.Time, bool, error) {
+	if t, err := time.Parse("20060102T150405Z", s); err == nil {
+		return t, false, nil
+	}
+
+	if t, err := time.Parse("20060102T150405", s); err == nil {
+		return t, true, nil
+	}
+
+	if t, err := time.Parse("20060102", s); err == nil {
+		return t.Add(24*time.Hour - time.Second), true, nil
+	}
+
+	return time.Time{}, false, errors.Errorf("%s is not a date or date-time", s)
+}
+
+// parseByDay parses a BYDAY list, such as MO,-1FR
+func parseByDay(s string) ([]WeekdayNum, error) {
+	list := []WeekdayNum{}
+
+	for _, v := range strings.Split(s, ",") {
+		if len(v) < 2 {
+			return nil, errors.Errorf("%s is not a weekday", v)
+		}
+
+		d, ok := weekdays[v[len(v)-2:]]
+		if !ok {
+			return nil, errors.Errorf("%s is not a weekday", v)
+		}
+
+		n := 0
+		if prefix := v[:len(v)-2]; prefix != "" {
+			var err error
+			if n, err = parseInt(strings.TrimPrefix(prefix, "+"), -53, 53); err != nil || n == 0 {
+				return nil, errors.Errorf("%s is not a numbered weekday", v)
+			}
+		}
+
+		list = append(list, WeekdayNum{N: n, Weekday: d})
+	}
+
+	return list, nil
+}
+
+// After returns the first occurrence after t of the series that starts at start, and false if there is
+// none. Occurrences are at the time of day of start, in its time zone.
+func (r *Rule) After(start, t time.Time) (time.Time, bool) {
+	var next time.Time
+
+	r.each(start, func(o time.Time) bool {
+		if o.After(t) {
+			next = o
+			return false
+		}
+		return true
+	})
+
+	return next, !next.IsZero()
+}
+
+// Between returns the occurrences of the series that starts at start from from up to and including to
+func (r *Rule) Between(start, from, to time.Time) []time.Time {
+	occurrences := []time.Time{}
+
+	r.each(start, func(o time.Time) bool {
+		if o.After(to) {
+			return false
+		}
+		if !o.Before(from) {
+			occurrences = append(occurrences, o)
+		}
+		return true
+	})
+
+	return occurrences
+}
+
+// each calls fn with each occurrence of the series that starts at start, in order, until fn returns false
+// or there are no more
+func (r *Rule) each(start time.Time, fn func(time.Time) bool) {
+	loc := start.Location()
+	hour, min, sec := start.Clock()
+
+	until := r.Until
+	if r.UntilLocal {
+		until = time.Date(until.Year(), until.Month(), until.Day(), until.Hour(), until.Minute(), until.Second(), 0, loc)
+	}
+
+	first := date(start.Year(), start.Month(), start.Day())
+	count := 0
+
+	// empty is the number of periods without days since emptySince, the start of the first of them
+	empty, emptySince := 0, first
+
+	for period := 0; ; period++ {
+		days, periodStart := r.expand(first, period)
+
+		if len(days) == 0 {
+			if empty == 0 {
+				emptySince = periodStart
+			}
+
+			if empty++; empty >= minEmptyPeriods && periodStart.Sub(emptySince) > maxGap {
+				return
+			}
+
+			continue
+		}
+
+		empty = 0
+
+		for _, d := range days {
+			o := time.Date(d.Year(), d.Month(), d.Day(), hour, min, sec, start.Nanosecond(), loc)
+
+			if o.Before(start) {
+				continue
+			}
+
+			if !until.IsZero() && o.After(until) {
+				return
+			}
+
+			count++
+
+			if !fn(o) || r.Count > 0 && count >= r.Count {
+				return
+			}
+		}
+	}
+}
+
+// date returns a date as a time at midnight UTC, so adding days to it is not affected by time zones
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// daysIn returns the number of days in a month
+func daysIn(year int, month time.Month) int {
+	return date(year, month+1, 0).Day()
+}
+
+// expand returns the days of a period of the rule that match it, in order, and the first day of the period.
+// Periods are numbered from the one first is in.
+func (r *Rule) expand(first time.Time, period int) ([]time.Time, time.Time) {
+	switch r.Freq {
+	case Daily:
+		d := first.AddDate(0, 0, period*r.Interval)
+		if r.matchesMonth(d) && r.matchesMonthDay(d) && r.matchesWeekday(d) {
+			return []time.Time{d}, d
+		}
+		return nil, d
+	case Weekly:
+		offset := (int(first.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := first.AddDate(0, 0, period*r.Interval*7-offset)
+
+		days := []time.Time{}
+		for i := 0; i < 7; i++ {
+			d := weekStart.AddDate(0, 0, i)
+			if r.matchesMonth(d) && (len(r.ByDay) > 0 && r.matchesWeekday(d) || len(r.ByDay) == 0 && d.Weekday() == first.Weekday()) {
+				days = append(days, d)
+			}
+		}
+		return days, weekStart
+	case Monthly:
+		month := date(first.Year(), first.Month()+time.Month(period*r.Interval), 1)
+		if !r.matchesMonth(month) {
+			return nil, month
+		}
+		return r.monthDays(first, month.Year(), month.Month()), month
+	default:
+		year := first.Year() + period*r.Interval
+		yearStart := date(year, time.January, 1)
+
+		if len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0 && len(r.ByDay) > 0 {
+			return r.weekdaysIn(yearStart, date(year+1, time.January, 1)), yearStart
+		}
+
+		months := r.ByMonth
+		if len(months) == 0 {
+			if len(r.ByMonthDay) > 0 {
+				months = []time.Month{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+			} else {
+				months = []time.Month{first.Month()}
+			}
+		}
+
+		sorted := append([]time.Month{}, months...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		days := []time.Time{}
+		for i, m := range sorted {
+			if i > 0 && m == sorted[i-1] {
+				continue
+			}
+			days = append(days, r.monthDays(first, year, m)...)
+		}
+		return days, yearStart
+	}
+}
+
+// monthDays returns the days of a month that match the BYMONTHDAY and BYDAY parts of the rule, in order,
+// or the day of the month of first if it has neither
+func (r *Rule) monthDays(first time.Time, year int, month time.Month) []time.Time {
+	n := daysIn(year, month)
+
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if first.Day() > n {
+			return nil
+		}
+		return []time.Time{date(year, month, first.Day())}
+	}
+
+	var days []time.Time
+
+	if len(r.ByDay) > 0 {
+		days = r.weekdaysIn(date(year, month, 1), date(year, month+1, 1))
+	} else {
+		for d := 1; d <= n; d++ {
+			days = append(days, date(year, month, d))
+		}
+	}
+
+	matched := []time.Time{}
+	for _, d := range days {
+		if r.matchesMonthDay(d) {
+			matched = append(matched, d)
+		}
+	}
+
+	return matched
+}
+
+// weekdaysIn returns the days from from until to that match the BYDAY part of the rule, in order. Numbered
+// weekdays are counted within the range.
+func (r *Rule) weekdaysIn(from, to time.Time) []time.Time {
+	byWeekday := make(map[time.Weekday][]time.Time)
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		byWeekday[d.Weekday()] = append(byWeekday[d.Weekday()], d)
+	}
+
+	selected := make(map[time.Time]bool)
+
+	for _, wd := range r.ByDay {
+		days := byWeekday[wd.Weekday]
+
+		switch {
+		case wd.N == 0:
+			for _, d := range days {
+				selected[d] = true
+			}
+		case wd.N > 0 && wd.N <= len(days):
+			selected[days[wd.N-1]] = true
+		case wd.N < 0 && -wd.N <= len(days):
+			selected[days[len(days)+wd.N]] = true
+		}
+	}
+
+	matched := []time.Time{}
+	for d := range selected {
+		matched = append(matched, d)
+	}
+
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Before(matched[j]) })
+
+	return matched
+}
+
+// matchesMonth reports whether d is in a month of the BYMONTH part of the rule, if it has one
+func (r *Rule) matchesMonth(d time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+
+	for _, m := range r.ByMonth {
+		if d.Month() == m {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesMonthDay reports whether d is a day of the BYMONTHDAY part of the rule, if it has one. Negative
+// days count from the end of the month.
+func (r *Rule) matchesMonthDay(d time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+
+	n := daysIn(d.Year(), d.Month())
+
+	for _, md := range r.ByMonthDay {
+		if md > 0 && d.Day() == md || md < 0 && d.Day() == n+md+1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesWeekday reports whether d is a weekday of the BYDAY part of the rule, if it has one. It is used
+// by rules that can not number weekdays.
+func (r *Rule) matchesWeekday(d time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+
+	for _, wd := range r.ByDay {
+		if d.Weekday() == wd.Weekday {
+			return true
+		}
+	}
+
+	return false
+}
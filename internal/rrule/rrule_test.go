@@ -0,0 +1,294 @@
+package rrule_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal/rrule"
+)
+
+func TestRRule(t *testing.T) {
+	t.Run("Occurrences", testOccurrences)
+	t.Run("Between", testBetween)
+	t.Run("ParseErrors", testParseErrors)
+}
+
+// occurrences returns up to n occurrences of rule from start, and whether there are more
+func occurrences(t *testing.T, rule, zone, start string, n int) ([]string, bool) {
+	r, err := rrule.Parse(rule)
+	if err != nil {
+		t.Fatalf("Could not parse %s: %v", rule, err)
+	}
+
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := time.ParseInLocation("2006-01-02T15:04", start, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	last := s.Add(-time.Nanosecond)
+
+	for len(got) < n {
+		next, ok := r.After(s, last)
+		if !ok {
+			return got, false
+		}
+		got = append(got, next.Format(time.RFC3339))
+		last = next
+	}
+
+	_, more := r.After(s, last)
+
+	return got, more
+}
+
+func testOccurrences(t *testing.T) {
+
+	cases := []struct {
+		name  string
+		rule  string
+		zone  string
+		start string
+		want  []string
+		// more is whether the rule continues after want
+		more bool
+	}{
+		{
+			name:  "WeeklyByDay",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,WE",
+			zone:  "UTC",
+			start: "2019-07-01T09:00",
+			want:  []string{"2019-07-01T09:00:00Z", "2019-07-03T09:00:00Z", "2019-07-08T09:00:00Z", "2019-07-10T09:00:00Z"},
+			more:  true,
+		},
+		{
+			name:  "WeeklyStartNotInRule",
+			rule:  "RRULE:FREQ=WEEKLY;BYDAY=MO",
+			zone:  "UTC",
+			start: "2019-07-03T09:00",
+			want:  []string{"2019-07-08T09:00:00Z", "2019-07-15T09:00:00Z"},
+			more:  true,
+		},
+		{
+			name:  "EveryOtherWeekWithWeekStart",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;WKST=SU",
+			zone:  "UTC",
+			start: "2019-07-02T10:00",
+			want:  []string{"2019-07-02T10:00:00Z", "2019-07-04T10:00:00Z", "2019-07-16T10:00:00Z", "2019-07-18T10:00:00Z"},
+			more:  true,
+		},
+		{
+			name:  "LastDayOfMonth",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1",
+			zone:  "UTC",
+			start: "2019-12-31T08:00",
+			want: []string{"2019-12-31T08:00:00Z", "2020-01-31T08:00:00Z", "2020-02-29T08:00:00Z", "2020-03-31T08:00:00Z",
+				"2020-04-30T08:00:00Z"},
+			more: true,
+		},
+		{
+			name:  "MonthlySkipsMonthsWithoutTheDay",
+			rule:  "FREQ=MONTHLY",
+			zone:  "UTC",
+			start: "2019-01-31T08:00",
+			want:  []string{"2019-01-31T08:00:00Z", "2019-03-31T08:00:00Z", "2019-05-31T08:00:00Z"},
+			more:  true,
+		},
+		{
+			name:  "LastFridayOfMonth",
+			rule:  "FREQ=MONTHLY;BYDAY=-1FR",
+			zone:  "UTC",
+			start: "2019-07-01T17:00",
+			want:  []string{"2019-07-26T17:00:00Z", "2019-08-30T17:00:00Z", "2019-09-27T17:00:00Z"},
+			more:  true,
+		},
+		{
+			name:  "SecondTuesdayOfMonth",
+			rule:  "FREQ=MONTHLY;BYDAY=+2TU",
+			zone:  "UTC",
+			start: "2019-07-01T12:00",
+			want:  []string{"2019-07-09T12:00:00Z", "2019-08-13T12:00:00Z", "2019-09-10T12:00:00Z"},
+			more:  true,
+		},
+		{
+			name:  "FridayThe13th",
+			rule:  "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13",
+			zone:  "UTC",
+			start: "2019-01-01T00:00",
+			want:  []string{"2019-09-13T00:00:00Z", "2019-12-13T00:00:00Z", "2020-03-13T00:00:00Z"},
+			more:  true,
+		},
+		{
+			name:  "Thanksgiving",
+			rule:  "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH",
+			zone:  "America/New_York",
+			start: "2019-01-01T12:00",
+			want:  []string{"2019-11-28T12:00:00-05:00", "2020-11-26T12:00:00-05:00", "2021-11-25T12:00:00-05:00"},
+			more:  true,
+		},
+		{
+			name:  "LeapDay",
+			rule:  "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29",
+			zone:  "UTC",
+			start: "2020-02-29T00:00",
+			want:  []string{"2020-02-29T00:00:00Z", "2024-02-29T00:00:00Z", "2028-02-29T00:00:00Z"},
+			more:  true,
+		},
+		{
+			name:  "FirstMondayOfYear",
+			rule:  "FREQ=YEARLY;BYDAY=1MO",
+			zone:  "UTC",
+			start: "2019-01-01T09:00",
+			want:  []string{"2019-01-07T09:00:00Z", "2020-01-06T09:00:00Z"},
+			more:  true,
+		},
+		{
+			name:  "Count",
+			rule:  "FREQ=DAILY;COUNT=3",
+			zone:  "UTC",
+			start: "2019-07-01T09:00",
+			want:  []string{"2019-07-01T09:00:00Z", "2019-07-02T09:00:00Z", "2019-07-03T09:00:00Z"},
+		},
+		{
+			name:  "CountOnlyCountsMatches",
+			rule:  "FREQ=MONTHLY;BYDAY=MO,TU;COUNT=3",
+			zone:  "UTC",
+			start: "2019-07-03T09:00",
+			want:  []string{"2019-07-08T09:00:00Z", "2019-07-09T09:00:00Z", "2019-07-15T09:00:00Z"},
+		},
+		{
+			name:  "UntilUTC",
+			rule:  "FREQ=WEEKLY;UNTIL=20190715T000000Z",
+			zone:  "UTC",
+			start: "2019-07-01T09:00",
+			want:  []string{"2019-07-01T09:00:00Z", "2019-07-08T09:00:00Z"},
+		},
+		{
+			name:  "UntilIsInclusive",
+			rule:  "FREQ=WEEKLY;UNTIL=20190715T090000Z",
+			zone:  "UTC",
+			start: "2019-07-01T09:00",
+			want:  []string{"2019-07-01T09:00:00Z", "2019-07-08T09:00:00Z", "2019-07-15T09:00:00Z"},
+		},
+		{
+			name:  "UntilDateInTimeZone",
+			rule:  "FREQ=DAILY;UNTIL=20190703",
+			zone:  "Europe/Berlin",
+			start: "2019-07-01T23:30",
+			want:  []string{"2019-07-01T23:30:00+02:00", "2019-07-02T23:30:00+02:00", "2019-07-03T23:30:00+02:00"},
+		},
+		{
+			name:  "DaylightSavingTimeStarts",
+			rule:  "FREQ=DAILY",
+			zone:  "America/New_York",
+			start: "2019-03-09T09:00",
+			want:  []string{"2019-03-09T09:00:00-05:00", "2019-03-10T09:00:00-04:00", "2019-03-11T09:00:00-04:00"},
+			more:  true,
+		},
+		{
+			name:  "DaylightSavingTimeEnds",
+			rule:  "FREQ=WEEKLY;BYDAY=SU",
+			zone:  "Europe/London",
+			start: "2019-10-20T08:00",
+			want:  []string{"2019-10-20T08:00:00+01:00", "2019-10-27T08:00:00Z", "2019-11-03T08:00:00Z"},
+			more:  true,
+		},
+		{
+			name:  "YearlyLongerThanGap",
+			rule:  "FREQ=YEARLY;INTERVAL=11",
+			zone:  "UTC",
+			start: "2019-07-01T09:00",
+			want:  []string{"2019-07-01T09:00:00Z", "2030-07-01T09:00:00Z", "2041-07-01T09:00:00Z"},
+			more:  true,
+		},
+		{
+			name:  "MonthlyLongerThanGap",
+			rule:  "FREQ=MONTHLY;INTERVAL=121",
+			zone:  "UTC",
+			start: "2019-07-01T09:00",
+			want:  []string{"2019-07-01T09:00:00Z", "2029-08-01T09:00:00Z", "2039-09-01T09:00:00Z"},
+			more:  true,
+		},
+		{
+			name:  "LeapDayEveryElevenYears",
+			rule:  "FREQ=YEARLY;INTERVAL=11;BYMONTH=2;BYMONTHDAY=29",
+			zone:  "UTC",
+			start: "2020-02-29T09:00",
+			want:  []string{"2020-02-29T09:00:00Z", "2064-02-29T09:00:00Z"},
+			more:  true,
+		},
+		{
+			name:  "NeverOccurs",
+			rule:  "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+			zone:  "UTC",
+			start: "2019-01-01T00:00",
+			want:  []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		got, more := occurrences(t, tc.rule, tc.zone, tc.start, len(tc.want))
+
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, got)
+			}
+		}
+
+		if more != tc.more {
+			t.Fatalf("%s: expected more occurrences to be %v, got %v", tc.name, tc.more, more)
+		}
+	}
+}
+
+func testBetween(t *testing.T) {
+
+	r, err := rrule.Parse("FREQ=WEEKLY;BYDAY=MO")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2019, 7, 1, 9, 0, 0, 0, time.UTC)
+
+	got := r.Between(start, time.Date(2019, 7, 8, 9, 0, 0, 0, time.UTC), time.Date(2019, 7, 22, 9, 0, 0, 0, time.UTC))
+
+	if len(got) != 3 || got[0].Day() != 8 || got[2].Day() != 22 {
+		t.Fatalf("Expected Mondays from the 8th to the 22nd, got %v", got)
+	}
+}
+
+func testParseErrors(t *testing.T) {
+
+	cases := []string{
+		"",
+		"BYDAY=MO",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20190701",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=MONTHLY;BYSETPOS=-1",
+	}
+
+	for _, rule := range cases {
+		if _, err := rrule.Parse(rule); err == nil {
+			t.Fatalf("Expected error parsing %q", rule)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package internal
+
+import "strings"
+
+// Matches reports whether every word of query is in the ToDo's title, notes or tags, ignoring case. Every
+// ToDo matches an empty query.
+func (t *ToDo) Matches(query string) bool {
+	text := strings.ToLower(t.Title + "\n" + t.Notes + "\n" + strings.Join(t.Tags, "\n"))
+
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+
+	return true
+}
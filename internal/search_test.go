@@ -0,0 +1,34 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+func TestMatches(t *testing.T) {
+
+	todo := internal.ToDo{
+		Title: "Rotate certs",
+		Notes: "Follow the [runbook](https://wiki.example.com/runbooks/TLS) for the load balancers",
+		Tags:  []string{"infra"},
+	}
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"rotate", true},
+		{"CERTS runbook", true},
+		{"tls", true},
+		{"infra certs", true},
+		{"rotate keys", false},
+	}
+
+	for _, test := range tests {
+		if got := todo.Matches(test.query); got != test.want {
+			t.Fatalf("Expected Matches(%q) to be %t", test.query, test.want)
+		}
+	}
+}
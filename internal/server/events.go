@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/realtime"
+)
+
+const (
+	// heartbeatInterval is how often a comment is sent on an idle event stream, so proxies do not close it
+	heartbeatInterval = 15 * time.Second
+	// resetEvent tells a client that changes it missed are no longer kept, so it must fetch its list again
+	resetEvent = "reset"
+)
+
+// Events returns a handler that streams the changes made to the list in the list query parameter, or the
+// default list, as Server-Sent Events. Each event is named after the type of change, has the changed ToDo
+// as its data, and can be resumed from with the Last-Event-ID header.
+func Events(hub *realtime.Hub) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		listID := r.URL.Query().Get("list")
+		if listID == "" {
+			listID = internal.DefaultListID
+		} else if strings.HasPrefix(listID, "_") {
+			http.Error(w, "invalid list ID", http.StatusBadRequest)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		var (
+			sub    *realtime.Subscription
+			missed []realtime.Event
+			reset  bool
+		)
+
+		if last := r.Header.Get("Last-Event-ID"); last != "" {
+			id, err := strconv.ParseUint(last, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+				return
+			}
+
+			var resumed bool
+			sub, missed, resumed = hub.Resume(listID, id)
+			reset = !resumed
+		} else {
+			sub = hub.Subscribe(listID)
+		}
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+
+		// The reset event has the ID of the last event, so the client does not reset again if it reconnects
+		// before the next one
+		if reset {
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: {}\n\n", hub.LastID(), resetEvent)
+		}
+
+		for _, e := range missed {
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case e, ok := <-sub.C:
+				// The subscriber fell behind and was dropped. The client reconnects and resumes from the
+				// last event it received.
+				if !ok {
+					return
+				}
+				if err := writeEvent(w, e); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+			case <-r.Context().Done():
+				return
+			}
+
+			flusher.Flush()
+		}
+	})
+}
+
+// writeEvent writes e as a Server-Sent Event
+func writeEvent(w http.ResponseWriter, e realtime.Event) error {
+	b, err := json.Marshal(e.ToDo)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, b)
+	return err
+}
@@ -0,0 +1,153 @@
+package server_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/realtime"
+	"github.com/benjaminbartels/todo/internal/server"
+)
+
+func TestEvents(t *testing.T) {
+	t.Run("ResumeAndStream", testResumeAndStream)
+	t.Run("ResetWhenMissed", testResetWhenMissed)
+	t.Run("EventsBadRequest", testEventsBadRequest)
+}
+
+// sseEvent is a parsed Server-Sent Event
+type sseEvent struct {
+	id, event, data string
+}
+
+// openEvents opens an event stream, resuming from lastEventID if it is not empty
+func openEvents(t *testing.T, url, lastEventID string) (*http.Response, func() sseEvent) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(resp.Body)
+
+	next := func() sseEvent {
+		var e sseEvent
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			line = strings.TrimSuffix(line, "\n")
+
+			switch {
+			case line == "" && e != sseEvent{}:
+				return e
+			case strings.HasPrefix(line, "id: "):
+				e.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				e.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				e.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}
+
+	return resp, next
+}
+
+func testResumeAndStream(t *testing.T) {
+
+	hub := realtime.NewHub()
+
+	s := httptest.NewServer(server.Events(hub))
+	defer s.Close()
+
+	last := hub.LastID()
+
+	hub.Publish(internal.Change{Type: internal.ChangeCreated, ToDo: internal.ToDo{ID: "1", Title: "Missed"}})
+
+	resp, next := openEvents(t, s.URL+"/todos/events", strconv.FormatUint(last, 10))
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Unexpected Content-Type '%s'", resp.Header.Get("Content-Type"))
+	}
+
+	e := next()
+	if e.id != strconv.FormatUint(last+1, 10) || e.event != internal.ChangeCreated || !strings.Contains(e.data, `"title":"Missed"`) {
+		t.Fatalf("Expected the missed event, got %+v", e)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		hub.Publish(internal.Change{Type: internal.ChangeDeleted, ToDo: internal.ToDo{ID: "1"}})
+	}()
+
+	if e := next(); e.event != internal.ChangeDeleted || e.id != strconv.FormatUint(last+2, 10) {
+		t.Fatalf("Expected ToDo 1 to be deleted, got %+v", e)
+	}
+}
+
+func testResetWhenMissed(t *testing.T) {
+
+	hub := realtime.NewHub()
+
+	s := httptest.NewServer(server.Events(hub))
+	defer s.Close()
+
+	hub.Publish(internal.Change{Type: internal.ChangeCreated, ToDo: internal.ToDo{ID: "1"}})
+
+	// An ID from before the hub was created
+	resp, next := openEvents(t, s.URL+"/todos/events", "1")
+	defer resp.Body.Close()
+
+	if e := next(); e.event != "reset" || e.id != strconv.FormatUint(hub.LastID(), 10) {
+		t.Fatalf("Expected a reset event with the last ID, got %+v", e)
+	}
+}
+
+func testEventsBadRequest(t *testing.T) {
+
+	s := httptest.NewServer(server.Events(realtime.NewHub()))
+	defer s.Close()
+
+	for _, test := range []struct {
+		url, lastEventID string
+	}{
+		{s.URL + "/todos/events?list=_connections", ""},
+		{s.URL + "/todos/events", "not a number"},
+	} {
+		req, err := http.NewRequest(http.MethodGet, test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if test.lastEventID != "" {
+			req.Header.Set("Last-Event-ID", test.lastEventID)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expected %d http response code, got %d", http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package server serves the API Gateway handlers over plain HTTP, for self-hosted deployments and local
+// testing.
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// maxBodySize is the largest request body that is read, matching the API Gateway payload limit
+const maxBodySize = 10 << 20
+
+// HandlerFunc handles a request in the form API Gateway sends it to Lambda
+type HandlerFunc func(events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// Authenticator returns the ID of the user making a request, or an empty string if the request is not
+// authenticated. It has the role of an API Gateway authorizer.
+type Authenticator func(r *http.Request) string
+
+// Route maps an API Gateway resource, such as /todos/{id} or /caldav/{proxy+}, to its handler
+type Route struct {
+	Resource string
+	Handler  HandlerFunc
+}
+
+// Server is an http.Handler that routes requests to API Gateway handlers
+type Server struct {
+	routes []Route
+	auth   Authenticator
+}
+
+// New returns a Server for routes, which are matched in order. auth may be nil if no requests are
+// authenticated.
+func New(routes []Route, auth Authenticator) *Server {
+	return &Server{routes: routes, auth: auth}
+}
+
+// ServeHTTP converts the request to an APIGatewayProxyRequest, calls the handler of the first matching
+// route and writes its response
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	route, params, ok := s.match(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Answer CORS preflight requests the way API Gateway does for routes with cors: true
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	req, err := s.request(r, route.Resource, params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	resp, err := route.Handler(req)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	writeResponse(w, resp)
+}
+
+// match returns the first route matching path and the values of its path parameters
+func (s *Server) match(path string) (Route, map[string]string, bool) {
+	for _, route := range s.routes {
+		if params, ok := matchResource(route.Resource, path); ok {
+			return route, params, true
+		}
+	}
+	return Route{}, nil, false
+}
+
+// matchResource matches path against an API Gateway resource. {name} matches a single segment and a
+// trailing {name+} matches the rest of the path.
+func matchResource(resource, path string) (map[string]string, bool) {
+	rs := strings.Split(strings.Trim(resource, "/"), "/")
+	ps := strings.Split(strings.Trim(path, "/"), "/")
+	params := make(map[string]string)
+
+	for i, r := range rs {
+		if strings.HasPrefix(r, "{") && strings.HasSuffix(r, "+}") {
+			if i >= len(ps) {
+				return nil, false
+			}
+			rest := strings.Join(ps[i:], "/")
+			if strings.HasSuffix(path, "/") {
+				rest += "/"
+			}
+			params[r[1:len(r)-2]] = rest
+			return params, true
+		}
+
+		if i >= len(ps) {
+			return nil, false
+		}
+
+		if strings.HasPrefix(r, "{") && strings.HasSuffix(r, "}") {
+			if ps[i] == "" {
+				return nil, false
+			}
+			params[r[1:len(r)-1]] = ps[i]
+		} else if r != ps[i] {
+			return nil, false
+		}
+	}
+
+	if len(ps) != len(rs) {
+		return nil, false
+	}
+
+	return params, true
+}
+
+// request converts an http.Request to an APIGatewayProxyRequest
+func (s *Server) request(r *http.Request, resource string, params map[string]string) (events.APIGatewayProxyRequest, error) {
+	b, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	req := events.APIGatewayProxyRequest{
+		Resource:                        resource,
+		Path:                            r.URL.Path,
+		HTTPMethod:                      r.Method,
+		Headers:                         make(map[string]string),
+		MultiValueHeaders:               make(map[string][]string),
+		QueryStringParameters:           make(map[string]string),
+		MultiValueQueryStringParameters: make(map[string][]string),
+		PathParameters:                  params,
+	}
+
+	for k, v := range r.Header {
+		req.Headers[k] = v[0]
+		req.MultiValueHeaders[k] = v
+	}
+
+	for k, v := range r.URL.Query() {
+		req.QueryStringParameters[k] = v[0]
+		req.MultiValueQueryStringParameters[k] = v
+	}
+
+	if utf8.Valid(b) {
+		req.Body = string(b)
+	} else {
+		req.Body = base64.StdEncoding.EncodeToString(b)
+		req.IsBase64Encoded = true
+	}
+
+	if s.auth != nil {
+		if id := s.auth(r); id != "" {
+			req.RequestContext.Authorizer = map[string]interface{}{"principalId": id}
+		}
+	}
+
+	return req, nil
+}
+
+// writeResponse writes an APIGatewayProxyResponse
+func writeResponse(w http.ResponseWriter, resp events.APIGatewayProxyResponse) {
+	for k, v := range resp.Headers {
+		if v != "" {
+			w.Header().Set(k, v)
+		}
+	}
+
+	for k, vs := range resp.MultiValueHeaders {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+
+	body := []byte(resp.Body)
+	if resp.IsBase64Encoded {
+		b, err := base64.StdEncoding.DecodeString(resp.Body)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		body = b
+	}
+
+	w.WriteHeader(resp.StatusCode)
+	w.Write(body)
+}
+
+// BasicAuth returns an Authenticator that accepts a single user. Requests without credentials are not
+// authenticated, so handlers that require a user respond with 401.
+func BasicAuth(user, password string) Authenticator {
+	return func(r *http.Request) string {
+		u, p, ok := r.BasicAuth()
+		if !ok {
+			return ""
+		}
+
+		if subtle.ConstantTimeCompare([]byte(u), []byte(user)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+			return ""
+		}
+
+		return user
+	}
+}
+
+// RequireAuth returns a handler that responds with 401 to requests auth does not authenticate, for
+// handlers that are not served through a Server, such as streams of changes. Every request is passed to h
+// if auth is nil, as with a Server.
+func RequireAuth(auth Authenticator, h http.Handler) http.Handler {
+	if auth == nil {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth(r) == "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="todo"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
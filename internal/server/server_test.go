@@ -0,0 +1,244 @@
+package server_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/benjaminbartels/todo/internal/server"
+)
+
+func TestServer(t *testing.T) {
+	t.Run("Routes", testRoutes)
+	t.Run("Request", testRequest)
+	t.Run("BasicAuth", testBasicAuth)
+	t.Run("Preflight", testPreflight)
+	t.Run("RequireAuth", testRequireAuth)
+}
+
+// recorder returns a handler that records the request it receives
+func recorder(got *events.APIGatewayProxyRequest) server.HandlerFunc {
+	return func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		*got = req
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusTeapot,
+			Headers:    map[string]string{"X-Resource": req.Resource, "Content-Type": ""},
+			Body:       "ok",
+		}, nil
+	}
+}
+
+func testRoutes(t *testing.T) {
+
+	var got events.APIGatewayProxyRequest
+
+	s := httptest.NewServer(server.New([]server.Route{
+		{Resource: "/todos/export", Handler: recorder(&got)},
+		{Resource: "/todos/{id}", Handler: recorder(&got)},
+		{Resource: "/todos", Handler: recorder(&got)},
+		{Resource: "/caldav", Handler: recorder(&got)},
+		{Resource: "/caldav/{proxy+}", Handler: recorder(&got)},
+	}, nil))
+	defer s.Close()
+
+	tests := []struct {
+		path     string
+		resource string
+		params   map[string]string
+	}{
+		{"/todos", "/todos", map[string]string{}},
+		{"/todos/export", "/todos/export", map[string]string{}},
+		{"/todos/123", "/todos/{id}", map[string]string{"id": "123"}},
+		{"/caldav/", "/caldav", map[string]string{}},
+		{"/caldav/default/", "/caldav/{proxy+}", map[string]string{"proxy": "default/"}},
+		{"/caldav/default/1.ics", "/caldav/{proxy+}", map[string]string{"proxy": "default/1.ics"}},
+	}
+
+	for _, tc := range tests {
+		resp, err := http.Get(s.URL + tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusTeapot || resp.Header.Get("X-Resource") != tc.resource {
+			t.Fatalf("Expected %s to be routed to %s, got %d %s", tc.path, tc.resource, resp.StatusCode, resp.Header.Get("X-Resource"))
+		}
+
+		for k, v := range tc.params {
+			if got.PathParameters[k] != v {
+				t.Fatalf("Expected path parameter %s=%s for %s, got %v", k, v, tc.path, got.PathParameters)
+			}
+		}
+	}
+
+	resp, err := http.Get(s.URL + "/todos/123/comments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func testRequest(t *testing.T) {
+
+	var got events.APIGatewayProxyRequest
+
+	s := httptest.NewServer(server.New([]server.Route{{Resource: "/todos/import", Handler: recorder(&got)}}, nil))
+	defer s.Close()
+
+	req, err := http.NewRequest("POST", s.URL+"/todos/import?format=csv&dryRun=true", strings.NewReader("\xff\xfe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Depth", "1")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != "ok" {
+		t.Fatalf("Expected body 'ok', got '%s'", body)
+	}
+
+	if _, ok := resp.Header["Content-Type"]; ok && resp.Header.Get("Content-Type") == "" {
+		t.Fatal("Expected empty headers not to be written")
+	}
+
+	if got.HTTPMethod != "POST" || got.QueryStringParameters["format"] != "csv" || got.Headers["Depth"] != "1" {
+		t.Fatalf("Unexpected request %+v", got)
+	}
+
+	if !got.IsBase64Encoded || got.Body != "//4=" {
+		t.Fatalf("Expected binary body to be base64 encoded, got '%s'", got.Body)
+	}
+
+	if got.RequestContext.Authorizer != nil {
+		t.Fatal("Expected request not to be authorized")
+	}
+}
+
+func testBasicAuth(t *testing.T) {
+
+	var got events.APIGatewayProxyRequest
+
+	s := httptest.NewServer(server.New([]server.Route{{Resource: "/caldav", Handler: recorder(&got)}},
+		server.BasicAuth("me", "secret")))
+	defer s.Close()
+
+	tests := []struct {
+		user     string
+		password string
+		expected interface{}
+	}{
+		{"me", "secret", "me"},
+		{"me", "wrong", nil},
+		{"other", "secret", nil},
+	}
+
+	for _, tc := range tests {
+		got = events.APIGatewayProxyRequest{}
+
+		req, err := http.NewRequest("PROPFIND", s.URL+"/caldav/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth(tc.user, tc.password)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if got.RequestContext.Authorizer["principalId"] != tc.expected {
+			t.Fatalf("Expected principalId %v for %s:%s, got %v", tc.expected, tc.user, tc.password, got.RequestContext.Authorizer)
+		}
+	}
+}
+
+func testPreflight(t *testing.T) {
+
+	var got events.APIGatewayProxyRequest
+
+	s := httptest.NewServer(server.New([]server.Route{{Resource: "/todos", Handler: recorder(&got)}}, nil))
+	defer s.Close()
+
+	req, err := http.NewRequest("OPTIONS", s.URL+"/todos", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "http://localhost:8081")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("Unexpected preflight response %d %v", resp.StatusCode, resp.Header)
+	}
+
+	if got.HTTPMethod != "" {
+		t.Fatal("Expected preflight not to reach the handler")
+	}
+}
+
+func testRequireAuth(t *testing.T) {
+
+	invoked := false
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		invoked = true
+	})
+
+	s := httptest.NewServer(server.RequireAuth(server.BasicAuth("me", "secret"), h))
+	defer s.Close()
+
+	tests := []struct {
+		user     string
+		password string
+		expected int
+	}{
+		{"me", "secret", http.StatusOK},
+		{"me", "wrong", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		invoked = false
+
+		req, err := http.NewRequest(http.MethodGet, s.URL+"/ws", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.user != "" {
+			req.SetBasicAuth(tc.user, tc.password)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.expected || invoked != (tc.expected == http.StatusOK) {
+			t.Fatalf("Expected %d for %s:%s, got %d", tc.expected, tc.user, tc.password, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/realtime"
+	"golang.org/x/net/websocket"
+)
+
+// WebSocket returns a handler for WebSocket connections that receive the changes made to the list in the
+// list query parameter, or the default list, as JSON messages. It is the equivalent of the API Gateway
+// WebSocket API for local testing and, like the todo routes, does not require authentication.
+func WebSocket(hub *realtime.Hub) http.Handler {
+	ws := websocket.Server{
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+
+			listID := conn.Request().URL.Query().Get("list")
+			if listID == "" {
+				listID = internal.DefaultListID
+			}
+
+			sub := hub.Subscribe(listID)
+			defer sub.Close()
+
+			// Clients only receive, so anything they send is discarded until they disconnect
+			done := make(chan struct{})
+			go func() {
+				io.Copy(ioutil.Discard, conn)
+				close(done)
+			}()
+
+			for {
+				select {
+				case c, ok := <-sub.C:
+					if !ok {
+						return
+					}
+					if err := websocket.JSON.Send(conn, c); err != nil {
+						return
+					}
+				case <-done:
+					return
+				}
+			}
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Query().Get("list"), "_") {
+			http.Error(w, "invalid list ID", http.StatusBadRequest)
+			return
+		}
+
+		ws.ServeHTTP(w, r)
+	})
+}
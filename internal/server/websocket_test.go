@@ -0,0 +1,80 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/realtime"
+	"github.com/benjaminbartels/todo/internal/server"
+	"golang.org/x/net/websocket"
+)
+
+func TestWebSocket(t *testing.T) {
+	t.Run("ReceiveChanges", testReceiveChanges)
+	t.Run("InvalidList", testInvalidList)
+}
+
+func testReceiveChanges(t *testing.T) {
+
+	hub := realtime.NewHub()
+
+	s := httptest.NewServer(server.WebSocket(hub))
+	defer s.Close()
+
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + "/ws?list=work"
+
+	conn, err := websocket.Dial(url, "", s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The subscription is made once the handshake completes, so publish until a change arrives
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+				hub.Publish(internal.Change{Type: internal.ChangeUpdated, ToDo: internal.ToDo{ID: "other"}})
+				hub.Publish(internal.Change{Type: internal.ChangeCreated, ToDo: internal.ToDo{ID: "1", ListID: "work"}})
+			}
+		}
+	}()
+
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	var c internal.Change
+	if err := websocket.JSON.Receive(conn, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Type != internal.ChangeCreated || c.ToDo.ID != "1" {
+		t.Fatalf("Expected ToDo 1 to be created, got %+v", c)
+	}
+}
+
+func testInvalidList(t *testing.T) {
+
+	s := httptest.NewServer(server.WebSocket(realtime.NewHub()))
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/ws?list=_feedtokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d http response code, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
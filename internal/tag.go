@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+)
+
+// TagCount is a tag and the number of ToDos that have it
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// NormalizeTag returns the form a tag is stored in. Tags are case insensitive and surrounding space is
+// ignored, so "Infra " and "infra" are the same tag.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+// NormalizeTags returns tags normalized, sorted and without duplicates or empty tags, or nil if there are
+// none
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+
+	sort.Strings(normalized)
+
+	return normalized
+}
+
+// HasTag reports whether the ToDo has the normalized tag
+func (t *ToDo) HasTag(tag string) bool {
+	for _, tt := range t.Tags {
+		if tt == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTags reports whether the ToDo has all of the normalized tags, or any of them if all is false
+func (t *ToDo) HasTags(tags []string, all bool) bool {
+	for _, tag := range tags {
+		if t.HasTag(tag) != all {
+			return !all
+		}
+	}
+	return all
+}
+
+// Retag replaces the normalized tags in from with to, and reports whether the ToDo had any of them. A ToDo
+// that already has to keeps a single copy of it, so renaming a tag to an existing tag merges them.
+func (t *ToDo) Retag(from []string, to string) bool {
+	if !t.HasTags(from, false) {
+		return false
+	}
+
+	tags := []string{to}
+	for _, tag := range t.Tags {
+		if !stringIn(tag, from) {
+			tags = append(tags, tag)
+		}
+	}
+
+	t.Tags = NormalizeTags(tags)
+
+	return true
+}
+
+// CountTags returns the tags of todos and the number of ToDos that have each of them, by name
+func CountTags(todos []ToDo) []TagCount {
+	counts := make(map[string]int)
+	for _, t := range todos {
+		for _, tag := range t.Tags {
+			counts[tag]++
+		}
+	}
+
+	result := make([]TagCount, 0, len(counts))
+	for name, count := range counts {
+		result = append(result, TagCount{Name: name, Count: count})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result
+}
+
+// stringIn reports whether s is one of values
+func stringIn(s string, values []string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// tagsEqual reports whether a and b have the same tags. Tags are stored normalized, so in the same order.
+// It also compares normalized assignees.
+func tagsEqual(a, b []string) bool {
+	return strings.Join(a, "\x00") == strings.Join(b, "\x00")
+}
+
+// tagsLess orders sets of tags, so ties between merged writes are broken the same way on every replica
+func tagsLess(a, b []string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return strings.Join(a, "\x00") < strings.Join(b, "\x00")
+}
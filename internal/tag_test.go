@@ -0,0 +1,83 @@
+package internal_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+func TestTags(t *testing.T) {
+	t.Run("NormalizeTags", testNormalizeTags)
+	t.Run("HasTags", testHasTags)
+	t.Run("Retag", testRetag)
+	t.Run("CountTags", testCountTags)
+}
+
+func testNormalizeTags(t *testing.T) {
+
+	tags := internal.NormalizeTags([]string{" Infra", "on-call", "infra ", "", "Release  Train"})
+
+	if !reflect.DeepEqual(tags, []string{"infra", "on-call", "release train"}) {
+		t.Fatalf("Expected tags to be normalized, got %q", tags)
+	}
+
+	if internal.NormalizeTags([]string{" "}) != nil {
+		t.Fatal("Expected no tags")
+	}
+}
+
+func testHasTags(t *testing.T) {
+
+	todo := internal.ToDo{Tags: []string{"infra", "urgent"}}
+
+	tests := []struct {
+		tags []string
+		all  bool
+		want bool
+	}{
+		{[]string{"infra"}, true, true},
+		{[]string{"infra", "urgent"}, true, true},
+		{[]string{"infra", "docs"}, true, false},
+		{[]string{"infra", "docs"}, false, true},
+		{[]string{"docs", "web"}, false, false},
+	}
+
+	for _, test := range tests {
+		if got := todo.HasTags(test.tags, test.all); got != test.want {
+			t.Fatalf("Expected HasTags(%q, %t) to be %t", test.tags, test.all, test.want)
+		}
+	}
+}
+
+func testRetag(t *testing.T) {
+
+	todo := internal.ToDo{Tags: []string{"infra", "ops", "urgent"}}
+
+	if !todo.Retag([]string{"ops"}, "infra") {
+		t.Fatal("Expected the ToDo to be retagged")
+	}
+
+	if !reflect.DeepEqual(todo.Tags, []string{"infra", "urgent"}) {
+		t.Fatalf("Expected ops to be merged into infra, got %q", todo.Tags)
+	}
+
+	if todo.Retag([]string{"docs"}, "documentation") {
+		t.Fatal("Expected a ToDo without the tag to be unchanged")
+	}
+}
+
+func testCountTags(t *testing.T) {
+
+	todos := []internal.ToDo{
+		{ID: "1", Tags: []string{"infra", "urgent"}},
+		{ID: "2", Tags: []string{"infra"}},
+		{ID: "3"},
+	}
+
+	want := []internal.TagCount{{Name: "infra", Count: 2}, {Name: "urgent", Count: 1}}
+
+	if got := internal.CountTags(todos); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// variablePattern matches a variable in the title or notes of a template item, such as {{version}}
+var variablePattern = regexp.MustCompile(`{{\s*([A-Za-z0-9_.-]+)\s*}}`)
+
+// Template is a saved tree of ToDos that is created again and again, such as a release checklist. The
+// titles and notes of its items may contain variables, such as {{version}}, that are replaced when it is
+// instantiated. Templates belong to the user who saved them.
+type Template struct {
+	ID      string         `json:"id"`
+	UserID  string         `json:"userId"`
+	Name    string         `json:"name"`
+	Items   []TemplateItem `json:"items"`
+	Created time.Time      `json:"created"`
+	ModTime time.Time      `json:"modTime"`
+}
+
+// TemplateItem is a ToDo of a template. The ToDos of its subtasks block its ToDo, so it can only be
+// completed after them. DueOffsetDays is how many days after the anchor date of an instance the ToDo is
+// due, and it has no due date if DueOffsetDays is nil.
+type TemplateItem struct {
+	Title         string         `json:"title"`
+	Notes         string         `json:"notes,omitempty"`
+	Tags          []string       `json:"tags,omitempty"`
+	DueOffsetDays *int           `json:"dueOffsetDays,omitempty"`
+	Subtasks      []TemplateItem `json:"subtasks,omitempty"`
+}
+
+// Walk calls fn for each item of the template and their subtasks, parents first, with the depth of the
+// item, which is 1 for the items of the template
+func (t *Template) Walk(fn func(item *TemplateItem, depth int)) {
+	var walk func(items []TemplateItem, depth int)
+
+	walk = func(items []TemplateItem, depth int) {
+		for i := range items {
+			fn(&items[i], depth)
+			walk(items[i].Subtasks, depth+1)
+		}
+	}
+
+	walk(t.Items, 1)
+}
+
+// Variables returns the names of the variables used by the template, sorted
+func (t *Template) Variables() []string {
+	names := make(map[string]bool)
+
+	t.Walk(func(item *TemplateItem, _ int) {
+		for _, s := range []string{item.Title, item.Notes} {
+			for _, m := range variablePattern.FindAllStringSubmatch(s, -1) {
+				names[m[1]] = true
+			}
+		}
+	})
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// HasDueOffsets reports whether any item of the template is due relative to the anchor date
+func (t *Template) HasDueOffsets() bool {
+	found := false
+
+	t.Walk(func(item *TemplateItem, _ int) {
+		found = found || item.DueOffsetDays != nil
+	})
+
+	return found
+}
+
+// Substitute replaces the variables in s with their values in vars. Variables without a value are left as
+// they are.
+func Substitute(s string, vars map[string]string) string {
+	return variablePattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := strings.TrimSpace(m[2 : len(m)-2])
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// Instantiate returns the ToDos of the template, parents first, with the variables in their titles and
+// notes replaced by their values in vars. Items are due relative to anchor, whose time and location are
+// kept. newID returns the ID of each ToDo.
+func (t *Template) Instantiate(vars map[string]string, anchor time.Time, newID func() string) []ToDo {
+	var todos []ToDo
+
+	var instantiate func(items []TemplateItem) []string
+
+	instantiate = func(items []TemplateItem) []string {
+		var ids []string
+
+		for _, item := range items {
+			i := len(todos)
+
+			todos = append(todos, ToDo{
+				ID:    newID(),
+				Title: Substitute(item.Title, vars),
+				Notes: Substitute(item.Notes, vars),
+				Tags:  append([]string(nil), item.Tags...),
+			})
+
+			if item.DueOffsetDays != nil {
+				due := anchor.AddDate(0, 0, *item.DueOffsetDays)
+				todos[i].Due = &due
+			}
+
+			ids = append(ids, todos[i].ID)
+
+			// todos grows while the subtasks are instantiated, so the parent is updated by index
+			todos[i].BlockedBy = instantiate(item.Subtasks)
+		}
+
+		return ids
+	}
+
+	instantiate(t.Items)
+
+	return todos
+}
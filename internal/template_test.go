@@ -0,0 +1,127 @@
+package internal_test
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+func TestTemplates(t *testing.T) {
+	t.Run("Variables", testTemplateVariables)
+	t.Run("Substitute", testSubstitute)
+	t.Run("Instantiate", testInstantiate)
+}
+
+// days returns a pointer to n, for a DueOffsetDays
+func days(n int) *int {
+	return &n
+}
+
+// releaseTemplate returns a Template of a release with a subtask that has its own subtask
+func releaseTemplate() internal.Template {
+	return internal.Template{
+		Name: "Release",
+		Items: []internal.TemplateItem{
+			{
+				Title:         "Release {{version}}",
+				DueOffsetDays: days(0),
+				Tags:          []string{"release"},
+				Subtasks: []internal.TemplateItem{
+					{
+						Title:         "Tag {{ version }}",
+						Notes:         "Ask {{owner}} to sign it",
+						DueOffsetDays: days(-1),
+						Subtasks:      []internal.TemplateItem{{Title: "Freeze {{branch}}"}},
+					},
+				},
+			},
+			{Title: "Announce {{version}}", DueOffsetDays: days(2)},
+		},
+	}
+}
+
+func testTemplateVariables(t *testing.T) {
+
+	tmpl := releaseTemplate()
+
+	if got := tmpl.Variables(); !reflect.DeepEqual(got, []string{"branch", "owner", "version"}) {
+		t.Fatalf("Expected [branch owner version], got %v", got)
+	}
+
+	if !tmpl.HasDueOffsets() {
+		t.Fatal("Expected the template to have due offsets")
+	}
+
+	if (&internal.Template{Items: []internal.TemplateItem{{Title: "Plain"}}}).HasDueOffsets() {
+		t.Fatal("Expected no due offsets")
+	}
+}
+
+func testSubstitute(t *testing.T) {
+
+	got := internal.Substitute("Ship {{version}} to {{ env }}, {{unknown}} and {{}}", map[string]string{
+		"version": "1.4",
+		"env":     "prod",
+	})
+
+	if got != "Ship 1.4 to prod, {{unknown}} and {{}}" {
+		t.Fatalf("Unexpected substitution %q", got)
+	}
+}
+
+func testInstantiate(t *testing.T) {
+
+	tmpl := releaseTemplate()
+
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The release is the day after daylight saving time ends
+	anchor := time.Date(2019, 11, 4, 9, 0, 0, 0, loc)
+
+	n := 0
+	newID := func() string {
+		n++
+		return strconv.Itoa(n)
+	}
+
+	todos := tmpl.Instantiate(map[string]string{"version": "1.4", "owner": "ann", "branch": "main"}, anchor, newID)
+
+	if len(todos) != 4 {
+		t.Fatalf("Expected 4 ToDos, got %d", len(todos))
+	}
+
+	titles := []string{todos[0].Title, todos[1].Title, todos[2].Title, todos[3].Title}
+	if !reflect.DeepEqual(titles, []string{"Release 1.4", "Tag 1.4", "Freeze main", "Announce 1.4"}) {
+		t.Fatalf("Unexpected titles %q", titles)
+	}
+
+	if todos[1].Notes != "Ask ann to sign it" {
+		t.Fatalf("Expected notes to be substituted, got %q", todos[1].Notes)
+	}
+
+	if !reflect.DeepEqual(todos[0].BlockedBy, []string{"2"}) || !reflect.DeepEqual(todos[1].BlockedBy, []string{"3"}) ||
+		todos[2].BlockedBy != nil || todos[3].BlockedBy != nil {
+		t.Fatalf("Expected each ToDo to be blocked by its subtasks, got %+v", todos)
+	}
+
+	// Due dates keep the time of day of the anchor across the change of offset
+	want := time.Date(2019, 11, 3, 9, 0, 0, 0, loc)
+	if todos[1].Due == nil || !todos[1].Due.Equal(want) {
+		t.Fatalf("Expected due %s, got %v", want, todos[1].Due)
+	}
+
+	if todos[2].Due != nil {
+		t.Fatalf("Expected no due date, got %v", todos[2].Due)
+	}
+
+	todos[0].Tags[0] = "changed"
+	if tmpl.Items[0].Tags[0] != "release" {
+		t.Fatal("Expected the ToDos not to share tags with the template")
+	}
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"sort"
+	"time"
+)
+
+// TimeEntry is time a user spent on a ToDo. End is nil while the entry is the user's running timer, and a
+// user has at most one running timer.
+type TimeEntry struct {
+	ID     string     `json:"id"`
+	ListID string     `json:"listId"`
+	ToDoID string     `json:"todoId"`
+	UserID string     `json:"userId"`
+	Start  time.Time  `json:"start"`
+	End    *time.Time `json:"end,omitempty"`
+	Note   string     `json:"note,omitempty"`
+}
+
+// Running reports whether the entry is a running timer
+func (e TimeEntry) Running() bool {
+	return e.End == nil
+}
+
+// Duration returns the time spent, counting a running timer up to now
+func (e TimeEntry) Duration(now time.Time) time.Duration {
+	end := now
+	if e.End != nil {
+		end = *e.End
+	}
+
+	if end.Before(e.Start) {
+		return 0
+	}
+
+	return end.Sub(e.Start)
+}
+
+// TrackedSeconds returns the time spent in the entries that are not running, in whole seconds
+func TrackedSeconds(entries []TimeEntry) int64 {
+	var d time.Duration
+
+	for _, e := range entries {
+		if !e.Running() {
+			d += e.Duration(time.Time{})
+		}
+	}
+
+	return int64(d / time.Second)
+}
+
+// TimeGroup is the time spent in the entries of a report that share a key, such as a list or a day
+type TimeGroup struct {
+	Key     string `json:"key"`
+	Seconds int64  `json:"seconds"`
+	Entries int    `json:"entries"`
+}
+
+// GroupTime sums the time spent in entries by the keys returned for each of them, ordered by key. An entry
+// with several keys, such as a ToDo with several tags, counts towards each of them. Running timers are
+// counted up to now.
+func GroupTime(entries []TimeEntry, now time.Time, keys func(TimeEntry) []string) []TimeGroup {
+	byKey := make(map[string]*TimeGroup)
+
+	for _, e := range entries {
+		seconds := int64(e.Duration(now) / time.Second)
+
+		for _, k := range keys(e) {
+			g, ok := byKey[k]
+			if !ok {
+				g = &TimeGroup{Key: k}
+				byKey[k] = g
+			}
+			g.Seconds += seconds
+			g.Entries++
+		}
+	}
+
+	groups := make([]TimeGroup, 0, len(byKey))
+	for _, g := range byKey {
+		groups = append(groups, *g)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Key < groups[j].Key
+	})
+
+	return groups
+}
@@ -0,0 +1,98 @@
+package internal_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+)
+
+func TestTimeEntries(t *testing.T) {
+	t.Run("Duration", testTimeEntryDuration)
+	t.Run("TrackedSeconds", testTrackedSeconds)
+	t.Run("GroupTime", testGroupTime)
+}
+
+var workStart = time.Date(2019, 7, 1, 9, 0, 0, 0, time.UTC)
+
+// entry returns a TimeEntry on a ToDo in a list starting at workStart plus start, running if d is 0
+func entry(listID, todoID string, start, d time.Duration) internal.TimeEntry {
+	e := internal.TimeEntry{ListID: listID, ToDoID: todoID, Start: workStart.Add(start)}
+	if d > 0 {
+		end := e.Start.Add(d)
+		e.End = &end
+	}
+	return e
+}
+
+func testTimeEntryDuration(t *testing.T) {
+
+	now := workStart.Add(2 * time.Hour)
+
+	if d := entry("work", "1", 0, 30*time.Minute).Duration(now); d != 30*time.Minute {
+		t.Fatalf("Expected 30m, got %s", d)
+	}
+
+	running := entry("work", "1", time.Hour, 0)
+	if !running.Running() || running.Duration(now) != time.Hour {
+		t.Fatalf("Expected a running timer counted up to now, got %s", running.Duration(now))
+	}
+
+	if d := running.Duration(workStart); d != 0 {
+		t.Fatalf("Expected a timer that has not started to count 0, got %s", d)
+	}
+}
+
+func testTrackedSeconds(t *testing.T) {
+
+	entries := []internal.TimeEntry{
+		entry("work", "1", 0, 30*time.Minute),
+		entry("work", "1", time.Hour, 90*time.Second),
+		entry("work", "1", 2*time.Hour, 0),
+	}
+
+	if got := internal.TrackedSeconds(entries); got != 1890 {
+		t.Fatalf("Expected 1890 seconds, excluding the running timer, got %d", got)
+	}
+}
+
+func testGroupTime(t *testing.T) {
+
+	entries := []internal.TimeEntry{
+		entry("work", "1", 0, 30*time.Minute),
+		entry("home", "2", time.Hour, 15*time.Minute),
+		entry("work", "3", 2*time.Hour, 0),
+	}
+
+	now := workStart.Add(3 * time.Hour)
+
+	got := internal.GroupTime(entries, now, func(e internal.TimeEntry) []string {
+		return []string{e.ListID}
+	})
+
+	want := []internal.TimeGroup{
+		{Key: "home", Seconds: 900, Entries: 1},
+		{Key: "work", Seconds: 5400, Entries: 2},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %+v, got %+v", want, got)
+	}
+
+	got = internal.GroupTime(entries, now, func(e internal.TimeEntry) []string {
+		if e.ToDoID == "1" {
+			return []string{"infra", "ops"}
+		}
+		return nil
+	})
+
+	want = []internal.TimeGroup{
+		{Key: "infra", Seconds: 1800, Entries: 1},
+		{Key: "ops", Seconds: 1800, Entries: 1},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected an entry to count towards each of its keys, got %+v", got)
+	}
+}
@@ -2,10 +2,50 @@ package internal
 
 import "time"
 
+// DefaultListID is the list ToDos belong to when none is given
+const DefaultListID = "default"
+
 // ToDo represents details of a "todo" task to be compelted
 type ToDo struct {
-	ID        string    `json:"id"`
-	Title     string    `json:"title"`
-	Completed bool      `json:"completed"`
-	ModTime   time.Time `json:"modTime"`
+	ID        string     `json:"id" yaml:"id"`
+	ListID    string     `json:"listId,omitempty" yaml:"listId,omitempty"`
+	Title     string     `json:"title" yaml:"title"`
+	Completed bool       `json:"completed" yaml:"completed"`
+	Due       *time.Time `json:"due,omitempty" yaml:"due,omitempty"`
+	Created   time.Time  `json:"created" yaml:"created"`
+	ModTime   time.Time  `json:"modTime" yaml:"modTime"`
+	Clocks    Clocks     `json:"clocks,omitempty" yaml:"clocks,omitempty"`
+	Sets      Sets       `json:"sets,omitempty" yaml:"sets,omitempty"`
+	// Notes are Markdown, such as context and links to runbooks
+	Notes string `json:"notes,omitempty" yaml:"notes,omitempty"`
+	// Checklist are the steps of the ToDo, in order
+	Checklist []ChecklistItem `json:"checklist,omitempty" yaml:"checklist,omitempty"`
+	// Tags label the ToDo, such as infra or on-call. They are stored normalized, see NormalizeTags.
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty" dynamodbav:"tags,stringset,omitempty"`
+	// AssigneeIDs are the users the ToDo is assigned to, who must be members of its list. They are stored
+	// normalized, see NormalizeAssignees.
+	AssigneeIDs []string `json:"assigneeIds,omitempty" yaml:"assigneeIds,omitempty" dynamodbav:"assigneeIds,stringset,omitempty"`
+	// EstimateMinutes is how long the ToDo is expected to take
+	EstimateMinutes int `json:"estimateMinutes,omitempty" yaml:"estimateMinutes,omitempty"`
+	// BlockedBy are the IDs of the ToDos in the same list that must be completed before this one. They are
+	// only changed through the dependencies API.
+	BlockedBy []string `json:"blockedBy,omitempty" yaml:"blockedBy,omitempty" dynamodbav:"blockedBy,stringset,omitempty"`
+	// Attachments are the files attached to the ToDo. They are only changed through the attachments API.
+	Attachments []Attachment `json:"attachments,omitempty" yaml:"attachments,omitempty"`
+	// CommentCount is the number of comments on the ToDo. It is only changed through the comments API.
+	CommentCount int `json:"commentCount,omitempty" yaml:"commentCount,omitempty"`
+	// TrackedSeconds is the time spent on the ToDo in time entries that are not running. It is only changed
+	// through the time tracking API.
+	TrackedSeconds int64 `json:"trackedSeconds,omitempty" yaml:"trackedSeconds,omitempty"`
+	// AutoComplete marks the ToDo as completed when every item of its checklist is checked
+	AutoComplete bool `json:"autoComplete,omitempty" yaml:"autoComplete,omitempty"`
+	// RRule is an RFC 5545 recurrence rule, such as FREQ=WEEKLY;BYDAY=MO. Completing a recurring ToDo creates
+	// its next occurrence.
+	RRule string `json:"rrule,omitempty" yaml:"rrule,omitempty"`
+	// TimeZone is the IANA time zone the rule is expanded in, UTC if empty
+	TimeZone string `json:"timeZone,omitempty" yaml:"timeZone,omitempty"`
+	// SeriesID is the ID of the first ToDo of a recurring series
+	SeriesID string `json:"seriesId,omitempty" yaml:"seriesId,omitempty"`
+	// SeriesStart is the due date of the first ToDo of a recurring series, which the rule is expanded from
+	SeriesStart *time.Time `json:"seriesStart,omitempty" yaml:"seriesStart,omitempty"`
 }
@@ -0,0 +1,10 @@
+package internal
+
+import "time"
+
+// Tombstone records that a ToDo was deleted, so clients that sync can remove their copy of it
+type Tombstone struct {
+	ID      string    `json:"id"`
+	ListID  string    `json:"listId,omitempty"`
+	Deleted time.Time `json:"deleted"`
+}
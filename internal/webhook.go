@@ -0,0 +1,53 @@
+package internal
+
+import "time"
+
+// Webhook is a subscription to the events of a list. Events are POSTed to URL, signed with Secret. If
+// Events is empty every event is sent.
+type Webhook struct {
+	ID      string    `json:"id"`
+	UserID  string    `json:"userId"`
+	ListID  string    `json:"listId"`
+	URL     string    `json:"url"`
+	Events  []string  `json:"events,omitempty"`
+	Secret  string    `json:"secret,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Subscribes reports whether the webhook is sent events named name
+func (w *Webhook) Subscribes(name string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+
+	for _, e := range w.Events {
+		if e == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delivery statuses
+const (
+	// DeliverySucceeded is the status of a delivery the receiver accepted
+	DeliverySucceeded = "succeeded"
+	// DeliveryDeadLettered is the status of a delivery that failed every attempt. Its payload is kept so it
+	// can be sent again.
+	DeliveryDeadLettered = "dead-lettered"
+)
+
+// Delivery is the record of sending an event to a webhook. StatusCode and Error are from the last attempt.
+type Delivery struct {
+	ID         string    `json:"id"`
+	WebhookID  string    `json:"webhookId"`
+	Event      string    `json:"event"`
+	Status     string    `json:"status"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Payload    string    `json:"payload,omitempty"`
+	Created    time.Time `json:"created"`
+	Completed  time.Time `json:"completed"`
+}
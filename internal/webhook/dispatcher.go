@@ -0,0 +1,331 @@
+// Package webhook sends domain events to the webhooks subscribed to them
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/database"
+	"github.com/benjaminbartels/todo/internal/domain"
+	"github.com/pkg/errors"
+)
+
+// Headers sent with each delivery
+const (
+	// EventHeader is the name of the event
+	EventHeader = "X-Todo-Event"
+	// DeliveryHeader is the ID of the delivery, which is the same for every attempt
+	DeliveryHeader = "X-Todo-Delivery"
+	// SignatureHeader is "sha256=" followed by the hex encoded HMAC-SHA256 of the body, keyed with the
+	// webhook's secret
+	SignatureHeader = "X-Todo-Signature"
+)
+
+// signaturePrefix starts the value of the SignatureHeader
+const signaturePrefix = "sha256="
+
+// Backoff controls how failed deliveries are retried. The wait between attempts starts at
+// InitialInterval and is multiplied by Multiplier after each attempt, up to MaxInterval. A delivery is
+// dead-lettered after MaxAttempts.
+type Backoff struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	MaxAttempts     int
+}
+
+// DefaultBackoff is the Backoff used by NewDispatcher. A delivery to a receiver that is down takes at most
+// five attempts of requestTimeout plus 15 seconds of waiting, about 40 seconds, and deliveries to the
+// webhooks of an event are made concurrently.
+var DefaultBackoff = Backoff{
+	InitialInterval: 1 * time.Second,
+	MaxInterval:     8 * time.Second,
+	Multiplier:      2,
+	MaxAttempts:     5,
+}
+
+const (
+	// requestTimeout is how long a receiver has to respond to each attempt
+	requestTimeout = 5 * time.Second
+	// saveReserve is the time left before the deadline of a context to save the deliveries of an event
+	saveReserve = 10 * time.Second
+	// downFor is how long after a delivery to a webhook is dead-lettered that deliveries to it are made
+	// only once, so a receiver that is down does not hold up every event for the whole backoff
+	downFor = time.Minute
+)
+
+// Payload is the body of a delivery
+type Payload struct {
+	ID    string       `json:"id"`
+	Event string       `json:"event"`
+	Data  domain.Event `json:"data"`
+}
+
+// Dispatcher is a domain.ContextSink that sends events to the webhooks of their list
+type Dispatcher struct {
+	webhooks database.WebhookRepo
+	client   *http.Client
+	backoff  Backoff
+	mu       sync.Mutex
+	down     map[string]time.Time
+}
+
+// NewDispatcher returns a new Dispatcher that sends events to the webhooks in webhooks, with the client
+// returned by NewClient
+func NewDispatcher(webhooks database.WebhookRepo) *Dispatcher {
+	return NewDispatcherWithBackoff(webhooks, NewClient(), DefaultBackoff)
+}
+
+// NewDispatcherWithBackoff returns a new Dispatcher that sends events with client, retrying failed
+// deliveries with backoff
+func NewDispatcherWithBackoff(webhooks database.WebhookRepo, client *http.Client, backoff Backoff) *Dispatcher {
+	return &Dispatcher{
+		webhooks: webhooks,
+		client:   client,
+		backoff:  backoff,
+		down:     make(map[string]time.Time),
+	}
+}
+
+// Handle sends e to each webhook of its list that subscribes to it, without a deadline
+func (d *Dispatcher) Handle(e domain.Event) error {
+	return d.HandleContext(context.Background(), e)
+}
+
+// HandleContext sends e to each webhook of its list that subscribes to it. Deliveries are made
+// concurrently and stop being retried shortly before the deadline of ctx, leaving time to save them. A
+// delivery that fails every attempt is dead-lettered rather than returned as an error, so one receiver
+// that is down does not hold up the others. Events that were already delivered to a webhook are not sent
+// to it again, so if ctx is done before a delivery was attempted an error is returned and the event is
+// sent to the remaining webhooks when it is handled again.
+func (d *Dispatcher) HandleContext(ctx context.Context, e domain.Event) error {
+	meta := e.Metadata()
+
+	webhooks, err := d.webhooks.GetByList(meta.ListID)
+	if err != nil {
+		return errors.Wrapf(err, "Could not get webhooks of list %s", meta.ListID)
+	}
+
+	pending := []*internal.Webhook{}
+
+	for i := range webhooks {
+		w := &webhooks[i]
+
+		if !w.Subscribes(e.Name()) {
+			continue
+		}
+
+		existing, err := d.webhooks.GetDelivery(w.ID, meta.ID)
+		if err != nil {
+			return errors.Wrapf(err, "Could not get delivery %s of webhook %s", meta.ID, w.ID)
+		}
+
+		if existing == nil {
+			pending = append(pending, w)
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(Payload{ID: meta.ID, Event: e.Name(), Data: e})
+	if err != nil {
+		return errors.Wrapf(err, "Could not marshal %s event", e.Name())
+	}
+
+	deliverCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		deliverCtx, cancel = context.WithDeadline(ctx, deadline.Add(-saveReserve))
+		defer cancel()
+	}
+
+	deliveries := make([]*internal.Delivery, len(pending))
+
+	var wg sync.WaitGroup
+
+	for i, w := range pending {
+		wg.Add(1)
+		go func(i int, w *internal.Webhook) {
+			defer wg.Done()
+			deliveries[i] = d.deliver(deliverCtx, w, meta.ID, e.Name(), body)
+		}(i, w)
+	}
+
+	wg.Wait()
+
+	skipped := 0
+
+	for i, delivery := range deliveries {
+		if delivery == nil {
+			skipped++
+			continue
+		}
+
+		if delivery.Status == internal.DeliveryDeadLettered {
+			log.Printf("Dead-lettered delivery %s to webhook %s after %d attempts: %s", delivery.ID,
+				pending[i].ID, delivery.Attempts, delivery.Error)
+		}
+
+		if err := d.webhooks.SaveDelivery(delivery); err != nil {
+			return errors.Wrapf(err, "Could not save delivery %s of webhook %s", meta.ID, pending[i].ID)
+		}
+	}
+
+	if skipped > 0 {
+		return errors.Wrapf(deliverCtx.Err(), "Could not deliver %s to %d webhooks", meta.ID, skipped)
+	}
+
+	return nil
+}
+
+// deliver POSTs body to w until the receiver accepts it, the backoff is exhausted or ctx is done. It
+// returns nil if ctx was done before the first attempt.
+func (d *Dispatcher) deliver(ctx context.Context, w *internal.Webhook, id, event string, body []byte) *internal.Delivery {
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	delivery := &internal.Delivery{
+		ID:        id,
+		WebhookID: w.ID,
+		Event:     event,
+		Created:   time.Now().UTC(),
+	}
+
+	attempts := d.backoff.MaxAttempts
+	if d.isDown(w.ID) {
+		attempts = 1
+	}
+
+	interval := d.backoff.InitialInterval
+
+	for {
+		delivery.Attempts++
+		delivery.StatusCode, delivery.Error = 0, ""
+
+		code, err := d.post(ctx, w, id, event, body)
+		if err == nil {
+			delivery.Status = internal.DeliverySucceeded
+			break
+		}
+
+		delivery.StatusCode = code
+		delivery.Error = err.Error()
+
+		if delivery.Attempts >= attempts {
+			delivery.Status = internal.DeliveryDeadLettered
+			break
+		}
+
+		if !sleep(ctx, interval) {
+			delivery.Status = internal.DeliveryDeadLettered
+			delivery.Error = fmt.Sprintf("%s; stopped retrying: %s", delivery.Error, ctx.Err())
+			break
+		}
+
+		interval = time.Duration(float64(interval) * d.backoff.Multiplier)
+		if interval > d.backoff.MaxInterval {
+			interval = d.backoff.MaxInterval
+		}
+	}
+
+	if delivery.Status == internal.DeliveryDeadLettered {
+		delivery.Payload = string(body)
+	}
+
+	d.setDown(w.ID, delivery.Status == internal.DeliveryDeadLettered)
+	delivery.Completed = time.Now().UTC()
+
+	return delivery
+}
+
+// sleep waits for d, returning false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// isDown reports whether a delivery to a webhook was dead-lettered within downFor
+func (d *Dispatcher) isDown(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	at, ok := d.down[id]
+	return ok && time.Since(at) < downFor
+}
+
+// setDown records whether the last delivery to a webhook was dead-lettered
+func (d *Dispatcher) setDown(id string, down bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if down {
+		d.down[id] = time.Now()
+	} else {
+		delete(d.down, id)
+	}
+}
+
+// post makes a single attempt to deliver body to w, returning the status code of the response
+func (d *Dispatcher) post(ctx context.Context, w *internal.Webhook, id, event string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, errors.Wrap(err, "Could not create request")
+	}
+
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, id)
+	req.Header.Set(SignatureHeader, Sign(w.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	// Drain the body so the connection can be reused
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign returns the value of the SignatureHeader for body sent to a webhook with secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the value of the SignatureHeader for body sent to a webhook with
+// secret. Receivers use it to check that deliveries are genuine.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
@@ -0,0 +1,339 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benjaminbartels/todo/internal"
+	"github.com/benjaminbartels/todo/internal/domain"
+	"github.com/benjaminbartels/todo/internal/webhook"
+)
+
+// testBackoff retries quickly so tests do not wait
+var testBackoff = webhook.Backoff{
+	InitialInterval: time.Millisecond,
+	MaxInterval:     4 * time.Millisecond,
+	Multiplier:      2,
+	MaxAttempts:     3,
+}
+
+func TestDispatcher(t *testing.T) {
+	t.Run("SignedDelivery", testSignedDelivery)
+	t.Run("RetryThenSucceed", testRetryThenSucceed)
+	t.Run("DeadLetter", testDeadLetter)
+	t.Run("EventFilter", testEventFilter)
+	t.Run("AlreadyDelivered", testAlreadyDelivered)
+	t.Run("Concurrent", testConcurrent)
+	t.Run("Deadline", testDeadline)
+	t.Run("ContextDone", testContextDone)
+	t.Run("Down", testDown)
+}
+
+// completed returns the Completed event of a ToDo in the work list
+func completed() domain.Event {
+	return completedIn("e1")
+}
+
+// completedIn returns the Completed event of a ToDo in the work list, from the stream record with ID id
+func completedIn(id string) domain.Event {
+	at := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	return domain.Events(id, at, &internal.ToDo{ListID: "work", ID: "1", Title: "Foo"},
+		&internal.ToDo{ListID: "work", ID: "1", Title: "Foo", Completed: true})[0]
+}
+
+// webhooksFor returns a WebhookRepoMock holding a webhook of the work list for each URL
+func webhooksFor(t *testing.T, events []string, urls ...string) *WebhookRepoMock {
+	return &WebhookRepoMock{
+		GetByListFn: func(listID string) ([]internal.Webhook, error) {
+			if listID != "work" {
+				t.Fatalf("Expected webhooks of list work, got %s", listID)
+			}
+
+			webhooks := []internal.Webhook{}
+			for i, u := range urls {
+				webhooks = append(webhooks, internal.Webhook{
+					ID:     string(rune('a' + i)),
+					ListID: "work",
+					URL:    u,
+					Events: events,
+					Secret: "secret",
+				})
+			}
+			return webhooks, nil
+		},
+	}
+}
+
+func testSignedDelivery(t *testing.T) {
+
+	var (
+		body    []byte
+		headers http.Header
+	)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		headers = r.Header
+	}))
+	defer receiver.Close()
+
+	repo := webhooksFor(t, nil, receiver.URL)
+
+	if err := webhook.NewDispatcherWithBackoff(repo, receiver.Client(), testBackoff).Handle(completed()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !webhook.Verify("secret", body, headers.Get(webhook.SignatureHeader)) {
+		t.Fatalf("Expected valid signature, got %s", headers.Get(webhook.SignatureHeader))
+	}
+
+	if webhook.Verify("other", body, headers.Get(webhook.SignatureHeader)) {
+		t.Fatal("Expected signature not to be valid with another secret")
+	}
+
+	if headers.Get(webhook.EventHeader) != domain.CompletedEvent || headers.Get(webhook.DeliveryHeader) != "e1:Completed" {
+		t.Fatalf("Unexpected headers %v", headers)
+	}
+
+	var payload struct {
+		ID    string `json:"id"`
+		Event string `json:"event"`
+		Data  struct {
+			ToDo internal.ToDo `json:"todo"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if payload.ID != "e1:Completed" || payload.Event != domain.CompletedEvent || !payload.Data.ToDo.Completed {
+		t.Fatalf("Unexpected payload %s", body)
+	}
+
+	d := repo.Deliveries["a/e1:Completed"]
+	if d.Status != internal.DeliverySucceeded || d.Attempts != 1 || d.Payload != "" {
+		t.Fatalf("Unexpected delivery %+v", d)
+	}
+}
+
+func testRetryThenSucceed(t *testing.T) {
+
+	var calls int32
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	repo := webhooksFor(t, nil, receiver.URL)
+
+	if err := webhook.NewDispatcherWithBackoff(repo, receiver.Client(), testBackoff).Handle(completed()); err != nil {
+		t.Fatal(err)
+	}
+
+	d := repo.Deliveries["a/e1:Completed"]
+	if d.Status != internal.DeliverySucceeded || d.Attempts != 3 || d.Error != "" {
+		t.Fatalf("Unexpected delivery %+v", d)
+	}
+}
+
+func testDeadLetter(t *testing.T) {
+
+	var calls int32
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+
+	repo := webhooksFor(t, nil, failing.URL, ok.URL)
+
+	if err := webhook.NewDispatcherWithBackoff(repo, http.DefaultClient, testBackoff).Handle(completed()); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != int32(testBackoff.MaxAttempts) {
+		t.Fatalf("Expected %d attempts, got %d", testBackoff.MaxAttempts, calls)
+	}
+
+	d := repo.Deliveries["a/e1:Completed"]
+	if d.Status != internal.DeliveryDeadLettered || d.StatusCode != http.StatusInternalServerError ||
+		d.Error == "" || d.Payload == "" {
+		t.Fatalf("Unexpected delivery %+v", d)
+	}
+
+	if repo.Deliveries["b/e1:Completed"].Status != internal.DeliverySucceeded {
+		t.Fatal("Expected delivery to the other webhook to succeed")
+	}
+}
+
+func testEventFilter(t *testing.T) {
+
+	invoked := false
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		invoked = true
+	}))
+	defer receiver.Close()
+
+	repo := webhooksFor(t, []string{domain.DeletedEvent}, receiver.URL)
+
+	if err := webhook.NewDispatcherWithBackoff(repo, receiver.Client(), testBackoff).Handle(completed()); err != nil {
+		t.Fatal(err)
+	}
+
+	if invoked {
+		t.Fatal("Expected receiver not to be sent an event it did not subscribe to")
+	}
+
+	if len(repo.Deliveries) != 0 {
+		t.Fatalf("Expected no deliveries, got %d", len(repo.Deliveries))
+	}
+}
+
+func testAlreadyDelivered(t *testing.T) {
+
+	var calls int32
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer receiver.Close()
+
+	repo := webhooksFor(t, nil, receiver.URL)
+	d := webhook.NewDispatcherWithBackoff(repo, receiver.Client(), testBackoff)
+
+	// Stream records are delivered again when a batch is retried
+	for i := 0; i < 2; i++ {
+		if err := d.Handle(completed()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("Expected 1 delivery, got %d", calls)
+	}
+}
+
+func testConcurrent(t *testing.T) {
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	repo := webhooksFor(t, nil, slow.URL, slow.URL, slow.URL)
+
+	start := time.Now()
+
+	if err := webhook.NewDispatcherWithBackoff(repo, slow.Client(), testBackoff).Handle(completed()); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Fatalf("Expected deliveries to be made concurrently, took %s", elapsed)
+	}
+
+	if len(repo.Deliveries) != 3 {
+		t.Fatalf("Expected 3 deliveries, got %d", len(repo.Deliveries))
+	}
+}
+
+func testDeadline(t *testing.T) {
+
+	// The receiver does not respond until the test ends
+	release := make(chan struct{})
+
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hanging.Close()
+	defer close(release)
+
+	repo := webhooksFor(t, nil, hanging.URL)
+
+	// Deliveries stop 10 seconds before the deadline, leaving time to save them
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second+100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := webhook.NewDispatcherWithBackoff(repo, hanging.Client(), testBackoff).HandleContext(ctx, completed()); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected delivery to stop at the deadline, took %s", elapsed)
+	}
+
+	d := repo.Deliveries["a/e1:Completed"]
+	if d.Status != internal.DeliveryDeadLettered || d.Attempts != 1 || d.Payload == "" {
+		t.Fatalf("Unexpected delivery %+v", d)
+	}
+}
+
+func testContextDone(t *testing.T) {
+
+	invoked := false
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		invoked = true
+	}))
+	defer receiver.Close()
+
+	repo := webhooksFor(t, nil, receiver.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The batch is retried, so the event is delivered when it is handled again
+	if err := webhook.NewDispatcherWithBackoff(repo, receiver.Client(), testBackoff).HandleContext(ctx, completed()); err == nil {
+		t.Fatal("Expected error")
+	}
+
+	if invoked || len(repo.Deliveries) != 0 {
+		t.Fatal("Expected no delivery once the context is done")
+	}
+}
+
+func testDown(t *testing.T) {
+
+	var calls int32
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	repo := webhooksFor(t, nil, failing.URL)
+	d := webhook.NewDispatcherWithBackoff(repo, failing.Client(), testBackoff)
+
+	for _, id := range []string{"e1", "e2"} {
+		if err := d.Handle(completedIn(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Once a delivery is dead-lettered the next is only attempted once
+	if calls != int32(testBackoff.MaxAttempts)+1 {
+		t.Fatalf("Expected %d attempts, got %d", testBackoff.MaxAttempts+1, calls)
+	}
+
+	if d := repo.Deliveries["a/e2:Completed"]; d.Status != internal.DeliveryDeadLettered || d.Attempts != 1 {
+		t.Fatalf("Unexpected delivery %+v", d)
+	}
+}
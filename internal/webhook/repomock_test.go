@@ -0,0 +1,61 @@
+package webhook_test
+
+import (
+	"github.com/benjaminbartels/todo/internal"
+)
+
+// WebhookRepoMock is used to mock a WebhookRepo. Deliveries are kept in memory.
+type WebhookRepoMock struct {
+	GetByListFn      func(string) ([]internal.Webhook, error)
+	Deliveries       map[string]internal.Delivery
+	GetByListInvoked bool
+}
+
+// Get is not used by the dispatcher
+func (m *WebhookRepoMock) Get(id string) (*internal.Webhook, error) {
+	panic("not implemented")
+}
+
+// GetByList returns the webhooks subscribed to a list
+func (m *WebhookRepoMock) GetByList(listID string) ([]internal.Webhook, error) {
+	m.GetByListInvoked = true
+	return m.GetByListFn(listID)
+}
+
+// Save is not used by the dispatcher
+func (m *WebhookRepoMock) Save(webhook *internal.Webhook) error {
+	panic("not implemented")
+}
+
+// Delete is not used by the dispatcher
+func (m *WebhookRepoMock) Delete(id string) error {
+	panic("not implemented")
+}
+
+// GetDelivery returns a delivery of a webhook by its ID
+func (m *WebhookRepoMock) GetDelivery(webhookID, id string) (*internal.Delivery, error) {
+	if d, ok := m.Deliveries[webhookID+"/"+id]; ok {
+		return &d, nil
+	}
+	return nil, nil
+}
+
+// GetDeliveries returns the deliveries of a webhook
+func (m *WebhookRepoMock) GetDeliveries(webhookID string) ([]internal.Delivery, error) {
+	deliveries := []internal.Delivery{}
+	for _, d := range m.Deliveries {
+		if d.WebhookID == webhookID {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
+
+// SaveDelivery stores a delivery
+func (m *WebhookRepoMock) SaveDelivery(delivery *internal.Delivery) error {
+	if m.Deliveries == nil {
+		m.Deliveries = make(map[string]internal.Delivery)
+	}
+	m.Deliveries[delivery.WebhookID+"/"+delivery.ID] = *delivery
+	return nil
+}
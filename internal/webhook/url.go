@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPrivateHost is returned for webhook URLs, and connections made by deliveries, to hosts that are not
+// public, such as loopback, private and link-local addresses
+var ErrPrivateHost = errors.New("host is not public")
+
+// privateNets are the address ranges, besides loopback, link-local and multicast addresses, that are not
+// reachable from the internet
+var privateNets = parseCIDRs(
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"fc00::/7",
+)
+
+// parseCIDRs parses address ranges that are known to be valid
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// Public reports whether deliveries can be sent to ip
+func Public(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckURL returns an error unless raw is an absolute http or https URL whose host is a name, or a public
+// address. Names are not resolved, as they can resolve differently when a delivery is made; instead the
+// client of NewDispatcher refuses to connect to addresses that are not public.
+func CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.Wrap(ErrPrivateHost, host)
+	}
+
+	if ip := net.ParseIP(host); ip != nil && !Public(ip) {
+		return errors.Wrap(ErrPrivateHost, host)
+	}
+
+	return nil
+}
+
+// NewClient returns the http.Client of NewDispatcher. It only connects to public addresses, including
+// when following redirects, so webhooks can not be used to reach the network the dispatcher runs in.
+func NewClient() *http.Client {
+	dialer := &net.Dialer{Timeout: requestTimeout, Control: publicOnly}
+
+	return &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: requestTimeout,
+			MaxIdleConnsPerHost: 2,
+		},
+	}
+}
+
+// publicOnly is the Control function of the dialer of NewClient, which is called with the address being
+// connected to once its name is resolved
+func publicOnly(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !Public(ip) {
+		return errors.Wrap(ErrPrivateHost, host)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package webhook_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/benjaminbartels/todo/internal/webhook"
+)
+
+func TestURL(t *testing.T) {
+	t.Run("CheckURL", testCheckURL)
+	t.Run("Public", testPublic)
+	t.Run("ClientRefusesPrivate", testClientRefusesPrivate)
+}
+
+func testCheckURL(t *testing.T) {
+
+	valid := []string{
+		"https://example.com/hook",
+		"http://hooks.example.com:8080/hook?a=b",
+		"https://93.184.216.34/hook",
+	}
+
+	for _, u := range valid {
+		if err := webhook.CheckURL(u); err != nil {
+			t.Fatalf("Expected %s to be valid, got %v", u, err)
+		}
+	}
+
+	invalid := []string{
+		"ftp://example.com/hook",
+		"/hook",
+		"http://localhost/hook",
+		"http://LOCALHOST./hook",
+		"http://app.localhost/hook",
+		"http://127.0.0.1/hook",
+		"http://[::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[fe80::1]/hook",
+		"http://10.1.2.3/hook",
+		"http://172.16.0.1/hook",
+		"http://192.168.0.1/hook",
+		"http://100.64.0.1/hook",
+		"http://[fd00::1]/hook",
+		"http://0.0.0.0/hook",
+	}
+
+	for _, u := range invalid {
+		if err := webhook.CheckURL(u); err == nil {
+			t.Fatalf("Expected %s to be invalid", u)
+		}
+	}
+}
+
+func testPublic(t *testing.T) {
+
+	for ip, public := range map[string]bool{
+		"8.8.8.8":         true,
+		"172.32.0.1":      true,
+		"2606:4700::1111": true,
+		"127.0.0.2":       false,
+		"169.254.0.1":     false,
+		"172.31.255.255":  false,
+		"ff02::1":         false,
+		"::":              false,
+	} {
+		if webhook.Public(net.ParseIP(ip)) != public {
+			t.Fatalf("Expected Public(%s) to be %t", ip, public)
+		}
+	}
+}
+
+func testClientRefusesPrivate(t *testing.T) {
+
+	invoked := false
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		invoked = true
+	}))
+	defer receiver.Close()
+
+	// Names are checked once they resolve, so a public name can not point deliveries at a private address
+	_, err := webhook.NewClient().Get(receiver.URL)
+	if err == nil || !strings.Contains(err.Error(), webhook.ErrPrivateHost.Error()) {
+		t.Fatalf("Expected %v, got %v", webhook.ErrPrivateHost, err)
+	}
+
+	if invoked {
+		t.Fatal("Expected receiver on a loopback address not to be reached")
+	}
+}
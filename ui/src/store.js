@@ -14,7 +14,10 @@ const mutations = {
     state.todos = todos
   },
   addTodo(state, todo) {
-    state.todos.push(todo)
+    // The change may have been pushed before the response arrived
+    if (!state.todos.some(t => t.id === todo.id)) {
+      state.todos.push(todo)
+    }
   },
   removeTodo(state, todo) {
     state.todos.splice(state.todos.indexOf(todo), 1)
@@ -23,6 +26,18 @@ const mutations = {
     todo.title = title
     todo.completed = completed
   },
+  applyChange(state, { type, todo }) {
+    const i = state.todos.findIndex(t => t.id === todo.id)
+    if (type === 'deleted') {
+      if (i !== -1) {
+        state.todos.splice(i, 1)
+      }
+    } else if (i === -1) {
+      state.todos.push(todo)
+    } else {
+      state.todos.splice(i, 1, todo)
+    }
+  },
   populateError(state, errorMsg) {
     state.errorMsg = errorMsg
   }
@@ -39,6 +54,22 @@ const actions = {
         populateError(commit,e)
       })
   },
+  subscribe({ commit, dispatch }) {
+    if (!process.env.VUE_APP_WEBSOCKET_URL) {
+      return
+    }
+    const socket = new WebSocket(process.env.VUE_APP_WEBSOCKET_URL)
+    socket.onmessage = e => {
+      commit('applyChange', JSON.parse(e.data))
+    }
+    // Changes made while disconnected are missed, so reload once reconnected
+    socket.onclose = () => {
+      setTimeout(() => {
+        dispatch('loadTodos')
+        dispatch('subscribe')
+      }, 5000)
+    }
+  },
   addTodo({ commit }, title) {
     HTTP
       .post('/todos', { title })